@@ -1,61 +1,44 @@
-// Package cache provides SQLite-backed caching for discovered CLI trees.
+// Package cache provides caching for discovered CLI trees, backed by SQLite
+// or, in builds without cgo, by plain files.
 package cache
 
 import (
+	"context"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3" // sqlite3 driver
-
+	"github.com/aallbrig/treemand/discovery"
 	"github.com/aallbrig/treemand/models"
 )
 
-// Cache stores and retrieves discovered CLI trees.
+// Cache stores and retrieves discovered CLI trees. It is safe for
+// concurrent use, including by several processes sharing a directory:
+// writes that read or change more than one record hold the cache lock.
 type Cache struct {
-	db *sql.DB
+	s       store
+	lock    *cacheLock
+	maxSize int64 // bytes Put may leave in the cache; 0 = unlimited
 }
 
-// Open opens (or creates) the cache database at dir/cache.db.
+// Open opens (or creates) the cache in dir with the DefaultBackend.
 func Open(dir string) (*Cache, error) {
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return nil, fmt.Errorf("create cache dir: %w", err)
-	}
-	dbPath := filepath.Join(dir, "cache.db")
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("open sqlite3: %w", err)
-	}
-	c := &Cache{db: db}
-	if err := c.migrate(); err != nil {
-		db.Close()
-		return nil, err
-	}
-	return c, nil
+	return OpenBackend(dir, "")
 }
 
-// Close closes the underlying database.
-func (c *Cache) Close() error { return c.db.Close() }
-
-const schema = `
-CREATE TABLE IF NOT EXISTS trees (
-key       TEXT PRIMARY KEY,
-cli       TEXT NOT NULL,
-version   TEXT NOT NULL,
-strategy  TEXT NOT NULL,
-data      TEXT NOT NULL,
-cached_at INTEGER NOT NULL
-);
-`
-
-func (c *Cache) migrate() error {
-	_, err := c.db.Exec(schema)
+// Close closes the underlying store.
+func (c *Cache) Close() error {
+	err := c.s.close()
+	if lockErr := c.lock.close(); err == nil {
+		err = lockErr
+	}
 	return err
 }
 
@@ -68,118 +51,680 @@ const cacheSchemaVersion = "v9"
 // auto-invalidate stale entries on upgrade without a manual schema bump.
 var TreemandVersion string
 
-// Key produces a cache key from cli name, version string, and strategies list.
-func Key(cli, version string, strategies []string) string {
-	s := cli + "|" + version + "|" + strings.Join(strategies, ",") + "|" + cacheSchemaVersion + "|" + TreemandVersion
+// Key produces a cache key from cli name, the binary it resolves to (see
+// ResolveBinary), version string, and strategies list. Including the binary
+// keeps apart the trees of different executables with the same name, such
+// as a system and a virtualenv install earlier on PATH.
+func Key(cli string, bin Binary, version string, strategies []string) string {
+	s := cli + "|" + bin.Path + "|" + bin.Hash + "|" + version + "|" + strings.Join(strategies, ",") +
+		"|" + cacheSchemaVersion + "|" + TreemandVersion
 	h := sha256.Sum256([]byte(s))
 	return fmt.Sprintf("%x", h[:8])
 }
 
 // Get retrieves a cached tree. Returns nil, nil if not found or expired.
 func (c *Cache) Get(key string, maxAge time.Duration) (*models.Node, error) {
-	row := c.db.QueryRow(`SELECT data, cached_at FROM trees WHERE key = ?`, key)
-	var data string
-	var cachedAt int64
-	if err := row.Scan(&data, &cachedAt); err == sql.ErrNoRows {
-		return nil, nil
-	} else if err != nil {
+	r, err := c.s.tree(key)
+	if err != nil || r == nil {
 		return nil, err
 	}
-	if maxAge > 0 && time.Since(time.Unix(cachedAt, 0)) > maxAge {
+	if maxAge > 0 && time.Since(r.cachedAt) > maxAge {
 		return nil, nil // expired
 	}
 	var node models.Node
-	if err := json.Unmarshal([]byte(data), &node); err != nil {
+	if err := json.Unmarshal(r.data, &node); err != nil {
 		return nil, err
 	}
+	c.touch(key)
 	return &node, nil
 }
 
-// Put stores a tree in the cache.
-func (c *Cache) Put(key, cli, version, strategy string, node *models.Node) error {
+// Peek retrieves a cached tree regardless of its age, like Get, but
+// without marking it used, so that looking trees over leaves which are
+// evicted first unchanged. Returns nil, nil if not found.
+func (c *Cache) Peek(key string) (*models.Node, error) {
+	r, err := c.s.tree(key)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	var node models.Node
+	if err := json.Unmarshal(r.data, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// Latest returns the most recently cached tree for cli regardless of its
+// version, strategy, or age. Returns nil, nil when nothing is cached for cli.
+// It is the baseline for incremental re-discovery after a CLI upgrade.
+func (c *Cache) Latest(cli string) (*models.Node, error) {
+	trees, err := c.s.trees()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range trees {
+		if t.cli == cli {
+			// Trees are listed newest first within each CLI.
+			return c.Get(t.key, 0)
+		}
+	}
+	return nil, nil
+}
+
+// touch marks the tree stored under key as just used. Errors are ignored:
+// they only make the tree look older to eviction.
+func (c *Cache) touch(key string) {
+	_ = c.s.touchTree(key, time.Now())
+}
+
+// Put stores a tree in the cache and as the snapshot of cli at version (see
+// History), then evicts least-recently-used trees if the cache has grown
+// past the size set with SetMaxSize. bin is the binary
+// the tree was discovered from; when it came from ResolveBinary, it is also
+// recorded as cli's current binary (see Stamp).
+func (c *Cache) Put(key, cli string, bin Binary, version, strategy string, node *models.Node) error {
 	data, err := json.Marshal(node)
 	if err != nil {
 		return err
 	}
-	_, err = c.db.Exec(
-		`INSERT OR REPLACE INTO trees (key, cli, version, strategy, data, cached_at) VALUES (?,?,?,?,?,?)`,
-		key, cli, version, strategy, string(data), time.Now().Unix(),
-	)
-	return err
+	now := time.Now()
+	return c.lock.do(func() error {
+		r := &treeRecord{
+			key: key, cli: cli, version: version, strategy: strategy, binary: bin,
+			cachedAt: now, usedAt: now, size: len(data), data: data,
+		}
+		err := c.s.putTree(r)
+		if err == nil {
+			err = c.putSnapshot(r)
+		}
+		if err == nil && bin.stamp != "" {
+			err = c.s.putStamp(cli, bin.stamp+"\n"+bin.Hash)
+		}
+		if err != nil || c.maxSize <= 0 {
+			return err
+		}
+		_, err = c.prune(c.maxSize)
+		return err
+	})
+}
+
+// SetMaxSize sets how many bytes of trees and help text Put may leave in
+// the cache before evicting; 0 (the default) means unlimited.
+func (c *Cache) SetMaxSize(bytes int64) { c.maxSize = bytes }
+
+// Size returns the bytes of trees and help text in the cache. The cache
+// takes more space on disk: it also holds indexes, TUI state, and free
+// pages.
+func (c *Cache) Size() (int64, error) { return c.s.size() }
+
+// PruneResult reports what Prune evicted.
+type PruneResult struct {
+	Trees int   // trees evicted
+	Freed int64 // bytes of trees and help text evicted
+	Size  int64 // bytes left in the cache
+}
+
+// Prune evicts trees, least recently used first, until the cache holds at
+// most maxBytes. Evicting the last tree of a CLI version also evicts the
+// help text cached for it. The most recently used tree is always kept, even
+// when it alone is over budget.
+func (c *Cache) Prune(maxBytes int64) (res PruneResult, err error) {
+	err = c.lock.do(func() error {
+		res, err = c.prune(maxBytes)
+		return err
+	})
+	return res, err
+}
+
+// prune is Prune for callers holding the lock.
+func (c *Cache) prune(maxBytes int64) (PruneResult, error) {
+	size, err := c.Size()
+	if err != nil || size <= maxBytes {
+		return PruneResult{Size: size}, err
+	}
+	res := PruneResult{Size: size}
+	trees, err := c.s.trees()
+	if err != nil {
+		return res, err
+	}
+	// Trees left per CLI version, whose help text goes with the last one.
+	left := make(map[[2]string]int)
+	for _, t := range trees {
+		left[[2]string{t.cli, t.version}]++
+	}
+	slices.SortStableFunc(trees, func(a, b treeRecord) int {
+		if n := a.usedAt.Compare(b.usedAt); n != 0 {
+			return n
+		}
+		return a.cachedAt.Compare(b.cachedAt)
+	})
+	for _, t := range trees[:max(len(trees)-1, 0)] {
+		if res.Size <= maxBytes {
+			break
+		}
+		if err := c.s.deleteTree(t.key); err != nil {
+			return res, err
+		}
+		freed := int64(t.size)
+		cv := [2]string{t.cli, t.version}
+		if left[cv]--; left[cv] == 0 {
+			help, err := c.s.helpSize(t.cli, t.version)
+			if err != nil {
+				return res, err
+			}
+			if err := c.s.deleteHelp(t.cli, t.version); err != nil {
+				return res, err
+			}
+			freed += help
+		}
+		res.Trees++
+		res.Freed += freed
+		res.Size -= freed
+	}
+	return res, nil
 }
 
+// Vacuum compacts the store so the space freed by evictions is returned to
+// the file system.
+func (c *Cache) Vacuum() error { return c.lock.do(c.s.compact) }
+
 // Delete removes an entry from the cache.
 func (c *Cache) Delete(key string) error {
-	_, err := c.db.Exec(`DELETE FROM trees WHERE key = ?`, key)
-	return err
+	return c.lock.do(func() error { return c.s.deleteTree(key) })
 }
 
 // Clear removes all entries from the cache.
 func (c *Cache) Clear() error {
-	_, err := c.db.Exec(`DELETE FROM trees`)
-	return err
+	return c.lock.do(func() error { return c.s.clear("") })
 }
 
 // ClearCLI removes all cached entries for a specific CLI name.
 func (c *Cache) ClearCLI(cli string) error {
-	_, err := c.db.Exec(`DELETE FROM trees WHERE cli = ?`, cli)
-	return err
+	return c.lock.do(func() error { return c.s.clear(cli) })
+}
+
+// helpPathKey joins a subcommand path (below the CLI name) into the string
+// help text is stored under. The root command is "".
+func helpPathKey(path []string) string {
+	return strings.Join(path, " ")
+}
+
+// PutHelp stores the raw help text for one command path of cli at version.
+// It is called for every command discovered, so it does not take the lock:
+// a single record is replaced atomically by every store.
+func (c *Cache) PutHelp(cli, version string, path []string, help string) error {
+	return c.s.putHelp(cli, version, helpPathKey(path), help, time.Now())
+}
+
+// GetHelp retrieves the raw help text stored for a command path.
+// Returns "", nil if not found or older than maxAge (0 = no expiry).
+func (c *Cache) GetHelp(cli, version string, path []string, maxAge time.Duration) (string, error) {
+	help, cachedAt, err := c.s.help(cli, version, helpPathKey(path))
+	if err != nil || help == "" {
+		return "", err
+	}
+	if maxAge > 0 && time.Since(cachedAt) > maxAge {
+		return "", nil
+	}
+	return help, nil
+}
+
+// HelpStore adapts the cached help text of one CLI version to the
+// discovery.HelpStore interface. Errors are swallowed: a failing cache
+// only means the help text is fetched from the CLI again.
+type HelpStore struct {
+	c       *Cache
+	cli     string
+	version string
+	maxAge  time.Duration
+}
+
+// HelpStore returns a store scoped to cli at version whose entries expire
+// after maxAge (0 = never).
+func (c *Cache) HelpStore(cli, version string, maxAge time.Duration) *HelpStore {
+	return &HelpStore{c: c, cli: cli, version: version, maxAge: maxAge}
+}
+
+// LoadHelp returns the stored help text for path, if any.
+func (s *HelpStore) LoadHelp(path []string) (string, bool) {
+	help, err := s.c.GetHelp(s.cli, s.version, path, s.maxAge)
+	if err != nil || help == "" {
+		return "", false
+	}
+	return help, true
+}
+
+// SaveHelp stores help text for path.
+func (s *HelpStore) SaveHelp(path []string, help string) {
+	_ = s.c.PutHelp(s.cli, s.version, path, help)
+}
+
+// PutState stores the interactive explorer's saved view state for cli.
+// data is opaque to the cache; package tui defines its format.
+func (c *Cache) PutState(cli string, data []byte) error {
+	return c.lock.do(func() error { return c.s.putState(cli, data, time.Now()) })
+}
+
+// GetState returns the view state saved for cli. Returns nil, nil if none.
+func (c *Cache) GetState(cli string) ([]byte, error) { return c.s.state(cli) }
+
+// StateStore adapts the saved view state of one CLI to the tui.StateStore
+// interface. Like HelpStore, it swallows errors: a failing cache only
+// means the explorer starts from its default view.
+type StateStore struct {
+	c   *Cache
+	cli string
+}
+
+// StateStore returns a store for the view state of cli.
+func (c *Cache) StateStore(cli string) *StateStore {
+	return &StateStore{c: c, cli: cli}
+}
+
+// LoadState returns the saved view state, if any.
+func (s *StateStore) LoadState() ([]byte, bool) {
+	data, err := s.c.GetState(s.cli)
+	if err != nil || data == nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// SaveState stores data as the view state.
+func (s *StateStore) SaveState(data []byte) {
+	_ = s.c.PutState(s.cli, data)
+}
+
+// AddFlagValue records that value was entered for flag of cli, counting
+// repeated uses.
+func (c *Cache) AddFlagValue(cli, flag, value string) error {
+	return c.lock.do(func() error { return c.s.addFlagValue(cli, flag, value, time.Now()) })
+}
+
+// FlagValues returns up to limit values previously entered for flag of cli,
+// most used first and most recent among equals.
+func (c *Cache) FlagValues(cli, flag string, limit int) ([]string, error) {
+	return c.s.flagValues(cli, flag, limit)
+}
+
+// maxFlagValues is how many remembered values ValueHistory offers per flag.
+const maxFlagValues = 10
+
+// ValueHistory adapts the remembered flag values of one CLI to the
+// tui.ValueHistory interface. Like HelpStore, it swallows errors: a failing
+// cache only means no suggestions are offered.
+type ValueHistory struct {
+	c   *Cache
+	cli string
+}
+
+// ValueHistory returns the flag value history of cli.
+func (c *Cache) ValueHistory(cli string) *ValueHistory {
+	return &ValueHistory{c: c, cli: cli}
+}
+
+// FlagValues returns the values remembered for flag, most used first.
+func (h *ValueHistory) FlagValues(flag string) []string {
+	values, _ := h.c.FlagValues(h.cli, flag, maxFlagValues)
+	return values
+}
+
+// AddFlagValue remembers value for flag.
+func (h *ValueHistory) AddFlagValue(flag, value string) {
+	_ = h.c.AddFlagValue(h.cli, flag, value)
+}
+
+// AddUse counts item of cli as used once more: a command, by its full
+// command ("git remote add"), or a flag, by its command's and its name
+// ("git commit --message").
+func (c *Cache) AddUse(cli, item string) error {
+	return c.lock.do(func() error { return c.s.addUse(cli, item, time.Now()) })
+}
+
+// Uses returns how often each of cli's commands and flags was used, by
+// item as AddUse counted it.
+func (c *Cache) Uses(cli string) (map[string]int, error) { return c.s.uses(cli) }
+
+// UsageLog adapts the usage counts of one CLI to the tui.UsageLog
+// interface. Like ValueHistory, it swallows errors: a failing cache only
+// means the TUI's "used" order is the help's.
+type UsageLog struct {
+	c   *Cache
+	cli string
+}
+
+// UsageLog returns the usage counts of cli.
+func (c *Cache) UsageLog(cli string) *UsageLog {
+	return &UsageLog{c: c, cli: cli}
+}
+
+// Uses returns how often each command and flag was used.
+func (u *UsageLog) Uses() map[string]int {
+	uses, _ := u.c.Uses(u.cli)
+	return uses
+}
+
+// AddUse counts item as used once more.
+func (u *UsageLog) AddUse(item string) {
+	_ = u.c.AddUse(u.cli, item)
+}
+
+// PutNote stores note on command, the full command of one of cli's
+// commands ("git remote add"). An empty note deletes it. Notes outlive
+// Clear and ClearCLI.
+func (c *Cache) PutNote(cli, command, note string) error {
+	return c.lock.do(func() error { return c.s.putNote(cli, command, note, time.Now()) })
+}
+
+// Notes returns the notes on cli's commands, by full command.
+func (c *Cache) Notes(cli string) (map[string]string, error) { return c.s.notes(cli) }
+
+// NoteStore adapts the notes of one CLI to the tui.NoteStore interface.
+// Unlike StateStore it returns errors from saving, so a note that could
+// not be kept is reported rather than lost silently.
+type NoteStore struct {
+	c   *Cache
+	cli string
+}
+
+// NoteStore returns a store for the notes on cli's commands.
+func (c *Cache) NoteStore(cli string) *NoteStore {
+	return &NoteStore{c: c, cli: cli}
+}
+
+// Notes returns the saved notes, by full command; none if they cannot be
+// read.
+func (s *NoteStore) Notes() map[string]string {
+	notes, _ := s.c.Notes(s.cli)
+	return notes
+}
+
+// SaveNote stores note on command, deleting it when note is "".
+func (s *NoteStore) SaveNote(command, note string) error {
+	return s.c.PutNote(s.cli, command, note)
+}
+
+// PutSnippet saves command as the snippet name of cli, replacing any
+// snippet of that name. Like notes, snippets outlive Clear and ClearCLI.
+func (c *Cache) PutSnippet(cli, name, command string) error {
+	return c.lock.do(func() error { return c.s.putSnippet(cli, name, command, time.Now()) })
+}
+
+// DeleteSnippet deletes the snippet name of cli.
+func (c *Cache) DeleteSnippet(cli, name string) error {
+	return c.lock.do(func() error { return c.s.putSnippet(cli, name, "", time.Now()) })
+}
+
+// Snippets returns the snippets of cli: their commands, by name.
+func (c *Cache) Snippets(cli string) (map[string]string, error) { return c.s.snippets(cli) }
+
+// SnippetStore adapts the snippets of one CLI to the tui.SnippetStore
+// interface. Like NoteStore, it returns errors from saving.
+type SnippetStore struct {
+	c   *Cache
+	cli string
+}
+
+// SnippetStore returns a store for the snippets of cli.
+func (c *Cache) SnippetStore(cli string) *SnippetStore {
+	return &SnippetStore{c: c, cli: cli}
+}
+
+// Snippets returns the saved snippets, by name; none if they cannot be
+// read.
+func (s *SnippetStore) Snippets() map[string]string {
+	snippets, _ := s.c.Snippets(s.cli)
+	return snippets
+}
+
+// SaveSnippet saves command as the snippet name.
+func (s *SnippetStore) SaveSnippet(name, command string) error {
+	return s.c.PutSnippet(s.cli, name, command)
+}
+
+// DeleteSnippet deletes the snippet name.
+func (s *SnippetStore) DeleteSnippet(name string) error {
+	return s.c.DeleteSnippet(s.cli, name)
+}
+
+// PutEnv sets the environment variable name of cli's commands run or
+// copied from the TUI to value, deleting it when value is "". Like notes,
+// the variables outlive Clear and ClearCLI.
+func (c *Cache) PutEnv(cli, name, value string) error {
+	return c.lock.do(func() error { return c.s.putEnv(cli, name, value, time.Now()) })
+}
+
+// Env returns the environment variables of cli's commands, by name.
+func (c *Cache) Env(cli string) (map[string]string, error) { return c.s.env(cli) }
+
+// EnvStore adapts the environment variables of one CLI to the
+// tui.EnvStore interface.
+type EnvStore struct {
+	c   *Cache
+	cli string
+}
+
+// EnvStore returns a store for the environment variables of cli.
+func (c *Cache) EnvStore(cli string) *EnvStore {
+	return &EnvStore{c: c, cli: cli}
+}
+
+// Env returns the saved variables, by name; none if they cannot be read.
+func (s *EnvStore) Env() map[string]string {
+	env, _ := s.c.Env(s.cli)
+	return env
+}
+
+// SetEnv sets the variable name to value, deleting it when value is "".
+func (s *EnvStore) SetEnv(name, value string) error {
+	return s.c.PutEnv(s.cli, name, value)
+}
+
+// maxRuns is how many runs of each CLI AddRun keeps.
+const maxRuns = 50
+
+// AddRun records run of cli, dropping its oldest runs beyond the most
+// recent 50. Unlike notes and snippets, runs go with Clear and ClearCLI.
+func (c *Cache) AddRun(cli string, run models.Run) error {
+	return c.lock.do(func() error { return c.s.addRun(cli, run, maxRuns) })
+}
+
+// Runs returns the recorded runs of cli, newest first.
+func (c *Cache) Runs(cli string) ([]models.Run, error) { return c.s.runs(cli) }
+
+// RunLog adapts the runs of one CLI to the tui.RunLog interface.
+type RunLog struct {
+	c   *Cache
+	cli string
+}
+
+// RunLog returns a log of the runs of cli.
+func (c *Cache) RunLog(cli string) *RunLog {
+	return &RunLog{c: c, cli: cli}
+}
+
+// Runs returns the recorded runs, newest first; none if they cannot be
+// read.
+func (l *RunLog) Runs() []models.Run {
+	runs, _ := l.c.Runs(l.cli)
+	return runs
+}
+
+// AddRun records run.
+func (l *RunLog) AddRun(run models.Run) error {
+	return l.c.AddRun(l.cli, run)
 }
 
 // ListCLIs returns the names of all CLIs currently in the cache.
 func (c *Cache) ListCLIs() ([]string, error) {
-	rows, err := c.db.Query(`SELECT DISTINCT cli FROM trees ORDER BY cli`)
+	trees, err := c.s.trees()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 	var names []string
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			return nil, err
+	for _, t := range trees {
+		if len(names) == 0 || names[len(names)-1] != t.cli {
+			names = append(names, t.cli)
 		}
-		names = append(names, name)
 	}
-	return names, rows.Err()
+	return names, nil
 }
 
 // Entry holds display information for a cached tree entry.
 type Entry struct {
+	Key       string // the tree's cache key, for Get and Peek
 	CLI       string
 	Version   string
 	Strategy  string
+	Binary    Binary // zero for trees cached before binaries were recorded
 	CachedAt  time.Time
+	UsedAt    time.Time
 	SizeBytes int
 }
 
-// ListEntries returns all cache entries with metadata for display.
+// ListEntries returns all cache entries with metadata for display, by CLI
+// and newest first within each CLI.
 func (c *Cache) ListEntries() ([]Entry, error) {
-	rows, err := c.db.Query(`
-		SELECT cli, version, strategy, cached_at, length(data)
-		FROM trees
-		ORDER BY cli, cached_at DESC`)
+	trees, err := c.s.trees()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 	var entries []Entry
-	for rows.Next() {
-		var e Entry
-		var ts int64
-		if err := rows.Scan(&e.CLI, &e.Version, &e.Strategy, &ts, &e.SizeBytes); err != nil {
-			return nil, err
+	for _, t := range trees {
+		entries = append(entries, Entry{
+			Key:       t.key,
+			CLI:       t.cli,
+			Version:   t.version,
+			Strategy:  t.strategy,
+			Binary:    t.binary,
+			CachedAt:  t.cachedAt,
+			UsedAt:    t.usedAt,
+			SizeBytes: t.size,
+		})
+	}
+	return entries, nil
+}
+
+// LatestEntry returns the entry of the tree Latest returns for cli, and
+// false when nothing is cached for cli.
+func (c *Cache) LatestEntry(cli string) (Entry, bool, error) {
+	entries, err := c.ListEntries()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	for _, e := range entries {
+		if e.CLI == cli {
+			return e, true, nil
 		}
-		e.CachedAt = time.Unix(ts, 0)
-		entries = append(entries, e)
 	}
-	return entries, rows.Err()
+	return Entry{}, false, nil
+}
+
+// PutStamp records the BinaryStamp of the binary cli's cached tree was
+// discovered from.
+func (c *Cache) PutStamp(cli, stamp string) error {
+	return c.lock.do(func() error { return c.s.putStamp(cli, stamp) })
+}
+
+// Stamp returns the BinaryStamp recorded for cli. Returns "", nil if none.
+func (c *Cache) Stamp(cli string) (string, error) {
+	stamp, _, err := c.binaryRecord(cli)
+	return stamp, err
+}
+
+// binaryRecord returns the BinaryStamp recorded for cli and, when recorded
+// by Put, the Hash of the binary it stamped. The two are stored together
+// as "stamp\nhash".
+func (c *Cache) binaryRecord(cli string) (stamp, hash string, err error) {
+	rec, err := c.s.stamp(cli)
+	stamp, hash, _ = strings.Cut(rec, "\n")
+	return stamp, hash, err
+}
+
+// BinaryStamp identifies the binary cli resolves to on PATH by its path,
+// size and modification time, so that replacing or upgrading it changes
+// the stamp without running it. Returns "" when cli is not on PATH.
+func BinaryStamp(cli string) string {
+	path, err := exec.LookPath(cli)
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s|%d|%d", path, info.Size(), info.ModTime().UnixNano())
+}
+
+// Binary identifies the executable a CLI name resolved to.
+type Binary struct {
+	// Path is the absolute path of the executable, symlinks resolved.
+	Path string
+	// Hash is a SHA-256 of the executable's contents, in hex and
+	// shortened like cache keys.
+	Hash string
+
+	stamp string // BinaryStamp when resolved, recorded by Put
+}
+
+// String returns the path and the start of the hash, or "" for the zero
+// Binary.
+func (b Binary) String() string {
+	if b.Path == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", b.Path, b.Hash[:min(8, len(b.Hash))])
+}
+
+// ResolveBinary finds the executable cli resolves to on PATH and hashes it.
+// Hashing a large binary takes a while, so the hash recorded by Put is
+// reused while the binary's BinaryStamp is unchanged.
+func (c *Cache) ResolveBinary(cli string) (Binary, error) {
+	path, err := exec.LookPath(cli)
+	if err != nil {
+		return Binary{}, err
+	}
+	stamp := BinaryStamp(cli)
+	if path, err = filepath.Abs(path); err != nil {
+		return Binary{}, err
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return Binary{}, err
+	}
+	bin := Binary{Path: path, stamp: stamp}
+	if recorded, hash, err := c.binaryRecord(cli); err == nil && recorded == stamp && hash != "" {
+		bin.Hash = hash
+		return bin, nil
+	}
+	if bin.Hash, err = hashFile(path); err != nil {
+		return Binary{}, fmt.Errorf("hash %s: %w", path, err)
+	}
+	return bin, nil
+}
+
+// hashFile returns the SHA-256 of the file at path, shortened like Key.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)[:8]), nil
 }
 
-// CLIVersion attempts to get the version string for a CLI by running <cli> --version.
-func CLIVersion(cli string) string {
-	cmd := exec.Command(cli, "--version") //nolint:gosec
-	out, err := cmd.CombinedOutput()
+// CLIVersion attempts to get the version string for a CLI by running
+// <cli> --version, the way discovery runs it under ctx (see
+// discovery.WithVia).
+func CLIVersion(ctx context.Context, cli string) string {
+	path, err := discovery.BinaryPath(ctx, cli)
+	if err != nil {
+		return "unknown"
+	}
+	out, err := discovery.Command(ctx, path, "--version").CombinedOutput()
 	if err != nil || len(out) == 0 {
 		return "unknown"
 	}
@@ -1,7 +1,13 @@
 package cache_test
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,9 +33,9 @@ func TestCachePutGet(t *testing.T) {
 	defer c.Close()
 
 	node := &models.Node{Name: "git", Description: "version control"}
-	key := cache.Key("git", "2.40.0", []string{"help"})
+	key := cache.Key("git", cache.Binary{}, "2.40.0", []string{"help"})
 
-	if err := c.Put(key, "git", "2.40.0", "help", node); err != nil {
+	if err := c.Put(key, "git", cache.Binary{}, "2.40.0", "help", node); err != nil {
 		t.Fatalf("Put() error: %v", err)
 	}
 
@@ -71,8 +77,8 @@ func TestCacheGet_expired(t *testing.T) {
 	defer c.Close()
 
 	node := &models.Node{Name: "git"}
-	key := cache.Key("git", "2.40.0", []string{"help"})
-	if err := c.Put(key, "git", "2.40.0", "help", node); err != nil {
+	key := cache.Key("git", cache.Binary{}, "2.40.0", []string{"help"})
+	if err := c.Put(key, "git", cache.Binary{}, "2.40.0", "help", node); err != nil {
 		t.Fatalf("Put() error: %v", err)
 	}
 
@@ -95,8 +101,8 @@ func TestCacheDelete(t *testing.T) {
 	defer c.Close()
 
 	node := &models.Node{Name: "git"}
-	key := cache.Key("git", "2.40.0", []string{"help"})
-	_ = c.Put(key, "git", "2.40.0", "help", node)
+	key := cache.Key("git", cache.Binary{}, "2.40.0", []string{"help"})
+	_ = c.Put(key, "git", cache.Binary{}, "2.40.0", "help", node)
 	_ = c.Delete(key)
 
 	got, err := c.Get(key, 0)
@@ -118,13 +124,13 @@ func TestCacheClear(t *testing.T) {
 
 	node := &models.Node{Name: "git"}
 	for _, v := range []string{"1.0", "2.0"} {
-		k := cache.Key("git", v, []string{"help"})
-		_ = c.Put(k, "git", v, "help", node)
+		k := cache.Key("git", cache.Binary{}, v, []string{"help"})
+		_ = c.Put(k, "git", cache.Binary{}, v, "help", node)
 	}
 	if err := c.Clear(); err != nil {
 		t.Fatalf("Clear() error: %v", err)
 	}
-	k := cache.Key("git", "1.0", []string{"help"})
+	k := cache.Key("git", cache.Binary{}, "1.0", []string{"help"})
 	got, _ := c.Get(k, 0)
 	if got != nil {
 		t.Error("expected nil after clear")
@@ -132,9 +138,9 @@ func TestCacheClear(t *testing.T) {
 }
 
 func TestKey(t *testing.T) {
-	k1 := cache.Key("git", "2.40", []string{"help"})
-	k2 := cache.Key("git", "2.40", []string{"help"})
-	k3 := cache.Key("git", "2.41", []string{"help"})
+	k1 := cache.Key("git", cache.Binary{}, "2.40", []string{"help"})
+	k2 := cache.Key("git", cache.Binary{}, "2.40", []string{"help"})
+	k3 := cache.Key("git", cache.Binary{}, "2.41", []string{"help"})
 	if k1 != k2 {
 		t.Error("identical inputs should produce same key")
 	}
@@ -145,12 +151,12 @@ func TestKey(t *testing.T) {
 
 func TestCLIVersion(t *testing.T) {
 	// echo is always available and supports --version on most systems
-	v := cache.CLIVersion("go")
+	v := cache.CLIVersion(context.Background(), "go")
 	if v == "" {
 		t.Error("expected non-empty version for go")
 	}
 	// Non-existent CLI should return "unknown"
-	v2 := cache.CLIVersion("nonexistent_cli_99999")
+	v2 := cache.CLIVersion(context.Background(), "nonexistent_cli_99999")
 	if v2 != "unknown" {
 		t.Errorf("expected 'unknown' for nonexistent CLI, got %q", v2)
 	}
@@ -166,19 +172,19 @@ func TestCacheClearCLI(t *testing.T) {
 
 	node := &models.Node{Name: "git"}
 	for _, ver := range []string{"1.0", "2.0"} {
-		k := cache.Key("git", ver, []string{"help"})
-		_ = c.Put(k, "git", ver, "help", node)
+		k := cache.Key("git", cache.Binary{}, ver, []string{"help"})
+		_ = c.Put(k, "git", cache.Binary{}, ver, "help", node)
 	}
 	// Put an entry for a different CLI too.
-	kGo := cache.Key("go", "1.22", []string{"help"})
-	_ = c.Put(kGo, "go", "1.22", "help", &models.Node{Name: "go"})
+	kGo := cache.Key("go", cache.Binary{}, "1.22", []string{"help"})
+	_ = c.Put(kGo, "go", cache.Binary{}, "1.22", "help", &models.Node{Name: "go"})
 
 	if err := c.ClearCLI("git"); err != nil {
 		t.Fatalf("ClearCLI() error: %v", err)
 	}
 
 	// git entries should be gone.
-	k := cache.Key("git", "1.0", []string{"help"})
+	k := cache.Key("git", cache.Binary{}, "1.0", []string{"help"})
 	got, _ := c.Get(k, 0)
 	if got != nil {
 		t.Error("expected nil for cleared CLI 'git'")
@@ -210,11 +216,11 @@ func TestCacheListCLIs(t *testing.T) {
 
 	// Add two distinct CLIs.
 	for _, name := range []string{"aws", "git"} {
-		k := cache.Key(name, "1.0", []string{"help"})
-		_ = c.Put(k, name, "1.0", "help", &models.Node{Name: name})
+		k := cache.Key(name, cache.Binary{}, "1.0", []string{"help"})
+		_ = c.Put(k, name, cache.Binary{}, "1.0", "help", &models.Node{Name: name})
 		// Second version for git — should not duplicate in list.
-		k2 := cache.Key(name, "2.0", []string{"help"})
-		_ = c.Put(k2, name, "2.0", "help", &models.Node{Name: name})
+		k2 := cache.Key(name, cache.Binary{}, "2.0", []string{"help"})
+		_ = c.Put(k2, name, cache.Binary{}, "2.0", "help", &models.Node{Name: name})
 	}
 
 	clis, err = c.ListCLIs()
@@ -233,3 +239,753 @@ func TestCacheListCLIs(t *testing.T) {
 func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
+
+func TestCacheLatest(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer c.Close()
+
+	if got, err := c.Latest("git"); err != nil || got != nil {
+		t.Fatalf("Latest() on empty cache = %v, %v", got, err)
+	}
+	old := &models.Node{Name: "git", Description: "old"}
+	if err := c.Put(cache.Key("git", cache.Binary{}, "1.0", []string{"help"}), "git", cache.Binary{}, "1.0", "help", old); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Latest("git")
+	if err != nil || got == nil || got.Description != "old" {
+		t.Fatalf("Latest() = %v, %v", got, err)
+	}
+}
+
+func TestCachePeek(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer c.Close()
+
+	node := &models.Node{Name: "git", Description: "peeked"}
+	if err := c.Put(cache.Key("git", cache.Binary{}, "1.0", nil), "git", cache.Binary{}, "1.0", "help", node); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := c.ListEntries()
+	if len(before) != 1 {
+		t.Fatalf("ListEntries() = %+v, want one tree", before)
+	}
+	got, err := c.Peek(before[0].Key)
+	if err != nil || got == nil || got.Description != "peeked" {
+		t.Fatalf("Peek() = %v, %v", got, err)
+	}
+	if after, _ := c.ListEntries(); !after[0].UsedAt.Equal(before[0].UsedAt) {
+		t.Errorf("Peek() marked the tree used: %v, was %v", after[0].UsedAt, before[0].UsedAt)
+	}
+	if got, err := c.Peek("nosuch"); err != nil || got != nil {
+		t.Errorf("Peek(nosuch) = %v, %v, want nil, nil", got, err)
+	}
+}
+
+func TestCacheLatestEntry(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer c.Close()
+
+	bin := cache.Binary{Path: "/usr/bin/git", Hash: "abc123"}
+	for _, cli := range []string{"git", "kubectl"} {
+		if err := c.Put(cache.Key(cli, bin, cli+" 1.0", nil), cli, bin, cli+" 1.0", "help", &models.Node{Name: cli}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e, ok, err := c.LatestEntry("git")
+	if err != nil || !ok || e.CLI != "git" || e.Version != "git 1.0" || e.Binary.Hash != "abc123" {
+		t.Errorf("LatestEntry(git) = %+v, %v, %v", e, ok, err)
+	}
+	if _, ok, err := c.LatestEntry("nosuch"); ok || err != nil {
+		t.Errorf("LatestEntry(nosuch) = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func TestCacheHelpTexts(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer c.Close()
+
+	if got, err := c.GetHelp("git", "1.0", []string{"remote"}, 0); err != nil || got != "" {
+		t.Fatalf("GetHelp() on empty cache = %q, %v", got, err)
+	}
+	if err := c.PutHelp("git", "1.0", []string{"remote"}, "remote help"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutHelp("git", "1.0", nil, "root help"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.GetHelp("git", "1.0", []string{"remote"}, time.Hour); got != "remote help" {
+		t.Errorf("GetHelp(remote) = %q, want %q", got, "remote help")
+	}
+	if got, _ := c.GetHelp("git", "1.0", nil, 0); got != "root help" {
+		t.Errorf("GetHelp(root) = %q, want %q", got, "root help")
+	}
+	if got, _ := c.GetHelp("git", "2.0", []string{"remote"}, 0); got != "" {
+		t.Errorf("GetHelp() for other version = %q, want empty", got)
+	}
+
+	store := c.HelpStore("git", "1.0", 0)
+	if got, ok := store.LoadHelp([]string{"remote"}); !ok || got != "remote help" {
+		t.Errorf("LoadHelp(remote) = %q, %v", got, ok)
+	}
+	store.SaveHelp([]string{"remote", "add"}, "add help")
+	if got, _ := c.GetHelp("git", "1.0", []string{"remote", "add"}, 0); got != "add help" {
+		t.Errorf("SaveHelp did not persist, got %q", got)
+	}
+
+	if err := c.ClearCLI("git"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.LoadHelp([]string{"remote"}); ok {
+		t.Error("ClearCLI() should remove stored help texts")
+	}
+}
+
+func TestCacheTUIState(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer c.Close()
+
+	store := c.StateStore("aws")
+	if _, ok := store.LoadState(); ok {
+		t.Fatal("LoadState() on empty cache should report nothing saved")
+	}
+	store.SaveState([]byte(`{"selected":["aws","s3"]}`))
+	store.SaveState([]byte(`{"selected":["aws","ec2"]}`))
+	if got, ok := store.LoadState(); !ok || string(got) != `{"selected":["aws","ec2"]}` {
+		t.Errorf("LoadState() = %q, %v; want the latest saved state", got, ok)
+	}
+	if _, ok := c.StateStore("git").LoadState(); ok {
+		t.Error("state is per CLI; git should have none")
+	}
+
+	if err := c.ClearCLI("aws"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.LoadState(); ok {
+		t.Error("ClearCLI() should remove saved TUI state")
+	}
+}
+
+func TestCacheFlagValues(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer c.Close()
+
+	h := c.ValueHistory("kubectl")
+	if got := h.FlagValues("--namespace"); len(got) != 0 {
+		t.Fatalf("FlagValues() on empty cache = %v, want none", got)
+	}
+	for _, v := range []string{"dev", "prod", "prod", "staging"} {
+		h.AddFlagValue("--namespace", v)
+	}
+	h.AddFlagValue("--context", "minikube")
+	got := h.FlagValues("--namespace")
+	if len(got) != 3 || got[0] != "prod" {
+		t.Errorf("FlagValues() = %v, want 3 values with the most used (prod) first", got)
+	}
+	if got := c.ValueHistory("helm").FlagValues("--namespace"); len(got) != 0 {
+		t.Errorf("values are per CLI; helm should have none, got %v", got)
+	}
+
+	if err := c.ClearCLI("kubectl"); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.FlagValues("--context"); len(got) != 0 {
+		t.Errorf("ClearCLI() should remove flag values, got %v", got)
+	}
+}
+
+func TestCacheUses(t *testing.T) {
+	for _, backend := range cache.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			c, err := cache.OpenBackend(t.TempDir(), backend)
+			if err != nil {
+				t.Fatalf("OpenBackend() error: %v", err)
+			}
+			defer c.Close()
+
+			u := c.UsageLog("kubectl")
+			if got := u.Uses(); len(got) != 0 {
+				t.Fatalf("Uses() on empty cache = %v, want none", got)
+			}
+			for _, item := range []string{"kubectl apply", "kubectl apply", "kubectl apply --dry-run", "kubectl get"} {
+				u.AddUse(item)
+			}
+			got := u.Uses()
+			if len(got) != 3 || got["kubectl apply"] != 2 || got["kubectl apply --dry-run"] != 1 {
+				t.Errorf("Uses() = %v, want apply used twice and --dry-run and get once", got)
+			}
+			if got := c.UsageLog("helm").Uses(); len(got) != 0 {
+				t.Errorf("uses are per CLI; helm should have none, got %v", got)
+			}
+
+			if err := c.ClearCLI("kubectl"); err != nil {
+				t.Fatal(err)
+			}
+			if got := u.Uses(); len(got) != 0 {
+				t.Errorf("ClearCLI() should remove the usage counts, got %v", got)
+			}
+		})
+	}
+}
+
+func TestCacheNotes(t *testing.T) {
+	for _, backend := range cache.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			c, err := cache.OpenBackend(t.TempDir(), backend)
+			if err != nil {
+				t.Fatalf("OpenBackend() error: %v", err)
+			}
+			defer c.Close()
+
+			s := c.NoteStore("kubectl")
+			if got := s.Notes(); len(got) != 0 {
+				t.Fatalf("Notes() on empty cache = %v, want none", got)
+			}
+			for cmd, note := range map[string]string{
+				"kubectl apply":  "our prod cluster needs --context=prod",
+				"kubectl delete": "never on prod",
+			} {
+				if err := s.SaveNote(cmd, note); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.SaveNote("kubectl delete", ""); err != nil {
+				t.Fatal(err)
+			}
+			got := s.Notes()
+			if len(got) != 1 || got["kubectl apply"] != "our prod cluster needs --context=prod" {
+				t.Errorf("Notes() = %v, want the apply note only", got)
+			}
+			if got := c.NoteStore("helm").Notes(); len(got) != 0 {
+				t.Errorf("notes are per CLI; helm should have none, got %v", got)
+			}
+
+			if err := c.ClearCLI("kubectl"); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Clear(); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Notes(); len(got) != 1 {
+				t.Errorf("notes should outlive clearing the cache, got %v", got)
+			}
+		})
+	}
+}
+
+func TestCacheSnippets(t *testing.T) {
+	for _, backend := range cache.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			c, err := cache.OpenBackend(t.TempDir(), backend)
+			if err != nil {
+				t.Fatalf("OpenBackend() error: %v", err)
+			}
+			defer c.Close()
+
+			s := c.SnippetStore("kubectl")
+			for name, command := range map[string]string{
+				"pods": "kubectl get pods -n {{namespace}}",
+				"logs": "kubectl logs -f {{pod}}",
+			} {
+				if err := s.SaveSnippet(name, command); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.SaveSnippet("pods", "kubectl get pods -A"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.DeleteSnippet("logs"); err != nil {
+				t.Fatal(err)
+			}
+			got := s.Snippets()
+			if len(got) != 1 || got["pods"] != "kubectl get pods -A" {
+				t.Errorf("Snippets() = %v, want pods, saved again", got)
+			}
+			if got := c.SnippetStore("helm").Snippets(); len(got) != 0 {
+				t.Errorf("snippets are per CLI; helm should have none, got %v", got)
+			}
+
+			if err := c.Clear(); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Snippets(); len(got) != 1 {
+				t.Errorf("snippets should outlive clearing the cache, got %v", got)
+			}
+		})
+	}
+}
+
+func TestCacheEnv(t *testing.T) {
+	for _, backend := range cache.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			c, err := cache.OpenBackend(t.TempDir(), backend)
+			if err != nil {
+				t.Fatalf("OpenBackend() error: %v", err)
+			}
+			defer c.Close()
+
+			s := c.EnvStore("aws")
+			for name, value := range map[string]string{"AWS_PROFILE": "dev", "AWS_REGION": "eu-west-1"} {
+				if err := s.SetEnv(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.SetEnv("AWS_PROFILE", "prod"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetEnv("AWS_REGION", ""); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Env(); len(got) != 1 || got["AWS_PROFILE"] != "prod" {
+				t.Errorf("Env() = %v, want AWS_PROFILE, set again", got)
+			}
+			if got := c.EnvStore("kubectl").Env(); len(got) != 0 {
+				t.Errorf("variables are per CLI; kubectl should have none, got %v", got)
+			}
+
+			if err := c.Clear(); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Env(); len(got) != 1 {
+				t.Errorf("variables should outlive clearing the cache, got %v", got)
+			}
+		})
+	}
+}
+
+func TestCacheRuns(t *testing.T) {
+	for _, backend := range cache.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			c, err := cache.OpenBackend(t.TempDir(), backend)
+			if err != nil {
+				t.Fatalf("OpenBackend() error: %v", err)
+			}
+			defer c.Close()
+
+			log := c.RunLog("git")
+			start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+			for i := range 52 {
+				run := models.Run{
+					Command:   fmt.Sprintf("git log -%d", i),
+					StartedAt: start.Add(time.Duration(i) * time.Minute),
+					Duration:  1500 * time.Millisecond,
+					ExitCode:  i % 2,
+					Stdout:    "commit abc\n",
+					Stderr:    "warning\n",
+				}
+				if err := log.AddRun(run); err != nil {
+					t.Fatal(err)
+				}
+			}
+			runs := log.Runs()
+			if len(runs) != 50 {
+				t.Fatalf("Runs() kept %d runs, want the latest 50", len(runs))
+			}
+			got, want := runs[0], models.Run{
+				Command:   "git log -51",
+				StartedAt: start.Add(51 * time.Minute),
+				Duration:  1500 * time.Millisecond,
+				ExitCode:  1,
+				Stdout:    "commit abc\n",
+				Stderr:    "warning\n",
+			}
+			if !got.StartedAt.Equal(want.StartedAt) {
+				t.Errorf("StartedAt = %v, want %v", got.StartedAt, want.StartedAt)
+			}
+			got.StartedAt = want.StartedAt
+			if got != want {
+				t.Errorf("Runs()[0] = %+v, want the newest run %+v", got, want)
+			}
+			if runs[49].Command != "git log -2" {
+				t.Errorf("oldest run kept = %q, want git log -2", runs[49].Command)
+			}
+			if got := c.RunLog("helm").Runs(); len(got) != 0 {
+				t.Errorf("runs are per CLI; helm should have none, got %v", got)
+			}
+
+			if err := c.ClearCLI("git"); err != nil {
+				t.Fatal(err)
+			}
+			if got := log.Runs(); len(got) != 0 {
+				t.Errorf("clearing the CLI should drop its runs, got %d", len(got))
+			}
+		})
+	}
+}
+
+func TestCacheBinaryStamp(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer c.Close()
+
+	if cache.BinaryStamp("nonexistent_cli_99999") != "" {
+		t.Error("BinaryStamp() of a missing CLI should be empty")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "stampcli")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	stamp := cache.BinaryStamp("stampcli")
+	if stamp == "" {
+		t.Fatal("BinaryStamp() should identify a CLI on PATH")
+	}
+	if err := os.Chtimes(bin, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if cache.BinaryStamp("stampcli") == stamp {
+		t.Error("BinaryStamp() should change when the binary is modified")
+	}
+
+	if got, err := c.Stamp("stampcli"); err != nil || got != "" {
+		t.Errorf("Stamp() on empty cache = %q, %v; want none", got, err)
+	}
+	if err := c.PutStamp("stampcli", stamp); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Stamp("stampcli"); got != stamp {
+		t.Errorf("Stamp() = %q, want %q", got, stamp)
+	}
+	if err := c.ClearCLI("stampcli"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Stamp("stampcli"); got != "" {
+		t.Error("ClearCLI() should remove the recorded stamp")
+	}
+}
+
+func TestCacheResolveBinary(t *testing.T) {
+	for _, backend := range cache.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			c, err := cache.OpenBackend(t.TempDir(), backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			if _, err := c.ResolveBinary("nonexistent_cli_99999"); err == nil {
+				t.Error("ResolveBinary() of a missing CLI should fail")
+			}
+			// Two executables named bincli; PATH picks the one in first,
+			// reached through a symlink from link.
+			first, second, link := t.TempDir(), t.TempDir(), t.TempDir()
+			for i, dir := range []string{first, second} {
+				script := fmt.Sprintf("#!/bin/sh\necho %d\n", i)
+				if err := os.WriteFile(filepath.Join(dir, "bincli"), []byte(script), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.Symlink(filepath.Join(first, "bincli"), filepath.Join(link, "bincli")); err != nil {
+				t.Skipf("symlinks unsupported: %v", err)
+			}
+			t.Setenv("PATH", strings.Join([]string{link, second, os.Getenv("PATH")}, string(os.PathListSeparator)))
+			a, err := c.ResolveBinary("bincli")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := filepath.EvalSymlinks(filepath.Join(first, "bincli"))
+			if a.Path != want || a.Hash == "" {
+				t.Errorf("ResolveBinary() = %+v, want %s with a hash", a, want)
+			}
+			t.Setenv("PATH", strings.Join([]string{second, os.Getenv("PATH")}, string(os.PathListSeparator)))
+			b, err := c.ResolveBinary("bincli")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.Hash == a.Hash || cache.Key("bincli", a, "1.0", nil) == cache.Key("bincli", b, "1.0", nil) {
+				t.Errorf("binaries with different contents should key apart: %+v, %+v", a, b)
+			}
+
+			if err := c.Put(cache.Key("bincli", b, "1.0", nil), "bincli", b, "1.0", "help", &models.Node{Name: "bincli"}); err != nil {
+				t.Fatal(err)
+			}
+			if entries, _ := c.ListEntries(); len(entries) != 1 || entries[0].Binary.Path != b.Path || entries[0].Binary.Hash != b.Hash {
+				t.Errorf("ListEntries() = %+v, want the binary recorded", entries)
+			}
+			if stamp, _ := c.Stamp("bincli"); stamp != cache.BinaryStamp("bincli") {
+				t.Errorf("Stamp() after Put() = %q, want the binary's stamp", stamp)
+			}
+
+			// The recorded hash is reused while the stamp is unchanged, and
+			// recomputed once it changes.
+			path := filepath.Join(second, "bincli")
+			info, _ := os.Stat(path)
+			if err := os.WriteFile(path, []byte("#!/bin/sh\necho 2\n"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := c.ResolveBinary("bincli"); got.Hash != b.Hash {
+				t.Errorf("ResolveBinary() with an unchanged stamp hashed again: %q, want %q", got.Hash, b.Hash)
+			}
+			if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := c.ResolveBinary("bincli"); got.Hash == b.Hash {
+				t.Error("ResolveBinary() should hash a binary whose stamp changed")
+			}
+		})
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer c.Close()
+
+	desc := strings.Repeat("x", 1000)
+	for _, cli := range []string{"aws", "git", "kubectl"} {
+		if err := c.Put(cache.Key(cli, cache.Binary{}, "1.0", nil), cli, cache.Binary{}, "1.0", "help", &models.Node{Name: cli, Description: desc}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.PutHelp(cli, "1.0", nil, desc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// aws was used last, so git is now the least recently used.
+	if node, _ := c.Get(cache.Key("aws", cache.Binary{}, "1.0", nil), 0); node == nil {
+		t.Fatal("expected aws to be cached")
+	}
+	size, err := c.Size()
+	if err != nil || size < 6000 {
+		t.Fatalf("Size() = %d, %v; want the three trees and their help", size, err)
+	}
+
+	res, err := c.Prune(size - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Trees != 1 || res.Size != size-res.Freed || res.Size > size-2000 {
+		t.Errorf("Prune() = %+v; want one tree and its help evicted from %d bytes", res, size)
+	}
+	if clis, _ := c.ListCLIs(); !slices.Equal(clis, []string{"aws", "kubectl"}) {
+		t.Errorf("ListCLIs() after Prune() = %v, want the least recently used (git) evicted", clis)
+	}
+	if help, _ := c.GetHelp("git", "1.0", nil, 0); help != "" {
+		t.Error("Prune() should evict the help text of an evicted tree's CLI version")
+	}
+
+	// Put evicts on its own once a limit is set, but keeps the newest tree.
+	c.SetMaxSize(1)
+	if err := c.Put(cache.Key("gh", cache.Binary{}, "1.0", nil), "gh", cache.Binary{}, "1.0", "help", &models.Node{Name: "gh", Description: desc}); err != nil {
+		t.Fatal(err)
+	}
+	if clis, _ := c.ListCLIs(); !slices.Equal(clis, []string{"gh"}) {
+		t.Errorf("ListCLIs() after an over-budget Put() = %v, want only the new tree", clis)
+	}
+}
+
+func TestOpenBackend(t *testing.T) {
+	if _, err := cache.OpenBackend(t.TempDir(), "nosuch"); err == nil {
+		t.Error("expected an unknown backend to be rejected")
+	}
+	if !slices.Contains(cache.Backends(), cache.BackendFiles) {
+		t.Errorf("Backends() = %v, want the pure-Go %q backend in every build", cache.Backends(), cache.BackendFiles)
+	}
+	for _, backend := range cache.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			c, err := cache.OpenBackend(dir, backend)
+			if err != nil {
+				t.Fatalf("OpenBackend() error: %v", err)
+			}
+			defer c.Close()
+
+			for _, ver := range []string{"1.0", "2.0"} {
+				node := &models.Node{Name: "git", Description: ver}
+				if err := c.Put(cache.Key("git", cache.Binary{}, ver, nil), "git", cache.Binary{}, ver, "help", node); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got, _ := c.Get(cache.Key("git", cache.Binary{}, "1.0", nil), 0); got == nil || got.Description != "1.0" {
+				t.Errorf("Get() = %+v, want git 1.0", got)
+			}
+			if got, _ := c.Latest("git"); got == nil || got.Description != "2.0" {
+				t.Errorf("Latest() = %+v, want git 2.0", got)
+			}
+			if entries, _ := c.ListEntries(); len(entries) != 2 || entries[0].Version != "2.0" || !entries[1].UsedAt.After(entries[1].CachedAt.Add(-time.Second)) {
+				t.Errorf("ListEntries() = %+v, want both versions, newest first", entries)
+			}
+
+			_ = c.PutHelp("git", "2.0", []string{"commit"}, "usage: git commit")
+			if help, _ := c.GetHelp("git", "2.0", []string{"commit"}, 0); help != "usage: git commit" {
+				t.Errorf("GetHelp() = %q", help)
+			}
+			_ = c.PutState("git", []byte("state"))
+			c.AddFlagValue("git", "--message", "a")
+			c.AddFlagValue("git", "--message", "b")
+			c.AddFlagValue("git", "--message", "b")
+			if values, _ := c.FlagValues("git", "--message", 10); !slices.Equal(values, []string{"b", "a"}) {
+				t.Errorf("FlagValues() = %v, want most used first", values)
+			}
+			_ = c.PutStamp("git", "stamp")
+
+			// The cache persists across opens.
+			c.Close()
+			if c, err = cache.OpenBackend(dir, backend); err != nil {
+				t.Fatal(err)
+			}
+			if clis, _ := c.ListCLIs(); !slices.Equal(clis, []string{"git"}) {
+				t.Errorf("ListCLIs() after reopening = %v", clis)
+			}
+			if stamp, _ := c.Stamp("git"); stamp != "stamp" {
+				t.Errorf("Stamp() after reopening = %q", stamp)
+			}
+
+			if err := c.ClearCLI("git"); err != nil {
+				t.Fatal(err)
+			}
+			state, _ := c.GetState("git")
+			help, _ := c.GetHelp("git", "2.0", []string{"commit"}, 0)
+			values, _ := c.FlagValues("git", "--message", 10)
+			stamp, _ := c.Stamp("git")
+			size, _ := c.Size()
+			if state != nil || help != "" || values != nil || stamp != "" || size != 0 {
+				t.Errorf("ClearCLI() left state %q, help %q, values %v, stamp %q, %d bytes", state, help, values, stamp, size)
+			}
+		})
+	}
+}
+
+func TestCacheConcurrentAccess(t *testing.T) {
+	for _, backend := range cache.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			// Each worker opens its own Cache, as separate treemand
+			// processes would, and shares one with a sibling goroutine.
+			const workers, rounds = 4, 10
+			var wg sync.WaitGroup
+			errs := make(chan error, 2*workers*rounds*3+workers)
+			for w := range workers {
+				c, err := cache.OpenBackend(dir, backend)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer c.Close()
+				for g := range 2 {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						cli := fmt.Sprintf("cli%d", w)
+						for i := range rounds {
+							ver := fmt.Sprintf("%d.%d", g, i)
+							errs <- c.Put(cache.Key(cli, cache.Binary{}, ver, nil), cli, cache.Binary{}, ver, "help", &models.Node{Name: cli})
+							errs <- c.PutHelp(cli, ver, []string{"sub"}, "usage")
+							errs <- c.AddFlagValue("shared", "--flag", "value")
+							if _, err := c.ListEntries(); err != nil {
+								errs <- err
+							}
+						}
+					}()
+				}
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatalf("concurrent access error: %v", err)
+				}
+			}
+
+			c, err := cache.OpenBackend(dir, backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if entries, _ := c.ListEntries(); len(entries) != workers*2*rounds {
+				t.Errorf("ListEntries() = %d entries, want %d", len(entries), workers*2*rounds)
+			}
+			// Concurrent read-modify-writes must not lose updates: "value"
+			// was used once more than "other", which wins ties by being
+			// used more recently.
+			for range workers*2*rounds - 1 {
+				if err := c.AddFlagValue("shared", "--flag", "other"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if values, _ := c.FlagValues("shared", "--flag", 10); !slices.Equal(values, []string{"value", "other"}) {
+				t.Errorf("FlagValues() = %v, want updates from every goroutine counted", values)
+			}
+		})
+	}
+}
+
+func TestCacheHistory(t *testing.T) {
+	for _, backend := range cache.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			c, err := cache.OpenBackend(t.TempDir(), backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			if h, err := c.History("git"); err != nil || len(h) != 0 {
+				t.Errorf("History() of an empty cache = %v, %v", h, err)
+			}
+			deep := &models.Node{Name: "git", Children: []*models.Node{{Name: "commit"}, {Name: "log"}}}
+			shallow := &models.Node{Name: "git", Children: []*models.Node{{Name: "commit"}}}
+			put := func(ver string, depth int, node *models.Node) {
+				t.Helper()
+				key := cache.Key("git", cache.Binary{}, ver, []string{fmt.Sprint(depth)})
+				if err := c.Put(key, "git", cache.Binary{}, ver, "help", node); err != nil {
+					t.Fatal(err)
+				}
+			}
+			put("1.0", 2, deep)
+			put("1.0", 1, shallow) // must not replace the deeper snapshot
+			put("2.0", 1, shallow)
+
+			h, err := c.History("git")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(h) != 2 || h[0].Version != "2.0" || h[1].Version != "1.0" {
+				t.Fatalf("History() = %+v, want 2.0 then 1.0", h)
+			}
+			if snap, _ := c.Snapshot("git", "1.0"); snap == nil || len(snap.Children) != 2 {
+				t.Errorf("Snapshot(1.0) = %+v, want the deeper tree", snap)
+			}
+
+			// Snapshots survive eviction of the cached trees.
+			if _, err := c.Prune(1); err != nil {
+				t.Fatal(err)
+			}
+			if snap, _ := c.Snapshot("git", "1.0"); snap == nil {
+				t.Error("Prune() should keep snapshots")
+			}
+			if snap, _ := c.Snapshot("git", "3.0"); snap != nil {
+				t.Errorf("Snapshot() of an unknown version = %+v, want nil", snap)
+			}
+
+			if err := c.ClearCLI("git"); err != nil {
+				t.Fatal(err)
+			}
+			if h, _ := c.History("git"); len(h) != 0 {
+				t.Errorf("History() after ClearCLI() = %+v, want none", h)
+			}
+		})
+	}
+}
@@ -0,0 +1,549 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// fileStore keeps the cache as plain files under a directory, using only
+// the standard library:
+//
+//	trees/<key>.tree             a JSON metadata line, then the tree as
+//	                             JSON; modified when it was last used
+//	snapshots/<cli>/<version>    a snapshot, in the format of a .tree file
+//	help/<cli>/<version>/<path>  help text; modified when it was stored
+//	state/<cli>                  saved TUI state
+//	values/<cli>.json            remembered flag values
+//	uses/<cli>.json              how often commands and flags were used
+//	notes/<cli>.json             notes on commands, by full command
+//	snippets/<cli>.json          saved commands, by name
+//	runs/<cli>.json              commands run from the TUI, newest first
+//	env/<cli>.json               environment variables of commands, by name
+//	stamps/<cli>                 BinaryStamp and hash of the CLI
+//
+// CLI names, versions and paths are hashed with fileKey to make safe file
+// names. Every file is written to a temporary file and renamed into place,
+// so readers never see a partial write.
+type fileStore struct {
+	dir string
+}
+
+// fileStoreDirs are the subdirectories of a fileStore that clear empties.
+var fileStoreDirs = []string{"trees", "snapshots", "help", "state", "values", "uses", "runs", "stamps"}
+
+// openFiles opens (or creates) the file cache in dir/cache.
+func openFiles(dir string) (store, error) {
+	s := &fileStore{dir: filepath.Join(dir, "cache")}
+	for _, sub := range append(fileStoreDirs, "notes", "snippets", "env") {
+		if err := os.MkdirAll(filepath.Join(s.dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("create cache dir: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func (s *fileStore) close() error { return nil }
+
+// fileKey hashes a CLI name, version or command path into a file name.
+func fileKey(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("%x", h[:8])
+}
+
+// writeFile atomically replaces path with data and sets its modification
+// time to mtime.
+func writeFile(path string, data []byte, mtime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(f.Name(), mtime, mtime)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// readFile returns the contents of path, or nil, nil if it does not exist.
+func readFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// removeFile removes path; a missing file is not an error.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// treeMeta is the first line of a .tree file.
+type treeMeta struct {
+	CLI        string    `json:"cli"`
+	Version    string    `json:"version"`
+	Strategy   string    `json:"strategy"`
+	BinaryPath string    `json:"binary_path,omitempty"`
+	BinaryHash string    `json:"binary_hash,omitempty"`
+	CachedAt   time.Time `json:"cached_at"`
+}
+
+func (s *fileStore) treePath(key string) string {
+	return filepath.Join(s.dir, "trees", key+".tree")
+}
+
+// readTree reads the .tree file at path; with data false only its metadata
+// line is read. Returns nil, nil if the file does not exist.
+func readTree(path string, data bool) (*treeRecord, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	var meta treeMeta
+	if err := json.Unmarshal(line, &meta); err != nil {
+		return nil, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	r := &treeRecord{
+		key:      strings.TrimSuffix(filepath.Base(path), ".tree"),
+		cli:      meta.CLI,
+		version:  meta.Version,
+		strategy: meta.Strategy,
+		binary:   Binary{Path: meta.BinaryPath, Hash: meta.BinaryHash},
+		cachedAt: meta.CachedAt,
+		usedAt:   info.ModTime(),
+		size:     int(info.Size()) - len(line),
+	}
+	if data {
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(br); err != nil {
+			return nil, err
+		}
+		r.data = buf.Bytes()
+	}
+	return r, nil
+}
+
+func (s *fileStore) tree(key string) (*treeRecord, error) {
+	return readTree(s.treePath(key), true)
+}
+
+// writeTree writes r to path in the .tree file format.
+func writeTree(path string, r *treeRecord) error {
+	line, err := json.Marshal(treeMeta{
+		CLI: r.cli, Version: r.version, Strategy: r.strategy,
+		BinaryPath: r.binary.Path, BinaryHash: r.binary.Hash, CachedAt: r.cachedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return writeFile(path, slices.Concat(line, []byte("\n"), r.data), r.usedAt)
+}
+
+func (s *fileStore) putTree(r *treeRecord) error {
+	return writeTree(s.treePath(r.key), r)
+}
+
+func (s *fileStore) touchTree(key string, at time.Time) error {
+	return os.Chtimes(s.treePath(key), at, at)
+}
+
+func (s *fileStore) deleteTree(key string) error {
+	return removeFile(s.treePath(key))
+}
+
+func (s *fileStore) trees() ([]treeRecord, error) {
+	files, err := os.ReadDir(filepath.Join(s.dir, "trees"))
+	if err != nil {
+		return nil, err
+	}
+	var trees []treeRecord
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".tree") {
+			continue
+		}
+		r, err := readTree(filepath.Join(s.dir, "trees", f.Name()), false)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil { // removed since listed
+			trees = append(trees, *r)
+		}
+	}
+	slices.SortFunc(trees, func(a, b treeRecord) int {
+		if n := cmp.Compare(a.cli, b.cli); n != 0 {
+			return n
+		}
+		return b.cachedAt.Compare(a.cachedAt)
+	})
+	return trees, nil
+}
+
+func (s *fileStore) snapshotPath(cli, version string) string {
+	return filepath.Join(s.dir, "snapshots", fileKey(cli), fileKey(version))
+}
+
+func (s *fileStore) snapshot(cli, version string, data bool) (*treeRecord, error) {
+	return readTree(s.snapshotPath(cli, version), data)
+}
+
+func (s *fileStore) putSnapshot(r *treeRecord) error {
+	return writeTree(s.snapshotPath(r.cli, r.version), r)
+}
+
+func (s *fileStore) snapshots(cli string) ([]treeRecord, error) {
+	dir := filepath.Join(s.dir, "snapshots", fileKey(cli))
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var snaps []treeRecord
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), ".tmp-") {
+			continue
+		}
+		r, err := readTree(filepath.Join(dir, f.Name()), false)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			snaps = append(snaps, *r)
+		}
+	}
+	slices.SortFunc(snaps, func(a, b treeRecord) int { return b.cachedAt.Compare(a.cachedAt) })
+	return snaps, nil
+}
+
+func (s *fileStore) helpDir(cli, version string) string {
+	return filepath.Join(s.dir, "help", fileKey(cli), fileKey(version))
+}
+
+func (s *fileStore) help(cli, version, path string) (string, time.Time, error) {
+	p := filepath.Join(s.helpDir(cli, version), fileKey(path))
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", time.Time{}, nil
+	} else if err != nil {
+		return "", time.Time{}, err
+	}
+	data, err := readFile(p)
+	return string(data), info.ModTime(), err
+}
+
+func (s *fileStore) putHelp(cli, version, path, help string, at time.Time) error {
+	return writeFile(filepath.Join(s.helpDir(cli, version), fileKey(path)), []byte(help), at)
+}
+
+// dirSize returns the total size of the files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func (s *fileStore) helpSize(cli, version string) (int64, error) {
+	return dirSize(s.helpDir(cli, version))
+}
+
+func (s *fileStore) deleteHelp(cli, version string) error {
+	return os.RemoveAll(s.helpDir(cli, version))
+}
+
+func (s *fileStore) state(cli string) ([]byte, error) {
+	return readFile(filepath.Join(s.dir, "state", fileKey(cli)))
+}
+
+func (s *fileStore) putState(cli string, data []byte, at time.Time) error {
+	return writeFile(filepath.Join(s.dir, "state", fileKey(cli)), data, at)
+}
+
+// flagValue is one remembered value in a values/<cli>.json file.
+type flagValue struct {
+	Value  string    `json:"value"`
+	Uses   int       `json:"uses"`
+	UsedAt time.Time `json:"used_at"`
+}
+
+func (s *fileStore) valuesPath(cli string) string {
+	return filepath.Join(s.dir, "values", fileKey(cli)+".json")
+}
+
+// readValues returns the flag values remembered for cli, by flag.
+func (s *fileStore) readValues(cli string) (map[string][]flagValue, error) {
+	data, err := readFile(s.valuesPath(cli))
+	if err != nil || data == nil {
+		return map[string][]flagValue{}, err
+	}
+	values := map[string][]flagValue{}
+	return values, json.Unmarshal(data, &values)
+}
+
+func (s *fileStore) addFlagValue(cli, flag, value string, at time.Time) error {
+	values, err := s.readValues(cli)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(values[flag], func(v flagValue) bool { return v.Value == value })
+	if i < 0 {
+		values[flag] = append(values[flag], flagValue{Value: value})
+		i = len(values[flag]) - 1
+	}
+	values[flag][i].Uses++
+	values[flag][i].UsedAt = at
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return writeFile(s.valuesPath(cli), data, at)
+}
+
+func (s *fileStore) flagValues(cli, flag string, limit int) ([]string, error) {
+	values, err := s.readValues(cli)
+	if err != nil {
+		return nil, err
+	}
+	vs := values[flag]
+	slices.SortFunc(vs, func(a, b flagValue) int {
+		if n := cmp.Compare(b.Uses, a.Uses); n != 0 {
+			return n
+		}
+		if n := b.UsedAt.Compare(a.UsedAt); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.Value, b.Value)
+	})
+	var out []string
+	for _, v := range vs[:min(limit, len(vs))] {
+		out = append(out, v.Value)
+	}
+	return out, nil
+}
+
+func (s *fileStore) usesPath(cli string) string {
+	return filepath.Join(s.dir, "uses", fileKey(cli)+".json")
+}
+
+func (s *fileStore) addUse(cli, item string, at time.Time) error {
+	uses, err := s.uses(cli)
+	if err != nil {
+		return err
+	}
+	uses[item]++
+	data, err := json.Marshal(uses)
+	if err != nil {
+		return err
+	}
+	return writeFile(s.usesPath(cli), data, at)
+}
+
+func (s *fileStore) uses(cli string) (map[string]int, error) {
+	data, err := readFile(s.usesPath(cli))
+	if err != nil || data == nil {
+		return map[string]int{}, err
+	}
+	uses := map[string]int{}
+	return uses, json.Unmarshal(data, &uses)
+}
+
+func (s *fileStore) notes(cli string) (map[string]string, error) {
+	return s.readMap("notes", cli)
+}
+
+func (s *fileStore) putNote(cli, command, note string, at time.Time) error {
+	return s.putMapEntry("notes", cli, command, note, at)
+}
+
+func (s *fileStore) snippets(cli string) (map[string]string, error) {
+	return s.readMap("snippets", cli)
+}
+
+func (s *fileStore) putSnippet(cli, name, command string, at time.Time) error {
+	return s.putMapEntry("snippets", cli, name, command, at)
+}
+
+func (s *fileStore) env(cli string) (map[string]string, error) {
+	return s.readMap("env", cli)
+}
+
+func (s *fileStore) putEnv(cli, name, value string, at time.Time) error {
+	return s.putMapEntry("env", cli, name, value, at)
+}
+
+func (s *fileStore) runsPath(cli string) string {
+	return filepath.Join(s.dir, "runs", fileKey(cli)+".json")
+}
+
+func (s *fileStore) addRun(cli string, run models.Run, keep int) error {
+	runs, err := s.runs(cli)
+	if err != nil {
+		return err
+	}
+	runs = append([]models.Run{run}, runs...)
+	data, err := json.Marshal(runs[:min(keep, len(runs))])
+	if err != nil {
+		return err
+	}
+	return writeFile(s.runsPath(cli), data, run.StartedAt)
+}
+
+func (s *fileStore) runs(cli string) ([]models.Run, error) {
+	data, err := readFile(s.runsPath(cli))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var runs []models.Run
+	return runs, json.Unmarshal(data, &runs)
+}
+
+// readMap reads the JSON object of strings kept for cli in the
+// subdirectory sub, as notes, snippets and environment variables are.
+func (s *fileStore) readMap(sub, cli string) (map[string]string, error) {
+	data, err := readFile(filepath.Join(s.dir, sub, fileKey(cli)+".json"))
+	if err != nil || data == nil {
+		return map[string]string{}, err
+	}
+	m := map[string]string{}
+	return m, json.Unmarshal(data, &m)
+}
+
+// putMapEntry sets key to value in the map readMap reads, deleting it when
+// value is "", and the file with the last key.
+func (s *fileStore) putMapEntry(sub, cli, key, value string, at time.Time) error {
+	m, err := s.readMap(sub, cli)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		delete(m, key)
+	} else {
+		m[key] = value
+	}
+	path := filepath.Join(s.dir, sub, fileKey(cli)+".json")
+	if len(m) == 0 {
+		return removeFile(path)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data, at)
+}
+
+func (s *fileStore) stamp(cli string) (string, error) {
+	data, err := readFile(filepath.Join(s.dir, "stamps", fileKey(cli)))
+	return string(data), err
+}
+
+func (s *fileStore) putStamp(cli, stamp string) error {
+	return writeFile(filepath.Join(s.dir, "stamps", fileKey(cli)), []byte(stamp), time.Now())
+}
+
+func (s *fileStore) size() (int64, error) {
+	trees, err := s.trees()
+	if err != nil {
+		return 0, err
+	}
+	size, err := dirSize(filepath.Join(s.dir, "help"))
+	for _, t := range trees {
+		size += int64(t.size)
+	}
+	return size, err
+}
+
+func (s *fileStore) clear(cli string) error {
+	if cli == "" {
+		for _, sub := range fileStoreDirs {
+			dir := filepath.Join(s.dir, sub)
+			if err := os.RemoveAll(dir); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	trees, err := s.trees()
+	if err != nil {
+		return err
+	}
+	for _, t := range trees {
+		if t.cli == cli {
+			if err := s.deleteTree(t.key); err != nil {
+				return err
+			}
+		}
+	}
+	for _, sub := range []string{"snapshots", "help"} {
+		if err := os.RemoveAll(filepath.Join(s.dir, sub, fileKey(cli))); err != nil {
+			return err
+		}
+	}
+	for _, p := range []string{
+		filepath.Join(s.dir, "state", fileKey(cli)),
+		s.valuesPath(cli),
+		s.usesPath(cli),
+		s.runsPath(cli),
+		filepath.Join(s.dir, "stamps", fileKey(cli)),
+	} {
+		if err := removeFile(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compact has nothing to do: evicted files are removed outright.
+func (s *fileStore) compact() error { return nil }
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// Every Put also keeps the tree as the snapshot of its CLI version, which
+// outlives the cached trees: snapshots never expire, are not evicted
+// to stay under the size limit, and are only removed by Clear and
+// ClearCLI. They are the history of a CLI across upgrades.
+
+// putSnapshot records r as the snapshot of its CLI version unless the
+// snapshot already there is larger: a shallow run after a deep one (a
+// lower --depth) should not make the version look like it lost commands.
+// The caller holds the lock.
+func (c *Cache) putSnapshot(r *treeRecord) error {
+	old, err := c.s.snapshot(r.cli, r.version, false)
+	if err != nil {
+		return err
+	}
+	if old != nil && old.size > r.size {
+		return nil
+	}
+	snap := *r
+	snap.usedAt = snap.cachedAt
+	return c.s.putSnapshot(&snap)
+}
+
+// History returns an entry per version of cli with a snapshot, newest
+// first. Versions cached before snapshots were kept are listed from their
+// newest cached tree.
+func (c *Cache) History(cli string) ([]Entry, error) {
+	snaps, err := c.s.snapshots(cli)
+	if err != nil {
+		return nil, err
+	}
+	trees, err := c.s.trees()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range trees {
+		if t.cli == cli && !slices.ContainsFunc(snaps, func(s treeRecord) bool { return s.version == t.version }) {
+			snaps = append(snaps, t) // trees are newest first, so the newest of t.version
+		}
+	}
+	slices.SortStableFunc(snaps, func(a, b treeRecord) int { return b.cachedAt.Compare(a.cachedAt) })
+	entries := make([]Entry, len(snaps))
+	for i, s := range snaps {
+		entries[i] = Entry{
+			CLI:       s.cli,
+			Version:   s.version,
+			Strategy:  s.strategy,
+			Binary:    s.binary,
+			CachedAt:  s.cachedAt,
+			UsedAt:    s.usedAt,
+			SizeBytes: s.size,
+		}
+	}
+	return entries, nil
+}
+
+// Snapshot returns the snapshot of cli at version (as listed by History).
+// Returns nil, nil if there is none.
+func (c *Cache) Snapshot(cli, version string) (*models.Node, error) {
+	r, err := c.s.snapshot(cli, version, true)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		trees, err := c.s.trees()
+		if err != nil {
+			return nil, err
+		}
+		i := slices.IndexFunc(trees, func(t treeRecord) bool { return t.cli == cli && t.version == version })
+		if i < 0 {
+			return nil, nil
+		}
+		if r, err = c.s.tree(trees[i].key); err != nil || r == nil {
+			return nil, err
+		}
+	}
+	var node models.Node
+	if err := json.Unmarshal(r.data, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
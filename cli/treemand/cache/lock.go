@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// lockTimeout bounds how long a write waits for another process to release
+// the cache lock.
+var lockTimeout = 10 * time.Second
+
+// errLocked reports that another process held the cache lock for longer
+// than lockTimeout.
+var errLocked = errors.New("cache is locked by another treemand process")
+
+// cacheLock serializes writes to a cache directory across goroutines and
+// processes. The advisory lock on the lock file excludes other processes
+// (and other Caches on the same directory); the mutex excludes goroutines
+// sharing this Cache, which the file lock would let through.
+type cacheLock struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// openLock opens (or creates) the lock file at path.
+func openLock(path string) (*cacheLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open cache lock: %w", err)
+	}
+	return &cacheLock{f: f}, nil
+}
+
+// do runs fn holding the lock, waiting up to lockTimeout for it.
+func (l *cacheLock) do(fn func() error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	deadline := time.Now().Add(lockTimeout)
+	for wait := time.Millisecond; ; wait = min(2*wait, 100*time.Millisecond) {
+		ok, err := tryLockFile(l.f)
+		if err != nil {
+			return fmt.Errorf("lock cache: %w", err)
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return errLocked
+		}
+		time.Sleep(wait)
+	}
+	defer unlockFile(l.f)
+	return fn()
+}
+
+func (l *cacheLock) close() error { return l.f.Close() }
@@ -0,0 +1,570 @@
+//go:build cgo
+
+package cache
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // sqlite3 driver
+
+	"github.com/aallbrig/treemand/models"
+)
+
+func init() {
+	backends[BackendSQLite] = openSQLite
+}
+
+// sqliteStore keeps the cache in an SQLite database.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// sqliteBusyTimeout is how long a statement waits for another connection
+// or process to finish writing before failing with "database is locked".
+const sqliteBusyTimeout = 5 * time.Second
+
+// openSQLite opens (or creates) the cache database at dir/cache.db. It is
+// put in WAL mode, so readers are not blocked by a writer, and writers wait
+// up to sqliteBusyTimeout for each other.
+func openSQLite(dir string) (store, error) {
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate",
+		filepath.Join(dir, "cache.db"), sqliteBusyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite3: %w", err)
+	}
+	s := &sqliteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqliteStore) close() error { return s.db.Close() }
+
+const schema = `
+CREATE TABLE IF NOT EXISTS trees (
+key       TEXT PRIMARY KEY,
+cli       TEXT NOT NULL,
+version   TEXT NOT NULL,
+strategy  TEXT NOT NULL,
+data      TEXT NOT NULL,
+cached_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS help_texts (
+cli       TEXT NOT NULL,
+version   TEXT NOT NULL,
+path      TEXT NOT NULL,
+help      TEXT NOT NULL,
+cached_at INTEGER NOT NULL,
+PRIMARY KEY (cli, version, path)
+);
+CREATE TABLE IF NOT EXISTS tui_state (
+cli      TEXT PRIMARY KEY,
+data     TEXT NOT NULL,
+saved_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS flag_values (
+cli     TEXT NOT NULL,
+flag    TEXT NOT NULL,
+value   TEXT NOT NULL,
+uses    INTEGER NOT NULL,
+used_at INTEGER NOT NULL,
+PRIMARY KEY (cli, flag, value)
+);
+CREATE TABLE IF NOT EXISTS uses (
+cli     TEXT NOT NULL,
+item    TEXT NOT NULL,
+uses    INTEGER NOT NULL,
+used_at INTEGER NOT NULL,
+PRIMARY KEY (cli, item)
+);
+CREATE TABLE IF NOT EXISTS notes (
+cli      TEXT NOT NULL,
+command  TEXT NOT NULL,
+note     TEXT NOT NULL,
+saved_at INTEGER NOT NULL,
+PRIMARY KEY (cli, command)
+);
+CREATE TABLE IF NOT EXISTS snippets (
+cli      TEXT NOT NULL,
+name     TEXT NOT NULL,
+command  TEXT NOT NULL,
+saved_at INTEGER NOT NULL,
+PRIMARY KEY (cli, name)
+);
+CREATE TABLE IF NOT EXISTS env_vars (
+cli      TEXT NOT NULL,
+name     TEXT NOT NULL,
+value    TEXT NOT NULL,
+saved_at INTEGER NOT NULL,
+PRIMARY KEY (cli, name)
+);
+CREATE TABLE IF NOT EXISTS runs (
+id          INTEGER PRIMARY KEY AUTOINCREMENT,
+cli         TEXT NOT NULL,
+command     TEXT NOT NULL,
+started_at  INTEGER NOT NULL,
+duration_ns INTEGER NOT NULL,
+exit_code   INTEGER NOT NULL,
+stdout      TEXT NOT NULL,
+stderr      TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS snapshots (
+cli         TEXT NOT NULL,
+version     TEXT NOT NULL,
+strategy    TEXT NOT NULL,
+binary_path TEXT NOT NULL,
+binary_hash TEXT NOT NULL,
+data        TEXT NOT NULL,
+cached_at   INTEGER NOT NULL,
+PRIMARY KEY (cli, version)
+);
+CREATE TABLE IF NOT EXISTS binaries (
+cli   TEXT PRIMARY KEY,
+stamp TEXT NOT NULL
+);
+`
+
+// treeColumns are the columns added to trees after its first release, with
+// the statements that fill them in for existing rows.
+var treeColumns = []struct{ name, add, fill string }{
+	// used_at (Unix nanoseconds) was added for LRU eviction; rows cached
+	// before it count as last used when they were cached.
+	{"used_at", `ALTER TABLE trees ADD COLUMN used_at INTEGER NOT NULL DEFAULT 0`,
+		`UPDATE trees SET used_at = cached_at * 1000000000`},
+	// The binary a tree was discovered from; empty for older rows.
+	{"binary_path", `ALTER TABLE trees ADD COLUMN binary_path TEXT NOT NULL DEFAULT ''`, ""},
+	{"binary_hash", `ALTER TABLE trees ADD COLUMN binary_hash TEXT NOT NULL DEFAULT ''`, ""},
+}
+
+func (s *sqliteStore) migrate() error {
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+	for _, col := range treeColumns {
+		if ok, err := s.hasColumn("trees", col.name); err != nil {
+			return err
+		} else if ok {
+			continue
+		}
+		if _, err := s.db.Exec(col.add); err != nil {
+			return err
+		}
+		if col.fill != "" {
+			if _, err := s.db.Exec(col.fill); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasColumn reports whether table has the named column.
+func (s *sqliteStore) hasColumn(table, column string) (bool, error) {
+	rows, err := s.db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+func (s *sqliteStore) tree(key string) (*treeRecord, error) {
+	row := s.db.QueryRow(`SELECT cli, version, strategy, binary_path, binary_hash, data, cached_at, used_at
+		FROM trees WHERE key = ?`, key)
+	r := &treeRecord{key: key}
+	var data string
+	var cachedAt, usedAt int64
+	if err := row.Scan(&r.cli, &r.version, &r.strategy, &r.binary.Path, &r.binary.Hash, &data, &cachedAt, &usedAt); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	r.data, r.size = []byte(data), len(data)
+	r.cachedAt, r.usedAt = time.Unix(cachedAt, 0), time.Unix(0, usedAt)
+	return r, nil
+}
+
+func (s *sqliteStore) putTree(r *treeRecord) error {
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO trees (key, cli, version, strategy, binary_path, binary_hash, data, cached_at, used_at)
+		VALUES (?,?,?,?,?,?,?,?,?)`,
+		r.key, r.cli, r.version, r.strategy, r.binary.Path, r.binary.Hash, string(r.data), r.cachedAt.Unix(), r.usedAt.UnixNano(),
+	)
+	return err
+}
+
+func (s *sqliteStore) touchTree(key string, at time.Time) error {
+	_, err := s.db.Exec(`UPDATE trees SET used_at = ? WHERE key = ?`, at.UnixNano(), key)
+	return err
+}
+
+func (s *sqliteStore) deleteTree(key string) error {
+	_, err := s.db.Exec(`DELETE FROM trees WHERE key = ?`, key)
+	return err
+}
+
+func (s *sqliteStore) trees() ([]treeRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT key, cli, version, strategy, binary_path, binary_hash, cached_at, used_at, length(data)
+		FROM trees
+		ORDER BY cli, cached_at DESC, rowid DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var trees []treeRecord
+	for rows.Next() {
+		var r treeRecord
+		var cachedAt, usedAt int64
+		if err := rows.Scan(&r.key, &r.cli, &r.version, &r.strategy, &r.binary.Path, &r.binary.Hash,
+			&cachedAt, &usedAt, &r.size); err != nil {
+			return nil, err
+		}
+		r.cachedAt, r.usedAt = time.Unix(cachedAt, 0), time.Unix(0, usedAt)
+		trees = append(trees, r)
+	}
+	return trees, rows.Err()
+}
+
+func (s *sqliteStore) snapshot(cli, version string, data bool) (*treeRecord, error) {
+	column := `''`
+	if data {
+		column = `data`
+	}
+	row := s.db.QueryRow(`SELECT strategy, binary_path, binary_hash, `+column+`, length(data), cached_at
+		FROM snapshots WHERE cli = ? AND version = ?`, cli, version)
+	r := &treeRecord{cli: cli, version: version}
+	var d string
+	var cachedAt int64
+	if err := row.Scan(&r.strategy, &r.binary.Path, &r.binary.Hash, &d, &r.size, &cachedAt); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if data {
+		r.data = []byte(d)
+	}
+	r.cachedAt = time.Unix(cachedAt, 0)
+	return r, nil
+}
+
+func (s *sqliteStore) putSnapshot(r *treeRecord) error {
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO snapshots (cli, version, strategy, binary_path, binary_hash, data, cached_at)
+		VALUES (?,?,?,?,?,?,?)`,
+		r.cli, r.version, r.strategy, r.binary.Path, r.binary.Hash, string(r.data), r.cachedAt.Unix(),
+	)
+	return err
+}
+
+func (s *sqliteStore) snapshots(cli string) ([]treeRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT version, strategy, binary_path, binary_hash, cached_at, length(data)
+		FROM snapshots WHERE cli = ?
+		ORDER BY cached_at DESC, rowid DESC`, cli)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var snaps []treeRecord
+	for rows.Next() {
+		r := treeRecord{cli: cli}
+		var cachedAt int64
+		if err := rows.Scan(&r.version, &r.strategy, &r.binary.Path, &r.binary.Hash, &cachedAt, &r.size); err != nil {
+			return nil, err
+		}
+		r.cachedAt = time.Unix(cachedAt, 0)
+		snaps = append(snaps, r)
+	}
+	return snaps, rows.Err()
+}
+
+func (s *sqliteStore) help(cli, version, path string) (string, time.Time, error) {
+	row := s.db.QueryRow(`SELECT help, cached_at FROM help_texts WHERE cli = ? AND version = ? AND path = ?`,
+		cli, version, path)
+	var help string
+	var cachedAt int64
+	if err := row.Scan(&help, &cachedAt); err == sql.ErrNoRows {
+		return "", time.Time{}, nil
+	} else if err != nil {
+		return "", time.Time{}, err
+	}
+	return help, time.Unix(cachedAt, 0), nil
+}
+
+func (s *sqliteStore) putHelp(cli, version, path, help string, at time.Time) error {
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO help_texts (cli, version, path, help, cached_at) VALUES (?,?,?,?,?)`,
+		cli, version, path, help, at.Unix(),
+	)
+	return err
+}
+
+func (s *sqliteStore) helpSize(cli, version string) (int64, error) {
+	var size int64
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(length(help)), 0) FROM help_texts WHERE cli = ? AND version = ?`,
+		cli, version).Scan(&size)
+	return size, err
+}
+
+func (s *sqliteStore) deleteHelp(cli, version string) error {
+	_, err := s.db.Exec(`DELETE FROM help_texts WHERE cli = ? AND version = ?`, cli, version)
+	return err
+}
+
+func (s *sqliteStore) state(cli string) ([]byte, error) {
+	row := s.db.QueryRow(`SELECT data FROM tui_state WHERE cli = ?`, cli)
+	var data string
+	if err := row.Scan(&data); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return []byte(data), nil
+}
+
+func (s *sqliteStore) putState(cli string, data []byte, at time.Time) error {
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO tui_state (cli, data, saved_at) VALUES (?,?,?)`,
+		cli, string(data), at.Unix(),
+	)
+	return err
+}
+
+func (s *sqliteStore) addFlagValue(cli, flag, value string, at time.Time) error {
+	_, err := s.db.Exec(
+		`INSERT INTO flag_values (cli, flag, value, uses, used_at) VALUES (?,?,?,1,?)
+ON CONFLICT (cli, flag, value) DO UPDATE SET uses = uses + 1, used_at = excluded.used_at`,
+		cli, flag, value, at.Unix(),
+	)
+	return err
+}
+
+func (s *sqliteStore) flagValues(cli, flag string, limit int) ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT value FROM flag_values WHERE cli = ? AND flag = ? ORDER BY uses DESC, used_at DESC, value LIMIT ?`,
+		cli, flag, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func (s *sqliteStore) notes(cli string) (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT command, note FROM notes WHERE cli = ?`, cli)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	notes := map[string]string{}
+	for rows.Next() {
+		var command, note string
+		if err := rows.Scan(&command, &note); err != nil {
+			return nil, err
+		}
+		notes[command] = note
+	}
+	return notes, rows.Err()
+}
+
+func (s *sqliteStore) putNote(cli, command, note string, at time.Time) error {
+	if note == "" {
+		_, err := s.db.Exec(`DELETE FROM notes WHERE cli = ? AND command = ?`, cli, command)
+		return err
+	}
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO notes (cli, command, note, saved_at) VALUES (?,?,?,?)`,
+		cli, command, note, at.Unix(),
+	)
+	return err
+}
+
+func (s *sqliteStore) snippets(cli string) (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT name, command FROM snippets WHERE cli = ?`, cli)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	snippets := map[string]string{}
+	for rows.Next() {
+		var name, command string
+		if err := rows.Scan(&name, &command); err != nil {
+			return nil, err
+		}
+		snippets[name] = command
+	}
+	return snippets, rows.Err()
+}
+
+func (s *sqliteStore) putSnippet(cli, name, command string, at time.Time) error {
+	if command == "" {
+		_, err := s.db.Exec(`DELETE FROM snippets WHERE cli = ? AND name = ?`, cli, name)
+		return err
+	}
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO snippets (cli, name, command, saved_at) VALUES (?,?,?,?)`,
+		cli, name, command, at.Unix(),
+	)
+	return err
+}
+
+func (s *sqliteStore) addUse(cli, item string, at time.Time) error {
+	_, err := s.db.Exec(
+		`INSERT INTO uses (cli, item, uses, used_at) VALUES (?,?,1,?)
+ON CONFLICT (cli, item) DO UPDATE SET uses = uses + 1, used_at = excluded.used_at`,
+		cli, item, at.Unix(),
+	)
+	return err
+}
+
+func (s *sqliteStore) uses(cli string) (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT item, uses FROM uses WHERE cli = ?`, cli)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	uses := map[string]int{}
+	for rows.Next() {
+		var item string
+		var n int
+		if err := rows.Scan(&item, &n); err != nil {
+			return nil, err
+		}
+		uses[item] = n
+	}
+	return uses, rows.Err()
+}
+
+func (s *sqliteStore) env(cli string) (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT name, value FROM env_vars WHERE cli = ?`, cli)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	env := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		env[name] = value
+	}
+	return env, rows.Err()
+}
+
+func (s *sqliteStore) putEnv(cli, name, value string, at time.Time) error {
+	if value == "" {
+		_, err := s.db.Exec(`DELETE FROM env_vars WHERE cli = ? AND name = ?`, cli, name)
+		return err
+	}
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO env_vars (cli, name, value, saved_at) VALUES (?,?,?,?)`,
+		cli, name, value, at.Unix(),
+	)
+	return err
+}
+
+func (s *sqliteStore) addRun(cli string, run models.Run, keep int) error {
+	if _, err := s.db.Exec(
+		`INSERT INTO runs (cli, command, started_at, duration_ns, exit_code, stdout, stderr) VALUES (?,?,?,?,?,?,?)`,
+		cli, run.Command, run.StartedAt.UnixNano(), int64(run.Duration), run.ExitCode, run.Stdout, run.Stderr,
+	); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(
+		`DELETE FROM runs WHERE cli = ? AND id NOT IN (SELECT id FROM runs WHERE cli = ? ORDER BY id DESC LIMIT ?)`,
+		cli, cli, keep,
+	)
+	return err
+}
+
+func (s *sqliteStore) runs(cli string) ([]models.Run, error) {
+	rows, err := s.db.Query(
+		`SELECT command, started_at, duration_ns, exit_code, stdout, stderr FROM runs WHERE cli = ? ORDER BY id DESC`, cli)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var runs []models.Run
+	for rows.Next() {
+		var r models.Run
+		var started, duration int64
+		if err := rows.Scan(&r.Command, &started, &duration, &r.ExitCode, &r.Stdout, &r.Stderr); err != nil {
+			return nil, err
+		}
+		r.StartedAt, r.Duration = time.Unix(0, started), time.Duration(duration)
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+func (s *sqliteStore) stamp(cli string) (string, error) {
+	row := s.db.QueryRow(`SELECT stamp FROM binaries WHERE cli = ?`, cli)
+	var stamp string
+	if err := row.Scan(&stamp); err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	return stamp, nil
+}
+
+func (s *sqliteStore) putStamp(cli, stamp string) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO binaries (cli, stamp) VALUES (?,?)`, cli, stamp)
+	return err
+}
+
+func (s *sqliteStore) size() (int64, error) {
+	var size int64
+	err := s.db.QueryRow(`SELECT
+		(SELECT COALESCE(SUM(length(data)), 0) FROM trees) +
+		(SELECT COALESCE(SUM(length(help)), 0) FROM help_texts)`).Scan(&size)
+	return size, err
+}
+
+// clearTables are the tables clear empties, each keyed by cli. notes,
+// snippets and env_vars are not among them.
+var clearTables = []string{"trees", "snapshots", "help_texts", "tui_state", "flag_values", "uses", "runs", "binaries"}
+
+func (s *sqliteStore) clear(cli string) error {
+	for _, table := range clearTables {
+		var err error
+		if cli == "" {
+			_, err = s.db.Exec(`DELETE FROM ` + table)
+		} else {
+			_, err = s.db.Exec(`DELETE FROM `+table+` WHERE cli = ?`, cli)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) compact() error {
+	_, err := s.db.Exec(`VACUUM`)
+	return err
+}
@@ -0,0 +1,79 @@
+//go:build cgo
+
+package cache_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aallbrig/treemand/cache"
+	"github.com/aallbrig/treemand/models"
+)
+
+func TestCacheOpen_migratesUsedAt(t *testing.T) {
+	dir := t.TempDir()
+	// A cache written before trees recorded their last use and binary.
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedAt := time.Now().Add(-time.Hour).Unix()
+	_, err = db.Exec(`CREATE TABLE trees (key TEXT PRIMARY KEY, cli TEXT NOT NULL, version TEXT NOT NULL,
+strategy TEXT NOT NULL, data TEXT NOT NULL, cached_at INTEGER NOT NULL);
+INSERT INTO trees VALUES ('k', 'git', '1.0', 'help', '{"name":"git"}', ?)`, cachedAt)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cache.Open(dir)
+	if err != nil {
+		t.Fatalf("Open() of an old cache: %v", err)
+	}
+	defer c.Close()
+	entries, err := c.ListEntries()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ListEntries() = %v, %v", entries, err)
+	}
+	if !entries[0].UsedAt.Equal(entries[0].CachedAt) {
+		t.Errorf("UsedAt = %v, want old entries last used when cached (%v)", entries[0].UsedAt, entries[0].CachedAt)
+	}
+	if entries[0].Binary != (cache.Binary{}) {
+		t.Errorf("Binary = %+v, want none recorded for old entries", entries[0].Binary)
+	}
+}
+
+func TestCacheHistory_fromTreesBeforeSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	c, err := cache.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("k", "git", cache.Binary{}, "1.0", "help", &models.Node{Name: "git"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	// A cache written before snapshots were kept has the tree only.
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`DELETE FROM snapshots`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err = cache.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if h, _ := c.History("git"); len(h) != 1 || h[0].Version != "1.0" {
+		t.Errorf("History() = %+v, want the cached tree's version", h)
+	}
+	if snap, _ := c.Snapshot("git", "1.0"); snap == nil || snap.Name != "git" {
+		t.Errorf("Snapshot() = %+v, want the cached tree", snap)
+	}
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// Cache backends, selected with OpenBackend.
+const (
+	// BackendSQLite keeps the cache in dir/cache.db. It needs a cgo build.
+	BackendSQLite = "sqlite"
+	// BackendFiles keeps the cache as plain files under dir/cache, in
+	// pure Go.
+	BackendFiles = "files"
+)
+
+// store is where a Cache keeps its data. Trees are stored as JSON; the
+// Cache decodes them and implements expiry and eviction on top.
+type store interface {
+	// tree returns the tree stored under key, or nil if none.
+	tree(key string) (*treeRecord, error)
+	putTree(r *treeRecord) error
+	touchTree(key string, at time.Time) error
+	deleteTree(key string) error
+	// trees lists every stored tree without its data, by CLI and newest
+	// first within each CLI.
+	trees() ([]treeRecord, error)
+
+	// snapshot returns the snapshot of cli at version, or nil if none;
+	// with data false it is returned without its data.
+	snapshot(cli, version string, data bool) (*treeRecord, error)
+	// putSnapshot stores r as the snapshot of r.cli at r.version; r.key
+	// is not used.
+	putSnapshot(r *treeRecord) error
+	// snapshots lists the snapshots of cli without their data.
+	snapshots(cli string) ([]treeRecord, error)
+
+	// help returns the help text stored for path ("" = none) and when it
+	// was stored.
+	help(cli, version, path string) (string, time.Time, error)
+	putHelp(cli, version, path, help string, at time.Time) error
+	// helpSize returns the bytes of help text stored for cli at version.
+	helpSize(cli, version string) (int64, error)
+	deleteHelp(cli, version string) error
+
+	state(cli string) ([]byte, error)
+	putState(cli string, data []byte, at time.Time) error
+
+	addFlagValue(cli, flag, value string, at time.Time) error
+	// flagValues returns up to limit values of flag, most used first and
+	// most recent among equals.
+	flagValues(cli, flag string, limit int) ([]string, error)
+
+	// addUse counts item, one of cli's commands or flags, as used once
+	// more.
+	addUse(cli, item string, at time.Time) error
+	// uses returns how often each of cli's items was used, by item.
+	uses(cli string) (map[string]int, error)
+
+	// notes returns the notes on cli's commands, by full command.
+	notes(cli string) (map[string]string, error)
+	// putNote stores note on command of cli, deleting it when note is "".
+	putNote(cli, command, note string, at time.Time) error
+	// snippets returns the commands saved as snippets of cli, by name.
+	snippets(cli string) (map[string]string, error)
+	// putSnippet saves command as the snippet name of cli, deleting it
+	// when command is "".
+	putSnippet(cli, name, command string, at time.Time) error
+	// env returns the environment variables set for cli's commands, by
+	// name.
+	env(cli string) (map[string]string, error)
+	// putEnv sets the variable name for cli's commands to value, deleting
+	// it when value is "".
+	putEnv(cli, name, value string, at time.Time) error
+
+	// addRun records run of cli, keeping only its keep most recent runs.
+	addRun(cli string, run models.Run, keep int) error
+	// runs returns the recorded runs of cli, newest first.
+	runs(cli string) ([]models.Run, error)
+
+	// stamp returns what putStamp recorded for cli, "" if nothing.
+	stamp(cli string) (string, error)
+	putStamp(cli, stamp string) error
+
+	// size returns the bytes of trees and help text stored.
+	size() (int64, error)
+	// clear removes everything stored for cli, or everything when cli is
+	// "", except notes, snippets and environment variables: the user
+	// wrote those, and no rediscovery brings them back.
+	clear(cli string) error
+	// compact returns space freed by deletions to the file system.
+	compact() error
+	close() error
+}
+
+// treeRecord is a stored tree and its metadata.
+type treeRecord struct {
+	key      string
+	cli      string
+	version  string
+	strategy string
+	binary   Binary
+	cachedAt time.Time
+	usedAt   time.Time
+	size     int    // bytes of data
+	data     []byte // the tree as JSON; nil when listed by trees
+}
+
+// backends maps backend names to their constructors, which open (or
+// create) the store in dir. BackendSQLite registers itself in cgo builds.
+var backends = map[string]func(dir string) (store, error){
+	BackendFiles: openFiles,
+}
+
+// Backends returns the names of the backends compiled in, sorted.
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// DefaultBackend returns the backend Open uses: BackendSQLite when compiled
+// in, BackendFiles otherwise.
+func DefaultBackend() string {
+	if _, ok := backends[BackendSQLite]; ok {
+		return BackendSQLite
+	}
+	return BackendFiles
+}
+
+// OpenBackend opens (or creates) the cache in dir using the named backend;
+// "" or "auto" selects DefaultBackend.
+func OpenBackend(dir, backend string) (*Cache, error) {
+	if backend == "" || backend == "auto" {
+		backend = DefaultBackend()
+	}
+	open, ok := backends[backend]
+	if !ok {
+		if backend == BackendSQLite {
+			return nil, fmt.Errorf("the %s cache backend needs a cgo build of treemand; use %q", backend, BackendFiles)
+		}
+		return nil, fmt.Errorf("unknown cache backend %q (want one of %v)", backend, Backends())
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	lock, err := openLock(filepath.Join(dir, "cache.lock"))
+	if err != nil {
+		return nil, err
+	}
+	// Opening may create or migrate the store, which must not race with
+	// another process doing the same.
+	var s store
+	if err := lock.do(func() (err error) { s, err = open(dir); return err }); err != nil {
+		lock.close()
+		return nil, err
+	}
+	return &Cache{s: s, lock: lock}, nil
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/aallbrig/treemand/cache"
-	"github.com/aallbrig/treemand/config"
 )
 
 var cacheCmd = &cobra.Command{
@@ -29,8 +28,8 @@ Examples:
   treemand cache clear git      # clear only git's cached entries`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg := config.DefaultConfig()
-		c, err := cache.Open(cfg.CacheDir)
+		cfg := resolveConfig()
+		c, err := cache.OpenBackend(cfg.CacheDir, cfg.CacheBackend)
 		if err != nil {
 			return fmt.Errorf("open cache: %w", err)
 		}
@@ -57,8 +56,8 @@ var cacheListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List CLIs with cached discovery results",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg := config.DefaultConfig()
-		c, err := cache.Open(cfg.CacheDir)
+		cfg := resolveConfig()
+		c, err := cache.OpenBackend(cfg.CacheDir, cfg.CacheBackend)
 		if err != nil {
 			return fmt.Errorf("open cache: %w", err)
 		}
@@ -74,18 +73,78 @@ var cacheListCmd = &cobra.Command{
 		}
 
 		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "CLI\tVERSION\tSTRATEGY\tCACHED AT\tSIZE")
-		fmt.Fprintln(w, "---\t-------\t--------\t---------\t----")
+		fmt.Fprintln(w, "CLI\tVERSION\tSTRATEGY\tCACHED AT\tLAST USED\tSIZE\tBINARY")
+		fmt.Fprintln(w, "---\t-------\t--------\t---------\t---------\t----\t------")
 		for _, e := range entries {
 			age := formatAge(time.Since(e.CachedAt))
+			used := formatAge(time.Since(e.UsedAt))
 			size := formatBytes(e.SizeBytes)
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-				e.CLI, e.Version, e.Strategy, age, size)
+			bin := e.Binary.String()
+			if bin == "" {
+				bin = "-" // cached before binaries were recorded
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				e.CLI, e.Version, e.Strategy, age, used, size, bin)
 		}
 		return w.Flush()
 	},
 }
 
+var cachePruneMaxSize int
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Evict least-recently-used trees over the cache size limit",
+	Long: `Prune evicts cached trees, least recently used first, until the cache
+holds at most cache_max_size_mb megabytes (default 100) of trees and help
+text, or --max-size when given. Evicting the last tree of a CLI version also
+evicts the help text cached for it; the most recently used tree is always
+kept. The database file is then compacted.
+
+Discovery prunes the same way after caching a tree, so this is only needed
+after lowering the limit, or to make room now.
+
+Examples:
+  treemand cache prune                 # enforce the configured limit
+  treemand cache prune --max-size=10   # shrink the cache to 10 MB`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := resolveConfig()
+		limit := cfg.CacheMaxSize
+		if cmd.Flags().Changed("max-size") {
+			if cachePruneMaxSize <= 0 {
+				return fmt.Errorf("--max-size must be positive")
+			}
+			limit = int64(cachePruneMaxSize) << 20
+		}
+		if limit <= 0 {
+			return fmt.Errorf("the cache size is unlimited (cache_max_size_mb: 0); pass --max-size")
+		}
+		c, err := cache.OpenBackend(cfg.CacheDir, cfg.CacheBackend)
+		if err != nil {
+			return fmt.Errorf("open cache: %w", err)
+		}
+		defer c.Close()
+
+		res, err := c.Prune(limit)
+		if err != nil {
+			return fmt.Errorf("prune cache: %w", err)
+		}
+		if res.Trees == 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "Cache holds %s, within the %s limit.\n",
+				formatBytes(int(res.Size)), formatBytes(int(limit)))
+			return nil
+		}
+		if err := c.Vacuum(); err != nil {
+			return fmt.Errorf("compact cache: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Evicted %s (%s); cache holds %s of %s.\n",
+			plural(res.Trees, "tree", "trees"), formatBytes(int(res.Freed)),
+			formatBytes(int(res.Size)), formatBytes(int(limit)))
+		return nil
+	},
+}
+
 func formatAge(d time.Duration) string {
 	switch {
 	case d < time.Minute:
@@ -113,4 +172,7 @@ func formatBytes(n int) string {
 func init() {
 	cacheCmd.AddCommand(cacheClearCmd)
 	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cacheRefreshCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	cachePruneCmd.Flags().IntVar(&cachePruneMaxSize, "max-size", 0, "Megabytes to prune the cache to (default: cache_max_size_mb)")
 }
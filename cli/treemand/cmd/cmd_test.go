@@ -2,13 +2,21 @@ package cmd_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 
+	"github.com/aallbrig/treemand/cache"
 	"github.com/aallbrig/treemand/cmd"
+	"github.com/aallbrig/treemand/discovery"
+	"github.com/aallbrig/treemand/models"
 )
 
 func runCmd(args ...string) (string, error) {
@@ -33,12 +41,20 @@ func TestVersion(t *testing.T) {
 
 func TestRootNoArgs(t *testing.T) {
 	_, err := runCmd()
-	// Cobra returns an error when no args provided (ExactArgs(1))
+	// Cobra returns an error when no CLI is named (MinimumNArgs(1)) and
+	// the output is not a terminal to show the launcher on.
 	if err == nil {
 		t.Error("expected error with no args")
 	}
 }
 
+func TestRootTabsNeedsInteractive(t *testing.T) {
+	_, err := runCmd("--tabs", "git", "ls")
+	if err == nil || !strings.Contains(err.Error(), "needs -i") {
+		t.Errorf("--tabs without -i: err = %v, want it to ask for -i", err)
+	}
+}
+
 func TestRootHelp(t *testing.T) {
 	out, err := runCmd("--help")
 	if err != nil {
@@ -106,6 +122,13 @@ func TestRootFilter(t *testing.T) {
 	_ = err
 }
 
+func TestRootMinConfidence_outOfRange(t *testing.T) {
+	_, err := runCmd("--no-cache", "--min-confidence=2", "echo")
+	if err == nil || !strings.Contains(err.Error(), "--min-confidence") {
+		t.Errorf("expected a --min-confidence range error, got %v", err)
+	}
+}
+
 func TestRootDepthZero(t *testing.T) {
 	out, err := runCmd("--no-cache", "--no-color", "--depth=0", "--timeout=5", "echo")
 	_ = err
@@ -115,6 +138,117 @@ func TestRootDepthZero(t *testing.T) {
 	}
 }
 
+func TestRootStats(t *testing.T) {
+	out, err := runCmd("--no-cache", "--no-color", "--stats", "--timeout=5", "echo")
+	if err != nil {
+		t.Skipf("echo not discoverable: %v", err)
+	}
+	if !strings.Contains(out, "commands · ") || !strings.Contains(out, "discovered in ") {
+		t.Errorf("expected stats footer, got: %q", out)
+	}
+	out, err = runCmd("--no-cache", "--output=json", "--stats", "--timeout=5", "echo")
+	if err == nil && strings.Contains(out, "commands · ") {
+		t.Errorf("stats footer must not be appended to JSON output: %q", out)
+	}
+}
+
+func TestStatsCmd_json(t *testing.T) {
+	out, err := runCmd("stats", "--no-cache", "--output=json", "--timeout=5", "echo")
+	if err != nil {
+		t.Skipf("echo not discoverable: %v", err)
+	}
+	var rep struct {
+		CLI      string `json:"cli"`
+		Commands int    `json:"commands"`
+		TopFlags []any  `json:"top_flags"`
+	}
+	if err := json.Unmarshal([]byte(out), &rep); err != nil {
+		t.Fatalf("stats JSON did not parse: %v\n%s", err, out)
+	}
+	if rep.CLI != "echo" || rep.Commands < 1 || rep.TopFlags == nil {
+		t.Errorf("unexpected stats: %+v", rep)
+	}
+}
+
+func TestStatsCmd_rejectsYAML(t *testing.T) {
+	if _, err := runCmd("stats", "--no-cache", "--output=yaml", "echo"); err == nil {
+		t.Error("expected error for --output=yaml")
+	}
+}
+
+func TestSearchCmd(t *testing.T) {
+	out, err := runCmd("search", "--no-cache", "--timeout=5", "echo", "echo")
+	if err != nil {
+		t.Skipf("echo not discoverable: %v", err)
+	}
+	if !strings.HasPrefix(out, "echo") || !strings.Contains(out, "[name") {
+		t.Errorf("search output = %q", out)
+	}
+	if _, err := runCmd("search", "--no-cache", "--timeout=5", "echo", "zzz-no-such-term"); err == nil {
+		t.Error("expected error when nothing matches")
+	}
+}
+
+const parseHelp = `Usage: pkgtool [-h] {install,remove} ...
+
+Manage packages.
+
+options:
+  -h, --help  show this help message and exit
+`
+
+func TestParseCmd(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "help.txt")
+	if err := os.WriteFile(file, []byte(parseHelp), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCmd("parse", "--stdin=false", "--output=json", file)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var node models.Node
+	if err := json.Unmarshal([]byte(out), &node); err != nil {
+		t.Fatalf("parse output is not a JSON tree: %v\n%s", err, out)
+	}
+	if node.Name != "pkgtool" || len(node.Flags) != 1 || len(node.Children) != 2 || !node.Children[0].Stub {
+		t.Errorf("parsed node = %+v", node)
+	}
+
+	c := cmd.NewRootCmd()
+	buf := &bytes.Buffer{}
+	c.SetOut(buf)
+	c.SetErr(buf)
+	c.SetIn(strings.NewReader(parseHelp))
+	c.SetArgs([]string{"parse", "--stdin", "--name=tool", "--no-color"})
+	if err := c.Execute(); err != nil {
+		t.Fatalf("parse --stdin: %v", err)
+	}
+	if !strings.Contains(buf.String(), "tool") || !strings.Contains(buf.String(), "install") {
+		t.Errorf("parse --stdin output = %q", buf.String())
+	}
+
+	if _, err := runCmd("parse", "--stdin", file); err == nil {
+		t.Error("expected an error for both a file and --stdin")
+	}
+}
+
+func TestParseCmd_parserProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "help.txt")
+	if err := os.WriteFile(file, []byte(parseHelp), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd("parse", "--stdin=false", "--parser-profile=cobbra", file); err == nil || !strings.Contains(err.Error(), "unknown parser profile") {
+		t.Errorf("expected an unknown parser profile error, got %v", err)
+	}
+	out, err := runCmd("parse", "--stdin=false", "--name=", "--parser-profile=generic", "--output=json", file)
+	if err != nil {
+		t.Fatalf("parse --parser-profile=generic: %v", err)
+	}
+	if !strings.Contains(out, `"name": "pkgtool"`) {
+		t.Errorf("parse output = %s", out)
+	}
+}
+
 func TestRootUnknownBinary(t *testing.T) {
 	_, err := runCmd("--no-cache", "--timeout=5", "nonexistent_cli_xyz_99999")
 	if err == nil {
@@ -156,6 +290,32 @@ func TestRootIcons_ascii(t *testing.T) {
 	_ = err // just check no panic
 }
 
+func TestRootPipedOutputIsPlain(t *testing.T) {
+	// The test buffer is not a terminal, so output is uncolored and ASCII.
+	out, err := runCmd("--no-cache", "--timeout=5", "echo")
+	if err != nil {
+		t.Skipf("echo discovery error (acceptable): %v", err)
+	}
+	if strings.Contains(out, "\x1b[") {
+		t.Errorf("piped output contains ANSI escapes: %q", out)
+	}
+	for _, glyph := range []string{"├", "└", "│"} {
+		if strings.Contains(out, glyph) {
+			t.Errorf("piped output contains %q: %q", glyph, out)
+		}
+	}
+}
+
+func TestRootASCII(t *testing.T) {
+	out, err := runCmd("--no-cache", "--ascii", "--timeout=5", "echo")
+	if err != nil {
+		t.Skipf("echo discovery error (acceptable): %v", err)
+	}
+	if strings.ContainsAny(out, "▼▶•◆") {
+		t.Errorf("--ascii output contains Unicode icons: %q", out)
+	}
+}
+
 func TestRootIcons_nerd(t *testing.T) {
 	_, err := runCmd("--no-cache", "--no-color", "--icons=nerd", "--timeout=5", "echo")
 	_ = err
@@ -267,6 +427,20 @@ func TestVersionCmd(t *testing.T) {
 	}
 }
 
+func TestSchemaCmd(t *testing.T) {
+	out, err := runCmd("schema")
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(out), &schema); err != nil {
+		t.Fatalf("schema output is not valid JSON: %v", err)
+	}
+	if _, ok := schema["$defs"]; !ok {
+		t.Errorf("schema output missing $defs: %q", out)
+	}
+}
+
 // ── genDocs ───────────────────────────────────────────────────────────────────
 
 func TestGenDocs_md(t *testing.T) {
@@ -554,6 +728,18 @@ func TestSpinner_nonTTY_writesNothing(t *testing.T) {
 	}
 }
 
+func TestSpinner_SetLabel_nonTTY_writesNothing(t *testing.T) {
+	var buf bytes.Buffer
+	s := cmd.NewSpinner(&buf)
+	s.SetLabel("before start")
+	s.Start("testing…")
+	s.SetLabel("testing… 12 commands so far")
+	s.Stop()
+	if buf.Len() != 0 {
+		t.Errorf("spinner wrote %d bytes to non-TTY writer, want 0", buf.Len())
+	}
+}
+
 func TestSpinner_Stop_beforeStart_noPanic(t *testing.T) {
 	var buf bytes.Buffer
 	s := cmd.NewSpinner(&buf)
@@ -568,3 +754,725 @@ func TestSpinner_doubleStop_noPanic(t *testing.T) {
 	s.Stop()
 	s.Stop() // second stop must be safe
 }
+
+func TestRootTemplate(t *testing.T) {
+	tmpl := filepath.Join(t.TempDir(), "tree.tmpl")
+	if err := os.WriteFile(tmpl, []byte("{{range walk .}}cmd={{path .}}\n{{end}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCmd("--no-cache", "--output=template", "--template="+tmpl, "--timeout=5", "echo")
+	if err != nil {
+		t.Skipf("echo discovery error (acceptable): %v", err)
+	}
+	if !strings.HasPrefix(out, "cmd=echo\n") {
+		t.Errorf("template output = %q, want it to start with cmd=echo", out)
+	}
+}
+
+func TestRootTemplate_requiresBothFlags(t *testing.T) {
+	if _, err := runCmd("--no-cache", "--output=template", "echo"); err == nil {
+		t.Error("expected error for --output=template without --template")
+	}
+	if _, err := runCmd("--no-cache", "--template=x.tmpl", "echo"); err == nil {
+		t.Error("expected error for --template without --output=template")
+	}
+}
+
+func TestRootCSV(t *testing.T) {
+	out, err := runCmd("--no-cache", "--output=csv", "--timeout=5", "echo")
+	if err != nil {
+		t.Skipf("echo discovery error (acceptable): %v", err)
+	}
+	if !strings.HasPrefix(out, "command,description,flags\necho,") {
+		t.Errorf("csv output = %q", out)
+	}
+}
+
+func TestGenMan(t *testing.T) {
+	dir := t.TempDir()
+	out, err := runCmd("--no-cache", "--timeout=5", "gen-man", "--dir="+dir, "echo")
+	if err != nil {
+		t.Skipf("echo discovery error (acceptable): %v", err)
+	}
+	if !strings.Contains(out, "man pages to "+dir) {
+		t.Errorf("gen-man output = %q", out)
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "echo.1"))
+	if err != nil {
+		t.Fatalf("read man page: %v", err)
+	}
+	if !strings.HasPrefix(string(page), `.TH "ECHO" "1"`) {
+		t.Errorf("unexpected man page:\n%s", page)
+	}
+}
+
+// brokenCLI installs a fake CLI on PATH whose "bad" subcommand fails.
+func brokenCLI(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncase \"$1\" in\n  bad) exit 1 ;;\n  good) printf 'Usage: brokencli good\\n\\nworks fine\\n' ;;\n" +
+		"  ''|-h|--help) printf 'brokencli does things\\n\\nCommands:\\n  bad    broken\\n  good   fine\\n' ;;\n  *) exit 1 ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, "brokencli"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRootPruneAndShowErrors(t *testing.T) {
+	brokenCLI(t)
+	out, err := runCmd("--no-cache", "--output=flat", "--commands-only", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "brokencli bad") {
+		t.Fatalf("expected the failed command without --prune-errors, got %q", out)
+	}
+
+	out, err = runCmd("--no-cache", "--output=flat", "--commands-only", "--prune-errors", "--show-errors", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "brokencli bad\n") {
+		t.Errorf("--prune-errors should drop the failed command, got %q", out)
+	}
+	if !strings.Contains(out, "brokencli good") {
+		t.Errorf("healthy commands should be kept, got %q", out)
+	}
+	if !strings.Contains(out, "1 command could not be discovered:\n  brokencli bad: could not get help") {
+		t.Errorf("--show-errors should list the failure, got %q", out)
+	}
+}
+
+func TestRootPruneDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncase \"$1\" in\n  ls|list) printf 'Usage: dupecli ls\\n\\nlists things\\n' ;;\n" +
+		"  other) printf 'Usage: dupecli other\\n\\ndoes other things\\n' ;;\n" +
+		"  *) printf 'dupecli does things\\n\\nCommands:\\n  ls     list things\\n  list   list things\\n  other  other things\\n' ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, "dupecli"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	out, err := runCmd("--no-cache", "--output=flat", "--commands-only", "dupecli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "dupecli list") {
+		t.Fatalf("expected the duplicate kept as an alias without --prune-duplicates, got %q", out)
+	}
+
+	out, err = runCmd("--no-cache", "--output=flat", "--commands-only", "--prune-duplicates", "dupecli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "dupecli list") {
+		t.Errorf("--prune-duplicates should drop the duplicate, got %q", out)
+	}
+	if !strings.Contains(out, "dupecli ls") || !strings.Contains(out, "dupecli other") {
+		t.Errorf("the first of the duplicates and the other commands should stay, got %q", out)
+	}
+
+	out, err = runCmd("--no-cache", "--output=jsonl", "--prune-duplicates", "dupecli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, `"name":"list"`) || !strings.Contains(out, `"name":"ls"`) {
+		t.Errorf("--prune-duplicates should drop the duplicate from JSON Lines, got %q", out)
+	}
+}
+
+func TestRootJSONLinesOverride(t *testing.T) {
+	brokenCLI(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "brokencli.yaml"), []byte("commands:\n  - name: bad\n    remove: true\n  - name: good\n    description: overridden\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TREEMAND_OVERRIDES_DIR", dir)
+	out, err := runCmd("--no-cache", "--output=jsonl", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, `"name":"bad"`) {
+		t.Errorf("the override should remove bad from JSON Lines, got %q", out)
+	}
+	if !strings.Contains(out, `"description":"overridden"`) {
+		t.Errorf("the override should describe good, got %q", out)
+	}
+}
+
+func TestRootJSONLinesPruneAndShowErrors(t *testing.T) {
+	brokenCLI(t)
+	out, err := runCmd("--no-cache", "--output=jsonl", "--prune-errors", "--show-errors", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, `"name":"bad"`) {
+		t.Errorf("--prune-errors should drop the failed command from JSON Lines, got %q", out)
+	}
+	if !strings.Contains(out, `"name":"good"`) {
+		t.Errorf("healthy commands should be written, got %q", out)
+	}
+	if !strings.Contains(out, "1 command could not be discovered:\n  brokencli bad: could not get help") {
+		t.Errorf("--show-errors should list the failure, got %q", out)
+	}
+}
+
+func TestRootJSONLinesFilterExcludeSort(t *testing.T) {
+	brokenCLI(t)
+	out, err := runCmd("--no-cache", "--output=jsonl", "--filter=good", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, `"name":"bad"`) || !strings.Contains(out, `"name":"good"`) {
+		t.Errorf("--filter should apply to JSON Lines, got %q", out)
+	}
+	out, err = runCmd("--no-cache", "--output=jsonl", "--exclude=good", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, `"name":"good"`) || !strings.Contains(out, `"name":"bad"`) {
+		t.Errorf("--exclude should apply to JSON Lines, got %q", out)
+	}
+	out, err = runCmd("--no-cache", "--output=jsonl", "--sort=name", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bad, good := strings.Index(out, `"name":"bad"`), strings.Index(out, `"name":"good"`); bad < 0 || good < bad {
+		t.Errorf("--sort=name should order JSON Lines, got %q", out)
+	}
+}
+
+func TestRootTiming(t *testing.T) {
+	brokenCLI(t)
+	out, err := runCmd("--no-cache", "--output=flat", "--timing", "--prune-errors", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Probed 3 commands with ") || !strings.Contains(out, "Slowest commands:") {
+		t.Fatalf("expected the timing summary, got %q", out)
+	}
+	// Pruned commands still count: they are often the slow ones.
+	if !regexp.MustCompile(`ms  +\d+ execs  1 attempt  brokencli bad\n`).MatchString(out) {
+		t.Errorf("expected a timing row for the failed command, got %q", out)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	brokenCLI(t)
+	out, err := runCmd("--no-cache", "doctor", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "brokencli: 1 problem in 3 commands (0 timeouts, 1 error, 0 anomalies)") {
+		t.Errorf("unexpected summary: %q", out)
+	}
+	if !strings.Contains(out, "error  brokencli bad  could not get help") {
+		t.Errorf("expected the failed command to be listed: %q", out)
+	}
+
+	out, err = runCmd("--no-cache", "--output=json", "doctor", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags []map[string]string
+	if err := json.Unmarshal([]byte(out), &diags); err != nil {
+		t.Fatalf("doctor --output=json is not JSON: %v\n%s", err, out)
+	}
+	if len(diags) != 1 || diags[0]["kind"] != "error" || diags[0]["command"] != "brokencli bad" {
+		t.Errorf("diagnostics = %v", diags)
+	}
+}
+
+func TestRootMergePolicy(t *testing.T) {
+	brokenCLI(t)
+	if _, err := runCmd("--no-cache", "--merge-policy=richer", "brokencli"); err != nil {
+		t.Errorf("--merge-policy=richer: %v", err)
+	}
+	_, err := runCmd("--no-cache", "--merge-policy=newest", "brokencli")
+	if err == nil || !strings.Contains(err.Error(), `unknown merge policy "newest"`) {
+		t.Errorf("err = %v, want unknown merge policy", err)
+	}
+}
+
+func TestRootTraceFile(t *testing.T) {
+	brokenCLI(t)
+	trace := filepath.Join(t.TempDir(), "trace.jsonl")
+	if _, err := runCmd("--no-cache", "--trace-file="+trace, "brokencli"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exits := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec discovery.ExecRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("trace line %q: %v", line, err)
+		}
+		if len(rec.Command) == 0 || filepath.Base(rec.Command[0]) != "brokencli" {
+			t.Errorf("trace line %q: unexpected command", line)
+			continue
+		}
+		exits[strings.Join(rec.Command[1:], " ")] = rec.ExitCode
+		if rec.ExitCode == 0 && rec.OutputBytes == 0 {
+			t.Errorf("trace line %q: no output recorded", line)
+		}
+	}
+	if code, ok := exits["--help"]; !ok || code != 0 {
+		t.Errorf("brokencli --help: exit %d (traced: %v), want 0", code, ok)
+	}
+	if code, ok := exits["bad --help"]; !ok || code != 1 {
+		t.Errorf("brokencli bad --help: exit %d (traced: %v), want 1", code, ok)
+	}
+}
+
+func TestRootOffline(t *testing.T) {
+	brokenCLI(t)
+	t.Setenv("TREEMAND_CACHE_DIR", t.TempDir())
+	_, err := runCmd("--offline", "brokencli")
+	if err == nil || !strings.Contains(err.Error(), `no cached tree for "brokencli"`) ||
+		!strings.Contains(err.Error(), "without --offline") {
+		t.Fatalf("expected a not-cached error with a hint, got %v", err)
+	}
+	if _, err := runCmd("--offline", "--no-cache", "brokencli"); err == nil || !strings.Contains(err.Error(), "--no-cache") {
+		t.Errorf("expected --offline to reject --no-cache, got %v", err)
+	}
+
+	if _, err := runCmd("--output=flat", "brokencli"); err != nil {
+		t.Fatal(err)
+	}
+	// Replace the CLI with one that records being run: offline runs must
+	// serve the cached tree without executing it.
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	script := "#!/bin/sh\ntouch " + marker + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "brokencli"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	out, err := runCmd("--offline", "--output=flat", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "brokencli good") {
+		t.Errorf("expected the cached tree, got %q", out)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("--offline ran the CLI")
+	}
+}
+
+func TestCacheRefresh(t *testing.T) {
+	brokenCLI(t)
+	t.Setenv("TREEMAND_CACHE_DIR", t.TempDir())
+	if _, err := runCmd("cache", "refresh"); err == nil || !strings.Contains(err.Error(), "--all") {
+		t.Fatalf("expected refresh without CLIs to ask for --all, got %v", err)
+	}
+
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"brokencli", "nonexistent_cli_xyz_99999"}, "brokencli: not cached, re-discovered in"},
+		{[]string{"brokencli"}, "brokencli: up to date"},
+		{nil, "brokencli: binary changed, re-discovered in"}, // after touching the binary
+		{[]string{"--all", "--force"}, "brokencli: forced, re-discovered in"},
+	}
+	for i, step := range steps {
+		if step.args == nil {
+			bin, err := exec.LookPath("brokencli")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(bin, time.Now(), time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			step.args = []string{"brokencli"}
+		}
+		out, err := runCmd(append([]string{"cache", "refresh"}, step.args...)...)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if !strings.Contains(out, step.want) {
+			t.Errorf("step %d: expected %q, got %q", i, step.want, out)
+		}
+	}
+	if out, _ := runCmd("cache", "refresh", "--all"); !strings.Contains(out, "brokencli") || strings.Contains(out, "nonexistent") {
+		t.Errorf("--all should refresh only cached CLIs, got %q", out)
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	brokenCLI(t)
+	t.Setenv("TREEMAND_CACHE_DIR", t.TempDir())
+	if _, err := runCmd("--output=flat", "brokencli"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCmd("cache", "prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "within the 100.0MB limit") {
+		t.Errorf("expected the default limit to be met, got %q", out)
+	}
+	if _, err := runCmd("cache", "prune", "--max-size=0"); err == nil {
+		t.Error("expected --max-size=0 to be rejected")
+	}
+	if out, _ := runCmd("cache", "list"); !strings.Contains(out, "LAST USED") || !strings.Contains(out, "brokencli") {
+		t.Errorf("expected cache list to show the entry with its last use, got %q", out)
+	}
+}
+
+func TestCacheBackendFiles(t *testing.T) {
+	brokenCLI(t)
+	dir := t.TempDir()
+	t.Setenv("TREEMAND_CACHE_DIR", dir)
+	t.Setenv("TREEMAND_CACHE_BACKEND", "files")
+	if _, err := runCmd("--output=flat", "brokencli"); err != nil {
+		t.Fatal(err)
+	}
+	if trees, _ := filepath.Glob(filepath.Join(dir, "cache", "trees", "*.tree")); len(trees) != 1 {
+		t.Errorf("expected one tree file in the file cache, got %v", trees)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cache.db")); err == nil {
+		t.Error("the file backend should not create cache.db")
+	}
+	if out, _ := runCmd("cache", "list"); !strings.Contains(out, "brokencli") {
+		t.Errorf("cache list should read the file cache, got %q", out)
+	}
+}
+
+func TestCacheKeyedByBinary(t *testing.T) {
+	brokenCLI(t)
+	t.Setenv("TREEMAND_CACHE_DIR", t.TempDir())
+	if _, err := runCmd("--output=flat", "brokencli"); err != nil {
+		t.Fatal(err)
+	}
+	// Another brokencli earlier on PATH must not be served the first one's
+	// cached tree.
+	dir := t.TempDir()
+	script := "#!/bin/sh\nprintf 'brokencli does other things\\n\\nCommands:\\n  other   different\\n'\n"
+	if err := os.WriteFile(filepath.Join(dir, "brokencli"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	out, err := runCmd("--output=flat", "--commands-only", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "brokencli other") || strings.Contains(out, "brokencli good") {
+		t.Errorf("expected the tree of the binary now on PATH, got %q", out)
+	}
+	bin, _ := filepath.EvalSymlinks(filepath.Join(dir, "brokencli"))
+	out, _ = runCmd("cache", "list")
+	if !strings.Contains(out, "BINARY") || !strings.Contains(out, bin) {
+		t.Errorf("expected cache list to show which binary each tree came from, got %q", out)
+	}
+}
+
+func TestNotesInOutput(t *testing.T) {
+	brokenCLI(t)
+	dir := t.TempDir()
+	t.Setenv("TREEMAND_CACHE_DIR", dir)
+	c, err := cache.OpenBackend(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutNote("brokencli", "brokencli good", "run it twice on prod"); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	out, err := runCmd("--output=rst", "--notes", "brokencli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, ".. note:: run it twice on prod") {
+		t.Errorf("--notes should add the note to its command's section, got %q", out)
+	}
+	if out, _ := runCmd("--output=rst", "brokencli"); strings.Contains(out, "run it twice") {
+		t.Errorf("notes should be left out without --notes, got %q", out)
+	}
+}
+
+func TestSnippetsCmd(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TREEMAND_CACHE_DIR", dir)
+	if out, err := runCmd("snippets", "kubectl"); err != nil || !strings.Contains(out, "No snippets of kubectl") {
+		t.Errorf("snippets with none saved = %q, %v", out, err)
+	}
+	c, err := cache.OpenBackend(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, command := range map[string]string{"pods": "kubectl get pods -n {{namespace}}", "nodes": "kubectl get nodes"} {
+		if err := c.PutSnippet("kubectl", name, command); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Close()
+
+	out, err := runCmd("snippets", "kubectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, j := strings.Index(out, "nodes"), strings.Index(out, "pods"); i < 0 || j < i || !strings.Contains(out, "kubectl get pods -n {{namespace}}") {
+		t.Errorf("snippets should list both by name, got %q", out)
+	}
+	if out, _ := runCmd("snippets", "kubectl", "pods"); out != "kubectl get pods -n {{namespace}}\n" {
+		t.Errorf("snippets kubectl pods = %q, want its command alone", out)
+	}
+	if _, err := runCmd("snippets", "kubectl", "nosuch"); err == nil {
+		t.Error("expected an unknown snippet to be an error")
+	}
+	out, _ = runCmd("snippets", "--output=json", "kubectl")
+	var entries []map[string]string
+	if err := json.Unmarshal([]byte(out), &entries); err != nil || len(entries) != 2 || entries[0]["name"] != "nodes" {
+		t.Errorf("--output=json = %q (%v), want both snippets sorted by name", out, err)
+	}
+	if _, err := runCmd("snippets", "--delete", "kubectl", "nodes"); err != nil {
+		t.Fatal(err)
+	}
+	c, err = cache.OpenBackend(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got, _ := c.Snippets("kubectl"); len(got) != 1 || got["nodes"] != "" {
+		t.Errorf("snippets after --delete nodes = %v, want pods only", got)
+	}
+}
+
+func TestRunsCmd(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TREEMAND_CACHE_DIR", dir)
+	if out, err := runCmd("runs", "kubectl"); err != nil || !strings.Contains(out, "No runs of kubectl") {
+		t.Errorf("runs with none recorded = %q, %v", out, err)
+	}
+	c, err := cache.OpenBackend(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, run := range []models.Run{
+		{Command: "kubectl get pods", StartedAt: time.Now().Add(-time.Hour), ExitCode: 0, Stdout: "web-1  Running\n"},
+		{Command: "kubectl delete pod web-1", StartedAt: time.Now(), ExitCode: 1, Stderr: "forbidden\n"},
+	} {
+		if err := c.AddRun("kubectl", run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Close()
+
+	out, err := runCmd("runs", "kubectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, j := strings.Index(out, "delete pod"), strings.Index(out, "get pods"); i < 0 || j < i {
+		t.Errorf("runs should list both, newest first, got %q", out)
+	}
+	if out, _ := runCmd("runs", "kubectl", "2"); out != "web-1  Running\n" {
+		t.Errorf("runs kubectl 2 = %q, want the output of the older run", out)
+	}
+	if out, _ := runCmd("runs", "kubectl", "1"); out != "forbidden\n" {
+		t.Errorf("runs kubectl 1 = %q, want the stderr of the newest run", out)
+	}
+	for _, n := range []string{"3", "0", "x"} {
+		if _, err := runCmd("runs", "kubectl", n); err == nil {
+			t.Errorf("expected run %s to be an error", n)
+		}
+	}
+	out, _ = runCmd("runs", "--output=json", "kubectl")
+	var runs []models.Run
+	if err := json.Unmarshal([]byte(out), &runs); err != nil || len(runs) != 2 || runs[0].ExitCode != 1 {
+		t.Errorf("--output=json = %q (%v), want both runs, newest first", out, err)
+	}
+}
+
+// versionedCLI puts a vcli script on PATH that reports version and lists
+// commands, replacing the one a previous call wrote.
+func versionedCLI(t *testing.T, dir, version string, commands ...string) {
+	t.Helper()
+	var list strings.Builder
+	for _, c := range commands {
+		list.WriteString("  " + c + "    does " + c + "\\n")
+	}
+	script := "#!/bin/sh\ncase \"$1\" in\n  --version) echo 'vcli version " + version + "' ;;\n" +
+		"  ''|-h|--help) printf 'vcli does things\\n\\nCommands:\\n" + list.String() + "' ;;\n" +
+		"  *) printf 'Usage: vcli %s\\n\\nruns %s\\n' \"$1\" \"$1\" ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, "vcli"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHistoryAndDiff(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("TREEMAND_CACHE_DIR", t.TempDir())
+	if _, err := runCmd("history", "vcli"); err == nil {
+		t.Error("expected history of an uncached CLI to fail")
+	}
+	versionedCLI(t, dir, "1.0.0", "build", "legacy")
+	if _, err := runCmd("--output=flat", "vcli"); err != nil {
+		t.Fatal(err)
+	}
+	versionedCLI(t, dir, "2.0.0", "build", "deploy")
+	if _, err := runCmd("--output=flat", "vcli"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCmd("history", "vcli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, j := strings.Index(out, "vcli version 2.0.0"), strings.Index(out, "vcli version 1.0.0"); i < 0 || j < i {
+		t.Errorf("expected both versions, newest first, got %q", out)
+	}
+
+	out, err = runCmd("diff", "vcli", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1 added, 1 removed", "+ vcli deploy", "- vcli legacy"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diff output missing %q: %q", want, out)
+		}
+	}
+	if strings.Contains(out, "vcli build") {
+		t.Errorf("unchanged commands should not be listed: %q", out)
+	}
+	out, err = runCmd("--output=json", "diff", "vcli", "2.0", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []models.Change
+	if err := json.Unmarshal([]byte(out), &changes); err != nil || len(changes) != 2 || changes[0].Kind != models.ChangeRemoved {
+		t.Errorf("diff --output=json = %q (%v), want deploy removed and legacy added", out, err)
+	}
+	if _, err := runCmd("diff", "vcli", "3.0"); err == nil {
+		t.Error("expected an unknown version to be rejected")
+	}
+	if _, err := runCmd("diff", "vcli", "0.0", "2.0"); err == nil || !strings.Contains(err.Error(), "several versions") {
+		t.Errorf("expected an ambiguous version to be rejected, got %v", err)
+	}
+}
+
+// handTree is a tree as written by hand: names only, no full paths.
+const handTree = `{"name": "tool", "description": "does tool things", "children": [
+  {"name": "sub", "description": "the sub command", "flags": [{"name": "--thing"}], "children": [
+    {"name": "leaf", "description": "the leaf"}
+  ]},
+  {"name": "other"}
+]}`
+
+func TestRootFromFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tool.json")
+	if err := os.WriteFile(file, []byte(handTree), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCmd("--from-file", file, "--output=flat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"tool sub leaf", "tool other"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	out, err = runCmd("--from-file", file, "--no-color", "tool", "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "tool sub") || !strings.Contains(out, "leaf") || strings.Contains(out, "other") {
+		t.Errorf("want the tool sub subtree only:\n%s", out)
+	}
+	if _, err := runCmd("--from-file", file, "tool", "nosuch"); err == nil || !strings.Contains(err.Error(), "no command") {
+		t.Errorf("missing subcommand: err = %v", err)
+	}
+	if _, err := runCmd("--from-file", file, "--from-stdin"); err == nil {
+		t.Error("--from-file with --from-stdin should fail")
+	}
+}
+
+func TestRootFromStdin(t *testing.T) {
+	c := cmd.NewRootCmd()
+	buf := &bytes.Buffer{}
+	c.SetOut(buf)
+	c.SetErr(buf)
+	c.SetIn(strings.NewReader(handTree))
+	c.SetArgs([]string{"--from-stdin", "--output=json"})
+	if err := c.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var root models.Node
+	if err := json.Unmarshal(buf.Bytes(), &root); err != nil {
+		t.Fatalf("%v:\n%s", err, buf)
+	}
+	if leaf := root.FindPath([]string{"sub", "leaf"}); leaf == nil || leaf.FullCommand() != "tool sub leaf" {
+		t.Errorf("leaf = %+v, want tool sub leaf", leaf)
+	}
+
+	c = cmd.NewRootCmd()
+	c.SetOut(buf)
+	c.SetErr(buf)
+	c.SetIn(strings.NewReader(`{"schema_version": "99", "name": "tool"}`))
+	c.SetArgs([]string{"--from-stdin"})
+	if err := c.Execute(); err == nil || !strings.Contains(err.Error(), "schema version") {
+		t.Errorf("tree of another schema version: err = %v", err)
+	}
+}
+
+const deploySpec = `name: deployctl
+commands:
+  - name: deploy
+    description: Deploy one service
+    args:
+      - {name: service, required: true}
+`
+
+func TestSpecValidateAndShow(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "deployctl.yaml")
+	bad := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(good, []byte(deploySpec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte("name: x\nflags:\n  - {name: verbose}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCmd("spec", "validate", good)
+	if err != nil || !strings.Contains(out, "spec is valid") {
+		t.Errorf("validate good spec: %q, %v", out, err)
+	}
+	out, err = runCmd("spec", "validate", bad)
+	if err == nil || !strings.Contains(out, `flag "verbose"`) {
+		t.Errorf("validate bad spec: %q, %v", out, err)
+	}
+
+	out, err = runCmd("--output=flat", "spec", "show", good)
+	if err != nil || !strings.Contains(out, "deployctl deploy") {
+		t.Errorf("spec show: %q, %v", out, err)
+	}
+	if _, err := runCmd("spec", "show", bad); err == nil {
+		t.Error("spec show of an invalid spec should fail")
+	}
+	out, err = runCmd("--from-file", good, "--output=flat", "deployctl", "deploy")
+	if err != nil || !strings.Contains(out, "deployctl deploy") {
+		t.Errorf("--from-file with a spec: %q, %v", out, err)
+	}
+}
+
+func TestEditCmd(t *testing.T) {
+	if _, err := runCmd("edit"); err == nil {
+		t.Error("edit without a CLI should fail")
+	}
+	file := filepath.Join(t.TempDir(), "tool.json")
+	if err := os.WriteFile(file, []byte(handTree), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd("--from-file", file, "edit", "tool"); err == nil || !strings.Contains(err.Error(), "discovered trees") {
+		t.Errorf("edit with --from-file: err = %v", err)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/aallbrig/treemand/cache"
-	"github.com/aallbrig/treemand/config"
 )
 
 // completionCmd provides shell completion script generation.
@@ -52,8 +51,8 @@ func completeCLIName(_ *cobra.Command, args []string, toComplete string) ([]stri
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	cfg := config.DefaultConfig()
-	c, err := cache.Open(cfg.CacheDir)
+	cfg := resolveConfig()
+	c, err := cache.OpenBackend(cfg.CacheDir, cfg.CacheBackend)
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
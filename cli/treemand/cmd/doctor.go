@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/discovery"
+	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/render"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor <cli>",
+	Short: "Report the problems met while discovering a CLI",
+	Long: `Report the problems met while discovering a CLI's command tree, to find
+out why part of it is missing:
+
+  timeout   the command's help did not arrive in time
+  error     the command's help could not be fetched
+  anomaly   help was fetched but looks wrong: identical to its parent's
+            or a sibling's, or nothing could be parsed from it
+
+The tree comes from the cache when fresh, otherwise it is discovered with
+the persistent flags (--depth, --strategy, --no-cache, --timeout); pass
+--no-cache to diagnose a fresh run. --output=json prints the problems as a
+JSON array.
+
+Examples:
+  treemand doctor aws
+  treemand doctor --no-cache --depth=-1 kubectl
+  treemand doctor --output=json gcloud | jq 'group_by(.kind)'`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeCLIName,
+	RunE:              runDoctor,
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	if err := checkOutputFormat(cfgOutput, "text", "json"); err != nil {
+		return err
+	}
+	root, err := loadCLI(args[0])
+	if err != nil {
+		return err
+	}
+	diags := root.Diagnostics
+	if diags == nil {
+		// Trees cached before diagnostics were recorded.
+		diags = discovery.Diagnose(root)
+	}
+	if cfgOutput == "json" {
+		if diags == nil {
+			diags = []models.Diagnostic{}
+		}
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(diags)
+	}
+	writeDiagnostics(cmd.OutOrStdout(), root, diags)
+	return nil
+}
+
+// plural formats n with the singular or plural noun, e.g. "1 error".
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, one)
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// doctorHints suggest what to try for each kind of problem.
+var doctorHints = map[string]string{
+	models.DiagTimeout:  "raise --per-command-timeout (--timeout bounds the whole run), or try --retries",
+	models.DiagError:    "run the command with --help yourself to see why it fails",
+	models.DiagAnomaly:  "the CLI may not support --help on these commands; try --strategy=help,completions",
+	models.DiagConflict: "list the strategy you trust first in --strategy, or keep the richer value with --merge-policy=richer",
+}
+
+// writeDiagnostics prints a summary line, one row per problem and a hint
+// per kind of problem found.
+func writeDiagnostics(w io.Writer, root *models.Node, diags []models.Diagnostic) {
+	commands := render.Collect(root).Commands
+	if len(diags) == 0 {
+		fmt.Fprintf(w, "%s: no problems in %d commands\n", root.Name, commands)
+		return
+	}
+	counts := map[string]int{}
+	for _, d := range diags {
+		counts[d.Kind]++
+	}
+	conflicts := ""
+	if n := counts[models.DiagConflict]; n > 0 {
+		conflicts = ", " + plural(n, "conflict", "conflicts")
+	}
+	fmt.Fprintf(w, "%s: %s in %d commands (%s, %s, %s%s)\n\n", root.Name,
+		plural(len(diags), "problem", "problems"), commands,
+		plural(counts[models.DiagTimeout], "timeout", "timeouts"),
+		plural(counts[models.DiagError], "error", "errors"),
+		plural(counts[models.DiagAnomaly], "anomaly", "anomalies"), conflicts)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, d := range diags {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", d.Kind, d.Command, d.Message)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	for _, kind := range []string{models.DiagTimeout, models.DiagError, models.DiagAnomaly, models.DiagConflict} {
+		if counts[kind] > 0 {
+			fmt.Fprintf(w, "Hint (%s): %s\n", kind, doctorHints[kind])
+		}
+	}
+}
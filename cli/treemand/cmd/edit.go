@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+// editing is set while treemand edit runs: output starts the TUI in edit
+// mode.
+var editing bool
+
+var editCmd = &cobra.Command{
+	Use:   "edit <cli> [subcommand...]",
+	Short: "Correct a CLI's tree in the TUI, saving the changes as its override",
+	Long: `Edit discovers a CLI like 'treemand -i' does and opens the TUI in edit
+mode, where the : command line changes the tree:
+
+  :rename NAME           rename the selected command or flag
+  :describe TEXT         set its description
+  :delete                delete it
+  :add command NAME      add a subcommand to the selected command
+  :add flag NAME [TYPE]  add a flag to it
+
+Every change is saved at once to the CLI's override file, overrides/<cli>.yaml
+in treemand's config directory (or overrides_dir), and is applied to every
+later run. The file is plain YAML, so corrections can be reviewed and shared.
+
+To explore a CLI called edit rather than run this command, use
+'treemand -- edit'.
+
+Examples:
+  treemand edit mytool
+  treemand edit git remote`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeCLIName,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cfgFromFile != "" || cfgFromStdin {
+			return errors.New("edit corrects discovered trees; it cannot be used with --from-file or --from-stdin")
+		}
+		cfgInteractive, editing = true, true
+		defer func() { editing = false }()
+		return runRoot(cmd, args)
+	},
+}
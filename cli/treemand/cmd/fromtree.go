@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/spec"
+	"github.com/aallbrig/treemand/treemand"
+)
+
+// rootArgs checks the root command's arguments: the CLI to discover and the
+// subcommand path to start at. A tree read with --from-file or --from-stdin
+// needs no CLI; the arguments, if any, name the command to start at. On a
+// terminal, no arguments open the launcher to pick a CLI in.
+func rootArgs(cmd *cobra.Command, args []string) error {
+	if cfgFromFile != "" || cfgFromStdin {
+		return nil
+	}
+	if len(args) == 0 && isTerminal(cmd.OutOrStdout()) {
+		return nil
+	}
+	return cobra.MinimumNArgs(1)(cmd, args)
+}
+
+// runFromTree shows the tree read from --from-file or --from-stdin, or its
+// subtree at the command path args name, the way runRoot shows a
+// discovered one. A .yaml, .yml or .toml file is read as a spec. The CLI is
+// never run, not even from the TUI.
+func runFromTree(cmd *cobra.Command, args []string) error {
+	if cfgFromFile != "" && cfgFromStdin {
+		return errors.New("--from-file and --from-stdin cannot be used together")
+	}
+	if spec.IsSpecFile(cfgFromFile) {
+		c, err := loadSpec(cfgFromFile)
+		if err != nil {
+			return err
+		}
+		return showTree(cmd, c.Node(), cfgFromFile, args)
+	}
+	src, r := "stdin", cmd.InOrStdin()
+	if cfgFromFile != "" {
+		f, err := os.Open(cfgFromFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		src, r = cfgFromFile, io.Reader(f)
+	}
+	root, err := models.ReadTree(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	return showTree(cmd, root, src, args)
+}
+
+// showTree shows root, read from src rather than discovered, or its subtree
+// at the command path args name. The CLI is never run.
+func showTree(cmd *cobra.Command, root *models.Node, src string, args []string) error {
+	if len(args) > 0 {
+		node := root.FindFullPath(args)
+		if node == nil {
+			return fmt.Errorf("%s has no command %q (its tree is of %s)", src, strings.Join(args, " "), root.FullCommand())
+		}
+		root = node
+	}
+
+	cfg := resolveConfig()
+	cfg.Offline = true
+	var failed []*models.Node
+	if cfg.PruneErrors {
+		failed = models.PruneErrors(root)
+	} else if cfgShowErrors {
+		failed = models.ErrorNodes(root)
+	}
+	if cfgMinConfidence > 0 {
+		models.PruneLowConfidence(root, cfgMinConfidence)
+	}
+	if err := output(cmd, &treemand.Result{Root: root}, cfg, nil, nil); err != nil {
+		return err
+	}
+	if cfgShowErrors && !cfgInteractive {
+		writeErrors(cmd.ErrOrStderr(), failed)
+	}
+	return nil
+}
@@ -3,10 +3,13 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/cobra/doc"
+
+	"github.com/aallbrig/treemand/discovery"
 )
 
 var genDocsOutputDir string
@@ -74,16 +77,37 @@ func buildDocRoot() *cobra.Command {
 
 	// Mirror all persistent flags
 	root.PersistentFlags().BoolP("interactive", "i", false, "Launch interactive TUI")
-	root.PersistentFlags().StringP("strategy", "s", "help", "Discovery strategies (comma-separated: help,completions)")
+	root.PersistentFlags().StringP("strategy", "s", "help", "Discovery strategies (comma-separated: help,completions,man,hidden)")
+	root.PersistentFlags().String("parser-profile", "auto", "Help parser profile: auto, "+strings.Join(discovery.ProfileNames(), ", "))
+	root.PersistentFlags().String("merge-policy", "first", "Which value wins when strategies disagree: "+strings.Join(discovery.MergePolicies, ", "))
+	root.PersistentFlags().String("via", "", `Run the CLI through a command prefix, such as "docker run image" or "wsl"`)
 	root.PersistentFlags().Int("depth", -1, "Max tree depth (-1 = unlimited)")
-	root.PersistentFlags().String("filter", "", "Only show nodes matching pattern")
-	root.PersistentFlags().String("exclude", "", "Exclude nodes matching pattern")
+	root.PersistentFlags().String("filter", "", "Only show nodes matching pattern (comma-separated regexes; terms with a space match the full path)")
+	root.PersistentFlags().String("exclude", "", "Exclude nodes matching pattern (same syntax as --filter)")
 	root.PersistentFlags().Bool("commands-only", false, "Hide flags and positionals")
 	root.PersistentFlags().Bool("full-path", false, "Show full command paths")
-	root.PersistentFlags().String("output", "text", "Output format: text, json")
-	root.PersistentFlags().Bool("no-color", false, "Disable color output")
+	root.PersistentFlags().Bool("prune-errors", false, "Drop commands whose help could not be fetched")
+	root.PersistentFlags().Float64("min-confidence", 0, "Drop commands and flags parsed from help with a confidence score below this (0-1)")
+	root.PersistentFlags().Bool("show-errors", false, "List commands whose help could not be fetched, with the error, on stderr")
+	root.PersistentFlags().String("output", "text", "Output format: text, json, yaml, jsonl, flat, template, csv, tsv, org, rst")
+	root.PersistentFlags().String("template", "", "Go text/template file for --output=template")
+	root.PersistentFlags().Bool("flag-rows", false, "With --output=csv or tsv, print one row per flag instead of per command")
+	root.PersistentFlags().Bool("notes", false, "With --output=org, rst or template, include the notes written in the TUI (Ctrl+N)")
+	root.PersistentFlags().Bool("flat", false, "Print one colored line per full command path instead of a tree")
+	root.PersistentFlags().Bool("stats", false, "Append a summary of command, flag and positional counts, discovery time and binary to text output")
+	root.PersistentFlags().Bool("timing", false, "Print probe counts and the 10 slowest commands to discover on stderr")
+	root.PersistentFlags().String("trace-file", "", "Append a JSON Lines record of every command discovery runs to this file")
+	root.PersistentFlags().String("from-file", "", "Show the tree in this --output=json file, or .yaml/.toml spec, instead of discovering one")
+	root.PersistentFlags().Bool("from-stdin", false, "Show the tree read from stdin, as written by --output=json, instead of discovering one")
+	root.PersistentFlags().Bool("no-color", false, "Disable color output (automatic when stdout is not a terminal)")
+	root.PersistentFlags().Bool("ascii", false, "Draw the tree with ASCII connectors and icons only")
+	root.PersistentFlags().Bool("accessible", false, "High-contrast colors, ASCII glyphs, and flag types as [bool]/[str] tags instead of colors")
 	root.PersistentFlags().Bool("no-cache", false, "Disable caching")
+	root.PersistentFlags().Bool("offline", false, "Serve trees from the cache only; never run the CLI")
 	root.PersistentFlags().Int("timeout", 30, "Discovery timeout in seconds")
+	root.PersistentFlags().Int("per-command-timeout", 0, "Seconds allowed to fetch one command's help (default 5)")
+	root.PersistentFlags().Int("retries", 0, "Retry a command's help this many times when it fails or times out")
+	root.PersistentFlags().Int("attempt-timeout", 0, "Seconds one help invocation may take before it is retried (default: no separate limit)")
 	root.PersistentFlags().Bool("debug", false, "Enable debug logging")
 
 	root.AddCommand(&cobra.Command{
@@ -98,6 +122,78 @@ func buildDocRoot() *cobra.Command {
 		Long:              cacheCmd.Long,
 		DisableAutoGenTag: true,
 	})
+	root.AddCommand(&cobra.Command{
+		Use:               mcpCmd.Use,
+		Short:             mcpCmd.Short,
+		Long:              mcpCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               schemaCmd.Use,
+		Short:             schemaCmd.Short,
+		Long:              schemaCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               statsCmd.Use,
+		Short:             statsCmd.Short,
+		Long:              statsCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               searchCmd.Use,
+		Short:             searchCmd.Short,
+		Long:              searchCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               genManCmd.Use,
+		Short:             genManCmd.Short,
+		Long:              genManCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               doctorCmd.Use,
+		Short:             doctorCmd.Short,
+		Long:              doctorCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               historyCmd.Use,
+		Short:             historyCmd.Short,
+		Long:              historyCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               diffCmd.Use,
+		Short:             diffCmd.Short,
+		Long:              diffCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               specCmd.Use,
+		Short:             specCmd.Short,
+		Long:              specCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               editCmd.Use,
+		Short:             editCmd.Short,
+		Long:              editCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               snippetsCmd.Use,
+		Short:             snippetsCmd.Short,
+		Long:              snippetsCmd.Long,
+		DisableAutoGenTag: true,
+	})
+	root.AddCommand(&cobra.Command{
+		Use:               runsCmd.Use,
+		Short:             runsCmd.Short,
+		Long:              runsCmd.Long,
+		DisableAutoGenTag: true,
+	})
 
 	return root
 }
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/render"
+)
+
+var (
+	genManDir     string
+	genManSection string
+)
+
+var genManCmd = &cobra.Command{
+	Use:   "gen-man <cli>",
+	Short: "Write roff man pages for a CLI's commands",
+	Long: `Write one roff man page per command of a CLI, built from the discovered
+descriptions, flags and positional arguments — handy for internal CLIs that
+never shipped man pages. Pages are named after the command path, e.g.
+git-remote-add.1, and link to their parent and subcommands.
+
+The tree comes from the cache when fresh, otherwise it is discovered with
+the persistent flags (--depth, --strategy, --no-cache, --timeout).
+--filter, --exclude and --depth limit which commands get a page.
+
+Examples:
+  treemand gen-man --dir=man mycli
+  treemand gen-man --depth=-1 --dir=/usr/local/share/man/man1 mycli
+  man ./man/mycli-deploy.1`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeCLIName,
+	RunE:              runGenMan,
+}
+
+func init() {
+	genManCmd.Flags().StringVar(&genManDir, "dir", "man", "Directory to write the man pages to")
+	genManCmd.Flags().StringVar(&genManSection, "section", "1", "Manual section of the pages")
+}
+
+func runGenMan(cmd *cobra.Command, args []string) error {
+	root, err := loadCLI(args[0])
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(genManDir, 0o755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+	cfg := resolveConfig()
+	r := render.New(render.Options{
+		MaxDepth: cfgDepth,
+		Filter:   cfgFilter,
+		Exclude:  cfgExclude,
+		Sort:     cfg.Sort,
+	})
+	header := render.ManHeader{
+		Section: genManSection,
+		Date:    time.Now(),
+		Source:  root.Name,
+		Manual:  root.Name + " manual",
+	}
+	nodes := r.Commands(root)
+	for _, node := range nodes {
+		if err := writeManPage(r, node, header); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Wrote %d man pages to %s\n", len(nodes), genManDir)
+	return nil
+}
+
+// writeManPage writes node's page into genManDir.
+func writeManPage(r *render.Renderer, node *models.Node, h render.ManHeader) error {
+	path := filepath.Join(genManDir, render.ManPageName(node, h.Section))
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create man page: %w", err)
+	}
+	if err := r.WriteManPage(f, node, h); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/cache"
+	"github.com/aallbrig/treemand/models"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <cli>",
+	Short: "List the versions of a CLI with a cached snapshot",
+	Long: `History lists the versions of a CLI whose command tree treemand has kept,
+newest first. Every discovery keeps a snapshot of the tree per CLI version;
+unlike cached trees, snapshots do not expire and are not evicted to keep
+the cache under its size limit, so upgrading a CLI leaves the tree of the
+old version behind for 'treemand diff'. 'treemand cache clear <cli>'
+removes them.
+
+--output=json prints the versions as a JSON array.
+
+Examples:
+  treemand history git
+  treemand history --output=json kubectl | jq -r '.[].version'`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeCLIName,
+	RunE:              runHistory,
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <cli> <old-version> [new-version]",
+	Short: "Compare the command trees of two versions of a CLI",
+	Long: `Diff compares the snapshots of two versions of a CLI (see 'treemand
+history') and prints the commands and flags added (+), removed (-) and
+changed (~) between them. Without new-version, the old version is compared
+with the newest snapshot.
+
+A version is the first line the CLI printed for --version, as 'treemand
+history' lists it; any unambiguous part of it will do, such as 2.39 for
+"git version 2.39.2". Only what both snapshots know is compared, so a
+shallow snapshot shows no changes below its depth limit. The CLI is never
+run. --output=json prints the changes as a JSON array.
+
+To explore the diff CLI itself rather than run this command, use
+'treemand -- diff'.
+
+Examples:
+  treemand diff git 2.39 2.43
+  treemand diff kubectl 1.28            # 1.28 against the newest snapshot
+  treemand diff --output=json gh 2.40 | jq -r '.[] | select(.kind == "removed") | .command'`,
+	Args:              cobra.RangeArgs(2, 3),
+	ValidArgsFunction: completeHistory,
+	RunE:              runDiff,
+}
+
+// historyEntry is one version in history --output=json.
+type historyEntry struct {
+	Version    string    `json:"version"`
+	CachedAt   time.Time `json:"cached_at"`
+	SizeBytes  int       `json:"size_bytes"`
+	BinaryPath string    `json:"binary_path,omitempty"`
+	BinaryHash string    `json:"binary_hash,omitempty"`
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	if err := checkOutputFormat(cfgOutput, "text", "json"); err != nil {
+		return err
+	}
+	c, history, err := openHistory(args[0])
+	if err != nil {
+		return err
+	}
+	c.Close()
+
+	w := cmd.OutOrStdout()
+	if cfgOutput == "json" {
+		entries := make([]historyEntry, len(history))
+		for i, e := range history {
+			entries[i] = historyEntry{e.Version, e.CachedAt, e.SizeBytes, e.Binary.Path, e.Binary.Hash}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tCACHED AT\tSIZE\tBINARY")
+	fmt.Fprintln(tw, "-------\t---------\t----\t------")
+	for _, e := range history {
+		bin := e.Binary.String()
+		if bin == "" {
+			bin = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Version, formatAge(time.Since(e.CachedAt)), formatBytes(e.SizeBytes), bin)
+	}
+	return tw.Flush()
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	if err := checkOutputFormat(cfgOutput, "text", "json"); err != nil {
+		return err
+	}
+	cli := args[0]
+	c, history, err := openHistory(cli)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	oldVer, err := matchVersion(cli, history, args[1])
+	if err != nil {
+		return err
+	}
+	newVer := history[0].Version
+	if len(args) == 3 {
+		if newVer, err = matchVersion(cli, history, args[2]); err != nil {
+			return err
+		}
+	}
+	if oldVer == newVer {
+		return fmt.Errorf("%q and %q are the same version of %s", args[1], args[len(args)-1], cli)
+	}
+	var trees [2]*models.Node
+	for i, ver := range []string{oldVer, newVer} {
+		if trees[i], err = c.Snapshot(cli, ver); err != nil {
+			return fmt.Errorf("read cache: %w", err)
+		} else if trees[i] == nil {
+			return fmt.Errorf("the snapshot of %s %q was removed", cli, ver)
+		}
+	}
+	changes := models.Diff(trees[0], trees[1])
+
+	w := cmd.OutOrStdout()
+	if cfgOutput == "json" {
+		if changes == nil {
+			changes = []models.Change{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "No differences between %s and %s.\n", oldVer, newVer)
+		return nil
+	}
+	counts := map[string]int{}
+	for _, ch := range changes {
+		counts[ch.Kind]++
+	}
+	fmt.Fprintf(w, "%s → %s: %d added, %d removed, %d changed\n", oldVer, newVer,
+		counts[models.ChangeAdded], counts[models.ChangeRemoved], counts[models.ChangeChanged])
+	for _, ch := range changes {
+		fmt.Fprintln(w, ch)
+	}
+	return nil
+}
+
+// openHistory opens the cache and returns the history of cli, failing
+// when it is empty. The caller closes the cache.
+func openHistory(cli string) (*cache.Cache, []cache.Entry, error) {
+	cfg := resolveConfig()
+	c, err := cache.OpenBackend(cfg.CacheDir, cfg.CacheBackend)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open cache: %w", err)
+	}
+	history, err := c.History(cli)
+	if err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("read cache: %w", err)
+	}
+	if len(history) == 0 {
+		c.Close()
+		return nil, nil, fmt.Errorf("no snapshots of %s; run 'treemand %s' to discover it", cli, cli)
+	}
+	return c, history, nil
+}
+
+// matchVersion returns the version in history that q names: the version
+// itself, or the only one containing q.
+func matchVersion(cli string, history []cache.Entry, q string) (string, error) {
+	var matches []string
+	for _, e := range history {
+		if e.Version == q {
+			return q, nil
+		}
+		if strings.Contains(e.Version, q) {
+			matches = append(matches, e.Version)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no snapshot of %s matches %q (see 'treemand history %s')", cli, q, cli)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("%q matches several versions of %s: %s", q, cli, strings.Join(matches, "; "))
+}
+
+// completeHistory completes the CLI name, then the versions in its history.
+func completeHistory(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeCLIName(cmd, args, toComplete)
+	}
+	if len(args) > 2 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	c, history, err := openHistory(args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	c.Close()
+	var versions []string
+	for _, e := range history {
+		if strings.HasPrefix(e.Version, toComplete) {
+			versions = append(versions, e.Version)
+		}
+	}
+	return versions, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"slices"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/config"
+	"github.com/aallbrig/treemand/render"
+	"github.com/aallbrig/treemand/tui"
+)
+
+// runLauncher shows the launcher, which lists the cached CLIs, and explores
+// the CLI picked in it in the TUI, from the cache or discovering it afresh
+// as runRoot would. treemand starts here when run without arguments.
+func runLauncher(cmd *cobra.Command) error {
+	cfg := resolveConfig()
+	cli, err := tui.RunLauncher(launcherEntries(cfg), cfg)
+	if err != nil || cli == "" {
+		return err
+	}
+	cfgInteractive = true
+	return runRoot(cmd, []string{cli})
+}
+
+// launcherEntries returns the CLIs in the cache, most recently used first,
+// with the counts of their newest trees. The trees are read without
+// marking them used, so the order of the next launch stays the same.
+func launcherEntries(cfg *config.Config) []tui.LauncherEntry {
+	c := openCache(cfg)
+	if c == nil {
+		return nil
+	}
+	defer c.Close()
+	cached, err := c.ListEntries()
+	if err != nil {
+		log.Warn().Err(err).Msg("could not list the cache")
+		return nil
+	}
+	var entries []tui.LauncherEntry
+	seen := map[string]int{} // CLI → index in entries
+	for _, e := range cached {
+		if i, ok := seen[e.CLI]; ok {
+			if e.UsedAt.After(entries[i].UsedAt) {
+				entries[i].UsedAt = e.UsedAt
+			}
+			continue
+		}
+		// The newest tree of each CLI is listed first.
+		entry := tui.LauncherEntry{CLI: e.CLI, Version: e.Version, UsedAt: e.UsedAt}
+		if root, err := c.Peek(e.Key); err == nil && root != nil {
+			s := render.Collect(root)
+			entry.Commands, entry.Flags = s.Commands, s.Flags
+		}
+		seen[e.CLI] = len(entries)
+		entries = append(entries, entry)
+	}
+	slices.SortStableFunc(entries, func(a, b tui.LauncherEntry) int {
+		return b.UsedAt.Compare(a.UsedAt)
+	})
+	return entries
+}
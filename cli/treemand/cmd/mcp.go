@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/config"
+	"github.com/aallbrig/treemand/mcp"
+	"github.com/aallbrig/treemand/models"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve CLI trees to AI assistants over MCP (stdio)",
+	Long: `Run a Model Context Protocol server on stdin/stdout so AI assistants can
+query discovered CLI hierarchies and build validated commands.
+
+Tools exposed:
+  discover_cli      command tree of a CLI as indented text
+  search_commands   find commands by name, description or flag
+  get_flags         flags and positionals of one command (JSON)
+  build_command     assemble a command line, validating flags and arguments
+
+Trees are read from and written to the normal discovery cache; the
+persistent flags (--depth, --strategy, --no-cache, --timeout) apply to every
+discovery the server runs.
+
+Example MCP client configuration:
+  {"mcpServers": {"treemand": {"command": "treemand", "args": ["mcp"]}}}`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging() // stdout carries the protocol; logs go to stderr
+
+		cfg := resolveConfig()
+		strategies := config.ParseStrategies(cfgStrategy)
+
+		cacheInst := openCache(cfg)
+		if cacheInst != nil {
+			defer cacheInst.Close()
+		}
+
+		load := func(ctx context.Context, cli string) (*models.Node, error) {
+			ctx, cancel := context.WithTimeout(ctx, time.Duration(cfgTimeout)*time.Second)
+			defer cancel()
+			res, err := loadTree(ctx, cacheInst, cli, nil, cfg, strategies, false, false, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", cli, err)
+			}
+			return res.Root, nil
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return mcp.NewServer(load, Version).Serve(ctx, cmd.InOrStdin(), cmd.OutOrStdout())
+	},
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/discovery"
+	"github.com/aallbrig/treemand/treemand"
+)
+
+var (
+	parseStdin bool
+	parseName  string
+)
+
+var parseCmd = &cobra.Command{
+	Use:   "parse [file]",
+	Short: "Parse captured help text and print the command it describes",
+	Long: `Parse reads help text captured from a CLI — from a file, or from stdin
+with --stdin — and prints what treemand makes of it: the command with its
+flags, positionals and subcommands, in any --output format. Nothing is run,
+so a parsing bug can be reported, and reproduced, with the help text alone.
+
+Subcommands are listed as undiscovered stubs, since their help was not
+captured. The command is named after the program in the usage line; --name
+names it explicitly, and keeps lines mentioning it from being read as
+subcommands, as discovery does.
+
+To explore a CLI called parse rather than run this command, use
+'treemand -- parse'.
+
+Examples:
+  mycli --help > mycli.txt && treemand parse --output=json mycli.txt
+  kubectl get --help | treemand parse --stdin --name=kubectl`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runParse,
+}
+
+func init() {
+	parseCmd.Flags().BoolVar(&parseStdin, "stdin", false, "Read the help text from stdin")
+	parseCmd.Flags().StringVar(&parseName, "name", "", "Name of the CLI the help text is from (default: the program in its usage line)")
+}
+
+func runParse(cmd *cobra.Command, args []string) error {
+	var text []byte
+	var err error
+	switch {
+	case parseStdin && len(args) == 0:
+		text, err = io.ReadAll(cmd.InOrStdin())
+	case !parseStdin && len(args) == 1:
+		text, err = os.ReadFile(args[0])
+	default:
+		return fmt.Errorf("give either a file to parse or --stdin")
+	}
+	if err != nil {
+		return fmt.Errorf("read help text: %w", err)
+	}
+	initLogging()
+	cfg := resolveConfig()
+	profile, err := discovery.LookupProfile(cfg.ParserProfile)
+	if err != nil {
+		return err
+	}
+	node := discovery.ParseHelpNode(string(text), parseName, profile)
+	return output(cmd, &treemand.Result{Root: node}, cfg, nil, nil)
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/cache"
+	"github.com/aallbrig/treemand/config"
+	"github.com/aallbrig/treemand/treemand"
+)
+
+var (
+	refreshAll      bool
+	refreshForce    bool
+	refreshDaemon   bool
+	refreshInterval time.Duration
+)
+
+var cacheRefreshCmd = &cobra.Command{
+	Use:   "refresh [--all | cli...]",
+	Short: "Re-discover cached CLIs whose binaries changed",
+	Long: `Refresh re-discovers the named CLIs, or with --all every cached CLI, when
+the binary on PATH changed since its tree was cached (it was upgraded,
+replaced or moved) or the cached tree has expired. Current trees are left
+alone unless --force is given. Named CLIs that are not cached yet are
+discovered, so refresh also warms the cache ahead of time.
+
+Re-discovery is incremental: subtrees whose help text is unchanged are
+reused from the previous tree. It uses the persistent flags (--depth,
+--strategy, --timeout, ...) like any other run, so pass the ones you use
+interactively to warm the entries those runs look up.
+
+With --daemon, refresh runs in the foreground every --interval until
+interrupted, re-reading the list of cached CLIs each time; start it from
+your login session, a systemd user unit or launchd agent to keep
+interactive launches served from a warm cache.
+
+Examples:
+  treemand cache refresh git kubectl      # refresh two CLIs if they changed
+  treemand cache refresh --all --force    # re-discover everything now
+  treemand cache refresh --all --daemon --interval=30m`,
+	ValidArgsFunction: completeCLIName,
+	RunE:              runCacheRefresh,
+}
+
+func runCacheRefresh(cmd *cobra.Command, args []string) error {
+	switch {
+	case refreshAll && len(args) > 0:
+		return fmt.Errorf("pass either --all or CLI names, not both")
+	case !refreshAll && len(args) == 0:
+		return fmt.Errorf("name the CLIs to refresh, or pass --all")
+	case refreshDaemon && refreshInterval <= 0:
+		return fmt.Errorf("--interval must be positive")
+	}
+	initLogging()
+	cfg := resolveConfig()
+	if cfg.NoCache {
+		return fmt.Errorf("cache refresh fills the cache, which is disabled (--no-cache)")
+	}
+	// Refreshing means running the CLIs, whatever offline says.
+	cfg.Offline = false
+	c, err := cache.OpenBackend(cfg.CacheDir, cfg.CacheBackend)
+	if err != nil {
+		return fmt.Errorf("open cache: %w", err)
+	}
+	defer c.Close()
+	c.SetMaxSize(cfg.CacheMaxSize)
+
+	w := cmd.OutOrStdout()
+	if !refreshDaemon {
+		return refreshCLIs(context.Background(), w, c, cfg, args, false)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Fprintf(w, "Refreshing every %s; press Ctrl-C to stop.\n", refreshInterval)
+	for {
+		if err := refreshCLIs(ctx, w, c, cfg, args, true); err != nil {
+			fmt.Fprintln(cmd.ErrOrStderr(), "Error:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(refreshInterval):
+		}
+	}
+}
+
+// refreshCLIs runs one refresh pass over clis, or over every cached CLI
+// when clis is empty, printing a line per CLI. quiet leaves out the CLIs
+// that were up to date, as the daemon does on every pass. A CLI that fails
+// to re-discover does not stop the others; the pass then returns an error.
+func refreshCLIs(ctx context.Context, w io.Writer, c *cache.Cache, cfg *config.Config, clis []string, quiet bool) error {
+	entries, err := c.ListEntries()
+	if err != nil {
+		return fmt.Errorf("list cache: %w", err)
+	}
+	// Entries are sorted newest first within each CLI.
+	cachedAt := make(map[string]time.Time)
+	for _, e := range entries {
+		if _, ok := cachedAt[e.CLI]; !ok {
+			cachedAt[e.CLI] = e.CachedAt
+		}
+	}
+	if len(clis) == 0 {
+		if clis, err = c.ListCLIs(); err != nil {
+			return fmt.Errorf("list cache: %w", err)
+		}
+	}
+	failed := 0
+	for _, cli := range clis {
+		if ctx.Err() != nil {
+			break
+		}
+		stamp := cache.BinaryStamp(cli)
+		if stamp == "" {
+			fmt.Fprintf(w, "%s: not found on PATH, skipped\n", cli)
+			continue
+		}
+		at, cached := cachedAt[cli]
+		reason, err := refreshReason(c, cli, stamp, at, cached)
+		if err != nil {
+			return fmt.Errorf("read cache: %w", err)
+		}
+		switch {
+		case reason == "" && !refreshForce:
+			if !quiet {
+				fmt.Fprintf(w, "%s: up to date\n", cli)
+			}
+			continue
+		case reason == "":
+			reason = "forced"
+		}
+		start := time.Now()
+		if err := rediscover(ctx, c, cli, cfg); err != nil {
+			failed++
+			fmt.Fprintf(w, "%s: %s, re-discovery failed: %v\n", cli, reason, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s, re-discovered in %s\n", cli, reason, time.Since(start).Round(time.Millisecond))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s of %d could not be refreshed", plural(failed, "CLI", "CLIs"), len(clis))
+	}
+	return nil
+}
+
+// refreshReason says why cli's cached tree should be re-discovered, or ""
+// when it is current. stamp is the BinaryStamp of cli now; cachedAt is when
+// its latest tree was cached, if cached.
+func refreshReason(c *cache.Cache, cli, stamp string, cachedAt time.Time, cached bool) (string, error) {
+	if !cached {
+		return "not cached", nil
+	}
+	old, err := c.Stamp(cli)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case old == "":
+		// Trees cached before binaries were recorded.
+		return "binary not recorded", nil
+	case old != stamp:
+		return "binary changed", nil
+	case time.Since(cachedAt) > treemand.DefaultCacheMaxAge:
+		return "cache expired", nil
+	}
+	return "", nil
+}
+
+// rediscover re-discovers cli incrementally into the cache, bounded by
+// --timeout.
+func rediscover(ctx context.Context, c *cache.Cache, cli string, cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfgTimeout)*time.Second)
+	defer cancel()
+	_, err := loadTree(ctx, c, cli, nil, cfg, config.ParseStrategies(cfgStrategy), true, false, nil, nil)
+	return err
+}
+
+func init() {
+	cacheRefreshCmd.Flags().BoolVar(&refreshAll, "all", false, "Refresh every cached CLI")
+	cacheRefreshCmd.Flags().BoolVar(&refreshForce, "force", false, "Re-discover even when the binary and cached tree are current")
+	cacheRefreshCmd.Flags().BoolVar(&refreshDaemon, "daemon", false, "Keep refreshing every --interval until interrupted")
+	cacheRefreshCmd.Flags().DurationVar(&refreshInterval, "interval", time.Hour, "Time between refreshes with --daemon")
+}
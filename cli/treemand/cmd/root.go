@@ -3,10 +3,19 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/charmbracelet/lipgloss"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -16,33 +25,61 @@ import (
 	"github.com/aallbrig/treemand/config"
 	"github.com/aallbrig/treemand/discovery"
 	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/override"
 	"github.com/aallbrig/treemand/render"
+	"github.com/aallbrig/treemand/treemand"
 	"github.com/aallbrig/treemand/tui"
 )
 
 var (
-	cfgFile          string
-	cfgInteractive   bool
-	cfgStrategy      string
-	cfgDepth         int
-	cfgFilter        string
-	cfgExclude       string
-	cfgCommandsOnly  bool
-	cfgFullPath      bool
-	cfgOutput        string
-	cfgNoColor       bool
-	cfgNoCache       bool
-	cfgTimeout       int
-	cfgDebug         bool
-	cfgIcons         string
-	cfgLineLength    int
-	cfgStubThreshold int
-	cfgTreeStyle     string
+	cfgFile           string
+	cfgInteractive    bool
+	cfgStrategy       string
+	cfgParserProfile  string
+	cfgMergePolicy    string
+	cfgVia            string
+	cfgDepth          int
+	cfgFilter         string
+	cfgExclude        string
+	cfgCommandsOnly   bool
+	cfgFullPath       bool
+	cfgPruneErrors    bool
+	cfgPruneDupes     bool
+	cfgMinConfidence  float64
+	cfgShowErrors     bool
+	cfgFlat           bool
+	cfgOutput         string
+	cfgTemplate       string
+	cfgFlagRows       bool
+	cfgNotes          bool
+	cfgNoColor        bool
+	cfgASCII          bool
+	cfgAccessible     bool
+	cfgNoCache        bool
+	cfgOffline        bool
+	cfgTimeout        int
+	cfgDebug          bool
+	cfgIcons          string
+	cfgLineLength     int
+	cfgStubThreshold  int
+	cfgCommandTimeout int
+	cfgRetries        int
+	cfgAttemptTimeout int
+	cfgTreeStyle      string
+	cfgSort           string
+	cfgIncremental    bool
+	cfgStats          bool
+	cfgTiming         bool
+	cfgTraceFile      string
+	cfgFromFile       string
+	cfgFromStdin      bool
+	cfgTabs           bool
+	cfgWait           bool
 )
 
 // rootCmd is the cobra root command.
 var rootCmd = &cobra.Command{
-	Use:   "treemand <cli>",
+	Use:   "treemand <cli> [subcommand...]",
 	Short: "Visualize CLI command hierarchies as a tree",
 	Long: `treemand discovers and visualizes any CLI tool as a command tree.
 
@@ -65,23 +102,40 @@ Interactive TUI controls (press ? inside TUI for full help):
 Discovery strategies (--strategy):
   help          parse --help output (default, works on nearly every CLI)
   completions   use shell completion data (richer flag metadata)
+  hidden        probe for commands --help leaves out (help -a, --help-all)
 
 Output formats (--output):
   text          colored tree (default)
   json          machine-readable full tree with flags and descriptions
   yaml          YAML output (same structure as JSON)
+  jsonl         one JSON object per command, written as discovery proceeds
+                (duplicates not collapsed, inherited flags not marked)
+  flat          one uncolored line per command: full path, positionals, flags
+  template      the tree rendered through the Go text/template in --template
+  csv, tsv      one row per command, or per flag with --flag-rows
+  org, rst      one Org-mode / reStructuredText section per command
 
 Examples:
   treemand git                        # full git tree
   treemand -i aws                     # interactive aws explorer
+  treemand -i --tabs git kubectl      # git and kubectl in tabs of one TUI
+  treemand                            # pick a recently used CLI to explore
   treemand --depth=2 kubectl          # kubectl tree, 2 levels deep
+  treemand git remote                 # only the git remote subtree
   treemand --commands-only docker     # subcommands only, no flags
   treemand --output=json gh | jq .    # pipe JSON to jq
+  treemand --output=flat git | fzf    # every command path, one per line
+  treemand --output=template --template=docs.tmpl git  # custom docs
+  treemand --output=csv --flag-rows kubectl > flags.csv  # flag audit sheet
   treemand --filter=remote git        # only show nodes matching "remote"
+  treemand --filter='^(add|rm)$' git  # regex, comma-separated alternatives
+  treemand --stats kubectl            # append command/flag counts and timing
+  treemand --incremental aws          # refresh, re-probing only changed subtrees
   treemand treemand                   # introspect treemand itself
+  treemand --from-file git.json -i    # explore a tree saved with --output=json
 
 Docs: https://aallbrig.github.io/treemand`,
-	Args:          cobra.ExactArgs(1),
+	Args:          rootArgs,
 	SilenceErrors: true,
 	SilenceUsage:  true,
 	RunE:          runRoot,
@@ -92,30 +146,69 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default: ~/.config/treemand/config.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&cfgInteractive, "interactive", "i", false, "Launch interactive TUI")
-	rootCmd.PersistentFlags().StringVarP(&cfgStrategy, "strategy", "s", "help", "Discovery strategies (comma-separated: help,completions)")
+	rootCmd.PersistentFlags().StringVarP(&cfgStrategy, "strategy", "s", "help", "Discovery strategies (comma-separated: help,completions,man,hidden)")
+	rootCmd.PersistentFlags().StringVar(&cfgParserProfile, "parser-profile", "auto", "Help parser profile: auto, "+strings.Join(discovery.ProfileNames(), ", "))
+	rootCmd.PersistentFlags().StringVar(&cfgMergePolicy, "merge-policy", "first", "Which value wins when strategies disagree: "+strings.Join(discovery.MergePolicies, ", "))
+	rootCmd.PersistentFlags().StringVar(&cfgVia, "via", "", `Run the CLI through a command prefix, such as "docker run image" or "wsl"`)
 	rootCmd.PersistentFlags().IntVar(&cfgDepth, "depth", 3, "Max tree depth (default 3; -1 = unlimited)")
-	rootCmd.PersistentFlags().StringVar(&cfgFilter, "filter", "", "Only show nodes matching pattern")
-	rootCmd.PersistentFlags().StringVar(&cfgExclude, "exclude", "", "Exclude nodes matching pattern")
+	rootCmd.PersistentFlags().StringVar(&cfgFilter, "filter", "", "Only show nodes matching pattern (comma-separated regexes; terms with a space match the full path)")
+	rootCmd.PersistentFlags().StringVar(&cfgExclude, "exclude", "", "Exclude nodes matching pattern (same syntax as --filter)")
 	rootCmd.PersistentFlags().BoolVar(&cfgCommandsOnly, "commands-only", false, "Hide flags and positionals")
 	rootCmd.PersistentFlags().BoolVar(&cfgFullPath, "full-path", false, "Show full command paths")
-	rootCmd.PersistentFlags().StringVar(&cfgOutput, "output", "text", "Output format: text, json, yaml")
-	rootCmd.PersistentFlags().BoolVar(&cfgNoColor, "no-color", false, "Disable color output")
+	rootCmd.PersistentFlags().BoolVar(&cfgPruneErrors, "prune-errors", false, "Drop commands whose help could not be fetched")
+	rootCmd.PersistentFlags().BoolVar(&cfgPruneDupes, "prune-duplicates", false, "Drop subcommands whose help is identical to their parent's or a sibling's")
+	rootCmd.PersistentFlags().Float64Var(&cfgMinConfidence, "min-confidence", 0, "Drop commands and flags parsed from help with a confidence score below this (0-1)")
+	rootCmd.PersistentFlags().BoolVar(&cfgShowErrors, "show-errors", false, "List commands whose help could not be fetched, with the error, on stderr")
+	rootCmd.PersistentFlags().StringVar(&cfgOutput, "output", "text", "Output format: text, json, yaml, jsonl, flat, template, csv, tsv, org, rst")
+	rootCmd.PersistentFlags().StringVar(&cfgTemplate, "template", "", "Go text/template file for --output=template")
+	rootCmd.PersistentFlags().BoolVar(&cfgFlagRows, "flag-rows", false, "With --output=csv or tsv, print one row per flag instead of per command")
+	rootCmd.PersistentFlags().BoolVar(&cfgNotes, "notes", false, "With --output=org, rst or template, include the notes written in the TUI (Ctrl+N)")
+	rootCmd.PersistentFlags().BoolVar(&cfgFlat, "flat", false, "Print one colored line per full command path instead of a tree")
+	rootCmd.PersistentFlags().BoolVar(&cfgNoColor, "no-color", false, "Disable color output (automatic when stdout is not a terminal)")
+	rootCmd.PersistentFlags().BoolVar(&cfgASCII, "ascii", false, "Draw the tree with ASCII connectors and icons only")
+	rootCmd.PersistentFlags().BoolVar(&cfgAccessible, "accessible", false, "High-contrast colors, ASCII glyphs, and flag types as [bool]/[str] tags instead of colors")
 	rootCmd.PersistentFlags().BoolVar(&cfgNoCache, "no-cache", false, "Disable caching")
+	rootCmd.PersistentFlags().BoolVar(&cfgOffline, "offline", false, "Serve trees from the cache only; never run the CLI")
 	rootCmd.PersistentFlags().IntVar(&cfgTimeout, "timeout", 30, "Discovery timeout in seconds")
+	rootCmd.PersistentFlags().IntVar(&cfgCommandTimeout, "per-command-timeout", 0, "Seconds allowed to fetch one command's help (default 5)")
 	rootCmd.PersistentFlags().BoolVar(&cfgDebug, "debug", false, "Enable debug logging")
 	rootCmd.PersistentFlags().StringVar(&cfgIcons, "icons", "", "Icon preset: unicode (default), ascii, nerd")
 	rootCmd.PersistentFlags().IntVar(&cfgLineLength, "line-length", 0, "Max description chars before truncation (default 80)")
 	rootCmd.PersistentFlags().IntVar(&cfgStubThreshold, "stub-threshold", 0, "Max eager children before creating stubs (default 150)")
+	rootCmd.PersistentFlags().IntVar(&cfgRetries, "retries", 0, "Retry a command's help this many times when it fails or times out")
+	rootCmd.PersistentFlags().IntVar(&cfgAttemptTimeout, "attempt-timeout", 0, "Seconds one help invocation may take before it is retried (default: no separate limit)")
 	rootCmd.PersistentFlags().StringVar(&cfgTreeStyle, "tree-style", "default", "TUI tree presentation style: default, columns, compact, graph")
+	rootCmd.PersistentFlags().StringVar(&cfgSort, "sort", "none", "Order of commands and flags: none, name, discovered, flags, used")
+	rootCmd.PersistentFlags().BoolVar(&cfgIncremental, "incremental", false, "Re-discover, reusing cached subtrees whose help text is unchanged")
+	rootCmd.PersistentFlags().BoolVar(&cfgStats, "stats", false, "Append a summary of command, flag and positional counts, discovery time and binary to text output")
+	rootCmd.PersistentFlags().BoolVar(&cfgTiming, "timing", false, "Print probe counts and the 10 slowest commands to discover on stderr")
+	rootCmd.PersistentFlags().StringVar(&cfgTraceFile, "trace-file", "", "Append a JSON Lines record of every command discovery runs to this file")
+	rootCmd.PersistentFlags().StringVar(&cfgFromFile, "from-file", "", "Show the tree in this --output=json file, or .yaml/.toml spec, instead of discovering one")
+	rootCmd.PersistentFlags().BoolVar(&cfgFromStdin, "from-stdin", false, "Show the tree read from stdin, as written by --output=json, instead of discovering one")
+	rootCmd.PersistentFlags().BoolVar(&cfgTabs, "tabs", false, "With -i, open each argument as a CLI in a tab of its own rather than as a subcommand path")
+	rootCmd.PersistentFlags().BoolVar(&cfgWait, "wait", false, "With -i, discover the whole tree before opening the TUI rather than its top level first and the rest in the background")
 
 	_ = viper.BindPFlag("icons", rootCmd.PersistentFlags().Lookup("icons"))
 	_ = viper.BindPFlag("desc_line_length", rootCmd.PersistentFlags().Lookup("line-length"))
 	_ = viper.BindPFlag("stub_threshold", rootCmd.PersistentFlags().Lookup("stub-threshold"))
+	_ = viper.BindPFlag("per_command_timeout", rootCmd.PersistentFlags().Lookup("per-command-timeout"))
+	_ = viper.BindPFlag("retries", rootCmd.PersistentFlags().Lookup("retries"))
+	_ = viper.BindPFlag("attempt_timeout", rootCmd.PersistentFlags().Lookup("attempt-timeout"))
 	_ = viper.BindPFlag("no_color", rootCmd.PersistentFlags().Lookup("no-color"))
+	_ = viper.BindPFlag("accessible", rootCmd.PersistentFlags().Lookup("accessible"))
 	_ = viper.BindPFlag("tree_style", rootCmd.PersistentFlags().Lookup("tree-style"))
+	_ = viper.BindPFlag("sort", rootCmd.PersistentFlags().Lookup("sort"))
+	_ = viper.BindPFlag("commands_only", rootCmd.PersistentFlags().Lookup("commands-only"))
+	_ = viper.BindPFlag("full_path", rootCmd.PersistentFlags().Lookup("full-path"))
+	_ = viper.BindPFlag("prune_errors", rootCmd.PersistentFlags().Lookup("prune-errors"))
+	_ = viper.BindPFlag("prune_duplicates", rootCmd.PersistentFlags().Lookup("prune-duplicates"))
 	_ = viper.BindPFlag("depth", rootCmd.PersistentFlags().Lookup("depth"))
 	_ = viper.BindPFlag("strategies", rootCmd.PersistentFlags().Lookup("strategy"))
+	_ = viper.BindPFlag("parser_profile", rootCmd.PersistentFlags().Lookup("parser-profile"))
+	_ = viper.BindPFlag("merge_policy", rootCmd.PersistentFlags().Lookup("merge-policy"))
+	_ = viper.BindPFlag("via", rootCmd.PersistentFlags().Lookup("via"))
 	_ = viper.BindPFlag("no_cache", rootCmd.PersistentFlags().Lookup("no-cache"))
+	_ = viper.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline"))
 }
 
 func initConfig() {
@@ -125,19 +218,301 @@ func initConfig() {
 }
 
 func runRoot(cmd *cobra.Command, args []string) error {
+	initLogging()
+
+	if !cfgInteractive && (cfgOutput == "template") != (cfgTemplate != "") {
+		return fmt.Errorf("--output=template and --template=FILE must be used together")
+	}
+	if cfgMinConfidence < 0 || cfgMinConfidence > 1 {
+		return fmt.Errorf("--min-confidence must be between 0 and 1, got %g", cfgMinConfidence)
+	}
+	if cfgFromFile != "" || cfgFromStdin {
+		return runFromTree(cmd, args)
+	}
+	if len(args) == 0 {
+		return runLauncher(cmd)
+	}
+	if cfgTabs {
+		return runTabs(args)
+	}
+	cliName := args[0]
+
+	cfg := resolveConfig()
+	// Fail early with a clear message if the binary cannot be found. Offline
+	// runs never use it, so it need not be installed, and runs via a prefix
+	// find it where the prefix runs it.
+	if !cfg.Offline && cfg.Via == "" {
+		if err := discovery.CheckAvailable(cliName); err != nil {
+			return fmt.Errorf("%w\nHint: check spelling and ensure the command is on your PATH", err)
+		}
+	}
+	strategies := config.ParseStrategies(cfgStrategy)
+
+	// Ctrl-C cancels discovery like its timeout: the commands it started
+	// are killed and what was found so far is kept. A second Ctrl-C exits
+	// at once.
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-sigCtx.Done()
+		stop()
+	}()
+	ctx, cancel := context.WithTimeout(sigCtx, time.Duration(cfgTimeout)*time.Second)
+	defer cancel()
+
+	cacheInst := openCache(cfg)
+	if cacheInst != nil {
+		defer cacheInst.Close()
+	}
+
+	// JSON Lines output is written while discovery runs. Other strategies
+	// merge into the tree afterwards, so only help-only discovery streams;
+	// cached and merged trees are written once loaded, as are pruned ones:
+	// a command is only scored once its parent's help is parsed, and
+	// failures and duplicates are pruned, or failures listed, once
+	// discovery ends. Filtered and
+	// sorted output needs the whole tree too, as does the CLI's override
+	// file, applied once it is loaded. Streamed nodes are written
+	// as parsed, before duplicates are collapsed and inherited flags
+	// marked.
+	var onNode func(*models.Node)
+	streamed := false
+	if cfgOutput == "jsonl" && !cfgInteractive && slices.Equal(strategies, []string{"help"}) && cfgMinConfidence == 0 &&
+		!cfg.PruneErrors && !cfg.PruneDuplicates && !cfgShowErrors && cfgFilter == "" && cfgExclude == "" && cfg.Sort == config.SortNone &&
+		!hasOverride(cfg, cliName) {
+		w := cmd.OutOrStdout()
+		onNode = func(n *models.Node) {
+			streamed = true
+			if err := render.WriteJSONLine(w, n); err != nil {
+				log.Warn().Err(err).Msg("could not write node")
+			}
+		}
+	}
+
+	start := time.Now()
+	res, err := loadTree(ctx, cacheInst, cliName, args[1:], cfg, strategies, cfgIncremental, openShallow(cfg, cliName, strategies, cfgIncremental), os.Stderr, onNode)
+	// The partial tree of an interrupted run is still printed, but not
+	// explored: the user asked to stop.
+	var interrupted error
+	if sigCtx.Err() != nil {
+		interrupted = fmt.Errorf("%w: the tree printed is incomplete", errInterrupted)
+		if err != nil || cfgInteractive {
+			return errInterrupted
+		}
+	}
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+	if cfgTiming && !cfgInteractive {
+		probed := probedNodes(res.Root)
+		defer writeTiming(cmd.ErrOrStderr(), res, probed, elapsed)
+	}
+	if streamed {
+		return interrupted
+	}
+	var failed []*models.Node
+	if cfg.PruneErrors {
+		failed = models.PruneErrors(res.Root)
+	} else if cfgShowErrors {
+		failed = models.ErrorNodes(res.Root)
+	}
+	if cfgMinConfidence > 0 {
+		models.PruneLowConfidence(res.Root, cfgMinConfidence)
+	}
+	if err := output(cmd, res, cfg, cacheInst, &origin{cli: cliName, path: args[1:]}); err != nil {
+		return err
+	}
+	if cfgStats && !cfgInteractive && (cfgOutput == "text" || cfgOutput == "flat") {
+		writeStats(cmd.OutOrStdout(), res, elapsed, cfg.NoColor || !isTerminal(cmd.OutOrStdout()))
+	}
+	if cfgShowErrors && !cfgInteractive {
+		writeErrors(cmd.ErrOrStderr(), failed)
+	}
+	return interrupted
+}
+
+// origin is the CLI, or the subcommand path below it, a tree was
+// discovered from, for the TUI to go back to it.
+type origin struct {
+	cli  string
+	path []string
+}
+
+// explorer returns the TUI model exploring the tree res loaded, its header
+// showing the executable the tree is from. c may be nil; when set, the
+// model keeps its view state, flag values, notes, snippets, runs,
+// environment variables and usage counts in it. from, which is nil for a
+// tree read from a file, lets Ctrl+R rediscover the tree, and the stubs
+// left in it be discovered in the background.
+func explorer(res *treemand.Result, cfg *config.Config, c *cache.Cache, from *origin) (*tui.Model, error) {
+	cli := cliOf(res.Root)
+	m := tui.NewModel(res.Root, cfg)
+	m.SetHelpStore(res.HelpStore)
+	if c != nil {
+		m.SetStateStore(c.StateStore(cli))
+		m.SetValueHistory(c.ValueHistory(cli))
+	}
+	// git's suggestions come from the local repository, so they are
+	// cheap enough to offer without value_completion.
+	if !cfg.Offline && (cfg.ValueCompletion || cli == "git") {
+		m.SetValueCompleter(discovery.NewValueCompleter(cli))
+	}
+	if c != nil {
+		m.SetNoteStore(c.NoteStore(cli))
+		m.SetSnippetStore(c.SnippetStore(cli))
+		m.SetRunLog(c.RunLog(cli))
+		m.SetEnvStore(c.EnvStore(cli))
+		m.SetUsageLog(c.UsageLog(cli))
+	}
+	if editing {
+		if err := m.SetOverrideStore(override.File{Dir: cfg.OverridesDir, CLI: cli}); err != nil {
+			return nil, err
+		}
+	}
+	if from != nil {
+		m.SetReloader(reloader(from.cli, from.path, cfg, c))
+		// Stubs down to --depth, left by a shallow load or a timeout, are
+		// discovered while the tree is explored; a shallow tree is cached
+		// once they are.
+		var done func()
+		if res.Shallow {
+			done = cacheWhole(from.cli, from.path, cfg, c)
+		}
+		m.DiscoverInBackground(cfg.Depth, done)
+	}
+	m.SetCLIInfo(cliInfo(res))
+	return m, nil
+}
+
+// cliInfo describes for the TUI's header the executable res's tree is from.
+func cliInfo(res *treemand.Result) tui.CLIInfo {
+	return tui.CLIInfo{Path: res.Binary.Path, Version: res.Version, Stale: res.Stale, Missing: res.Missing}
+}
+
+// reloader returns the tui.Reloader that rediscovers the tree of cliName,
+// or of the subcommand path names below it, from the CLI: online whatever
+// cfg says, within --timeout, and pruned as the first tree was. The tree
+// found replaces the cached one.
+func reloader(cliName string, path []string, cfg *config.Config, c *cache.Cache) tui.Reloader {
+	online := *cfg
+	online.Offline = false
+	strategies := config.ParseStrategies(cfgStrategy)
+	return func(ctx context.Context) (*models.Node, tui.CLIInfo, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(cfgTimeout)*time.Second)
+		defer cancel()
+		res, err := loadTree(ctx, c, cliName, path, &online, strategies, true, false, nil, nil)
+		if err != nil {
+			return nil, tui.CLIInfo{}, err
+		}
+		if online.PruneErrors {
+			models.PruneErrors(res.Root)
+		}
+		if cfgMinConfidence > 0 {
+			models.PruneLowConfidence(res.Root, cfgMinConfidence)
+		}
+		return res.Root, cliInfo(res), nil
+	}
+}
+
+// cacheWhole returns the function that caches the tree of cliName once the
+// TUI has discovered the rest of a shallow one in the background: the
+// whole tree is loaded again, from the help text the TUI fetched, and
+// cached as if it had been discovered at once. Subtrees are not cached as
+// trees, so there is nothing to do for one below the CLI, or without a
+// cache.
+func cacheWhole(cliName string, path []string, cfg *config.Config, c *cache.Cache) func() {
+	if c == nil || len(path) > 0 {
+		return nil
+	}
+	strategies := config.ParseStrategies(cfgStrategy)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfgTimeout)*time.Second)
+		defer cancel()
+		// Logged at debug level only: a warning would be drawn over the TUI.
+		if _, err := loadTree(ctx, c, cliName, nil, cfg, strategies, false, false, nil, nil); err != nil {
+			log.Debug().Err(err).Str("cli", cliName).Msg("could not cache the tree discovered in the background")
+		}
+	}
+}
+
+// cliOf returns the name of the CLI node belongs to. The root is a
+// subcommand when the tree starts below the CLI.
+func cliOf(node *models.Node) string {
+	if len(node.FullPath) > 0 {
+		return node.FullPath[0]
+	}
+	return node.Name
+}
+
+// explore runs the TUI on tabs, each with its config in cfgs, and saves a
+// pane split changed in one of them for the next session.
+func explore(tabs []*tui.Model, cfgs []*config.Config) error {
+	startRatio := cfgs[0].PaneRatio
+	tour := firstLaunch()
+	err := tui.RunTabs(tabs, tour)
+	for _, cfg := range cfgs {
+		if cfg.PaneRatio != startRatio {
+			savePaneRatio(cfg.PaneRatio)
+			break
+		}
+	}
+	if tour {
+		markLaunched()
+	}
+	return err
+}
+
+// errInterrupted is returned when Ctrl-C stopped discovery.
+var errInterrupted = errors.New("interrupted")
+
+// writeErrors lists the commands whose discovery failed, one per line with
+// the underlying error. It goes to stderr so it never mixes with the tree.
+func writeErrors(w io.Writer, failed []*models.Node) {
+	if len(failed) == 0 {
+		fmt.Fprintln(w, "All commands were discovered.")
+		return
+	}
+	fmt.Fprintf(w, "%s could not be discovered:\n", plural(len(failed), "command", "commands"))
+	for _, n := range failed {
+		fmt.Fprintf(w, "  %s: %s\n", n.FullCommand(), n.DiscoveryErr)
+	}
+}
+
+// writeStats appends the --stats footer: tree counts from render.Collect
+// and how long loading took.
+func writeStats(w io.Writer, res *treemand.Result, elapsed time.Duration, noColor bool) {
+	line := render.Collect(res.Root).String()
+	if res.Cached {
+		line += fmt.Sprintf(" · loaded from cache in %s", elapsed.Round(time.Millisecond))
+	} else {
+		line += fmt.Sprintf(" · discovered in %s", elapsed.Round(time.Millisecond))
+	}
+	if bin := res.Binary.String(); bin != "" {
+		line += " · " + bin
+	}
+	if pkg := res.Package.String(); pkg != "" {
+		line += " · " + pkg
+	}
+	if !noColor {
+		line = lipgloss.NewStyle().Faint(true).Render(line)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, line)
+}
+
+// initLogging sends log output to stderr at the level chosen by --debug.
+func initLogging() {
 	logLevel := zerolog.WarnLevel
 	if cfgDebug {
 		logLevel = zerolog.DebugLevel
 	}
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr}).Level(logLevel)
+}
 
-	cliName := args[0]
-
-	// Fail early with a clear message if the binary cannot be found.
-	if err := discovery.CheckAvailable(cliName); err != nil {
-		return fmt.Errorf("%w\nHint: check spelling and ensure the command is on your PATH", err)
-	}
-
+// resolveConfig builds the effective configuration from defaults, the
+// config file and the persistent command-line flags.
+func resolveConfig() *config.Config {
 	cfg := config.DefaultConfig()
 	cfg.NoColor = cfgNoColor || cfg.NoColor
 	cfg.Depth = cfgDepth
@@ -155,83 +530,245 @@ func runRoot(cmd *cobra.Command, args []string) error {
 	if cfgStubThreshold > 0 {
 		cfg.StubThreshold = cfgStubThreshold
 	}
+	if cfgCommandTimeout > 0 {
+		cfg.CommandTimeout = time.Duration(cfgCommandTimeout) * time.Second
+	}
+	if cfgRetries > 0 {
+		cfg.Retries = cfgRetries
+	}
+	if cfgAttemptTimeout > 0 {
+		cfg.AttemptTimeout = time.Duration(cfgAttemptTimeout) * time.Second
+	}
 	if cfgTreeStyle != "" && cfgTreeStyle != "default" {
 		cfg.TreeStyle = config.ParseTreeStyle(cfgTreeStyle)
 	}
-	strategies := config.ParseStrategies(cfgStrategy)
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfgTimeout)*time.Second)
-	defer cancel()
-
-	// Attempt cache lookup
-	var (
-		cacheInst *cache.Cache
-		cacheKey  string
-	)
-	if !cfg.NoCache {
-		var err error
-		cacheInst, err = cache.Open(cfg.CacheDir)
-		if err != nil {
-			log.Warn().Err(err).Msg("could not open cache, running without")
-		} else {
-			defer cacheInst.Close()
-			ver := cache.CLIVersion(cliName)
-			cacheKey = cache.Key(cliName, ver, strategies)
-			if node, err := cacheInst.Get(cacheKey, 24*time.Hour); err == nil && node != nil {
-				log.Debug().Str("cli", cliName).Msg("cache hit")
-				return output(cmd, node, cfg)
-			}
-		}
+	if cfgSort != "" && cfgSort != "none" {
+		cfg.Sort = config.ParseSortMode(cfgSort)
+	}
+	if cfgParserProfile != "" && cfgParserProfile != "auto" {
+		cfg.ParserProfile = cfgParserProfile
+	}
+	if cfgMergePolicy != "" && cfgMergePolicy != "first" {
+		cfg.MergePolicy = cfgMergePolicy
 	}
+	if cfgVia != "" {
+		cfg.Via = cfgVia
+	}
+	if cfgCommandsOnly {
+		cfg.CommandsOnly = true
+	}
+	if cfgFullPath {
+		cfg.FullPath = true
+	}
+	if cfgPruneErrors {
+		cfg.PruneErrors = true
+	}
+	if cfgPruneDupes {
+		cfg.PruneDuplicates = true
+	}
+	if cfgOffline {
+		cfg.Offline = true
+	}
+	if cfgAccessible && !cfg.Accessible {
+		cfg.SetAccessible()
+	}
+	return cfg
+}
 
-	// Discover tree
-	maxDepth := cfg.Depth
-	if maxDepth < 0 {
-		maxDepth = 99 // -1 means unlimited; cap at 99 to prevent infinite loops
+// openCache opens the discovery cache unless it is disabled. A cache that
+// cannot be opened is logged and treated as disabled (nil).
+func openCache(cfg *config.Config) *cache.Cache {
+	if cfg.NoCache {
+		return nil
 	}
-	discoverers := discovery.BuildDiscoverersWithThreshold(strategies, maxDepth, cfg.StubThreshold)
-	spin := NewSpinner(os.Stderr)
-	spin.Start("discovering " + cliName + "…")
-	node, err := discovery.Run(ctx, discoverers, cliName)
-	spin.Stop()
+	c, err := cache.OpenBackend(cfg.CacheDir, cfg.CacheBackend)
 	if err != nil {
-		return fmt.Errorf("discovery failed: %w", err)
-	}
-	if node == nil {
-		return fmt.Errorf("no results from discovery for %q", cliName)
+		log.Warn().Err(err).Msg("could not open cache, running without")
+		return nil
 	}
+	c.SetMaxSize(cfg.CacheMaxSize)
+	return c
+}
 
-	// Persist to cache
-	if cacheInst != nil && cacheKey != "" {
-		ver := cache.CLIVersion(cliName)
-		if putErr := cacheInst.Put(cacheKey, cliName, ver, cfgStrategy, node); putErr != nil {
-			log.Warn().Err(putErr).Msg("cache write failed")
+// loadTree loads the tree for cliName via treemand.Load, or the subtree of
+// the subcommand path names below it. c may be nil to bypass the cache.
+// shallow discovers a tree that is not cached only one level deep (see
+// openShallow). When progress is non-nil a spinner is drawn on it while
+// live discovery runs, counting the commands found as strategies finish;
+// onNode, when non-nil, receives each discovered node.
+func loadTree(ctx context.Context, c *cache.Cache, cliName string, path []string, cfg *config.Config, strategies []string, incremental, shallow bool, progress io.Writer, onNode func(*models.Node)) (*treemand.Result, error) {
+	opts := treemand.Options{
+		Strategies:     strategies,
+		Path:           path,
+		Depth:          cfg.Depth,
+		StubThreshold:  cfg.StubThreshold,
+		CommandTimeout: cfg.CommandTimeout,
+		Retry: discovery.RetryPolicy{
+			Retries:        cfg.Retries,
+			Backoff:        cfg.RetryBackoff,
+			AttemptTimeout: cfg.AttemptTimeout,
+		},
+		ParserProfile:   cfg.ParserProfile,
+		MergePolicy:     cfg.MergePolicy,
+		Via:             discovery.ParseVia(cfg.Via),
+		PackageMetadata: cfg.PackageMetadata,
+		OverrideDir:     cfg.OverridesDir,
+		PruneDuplicates: cfg.PruneDuplicates,
+		Cache:           c,
+		Incremental:     incremental,
+		Offline:         cfg.Offline,
+		OnNode:          onNode,
+	}
+	if shallow {
+		opts.Shallow = 1
+	}
+	if progress != nil {
+		var spin *Spinner
+		var label string
+		opts.OnDiscover = func(cli string) func() {
+			label = "discovering " + cli + "…"
+			spin = NewSpinner(progress)
+			spin.Start(label)
+			return spin.Stop
 		}
+		// With several strategies, count the commands found as each one's
+		// tree is merged in.
+		opts.OnPartial = func(partial *models.Node) {
+			n := -1 // not the root
+			partial.Walk(func(node *models.Node) {
+				if !node.Virtual {
+					n++
+				}
+			})
+			spin.SetLabel(fmt.Sprintf("%s %d commands so far", label, n))
+		}
+	}
+	if cfg.Offline && c == nil {
+		return nil, fmt.Errorf("--offline serves trees from the cache, which is disabled (--no-cache)")
 	}
+	if cfgTraceFile != "" {
+		f, err := os.OpenFile(cfgTraceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open trace file: %w", err)
+		}
+		defer f.Close()
+		opts.Trace = f
+	}
+	res, err := treemand.Load(ctx, cliName, opts)
+	if errors.Is(err, treemand.ErrNotCached) {
+		return nil, fmt.Errorf("%w\nHint: run 'treemand %s' once without --offline to cache it", err, cliName)
+	}
+	return res, err
+}
+
+// openShallow reports whether the TUI opens on the top level of a tree that
+// is not cached, discovering the rest in the background (see
+// tui.Model.DiscoverInBackground), rather than once it is discovered
+// whole. Only the help strategy discovers in the background, and
+// incremental runs go over the whole tree anyway. A tree pruned of
+// failures or duplicates, or corrected by an override file, is not opened
+// shallow, as the tree the TUI fills in is pruned and corrected only at
+// the top.
+func openShallow(cfg *config.Config, cli string, strategies []string, incremental bool) bool {
+	return cfgInteractive && !cfgWait && !cfg.Offline && !incremental && slices.Equal(strategies, []string{"help"}) &&
+		!cfg.PruneErrors && !cfg.PruneDuplicates && cfgMinConfidence == 0 && !hasOverride(cfg, cli)
+}
 
-	return output(cmd, node, cfg)
+// hasOverride reports whether cli has an override file.
+func hasOverride(cfg *config.Config, cli string) bool {
+	if cfg.OverridesDir == "" {
+		return false
+	}
+	_, err := os.Stat(override.Path(cfg.OverridesDir, cli))
+	return err == nil
 }
 
-func output(cmd *cobra.Command, node *models.Node, cfg *config.Config) error {
+// output shows the tree res loaded: in the TUI with -i, rendered to stdout
+// otherwise. c may be nil; when set, the TUI keeps its state in it (see
+// explorer), and --notes reads notes from it. from is the CLI the tree was
+// discovered from, nil for one read from a file.
+func output(cmd *cobra.Command, res *treemand.Result, cfg *config.Config, c *cache.Cache, from *origin) error {
+	node := res.Root
 	if cfgInteractive {
-		return tui.Run(node, cfg)
+		m, err := explorer(res, cfg, c, from)
+		if err != nil {
+			return err
+		}
+		return explore([]*tui.Model{m}, []*config.Config{cfg})
 	}
+	// Piped output gets neither colors nor box-drawing connectors, so it
+	// stays readable in files, pagers and other tools.
+	tty := isTerminal(cmd.OutOrStdout())
 	opts := render.Options{
 		MaxDepth:       cfgDepth,
 		Filter:         cfgFilter,
 		Exclude:        cfgExclude,
-		CommandsOnly:   cfgCommandsOnly,
-		FullPath:       cfgFullPath,
+		CommandsOnly:   cfg.CommandsOnly,
+		FullPath:       cfg.FullPath,
+		Flat:           cfgFlat,
 		Output:         cfgOutput,
-		NoColor:        cfg.NoColor,
+		FlagRows:       cfgFlagRows,
+		NoColor:        cfg.NoColor || !tty,
+		ASCII:          cfgASCII || cfg.Accessible || !tty,
+		Accessible:     cfg.Accessible,
 		Colors:         cfg.Colors,
 		Icons:          cfg.Icons,
 		DescLineLength: cfg.DescLineLength,
+		Sort:           cfg.Sort,
+	}
+	if cfgTemplate != "" {
+		src, err := os.ReadFile(cfgTemplate)
+		if err != nil {
+			return fmt.Errorf("read template: %w", err)
+		}
+		opts.Template = string(src)
+	}
+	if cfgASCII && cfgIcons == "" {
+		opts.Icons = config.IconSetForPreset(config.IconPresetASCII)
+	}
+	if cfgNotes && c != nil {
+		notes, err := c.Notes(cliOf(node))
+		if err != nil {
+			return fmt.Errorf("read notes: %w", err)
+		}
+		opts.Notes = notes
 	}
 	r := render.New(opts)
 	return r.Render(cmd.OutOrStdout(), node)
 }
 
+// savePaneRatio persists a pane split changed in the TUI. Failing to save
+// is not worth failing the command over, so errors are only logged.
+func savePaneRatio(ratio int) {
+	path := resolveConfigPath()
+	if path == "" {
+		path = config.DefaultConfigPath()
+	}
+	if err := config.SaveKey(path, "pane_ratio", strconv.Itoa(ratio)); err != nil {
+		log.Warn().Err(err).Msg("could not save pane ratio")
+	}
+}
+
+// firstLaunch reports whether the TUI has never been launched, so its
+// guided tour should open.
+func firstLaunch() bool {
+	_, err := os.Stat(config.TourMarkerPath())
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// markLaunched records that the TUI has been launched, so the guided tour
+// does not open again. Like savePaneRatio, it only logs errors.
+func markLaunched() {
+	path := config.TourMarkerPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Warn().Err(err).Msg("could not record the first launch")
+		return
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		log.Warn().Err(err).Msg("could not record the first launch")
+	}
+}
+
 // Execute runs the root command.
 func Execute() {
 	// Inject treemand's own version into the cache key so upgrades
@@ -246,6 +783,19 @@ func Execute() {
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(genDocsCmd)
 	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(mcpCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(genManCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(parseCmd)
+	rootCmd.AddCommand(specCmd)
+	rootCmd.AddCommand(editCmd)
+	rootCmd.AddCommand(snippetsCmd)
+	rootCmd.AddCommand(runsCmd)
 	rootCmd.ValidArgsFunction = completeCLIName
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, "Error:", err)
@@ -259,7 +809,7 @@ func NewRootCmd() *cobra.Command {
 		Use:           rootCmd.Use,
 		Short:         rootCmd.Short,
 		Long:          rootCmd.Long,
-		Args:          cobra.ExactArgs(1),
+		Args:          rootArgs,
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		RunE:          runRoot,
@@ -267,24 +817,63 @@ func NewRootCmd() *cobra.Command {
 	c.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file")
 	c.PersistentFlags().BoolVarP(&cfgInteractive, "interactive", "i", false, "Launch interactive TUI")
 	c.PersistentFlags().StringVarP(&cfgStrategy, "strategy", "s", "help", "Discovery strategies")
+	c.PersistentFlags().StringVar(&cfgParserProfile, "parser-profile", "auto", "Help parser profile")
+	c.PersistentFlags().StringVar(&cfgMergePolicy, "merge-policy", "first", "Merge policy")
+	c.PersistentFlags().StringVar(&cfgVia, "via", "", "Command prefix")
 	c.PersistentFlags().IntVar(&cfgDepth, "depth", 3, "Max tree depth (default 3; -1 = unlimited)")
 	c.PersistentFlags().StringVar(&cfgFilter, "filter", "", "Filter pattern")
 	c.PersistentFlags().StringVar(&cfgExclude, "exclude", "", "Exclude pattern")
 	c.PersistentFlags().BoolVar(&cfgCommandsOnly, "commands-only", false, "Hide flags/positionals")
 	c.PersistentFlags().BoolVar(&cfgFullPath, "full-path", false, "Full command paths")
+	c.PersistentFlags().BoolVar(&cfgPruneErrors, "prune-errors", false, "Drop failed commands")
+	c.PersistentFlags().BoolVar(&cfgPruneDupes, "prune-duplicates", false, "Drop duplicate commands")
+	c.PersistentFlags().Float64Var(&cfgMinConfidence, "min-confidence", 0, "Minimum parse confidence")
+	c.PersistentFlags().BoolVar(&cfgShowErrors, "show-errors", false, "List failed commands")
 	c.PersistentFlags().StringVar(&cfgOutput, "output", "text", "Output format")
+	c.PersistentFlags().StringVar(&cfgTemplate, "template", "", "Template file")
+	c.PersistentFlags().BoolVar(&cfgFlagRows, "flag-rows", false, "One CSV row per flag")
+	c.PersistentFlags().BoolVar(&cfgNotes, "notes", false, "Include notes")
+	c.PersistentFlags().BoolVar(&cfgFlat, "flat", false, "Flat text output")
 	c.PersistentFlags().BoolVar(&cfgNoColor, "no-color", false, "Disable color")
+	c.PersistentFlags().BoolVar(&cfgASCII, "ascii", false, "ASCII connectors and icons")
+	c.PersistentFlags().BoolVar(&cfgAccessible, "accessible", false, "Accessibility mode")
 	c.PersistentFlags().BoolVar(&cfgNoCache, "no-cache", false, "Disable cache")
+	c.PersistentFlags().BoolVar(&cfgOffline, "offline", false, "Cache only")
 	c.PersistentFlags().IntVar(&cfgTimeout, "timeout", 5, "Discovery timeout")
+	c.PersistentFlags().IntVar(&cfgCommandTimeout, "per-command-timeout", 0, "Per-command timeout")
 	c.PersistentFlags().BoolVar(&cfgDebug, "debug", false, "Debug logging")
 	c.PersistentFlags().StringVar(&cfgIcons, "icons", "", "Icon preset")
 	c.PersistentFlags().IntVar(&cfgLineLength, "line-length", 0, "Max description line length")
 	c.PersistentFlags().IntVar(&cfgStubThreshold, "stub-threshold", 0, "Stub threshold")
+	c.PersistentFlags().IntVar(&cfgRetries, "retries", 0, "Help retries")
+	c.PersistentFlags().IntVar(&cfgAttemptTimeout, "attempt-timeout", 0, "Per-attempt timeout")
+	c.PersistentFlags().BoolVar(&cfgIncremental, "incremental", false, "Incremental re-discovery")
+	c.PersistentFlags().StringVar(&cfgSort, "sort", "none", "Sort order")
+	c.PersistentFlags().BoolVar(&cfgStats, "stats", false, "Append summary")
+	c.PersistentFlags().BoolVar(&cfgTiming, "timing", false, "Print discovery timing")
+	c.PersistentFlags().StringVar(&cfgTraceFile, "trace-file", "", "Discovery trace file")
+	c.PersistentFlags().StringVar(&cfgFromFile, "from-file", "", "Tree file")
+	c.PersistentFlags().BoolVar(&cfgFromStdin, "from-stdin", false, "Read the tree from stdin")
+	c.PersistentFlags().BoolVar(&cfgTabs, "tabs", false, "Open each argument as a CLI in a tab")
+	c.PersistentFlags().BoolVar(&cfgWait, "wait", false, "Discover the whole tree before opening the TUI")
 	c.AddCommand(versionCmd)
 	c.AddCommand(cacheCmd)
 	c.AddCommand(configCmd)
 	c.AddCommand(genDocsCmd)
 	c.AddCommand(completionCmd)
+	c.AddCommand(schemaCmd)
+	c.AddCommand(mcpCmd)
+	c.AddCommand(statsCmd)
+	c.AddCommand(searchCmd)
+	c.AddCommand(genManCmd)
+	c.AddCommand(doctorCmd)
+	c.AddCommand(historyCmd)
+	c.AddCommand(diffCmd)
+	c.AddCommand(parseCmd)
+	c.AddCommand(specCmd)
+	c.AddCommand(editCmd)
+	c.AddCommand(snippetsCmd)
+	c.AddCommand(runsCmd)
 	c.ValidArgsFunction = completeCLIName
 	return c
 }
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/cache"
+	"github.com/aallbrig/treemand/models"
+)
+
+var runsCmd = &cobra.Command{
+	Use:   "runs <cli> [n]",
+	Short: "List the commands run from the TUI, with their output",
+	Long: `Runs lists the commands of a CLI run from the interactive explorer with
+Ctrl+E, newest first: when each started, its exit code and how long it took.
+With n, it prints the output of the nth run (1 = the newest): what it wrote
+to stdout on stdout, and what it wrote to stderr on stderr.
+
+Runs are recorded only with record_runs: true in the config file, which
+keeps the last 64 KiB of each stream and the last 50 runs of each CLI.
+':runs' in the TUI shows the same list. 'treemand cache clear' deletes
+them. --output=json prints them as a JSON array, or the nth as an object.
+
+To explore a CLI called runs rather than run this command, use
+'treemand -- runs'.
+
+Examples:
+  treemand runs kubectl
+  treemand runs kubectl 1
+  treemand runs kubectl 2 2>/dev/null`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeCLIName,
+	RunE:              runRuns,
+}
+
+func runRuns(cmd *cobra.Command, args []string) error {
+	if err := checkOutputFormat(cfgOutput, "text", "json"); err != nil {
+		return err
+	}
+	cli := args[0]
+	cfg := resolveConfig()
+	c, err := cache.OpenBackend(cfg.CacheDir, cfg.CacheBackend)
+	if err != nil {
+		return fmt.Errorf("open cache: %w", err)
+	}
+	defer c.Close()
+	runs, err := c.Runs(cli)
+	if err != nil {
+		return fmt.Errorf("read cache: %w", err)
+	}
+
+	w := cmd.OutOrStdout()
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("run number %q is not a positive number", args[1])
+		}
+		if n > len(runs) {
+			return fmt.Errorf("%s has %d recorded runs, not %d (see 'treemand runs %s')", cli, len(runs), n, cli)
+		}
+		run := runs[n-1]
+		if cfgOutput == "json" {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(run)
+		}
+		io.WriteString(w, run.Stdout)
+		io.WriteString(cmd.ErrOrStderr(), run.Stderr)
+		return nil
+	}
+
+	if cfgOutput == "json" {
+		if runs == nil {
+			runs = []models.Run{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(runs)
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(w, "No runs of %s recorded; set record_runs: true in the config file and run commands with Ctrl+E in 'treemand -i %s'.\n", cli, cli)
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tSTARTED\tEXIT\tDURATION\tCOMMAND")
+	fmt.Fprintln(tw, "-\t-------\t----\t--------\t-------")
+	for i, run := range runs {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n", i+1, formatAge(time.Since(run.StartedAt)), run.ExitCode, run.Duration.Round(time.Millisecond), run.Command)
+	}
+	return tw.Flush()
+}
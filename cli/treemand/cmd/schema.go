@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema for --output=json",
+	Long: `Print the JSON Schema (draft 2020-12) describing the tree emitted by
+--output=json. The root node of every exported tree carries a schema_version
+field matching the "const" in this schema.
+
+Examples:
+  treemand schema > treemand-tree.schema.json
+  treemand --output=json git > git.json   # validate git.json against the schema`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := cmd.OutOrStdout().Write(models.JSONSchema)
+		return err
+	},
+}
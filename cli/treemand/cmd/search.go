@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <cli> <term>",
+	Short: "Find commands by name, description or flag",
+	Long: `Search a CLI's command tree for a term and print the full path of every
+command whose name, description or own flag names contain it
+(case-insensitive), with the matching fields in brackets.
+
+A term of the form -x also matches flags by their short name; put -- before
+terms that start with a dash. The search exits non-zero when nothing
+matches, so it can be used in scripts. --output=json prints the matches as a
+JSON array.
+
+Examples:
+  treemand search aws -- --capabilities
+  treemand search git rebase
+  treemand search --output=json kubectl namespace | jq -r '.[].command'`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeCLIName,
+	RunE:              runSearch,
+}
+
+// searchResult is one match in --output=json.
+type searchResult struct {
+	Command     string   `json:"command"`
+	Description string   `json:"description,omitempty"`
+	Matched     []string `json:"matched"`
+}
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	if err := checkOutputFormat(cfgOutput, "text", "json"); err != nil {
+		return err
+	}
+	root, err := loadCLI(args[0])
+	if err != nil {
+		return err
+	}
+	matches := models.Search(root, args[1], 0)
+	if len(matches) == 0 {
+		return fmt.Errorf("no %s commands match %q", root.Name, args[1])
+	}
+	w := cmd.OutOrStdout()
+	if cfgOutput == "json" {
+		results := make([]searchResult, len(matches))
+		for i, m := range matches {
+			results[i] = searchResult{Command: m.Node.FullCommand(), Description: m.Node.Description, Matched: m.Fields}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	for _, m := range matches {
+		fmt.Fprintln(w, m)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/cache"
+)
+
+var snippetsDelete bool
+
+var snippetsCmd = &cobra.Command{
+	Use:   "snippets <cli> [name]",
+	Short: "List the commands saved as snippets in the TUI",
+	Long: `Snippets lists the commands of a CLI saved in the interactive explorer with
+':save NAME'. With a name, it prints that snippet's command alone, ready for
+the shell; --delete deletes it instead.
+
+A snippet may hold placeholders such as {{namespace}}. Loading it in the
+TUI (':snippets', then Enter) prompts for each one; here they are printed
+as they are.
+
+Snippets are kept in the cache, but 'treemand cache clear' leaves them.
+--output=json prints them as a JSON array.
+
+To explore a CLI called snippets rather than run this command, use
+'treemand -- snippets'.
+
+Examples:
+  treemand snippets kubectl
+  treemand snippets kubectl pods
+  treemand snippets --delete kubectl pods`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeCLIName,
+	RunE:              runSnippets,
+}
+
+func init() {
+	snippetsCmd.Flags().BoolVar(&snippetsDelete, "delete", false, "Delete the named snippet")
+}
+
+// snippetEntry is one snippet in snippets --output=json.
+type snippetEntry struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+}
+
+func runSnippets(cmd *cobra.Command, args []string) error {
+	if err := checkOutputFormat(cfgOutput, "text", "json"); err != nil {
+		return err
+	}
+	cli := args[0]
+	if snippetsDelete && len(args) < 2 {
+		return fmt.Errorf("--delete needs the name of the snippet to delete")
+	}
+	cfg := resolveConfig()
+	c, err := cache.OpenBackend(cfg.CacheDir, cfg.CacheBackend)
+	if err != nil {
+		return fmt.Errorf("open cache: %w", err)
+	}
+	defer c.Close()
+	snippets, err := c.Snippets(cli)
+	if err != nil {
+		return fmt.Errorf("read cache: %w", err)
+	}
+
+	w := cmd.OutOrStdout()
+	if len(args) == 2 {
+		name := args[1]
+		command, ok := snippets[name]
+		if !ok {
+			return fmt.Errorf("%s has no snippet %q (see 'treemand snippets %s')", cli, name, cli)
+		}
+		if snippetsDelete {
+			if err := c.DeleteSnippet(cli, name); err != nil {
+				return fmt.Errorf("delete snippet: %w", err)
+			}
+			fmt.Fprintf(w, "Deleted snippet %s of %s.\n", name, cli)
+			return nil
+		}
+		fmt.Fprintln(w, command)
+		return nil
+	}
+
+	names := slices.Sorted(maps.Keys(snippets))
+	if cfgOutput == "json" {
+		entries := make([]snippetEntry, len(names))
+		for i, name := range names {
+			entries[i] = snippetEntry{name, snippets[name]}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(w, "No snippets of %s; save one in 'treemand -i %s' with :save NAME.\n", cli, cli)
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tCOMMAND")
+	fmt.Fprintln(tw, "----\t-------")
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%s\n", name, snippets[name])
+	}
+	return tw.Flush()
+}
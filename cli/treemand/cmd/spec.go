@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/spec"
+)
+
+var specCmd = &cobra.Command{
+	Use:   "spec",
+	Short: "Check and show hand-written CLI specs",
+	Long: `A spec describes a CLI by hand, in YAML or TOML: its commands, flags and
+arguments. It documents CLIs whose help treemand cannot parse, or that have
+none, and is shown like a discovered tree without running anything.
+
+  name: deployctl
+  description: Deploy services to the fleet
+  flags:
+    - {name: --verbose, short: v, description: Log every step}
+  commands:
+    - name: deploy
+      description: Deploy one service
+      args:
+        - {name: service, required: true}
+      flags:
+        - {name: --env, type: string, default: staging}
+
+In TOML, subcommands are [[commands]] tables, flags [[flags]] and arguments
+[[args]]. A command also takes deprecated, replaced_by and hidden; a flag
+takes short, type, description, default, required, repeatable, negatable,
+deprecated and replaced_by; an argument takes description, required and
+variadic. Unknown keys are errors.
+
+Examples:
+  treemand spec validate deployctl.yaml
+  treemand spec show deployctl.yaml -i
+  treemand spec show deployctl.toml deployctl deploy`,
+}
+
+var specValidateCmd = &cobra.Command{
+	Use:   "validate <file>",
+	Short: "Check a spec for errors",
+	Long: `Check a spec for syntax errors, unknown keys, missing or malformed names,
+commands, flags and arguments listed twice, and arguments in an order no
+command line can give them. Exits non-zero when there are errors.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSpecValidate,
+}
+
+var specShowCmd = &cobra.Command{
+	Use:   "show <file> [cli subcommand...]",
+	Short: "Show the tree a spec describes",
+	Long: `Show the tree a spec describes, as treemand shows a discovered one: with
+the global --output, -i, --filter and --depth flags, among others. After the
+file, the CLI's name and a subcommand path show that command's subtree. A
+spec with errors is not shown; run 'treemand spec validate' to see them.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSpecShow,
+}
+
+func init() {
+	specCmd.AddCommand(specValidateCmd)
+	specCmd.AddCommand(specShowCmd)
+}
+
+func runSpecValidate(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	c, err := spec.Load(args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	errs := c.Validate()
+	for _, err := range errs {
+		fmt.Fprintf(out, "✗ error: %v\n", err)
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(out, "✗ %d error(s)\n", len(errs))
+		return fmt.Errorf("validation failed: %d error(s)", len(errs))
+	}
+	fmt.Fprintln(out, "✓ spec is valid")
+	return nil
+}
+
+func runSpecShow(cmd *cobra.Command, args []string) error {
+	c, err := loadSpec(args[0])
+	if err != nil {
+		return err
+	}
+	return showTree(cmd, c.Node(), args[0], args[1:])
+}
+
+// loadSpec reads and validates the spec at path, returning its first
+// error.
+func loadSpec(path string) (*spec.Command, error) {
+	c, err := spec.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch errs := c.Validate(); len(errs) {
+	case 0:
+	case 1:
+		return nil, fmt.Errorf("%s: %w", path, errs[0])
+	default:
+		return nil, fmt.Errorf("%s: %w (and %d more; see 'treemand spec validate')", path, errs[0], len(errs)-1)
+	}
+	return c, nil
+}
@@ -3,10 +3,8 @@ package cmd
 import (
 	"fmt"
 	"io"
-	"os"
+	"sync"
 	"time"
-
-	"github.com/mattn/go-isatty"
 )
 
 // Spinner writes animated braille frames to w while a long operation runs.
@@ -16,15 +14,14 @@ type Spinner struct {
 	isTTY bool
 	stop  chan struct{}
 	done  chan struct{}
+
+	mu    sync.Mutex
+	label string
 }
 
 // NewSpinner creates a Spinner that writes to w.
 func NewSpinner(w io.Writer) *Spinner {
-	tty := false
-	if f, ok := w.(*os.File); ok {
-		tty = isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
-	}
-	return &Spinner{w: w, isTTY: tty}
+	return &Spinner{w: w, isTTY: isTerminal(w)}
 }
 
 // Start begins rendering the spinner with the given label in a background
@@ -33,6 +30,7 @@ func (s *Spinner) Start(label string) {
 	if !s.isTTY {
 		return
 	}
+	s.SetLabel(label)
 	s.stop = make(chan struct{})
 	s.done = make(chan struct{})
 	go func() {
@@ -47,13 +45,23 @@ func (s *Spinner) Start(label string) {
 				fmt.Fprintf(s.w, "\r\033[K")
 				return
 			case <-ticker.C:
-				fmt.Fprintf(s.w, "\r%s %s", frames[i%len(frames)], label)
+				s.mu.Lock()
+				label := s.label
+				s.mu.Unlock()
+				fmt.Fprintf(s.w, "\r%s %s\033[K", frames[i%len(frames)], label)
 				i++
 			}
 		}
 	}()
 }
 
+// SetLabel changes the label shown next to the spinner while it runs.
+func (s *Spinner) SetLabel(label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.label = label
+}
+
 // Stop halts the spinner and clears the line. Safe to call when not started.
 func (s *Spinner) Stop() {
 	if s.stop == nil {
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aallbrig/treemand/config"
+	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/render"
+)
+
+// statsTop is the number of commands listed in the flag-count ranking.
+const statsTop = 10
+
+var statsCmd = &cobra.Command{
+	Use:   "stats <cli>",
+	Short: "Print metrics for a CLI's command tree",
+	Long: `Print metrics for a CLI's command tree: command, flag and positional
+counts, maximum depth and the deepest command, the command with the most
+subcommands, and the top 10 commands by flag count.
+
+The tree comes from the cache when fresh, otherwise it is discovered with
+the persistent flags (--depth, --strategy, --no-cache, --timeout).
+--output=json prints the same metrics as a JSON object.
+
+Examples:
+  treemand stats git
+  treemand stats --depth=-1 kubectl
+  treemand stats --output=json aws | jq .top_flags`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeCLIName,
+	RunE:              runStats,
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if err := checkOutputFormat(cfgOutput, "text", "json"); err != nil {
+		return err
+	}
+	root, err := loadCLI(args[0])
+	if err != nil {
+		return err
+	}
+	rep := render.Analyze(root, statsTop)
+	if cfgOutput == "json" {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	rep.WriteText(cmd.OutOrStdout())
+	return nil
+}
+
+// loadCLI loads the tree of cliName for a one-shot subcommand using the
+// persistent flags and the cache, with a spinner on stderr while discovery
+// runs.
+func loadCLI(cliName string) (*models.Node, error) {
+	initLogging()
+	cfg := resolveConfig()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfgTimeout)*time.Second)
+	defer cancel()
+
+	cacheInst := openCache(cfg)
+	if cacheInst != nil {
+		defer cacheInst.Close()
+	}
+	res, err := loadTree(ctx, cacheInst, cliName, nil, cfg, config.ParseStrategies(cfgStrategy), cfgIncremental, false, os.Stderr, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PruneErrors {
+		models.PruneErrors(res.Root)
+	}
+	if cfgMinConfidence > 0 {
+		models.PruneLowConfidence(res.Root, cfgMinConfidence)
+	}
+	return res.Root, nil
+}
+
+// checkOutputFormat rejects --output values a subcommand does not support.
+func checkOutputFormat(format string, allowed ...string) error {
+	for _, a := range allowed {
+		if format == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported --output %q (want one of %v)", format, allowed)
+}
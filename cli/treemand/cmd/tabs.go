@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/aallbrig/treemand/config"
+	"github.com/aallbrig/treemand/discovery"
+	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/tui"
+)
+
+// runTabs explores each CLI clis names in a tab of one TUI session, for
+// --tabs. Each tab keeps its own tree, preview and help pane, and its own
+// copy of the config, so cycling the sort order or display style in one
+// leaves the others as they are.
+func runTabs(clis []string) error {
+	if !cfgInteractive {
+		return errors.New("--tabs opens the CLIs in the TUI, so it needs -i")
+	}
+	cfg := resolveConfig()
+	if !cfg.Offline && cfg.Via == "" {
+		for _, cli := range clis {
+			if err := discovery.CheckAvailable(cli); err != nil {
+				return fmt.Errorf("%w\nHint: check spelling and ensure the command is on your PATH", err)
+			}
+		}
+	}
+	strategies := config.ParseStrategies(cfgStrategy)
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cacheInst := openCache(cfg)
+	if cacheInst != nil {
+		defer cacheInst.Close()
+	}
+
+	var tabs []*tui.Model
+	var cfgs []*config.Config
+	for _, cli := range clis {
+		ctx, cancel := context.WithTimeout(sigCtx, time.Duration(cfgTimeout)*time.Second)
+		res, err := loadTree(ctx, cacheInst, cli, nil, cfg, strategies, cfgIncremental, openShallow(cfg, cli, strategies, cfgIncremental), os.Stderr, nil)
+		cancel()
+		if sigCtx.Err() != nil {
+			return errInterrupted
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", cli, err)
+		}
+		if cfg.PruneErrors {
+			models.PruneErrors(res.Root)
+		}
+		if cfgMinConfidence > 0 {
+			models.PruneLowConfidence(res.Root, cfgMinConfidence)
+		}
+		tabCfg := *cfg
+		m, err := explorer(res, &tabCfg, cacheInst, &origin{cli: cli})
+		if err != nil {
+			return err
+		}
+		tabs = append(tabs, m)
+		cfgs = append(cfgs, &tabCfg)
+	}
+	return explore(tabs, cfgs)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/treemand"
+)
+
+// timingTop is how many of the slowest commands --timing lists.
+const timingTop = 10
+
+// probedNodes returns the nodes whose help was fetched by running the CLI,
+// slowest first.
+func probedNodes(root *models.Node) []*models.Node {
+	var nodes []*models.Node
+	root.Walk(func(n *models.Node) {
+		if n.Probe != nil {
+			nodes = append(nodes, n)
+		}
+	})
+	slices.SortStableFunc(nodes, func(a, b *models.Node) int {
+		return cmp.Compare(b.Probe.Duration, a.Probe.Duration)
+	})
+	return nodes
+}
+
+// writeTiming prints the --timing summary: how many commands were probed
+// with how many execs, and the slowest of them. probed comes from
+// probedNodes, taken before any pruning so failed commands are included.
+func writeTiming(w io.Writer, res *treemand.Result, probed []*models.Node, elapsed time.Duration) {
+	if res.Cached {
+		fmt.Fprintf(w, "Loaded from cache in %s; timing below is from the discovery that filled it (--no-cache to measure a fresh run).\n",
+			elapsed.Round(time.Millisecond))
+	}
+	if len(probed) == 0 {
+		fmt.Fprintln(w, "No commands were probed: all help output came from the cache.")
+		return
+	}
+	var execs int
+	var total time.Duration
+	for _, n := range probed {
+		execs += n.Probe.Execs
+		total += n.Probe.Duration
+	}
+	line := fmt.Sprintf("Probed %s with %s, %s of probe time",
+		plural(len(probed), "command", "commands"), plural(execs, "exec", "execs"), total.Round(time.Millisecond))
+	if !res.Cached {
+		line += fmt.Sprintf(" (discovery took %s; commands are probed in parallel)", elapsed.Round(time.Millisecond))
+	}
+	fmt.Fprintln(w, line+".")
+	fmt.Fprintln(w, "Slowest commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, n := range probed[:min(timingTop, len(probed))] {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t  %s\n", n.Probe.Duration.Round(time.Millisecond),
+			plural(n.Probe.Execs, "exec", "execs"), plural(n.Probe.Attempts, "attempt", "attempts"), n.FullCommand())
+	}
+	tw.Flush()
+}
@@ -19,6 +19,7 @@ package config
 
 import (
 	"os"
+	"slices"
 	"strings"
 	"time"
 )
@@ -57,6 +58,38 @@ func DefaultColors() ColorScheme {
 	}
 }
 
+// HighContrastColors returns the palette of accessibility mode: pure,
+// saturated colors that stay legible against a black or a white terminal
+// background. Flags share one color, their types being spelled out as tags.
+func HighContrastColors() ColorScheme {
+	return ColorScheme{
+		Base:         "#FFFFFF",
+		Subcmd:       "#00FFFF",
+		Flag:         "#FFFF00",
+		FlagBool:     "#FFFF00",
+		FlagString:   "#FFFF00",
+		FlagInt:      "#FFFF00",
+		FlagOther:    "#FFFF00",
+		Pos:          "#FF80FF",
+		Value:        "#FFFFFF",
+		Invalid:      "#FF4040",
+		Selected:     "#FFFFFF",
+		SelectedText: "#000000",
+	}
+}
+
+// SetAccessible turns accessibility mode on: the HighContrastColors
+// palette, ASCII icons in place of the default unicode ones, and flag
+// types spelled out as tags (see Accessible).
+func (c *Config) SetAccessible() {
+	c.Accessible = true
+	c.Colors = HighContrastColors()
+	if c.IconPreset == IconPresetUnicode {
+		c.IconPreset = IconPresetASCII
+		c.Icons = IconSetForPreset(IconPresetASCII)
+	}
+}
+
 // IconSet defines the glyphs used when drawing the command tree.
 // All strings should include a trailing space so they align with node names.
 type IconSet struct {
@@ -140,6 +173,45 @@ func ParseTreeStyle(s string) DisplayStyle {
 	}
 }
 
+// SortMode controls the order of child commands and flags in the rendered
+// tree and the TUI.
+type SortMode int
+
+const (
+	// SortNone keeps the order in which the CLI's help output listed them.
+	SortNone SortMode = iota
+	// SortName orders commands and flags alphabetically.
+	SortName
+	// SortDiscovered lists fully discovered commands before stubs and
+	// commands whose discovery failed.
+	SortDiscovered
+	// SortFlags lists commands with the most own flags first and orders
+	// flags alphabetically.
+	SortFlags
+	// SortUsed lists the commands and flags used most from the TUI first.
+	// Output without usage counts keeps the help output's order.
+	SortUsed
+)
+
+// SortModeNames maps each sort mode to its config / flag name.
+var SortModeNames = []string{"none", "name", "discovered", "flags", "used"}
+
+// ParseSortMode converts a string name to a SortMode.
+func ParseSortMode(s string) SortMode {
+	switch s {
+	case "name":
+		return SortName
+	case "discovered":
+		return SortDiscovered
+	case "flags":
+		return SortFlags
+	case "used":
+		return SortUsed
+	default:
+		return SortNone
+	}
+}
+
 // Config holds all treemand runtime configuration.
 type Config struct {
 	Colors           ColorScheme
@@ -150,10 +222,34 @@ type Config struct {
 	NoColor          bool
 	Depth            int
 	NoCache          bool
+	Offline          bool // serve trees from the cache only, never running the CLI
 	CacheDir         string
+	CacheBackend     string // "auto" | "sqlite" | "files"; auto uses sqlite when compiled with cgo
+	CacheMaxSize     int64  // bytes of trees and help text kept before LRU eviction; 0 = unlimited (default 100 MB)
 	Strategies       []string
+	ParserProfile    string        // help parser profile: "auto" (detect per help text) or a discovery.Profiles name
+	MergePolicy      string        // which value wins when strategies disagree: "first" or "richer"
+	Via              string        // command prefix discovery runs the CLI through ("docker run image", "wsl"); "" runs it directly
+	PackageMetadata  bool          // read the package.json or pipx metadata of CLIs installed by npm or pipx (default true)
+	OverridesDir     string        // directory of per-CLI override files applied to discovered trees
 	TreeStyle        DisplayStyle  // controls TUI tree presentation variant
-	StatusMsgTimeout time.Duration // how long a timed status message is shown (default 3s)
+	Sort             SortMode      // order of child commands and flags
+	CommandsOnly     bool          // hide flags and positionals (text output and TUI tree)
+	FullPath         bool          // show full command paths instead of names (text output and TUI tree)
+	PruneErrors      bool          // drop commands whose help could not be fetched
+	PruneDuplicates  bool          // drop subcommands whose help is identical to their parent's or a sibling's, instead of keeping them as aliases
+	PaneRatio        int           // TUI tree pane width as a percentage of the terminal (default 55)
+	StatusMsgTimeout time.Duration // how long a TUI status message is shown (default 3s)
+	ValueCompletion  bool          // ask the CLI's completion hook for value suggestions in the TUI
+	ShowSources      bool          // badge TUI commands and flags with the strategies that found them
+	CommandTimeout   time.Duration // time allowed to fetch one command's help (default 5s)
+	Retries          int           // retries of a help invocation that failed or timed out
+	RetryBackoff     time.Duration // wait before the first retry, doubled per retry (default 500ms)
+	AttemptTimeout   time.Duration // bound on one help invocation; 0 = the whole per-command budget
+	DangerPatterns   []string      // commands the TUI runs only once the user types "yes"
+	RecordRuns       bool          // record the output and exit code of commands run from the TUI in the cache
+	Accessible       bool          // high-contrast colors, ASCII glyphs, and flag types as text tags rather than colors
+	PlainView        bool          // start the TUI in its plain linear view of the selection, for screen readers
 }
 
 // DefaultConfig returns config with sensible defaults.
@@ -173,12 +269,26 @@ func DefaultConfig() *Config {
 		Depth:            -1, // unlimited
 		NoCache:          false,
 		CacheDir:         cacheDir,
+		CacheBackend:     "auto",
+		CacheMaxSize:     100 << 20,
 		Strategies:       defaultStrategies(),
+		ParserProfile:    "auto",
+		MergePolicy:      "first",
+		PackageMetadata:  true,
+		OverridesDir:     DefaultOverridesDir(),
 		TreeStyle:        StyleDefault,
+		Sort:             SortNone,
+		PaneRatio:        55,
 		StatusMsgTimeout: 3 * time.Second,
+		CommandTimeout:   5 * time.Second,
+		RetryBackoff:     500 * time.Millisecond,
+		DangerPatterns:   slices.Clone(DefaultDangerPatterns),
 	}
 }
 
+// DefaultDangerPatterns are the danger_patterns of a config that sets none.
+var DefaultDangerPatterns = []string{"rm -rf", "kubectl delete", "terraform destroy", "--force"}
+
 func defaultStrategies() []string {
 	if s := os.Getenv("TREEMAND_STRATEGIES"); s != "" {
 		return strings.Split(s, ",")
@@ -191,6 +301,16 @@ func ParseStrategies(s string) []string {
 	if s == "" {
 		return defaultStrategies()
 	}
+	return splitList(s)
+}
+
+// ParseDangerPatterns splits a comma-separated danger_patterns string; ""
+// gives none.
+func ParseDangerPatterns(s string) []string {
+	return splitList(s)
+}
+
+func splitList(s string) []string {
 	parts := strings.Split(s, ",")
 	result := make([]string, 0, len(parts))
 	for _, p := range parts {
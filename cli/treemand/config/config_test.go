@@ -3,6 +3,8 @@ package config_test
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/aallbrig/treemand/config"
@@ -127,6 +129,24 @@ func TestParseTreeStyle(t *testing.T) {
 	}
 }
 
+func TestParseSortMode(t *testing.T) {
+	tests := []struct {
+		input string
+		want  config.SortMode
+	}{
+		{"none", config.SortNone},
+		{"name", config.SortName},
+		{"discovered", config.SortDiscovered},
+		{"flags", config.SortFlags},
+		{"bogus", config.SortNone},
+	}
+	for _, tt := range tests {
+		if got := config.ParseSortMode(tt.input); got != tt.want {
+			t.Errorf("ParseSortMode(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
 func TestLoadConfigFile(t *testing.T) {
 	// Write a temporary config file and verify ApplyViper reads it.
 	dir := t.TempDir()
@@ -193,3 +213,105 @@ colors:
 		t.Errorf("Colors.Selected = %q, want #0000FF", cfg.Colors.Selected)
 	}
 }
+
+func TestLoadConfigFile_dangerPatterns(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		want    []string
+	}{
+		{"icons: ascii\n", config.DefaultDangerPatterns},
+		{"danger_patterns: \"helm uninstall, --purge\"\n", []string{"helm uninstall", "--purge"}},
+		{"danger_patterns: \"\"\n", []string{}},
+	} {
+		cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(cfgPath, []byte(tc.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := config.InitViper(cfgPath); err != nil {
+			t.Fatalf("InitViper error: %v", err)
+		}
+		cfg := config.DefaultConfig()
+		config.ApplyViper(cfg)
+		if !slices.Equal(cfg.DangerPatterns, tc.want) {
+			t.Errorf("%q: DangerPatterns = %q, want %q", tc.content, cfg.DangerPatterns, tc.want)
+		}
+	}
+}
+
+func TestLoadConfigFile_accessible(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "accessible: true\ncolors:\n  pos: \"#FFFFFF\"\n"
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.InitViper(cfgPath); err != nil {
+		t.Fatalf("InitViper error: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	config.ApplyViper(cfg)
+
+	if !cfg.Accessible {
+		t.Fatal("Accessible = false, want true")
+	}
+	if cfg.Colors.Subcmd != config.HighContrastColors().Subcmd {
+		t.Errorf("Colors.Subcmd = %q, want the high-contrast %q", cfg.Colors.Subcmd, config.HighContrastColors().Subcmd)
+	}
+	if cfg.Colors.Pos != "#FFFFFF" {
+		t.Errorf("Colors.Pos = %q, want the configured #FFFFFF", cfg.Colors.Pos)
+	}
+	if cfg.IconPreset != config.IconPresetASCII {
+		t.Errorf("IconPreset = %q, want ascii", cfg.IconPreset)
+	}
+}
+
+func TestSaveKey(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	content := `# my settings
+icons: ascii # keep
+colors:
+  base: "#FFFFFF"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SaveKey(cfgPath, "pane_ratio", "40"); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+	if err := config.SaveKey(cfgPath, "colors.subcmd", "#FF5555"); err != nil {
+		t.Fatalf("SaveKey nested: %v", err)
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{"# my settings", "icons: ascii # keep", "pane_ratio: 40", "subcmd: '#FF5555'"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("config missing %q:\n%s", want, got)
+		}
+	}
+
+	if err := config.InitViper(cfgPath); err != nil {
+		t.Fatalf("InitViper error: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	config.ApplyViper(cfg)
+	if cfg.PaneRatio != 40 {
+		t.Errorf("PaneRatio = %d, want 40", cfg.PaneRatio)
+	}
+}
+
+func TestSaveKey_createsMissingFile(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "treemand", "config.yaml")
+	if err := config.SaveKey(cfgPath, "pane_ratio", "70"); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "pane_ratio: 70") || !strings.Contains(string(data), "# treemand configuration file") {
+		t.Errorf("unexpected config:\n%s", data)
+	}
+}
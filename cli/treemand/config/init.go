@@ -23,6 +23,77 @@ stub_threshold: 150
 # TUI tree presentation style: default, columns, compact, graph
 tree_style: default
 
+# Order of child commands and flags: none (help-output order), name,
+# discovered (discovered before stubs), flags (most flags first),
+# used (most used from the TUI first)
+sort: none
+
+# Hide flags and positional arguments in text output and the TUI tree
+# (toggle with c in the TUI; default: false)
+commands_only: false
+
+# Show full command paths ("git remote add") instead of indented names
+# (toggle with p in the TUI; default: false)
+full_path: false
+
+# Drop commands whose help could not be fetched ("(could not get help: …)")
+# from output and the TUI tree; --show-errors lists them (default: false)
+prune_errors: false
+
+# Drop subcommands whose help is identical to their parent's or a sibling's
+# instead of keeping them as aliases (default: false)
+prune_duplicates: false
+
+# TUI tree pane width as a percentage of the terminal, 20-80 (resize with
+# < and > or by dragging the divider; saved on exit; default: 55)
+pane_ratio: 55
+
+# Suggest flag and argument values in the TUI by running the CLI's own
+# completion hook (cobra __complete, aws_completer); default: false
+value_completion: false
+
+# Badge commands and flags in the TUI with the discovery strategies that
+# found them, e.g. [help,man], to trace where merged data came from
+# (default: false)
+show_sources: false
+
+# Record the output, exit code and duration of commands run from the TUI in
+# the cache, to review with :runs or 'treemand runs <cli>'. The command's
+# output still shows in the terminal, but through a pipe, so it may lose
+# its colors and full-screen programs may not work (default: false)
+record_runs: false
+
+# Accessibility mode: a high-contrast palette, ASCII icons and borders in
+# place of the unicode ones, and flag types spelled out as [bool], [str]
+# and [int] tags rather than told apart by color alone (default: false)
+accessible: false
+
+# Start the TUI in its plain view, which lists the selected command, its
+# flags and arguments and the command being built as plain lines of text
+# for screen readers; :plain toggles it (default: false)
+plain_view: false
+
+# Commands the TUI runs only after you type "yes" in the Ctrl+E modal,
+# comma-separated. Each matches a command holding its words in a row, so
+# "kubectl delete" matches "kubectl delete pod web" (default: rm -rf,
+# kubectl delete, terraform destroy, --force; "" = none)
+danger_patterns: "rm -rf,kubectl delete,terraform destroy,--force"
+
+# Seconds allowed to fetch one command's help. A command that takes longer
+# is marked as timed out and its siblings are still discovered; --timeout
+# bounds the whole run (default: 5)
+per_command_timeout: 5
+
+# Retry a command's help when it fails or times out, for CLIs that do so
+# intermittently (default: 0 = try once). The wait before the first retry
+# doubles for each further retry (default: 500 ms).
+retries: 0
+retry_backoff_ms: 500
+
+# Seconds one help invocation may take before it is abandoned and retried,
+# so a hang leaves time for a retry (default: 0 = no separate limit)
+attempt_timeout: 0
+
 # Disable colored output (default: false)
 no_color: false
 
@@ -32,10 +103,51 @@ depth: 3
 # Disable discovery cache (default: false)
 no_cache: false
 
+# Serve trees from the cache only and never run the CLI — no discovery,
+# no version probe, no value completion or running commands from the TUI.
+# Fails for CLIs that are not cached (default: false)
+offline: false
+
+# Where the cache is stored: "sqlite" (~/.treemand/cache.db, needs a cgo
+# build of treemand), "files" (~/.treemand/cache/, pure Go) or "auto",
+# which uses sqlite when it is compiled in (default: auto)
+cache_backend: auto
+
+# Megabytes of trees and help text the cache may hold; past it, the least
+# recently used trees are evicted (default: 100; 0 = unlimited)
+cache_max_size_mb: 100
+
 # Discovery strategies, comma-separated (default: help)
-# Available: help, completions, man
+# Available: help, completions, man, hidden
 strategies: help
 
+# How help output is parsed: auto detects the CLI framework from each help
+# text; cobra, clap, argparse, aws, bsd or gnu force that family's rules,
+# and generic applies every rule (default: auto)
+parser_profile: auto
+
+# Which value wins when discovery strategies disagree on a description or
+# flag type: first keeps the first strategy's in the strategies list, richer
+# the longer description and the more specific type (default: first)
+merge_policy: first
+
+# Command prefix every command discovery runs goes through, to discover a
+# CLI installed in a container or WSL distribution: "docker run image",
+# "wsl" (default: empty, run the CLI directly)
+via: ""
+
+# Read the package.json or pipx metadata of a CLI installed by npm or pipx,
+# whose command on PATH is a shim: the root gets the package's description
+# when its help has none. The package's version is part of the cache key
+# either way (default: true)
+package_metadata: true
+
+# Directory of override files, one per CLI named <cli>.yaml, that correct
+# discovered trees: add missing flags and commands, fix flag types and
+# descriptions, remove noise (default: empty, overrides/ in treemand's
+# config directory)
+overrides_dir: ""
+
 # Color scheme (hex colors, all optional)
 colors:
   base: "#FFFFFF"
@@ -65,6 +177,19 @@ func DefaultConfigPath() string {
 	return "config.yaml"
 }
 
+// DefaultOverridesDir returns the directory override files are read from
+// unless overrides_dir says otherwise: overrides/ next to the preferred
+// config file.
+func DefaultOverridesDir() string {
+	return filepath.Join(filepath.Dir(DefaultConfigPath()), "overrides")
+}
+
+// TourMarkerPath returns the file whose presence records that the TUI has
+// been launched before, so its guided tour opens only the first time.
+func TourMarkerPath() string {
+	return filepath.Join(filepath.Dir(DefaultConfigPath()), "toured")
+}
+
 // WriteDefaultConfig writes a commented default configuration file to path.
 // It creates parent directories as needed. If the file already exists and
 // force is false, it returns an error.
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -62,15 +63,88 @@ func ApplyViper(cfg *Config) {
 	if v := viper.GetString("tree_style"); v != "" {
 		cfg.TreeStyle = ParseTreeStyle(v)
 	}
+	if v := viper.GetString("sort"); v != "" {
+		cfg.Sort = ParseSortMode(v)
+	}
+	if viper.GetBool("commands_only") {
+		cfg.CommandsOnly = true
+	}
+	if viper.GetBool("full_path") {
+		cfg.FullPath = true
+	}
+	if viper.GetBool("prune_errors") {
+		cfg.PruneErrors = true
+	}
+	if viper.GetBool("prune_duplicates") {
+		cfg.PruneDuplicates = true
+	}
+	if v := viper.GetInt("pane_ratio"); v > 0 {
+		cfg.PaneRatio = v
+	}
+	if viper.GetBool("value_completion") {
+		cfg.ValueCompletion = true
+	}
+	if viper.GetBool("show_sources") {
+		cfg.ShowSources = true
+	}
+	if v := viper.GetInt("per_command_timeout"); v > 0 {
+		cfg.CommandTimeout = time.Duration(v) * time.Second
+	}
+	if v := viper.GetInt("retries"); v > 0 {
+		cfg.Retries = v
+	}
+	if viper.IsSet("retry_backoff_ms") {
+		cfg.RetryBackoff = time.Duration(viper.GetInt("retry_backoff_ms")) * time.Millisecond
+	}
+	if v := viper.GetInt("attempt_timeout"); v > 0 {
+		cfg.AttemptTimeout = time.Duration(v) * time.Second
+	}
+	if viper.GetBool("record_runs") {
+		cfg.RecordRuns = true
+	}
+	// Before the color overrides, which may still change its palette.
+	if viper.GetBool("accessible") {
+		cfg.SetAccessible()
+	}
+	if viper.GetBool("plain_view") {
+		cfg.PlainView = true
+	}
+	if viper.IsSet("danger_patterns") {
+		cfg.DangerPatterns = ParseDangerPatterns(viper.GetString("danger_patterns"))
+	}
 	if v := viper.GetInt("depth"); v != 0 {
 		cfg.Depth = v
 	}
 	if v := viper.GetString("strategies"); v != "" {
 		cfg.Strategies = ParseStrategies(v)
 	}
+	if v := viper.GetString("parser_profile"); v != "" {
+		cfg.ParserProfile = v
+	}
+	if v := viper.GetString("merge_policy"); v != "" {
+		cfg.MergePolicy = v
+	}
+	if v := viper.GetString("via"); v != "" {
+		cfg.Via = v
+	}
+	if viper.IsSet("package_metadata") {
+		cfg.PackageMetadata = viper.GetBool("package_metadata")
+	}
+	if v := viper.GetString("overrides_dir"); v != "" {
+		cfg.OverridesDir = v
+	}
 	if viper.GetBool("no_cache") {
 		cfg.NoCache = true
 	}
+	if viper.GetBool("offline") {
+		cfg.Offline = true
+	}
+	if v := viper.GetString("cache_backend"); v != "" {
+		cfg.CacheBackend = v
+	}
+	if viper.IsSet("cache_max_size_mb") {
+		cfg.CacheMaxSize = int64(viper.GetInt("cache_max_size_mb")) << 20
+	}
 
 	// Color overrides — each sub-key under "colors" is optional.
 	if v := viper.GetString("colors.base"); v != "" {
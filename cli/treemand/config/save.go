@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// SaveKey sets a single key in the YAML config file at path, creating the
+// file from the commented default template if it does not exist. Other keys
+// and comments are preserved. Nested keys use dot-notation (colors.subcmd).
+func SaveKey(path, key, value string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := WriteDefaultConfig(path, false); err != nil {
+			return err
+		}
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("parse config: %s is not a YAML mapping", path)
+	}
+
+	m := doc.Content[0]
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		m = mappingChild(m, p, yaml.MappingNode)
+	}
+	leaf := mappingChild(m, parts[len(parts)-1], yaml.ScalarNode)
+	leaf.Kind = yaml.ScalarNode
+	leaf.Tag = ""
+	leaf.Style = 0
+	leaf.Value = value
+	leaf.Content = nil
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
+
+// mappingChild returns the value node for key in mapping m, appending a new
+// node of the given kind when the key is missing.
+func mappingChild(m *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	v := &yaml.Node{Kind: kind}
+	m.Content = append(m.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: key}, v)
+	return v
+}
@@ -57,10 +57,34 @@ func buildKnownKeys() []SchemaEntry {
 		{Key: "desc_line_length", Type: TypeInt, Default: "80", MinInt: 1, MaxInt: 500, Description: "Max description characters before truncation"},
 		{Key: "stub_threshold", Type: TypeInt, Default: "150", MinInt: 1, MaxInt: 10000, Description: "Max eager children before creating stubs"},
 		{Key: "tree_style", Type: TypeString, Default: "default", AllowedValues: []string{"default", "columns", "compact", "graph"}, Description: "TUI tree presentation style"},
+		{Key: "sort", Type: TypeString, Default: "none", AllowedValues: []string{"none", "name", "discovered", "flags", "used"}, Description: "Order of child commands and flags"},
+		{Key: "commands_only", Type: TypeBool, Default: "false", Description: "Hide flags and positionals in text output and the TUI tree"},
+		{Key: "full_path", Type: TypeBool, Default: "false", Description: "Show full command paths instead of names in text output and the TUI tree"},
+		{Key: "prune_errors", Type: TypeBool, Default: "false", Description: "Drop commands whose help could not be fetched from output and the TUI tree"},
+		{Key: "prune_duplicates", Type: TypeBool, Default: "false", Description: "Drop subcommands whose help is identical to their parent's or a sibling's instead of keeping them as aliases"},
+		{Key: "pane_ratio", Type: TypeInt, Default: "55", MinInt: 20, MaxInt: 80, Description: "TUI tree pane width as a percentage of the terminal"},
+		{Key: "value_completion", Type: TypeBool, Default: "false", Description: "Suggest flag and argument values from the CLI's completion hook in the TUI"},
+		{Key: "show_sources", Type: TypeBool, Default: "false", Description: "Badge commands and flags in the TUI with the discovery strategies that found them"},
+		{Key: "record_runs", Type: TypeBool, Default: "false", Description: "Record the output and exit code of commands run from the TUI in the cache (see treemand runs)"},
+		{Key: "accessible", Type: TypeBool, Default: "false", Description: "High-contrast colors, ASCII glyphs, and flag types as [bool]/[str] tags instead of colors"},
+		{Key: "plain_view", Type: TypeBool, Default: "false", Description: "Start the TUI in its plain linear view of the selection, for screen readers"},
+		{Key: "danger_patterns", Type: TypeString, Default: "rm -rf,kubectl delete,terraform destroy,--force", Description: "Comma-separated commands the TUI runs only after typing yes, e.g. \"kubectl delete\" (empty = none)"},
+		{Key: "per_command_timeout", Type: TypeInt, Default: "5", MinInt: 1, MaxInt: 3600, Description: "Seconds allowed to fetch one command's help"},
+		{Key: "retries", Type: TypeInt, Default: "0", MinInt: 0, MaxInt: 10, Description: "Retries of a help invocation that failed or timed out"},
+		{Key: "retry_backoff_ms", Type: TypeInt, Default: "500", MinInt: 0, MaxInt: 60000, Description: "Milliseconds before the first retry, doubled for each further retry"},
+		{Key: "attempt_timeout", Type: TypeInt, Default: "0", MinInt: 0, MaxInt: 3600, Description: "Seconds one help invocation may take before it is retried (0 = no separate limit)"},
 		{Key: "no_color", Type: TypeBool, Default: "false", Description: "Disable colored output"},
 		{Key: "depth", Type: TypeInt, Default: "3", MinInt: -1, MaxInt: 100, Description: "Max tree depth (default 3; -1 = unlimited)"},
 		{Key: "no_cache", Type: TypeBool, Default: "false", Description: "Disable discovery cache"},
-		{Key: "strategies", Type: TypeString, Default: "help", Description: "Comma-separated discovery strategies (help, completions, man)"},
+		{Key: "offline", Type: TypeBool, Default: "false", Description: "Serve trees from the cache only and never run the CLI"},
+		{Key: "cache_backend", Type: TypeString, Default: "auto", AllowedValues: []string{"auto", "sqlite", "files"}, Description: "Where the cache is stored: SQLite (needs a cgo build) or plain files; auto prefers SQLite"},
+		{Key: "cache_max_size_mb", Type: TypeInt, Default: "100", MinInt: 0, MaxInt: 1 << 20, Description: "Megabytes of trees and help text cached before the least recently used are evicted (0 = unlimited)"},
+		{Key: "strategies", Type: TypeString, Default: "help", Description: "Comma-separated discovery strategies (help, completions, man, hidden)"},
+		{Key: "parser_profile", Type: TypeString, Default: "auto", AllowedValues: []string{"auto", "cobra", "clap", "argparse", "aws", "bsd", "gnu", "generic"}, Description: "Help parser profile; auto detects it from each help text"},
+		{Key: "merge_policy", Type: TypeString, Default: "first", AllowedValues: []string{"first", "richer"}, Description: "Which value wins when strategies disagree: the first strategy's, or the richer one"},
+		{Key: "via", Type: TypeString, Default: "", Description: "Command prefix every command discovery runs goes through, such as \"docker run image\" or \"wsl\" (empty = run the CLI directly)"},
+		{Key: "package_metadata", Type: TypeBool, Default: "true", Description: "Read the package metadata of CLIs installed by npm or pipx, for the root's description when its help has none"},
+		{Key: "overrides_dir", Type: TypeString, Default: "", Description: "Directory of per-CLI override files, <cli>.yaml (empty = overrides/ next to the default config file)"},
 	}
 
 	for _, c := range colorKeys {
@@ -133,14 +133,38 @@ func flattenMap(prefix string, m map[string]interface{}) map[string]interface{}
 func ToYAML(cfg *Config) (string, error) {
 	// Build an ordered representation for clean output.
 	m := map[string]interface{}{
-		"icons":            cfg.IconPreset,
-		"desc_line_length": cfg.DescLineLength,
-		"stub_threshold":   cfg.StubThreshold,
-		"tree_style":       displayStyleToString(cfg.TreeStyle),
-		"no_color":         cfg.NoColor,
-		"depth":            cfg.Depth,
-		"no_cache":         cfg.NoCache,
-		"strategies":       strings.Join(cfg.Strategies, ","),
+		"icons":               cfg.IconPreset,
+		"desc_line_length":    cfg.DescLineLength,
+		"stub_threshold":      cfg.StubThreshold,
+		"tree_style":          displayStyleToString(cfg.TreeStyle),
+		"sort":                sortModeToString(cfg.Sort),
+		"commands_only":       cfg.CommandsOnly,
+		"full_path":           cfg.FullPath,
+		"prune_errors":        cfg.PruneErrors,
+		"prune_duplicates":    cfg.PruneDuplicates,
+		"pane_ratio":          cfg.PaneRatio,
+		"value_completion":    cfg.ValueCompletion,
+		"show_sources":        cfg.ShowSources,
+		"record_runs":         cfg.RecordRuns,
+		"accessible":          cfg.Accessible,
+		"plain_view":          cfg.PlainView,
+		"danger_patterns":     strings.Join(cfg.DangerPatterns, ","),
+		"per_command_timeout": int(cfg.CommandTimeout.Seconds()),
+		"retries":             cfg.Retries,
+		"retry_backoff_ms":    cfg.RetryBackoff.Milliseconds(),
+		"attempt_timeout":     int(cfg.AttemptTimeout.Seconds()),
+		"no_color":            cfg.NoColor,
+		"depth":               cfg.Depth,
+		"no_cache":            cfg.NoCache,
+		"offline":             cfg.Offline,
+		"cache_backend":       cfg.CacheBackend,
+		"cache_max_size_mb":   cfg.CacheMaxSize >> 20,
+		"strategies":          strings.Join(cfg.Strategies, ","),
+		"parser_profile":      cfg.ParserProfile,
+		"merge_policy":        cfg.MergePolicy,
+		"via":                 cfg.Via,
+		"package_metadata":    cfg.PackageMetadata,
+		"overrides_dir":       cfg.OverridesDir,
 		"colors": map[string]interface{}{
 			"base":          cfg.Colors.Base,
 			"subcmd":        cfg.Colors.Subcmd,
@@ -169,3 +193,10 @@ func displayStyleToString(s DisplayStyle) string {
 	}
 	return "default"
 }
+
+func sortModeToString(m SortMode) string {
+	if int(m) < len(SortModeNames) {
+		return SortModeNames[m]
+	}
+	return "none"
+}
@@ -0,0 +1,107 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// gitValueKind is what a git flag or positional refers to.
+type gitValueKind int
+
+const (
+	gitValueNone gitValueKind = iota
+	gitValueRef
+	gitValueRemote
+	gitValuePath
+)
+
+// gitValueKinds maps flag and positional names, as git's help output spells
+// them, to what they refer to. Names are looked up without dashes and
+// angle brackets.
+var gitValueKinds = map[string]gitValueKind{
+	"branch": gitValueRef, "branchname": gitValueRef, "new-branch": gitValueRef,
+	"commit": gitValueRef, "commit-ish": gitValueRef, "tree-ish": gitValueRef,
+	"ref": gitValueRef, "refname": gitValueRef, "rev": gitValueRef,
+	"revision": gitValueRef, "revision-range": gitValueRef, "start-point": gitValueRef,
+	"tag": gitValueRef, "tagname": gitValueRef, "onto": gitValueRef,
+	"upstream": gitValueRef, "set-upstream-to": gitValueRef, "newbase": gitValueRef,
+	"source": gitValueRef, "fixup": gitValueRef, "squash": gitValueRef,
+	"reuse-message": gitValueRef, "reedit-message": gitValueRef,
+
+	"remote": gitValueRemote, "repository": gitValueRemote, "repo": gitValueRemote,
+
+	"path": gitValuePath, "paths": gitValuePath, "pathspec": gitValuePath,
+	"file": gitValuePath, "files": gitValuePath,
+}
+
+// GitCompleter suggests values for git from the repository in Dir (the
+// working directory when empty): branches and tags for refs, remote names
+// for remotes, and changed files for paths.
+type GitCompleter struct {
+	Dir string
+}
+
+// CompleteValues returns the repository's refs, remotes or changed files
+// starting with toComplete, depending on what name refers to. Values for
+// other flags and positionals are left to the user.
+func (g *GitCompleter) CompleteValues(ctx context.Context, _ []string, toComplete, name string) ([]string, error) {
+	var (
+		out string
+		err error
+	)
+	switch gitValueKinds[strings.Trim(strings.TrimLeft(name, "-"), "<>[].")] {
+	case gitValueRef:
+		out, err = g.git(ctx, "for-each-ref", "--format=%(refname:short)", "refs/heads", "refs/tags", "refs/remotes")
+	case gitValueRemote:
+		out, err = g.git(ctx, "remote")
+	case gitValuePath:
+		out, err = g.git(ctx, "status", "--porcelain", "--untracked-files=all")
+		out = porcelainPaths(out)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, v := range strings.Split(out, "\n") {
+		if v = strings.TrimSpace(v); v != "" && strings.HasPrefix(v, toComplete) {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// git runs a git subcommand in g.Dir and returns its stdout.
+func (g *GitCompleter) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.Dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// porcelainPaths extracts the paths from `git status --porcelain` lines
+// ("XY path", or "XY old -> new" for renames), one per line.
+func porcelainPaths(out string) string {
+	var paths []string
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		p := line[3:]
+		if _, to, ok := strings.Cut(p, " -> "); ok {
+			p = to
+		}
+		if unq, err := strconv.Unquote(p); err == nil {
+			p = unq
+		}
+		paths = append(paths, p)
+	}
+	return strings.Join(paths, "\n")
+}
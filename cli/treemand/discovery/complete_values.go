@@ -0,0 +1,106 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ValueCompleter suggests values for the word being typed, e.g. pod names
+// after "kubectl get pods -n". args are the command line tokens after the
+// CLI name that precede the word; toComplete is the partially typed word;
+// name is the flag ("--namespace") or positional ("pathspec") it fills.
+type ValueCompleter interface {
+	CompleteValues(ctx context.Context, args []string, toComplete, name string) ([]string, error)
+}
+
+// NewValueCompleter returns the completer for cliName: the built-in
+// GitCompleter for git, aws_completer for aws, and Cobra's __complete
+// protocol for everything else. CLIs that support none of these simply
+// return no values.
+func NewValueCompleter(cliName string) ValueCompleter {
+	switch cliName {
+	case "git":
+		return &GitCompleter{}
+	case "aws":
+		if path, err := exec.LookPath("aws_completer"); err == nil {
+			return &AWSCompleter{Path: path}
+		}
+	}
+	return &CobraCompleter{CLI: cliName}
+}
+
+// CobraCompleter completes values by running <cli> __complete <args...>
+// <toComplete>, the hidden command every Cobra CLI (kubectl, helm, gh, …)
+// uses for shell completion.
+type CobraCompleter struct {
+	CLI string
+}
+
+// CompleteValues runs the CLI's __complete command and parses its output.
+func (c *CobraCompleter) CompleteValues(ctx context.Context, args []string, toComplete, _ string) ([]string, error) {
+	cmdArgs := append(append([]string{"__complete"}, args...), toComplete)
+	path, _ := BinaryPath(ctx, c.CLI)
+	cmd := Command(ctx, path, cmdArgs...)
+	// Cobra reports the directive on stderr as well; only stdout is parsed.
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s __complete: %w", c.CLI, err)
+	}
+	return ParseValueCompletions(string(out), toComplete), nil
+}
+
+// cobraDirectiveError is Cobra's ShellCompDirectiveError bit: completion
+// failed and the values, if any, must be ignored.
+const cobraDirectiveError = 1
+
+// ParseValueCompletions parses Cobra __complete output into values. Each
+// line is "<value>\t<description>" or "<value>"; the final ":<directive>"
+// line and active-help lines are not values. Flags are only returned when
+// toComplete itself starts with "-".
+func ParseValueCompletions(out, toComplete string) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if d, ok := strings.CutPrefix(line, ":"); ok {
+			if n, err := strconv.Atoi(d); err == nil && n&cobraDirectiveError != 0 {
+				return nil
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "_activeHelp_") {
+			continue
+		}
+		value, _, _ := strings.Cut(line, "\t")
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] || (strings.HasPrefix(value, "-") && !strings.HasPrefix(toComplete, "-")) {
+			continue
+		}
+		seen[value] = true
+		values = append(values, value)
+	}
+	return values
+}
+
+// AWSCompleter completes values with aws_completer, which reads the command
+// line from the COMP_LINE and COMP_POINT environment variables the way bash
+// completion passes them.
+type AWSCompleter struct {
+	Path string
+}
+
+// CompleteValues runs aws_completer for "aws <args...> <toComplete>".
+func (c *AWSCompleter) CompleteValues(ctx context.Context, args []string, toComplete, _ string) ([]string, error) {
+	line := strings.Join(append(append([]string{"aws"}, args...), toComplete), " ")
+	cmd := exec.CommandContext(ctx, c.Path) //nolint:gosec
+	cmd.Env = append(os.Environ(), "COMP_LINE="+line, "COMP_POINT="+strconv.Itoa(len(line)))
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("aws_completer: %w", err)
+	}
+	return ParseValueCompletions(string(out), toComplete), nil
+}
@@ -2,8 +2,8 @@ package discovery
 
 import (
 	"context"
-	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/aallbrig/treemand/models"
 )
@@ -40,7 +40,7 @@ func (c *CompletionsDiscoverer) Discover(ctx context.Context, cliName string, ar
 	fullPath := []string{cliName}
 	fullPath = append(fullPath, args...)
 	root := &models.Node{
-		Name:     cliName,
+		Name:     fullPath[len(fullPath)-1],
 		FullPath: fullPath,
 		Children: children,
 	}
@@ -49,9 +49,11 @@ func (c *CompletionsDiscoverer) Discover(ctx context.Context, cliName string, ar
 
 // runCommand executes cliName with args and returns combined stdout+stderr.
 func runCommand(ctx context.Context, cliName string, args []string) (string, error) {
-	resolved := resolveBinary(cliName)
-	cmd := exec.CommandContext(ctx, resolved, args...) //nolint:gosec
+	resolved, _ := BinaryPath(ctx, cliName)
+	cmd := Command(ctx, resolved, args...)
+	start := time.Now()
 	out, err := cmd.CombinedOutput()
+	logExec(ctx, cmd, start, len(out), err)
 	return strings.TrimSpace(string(out)), err
 }
 
@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"math"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// Parsed flags and commands carry a confidence score from 0 to 1 saying how
+// well the line they came from matched the pattern they were read by. A
+// flag under "Options:" with a description is almost surely a flag; an
+// indented word in a paragraph may be a command, or a stray line of prose.
+// The scores are coarse on purpose: they sort parses into trusted and
+// doubtful (models.LowConfidence), not into a precise ranking.
+
+// Base scores by where an element was found.
+const (
+	confidenceListed = 0.9 // in a section for its kind ("Flags:", "Commands:")
+	confidenceUsage  = 0.8 // named by the usage grammar (argparse choices, docopt)
+	confidenceList   = 0.7 // bare names in a comma list or a grid
+	confidenceLoose  = 0.6 // outside any section, in a layout of its kind
+	confidenceProse  = 0.4 // in examples or descriptions
+)
+
+// flagConfidence scores a flag found where base applies: flags without a
+// description, or whose "description" is a value list, are likely misparses.
+func flagConfidence(f models.Flag, base float64) float64 {
+	if f.Description == "" {
+		base -= 0.2
+	}
+	if looksLikeValue(f.Description) {
+		base -= 0.5
+	}
+	return roundConfidence(base)
+}
+
+// commandConfidence scores a listed command with description desc found
+// where base applies.
+func commandConfidence(desc string, base float64) float64 {
+	if strings.TrimSpace(desc) == "" {
+		base -= 0.2
+	}
+	return roundConfidence(base)
+}
+
+// looksLikeValue reports whether a flag's description is really its value:
+// "--style {plain,fancy}" read as the flag --style described "{plain,fancy}".
+func looksLikeValue(desc string) bool {
+	return strings.HasPrefix(desc, "{") || strings.HasPrefix(desc, "<") || strings.HasPrefix(desc, "=")
+}
+
+// roundConfidence rounds c to two places and keeps it within (0, 1], since
+// 0 means unscored.
+func roundConfidence(c float64) float64 {
+	return math.Min(1, math.Max(0.1, math.Round(c*100)/100))
+}
+
+// scoreChildren sets the confidence of node's children from the scores the
+// parse of node's help gave the subcommands it listed.
+func scoreChildren(node *models.Node, scores map[string]float64) {
+	for _, c := range node.Children {
+		if s, ok := scores[c.Name]; ok {
+			c.Confidence = s
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package discovery
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// HashHelp returns a short, stable hash of help text. ANSI escapes and
+// trailing whitespace are ignored so cosmetic differences between two runs
+// of the same CLI do not produce different hashes.
+func HashHelp(text string) string {
+	if text == "" {
+		return ""
+	}
+	lines := strings.Split(stripANSI(text), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t\r")
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(strings.Join(lines, "\n"))))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// Dedupe walks the tree and collapses subcommands whose help output is
+// identical to their parent's or to an earlier sibling's. Some CLIs print
+// the parent help for every unknown subcommand, which otherwise produces
+// huge duplicated subtrees.
+//
+// Duplicates of the parent keep their row but lose their children; duplicate
+// siblings are marked with AliasOf. When prune is true both kinds are removed
+// from the tree instead. Every decision is recorded in the Dedup field of
+// the affected node (or of the parent, for pruned children).
+func Dedupe(root *models.Node, prune bool) {
+	if root == nil {
+		return
+	}
+	root.Walk(func(n *models.Node) { dedupeChildren(n, prune) })
+}
+
+func dedupeChildren(parent *models.Node, prune bool) {
+	if len(parent.Children) == 0 {
+		return
+	}
+	first := map[string]*models.Node{}
+	kept := parent.Children[:0]
+	for _, c := range parent.Children {
+		if c.HelpHash == "" || c.Virtual {
+			kept = append(kept, c)
+			continue
+		}
+		var reason string
+		switch orig, seen := first[c.HelpHash]; {
+		case parent.HelpHash != "" && c.HelpHash == parent.HelpHash:
+			reason = "help identical to parent"
+		case seen:
+			reason = fmt.Sprintf("help identical to sibling %q", orig.Name)
+			c.AliasOf = orig.Name
+		default:
+			first[c.HelpHash] = c
+			kept = append(kept, c)
+			continue
+		}
+		if prune {
+			addDedupNote(parent, fmt.Sprintf("pruned %q: %s", c.Name, reason))
+			continue
+		}
+		c.Children = nil
+		addDedupNote(c, reason)
+		kept = append(kept, c)
+	}
+	// Clear the tail so pruned nodes can be garbage collected.
+	for i := len(kept); i < len(parent.Children); i++ {
+		parent.Children[i] = nil
+	}
+	parent.Children = kept
+}
+
+// addDedupNote records note on n unless it is already present, so running
+// Dedupe again over a reused subtree does not repeat itself.
+func addDedupNote(n *models.Node, note string) {
+	for _, existing := range n.Dedup {
+		if existing == note {
+			return
+		}
+	}
+	n.Dedup = append(n.Dedup, note)
+}
@@ -0,0 +1,54 @@
+package discovery
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+var (
+	// deprecatedRe matches the ways help output marks a flag or command
+	// as deprecated: "(deprecated)", "[DEPRECATED: ...]", a description
+	// starting with "Deprecated", "is deprecated", "deprecated in favor
+	// of". A bare lower-case "deprecated" elsewhere ("list deprecated
+	// APIs") is not a marker.
+	deprecatedRe = regexp.MustCompile(`(?i)[(\[]\s*deprecated\b|^\W*deprecated\b|\b(?:is|are|been|now)\s+deprecated\b|\bdeprecated\s+in\s+favou?r\s+of\b`)
+	// deprecatedCapsRe matches an all-caps DEPRECATED anywhere.
+	deprecatedCapsRe = regexp.MustCompile(`\bDEPRECATED\b`)
+	// replacementRes capture the replacement a deprecation points to:
+	// "use X instead", "in favor of X", "replaced by X".
+	replacementRes = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\buse\s+((?:[^\s,;()]+\s+){0,3}?[^\s,;()]+)\s+instead\b`),
+		regexp.MustCompile(`(?i)\b(?:in\s+favou?r\s+of|replaced\s+by|superseded\s+by|please\s+use)\s+([^\s,;()]+)`),
+	}
+)
+
+// parseDeprecation reports whether text (a flag or command description,
+// or a line of help output) marks its subject as deprecated, and the
+// replacement it suggests, if any.
+func parseDeprecation(text string) (deprecated bool, replacedBy string) {
+	if !deprecatedRe.MatchString(text) && !deprecatedCapsRe.MatchString(text) {
+		return false, ""
+	}
+	for _, re := range replacementRes {
+		if m := re.FindStringSubmatch(text); m != nil {
+			return true, strings.TrimRight(strings.Trim(m[1], "'\"`"), ".:")
+		}
+	}
+	return true, ""
+}
+
+// markDeprecatedChildren marks the children of node that its help listed
+// as deprecated; deprecated maps their names to replacements.
+func markDeprecatedChildren(node *models.Node, deprecated map[string]string) {
+	for _, c := range node.Children {
+		if c.Virtual {
+			markDeprecatedChildren(c, deprecated)
+			continue
+		}
+		if replacedBy, ok := deprecated[c.Name]; ok && !c.Deprecated {
+			c.Deprecated, c.ReplacedBy = true, replacedBy
+		}
+	}
+}
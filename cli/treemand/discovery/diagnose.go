@@ -0,0 +1,53 @@
+package discovery
+
+import (
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// Diagnose collects the problems met while discovering root's tree: nodes
+// whose help could not be fetched or timed out, and help output that looks
+// wrong — identical to the parent's or a sibling's, or yielding nothing
+// treemand could parse — and the values strategies disagreed on. Stubs were
+// never probed and are not reported, but a run that ran out of time before
+// reaching them is.
+func Diagnose(root *models.Node) []models.Diagnostic {
+	var diags []models.Diagnostic
+	if root.Incomplete {
+		diags = append(diags, models.Diagnostic{Command: root.FullCommand(), Kind: models.DiagTimeout,
+			Message: "discovery ran out of time; the commands it did not reach are stubs"})
+	}
+	diagnose(root, nil, &diags)
+	return diags
+}
+
+func diagnose(n, parent *models.Node, diags *[]models.Diagnostic) {
+	add := func(kind, msg string) {
+		*diags = append(*diags, models.Diagnostic{Command: n.FullCommand(), Kind: kind, Message: msg})
+	}
+	switch {
+	case n.Stub || n.Virtual:
+	case n.DiscoveryErr != "":
+		kind := models.DiagError
+		if strings.Contains(n.DiscoveryErr, errHelpTimeout.Error()) {
+			kind = models.DiagTimeout
+		}
+		add(kind, n.DiscoveryErr)
+	case len(n.Dedup) > 0:
+		for _, note := range n.Dedup {
+			add(models.DiagAnomaly, note)
+		}
+	case parent != nil && n.HelpText != "" && n.HelpText == parent.HelpText:
+		add(models.DiagAnomaly, "help identical to parent")
+	case n.HelpText != "" && n.Description == "" && len(n.Flags) == 0 &&
+		len(n.Positionals) == 0 && len(n.Children) == 0:
+		add(models.DiagAnomaly, "nothing could be parsed from the help output")
+	}
+	for _, note := range n.Conflicts {
+		add(models.DiagConflict, note)
+	}
+	for _, c := range n.Children {
+		diagnose(c, n, diags)
+	}
+}
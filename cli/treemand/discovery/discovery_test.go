@@ -2,8 +2,15 @@ package discovery_test
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aallbrig/treemand/discovery"
 	"github.com/aallbrig/treemand/models"
@@ -76,6 +83,92 @@ func TestMerge_basic(t *testing.T) {
 	}
 }
 
+func TestMerge_negatable(t *testing.T) {
+	help := &models.Node{Name: "tool", Flags: []models.Flag{{Name: "--color", ValueType: "bool"}}}
+	comp := &models.Node{Name: "tool", Flags: []models.Flag{{Name: "--no-color"}, {Name: "--no-pager"}}}
+	merged := discovery.Merge([]*models.Node{help, comp})
+	var names []string
+	for _, f := range merged.Flags {
+		names = append(names, fmt.Sprintf("%s:%v", f.Name, f.Negatable))
+	}
+	if got := strings.Join(names, " "); got != "--color:true --no-pager:false" {
+		t.Errorf("merged flags = %s, want --color:true --no-pager:false", got)
+	}
+}
+
+func TestMergeWith_policies(t *testing.T) {
+	help := &models.Node{Name: "tool", Description: "A tool", Sources: []string{"help"}, Flags: []models.Flag{
+		{Name: "--timeout", ValueType: "bool", Description: "Time limit", Sources: []string{"help"}},
+		{Name: "--out", ValueType: "string", Sources: []string{"help"}},
+		{Name: "--level", ValueType: "LEVEL", Sources: []string{"help"}},
+	}}
+	man := &models.Node{Name: "tool", Description: "A tool for testing merges", Sources: []string{"man"}, Flags: []models.Flag{
+		{Name: "--timeout", ValueType: "duration", ValueStyle: models.ValueStyleEquals, Description: "time limit", Sources: []string{"man"}},
+		{Name: "--out", ValueType: "file", Sources: []string{"man"}},
+		{Name: "--level", ValueType: "level", Sources: []string{"man"}},
+	}}
+	tests := []struct {
+		policy, desc, outType string
+		conflicts             []string
+	}{
+		{discovery.MergeFirst, "A tool", "string", []string{
+			`description: "A tool", man says "A tool for testing merges"; kept "A tool"`,
+			`--timeout type: "bool", man says "duration"; kept "duration"`,
+			`--out type: "string", man says "file"; kept "string"`,
+		}},
+		{discovery.MergeRicher, "A tool for testing merges", "file", []string{
+			`description: "A tool", man says "A tool for testing merges"; kept "A tool for testing merges"`,
+			`--timeout type: "bool", man says "duration"; kept "duration"`,
+			`--out type: "string", man says "file"; kept "file"`,
+		}},
+	}
+	for _, tt := range tests {
+		merged := discovery.MergeWith([]*models.Node{help, man}, tt.policy)
+		if merged.Description != tt.desc {
+			t.Errorf("%s: Description = %q, want %q", tt.policy, merged.Description, tt.desc)
+		}
+		// A bool from one strategy yields to a value type under any policy.
+		if f := merged.Flags[0]; f.ValueType != "duration" || f.ValueStyle != models.ValueStyleEquals {
+			t.Errorf("%s: --timeout = %s (%s), want duration (equals)", tt.policy, f.ValueType, f.ValueStyle)
+		}
+		if got := merged.Flags[1].ValueType; got != tt.outType {
+			t.Errorf("%s: --out type = %q, want %q", tt.policy, got, tt.outType)
+		}
+		if strings.Join(merged.Conflicts, "\n") != strings.Join(tt.conflicts, "\n") {
+			t.Errorf("%s: Conflicts:\ngot  %q\nwant %q", tt.policy, merged.Conflicts, tt.conflicts)
+		}
+	}
+	if len(help.Conflicts) != 0 {
+		t.Errorf("merging noted conflicts on its input: %v", help.Conflicts)
+	}
+	if err := discovery.CheckMergePolicy("newest"); err == nil || !strings.Contains(err.Error(), "richer") {
+		t.Errorf("CheckMergePolicy(newest) = %v, want an error listing the policies", err)
+	}
+}
+
+func TestMerge_confidence(t *testing.T) {
+	help := &models.Node{
+		Name:  "tool",
+		Flags: []models.Flag{{Name: "--verbose", Confidence: 0.4}, {Name: "--style", Confidence: 0.4}},
+		Children: []*models.Node{
+			{Name: "run", Confidence: 0.4},
+			{Name: "bare", Confidence: 0.4},
+		},
+	}
+	other := &models.Node{
+		Name:     "tool",
+		Flags:    []models.Flag{{Name: "--verbose"}, {Name: "--style", Confidence: 0.8}},
+		Children: []*models.Node{{Name: "run"}},
+	}
+	merged := discovery.Merge([]*models.Node{help, other})
+	if got := merged.Flags; got[0].Confidence != 0 || got[1].Confidence != 0.8 {
+		t.Errorf("flag confidences = %v, %v, want 0 (unscored) and 0.8", got[0].Confidence, got[1].Confidence)
+	}
+	if run, bare := merged.Find("run"), merged.Find("bare"); run.Doubtful() || !bare.Doubtful() {
+		t.Errorf("run confirmed by an unscored source should be trusted, bare still doubtful")
+	}
+}
+
 func TestMerge_empty(t *testing.T) {
 	if r := discovery.Merge(nil); r != nil {
 		t.Error("expected nil for empty merge")
@@ -129,6 +222,103 @@ func TestRun_withMock(t *testing.T) {
 	}
 }
 
+func TestRun_recordsSources(t *testing.T) {
+	help := &MockDiscoverer{name: "help", node: &models.Node{
+		Name:     "tool",
+		Flags:    []models.Flag{{Name: "--verbose"}},
+		Children: []*models.Node{{Name: "run"}},
+	}}
+	man := &MockDiscoverer{name: "man", node: &models.Node{
+		Name:     "tool",
+		Flags:    []models.Flag{{Name: "--verbose"}, {Name: "--color"}},
+		Children: []*models.Node{{Name: "run", Flags: []models.Flag{{Name: "--fast"}}}, {Name: "serve"}},
+	}}
+	none := &MockDiscoverer{name: "completions"}
+	node, err := discovery.Run(context.Background(), []discovery.Discoverer{help, none, man}, "tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{
+		"tool":           strings.Join(node.Sources, ","),
+		"tool --verbose": strings.Join(node.Flags[0].Sources, ","),
+		"tool --color":   strings.Join(node.Flags[1].Sources, ","),
+		"run":            strings.Join(node.Find("run").Sources, ","),
+		"run --fast":     strings.Join(node.Find("run").Flags[0].Sources, ","),
+		"serve":          strings.Join(node.Find("serve").Sources, ","),
+	}
+	want := map[string]string{
+		"tool": "help,man", "tool --verbose": "help,man", "tool --color": "man",
+		"run": "help,man", "run --fast": "man", "serve": "man",
+	}
+	for k, w := range want {
+		if got[k] != w {
+			t.Errorf("%s: Sources = %q, want %q", k, got[k], w)
+		}
+	}
+	if len(help.node.Flags[0].Sources) != 1 {
+		t.Errorf("merging changed the help tree's own sources: %v", help.node.Flags[0].Sources)
+	}
+}
+
+// gateDiscoverer is a MockDiscoverer that closes started as it runs, then
+// waits for wait to close before returning its tree.
+type gateDiscoverer struct {
+	MockDiscoverer
+	started, wait chan struct{}
+}
+
+func (g *gateDiscoverer) Discover(ctx context.Context, cli string, args []string) (*models.Node, error) {
+	if g.started != nil {
+		close(g.started)
+	}
+	if g.wait != nil {
+		select {
+		case <-g.wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return g.MockDiscoverer.Discover(ctx, cli, args)
+}
+
+func TestRunStream_concurrentInOrder(t *testing.T) {
+	manStarted, release := make(chan struct{}), make(chan struct{})
+	// help only finishes once man has started, which it would not if the
+	// strategies ran one after another.
+	help := &gateDiscoverer{MockDiscoverer: MockDiscoverer{name: "help", node: &models.Node{
+		Name: "tool", Description: "from help",
+	}}, wait: manStarted}
+	man := &gateDiscoverer{MockDiscoverer: MockDiscoverer{name: "man", node: &models.Node{
+		Name: "tool", Description: "from the man page", Children: []*models.Node{{Name: "run"}},
+	}}, started: manStarted}
+	completions := &gateDiscoverer{MockDiscoverer: MockDiscoverer{name: "completions", node: &models.Node{
+		Name: "tool", Children: []*models.Node{{Name: "serve"}},
+	}}, wait: release}
+
+	var partials []string
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	node, err := discovery.RunStream(ctx, []discovery.Discoverer{help, man, completions}, "tool", nil, discovery.MergeFirst,
+		func(n *models.Node) {
+			partials = append(partials, strings.Join(n.Sources, ","))
+			if len(partials) == 1 {
+				close(release)
+			}
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Description != "from help" {
+		t.Errorf("Description = %q, want the first strategy's", node.Description)
+	}
+	if node.Find("run") == nil || node.Find("serve") == nil {
+		t.Errorf("merged tree lacks commands: %v", node.Children)
+	}
+	if want := []string{"help,man", "help,man,completions"}; !slices.Equal(partials, want) {
+		t.Errorf("partial trees from %v, want %v", partials, want)
+	}
+}
+
 func TestParseHelpOutput_flags(t *testing.T) {
 	helpText := `Usage: git [options] <command>
 
@@ -321,6 +511,84 @@ func TestBuildDiscoverersWithThreshold_completions(t *testing.T) {
 	}
 }
 
+// ── HiddenDiscoverer ──────────────────────────────────────────────────────────
+
+const hiddenCLIScript = `case "$1 $2" in
+  "help -a")      printf 'All commands:\n\nCommands:\n  run     run a job\n  debug   debug a job\n' ;;
+  "--help-all "*) echo "error: unknown flag: --help-all"; exit 1 ;;
+  "__complete "*) printf 'run\tRun a job\nsecret\tInternal\n:4\n'; echo "Completion ended with directive: ShellCompDirectiveNoFileComp" >&2 ;;
+  *)              printf 'hidecli runs jobs\n\nCommands:\n  run     run a job\n' ;;
+esac
+`
+
+func TestHiddenDiscoverer(t *testing.T) {
+	fakeCLI(t, "hidecli", hiddenCLIScript)
+	ctx := context.Background()
+
+	got, err := discovery.NewHiddenDiscoverer().Discover(ctx, "hidecli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("expected hidden commands")
+	}
+	if names := strings.Join(nodeNames(got.Children), ","); names != "debug,secret" {
+		t.Errorf("hidden children = %s, want debug,secret", names)
+	}
+	for _, c := range got.Children {
+		if !c.Hidden || !c.Stub {
+			t.Errorf("%s: Hidden=%v Stub=%v, want both", c.Name, c.Hidden, c.Stub)
+		}
+	}
+
+	merged, err := discovery.Run(ctx, discovery.BuildDiscoverers([]string{"help", "hidden"}, 1), "hidecli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run := merged.Find("run"); run == nil || run.Hidden {
+		t.Errorf("run is in the help and should not be hidden, got %+v", run)
+	}
+	if debug := merged.Find("debug"); debug == nil || !debug.Hidden {
+		t.Errorf("debug should be merged in as hidden, got %+v", debug)
+	}
+}
+
+func TestHiddenDiscoverer_none(t *testing.T) {
+	fakeCLI(t, "plaincli", fakeCLIScript)
+	got, err := discovery.NewHiddenDiscoverer().Discover(context.Background(), "plaincli", nil)
+	if err != nil || got != nil {
+		t.Errorf("Discover() = %+v, %v; want nil, nil for a CLI without hidden commands", got, err)
+	}
+}
+
+func TestHelpDiscoverer_docopt(t *testing.T) {
+	// Every command prints the root help, as docopt tools do, so the tree
+	// is built from the root's without probing the commands.
+	fakeCLI(t, "naval_fate", "cat <<'EOF'\n"+mockDocoptHelp+"EOF\necho \"$*\" >> \"$(dirname \"$0\")/calls\"\n")
+	root, err := discovery.NewHelpDiscoverer(3).Discover(context.Background(), "naval_fate", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	move := root.FindPath([]string{"ship", "move"})
+	if move == nil {
+		t.Fatalf("ship move not found in %v", nodeNames(root.Children))
+	}
+	if got := strings.Join(move.FullPath, " "); got != "naval_fate ship move" {
+		t.Errorf("FullPath = %q, want naval_fate ship move", got)
+	}
+	bin, err := exec.LookPath("naval_fate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls, err := os.ReadFile(filepath.Join(filepath.Dir(bin), "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(calls), "ship") || strings.Contains(string(calls), "mine") {
+		t.Errorf("commands were probed, want only the root help:\n%s", calls)
+	}
+}
+
 func nodeNames(nodes []*models.Node) []string {
 	names := make([]string, len(nodes))
 	for i, n := range nodes {
@@ -328,3 +596,667 @@ func nodeNames(nodes []*models.Node) []string {
 	}
 	return names
 }
+
+func TestHashHelp_ignoresCosmeticDifferences(t *testing.T) {
+	a := discovery.HashHelp("Usage: foo\n  --bar   do bar  \n")
+	b := discovery.HashHelp("\x1b[1mUsage: foo\x1b[0m\n  --bar   do bar\n\n")
+	if a == "" || a != b {
+		t.Errorf("HashHelp mismatch: %q vs %q", a, b)
+	}
+	if discovery.HashHelp("") != "" {
+		t.Error("HashHelp(\"\") should be empty")
+	}
+}
+
+func dupTree() *models.Node {
+	return &models.Node{
+		Name: "cli", HelpHash: "p",
+		Children: []*models.Node{
+			{Name: "real", HelpHash: "a", Children: []*models.Node{{Name: "deep"}}},
+			{Name: "bogus", HelpHash: "p", Children: []*models.Node{{Name: "x"}}},
+			{Name: "copy", HelpHash: "a", Children: []*models.Node{{Name: "deep"}}},
+			{Name: "other", HelpHash: "b"},
+		},
+	}
+}
+
+func TestDedupe_marksAliases(t *testing.T) {
+	root := dupTree()
+	discovery.Dedupe(root, false)
+	if len(root.Children) != 4 {
+		t.Fatalf("children = %d, want 4", len(root.Children))
+	}
+	bogus := root.Find("bogus")
+	if len(bogus.Children) != 0 || len(bogus.Dedup) == 0 {
+		t.Errorf("parent-identical child not collapsed: %+v", bogus)
+	}
+	cp := root.Find("copy")
+	if cp.AliasOf != "real" {
+		t.Errorf("AliasOf = %q, want real", cp.AliasOf)
+	}
+	if len(cp.Children) != 0 {
+		t.Error("alias should lose its subtree")
+	}
+	if len(root.Find("real").Children) != 1 {
+		t.Error("original sibling must keep its children")
+	}
+}
+
+func TestDedupe_prune(t *testing.T) {
+	root := dupTree()
+	discovery.Dedupe(root, true)
+	var names []string
+	for _, c := range root.Children {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "real,other" {
+		t.Errorf("children = %v, want [real other]", names)
+	}
+	if len(root.Dedup) != 2 {
+		t.Errorf("parent Dedup notes = %v, want 2 entries", root.Dedup)
+	}
+}
+
+func TestHelpDiscoverer_dedupesParentIdenticalHelp(t *testing.T) {
+	fakeCLI(t, "parcli", `case "$1" in
+  real)  printf 'Usage: parcli real\n\nreal things\n' ;;
+  *)     printf 'parcli does things\n\nCommands:\n  real    real things\n  bogus   not really\n' ;;
+esac
+`)
+	d := discovery.NewHelpDiscoverer(2)
+	root, err := d.Discover(context.Background(), "parcli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bogus := root.Find("bogus")
+	if bogus == nil || bogus.HelpHash == "" || bogus.HelpHash != root.HelpHash {
+		t.Fatalf("a command printing its parent's help should hash it, got %+v", bogus)
+	}
+	if !slices.Contains(bogus.Dedup, "help identical to parent") {
+		t.Errorf("Dedup = %v, want the parent-identical note", bogus.Dedup)
+	}
+
+	d = discovery.NewHelpDiscoverer(2)
+	d.PruneDuplicates = true
+	if root, err = d.Discover(context.Background(), "parcli", nil); err != nil {
+		t.Fatal(err)
+	}
+	if root.Find("bogus") != nil || root.Find("real") == nil {
+		t.Errorf("PruneDuplicates should drop bogus and keep real, got %v", root.Children)
+	}
+}
+
+func TestRun_skipsNilTrees(t *testing.T) {
+	empty := &MockDiscoverer{name: "man"}
+	mock := &MockDiscoverer{name: "mock", node: &models.Node{Name: "testcli"}}
+	node, err := discovery.Run(context.Background(), []discovery.Discoverer{empty, mock}, "testcli")
+	if err != nil || node == nil || node.Name != "testcli" {
+		t.Fatalf("Run() = %v, %v", node, err)
+	}
+}
+
+// fakeCLI writes an executable shell script named name into a temp dir that
+// is prepended to PATH, so discovery can probe it like a real binary.
+func fakeCLI(t *testing.T, name, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+const fakeCLIScript = `case "$1" in
+  sub) printf 'Usage: fakecli sub [flags]\n\nFlags:\n  --thing   do the thing\n' ;;
+  *)   printf 'fakecli does things\n\nCommands:\n  sub   the sub command\n' ;;
+esac
+`
+
+func TestHelpDiscoverer_PreviousReusesUnchangedSubtree(t *testing.T) {
+	fakeCLI(t, "fakecli", fakeCLIScript)
+	ctx := context.Background()
+
+	fresh, err := discovery.NewHelpDiscoverer(2).Discover(ctx, "fakecli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := fresh.Find("sub")
+	if sub == nil || sub.HelpHash == "" {
+		t.Fatalf("expected hashed sub node, got %+v", sub)
+	}
+
+	prev := &models.Node{
+		Name: "fakecli", FullPath: []string{"fakecli"}, HelpHash: "stale",
+		Children: []*models.Node{{
+			Name: "sub", FullPath: []string{"fakecli", "sub"},
+			HelpHash: sub.HelpHash, Description: "from cache", Discovered: true,
+		}},
+	}
+	d := discovery.NewHelpDiscoverer(2)
+	d.Previous = prev
+	got, err := d.Discover(ctx, "fakecli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Description != "fakecli does things" {
+		t.Errorf("root should be re-parsed, Description = %q", got.Description)
+	}
+	if s := got.Find("sub"); s == nil || s.Description != "from cache" {
+		t.Errorf("unchanged sub should be reused from Previous, got %+v", s)
+	}
+}
+
+func TestHelpDiscoverer_PreviousProbesBelowUnchangedHelp(t *testing.T) {
+	fakeCLI(t, "fakecli", fakeCLIScript)
+	ctx := context.Background()
+
+	fresh, err := discovery.NewHelpDiscoverer(2).Discover(ctx, "fakecli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := &models.Node{
+		Name: "fakecli", FullPath: []string{"fakecli"}, HelpHash: fresh.HelpHash,
+		HelpText: fresh.HelpText, Description: "from cache", Discovered: true,
+		Children: []*models.Node{{
+			Name: "sub", FullPath: []string{"fakecli", "sub"},
+			HelpHash: "stale", Description: "stale", Discovered: true, Confidence: 0.5,
+		}},
+	}
+	d := discovery.NewHelpDiscoverer(2)
+	d.Previous = prev
+	got, err := d.Discover(ctx, "fakecli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Description != "from cache" {
+		t.Errorf("unchanged root should keep its parse from Previous, Description = %q", got.Description)
+	}
+	s := got.Find("sub")
+	if s == nil || s.HelpHash == "stale" || len(s.Flags) == 0 || s.Flags[0].Name != "--thing" {
+		t.Fatalf("changed sub below an unchanged root should be rediscovered, got %+v", s)
+	}
+	if s.Confidence != 0.5 {
+		t.Errorf("sub should keep the confidence its parent's help gave it, got %v", s.Confidence)
+	}
+	if prev.Children[0].HelpHash != "stale" {
+		t.Error("Previous should be left untouched")
+	}
+}
+
+func TestHelpDiscoverer_PreviousProbesFormerlyParentIdentical(t *testing.T) {
+	fakeCLI(t, "fakecli", fakeCLIScript)
+	ctx := context.Background()
+
+	fresh, err := discovery.NewHelpDiscoverer(2).Discover(ctx, "fakecli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sub used to print its parent's help, as it was cached before its
+	// help was hashed.
+	prev := &models.Node{
+		Name: "fakecli", FullPath: []string{"fakecli"}, HelpHash: fresh.HelpHash,
+		HelpText: fresh.HelpText, Discovered: true,
+		Children: []*models.Node{{
+			Name: "sub", FullPath: []string{"fakecli", "sub"},
+			HelpText: fresh.HelpText, Discovered: true,
+		}},
+	}
+	d := discovery.NewHelpDiscoverer(2)
+	d.Previous = prev
+	got, err := d.Discover(ctx, "fakecli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := got.Find("sub")
+	if s == nil || s.HelpText == fresh.HelpText || len(s.Flags) == 0 || s.Flags[0].Name != "--thing" {
+		t.Errorf("sub's changed help should be probed again, got %+v", s)
+	}
+}
+
+// mapStore is an in-memory discovery.HelpStore.
+type mapStore struct {
+	mu    sync.Mutex
+	texts map[string]string
+}
+
+func (m *mapStore) LoadHelp(path []string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.texts[strings.Join(path, " ")]
+	return t, ok
+}
+
+func (m *mapStore) SaveHelp(path []string, help string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.texts[strings.Join(path, " ")] = help
+}
+
+func TestHelpDiscoverer_StoreReadThrough(t *testing.T) {
+	fakeCLI(t, "fakecli", fakeCLIScript)
+	ctx := context.Background()
+
+	store := &mapStore{texts: map[string]string{}}
+	d := discovery.NewHelpDiscoverer(2)
+	d.Store = store
+	if _, err := d.Discover(ctx, "fakecli", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.texts["sub"]; !ok {
+		t.Fatalf("expected sub help to be saved, store = %v", store.texts)
+	}
+
+	store.texts["sub"] = "Usage: fakecli sub\n\nstored description\n"
+	got, err := d.Discover(ctx, "fakecli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := got.Find("sub"); s == nil || !strings.Contains(s.HelpText, "stored description") {
+		t.Errorf("sub should come from the store, got %+v", s)
+	}
+
+	d.Fresh = true
+	got, err = d.Discover(ctx, "fakecli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := got.Find("sub"); s == nil || strings.Contains(s.HelpText, "stored description") {
+		t.Errorf("Fresh should re-probe sub, got %+v", s)
+	}
+	if strings.Contains(store.texts["sub"], "stored description") {
+		t.Error("Fresh discovery should overwrite stored help")
+	}
+}
+
+func TestHelpDiscoverer_depthLimitLeavesStubs(t *testing.T) {
+	fakeCLI(t, "fakecli", fakeCLIScript)
+	d := discovery.NewHelpDiscoverer(1)
+	d.MaxDepth = 0
+	got, err := d.Discover(context.Background(), "fakecli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := got.Find("sub")
+	if sub == nil {
+		t.Fatal("expected sub below the depth limit to be kept as a stub")
+	}
+	if !sub.Stub || sub.Discovered {
+		t.Errorf("sub should be an undiscovered stub, got %+v", sub)
+	}
+	if len(sub.FullPath) != 2 || sub.FullPath[1] != "sub" {
+		t.Errorf("stub FullPath = %v", sub.FullPath)
+	}
+}
+
+func TestHelpDiscoverer_OnNodeStreamsEveryNode(t *testing.T) {
+	fakeCLI(t, "fakecli", fakeCLIScript)
+	for _, depth := range []int{0, 2} {
+		var paths []string
+		d := discovery.NewHelpDiscoverer(2)
+		d.MaxDepth = depth
+		d.OnNode = func(n *models.Node) {
+			if len(n.Children) > 0 {
+				t.Errorf("%v emitted after its children were attached", n.FullPath)
+			}
+			paths = append(paths, strings.Join(n.FullPath, " "))
+		}
+		if _, err := d.Discover(context.Background(), "fakecli", nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(paths, ","); got != "fakecli,fakecli sub" {
+			t.Errorf("depth %d: OnNode saw %q, want fakecli,fakecli sub", depth, got)
+		}
+	}
+}
+
+func TestGitCompleter(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "a.txt")
+	git("commit", "-q", "-m", "init")
+	git("branch", "feature")
+	git("remote", "add", "origin", "https://example.com/r.git")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new file.txt"), []byte("c"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := &discovery.GitCompleter{Dir: dir}
+	ctx := context.Background()
+	cases := []struct {
+		name, toComplete, want string
+	}{
+		{"<branch>", "", "feature main"},
+		{"--onto", "fe", "feature"},
+		{"repository", "", "origin"},
+		{"pathspec", "", "a.txt new file.txt"},
+		{"--message", "", ""},
+	}
+	for _, c := range cases {
+		got, err := g.CompleteValues(ctx, nil, c.toComplete, c.name)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("%s %q: got %q, want %q", c.name, c.toComplete, strings.Join(got, " "), c.want)
+		}
+	}
+	if _, ok := discovery.NewValueCompleter("git").(*discovery.GitCompleter); !ok {
+		t.Error("NewValueCompleter(git) should return the GitCompleter")
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	root := &models.Node{Name: "cli", FullPath: []string{"cli"}, HelpText: "cli help", Description: "a cli", Children: []*models.Node{
+		{Name: "slow", FullPath: []string{"cli", "slow"}, DiscoveryErr: "could not get help: timed out"},
+		{Name: "bad", FullPath: []string{"cli", "bad"}, DiscoveryErr: "could not get help: no help output from cli"},
+		{Name: "same", FullPath: []string{"cli", "same"}, HelpText: "cli help", Description: "a cli"},
+		{Name: "alias", FullPath: []string{"cli", "alias"}, HelpText: "x", Dedup: []string{`help identical to sibling "ok"`}},
+		{Name: "blank", FullPath: []string{"cli", "blank"}, HelpText: "???"},
+		{Name: "ok", FullPath: []string{"cli", "ok"}, HelpText: "x", Description: "fine"},
+		{Name: "stub", FullPath: []string{"cli", "stub"}, Stub: true},
+		{Name: "both", FullPath: []string{"cli", "both"}, HelpText: "x", Description: "fine", Conflicts: []string{`--n type: "bool", man says "int"; kept "int"`}},
+	}}
+	var got []string
+	for _, d := range discovery.Diagnose(root) {
+		got = append(got, d.Kind+" "+d.Command+": "+d.Message)
+	}
+	want := []string{
+		"timeout cli slow: could not get help: timed out",
+		"error cli bad: could not get help: no help output from cli",
+		"anomaly cli same: help identical to parent",
+		`anomaly cli alias: help identical to sibling "ok"`,
+		"anomaly cli blank: nothing could be parsed from the help output",
+		`conflict cli both: --n type: "bool", man says "int"; kept "int"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Diagnose:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestHelpDiscoverer_RetryRecordsAttempts(t *testing.T) {
+	// sub fails on every invocation of its first attempt (--help, -h and
+	// "sub help"), then prints its help.
+	fakeCLI(t, "flakycli", `case "$1" in
+  sub)
+    n=$(($(cat "$FLAKY_COUNT" 2>/dev/null || echo 0) + 1))
+    echo "$n" > "$FLAKY_COUNT"
+    [ "$n" -gt 3 ] || exit 1
+    printf 'Usage: flakycli sub [flags]\n\nFlags:\n  --thing   do the thing\n' ;;
+  help) exit 1 ;;
+  *) printf 'flakycli does things\n\nCommands:\n  sub   the sub command\n' ;;
+esac
+`)
+	for _, tc := range []struct {
+		retries  int
+		attempts int
+		execs    int // 4 per failed attempt (--help, -h, "sub help", "help sub"), 2 per good one
+		wantErr  bool
+	}{
+		{retries: 0, attempts: 1, execs: 4, wantErr: true},
+		{retries: 2, attempts: 2, execs: 6},
+	} {
+		t.Setenv("FLAKY_COUNT", filepath.Join(t.TempDir(), "count"))
+		d := discovery.NewHelpDiscoverer(2)
+		d.Retry = discovery.RetryPolicy{Retries: tc.retries, Backoff: time.Millisecond}
+		root, err := d.Discover(context.Background(), "flakycli", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root.Probe == nil || root.Probe.Attempts != 1 {
+			t.Errorf("retries=%d: root probe = %+v, want 1 attempt", tc.retries, root.Probe)
+		}
+		sub := root.Find("sub")
+		if sub == nil {
+			t.Fatalf("retries=%d: sub not discovered", tc.retries)
+		}
+		if (sub.DiscoveryErr != "") != tc.wantErr {
+			t.Errorf("retries=%d: sub DiscoveryErr = %q", tc.retries, sub.DiscoveryErr)
+		}
+		if sub.Probe == nil || sub.Probe.Attempts != tc.attempts || sub.Probe.Execs != tc.execs || sub.Probe.Duration <= 0 {
+			t.Errorf("retries=%d: sub probe = %+v, want %d attempts and %d execs", tc.retries, sub.Probe, tc.attempts, tc.execs)
+		}
+	}
+}
+
+func TestHelpDiscoverer_KillsCommandWaitingForInput(t *testing.T) {
+	fakeCLI(t, "promptcli", `case "$1" in
+  setup) printf 'Continue? [y/N] '; exec sleep 30 ;;
+  *) printf 'promptcli does things\n\nCommands:\n  setup   set things up\n' ;;
+esac
+`)
+	d := discovery.NewHelpDiscoverer(2)
+	d.Timeout = 20 * time.Second
+	d.Retry.Retries = 2
+	start := time.Now()
+	root, err := d.Discover(context.Background(), "promptcli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("discovery took %v; the prompting command was not killed", elapsed)
+	}
+	setup := root.Find("setup")
+	if setup == nil || !setup.Interactive || !strings.Contains(setup.DiscoveryErr, "waiting for input") {
+		t.Fatalf("setup = %+v, want an interactive node", setup)
+	}
+	if setup.Probe == nil || setup.Probe.Attempts != 1 {
+		t.Errorf("setup probe = %+v, want 1 attempt (prompts are not retried)", setup.Probe)
+	}
+	if root.Interactive {
+		t.Error("root marked interactive")
+	}
+}
+
+func TestHelpDiscoverer_TimeoutIsPerCommand(t *testing.T) {
+	// Every command takes 0.4s; "slow" never finishes. Timeout covers one
+	// command but not "alpha" and "beta" together, and must not be shared with
+	// the siblings or the subtree of a command.
+	fakeCLI(t, "chaincli", `sleep 0.4
+case "$1" in
+  slow) exec sleep 30 ;;
+  alpha) case "$2" in
+       beta) printf 'Usage: chaincli alpha beta\n\nFlags:\n  --x   the x flag\n' ;;
+       *) printf 'the alpha command\n\nCommands:\n  beta   the beta command\n' ;;
+     esac ;;
+  *) printf 'chaincli does things\n\nCommands:\n  alpha  the alpha command\n  slow   never answers\n' ;;
+esac
+`)
+	d := discovery.NewHelpDiscoverer(3)
+	d.Timeout = 700 * time.Millisecond
+	root, err := d.Discover(context.Background(), "chaincli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slow := root.Find("slow"); slow == nil || !strings.Contains(slow.DiscoveryErr, "timed out") {
+		t.Errorf("slow = %+v, want a timeout", slow)
+	}
+	a := root.Find("alpha")
+	if a == nil || a.DiscoveryErr != "" {
+		t.Fatalf("alpha = %+v, want it discovered", a)
+	}
+	if b := a.Find("beta"); b == nil || b.DiscoveryErr != "" || len(b.Flags) == 0 {
+		t.Errorf("beta = %+v, want it discovered with its own time budget", b)
+	}
+}
+
+func TestRun_deadlineReturnsPartialTree(t *testing.T) {
+	// "slow" outlasts the run, not its own command timeout.
+	fakeCLI(t, "partialcli", `case "$1" in
+  slow) exec sleep 30 ;;
+  fast) printf 'Usage: partialcli fast\n\nFlags:\n  --quick   be quick\n' ;;
+  *) printf 'partialcli does things\n\nCommands:\n  fast   answers at once\n  slow   never answers\n' ;;
+esac
+`)
+	d := discovery.NewHelpDiscoverer(2)
+	d.Timeout = 20 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	root, err := discovery.Run(ctx, []discovery.Discoverer{d}, "partialcli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !root.Incomplete || root.DiscoveryErr != "" {
+		t.Errorf("root Incomplete = %v, DiscoveryErr = %q; want an incomplete, discovered root", root.Incomplete, root.DiscoveryErr)
+	}
+	if fast := root.Find("fast"); fast == nil || len(fast.Flags) == 0 {
+		t.Errorf("fast = %+v, want it discovered", fast)
+	}
+	if slow := root.Find("slow"); slow == nil || !slow.Stub || slow.DiscoveryErr != "" {
+		t.Errorf("slow = %+v, want a stub left to expand later", slow)
+	}
+	diags := discovery.Diagnose(root)
+	if len(diags) != 1 || diags[0].Kind != models.DiagTimeout || diags[0].Command != "partialcli" {
+		t.Errorf("Diagnose() = %+v, want one timeout for the run", diags)
+	}
+}
+
+func TestHelpDiscoverer_CancelKillsProcessGroup(t *testing.T) {
+	// The help forks a process that would outlive the CLI if only the CLI
+	// itself were killed.
+	mark := filepath.Join(t.TempDir(), "survived")
+	t.Setenv("MARK", mark)
+	fakeCLI(t, "forkcli", `(sleep 1; touch "$MARK") &
+sleep 30
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := discovery.NewHelpDiscoverer(1).Discover(ctx, "forkcli", nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(mark); err == nil {
+		t.Error("a process the CLI started outlived the cancelled discovery")
+	}
+}
+
+func TestParseVia(t *testing.T) {
+	for via, want := range map[string]string{
+		"":                       "",
+		"wsl":                    "wsl --exec",
+		"wsl -d Ubuntu -e":       "wsl -d Ubuntu -e",
+		"docker run alpine/git":  "docker run --rm alpine/git",
+		"podman run --rm -i img": "podman run --rm -i img",
+		"docker exec -i box":     "docker exec -i box",
+		"ssh build-host":         "ssh build-host",
+	} {
+		if got := strings.Join(discovery.ParseVia(via), " "); got != want {
+			t.Errorf("ParseVia(%q) = %q, want %q", via, got, want)
+		}
+	}
+}
+
+func TestWithVia_runsThroughPrefix(t *testing.T) {
+	// viacli is only on the PATH that runner gives the commands it runs,
+	// as a CLI installed in a container is.
+	hidden := t.TempDir()
+	script := "#!/bin/sh\ncase \"$1\" in\n  sub) printf 'Usage: viacli sub\\n\\nFlags:\\n  --deep   go deep\\n' ;;\n" +
+		"  *) printf 'viacli does things\\n\\nCommands:\\n  sub   the sub command\\n' ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(hidden, "viacli"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fakeCLI(t, "runner", `PATH="`+hidden+`:$PATH" exec "$@"`)
+	if discovery.CheckAvailable("viacli") == nil {
+		t.Fatal("viacli is on PATH")
+	}
+	ctx := discovery.WithVia(context.Background(), discovery.ParseVia("runner"))
+	root, err := discovery.NewHelpDiscoverer(2).Discover(ctx, "viacli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub := root.Find("sub"); sub == nil || len(sub.Flags) == 0 {
+		t.Fatalf("sub = %+v, want it discovered through runner", sub)
+	}
+}
+
+// writeFiles creates files under dir, their paths slash-separated.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetectPackage_npm(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"lib/node_modules/@acme/tool/package.json": `{"name": "@acme/tool", "version": "2.1.0", "description": "Acme's tool",
+			"bin": {"acme": "bin/acme.js", "acme-lint": "bin/lint.js"}}`,
+		"lib/node_modules/@acme/tool/bin/acme.js":    "#!/usr/bin/env node\n",
+		"shims/node_modules/@acme/tool/package.json": `{"name": "@acme/tool", "version": "2.2.0", "bin": "cli.js"}`,
+		"shims/acme.cmd": "@ECHO off\r\n\"%dp0%\\node_modules\\@acme\\tool\\cli.js\" %*\r\n",
+	})
+	link := filepath.Join(dir, "acme")
+	if err := os.Symlink(filepath.Join(dir, "lib", "node_modules", "@acme", "tool", "bin", "acme.js"), link); err != nil {
+		t.Skip("symlinks unavailable:", err)
+	}
+
+	pkg, ok := discovery.DetectPackage(link)
+	if !ok {
+		t.Fatal("no package detected behind the npm link")
+	}
+	if pkg.String() != "npm @acme/tool@2.1.0" || pkg.Description != "Acme's tool" || !slices.Equal(pkg.Bins, []string{"acme", "acme-lint"}) {
+		t.Errorf("linked package = %+v", pkg)
+	}
+
+	pkg, ok = discovery.DetectPackage(filepath.Join(dir, "shims", "acme.cmd"))
+	if !ok || pkg.String() != "npm @acme/tool@2.2.0" || !slices.Equal(pkg.Bins, []string{"tool"}) {
+		t.Errorf("shimmed package = %+v, %v", pkg, ok)
+	}
+}
+
+func TestDetectPackage_pipx(t *testing.T) {
+	dir := t.TempDir()
+	venv := "pipx/venvs/black"
+	writeFiles(t, dir, map[string]string{
+		venv + "/pipx_metadata.json": `{"main_package": {"package": "black", "package_version": "24.1.0", "apps": ["black", "blackd"]}}`,
+		venv + "/bin/black":          "#!/usr/bin/python\n",
+		venv + "/lib/python3.12/site-packages/black-24.1.0.dist-info/METADATA": "Metadata-Version: 2.1\nName: black\n" +
+			"Summary: The uncompromising code formatter.\n\nSummary: not a header\n",
+	})
+	link := filepath.Join(dir, "black")
+	if err := os.Symlink(filepath.Join(dir, filepath.FromSlash(venv), "bin", "black"), link); err != nil {
+		t.Skip("symlinks unavailable:", err)
+	}
+	pkg, ok := discovery.DetectPackage(link)
+	if !ok {
+		t.Fatal("no package detected behind the pipx link")
+	}
+	if pkg.String() != "pipx black@24.1.0" || pkg.Description != "The uncompromising code formatter." ||
+		!slices.Equal(pkg.Bins, []string{"black", "blackd"}) {
+		t.Errorf("package = %+v", pkg)
+	}
+}
+
+func TestDetectPackage_plainBinary(t *testing.T) {
+	path, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	if pkg, ok := discovery.DetectPackage(path); ok {
+		t.Errorf("DetectPackage(%s) = %+v, want none", path, pkg)
+	}
+}
@@ -0,0 +1,299 @@
+package discovery
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// Docopt help (common in Python and Rust tools) describes a CLI entirely
+// by its usage patterns, one per line, and a list of options:
+//
+//	Usage:
+//	  naval_fate ship new <name>...
+//	  naval_fate ship <name> move <x> <y> [--speed=<kn>]
+//	  naval_fate mine (set|remove) <x> <y> [--moored | --drifting]
+//	  naval_fate --version
+//
+//	Options:
+//	  -h --help     Show this screen.
+//	  --speed=<kn>  Speed in knots [default: 10].
+//
+// The leading literal words of a pattern are its commands, so the patterns
+// above make the commands "ship new", "ship", "mine set" and "mine remove",
+// each with the positionals and options its patterns use. Running such a
+// tool with "ship --help" prints the same text again, so the commands are
+// built from this one help rather than probed.
+
+var (
+	// docoptLiteralRe matches a command word in a usage pattern.
+	docoptLiteralRe = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+	// defaultRe matches a "[default: x]" note in an option description.
+	defaultRe = regexp.MustCompile(`(?i)\[default:\s*([^\]]*)\]`)
+)
+
+// docoptHelp is the result of parsing docopt help.
+type docoptHelp struct {
+	flags       []models.Flag       // options of the root command
+	positionals []models.Positional // positionals of the root command
+	children    []*models.Node      // commands, with paths relative to the root
+}
+
+// parseDocopt parses lines as docopt help, or returns nil when they are not:
+// docopt help has two or more usage patterns starting with the program
+// name, and no separate list of commands.
+func parseDocopt(lines []string) *docoptHelp {
+	patterns := docoptPatterns(lines)
+	if len(patterns) < 2 {
+		return nil
+	}
+	for _, l := range lines {
+		if detectSection(strings.ToLower(strings.TrimSpace(l))) == secCommands {
+			return nil
+		}
+	}
+	var options []models.Flag
+	for _, l := range lines {
+		if f, ok := parseDocoptOption(l); ok {
+			options = append(options, f)
+		}
+	}
+
+	d := &docoptHelp{}
+	root := &models.Node{}
+	nodes := map[string]*models.Node{"": root}
+	forms := map[*models.Node]int{}
+	used := map[string]bool{} // options some command uses
+	for _, pat := range patterns {
+		items := []*usageNode{parseUsage(pat)}
+		if items[0].kind == usageSeq {
+			items = items[0].children
+		}
+		paths, args, restItems := docoptCommands(items)
+		rest := &usageNode{kind: usageSeq, children: restItems}
+		for _, path := range paths {
+			n := root
+			for i := range path {
+				key := strings.Join(path[:i+1], " ")
+				child := nodes[key]
+				if child == nil {
+					child = &models.Node{Name: path[i], FullPath: append([]string{}, path[:i+1]...), Discovered: true, Confidence: confidenceUsage}
+					nodes[key] = child
+					n.Children = append(n.Children, child)
+				}
+				n = child
+				if i < len(path)-1 && len(args[i]) > 0 {
+					addDocoptPositionals(n, &usageNode{kind: usageSeq, children: args[i]}, false)
+				}
+			}
+			// As with usage lines, positionals only later forms of a
+			// command name are optional.
+			forms[n]++
+			addDocoptPositionals(n, rest, forms[n] > 1)
+			for _, f := range docoptUsedOptions(rest, options) {
+				if !hasFlag(n.Flags, f.Name) {
+					n.Flags = append(n.Flags, f)
+				}
+				if n != root {
+					used[f.Name] = true
+				}
+			}
+		}
+	}
+	// Options no command uses belong to the root.
+	for _, f := range options {
+		if !used[f.Name] && !hasFlag(root.Flags, f.Name) {
+			root.Flags = append(root.Flags, f)
+		}
+	}
+	d.flags, d.positionals, d.children = root.Flags, root.Positionals, root.Children
+	return d
+}
+
+// docoptPatterns returns the usage patterns of lines, without the program
+// name: the rest of the "Usage:" line and the indented lines after it that
+// start with the same program.
+func docoptPatterns(lines []string) []string {
+	var patterns []string
+	prog := ""
+	for i, l := range lines {
+		before, rest, ok := strings.Cut(l, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(before), "usage") {
+			continue
+		}
+		for _, pl := range append([]string{rest}, lines[i+1:]...) {
+			fields := strings.Fields(pl)
+			if len(fields) == 0 {
+				if prog == "" {
+					continue
+				}
+				break
+			}
+			if prog == "" {
+				prog = fields[0]
+			}
+			if fields[0] != prog {
+				break
+			}
+			patterns = append(patterns, strings.Join(fields[1:], " "))
+		}
+		break
+	}
+	return patterns
+}
+
+// addDocoptPositionals adds the positionals pattern names to n. With
+// optional they are all optional, as for later forms of a usage line.
+func addDocoptPositionals(n *models.Node, pattern *usageNode, optional bool) {
+	w := &usageWalker{out: n.Positionals, angled: true}
+	for _, p := range n.Positionals {
+		w.nextGroup = max(w.nextGroup, p.Group)
+	}
+	w.walk(pattern, false, false)
+	for i := len(n.Positionals); i < len(w.out) && optional; i++ {
+		w.out[i].Required = false
+	}
+	n.Positionals = w.out
+}
+
+// docoptCommands splits the items of a pattern into the command paths its
+// leading literal words name — "(set|remove)" names two — and the rest.
+// Positionals between command words ("ship <name> move") belong to the
+// command before them: args[i] holds those after the (i+1)th word.
+func docoptCommands(items []*usageNode) (paths [][]string, args [][]*usageNode, rest []*usageNode) {
+	paths = [][]string{nil}
+	for i := 0; i < len(items); i++ {
+		it := items[i]
+		var words []string
+		switch {
+		case docoptLiteral(it):
+			words = []string{it.text}
+		case it.kind == usageAlt:
+			for _, b := range it.children {
+				if len(b.children) != 1 || !docoptLiteral(b.children[0]) {
+					return paths, args, items[i:]
+				}
+				words = append(words, b.children[0].text)
+			}
+		case len(paths[0]) > 0 && it.kind == usageWord && strings.HasPrefix(it.text, "<"):
+			j := i
+			for j < len(items) && items[j].kind == usageWord && strings.HasPrefix(items[j].text, "<") {
+				j++
+			}
+			if j == len(items) || !docoptLiteral(items[j]) {
+				return paths, args, items[i:]
+			}
+			args[len(args)-1] = items[i:j]
+			i = j - 1
+			continue
+		default:
+			return paths, args, items[i:]
+		}
+		var next [][]string
+		for _, p := range paths {
+			for _, w := range words {
+				next = append(next, append(append([]string{}, p...), w))
+			}
+		}
+		paths = next
+		args = append(args, nil)
+	}
+	return paths, args, nil
+}
+
+// docoptLiteral reports whether a pattern item is a command word.
+func docoptLiteral(n *usageNode) bool {
+	return n.kind == usageWord && !n.repeat && docoptLiteralRe.MatchString(n.text)
+}
+
+// docoptUsedOptions returns the options a pattern names, or all of them
+// for the "[options]" shortcut.
+func docoptUsedOptions(n *usageNode, options []models.Flag) []models.Flag {
+	var used []models.Flag
+	var visit func(n *usageNode)
+	visit = func(n *usageNode) {
+		if n.kind == usageWord {
+			if strings.EqualFold(n.text, "options") {
+				used = append(used, options...)
+			}
+			if !strings.HasPrefix(n.text, "-") || n.text == "--" {
+				return
+			}
+			name, _, _ := strings.Cut(n.text, "=")
+			for _, f := range options {
+				if f.Name == name || (f.ShortName != "" && "-"+f.ShortName == name) {
+					used = append(used, f)
+				}
+			}
+			return
+		}
+		for _, c := range n.children {
+			visit(c)
+		}
+	}
+	visit(n)
+	return used
+}
+
+// parseDocoptOption parses a docopt option line: the option's forms,
+// separated by spaces or commas, each with an optional argument
+// ("-o FILE --output=FILE"), then two spaces and its description.
+func parseDocoptOption(line string) (models.Flag, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "-") {
+		return models.Flag{}, false
+	}
+	spec, desc := trimmed, ""
+	if i := strings.Index(trimmed, "  "); i >= 0 {
+		spec, desc = trimmed[:i], strings.TrimSpace(trimmed[i:])
+	}
+	var f models.Flag
+	for _, tok := range strings.Fields(strings.ReplaceAll(spec, ",", " ")) {
+		name, arg, hasArg := strings.Cut(tok, "=")
+		switch {
+		case strings.HasPrefix(name, "--") && len(name) > 2:
+			f.Name = name
+			if hasArg {
+				f.ValueStyle = models.ValueStyleEquals
+			}
+		case strings.HasPrefix(name, "-") && len(name) == 2:
+			f.ShortName = name[1:]
+		case !strings.HasPrefix(tok, "-"):
+			arg, hasArg = tok, true
+		default:
+			return models.Flag{}, false
+		}
+		if hasArg && f.ValueType == "" {
+			f.ValueType = strings.Trim(arg, "<>")
+		}
+	}
+	switch {
+	case f.Name == "" && f.ShortName == "":
+		return models.Flag{}, false
+	case f.Name == "":
+		f.Name = "-" + f.ShortName
+	}
+	if f.ValueType == "" {
+		f.ValueType = "bool"
+	} else if f.ValueStyle == "" {
+		f.ValueStyle = models.ValueStyleSpace
+	}
+	f.Description = desc
+	if m := defaultRe.FindStringSubmatch(desc); m != nil {
+		f.Default = strings.TrimSpace(m[1])
+	}
+	f.Repeatable = isRepeatable(f)
+	f.Deprecated, f.ReplacedBy = parseDeprecation(desc)
+	f.Confidence = flagConfidence(f, confidenceListed)
+	return f, true
+}
+
+func hasFlag(flags []models.Flag, name string) bool {
+	for _, f := range flags {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
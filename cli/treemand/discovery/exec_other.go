@@ -0,0 +1,19 @@
+//go:build !windows
+
+package discovery
+
+import (
+	"context"
+	"os/exec"
+)
+
+// newCommand is exec.CommandContext.
+func newCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...) //nolint:gosec
+}
+
+// executableNames returns the file names an executable called name may
+// have: name itself.
+func executableNames(name string) []string {
+	return []string{name}
+}
@@ -0,0 +1,73 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// newCommand is exec.CommandContext, except that batch files (.bat, .cmd),
+// as npm and many Windows tools install their commands, are run through
+// cmd.exe the way a terminal runs them.
+func newCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if ext := strings.ToLower(filepath.Ext(name)); ext != ".bat" && ext != ".cmd" {
+		return exec.CommandContext(ctx, name, args...) //nolint:gosec
+	}
+	shell := os.Getenv("COMSPEC")
+	if shell == "" {
+		shell = "cmd.exe"
+	}
+	cmd := exec.CommandContext(ctx, shell) //nolint:gosec
+	// Args is what logs and traces show; CmdLine is what runs.
+	cmd.Args = append([]string{shell, "/D", "/S", "/C", name}, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: batchCmdLine(name, args)}
+	return cmd
+}
+
+// batchCmdLine returns the arguments of cmd.exe running the batch file
+// name with args. With /S, cmd.exe strips the outer quotes and runs the
+// rest as typed: /D /S /C ""C:\Program Files\tool.cmd" --help".
+func batchCmdLine(name string, args []string) string {
+	var b strings.Builder
+	b.WriteString(`/D /S /C "`)
+	b.WriteString(quoteBatchArg(name))
+	for _, a := range args {
+		b.WriteByte(' ')
+		b.WriteString(quoteBatchArg(a))
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// quoteBatchArg quotes s when cmd.exe would split it or read a character
+// of it as an operator.
+func quoteBatchArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"&|<>()^%!,;=") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// executableNames returns the file names an executable called name may
+// have: name itself and, unless it has one already, name with each
+// extension PATHEXT lists (tool.exe, tool.cmd).
+func executableNames(name string) []string {
+	exts := os.Getenv("PATHEXT")
+	if exts == "" {
+		exts = ".com;.exe;.bat;.cmd"
+	}
+	names := []string{name}
+	for _, ext := range strings.Split(exts, ";") {
+		if ext == "" {
+			continue
+		}
+		if strings.EqualFold(filepath.Ext(name), ext) {
+			return []string{name}
+		}
+		names = append(names, name+strings.ToLower(ext))
+	}
+	return names
+}
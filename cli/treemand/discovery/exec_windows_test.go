@@ -0,0 +1,24 @@
+package discovery
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBatchCmdLine(t *testing.T) {
+	got := batchCmdLine(`C:\Program Files\nodejs\tsc.cmd`, []string{"--help", "a&b", `say "hi"`, ""})
+	want := `/D /S /C ""C:\Program Files\nodejs\tsc.cmd" --help "a&b" "say ""hi""" """`
+	if got != want {
+		t.Errorf("batchCmdLine() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExecutableNames(t *testing.T) {
+	t.Setenv("PATHEXT", ".COM;.EXE;.CMD")
+	if got, want := executableNames("tool"), []string{"tool", "tool.com", "tool.exe", "tool.cmd"}; !slices.Equal(got, want) {
+		t.Errorf("executableNames(tool) = %v, want %v", got, want)
+	}
+	if got := executableNames("tool.EXE"); !slices.Equal(got, []string{"tool.EXE"}) {
+		t.Errorf("executableNames(tool.EXE) = %v, want the name alone", got)
+	}
+}
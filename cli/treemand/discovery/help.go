@@ -3,6 +3,7 @@ package discovery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -12,6 +13,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/rs/zerolog/log"
+
 	"github.com/aallbrig/treemand/models"
 )
 
@@ -23,9 +26,62 @@ type Discoverer interface {
 
 // HelpDiscoverer uses --help output to discover subcommands and flags.
 type HelpDiscoverer struct {
-	MaxDepth      int
+	MaxDepth int
+	// Timeout bounds fetching the help of one command, so a slow command
+	// fails alone while its siblings are still discovered. The context
+	// passed to Discover bounds the whole run.
 	Timeout       time.Duration
 	StubThreshold int // max subcommands before creating stubs instead of eager discovery
+	// PruneDuplicates removes subcommands whose help is identical to their
+	// parent's or a sibling's instead of keeping them as aliases.
+	PruneDuplicates bool
+	// Previous is an earlier discovery of the same CLI. When set, any node
+	// whose fresh help text hashes the same as its counterpart in Previous
+	// keeps what was read from that help instead of it being parsed again.
+	// Its subcommands are still probed, as their help may have changed
+	// though their parent's did not.
+	Previous *models.Node
+	// Store, when set, persists the raw help text of every probed command
+	// path. Stored text is reused instead of invoking the CLI unless Fresh
+	// is set, in which case every path is re-probed and the store updated.
+	Store HelpStore
+	Fresh bool
+	// Retry controls how failed or hanging help invocations are retried.
+	// The zero value tries every command once.
+	Retry RetryPolicy
+	// OnNode, when set, is called with each node as soon as its own help
+	// is parsed, before its children are probed, so output can be
+	// streamed while discovery runs. Calls are serialized but come in no
+	// particular order, and precede deduplication and inherited-flag
+	// marking. The node must not be retained or modified.
+	OnNode func(*models.Node)
+	// Profile is the parser profile help is read with; nil detects the
+	// profile of each command's help.
+	Profile *Profile
+
+	mu sync.Mutex // serializes OnNode calls
+}
+
+// RetryPolicy controls retries of help invocations that fail or time out,
+// for CLIs that intermittently do so (network checks, license prompts).
+// All attempts for one command share its HelpDiscoverer.Timeout.
+type RetryPolicy struct {
+	// Retries is how many times a failed attempt is retried.
+	Retries int
+	// Backoff is the wait before the first retry; it doubles for each
+	// further retry.
+	Backoff time.Duration
+	// AttemptTimeout bounds a single attempt so that a hang leaves time
+	// for a retry. 0 lets an attempt use all the time that is left.
+	AttemptTimeout time.Duration
+}
+
+// HelpStore persists raw help text per command path (below the CLI name)
+// so partial discoveries, lazy expansion and refreshes can reuse output
+// that was already fetched.
+type HelpStore interface {
+	LoadHelp(path []string) (string, bool)
+	SaveHelp(path []string, help string)
 }
 
 // NewHelpDiscoverer creates a HelpDiscoverer with sensible defaults.
@@ -40,14 +96,17 @@ func (h *HelpDiscoverer) Name() string { return "help" }
 
 // Discover runs the CLI with --help and recursively discovers subcommands.
 func (h *HelpDiscoverer) Discover(ctx context.Context, cliName string, args []string) (*models.Node, error) {
-	node, err := h.discover(ctx, cliName, args, 0)
+	node, err := h.discover(ctx, cliName, args, 0, "", nil)
 	if err == nil && node != nil {
+		Dedupe(node, h.PruneDuplicates)
 		models.MarkInheritedFlags(node)
 	}
 	return node, err
 }
 
-func (h *HelpDiscoverer) discover(ctx context.Context, cliName string, args []string, depth int) (*models.Node, error) {
+// discover builds the node for args. helpText may carry output the caller
+// already fetched, with probe describing how; when empty it is fetched here.
+func (h *HelpDiscoverer) discover(ctx context.Context, cliName string, args []string, depth int, helpText string, probe *models.Probe) (*models.Node, error) {
 	fullPath := make([]string, 0, 1+len(args))
 	fullPath = append(fullPath, cliName)
 	fullPath = append(fullPath, args...)
@@ -58,19 +117,100 @@ func (h *HelpDiscoverer) discover(ctx context.Context, cliName string, args []st
 		Discovered: true,
 	}
 
-	helpText, err := h.runHelp(ctx, cliName, args)
-	if err != nil || helpText == "" {
-		node.DiscoveryErr = fmt.Sprintf("could not get help: %v", err)
-		return node, nil
+	if helpText == "" {
+		var err error
+		helpText, probe, err = h.fetchHelpTimeout(ctx, cliName, args)
+		if err != nil && ctx.Err() != nil {
+			return h.unreached(fullPath), nil
+		}
+		if err != nil || helpText == "" {
+			node.DiscoveryErr = fmt.Sprintf("could not get help: %v", err)
+			node.Interactive = err == errHelpInteractive
+			node.Probe = probe
+			h.emit(node)
+			return node, nil
+		}
 	}
 	node.HelpText = helpText
+	node.HelpHash = HashHelp(helpText)
+	node.Probe = probe
 
-	parsed := ParseHelpOutputFor(helpText, cliName)
+	if prev := h.previousNode(fullPath); prev != nil && prev.HelpHash == node.HelpHash {
+		return h.rediscover(ctx, cliName, args, depth, prev, probe), nil
+	}
+
+	parsed := ParseHelpOutputWith(helpText, cliName, h.Profile)
 	node.Description = parsed.Description
 	node.Flags = parsed.Flags
+	node.FlagSections = flagSections(parsed.Sections)
 	node.Positionals = parsed.Positionals
+	node.Deprecated, node.ReplacedBy = parsed.Deprecated, parsed.ReplacedBy
+	h.emit(node)
+	// Mark the subcommands this help lists as deprecated once attached.
+	defer markDeprecatedChildren(node, parsed.DeprecatedSubcommands)
+	defer scoreChildren(node, parsed.SubcommandConfidence)
+
+	if len(parsed.Children) > 0 {
+		adoptChildren(node, parsed.Children)
+		for _, c := range node.Children {
+			c.Walk(h.emit)
+		}
+		return node, nil
+	}
+
+	h.discoverSubcommands(ctx, node, cliName, args, depth, helpText, parsed.Subcommands)
+	return node, nil
+}
 
-	if depth < h.MaxDepth && len(parsed.Subcommands) > 0 {
+// rediscover builds the node for args from prev, its counterpart in
+// Previous, whose help is unchanged: it keeps what prev read from the help
+// and probes the subcommands prev found, to compare their help in turn.
+// Subcommands the help describes fully are kept as they are. Subcommands
+// pruned as duplicates from Previous are not probed again.
+func (h *HelpDiscoverer) rediscover(ctx context.Context, cliName string, args []string, depth int, prev *models.Node, probe *models.Probe) *models.Node {
+	own := *prev
+	own.Children = nil
+	node := own.Clone()
+	node.Probe = probe
+	// Dedupe and MarkInheritedFlags mark these afresh once discovery ends.
+	node.Dedup, node.AliasOf = nil, ""
+	for i := range node.Flags {
+		node.Flags[i].Inherited = false
+	}
+	h.emit(node)
+
+	var subs []string
+	for _, c := range prev.Children {
+		// Commands whose help is known were probed, though it may hash
+		// nothing when it was their parent's; the others were read from
+		// node's help, unless they failed or were not reached.
+		if c.HelpText == "" && c.HelpHash == "" && c.DiscoveryErr == "" && !c.Stub {
+			described := c.Clone()
+			described.Walk(h.emit)
+			node.Children = append(node.Children, described)
+			continue
+		}
+		subs = append(subs, c.Name)
+	}
+	h.discoverSubcommands(ctx, node, cliName, args, depth, node.HelpText, subs)
+	// What the help said of its subcommands holds as well.
+	for _, c := range node.Children {
+		if p := prev.Find(c.Name); p != nil {
+			c.Confidence = p.Confidence
+			if p.Deprecated && !c.Deprecated {
+				c.Deprecated, c.ReplacedBy = true, p.ReplacedBy
+			}
+		}
+	}
+	return node
+}
+
+// discoverSubcommands appends the nodes of node's subcommands subs, probed
+// in parallel down to MaxDepth, or stubs for them below it or when there
+// are more than StubThreshold. helpText is node's help.
+func (h *HelpDiscoverer) discoverSubcommands(ctx context.Context, node *models.Node, cliName string, args []string, depth int, helpText string, subs []string) {
+	fullPath := node.FullPath
+	if depth < h.MaxDepth && len(subs) > 0 {
 		// When a command has a very large number of subcommands (e.g. aws
 		// with 200+ services), eagerly running --help on every child would
 		// take minutes. Instead, create lightweight stub nodes that carry
@@ -80,17 +220,9 @@ func (h *HelpDiscoverer) discover(ctx context.Context, cliName string, args []st
 		if threshold <= 0 {
 			threshold = 50
 		}
-		if len(parsed.Subcommands) > threshold {
-			for _, sub := range parsed.Subcommands {
-				subFull := append(append([]string{}, fullPath...), sub)
-				node.Children = append(node.Children, &models.Node{
-					Name:       sub,
-					FullPath:   subFull,
-					Discovered: false,
-					Stub:       true,
-				})
-			}
-			return node, nil
+		if len(subs) > threshold {
+			h.addStubChildren(node, subs)
+			return
 		}
 
 		const maxWorkers = 8
@@ -99,43 +231,53 @@ func (h *HelpDiscoverer) discover(ctx context.Context, cliName string, args []st
 			idx   int
 			child *models.Node
 		}
-		results := make([]result, len(parsed.Subcommands))
+		results := make([]result, len(subs))
 		var wg sync.WaitGroup
-		for i, sub := range parsed.Subcommands {
+		for i, sub := range subs {
 			wg.Add(1)
 			go func(i int, sub string) {
 				defer wg.Done()
 				sem <- struct{}{}
 				defer func() { <-sem }()
-				subCtx, cancel := context.WithTimeout(ctx, h.Timeout)
-				defer cancel()
 				subArgs := append(append([]string{}, args...), sub)
 				subFull := append(append([]string{}, fullPath...), sub)
-				childHelp, err := h.runHelp(subCtx, cliName, subArgs)
+				childHelp, childProbe, err := h.fetchHelpTimeout(ctx, cliName, subArgs)
+				if err != nil && ctx.Err() != nil {
+					results[i] = result{i, h.unreached(subFull)}
+					return
+				}
 				if err != nil || childHelp == "" {
-					results[i] = result{i, &models.Node{
+					child := &models.Node{
 						Name:         sub,
 						FullPath:     subFull,
 						Discovered:   true,
 						DiscoveryErr: fmt.Sprintf("could not get help: %v", err),
-					}}
+						Interactive:  err == errHelpInteractive,
+						Probe:        childProbe,
+					}
+					h.emit(child)
+					results[i] = result{i, child}
 					return
 				}
 				var child *models.Node
 				if childHelp == helpText {
-					childParsed := ParseHelpOutputFor(childHelp, cliName)
+					childParsed := ParseHelpOutputWith(childHelp, cliName, h.Profile)
 					child = &models.Node{
 						Name:        sub,
 						FullPath:    subFull,
 						Discovered:  true,
 						HelpText:    childHelp,
+						HelpHash:    HashHelp(childHelp),
 						Description: childParsed.Description,
 						Flags:       childParsed.Flags,
 						Positionals: childParsed.Positionals,
+						Probe:       childProbe,
 					}
+					child.FlagSections = flagSections(childParsed.Sections)
+					h.emit(child)
 				} else {
 					var cerr error
-					child, cerr = h.discover(subCtx, cliName, subArgs, depth+1)
+					child, cerr = h.discover(ctx, cliName, subArgs, depth+1, childHelp, childProbe)
 					if cerr != nil {
 						child = &models.Node{Name: sub, FullPath: subFull}
 					}
@@ -149,36 +291,183 @@ func (h *HelpDiscoverer) discover(ctx context.Context, cliName string, args []st
 				node.Children = append(node.Children, r.child)
 			}
 		}
+	} else if len(subs) > 0 {
+		// The depth limit is soft: subcommands below it are kept as stubs
+		// so they can be expanded later instead of disappearing.
+		h.addStubChildren(node, subs)
 	}
-	return node, nil
 }
 
-// resolveBinary finds the executable for cliName.
-// Tries PATH first, then ./cliName (current dir), then the directory of the
-// running executable so that "treemand treemand" works without PATH changes.
-func resolveBinary(cliName string) string {
-	p, _ := resolveBinaryOrError(cliName)
-	return p
+// unreached returns the stub for the command at fullPath, whose help could
+// not be fetched because the run ended: it ran out of time or was
+// interrupted. Like the stubs of a large command, it can be expanded later;
+// it is not reported as failing.
+func (h *HelpDiscoverer) unreached(fullPath []string) *models.Node {
+	stub := &models.Node{Name: fullPath[len(fullPath)-1], FullPath: fullPath, Stub: true}
+	h.emit(stub)
+	return stub
+}
+
+// addStubChildren appends an undiscovered stub child to node for each name.
+func (h *HelpDiscoverer) addStubChildren(node *models.Node, subs []string) {
+	for _, sub := range subs {
+		stub := &models.Node{
+			Name:       sub,
+			FullPath:   append(append([]string{}, node.FullPath...), sub),
+			Discovered: false,
+			Stub:       true,
+		}
+		h.emit(stub)
+		node.Children = append(node.Children, stub)
+	}
+}
+
+// adoptChildren appends copies of children, whose FullPath is relative to
+// node, to node's children.
+func adoptChildren(node *models.Node, children []*models.Node) {
+	for _, c := range children {
+		child := c.Clone()
+		child.Walk(func(n *models.Node) {
+			n.FullPath = append(append([]string{}, node.FullPath...), n.FullPath...)
+		})
+		node.Children = append(node.Children, child)
+	}
+}
+
+// emit passes node to OnNode, if set.
+func (h *HelpDiscoverer) emit(node *models.Node) {
+	if h.OnNode == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.OnNode(node)
 }
 
-// resolveBinaryOrError is like resolveBinary but returns an error when the
-// binary cannot be located anywhere on the system.
-func resolveBinaryOrError(cliName string) (string, error) {
+// previousNode returns the node of the command fullPath in h.Previous when
+// it is a fully discovered node that can be reused, or nil.
+func (h *HelpDiscoverer) previousNode(fullPath []string) *models.Node {
+	if h.Previous == nil {
+		return nil
+	}
+	prev := h.Previous.FindFullPath(fullPath)
+	if prev == nil || prev.Stub || prev.DiscoveryErr != "" || prev.HelpHash == "" {
+		return nil
+	}
+	return prev
+}
+
+// errHelpTimeout reports that the time allowed to fetch a command's help
+// ran out. Diagnose recognizes it in DiscoveryErr.
+var errHelpTimeout = errors.New("timed out")
+
+// fetchHelpTimeout is fetchHelp bounded by h.Timeout, when set.
+func (h *HelpDiscoverer) fetchHelpTimeout(ctx context.Context, cliName string, args []string) (string, *models.Probe, error) {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	return h.fetchHelp(ctx, cliName, args)
+}
+
+// fetchHelp returns help text for args, reading through h.Store when one
+// is configured and writing freshly fetched output back to it. Failed
+// attempts are retried according to h.Retry. The probe describes the
+// attempts made; it is nil when the text came from the store.
+func (h *HelpDiscoverer) fetchHelp(ctx context.Context, cliName string, args []string) (string, *models.Probe, error) {
+	if h.Store != nil && !h.Fresh {
+		if text, ok := h.Store.LoadHelp(args); ok {
+			return text, nil, nil
+		}
+	}
+	var (
+		text  string
+		err   error
+		probe = &models.Probe{}
+		start = time.Now()
+	)
+	for {
+		probe.Attempts++
+		text, err = h.attemptHelp(ctx, cliName, args, probe)
+		if err == nil || err == errHelpInteractive || probe.Attempts > h.Retry.Retries || !h.backoff(ctx, probe.Attempts) {
+			break
+		}
+	}
+	probe.Duration = time.Since(start)
+	log.Debug().
+		Str("command", strings.Join(append([]string{cliName}, args...), " ")).
+		Int("attempts", probe.Attempts).
+		Int("execs", probe.Execs).
+		Dur("took", probe.Duration).
+		Err(err).
+		Msg("probed help")
+	if err == nil && text != "" && h.Store != nil {
+		h.Store.SaveHelp(args, text)
+	}
+	return text, probe, err
+}
+
+// attemptHelp makes one try at fetching help, bounded by
+// h.Retry.AttemptTimeout when set, counting its execs in probe.
+func (h *HelpDiscoverer) attemptHelp(ctx context.Context, cliName string, args []string, probe *models.Probe) (string, error) {
+	if h.Retry.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Retry.AttemptTimeout)
+		defer cancel()
+	}
+	text, err := h.runHelp(ctx, cliName, args, probe)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = errHelpTimeout
+	}
+	return text, err
+}
+
+// backoff waits before retry number attempt: Backoff, doubled for each
+// earlier retry. It reports false, without waiting it out, when ctx ends
+// first and no time is left for another attempt.
+func (h *HelpDiscoverer) backoff(ctx context.Context, attempt int) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if h.Retry.Backoff <= 0 {
+		return true
+	}
+	t := time.NewTimer(h.Retry.Backoff << (attempt - 1))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ResolveBinary finds the executable for cliName.
+// Tries PATH first, then ./cliName (current dir), then the directory of the
+// running executable so that "treemand treemand" works without PATH changes.
+// On Windows, a name without an extension is looked for with each extension
+// PATHEXT lists. When the binary cannot be located anywhere on the system,
+// it returns cliName and an error.
+func ResolveBinary(cliName string) (string, error) {
 	if p, err := exec.LookPath(cliName); err == nil {
 		return p, nil
 	}
+	var dirs []string
 	// Try the current working directory
 	if cwd, err := os.Getwd(); err == nil {
-		candidate := filepath.Join(cwd, cliName)
-		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
-			return candidate, nil
-		}
+		dirs = append(dirs, cwd)
 	}
 	// Try the directory of the running executable (e.g. ./treemand treemand)
 	if exe, err := os.Executable(); err == nil {
-		candidate := filepath.Join(filepath.Dir(exe), cliName)
-		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
-			return candidate, nil
+		dirs = append(dirs, filepath.Dir(exe))
+	}
+	for _, dir := range dirs {
+		for _, name := range executableNames(cliName) {
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate, nil
+			}
 		}
 	}
 	return cliName, fmt.Errorf("command %q not found in PATH or current directory", cliName)
@@ -188,7 +477,7 @@ func resolveBinaryOrError(cliName string) (string, error) {
 // executable. Call this before starting discovery to give the user a clear
 // error message instead of a cryptic "no help output" stub node.
 func CheckAvailable(cliName string) error {
-	_, err := resolveBinaryOrError(cliName)
+	_, err := ResolveBinary(cliName)
 	return err
 }
 
@@ -208,15 +497,24 @@ var truncatedHelpRe = regexp.MustCompile(`(?i)--help all|--help <category>|not t
 // runHelp tries to get help text for args under cliName.
 // It attempts --help / -h first, then `help` as a positional fallback
 // (needed for tools like aws that use "aws help" instead of "aws --help").
-func (h *HelpDiscoverer) runHelp(ctx context.Context, cliName string, args []string) (string, error) {
-	resolved := resolveBinary(cliName)
+// Once an invocation prompts for input no further forms are tried. Every
+// process started is counted in probe.Execs.
+func (h *HelpDiscoverer) runHelp(ctx context.Context, cliName string, args []string, probe *models.Probe) (string, error) {
+	resolved, _ := BinaryPath(ctx, cliName)
 
 	// Helper that runs a command with pager env vars and returns trimmed output.
+	interactive := false
 	run := func(cmdArgs []string) string {
-		cmd := exec.CommandContext(ctx, resolved, cmdArgs...) //nolint:gosec
-		cmd.Env = append(os.Environ(), pagerEnv...)
-		out, _ := cmd.CombinedOutput()
-		return strings.TrimSpace(string(out))
+		if interactive {
+			return ""
+		}
+		probe.Execs++
+		out, waiting := runProbe(ctx, resolved, cmdArgs)
+		if waiting {
+			interactive = true
+			return ""
+		}
+		return strings.TrimSpace(out)
 	}
 
 	var firstOut string
@@ -259,6 +557,9 @@ func (h *HelpDiscoverer) runHelp(ctx context.Context, cliName string, args []str
 	if firstOut != "" {
 		return firstOut, nil
 	}
+	if interactive {
+		return "", errHelpInteractive
+	}
 	return "", fmt.Errorf("no help output from %s", cliName)
 }
 
@@ -273,6 +574,23 @@ type ParsedHelp struct {
 	// Sections holds named flag groups (e.g. Godot's "General options:",
 	// "Debug options:"). Only populated when multiple distinct sections exist.
 	Sections []ParsedSection
+	// Deprecated is set when the help marks the command itself as
+	// deprecated, in its description or a banner at the top;
+	// ReplacedBy is the suggested replacement.
+	Deprecated bool
+	ReplacedBy string
+	// DeprecatedSubcommands maps the subcommands listed as deprecated to
+	// their replacements ("" when none is suggested).
+	DeprecatedSubcommands map[string]string
+	// SubcommandConfidence scores how surely each of Subcommands was read
+	// as one; see models.LowConfidence.
+	SubcommandConfidence map[string]float64
+	// Children are subcommands the help describes fully, so they need not
+	// be probed: the commands of docopt usage patterns. Their FullPath is
+	// relative to the parsed command. Subcommands lists their names.
+	Children []*models.Node
+	// Profile names the parser profile the help was read with.
+	Profile string
 }
 
 // ParsedSection is a named group of flags found under a section header.
@@ -281,6 +599,22 @@ type ParsedSection struct {
 	Flags []models.Flag
 }
 
+// flagSections returns parsed flag groups as a node keeps them: none
+// unless the help lists its flags under more than one header.
+func flagSections(sections []ParsedSection) []models.FlagSection {
+	if len(sections) < 2 {
+		return nil
+	}
+	out := make([]models.FlagSection, len(sections))
+	for i, s := range sections {
+		out[i].Name = s.Name
+		for _, f := range s.Flags {
+			out[i].Flags = append(out[i].Flags, f.Name)
+		}
+	}
+	return out
+}
+
 // section labels we recognize
 const (
 	secNone     = ""
@@ -291,6 +625,7 @@ const (
 	secName     = "name" // man-page NAME section
 	secExamples = "examples"
 	secAliases  = "aliases"
+	secArgs     = "arguments" // positional descriptions, for profiles that list them
 )
 
 // sectionHeaders maps lower-cased keywords that appear in section header lines.
@@ -374,19 +709,26 @@ var (
 	// manpageHeaderRe matches lines like "GIT-CLONE(1)   Git Manual   GIT-CLONE(1)".
 	// These appear at the top of man-formatted --help output and should never be
 	// used as the command description.
-	manpageHeaderRe = regexp.MustCompile(`^[A-Z][A-Z0-9_.-]+\(\d+\)\s`)
+	manpageHeaderRe = regexp.MustCompile(`^[A-Z][A-Z0-9_.-]+\(\d*\)\s`)
 	// nameSectionDescRe matches the NAME section body: "   git-clone - Short desc"
 	nameSectionDescRe = regexp.MustCompile(`^[\s\t]+\S.*?\s+-\s+(.+)$`)
 	// subcommand line: 2–8 leading spaces, lowercase word; args like [PATTERN...] may appear
 	// between name and description (e.g. systemctl's "  list-units [PAT...]   description")
 	subcmdRe = regexp.MustCompile(`^\s{2,8}([a-z][a-z0-9_-]*)(?:.*?\s{2,}(.+))?$`)
-	// positional in usage: <required> or [optional]
-	reqArgRe = regexp.MustCompile(`<([A-Za-z][A-Za-z0-9_.-]*)>`)
-	optArgRe = regexp.MustCompile(`\[([A-Za-z][A-Za-z0-9_.-]*)(?:\.{3})?\]`)
 	// URL in help text
-	urlRe = regexp.MustCompile(`https?://[^\s]+`)
+	urlRe = regexp.MustCompile(`https?://[^\s>]+`)
+	// flag descriptions that say the flag may be given more than once
+	repeatableDescRe = regexp.MustCompile(`(?i)\b(?:can|may) be (?:repeated|(?:specified|given|used|passed|supplied) (?:multiple times|more than once))|\(repeatable\)`)
 )
 
+// repeatableFlagTypes are value types (cobra/pflag and friends) whose flags
+// may be given more than once.
+var repeatableFlagTypes = map[string]bool{
+	"count": true, "stringarray": true, "stringslice": true, "strings": true,
+	"intslice": true, "ints": true, "uintslice": true, "floatslice": true,
+	"boolslice": true, "durationslice": true, "[]string": true,
+}
+
 // ansiRe matches ANSI terminal escape codes (colors, etc.)
 var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[mGKHF]`)
 
@@ -470,18 +812,86 @@ func ParseHelpOutput(text string) ParsedHelp {
 	return ParseHelpOutputFor(text, "")
 }
 
+// ParseHelpNode parses help text into the node discovery would make of it,
+// without running anything: the command called name (by default the
+// program its usage line names), its flags and positionals, and its
+// subcommands as undiscovered stubs, unless the help describes them fully.
+func ParseHelpNode(text, name string, p *Profile) *models.Node {
+	if name == "" {
+		name = usageProgram(strings.Split(stripANSI(text), "\n"))
+	}
+	parsed := ParseHelpOutputWith(text, name, p)
+	node := &models.Node{
+		Name:        name,
+		FullPath:    []string{name},
+		Description: parsed.Description,
+		Flags:       parsed.Flags,
+		Positionals: parsed.Positionals,
+		HelpText:    text,
+		HelpHash:    HashHelp(text),
+		Discovered:  true,
+		Deprecated:  parsed.Deprecated,
+		ReplacedBy:  parsed.ReplacedBy,
+	}
+	node.FlagSections = flagSections(parsed.Sections)
+	if len(parsed.Children) > 0 {
+		adoptChildren(node, parsed.Children)
+	} else {
+		(&HelpDiscoverer{}).addStubChildren(node, parsed.Subcommands)
+	}
+	markDeprecatedChildren(node, parsed.DeprecatedSubcommands)
+	scoreChildren(node, parsed.SubcommandConfidence)
+	return node
+}
+
 // ParseHelpOutputFor parses --help output with knowledge of the CLI name being
 // introspected, so self-referential example lines don't create bogus subcommands.
+// The parser profile is detected from the text.
 func ParseHelpOutputFor(text, selfName string) ParsedHelp {
+	return ParseHelpOutputWith(text, selfName, nil)
+}
+
+// ParseHelpOutputWith is ParseHelpOutputFor with parser profile p, or the
+// detected one when p is nil.
+func ParseHelpOutputWith(text, selfName string, p *Profile) ParsedHelp {
 	text = stripANSI(text)
 	text = stripManpageFormatting(text)
-	var result ParsedHelp
+	if p == nil {
+		p = DetectProfile(text)
+	}
+	result := ParsedHelp{Profile: p.Name}
 	lines := strings.Split(text, "\n")
 	section := secNone
 	seenSubs := map[string]bool{}
 	seenFlags := map[string]bool{}
 	usageLines := []string{}
 
+	// noteDeprecatedSub records a listed subcommand whose description marks
+	// it as deprecated.
+	noteDeprecatedSub := func(name, desc string) {
+		if deprecated, replacedBy := parseDeprecation(desc); deprecated {
+			if result.DeprecatedSubcommands == nil {
+				result.DeprecatedSubcommands = map[string]string{}
+			}
+			result.DeprecatedSubcommands[name] = replacedBy
+		}
+	}
+
+	// addSub records a listed subcommand with its confidence score, unless
+	// it was seen already or is not a command name.
+	addSub := func(name string, confidence float64) bool {
+		if seenSubs[name] || skipSubcmdWords[name] || name == selfName {
+			return false
+		}
+		seenSubs[name] = true
+		result.Subcommands = append(result.Subcommands, name)
+		if result.SubcommandConfidence == nil {
+			result.SubcommandConfidence = map[string]float64{}
+		}
+		result.SubcommandConfidence[name] = confidence
+		return true
+	}
+
 	// inNameSection is set when we detect the man-page NAME section; used to
 	// extract a clean short description from "   git-clone - Short desc" lines.
 	inNameSection := false
@@ -491,9 +901,20 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 	currentSectionName := ""
 	sectionFlagCount := map[string]int{} // section name → flag count added so far
 
-	// pendingFlag holds a partially-parsed AWS-style flag whose description is
-	// on the next non-empty line ("--flag (type)" followed by "   description").
+	// pendingFlag holds a partially-parsed flag whose description is on the
+	// next non-empty line: AWS-style "--flag (type)" followed by
+	// "   description", or a wrapped flag (see Profile.wrappedFlags), whose
+	// description must be indented deeper than pendingIndent. pendingIndent
+	// is -1 for AWS-style flags; pendingBase is the flag's base confidence.
 	var pendingFlag *models.Flag
+	pendingIndent, pendingBase := -1, confidenceListed
+
+	// usageWraps is set while the lines read continue a usage line.
+	usageWraps := false
+
+	// listedArgs are the positionals an arguments section lists, with
+	// their descriptions.
+	var listedArgs []models.Positional
 
 	// addFlag appends a flag to result.Flags and (if we are in a named section)
 	// also to the corresponding ParsedSection entry.
@@ -502,6 +923,10 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 			return
 		}
 		seenFlags[f.Name] = true
+		f.Deprecated, f.ReplacedBy = parseDeprecation(f.Description)
+		if m := defaultRe.FindStringSubmatch(f.Description); m != nil {
+			f.Default = strings.TrimSpace(m[1])
+		}
 		result.Flags = append(result.Flags, f)
 		if currentSectionName != "" {
 			n := len(result.Sections)
@@ -514,24 +939,51 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 		}
 	}
 
+	// takeFlag scores and adds a flag parsed from rawLine where base
+	// applies, or holds it for its description when the profile wraps it.
+	takeFlag := func(rawLine string, f models.Flag, base float64) {
+		if p.choiceValues {
+			f = splitChoiceValue(f)
+		}
+		if p.wrappedFlags && f.Description == "" {
+			pendingFlag, pendingIndent, pendingBase = &f, indentWidth(rawLine), base
+			return
+		}
+		f.Confidence = flagConfidence(f, base)
+		addFlag(f)
+	}
+
 	for i, rawLine := range lines {
 		trimmed := strings.TrimSpace(rawLine)
 		lower := strings.ToLower(trimmed)
 
-		// Flush a pending AWS-style flag when we encounter a non-empty line.
+		// Flush a pending flag when we encounter a non-empty line.
 		if pendingFlag != nil && trimmed != "" {
 			// If the next line is another flag, don't use it as a description.
-			if !strings.HasPrefix(trimmed, "--") && awsFlagRe.FindString(rawLine) == "" {
+			isDesc := !strings.HasPrefix(trimmed, "--") && awsFlagRe.FindString(rawLine) == ""
+			if pendingIndent >= 0 {
+				isDesc = !strings.HasPrefix(trimmed, "-") && indentWidth(rawLine) > pendingIndent
+			}
+			if isDesc {
 				pendingFlag.Description = trimmed
 			}
+			pendingFlag.Confidence = flagConfidence(*pendingFlag, pendingBase)
 			if !seenFlags[pendingFlag.Name] {
 				addFlag(*pendingFlag)
 			}
-			pendingFlag = nil
+			pendingFlag, pendingIndent, pendingBase = nil, -1, confidenceListed
+		}
+
+		// A long usage line wraps onto lines indented past "usage:", as
+		// argparse and git wrap theirs.
+		if usageWraps && trimmed != "" && indentWidth(rawLine) > len("usage:") {
+			usageLines[len(usageLines)-1] += " " + trimmed
+			continue
 		}
+		usageWraps = false
 
 		// Detect section header: "Flags:", "Available Commands:", "GLOBAL OPTIONS", etc.
-		if sec := detectSection(lower); sec != secNone {
+		if sec := p.detectSection(rawLine); sec != secNone {
 			// For named flag-group sections (e.g. "General options:", "Debug options:"),
 			// remember the human-readable name so flags get grouped under it.
 			if sec == secFlags {
@@ -542,6 +994,11 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 			}
 			section = sec
 			inNameSection = (sec == secName)
+			// A short usage line can pass for a header ("Usage: curl <url>").
+			if _, rest, ok := strings.Cut(trimmed, ":"); sec == secUsage && ok && strings.TrimSpace(rest) != "" {
+				usageLines = append(usageLines, rawLine)
+				usageWraps = true
+			}
 			continue
 		}
 		// Multi-column command grid (openssl style): when already inside a commands
@@ -553,10 +1010,7 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 			parts := strings.Fields(rawLine)
 			if len(parts) >= 2 && allGridEntries(parts) {
 				for _, p := range parts {
-					if !seenSubs[p] && !skipSubcmdWords[p] {
-						seenSubs[p] = true
-						result.Subcommands = append(result.Subcommands, p)
-					}
+					addSub(p, confidenceList)
 				}
 				continue
 			}
@@ -567,7 +1021,7 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 		if i > 4 && trimmed != "" && !strings.HasPrefix(rawLine, " ") &&
 			!strings.HasPrefix(rawLine, "\t") && section != secNone &&
 			!strings.HasSuffix(lower, ":") &&
-			detectSection(lower) == secNone {
+			p.detectSection(rawLine) == secNone {
 			// Man-page footers like "TOOLNAME()" at end of page shouldn't reset.
 			if !strings.HasSuffix(trimmed, "()") {
 				section = secNone
@@ -596,11 +1050,19 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 			result.Description = trimmed
 		}
 
+		// A deprecation banner at the top marks the command itself, e.g.
+		// cobra's `Command "x" is deprecated, use y instead`.
+		if i < 5 && trimmed != "" && !strings.HasPrefix(trimmed, "-") && !result.Deprecated {
+			result.Deprecated, result.ReplacedBy = parseDeprecation(trimmed)
+		}
+
 		// Collect all usage / synopsis lines for positional parsing.
 		if strings.HasPrefix(lower, "usage:") || strings.HasPrefix(lower, "use:") ||
 			lower == "synopsis" ||
+			(strings.HasPrefix(lower, "or:") && len(usageLines) > 0) ||
 			(section == secUsage && trimmed != "") {
 			usageLines = append(usageLines, rawLine)
+			usageWraps = strings.HasPrefix(lower, "usage:") && len(trimmed) > len("usage:")
 		}
 
 		// Detect docs URL anywhere in text.
@@ -613,24 +1075,32 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 		switch section {
 		case secFlags:
 			// AWS man-page flag style: "       --flag (type)"
-			if m := awsFlagRe.FindStringSubmatch(rawLine); m != nil {
-				f := models.Flag{Name: m[1], ValueType: m[2]}
+			if m := awsFlagRe.FindStringSubmatch(rawLine); m != nil && p.awsFlags {
+				f := models.Flag{Name: m[1], ValueType: m[2], ValueStyle: models.ValueStyleSpace}
 				if m[2] == "boolean" {
-					f.ValueType = "bool"
+					f.ValueType, f.ValueStyle = "bool", ""
 				}
 				pendingFlag = &f
 				continue
 			}
-			if f, ok := parseFlag(rawLine); ok {
-				addFlag(f)
+			if f, ok := p.parseFlag(rawLine); ok {
+				takeFlag(rawLine, f, confidenceListed)
+			}
+		case secArgs:
+			// "  <PATTERN>   A regular expression used for searching."
+			if m := argDescRe.FindStringSubmatch(rawLine); m != nil {
+				listedArgs = append(listedArgs, models.Positional{
+					Name:        strings.Trim(m[1], "<>[].+"),
+					Description: m[2],
+					Required:    !strings.HasPrefix(m[1], "["),
+					Variadic:    strings.HasSuffix(m[1], "..."),
+				})
 			}
 		case secCommands:
 			// Tab-indented subcommand (Go toolchain style): "\tbug  start a bug report"
 			if m := goTabSubcmdRe.FindStringSubmatch(rawLine); m != nil {
-				name := m[1]
-				if !seenSubs[name] && !skipSubcmdWords[name] && name != selfName {
-					seenSubs[name] = true
-					result.Subcommands = append(result.Subcommands, name)
+				if addSub(m[1], commandConfidence(m[2], confidenceListed)) {
+					noteDeprecatedSub(m[1], m[2])
 				}
 				continue
 			}
@@ -655,48 +1125,46 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 				}
 				if allValid && len(names) > 0 {
 					for _, name := range names {
-						if !seenSubs[name] && !skipSubcmdWords[name] && name != selfName {
-							seenSubs[name] = true
-							result.Subcommands = append(result.Subcommands, name)
-						}
+						addSub(name, confidenceList)
 					}
 					continue
 				}
 			}
 			// AWS man-page bullet: "       +o subcmd"
 			if m := awsBulletRe.FindStringSubmatch(rawLine); m != nil {
-				name := m[1]
-				if !seenSubs[name] && !skipSubcmdWords[name] && name != selfName {
-					seenSubs[name] = true
-					result.Subcommands = append(result.Subcommands, name)
-				}
+				addSub(m[1], confidenceListed)
 				continue
 			}
 			if m := subcmdRe.FindStringSubmatch(rawLine); m != nil {
-				name := m[1]
-				if !seenSubs[name] && !skipSubcmdWords[name] && name != selfName {
-					seenSubs[name] = true
-					result.Subcommands = append(result.Subcommands, name)
+				if addSub(m[1], commandConfidence(m[2], confidenceListed)) {
+					noteDeprecatedSub(m[1], m[2])
 				}
 			}
 		case secExamples, secAliases, secDesc:
 			// These sections contain narrative text, examples, or aliases —
 			// not subcommand lists. Parse flags only (e.g. example usage may
 			// reference flags we want to surface), but never infer subcommands.
-			if f, ok := parseFlag(rawLine); ok {
-				addFlag(f)
+			// BSD man pages list their flags in DESCRIPTION.
+			if f, ok := p.parseFlag(rawLine); ok {
+				base := confidenceProse
+				if section == secDesc && p.descriptionFlags {
+					base = confidenceListed
+				}
+				takeFlag(rawLine, f, base)
 			}
 		case secNone, secUsage:
 			// Outside named sections: pick up flags and subcommands with stricter checks.
-			if f, ok := parseFlag(rawLine); ok {
-				addFlag(f)
+			if f, ok := p.parseFlag(rawLine); ok {
+				base := confidenceLoose
+				if p.sectionlessFlags {
+					base = confidenceListed
+				}
+				takeFlag(rawLine, f, base)
 			}
 			// Git-style free-form subcommand lists: indented word + required description.
-			if m2 := subcmdRe.FindStringSubmatch(rawLine); m2 != nil && m2[2] != "" {
-				name := m2[1]
-				if !seenSubs[name] && !skipSubcmdWords[name] && name != selfName {
-					seenSubs[name] = true
-					result.Subcommands = append(result.Subcommands, name)
+			if m2 := subcmdRe.FindStringSubmatch(rawLine); m2 != nil && m2[2] != "" && p.looseCommands {
+				if addSub(m2[1], commandConfidence(m2[2], confidenceLoose)) {
+					noteDeprecatedSub(m2[1], m2[2])
 				}
 			}
 		}
@@ -704,9 +1172,16 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 
 	// Flush any trailing pending flag.
 	if pendingFlag != nil {
+		pendingFlag.Confidence = flagConfidence(*pendingFlag, pendingBase)
 		addFlag(*pendingFlag)
 	}
 
+	// A --no-color listed beside --color is one negatable flag.
+	for i := range result.Sections {
+		result.Sections[i].Flags = foldNegations(result.Sections[i].Flags, result.Flags)
+	}
+	result.Flags = foldNegations(result.Flags, result.Flags)
+
 	// Drop sections that have fewer than 2 flags — they are noise.
 	{
 		filtered := result.Sections[:0]
@@ -719,16 +1194,96 @@ func ParseHelpOutputFor(text, selfName string) ParsedHelp {
 		_ = sectionFlagCount // used implicitly via addFlag
 	}
 
-	// Parse positionals from all collected usage lines
-	for _, ul := range usageLines {
-		result.Positionals = append(result.Positionals, parsePositionals(ul)...)
+	if !result.Deprecated {
+		result.Deprecated, result.ReplacedBy = parseDeprecation(result.Description)
+	}
+
+	// argparse may offer its subcommands only as a "{a,b} ..." choice in
+	// the usage line.
+	var usageCommands []string
+	result.Positionals, usageCommands = parseUsageLines(usageLines)
+	for _, name := range usageCommands {
+		addSub(name, confidenceUsage)
+	}
+	addListedArgs(&result, listedArgs, seenSubs)
+
+	// Docopt help is described by its usage patterns; they replace what
+	// the line-by-line parse made of them.
+	if d := parseDocopt(lines); d != nil {
+		result.Flags, result.Positionals, result.Sections = foldNegations(d.flags, d.flags), d.positionals, nil
+		result.Children, result.Subcommands = d.children, nil
+		result.SubcommandConfidence = map[string]float64{}
+		for _, c := range d.children {
+			result.Subcommands = append(result.Subcommands, c.Name)
+			result.SubcommandConfidence[c.Name] = c.Confidence
+		}
 	}
-	// Deduplicate positionals
-	result.Positionals = dedupePositionals(result.Positionals)
 
 	return result
 }
 
+// argDescRe matches a line of an arguments section: an indented name, two
+// or more spaces, and its description.
+var argDescRe = regexp.MustCompile(`^\s{2,8}(\S+)\s{2,}(\S.*)$`)
+
+// addListedArgs describes the positionals of result with the arguments
+// section's descriptions, and adds those the usage line does not name as
+// positionals: argparse's lowercase names read as command words there.
+// Entries naming subcommands (argparse lists them in the same section)
+// are skipped.
+func addListedArgs(result *ParsedHelp, listed []models.Positional, subs map[string]bool) {
+	for _, arg := range listed {
+		if subs[arg.Name] {
+			continue
+		}
+		found := false
+		for i, pos := range result.Positionals {
+			if strings.EqualFold(pos.Name, arg.Name) {
+				found = true
+				if pos.Description == "" {
+					result.Positionals[i].Description = arg.Description
+				}
+			}
+		}
+		if !found {
+			result.Positionals = append(result.Positionals, arg)
+		}
+	}
+}
+
+// indentWidth returns the width of line's leading whitespace, counting a
+// tab as eight columns.
+func indentWidth(line string) int {
+	w := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			w++
+		case '\t':
+			w += 8 - w%8
+		default:
+			return w
+		}
+	}
+	return w
+}
+
+// splitChoiceValue reads the description of argparse's "--style
+// {plain,fancy}  Style" as the choices the flag takes and its description.
+func splitChoiceValue(f models.Flag) models.Flag {
+	if !strings.HasPrefix(f.Description, "{") {
+		return f
+	}
+	end := strings.Index(f.Description, "}")
+	if end < 0 {
+		return f
+	}
+	f.ValueType = f.Description[:end+1]
+	f.ValueStyle = models.ValueStyleSpace
+	f.Description = strings.TrimSpace(f.Description[end+1:])
+	return f
+}
+
 // detectSection returns a section constant if the line is a recognized header.
 // Handles "Title Case:" (cobra/click style) and "UPPER CASE" (man/AWS style).
 func detectSection(lower string) string {
@@ -766,6 +1321,11 @@ func detectSection(lower string) string {
 
 // parseFlag tries to parse a flag definition line.
 func parseFlag(line string) (models.Flag, bool) {
+	// "--[no-]color" is --color and its negation, --no-color.
+	negatable := strings.Contains(line, "--[no-]")
+	if negatable {
+		line = strings.Replace(line, "--[no-]", "--", 1)
+	}
 	// Try long flag regex first
 	if m := longFlagRe.FindStringSubmatch(line); m != nil {
 		f := models.Flag{
@@ -782,7 +1342,12 @@ func parseFlag(line string) (models.Flag, bool) {
 		default:
 			f.ValueType = "bool"
 		}
+		if f.ValueType != "bool" || strings.Contains(line, m[2]+"[=") {
+			f.ValueStyle = valueStyle(line[strings.Index(line, m[2])+len(m[2]):])
+		}
 		f.Description = stripBuildMarker(m[6])
+		f.Repeatable = isRepeatable(f)
+		f.Negatable = negatable && !f.TakesValue()
 		return f, true
 	}
 	// Try short-only flag
@@ -799,12 +1364,63 @@ func parseFlag(line string) (models.Flag, bool) {
 		default:
 			f.ValueType = "bool"
 		}
+		if f.ValueType != "bool" {
+			f.ValueStyle = models.ValueStyleSpace
+		}
 		f.Description = stripBuildMarker(m[4])
+		f.Repeatable = isRepeatable(f)
 		return f, true
 	}
 	return models.Flag{}, false
 }
 
+// foldNegations folds each boolean "--no-x" in flags whose --x is a boolean
+// flag of all into --x, which it marks Negatable, so the pair is one flag
+// that can be set either way. all is flags itself, or the node's flags when
+// flags is one section of them.
+func foldNegations(flags, all []models.Flag) []models.Flag {
+	bools := map[string]bool{}
+	for _, f := range all {
+		if !f.TakesValue() {
+			bools[f.Name] = true
+		}
+	}
+	if flags == nil {
+		return nil
+	}
+	out := make([]models.Flag, 0, len(flags))
+	for _, f := range flags {
+		if f.TakesValue() {
+			out = append(out, f)
+			continue
+		}
+		if name, ok := strings.CutPrefix(f.Name, "--no-"); ok && bools["--"+name] {
+			continue
+		}
+		if strings.HasPrefix(f.Name, "--") && bools[f.NegatedName()] {
+			f.Negatable = true
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// valueStyle returns how a flag spec joins the flag to its value, given the
+// spec after the flag's name: "=FILE", "=<file>" and GNU's optional "[=WHEN]"
+// use ValueStyleEquals, " FILE" ValueStyleSpace.
+func valueStyle(afterName string) string {
+	if strings.HasPrefix(afterName, "=") || strings.HasPrefix(afterName, "[=") {
+		return models.ValueStyleEquals
+	}
+	return models.ValueStyleSpace
+}
+
+// isRepeatable reports whether f may be given more than once, judging by its
+// value type (count, stringArray, …) or its description ("can be repeated").
+func isRepeatable(f models.Flag) bool {
+	return repeatableFlagTypes[strings.ToLower(f.ValueType)] || repeatableDescRe.MatchString(f.Description)
+}
+
 // positionalPlaceholders are all-caps words in usage lines that represent
 // option/flag slots, not real positional arguments.
 var positionalPlaceholders = map[string]bool{
@@ -818,50 +1434,3 @@ var positionalPlaceholders = map[string]bool{
 	"CMD": true, "CMDS": true,
 	"RESOURCE": true, "NAME": true, "TYPE": true, "OBJECT": true,
 }
-
-// bracketOptionRe matches bracket-enclosed option patterns that should not be
-// treated as positional arguments: [--long-opt...] and [-X <value>] forms.
-var bracketOptionRe = regexp.MustCompile(`\[-[^\]]+\]`)
-
-// parsePositionals extracts positional args from a usage line.
-func parsePositionals(line string) []models.Positional {
-	var result []models.Positional
-	seen := map[string]bool{}
-	// Skip the "usage:" prefix for matching
-	searchLine := line
-	if idx := strings.Index(strings.ToLower(line), "usage:"); idx >= 0 {
-		searchLine = line[idx+6:]
-	}
-	// Strip [--option] bracket patterns to avoid false positives from
-	// lines like "git [--exec-path[=<path>]]".
-	searchLine = bracketOptionRe.ReplaceAllString(searchLine, "")
-	for _, m := range reqArgRe.FindAllStringSubmatch(searchLine, -1) {
-		name := m[1]
-		canonical := strings.TrimRight(strings.ToUpper(name), ".+")
-		if !seen[name] && !strings.ContainsAny(name, "= ") && !positionalPlaceholders[canonical] {
-			seen[name] = true
-			result = append(result, models.Positional{Name: name, Required: true})
-		}
-	}
-	for _, m := range optArgRe.FindAllStringSubmatch(searchLine, -1) {
-		name := m[1]
-		canonical := strings.TrimRight(strings.ToUpper(name), ".+")
-		if !seen[name] && !strings.ContainsAny(name, "= ") && !positionalPlaceholders[canonical] {
-			seen[name] = true
-			result = append(result, models.Positional{Name: name, Required: false})
-		}
-	}
-	return result
-}
-
-func dedupePositionals(ps []models.Positional) []models.Positional {
-	seen := map[string]bool{}
-	var out []models.Positional
-	for _, p := range ps {
-		if !seen[p.Name] {
-			seen[p.Name] = true
-			out = append(out, p)
-		}
-	}
-	return out
-}
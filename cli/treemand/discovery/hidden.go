@@ -0,0 +1,114 @@
+package discovery
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// hiddenProbes are the ways CLIs list commands their plain --help leaves
+// out: `help -a` (git), `--help-all` (gcloud, podman, Python CLIs) and
+// Cobra's `__complete` protocol, which lists commands by name.
+var hiddenProbes = [][]string{
+	{"help", "-a"},
+	{"--help-all"},
+	{"__complete", ""},
+}
+
+// completionDirectiveRe matches the directive line ending Cobra __complete
+// output, e.g. ":4".
+var completionDirectiveRe = regexp.MustCompile(`^:\d+$`)
+
+// HiddenDiscoverer finds commands a CLI accepts but does not list in its
+// --help output. It compares the plain help with each of hiddenProbes and
+// returns the commands only the probes list, as Hidden stub children. Like
+// the completions strategy it only looks one level deep: merge it with the
+// help strategy, which expands the stubs on demand.
+type HiddenDiscoverer struct {
+	// Timeout bounds each probe.
+	Timeout time.Duration
+}
+
+// NewHiddenDiscoverer creates a HiddenDiscoverer with sensible defaults.
+func NewHiddenDiscoverer() *HiddenDiscoverer {
+	return &HiddenDiscoverer{Timeout: 10 * time.Second}
+}
+
+func (h *HiddenDiscoverer) Name() string { return "hidden" }
+
+// Discover probes cliName (with args) for hidden commands. It returns
+// nil, nil when there are none, or the CLI supports none of the probes.
+func (h *HiddenDiscoverer) Discover(ctx context.Context, cliName string, args []string) (*models.Node, error) {
+	parentPath := append([]string{cliName}, args...)
+	listed := make(map[string]bool)
+	for _, name := range h.probe(ctx, cliName, args, []string{"--help"}, true) {
+		listed[name] = true
+	}
+	// Cobra's own shell completion commands are hidden by design.
+	listed["__complete"], listed["__completeNoDesc"] = true, true
+
+	var children []*models.Node
+	for _, probe := range hiddenProbes {
+		for _, name := range h.probe(ctx, cliName, args, probe, false) {
+			if listed[name] {
+				continue
+			}
+			listed[name] = true
+			children = append(children, &models.Node{
+				Name:     name,
+				FullPath: append(append([]string{}, parentPath...), name),
+				Stub:     true,
+				Hidden:   true,
+			})
+		}
+	}
+	if len(children) == 0 {
+		return nil, nil //nolint:nilnil // no hidden commands is the normal case
+	}
+	return &models.Node{
+		Name:     parentPath[len(parentPath)-1],
+		FullPath: parentPath,
+		Children: children,
+	}, nil
+}
+
+// probe runs cliName args probe... and returns the command names its output
+// lists. Output of a failed run is ignored unless lenient, since a CLI that
+// does not know the probe prints an error, or its usage.
+func (h *HiddenDiscoverer) probe(ctx context.Context, cliName string, args, probe []string, lenient bool) []string {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	out, err := runCommand(ctx, cliName, append(append([]string{}, args...), probe...))
+	if out == "" || (err != nil && !lenient) || isErrorOutput(out) {
+		return nil
+	}
+	if probe[0] == "__complete" {
+		return completionNames(out)
+	}
+	return ParseHelpOutputFor(stripANSI(out), cliName).Subcommands
+}
+
+// completionNames returns the command names in Cobra __complete output:
+// the lines before its ":<directive>" line, which stderr messages follow.
+// Output without a directive line is not from Cobra and yields nothing.
+func completionNames(out string) []string {
+	lines := strings.Split(out, "\n")
+	end := slices.IndexFunc(lines, func(l string) bool { return completionDirectiveRe.MatchString(strings.TrimSpace(l)) })
+	if end < 0 {
+		return nil
+	}
+	var names []string
+	for _, c := range ParseCompletionOutput(strings.Join(lines[:end], "\n"), nil) {
+		if !strings.ContainsAny(c.Name, " =") {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
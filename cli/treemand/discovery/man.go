@@ -27,8 +27,12 @@ func NewManDiscoverer() *ManDiscoverer {
 func (m *ManDiscoverer) Name() string { return "man" }
 
 // Discover fetches the man page for cliName and parses it into a Node.
-// args is ignored for the man strategy (man pages are always top-level).
-func (m *ManDiscoverer) Discover(ctx context.Context, cliName string, _ []string) (*models.Node, error) {
+// A man page describes the CLI as a whole, so there is nothing for the
+// subcommand args name, if any.
+func (m *ManDiscoverer) Discover(ctx context.Context, cliName string, args []string) (*models.Node, error) {
+	if len(args) > 0 {
+		return nil, nil //nolint:nilnil // nothing to contribute, as without a man page
+	}
 	plain, err := m.fetchManPage(ctx, cliName)
 	if err != nil {
 		return nil, err
@@ -40,13 +44,14 @@ func (m *ManDiscoverer) Discover(ctx context.Context, cliName string, _ []string
 	parsed := ParseHelpOutput(plain)
 
 	node := &models.Node{
-		Name:        cliName,
-		FullPath:    []string{cliName},
-		Description: parsed.Description,
-		Flags:       parsed.Flags,
-		Positionals: parsed.Positionals,
-		HelpText:    plain,
-		Discovered:  true,
+		Name:         cliName,
+		FullPath:     []string{cliName},
+		Description:  parsed.Description,
+		Flags:        parsed.Flags,
+		FlagSections: flagSections(parsed.Sections),
+		Positionals:  parsed.Positionals,
+		HelpText:     plain,
+		Discovered:   true,
 	}
 
 	for _, sub := range parsed.Subcommands {
@@ -78,14 +83,17 @@ func (m *ManDiscoverer) fetchManPage(ctx context.Context, cliName string) (strin
 	tctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	manCmd := exec.CommandContext(tctx, "man", cliName)
+	manCmd := Command(tctx, "man", cliName)
 	// MANPAGER=cat prevents man from invoking a pager; TERM=dumb keeps output plain.
 	manCmd.Env = append(manCmd.Environ(), "MANPAGER=cat", "TERM=dumb")
 	var manOut bytes.Buffer
 	manCmd.Stdout = &manOut
 	manCmd.Stderr = nil // discard stderr (e.g. "No manual entry for X")
 
-	if err := manCmd.Run(); err != nil {
+	start := time.Now()
+	err := manCmd.Run()
+	logExec(tctx, manCmd, start, manOut.Len(), err)
+	if err != nil {
 		// Exit code 1 means no man page — not a hard error.
 		return "", nil
 	}
@@ -100,7 +108,10 @@ func (m *ManDiscoverer) fetchManPage(ctx context.Context, cliName string) (strin
 	colCmd.Stdin = bytes.NewReader(raw)
 	var colOut bytes.Buffer
 	colCmd.Stdout = &colOut
-	if err := colCmd.Run(); err != nil {
+	start = time.Now()
+	err = colCmd.Run()
+	logExec(tctx, colCmd, start, colOut.Len(), err)
+	if err != nil {
 		// col not available — fall back to our built-in regex stripper.
 		return manpageBoldRe.ReplaceAllString(string(raw), ""), nil
 	}
@@ -3,43 +3,111 @@ package discovery
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/aallbrig/treemand/models"
 )
 
-// Merge combines results from multiple discoverers into a single tree.
-// Later discoverers fill in gaps from earlier ones.
+// Merge policies settle a description or flag type that two strategies
+// disagree on.
+const (
+	// MergeFirst keeps the value of the strategy that ran first, so
+	// --strategy lists the strategies in order of trust.
+	MergeFirst = "first"
+	// MergeRicher keeps the more informative value: the longer description,
+	// the more specific flag type ("duration" over "string").
+	MergeRicher = "richer"
+)
+
+// MergePolicies lists the merge policies.
+var MergePolicies = []string{MergeFirst, MergeRicher}
+
+// CheckMergePolicy returns an error unless policy names a merge policy.
+func CheckMergePolicy(policy string) error {
+	if !slices.Contains(MergePolicies, policy) {
+		return fmt.Errorf("unknown merge policy %q (want %s)", policy, strings.Join(MergePolicies, ", "))
+	}
+	return nil
+}
+
+// Merge combines results from multiple discoverers into a single tree with
+// the MergeFirst policy.
 func Merge(trees []*models.Node) *models.Node {
+	return MergeWith(trees, MergeFirst)
+}
+
+// MergeWith combines results from multiple discoverers into a single tree.
+// Later discoverers fill in gaps from earlier ones, and policy settles the
+// values they disagree on. Whatever the policy, a flag one strategy read as
+// bool takes the value type another gives it: help shows no value for many
+// flags that take one. Each conflict is noted in the Conflicts of its
+// command, which Diagnose reports.
+func MergeWith(trees []*models.Node, policy string) *models.Node {
 	if len(trees) == 0 {
 		return nil
 	}
+	m := merger{policy: policy}
 	result := trees[0].Clone()
 	for _, t := range trees[1:] {
-		mergeInto(result, t)
+		m.mergeInto(result, t)
 	}
 	return result
 }
 
-func mergeInto(dst, src *models.Node) {
+// merger merges trees under a merge policy.
+type merger struct {
+	policy string
+}
+
+func (m merger) mergeInto(dst, src *models.Node) {
 	if src == nil {
 		return
 	}
-	if dst.Description == "" {
-		dst.Description = src.Description
-	}
+	dst.Description = m.pick(dst, "description", dst.Description, src.Description, src.Sources,
+		m.policy == MergeRicher && len(src.Description) > len(dst.Description))
 	if dst.HelpText == "" {
 		dst.HelpText = src.HelpText
 	}
+	if src.Deprecated && !dst.Deprecated {
+		dst.Deprecated, dst.ReplacedBy = true, src.ReplacedBy
+	}
+	// A command some strategy found in the plain help is not hidden.
+	if dst.Hidden && !src.Hidden {
+		dst.Hidden = false
+	}
+	dst.Confidence = mergeConfidence(dst.Confidence, src.Confidence)
+	dst.Sources = addSources(dst.Sources, src.Sources)
 
 	// Merge flags (deduplicate by name)
-	flagSet := map[string]bool{}
-	for _, f := range dst.Flags {
-		flagSet[f.Name] = true
+	flagIdx := map[string]int{}
+	for i, f := range dst.Flags {
+		flagIdx[f.Name] = i
 	}
 	for _, f := range src.Flags {
-		if !flagSet[f.Name] {
-			dst.Flags = append(dst.Flags, f)
+		if i, ok := flagIdx[f.Name]; ok {
+			df := &dst.Flags[i]
+			df.Description = m.pick(dst, f.Name+" description", df.Description, f.Description, f.Sources,
+				m.policy == MergeRicher && len(f.Description) > len(df.Description))
+			if vt := m.pick(dst, f.Name+" type", df.ValueType, f.ValueType, f.Sources, m.richerType(f.ValueType, df.ValueType)); vt != df.ValueType {
+				df.ValueType, df.ValueStyle = vt, f.ValueStyle
+			}
+			dst.Flags[i].Confidence = mergeConfidence(dst.Flags[i].Confidence, f.Confidence)
+			if dst.Flags[i].ValueStyle == "" {
+				dst.Flags[i].ValueStyle = f.ValueStyle
+			}
+			dst.Flags[i].Negatable = dst.Flags[i].Negatable || f.Negatable
+			dst.Flags[i].Sources = addSources(dst.Flags[i].Sources, f.Sources)
+			continue
 		}
+		dst.Flags = append(dst.Flags, f)
+	}
+	// One strategy may list --color, another --no-color.
+	dst.Flags = foldNegations(dst.Flags, dst.Flags)
+
+	if len(dst.FlagSections) == 0 {
+		dst.FlagSections = src.FlagSections
 	}
 
 	// Merge positionals (deduplicate by name)
@@ -58,7 +126,7 @@ func mergeInto(dst, src *models.Node) {
 		found := false
 		for _, dstChild := range dst.Children {
 			if dstChild.Name == srcChild.Name {
-				mergeInto(dstChild, srcChild)
+				m.mergeInto(dstChild, srcChild)
 				found = true
 				break
 			}
@@ -69,27 +137,175 @@ func mergeInto(dst, src *models.Node) {
 	}
 }
 
-// Run executes all discoverers and merges their results.
+// richerType reports whether flag type a is to be kept over b: a value type
+// over one taking none, whatever the policy, and under MergeRicher a
+// specific type over a generic one.
+func (m merger) richerType(a, b string) bool {
+	takesValue := func(t string) bool { return models.Flag{ValueType: t}.TakesValue() }
+	if takesValue(a) != takesValue(b) {
+		return takesValue(a)
+	}
+	return m.policy == MergeRicher && genericTypes[strings.ToLower(b)] && !genericTypes[strings.ToLower(a)]
+}
+
+// genericTypes are flag value types that say little about the value.
+var genericTypes = map[string]bool{
+	"string": true, "str": true, "value": true, "val": true, "arg": true, "text": true,
+}
+
+// pick returns the value to keep of the two that dst's strategy and the
+// strategies in srcSources give what — "description", "--output type". A
+// value only one gives is kept; of two that differ, dst's unless takeSrc,
+// and the conflict is noted on dst.
+func (m merger) pick(dst *models.Node, what, dstVal, srcVal string, srcSources []string, takeSrc bool) string {
+	switch {
+	case srcVal == "" || strings.EqualFold(dstVal, srcVal):
+		return dstVal
+	case dstVal == "":
+		return srcVal
+	}
+	kept := dstVal
+	if takeSrc {
+		kept = srcVal
+	}
+	from := "another strategy"
+	if len(srcSources) > 0 {
+		from = strings.Join(srcSources, ",")
+	}
+	dst.Conflicts = append(dst.Conflicts, fmt.Sprintf("%s: %q, %s says %q; kept %q",
+		what, truncateNote(dstVal), from, truncateNote(srcVal), truncateNote(kept)))
+	return kept
+}
+
+// truncateNote shortens a value quoted in a conflict note.
+func truncateNote(s string) string {
+	const maxLen = 40
+	if r := []rune(s); len(r) > maxLen {
+		return string(r[:maxLen-1]) + "…"
+	}
+	return s
+}
+
+// MarkSource records source, the name of a discovery strategy, as having
+// found every command and flag of tree.
+func MarkSource(tree *models.Node, source string) {
+	tree.Walk(func(n *models.Node) {
+		n.Sources = addSources(n.Sources, []string{source})
+		for i := range n.Flags {
+			n.Flags[i].Sources = addSources(n.Flags[i].Sources, []string{source})
+		}
+	})
+}
+
+// addSources returns dst with the sources of add it does not list yet. It
+// never appends in place, as cloned trees share their flags' sources.
+func addSources(dst, add []string) []string {
+	for _, s := range add {
+		if !slices.Contains(dst, s) {
+			dst = append(slices.Clip(dst), s)
+		}
+	}
+	return dst
+}
+
+// mergeConfidence combines the scores two strategies gave one element: a
+// second sighting settles doubt, so the surer one wins, and unscored (0)
+// beats any score.
+func mergeConfidence(a, b float64) float64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return max(a, b)
+}
+
+// Run executes all discoverers and merges their results with the
+// MergeFirst policy.
 func Run(ctx context.Context, discoverers []Discoverer, cliName string) (*models.Node, error) {
+	return RunWith(ctx, discoverers, cliName, MergeFirst)
+}
+
+// RunWith executes all discoverers and merges their results under policy.
+func RunWith(ctx context.Context, discoverers []Discoverer, cliName, policy string) (*models.Node, error) {
+	return RunStream(ctx, discoverers, cliName, nil, policy, nil)
+}
+
+// RunStream is RunWith for the subtree of the command args name below
+// cliName (the whole CLI when args is empty), calling partial, when set,
+// with the tree merged so far each time it grows. The discoverers run
+// concurrently with ctx, so a slow strategy such as man adds its own time
+// to the run rather than following the others'. Their trees are still
+// merged in the order of discoverers, as MergeFirst relies on it: a tree
+// waits for those of the strategies before it. partial is called from the
+// calling goroutine, with a copy it may keep.
+//
+// When ctx ends first, the tree merged from what the strategies found is
+// returned all the same, marked Incomplete: commands the help strategy had
+// not reached are stubs, and strategies that failed are left out.
+func RunStream(ctx context.Context, discoverers []Discoverer, cliName string, args []string, policy string, partial func(*models.Node)) (*models.Node, error) {
 	if len(discoverers) == 0 {
 		d := NewHelpDiscoverer(-1)
-		return d.Discover(ctx, cliName, nil)
+		tree, err := d.Discover(ctx, cliName, args)
+		if tree != nil {
+			MarkSource(tree, d.Name())
+			tree.Incomplete = tree.Incomplete || ctx.Err() != nil
+		}
+		return tree, err
 	}
 
-	var trees []*models.Node
+	type outcome struct {
+		tree *models.Node
+		err  error
+		// cut reports that the run ran out of time before the
+		// strategy finished.
+		cut bool
+	}
+	done := make(chan int, len(discoverers))
+	outcomes := make([]*outcome, len(discoverers))
+	for i, d := range discoverers {
+		go func() {
+			tree, err := d.Discover(ctx, cliName, args)
+			if err == nil && tree != nil {
+				MarkSource(tree, d.Name())
+			}
+			outcomes[i] = &outcome{tree: tree, err: err, cut: ctx.Err() != nil}
+			done <- i
+		}()
+	}
+
+	m := merger{policy: policy}
+	ready := make([]bool, len(discoverers))
+	var result *models.Node
 	var lastErr error
-	for _, d := range discoverers {
-		tree, err := d.Discover(ctx, cliName, nil)
-		if err != nil {
-			lastErr = err
-			continue
+	incomplete := false
+	next := 0
+	for range discoverers {
+		ready[<-done] = true
+		grew := false
+		for ; next < len(discoverers) && ready[next]; next++ {
+			o := outcomes[next]
+			incomplete = incomplete || o.cut
+			switch {
+			case o.err != nil:
+				lastErr = o.err
+			case o.tree == nil:
+				// Strategy had nothing to contribute (e.g. no man page
+				// installed).
+			case result == nil:
+				result, grew = o.tree.Clone(), true
+			default:
+				m.mergeInto(result, o.tree)
+				grew = true
+			}
+		}
+		if grew && partial != nil {
+			partial(result.Clone())
 		}
-		trees = append(trees, tree)
 	}
-	if len(trees) == 0 {
+	if result == nil {
 		return nil, lastErr
 	}
-	return Merge(trees), nil
+	result.Incomplete = result.Incomplete || incomplete
+	return result, nil
 }
 
 // BuildDiscoverers creates Discoverer instances from strategy names.
@@ -112,6 +328,8 @@ func BuildDiscoverersWithThreshold(strategies []string, maxDepth, stubThreshold
 			result = append(result, NewManDiscoverer())
 		case "completions":
 			result = append(result, NewCompletionsDiscoverer())
+		case "hidden":
+			result = append(result, NewHiddenDiscoverer())
 		}
 	}
 	if len(result) == 0 {
@@ -0,0 +1,185 @@
+package discovery
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Package describes the package a CLI was installed from by a package
+// manager that puts a shim on PATH: npm links or wraps the package's
+// script, pipx links into the package's virtualenv.
+type Package struct {
+	Manager     string // "npm" or "pipx"
+	Name        string
+	Version     string
+	Description string
+	// Bins are the commands the package declares, the CLI among them.
+	Bins []string
+}
+
+// String returns the manager, name and version: "npm typescript@5.4.2".
+func (p Package) String() string {
+	if p.Name == "" {
+		return ""
+	}
+	s := p.Manager + " " + p.Name
+	if p.Version != "" {
+		s += "@" + p.Version
+	}
+	return s
+}
+
+// npmShimRe matches the package path in the shims npm writes on Windows
+// and for non-symlinked installs: "%dp0%\node_modules\typescript\bin\tsc",
+// "$basedir/node_modules/@scope/pkg/cli.js".
+var npmShimRe = regexp.MustCompile(`(?:%dp0%|\$basedir)[\\/]node_modules[\\/]((?:@[^\\/"]+[\\/])?[^\\/"]+)`)
+
+// DetectPackage reports the package the executable at path belongs to,
+// when npm or pipx installed it: path, or the file it links to, is inside
+// the package, or path is an npm shim naming it. It reads the package's
+// metadata; nothing is run.
+func DetectPackage(path string) (Package, bool) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return Package{}, false
+	}
+	resolved = filepath.ToSlash(resolved)
+	if dir, ok := npmPackageDir(resolved); ok {
+		return readNPMPackage(dir)
+	}
+	if i := strings.Index(resolved, "/pipx/venvs/"); i >= 0 {
+		venv, _, _ := strings.Cut(resolved[i+len("/pipx/venvs/"):], "/")
+		return readPipxPackage(filepath.FromSlash(resolved[:i+len("/pipx/venvs/")] + venv))
+	}
+	if m := npmShimRe.FindStringSubmatch(readHead(path)); m != nil {
+		return readNPMPackage(filepath.Join(filepath.Dir(path), "node_modules", filepath.FromSlash(strings.ReplaceAll(m[1], `\`, "/"))))
+	}
+	return Package{}, false
+}
+
+// npmPackageDir returns the directory of the package the slash-separated
+// path is in: the last node_modules/<name> or node_modules/@scope/<name>.
+func npmPackageDir(path string) (string, bool) {
+	i := strings.LastIndex(path, "/node_modules/")
+	if i < 0 {
+		return "", false
+	}
+	parts := strings.SplitN(path[i+len("/node_modules/"):], "/", 3)
+	n := 1
+	if strings.HasPrefix(parts[0], "@") {
+		n = 2
+	}
+	if len(parts) <= n {
+		return "", false
+	}
+	return filepath.FromSlash(path[:i+len("/node_modules/")] + strings.Join(parts[:n], "/")), true
+}
+
+// readNPMPackage reads the package.json of the npm package in dir.
+func readNPMPackage(dir string) (Package, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return Package{}, false
+	}
+	var meta struct {
+		Name        string          `json:"name"`
+		Version     string          `json:"version"`
+		Description string          `json:"description"`
+		Bin         json.RawMessage `json:"bin"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil || meta.Name == "" {
+		return Package{}, false
+	}
+	pkg := Package{Manager: "npm", Name: meta.Name, Version: meta.Version, Description: meta.Description}
+	// bin is a map of command names to scripts, or one script named
+	// after the package.
+	var bins map[string]string
+	var bin string
+	switch {
+	case json.Unmarshal(meta.Bin, &bins) == nil:
+		for name := range bins {
+			pkg.Bins = append(pkg.Bins, name)
+		}
+		slices.Sort(pkg.Bins)
+	case json.Unmarshal(meta.Bin, &bin) == nil:
+		pkg.Bins = []string{meta.Name[strings.LastIndex(meta.Name, "/")+1:]}
+	}
+	return pkg, true
+}
+
+// readPipxPackage reads the pipx metadata of the virtualenv venv, and the
+// summary of its main package.
+func readPipxPackage(venv string) (Package, bool) {
+	data, err := os.ReadFile(filepath.Join(venv, "pipx_metadata.json"))
+	if err != nil {
+		return Package{}, false
+	}
+	var meta struct {
+		MainPackage struct {
+			Package        string   `json:"package"`
+			PackageVersion string   `json:"package_version"`
+			Apps           []string `json:"apps"`
+		} `json:"main_package"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil || meta.MainPackage.Package == "" {
+		return Package{}, false
+	}
+	mp := meta.MainPackage
+	pkg := Package{Manager: "pipx", Name: mp.Package, Version: mp.PackageVersion, Bins: mp.Apps}
+	// Wheels name their metadata directory after the package with "-"
+	// and "." turned into "_".
+	dist := strings.NewReplacer("-", "_", ".", "_").Replace(mp.Package)
+	matches, _ := filepath.Glob(filepath.Join(venv, "lib", "python*", "site-packages", "*.dist-info", "METADATA"))
+	for _, m := range matches {
+		if strings.EqualFold(distName(filepath.Base(filepath.Dir(m))), dist) {
+			pkg.Description = metadataSummary(m)
+			break
+		}
+	}
+	return pkg, true
+}
+
+// distName returns the package name of a dist-info directory:
+// "black" for "black-24.1.0.dist-info".
+func distName(dir string) string {
+	name, _, _ := strings.Cut(strings.TrimSuffix(dir, ".dist-info"), "-")
+	return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+}
+
+// metadataSummary returns the Summary header of a wheel's METADATA file.
+func metadataSummary(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" { // the headers end at the first blank line
+			break
+		}
+		if v, ok := strings.CutPrefix(line, "Summary: "); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// readHead returns the start of the file at path, enough to hold a shim.
+func readHead(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	buf := make([]byte, 4096)
+	n, _ := io.ReadFull(f, buf)
+	return string(buf[:n])
+}
@@ -1,6 +1,8 @@
 package discovery_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/aallbrig/treemand/discovery"
@@ -599,3 +601,435 @@ Flags:
 		}
 	}
 }
+
+// mockRepeatableHelp mixes cobra array/count flags with argparse-style
+// "can be repeated" descriptions.
+const mockRepeatableHelp = `Usage:
+  demo [flags]
+
+Flags:
+      --tag stringArray     tag to apply
+      --label strings       labels, comma separated
+  -v, --verbose count       increase verbosity
+  -q, --quiet               decrease verbosity (can be repeated)
+  -e, --env string          environment variable; may be specified multiple times
+  -n, --name string         name of the thing
+`
+
+func TestParseHelpOutput_repeatableFlags(t *testing.T) {
+	p := discovery.ParseHelpOutput(mockRepeatableHelp)
+	flags := map[string]models.Flag{}
+	for _, f := range p.Flags {
+		flags[f.Name] = f
+	}
+	want := map[string]bool{
+		"--tag": true, "--label": true, "--verbose": true,
+		"--quiet": true, "--env": true, "--name": false,
+	}
+	for name, repeatable := range want {
+		f, ok := flags[name]
+		if !ok {
+			t.Errorf("flag %s not parsed, got %v", name, p.Flags)
+			continue
+		}
+		if f.Repeatable != repeatable {
+			t.Errorf("%s: Repeatable = %v, want %v", name, f.Repeatable, repeatable)
+		}
+	}
+	if flags["--verbose"].TakesValue() {
+		t.Error("count flag --verbose should not take a value")
+	}
+	if !flags["--tag"].TakesValue() {
+		t.Error("--tag should take a value")
+	}
+}
+
+func TestParseValueCompletions(t *testing.T) {
+	out := "default\tActive\nkube-system\n_activeHelp_ pick a namespace\nkube-system\n--all-namespaces\n:4\n"
+	got := discovery.ParseValueCompletions(out, "")
+	if want := []string{"default", "kube-system"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ParseValueCompletions() = %v, want %v", got, want)
+	}
+	if got := discovery.ParseValueCompletions("--all\n--amend\n:4\n", "--a"); len(got) != 2 {
+		t.Errorf("flags should be returned when completing a flag, got %v", got)
+	}
+	if got := discovery.ParseValueCompletions("oops\n:1\n", ""); got != nil {
+		t.Errorf("error directive should discard values, got %v", got)
+	}
+}
+
+const mockDeprecatedHelp = `DEPRECATED: tool is deprecated, use newtool instead.
+
+Usage:
+  tool [command]
+
+Available Commands:
+  run         Run a job
+  exec        (deprecated) Run a job; use "tool run" instead
+  serve       Serve the API
+
+Flags:
+      --old string        Old name of --name (deprecated: use --name instead)
+      --name string       Name of the job
+      --legacy            DEPRECATED
+      --list-deprecated   List deprecated APIs
+`
+
+func TestParseHelpOutput_deprecated(t *testing.T) {
+	p := discovery.ParseHelpOutput(mockDeprecatedHelp)
+	if !p.Deprecated || p.ReplacedBy != "newtool" {
+		t.Errorf("command: Deprecated=%v ReplacedBy=%q, want true, \"newtool\"", p.Deprecated, p.ReplacedBy)
+	}
+	if got, ok := p.DeprecatedSubcommands["exec"]; !ok || got != "tool run" {
+		t.Errorf("DeprecatedSubcommands = %v, want exec -> \"tool run\"", p.DeprecatedSubcommands)
+	}
+	if _, ok := p.DeprecatedSubcommands["run"]; ok {
+		t.Error("run should not be deprecated")
+	}
+	want := map[string]struct {
+		deprecated bool
+		replacedBy string
+	}{
+		"--old":             {true, "--name"},
+		"--name":            {false, ""},
+		"--legacy":          {true, ""},
+		"--list-deprecated": {false, ""},
+	}
+	for _, f := range p.Flags {
+		w, ok := want[f.Name]
+		if !ok {
+			continue
+		}
+		delete(want, f.Name)
+		if f.Deprecated != w.deprecated || f.ReplacedBy != w.replacedBy {
+			t.Errorf("%s: Deprecated=%v ReplacedBy=%q, want %v %q", f.Name, f.Deprecated, f.ReplacedBy, w.deprecated, w.replacedBy)
+		}
+	}
+	for name := range want {
+		t.Errorf("flag %s not parsed", name)
+	}
+}
+
+func TestParseHelpOutput_usageGrammar(t *testing.T) {
+	tests := []struct {
+		name, help string
+		want       []models.Positional
+	}{
+		{"ordered caps words", "Usage: cp [OPTION]... [-T] SOURCE DEST\nCopy SOURCE to DEST.\n",
+			[]models.Positional{{Name: "SOURCE", Required: true}, {Name: "DEST", Required: true}}},
+		{"flag values are skipped", "Usage: tool run [-o <out>] [--exec-path[=<path>]] --name=<n> IMAGE [ARG...] [path...]\n",
+			[]models.Positional{{Name: "IMAGE", Required: true}, {Name: "path", Variadic: true}}},
+		{"alternatives share a group", "Usage: tool fetch (<url> | <file>) [<dest>]\n",
+			[]models.Positional{{Name: "url", Required: true, Group: 1}, {Name: "file", Required: true, Group: 1}, {Name: "dest"}}},
+		{"variadic spellings", "Usage: tool add <src>... [<pathspec>...] <x...>\n",
+			[]models.Positional{{Name: "src", Required: true, Variadic: true}, {Name: "pathspec", Variadic: true}, {Name: "x", Required: true, Variadic: true}}},
+		{"later forms are optional", "usage: git checkout [<options>] <branch>\n   or: git checkout [<options>] [<branch>] -- <file>...\n",
+			[]models.Positional{{Name: "branch", Required: true}, {Name: "file", Variadic: true}}},
+		{"short usage line", "Usage: curl [options...] <url>\n -d, --data <data>          HTTP POST data\n",
+			[]models.Positional{{Name: "url", Required: true}}},
+		{"prose after usage is ignored", "Usage: tar [OPTION...] [FILE]...\nGNU 'tar' saves files into an ARCHIVE.\n",
+			[]models.Positional{{Name: "FILE", Variadic: true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := discovery.ParseHelpOutput(tt.help).Positionals
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Positionals =\n  %+v\nwant\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// mockDocoptHelp is the docopt example help, naval_fate.
+const mockDocoptHelp = `Naval Fate.
+
+Usage:
+  naval_fate ship new <name>...
+  naval_fate ship <name> move <x> <y> [--speed=<kn>]
+  naval_fate ship shoot <x> <y>
+  naval_fate mine (set|remove) <x> <y> [--moored | --drifting]
+  naval_fate (-h | --help)
+  naval_fate --version
+
+Options:
+  -h --help     Show this screen.
+  --version     Show version.
+  --speed=<kn>  Speed in knots [default: 10].
+  --moored      Moored (anchored) mine.
+  --drifting    Drifting mine.
+  -o FILE --output=FILE  Write to FILE [default: out.txt].
+`
+
+const mockValueStyleHelp = `Usage: tool [OPTIONS]
+
+Options:
+  -o, --output=FILE        write to FILE
+      --color[=WHEN]       colorize the output
+  -n, --count <n>          how many to make
+      --level LEVEL        log level
+  -q, --quiet              say less
+  -j <jobs>                parallel jobs
+`
+
+func TestParseHelpOutput_valueStyle(t *testing.T) {
+	p := discovery.ParseHelpOutput(mockValueStyleHelp)
+	got := map[string]string{}
+	for _, f := range p.Flags {
+		got[f.Name] = f.ValueStyle
+	}
+	want := map[string]string{
+		"--output": models.ValueStyleEquals,
+		"--color":  models.ValueStyleEquals,
+		"--count":  models.ValueStyleSpace,
+		"--level":  models.ValueStyleSpace,
+		"--quiet":  "",
+		"-j":       models.ValueStyleSpace,
+	}
+	for name, style := range want {
+		if s, ok := got[name]; !ok || s != style {
+			t.Errorf("%s: ValueStyle = %q (parsed: %v), want %q", name, s, ok, style)
+		}
+	}
+
+	docopt := discovery.ParseHelpOutputFor(mockDocoptHelp, "naval_fate")
+	for _, f := range docopt.Flags {
+		if f.Name == "--output" && f.ValueStyle != models.ValueStyleEquals {
+			t.Errorf("docopt --output=FILE: ValueStyle = %q, want equals", f.ValueStyle)
+		}
+	}
+}
+
+const mockNegatableHelp = `Usage: tool [OPTIONS]
+
+Options:
+      --[no-]progress       show progress
+      --color               colorize the output
+      --no-color            do not colorize the output
+      --no-verify           skip the hooks
+      --cache <dir>         cache directory
+      --no-cache <n>        not a negation: takes a value
+`
+
+func TestParseHelpOutput_negatable(t *testing.T) {
+	p := discovery.ParseHelpOutput(mockNegatableHelp)
+	var got []string
+	for _, f := range p.Flags {
+		got = append(got, fmt.Sprintf("%s:%v", f.Name, f.Negatable))
+	}
+	want := "--progress:true --color:true --no-verify:false --cache:false --no-cache:false"
+	if strings.Join(got, " ") != want {
+		t.Errorf("flags = %s\nwant    %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestParseHelpOutput_docopt(t *testing.T) {
+	p := discovery.ParseHelpOutputFor(mockDocoptHelp, "naval_fate")
+	if got := strings.Join(p.Subcommands, ","); got != "ship,mine" {
+		t.Errorf("Subcommands = %s, want ship,mine", got)
+	}
+	if len(p.Positionals) != 0 {
+		t.Errorf("root Positionals = %+v, want none", p.Positionals)
+	}
+	root := &models.Node{Name: "naval_fate", Flags: p.Flags, Children: p.Children}
+
+	tests := []struct {
+		path        string
+		positionals string
+		flags       string
+	}{
+		{"", "", "--help,--version,--output"},
+		{"ship", "name", ""},
+		{"ship new", "name...", ""},
+		{"ship move", "x,y", "--speed"},
+		{"ship shoot", "x,y", ""},
+		{"mine set", "x,y", "--moored,--drifting"},
+		{"mine remove", "x,y", "--moored,--drifting"},
+	}
+	for _, tt := range tests {
+		n := root.FindPath(strings.Fields(tt.path))
+		if n == nil {
+			t.Errorf("%q: command not found", tt.path)
+			continue
+		}
+		if tt.path != "" && strings.Join(n.FullPath, " ") != tt.path {
+			t.Errorf("%q: FullPath = %v", tt.path, n.FullPath)
+		}
+		var pos, flags []string
+		for _, q := range n.Positionals {
+			name := q.Name
+			if q.Variadic {
+				name += "..."
+			}
+			if !q.Required {
+				t.Errorf("%q: positional %s should be required", tt.path, q.Name)
+			}
+			pos = append(pos, name)
+		}
+		for _, f := range n.Flags {
+			flags = append(flags, f.Name)
+		}
+		if got := strings.Join(pos, ","); got != tt.positionals {
+			t.Errorf("%q: positionals = %s, want %s", tt.path, got, tt.positionals)
+		}
+		if got := strings.Join(flags, ","); got != tt.flags {
+			t.Errorf("%q: flags = %s, want %s", tt.path, got, tt.flags)
+		}
+	}
+
+	if f := root.FindPath([]string{"ship", "move"}).Flags[0]; f.ValueType != "kn" || f.Default != "10" {
+		t.Errorf("--speed: ValueType=%q Default=%q, want kn, 10", f.ValueType, f.Default)
+	}
+	if f := p.Flags[2]; f.ShortName != "o" || f.ValueType != "FILE" || f.Default != "out.txt" {
+		t.Errorf("--output: ShortName=%q ValueType=%q Default=%q, want o, FILE, out.txt", f.ShortName, f.ValueType, f.Default)
+	}
+}
+
+func TestParseHelpOutput_notDocopt(t *testing.T) {
+	// One usage pattern, or a list of commands, is ordinary help.
+	p := discovery.ParseHelpOutput(mockCobraHelp)
+	if len(p.Children) != 0 {
+		t.Errorf("cobra help parsed as docopt: %v", nodeNames(p.Children))
+	}
+}
+
+func TestParseHelpOutput_argparseChoices(t *testing.T) {
+	tests := []struct {
+		name string
+		help string
+		want string
+	}{
+		{"subparsers listed", `usage: pkgtool [-h] [--verbose] {install,remove,list} ...
+
+positional arguments:
+  {install,remove,list}
+    install             Install a package
+    remove              Remove a package
+    list                List installed packages
+
+options:
+  -h, --help            show this help message and exit
+`, "install,remove,list"},
+		{"usage only", `usage: pkgtool [-h] {install,remove,list-all} ...
+
+options:
+  -h, --help  show this help message and exit
+`, "install,remove,list-all"},
+		// Without "..." the braces are a positional's or a flag's choices.
+		{"choice positional", `usage: fmt.py [-h] [--style {plain,fancy}] {json,yaml} FILE
+
+options:
+  -h, --help  show this help message and exit
+`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := discovery.ParseHelpOutputFor(tt.help, "pkgtool")
+			if got := strings.Join(p.Subcommands, ","); got != tt.want {
+				t.Errorf("Subcommands = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHelpOutput_confidence(t *testing.T) {
+	p := discovery.ParseHelpOutput(`tool does things
+
+Usage:
+  tool [command]
+
+Commands:
+  run         Run a job
+  bare
+
+Options:
+  -v, --verbose          Be chatty
+  --style {plain,fancy}
+  --quiet
+
+Examples:
+  --example-only   read from a sample line
+`)
+	flags := map[string]float64{}
+	for _, f := range p.Flags {
+		flags[f.Name] = f.Confidence
+	}
+	for name, want := range map[string]bool{"--verbose": false, "--quiet": false, "--style": true, "--example-only": true} {
+		c, ok := flags[name]
+		if !ok {
+			t.Errorf("flag %s not parsed", name)
+			continue
+		}
+		if c <= 0 || c > 1 {
+			t.Errorf("%s: confidence %v outside (0, 1]", name, c)
+		}
+		if doubtful := c < models.LowConfidence; doubtful != want {
+			t.Errorf("%s: confidence %v, want doubtful=%v", name, c, want)
+		}
+	}
+	if flags["--verbose"] <= flags["--quiet"] {
+		t.Errorf("a described flag should score above an undescribed one: %v", flags)
+	}
+	if run, bare := p.SubcommandConfidence["run"], p.SubcommandConfidence["bare"]; run < models.LowConfidence || bare >= run {
+		t.Errorf("SubcommandConfidence = %v, want run trusted and above bare", p.SubcommandConfidence)
+	}
+}
+
+func TestParseHelpNode(t *testing.T) {
+	node := discovery.ParseHelpNode(mockDocoptHelp, "", nil)
+	if node.Name != "naval_fate" {
+		t.Errorf("Name = %q, want the program of the usage line", node.Name)
+	}
+	move := node.FindPath([]string{"ship", "move"})
+	if move == nil || strings.Join(move.FullPath, " ") != "naval_fate ship move" {
+		t.Errorf("ship move = %+v, want it adopted with its full path", move)
+	}
+
+	node = discovery.ParseHelpNode(mockCobraHelp, "kubectl", nil)
+	if len(node.Children) == 0 {
+		t.Fatal("expected subcommands")
+	}
+	for _, c := range node.Children {
+		if !c.Stub || c.Discovered || c.Confidence == 0 {
+			t.Errorf("%s: Stub=%v Discovered=%v Confidence=%v, want a scored stub", c.Name, c.Stub, c.Discovered, c.Confidence)
+		}
+	}
+}
+
+// mockGroupedHelp lists its flags under several headers, as Godot does.
+const mockGroupedHelp = `Godot Engine v4.2 - https://godotengine.org
+
+Usage: godot [options] [path to scene or 'project.godot' file]
+
+General options:
+  -h, --help                   Display this help message.
+  --version                    Display the version string.
+
+Run options:
+  -e, --editor                 Start the editor instead of running the scene.
+  -p, --project-manager        Start the project manager.
+  --quit                       Quit after the first iteration.
+
+Debug options:
+  -d, --debug                  Debug (local stdout debugger).
+  -b, --breakpoints            Breakpoint list as source::line comma-separated pairs.
+`
+
+func TestParseHelpNode_flagSections(t *testing.T) {
+	node := discovery.ParseHelpNode(mockGroupedHelp, "godot", nil)
+	var got []string
+	for _, s := range node.FlagSections {
+		got = append(got, fmt.Sprintf("%s%v", s.Name, s.Flags))
+	}
+	want := "General options[--help --version] Run options[--editor --project-manager --quit] Debug options[--debug --breakpoints]"
+	if strings.Join(got, " ") != want {
+		t.Errorf("FlagSections = %v, want %s", got, want)
+	}
+	if len(node.Flags) != 7 {
+		t.Errorf("got %d flags, want all 7 in Flags too", len(node.Flags))
+	}
+
+	// One header is no grouping.
+	if node := discovery.ParseHelpNode(mockCobraHelp, "kubectl", nil); len(node.FlagSections) != 0 {
+		t.Errorf("FlagSections of help with one flags header = %+v", node.FlagSections)
+	}
+}
@@ -0,0 +1,24 @@
+package discovery
+
+import (
+	"context"
+	"os/exec"
+	"slices"
+)
+
+// Command returns the command running the executable at path with args, as
+// discovery runs the CLIs it probes. Ending ctx kills the command and the
+// processes it started, so the pagers, wrapped tools and shell pipelines a
+// CLI starts to print its help do not outlive an interrupted or timed-out
+// run: they share a process group, or on Windows a process tree. Windows
+// batch files run through cmd.exe. Under WithVia, path runs through the
+// prefix.
+func Command(ctx context.Context, path string, args ...string) *exec.Cmd {
+	if prefix := viaPrefix(ctx); prefix != nil {
+		args = append(append(slices.Clone(prefix[1:]), path), args...)
+		path = prefix[0]
+	}
+	cmd := newCommand(ctx, path, args...)
+	setProcessGroup(cmd)
+	return cmd
+}
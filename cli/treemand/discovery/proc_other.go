@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package discovery
+
+import "os/exec"
+
+// setProcessGroup leaves cmd as it is where process groups are unavailable:
+// cancelling it kills the process itself, and WaitDelay bounds the wait for
+// anything it started.
+func setProcessGroup(*exec.Cmd) {}
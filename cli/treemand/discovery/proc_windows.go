@@ -0,0 +1,19 @@
+package discovery
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup has cancelling cmd end the processes it started too, as
+// killing a process alone leaves its children, such as the tool a batch
+// file runs, behind.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+		if err := kill.Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
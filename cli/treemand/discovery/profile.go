@@ -0,0 +1,222 @@
+package discovery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// A Profile tunes the help parser to one family of help output. Frameworks
+// lay their help out differently, and a rule that reads one family right
+// misreads another: an indented lowercase word is a command in git's help
+// but a line of prose in an AWS man page. Profiles switch such rules on and
+// off, so fixing the parse of one family does not break the others.
+//
+// The profile is detected from each help text (see DetectProfile), or
+// chosen with --parser-profile. The generic profile applies every rule and
+// is used when no other matches.
+type Profile struct {
+	// Name is how --parser-profile selects the profile.
+	Name string
+	// Description says which CLIs the profile is for.
+	Description string
+
+	// detect reports whether help text is in this profile's family.
+	detect func(text string) bool
+	// sections adds section headers, or overrides the usual kind of one.
+	sections map[string]string
+	// indentedHeaders reads indented lines as section headers too. Most
+	// families only put headers at the margin, where an indented "How to
+	// format output" is a flag's description, not an "Output" section.
+	indentedHeaders bool
+	// looseCommands reads indented words with a description as subcommands
+	// outside any commands section, as in git's help.
+	looseCommands bool
+	// wrappedFlags takes a deeper-indented line after a flag without a
+	// description as its description, as argparse, clap and GNU tools wrap
+	// long flag specs.
+	wrappedFlags bool
+	// sectionlessFlags trusts flags listed outside any section: GNU tools
+	// list their options under a sentence, not a header.
+	sectionlessFlags bool
+	// awsFlags reads "--flag (type)" lines, described on the next line.
+	awsFlags bool
+	// descriptionFlags trusts flags listed in the DESCRIPTION section: BSD
+	// man pages have no OPTIONS section.
+	descriptionFlags bool
+	// metavarFlags reads argparse's "-o FILE, --output FILE" flag lines,
+	// which name the value after each spelling.
+	metavarFlags bool
+	// choiceValues reads "--style {plain,fancy}" as a flag taking one of
+	// the choices, not as the flag --style described "{plain,fancy}".
+	choiceValues bool
+}
+
+var (
+	// cobraHelpRe matches cobra's closing hint:
+	// Use "kubectl [command] --help" ...
+	cobraHelpRe = regexp.MustCompile(`(?m)^Use "\S+.* \[command\] --help"`)
+	// clapHelpRe matches how clap describes its own --help flag.
+	clapHelpRe = regexp.MustCompile(`(?m)^\s+-h, --help\s+Prints? help\b`)
+	// gnuUsageRe matches GNU's usage line: "Usage: ls [OPTION]... [FILE]...".
+	gnuUsageRe = regexp.MustCompile(`(?m)^Usage: \S+ \[OPTION\]\.\.\.`)
+	// metavarFlagRe matches argparse's flag lines with a value:
+	// "  -o FILE, --output FILE  where to write".
+	metavarFlagRe = regexp.MustCompile(`^\s{2,8}(-[A-Za-z0-9]) ([A-Z][A-Z0-9_]*|\{[^}]*\}), (--[A-Za-z][A-Za-z0-9_-]*) (?:[A-Z][A-Z0-9_]*|\{[^}]*\})(?:\s{2,}(.*))?$`)
+	// manHeaderLineRe matches a man page section header such as "DESCRIPTION".
+	manHeaderLineRe = regexp.MustCompile(`(?m)^(SYNOPSIS|DESCRIPTION|OPTIONS)\s*$`)
+)
+
+// Profiles lists the parser profiles in detection order: the first whose
+// family the help text is in is used.
+var Profiles = []*Profile{
+	{
+		Name:        "cobra",
+		Description: "Go CLIs built with cobra: kubectl, gh, docker, hugo",
+		detect: func(text string) bool {
+			return cobraHelpRe.MatchString(text) ||
+				(strings.Contains(text, "Available Commands:") && strings.Contains(text, "Flags:"))
+		},
+	},
+	{
+		Name:        "clap",
+		Description: "Rust CLIs built with clap: ripgrep, cargo, fd",
+		detect:      clapHelpRe.MatchString,
+		sections: map[string]string{
+			"arguments": secArgs,
+			"args":      secArgs,
+		},
+		wrappedFlags: true,
+	},
+	{
+		Name:        "argparse",
+		Description: "Python CLIs built with argparse",
+		detect: func(text string) bool {
+			return strings.Contains(text, "show this help message and exit")
+		},
+		sections: map[string]string{
+			"positional arguments": secArgs,
+		},
+		wrappedFlags: true,
+		metavarFlags: true,
+		choiceValues: true,
+	},
+	{
+		Name:        "aws",
+		Description: "AWS CLI man-page help",
+		detect: func(text string) bool {
+			return strings.Contains(text, "AVAILABLE COMMANDS") || strings.Contains(text, "AVAILABLE SERVICES") ||
+				firstMatchLine(text, awsFlagRe) != ""
+		},
+		awsFlags: true,
+	},
+	{
+		Name:        "bsd",
+		Description: "BSD and macOS man pages, which list flags under DESCRIPTION",
+		detect: func(text string) bool {
+			headers := manHeaderLineRe.FindAllString(text, -1)
+			has := func(h string) bool {
+				for _, got := range headers {
+					if strings.TrimSpace(got) == h {
+						return true
+					}
+				}
+				return false
+			}
+			return has("SYNOPSIS") && has("DESCRIPTION") && !has("OPTIONS")
+		},
+		sections: map[string]string{
+			"synopsis": secUsage,
+		},
+		wrappedFlags:     true,
+		descriptionFlags: true,
+	},
+	{
+		Name:        "gnu",
+		Description: "GNU tools: coreutils, grep, tar",
+		detect: func(text string) bool {
+			return gnuUsageRe.MatchString(text) || strings.Contains(text, "Report bugs to")
+		},
+		wrappedFlags:     true,
+		sectionlessFlags: true,
+	},
+	genericProfile,
+}
+
+// genericProfile applies every rule; it matches any help text.
+var genericProfile = &Profile{
+	Name:            "generic",
+	Description:     "any other help output; applies every rule",
+	detect:          func(string) bool { return true },
+	indentedHeaders: true,
+	looseCommands:   true,
+	awsFlags:        true,
+}
+
+// firstMatchLine returns the first line of text re matches, or "".
+func firstMatchLine(text string, re *regexp.Regexp) string {
+	for _, line := range strings.Split(text, "\n") {
+		if re.MatchString(line) {
+			return line
+		}
+	}
+	return ""
+}
+
+// DetectProfile returns the profile for help text.
+func DetectProfile(text string) *Profile {
+	for _, p := range Profiles {
+		if p.detect(text) {
+			return p
+		}
+	}
+	return genericProfile
+}
+
+// LookupProfile returns the profile called name. "auto" and "" return nil,
+// which detects the profile of each help text.
+func LookupProfile(name string) (*Profile, error) {
+	if name == "" || name == "auto" {
+		return nil, nil //nolint:nilnil // nil selects detection
+	}
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown parser profile %q (want auto, %s)", name, strings.Join(ProfileNames(), ", "))
+}
+
+// ProfileNames returns the names of Profiles.
+func ProfileNames() []string {
+	names := make([]string, len(Profiles))
+	for i, p := range Profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// detectSection returns the section line heads, if it is a header: the
+// profile's own headers first, then the usual ones.
+func (p *Profile) detectSection(line string) string {
+	if !p.indentedHeaders && indentWidth(line) > 0 {
+		return secNone
+	}
+	lower := strings.ToLower(strings.TrimSpace(line))
+	if s, ok := p.sections[strings.TrimSuffix(lower, ":")]; ok {
+		return s
+	}
+	return detectSection(lower)
+}
+
+// parseFlag is parseFlag with the profile's own flag layouts first.
+func (p *Profile) parseFlag(line string) (models.Flag, bool) {
+	if m := metavarFlagRe.FindStringSubmatch(line); m != nil && p.metavarFlags {
+		f := models.Flag{Name: m[3], ShortName: m[1][1:], ValueType: m[2], ValueStyle: models.ValueStyleSpace, Description: m[4]}
+		f.Repeatable = isRepeatable(f)
+		return f, true
+	}
+	return parseFlag(line)
+}
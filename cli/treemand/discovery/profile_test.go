@@ -0,0 +1,96 @@
+package discovery_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aallbrig/treemand/discovery"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the help corpus")
+
+// TestParseCorpus parses each help text in testdata/help and compares the
+// result with its golden file. Files are named <profile>-<cli>.txt after
+// the profile that should be detected for them. After a deliberate parser
+// change, regenerate the golden files and review their diff:
+//
+//	go test ./discovery -run TestParseCorpus -update
+func TestParseCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "help", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no help texts in testdata/help")
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".txt")
+		t.Run(name, func(t *testing.T) {
+			text, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed := discovery.ParseHelpOutput(string(text))
+			if want, _, _ := strings.Cut(name, "-"); parsed.Profile != want {
+				t.Errorf("detected profile %q, want %q", parsed.Profile, want)
+			}
+			got, err := json.MarshalIndent(parsed, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			golden := strings.TrimSuffix(file, ".txt") + ".golden.json"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("parse of %s differs from %s (run with -update and review the diff):\n%s", file, golden, got)
+			}
+		})
+	}
+}
+
+func TestLookupProfile(t *testing.T) {
+	for _, name := range []string{"", "auto"} {
+		if p, err := discovery.LookupProfile(name); p != nil || err != nil {
+			t.Errorf("LookupProfile(%q) = %v, %v; want nil, nil", name, p, err)
+		}
+	}
+	for _, name := range discovery.ProfileNames() {
+		if p, err := discovery.LookupProfile(name); err != nil || p.Name != name {
+			t.Errorf("LookupProfile(%q) = %v, %v", name, p, err)
+		}
+	}
+	if _, err := discovery.LookupProfile("cobbra"); err == nil || !strings.Contains(err.Error(), "cobra") {
+		t.Errorf("LookupProfile(cobbra) error = %v, want one listing the profiles", err)
+	}
+}
+
+func TestParseHelpOutputWith_profile(t *testing.T) {
+	text, err := os.ReadFile(filepath.Join("testdata", "help", "generic-git.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := discovery.ParseHelpOutput(string(text)).Subcommands; len(got) == 0 {
+		t.Fatal("generic profile found no commands in git's help")
+	}
+	// cobra lists its commands under a header, so its profile does not
+	// read git's free-form command lines.
+	cobra, _ := discovery.LookupProfile("cobra")
+	parsed := discovery.ParseHelpOutputWith(string(text), "git", cobra)
+	if parsed.Profile != "cobra" || len(parsed.Subcommands) != 0 {
+		t.Errorf("with the cobra profile: profile %q, subcommands %v; want cobra, none", parsed.Profile, parsed.Subcommands)
+	}
+}
@@ -0,0 +1,103 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// errHelpInteractive reports that a command prompted for input instead of
+// printing help. Retrying it would only prompt again.
+var errHelpInteractive = errors.New("waiting for input")
+
+// promptIdle is how long a command must stay silent after printing what
+// looks like a prompt before it is considered to be waiting for input.
+var promptIdle = 500 * time.Millisecond
+
+// promptRe matches the end of output that asks for input: a question,
+// a "[y/N]" choice, or a "name:" / REPL ">" prompt.
+var promptRe = regexp.MustCompile(`(?i)(?:[?:>]|\[[yn]/[yn]\]|\([yn]/[yn]\))\s*$`)
+
+// probeOutput collects a probed command's stdout and stderr and notes when
+// they last grew.
+type probeOutput struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	last time.Time
+}
+
+func (o *probeOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.last = time.Now()
+	return o.buf.Write(p)
+}
+
+func (o *probeOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// awaitingInput reports whether the output ends in a prompt — an
+// unterminated last line matching promptRe — and has not grown for idle.
+// Help output ends with a newline; prompts leave the cursor on their line.
+func (o *probeOutput) awaitingInput(idle time.Duration) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	b := o.buf.Bytes()
+	if len(b) == 0 || b[len(b)-1] == '\n' || time.Since(o.last) < idle {
+		return false
+	}
+	line := b[bytes.LastIndexByte(b, '\n')+1:]
+	return promptRe.MatchString(stripANSI(string(line)))
+}
+
+// runProbe runs path with args and returns its combined output. Stdin is
+// /dev/null so prompts that read it get EOF instead of blocking. A command
+// that still sits at a prompt (e.g. one reading the terminal directly) is
+// killed at once rather than left to run into ctx's deadline; interactive
+// reports that it was.
+func runProbe(ctx context.Context, path string, args []string) (out string, interactive bool) {
+	cmd := Command(ctx, path, args...)
+	cmd.Env = append(os.Environ(), pagerEnv...)
+	cmd.Stdin = nil // os.DevNull
+	// Background processes the command left holding its output open must
+	// not keep Wait from returning once it was killed.
+	cmd.WaitDelay = time.Second
+	buf := &probeOutput{}
+	cmd.Stdout, cmd.Stderr = buf, buf
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		logExec(ctx, cmd, start, 0, err)
+		return "", false
+	}
+	var waitErr error
+	done := make(chan struct{})
+	go func() {
+		waitErr = cmd.Wait()
+		close(done)
+	}()
+	tick := time.NewTicker(promptIdle / 5)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			out = buf.String()
+			logExec(ctx, cmd, start, len(out), waitErr)
+			return out, false
+		case <-tick.C:
+			if buf.awaitingInput(promptIdle) {
+				_ = cmd.Cancel()
+				<-done
+				out = buf.String()
+				logExec(ctx, cmd, start, len(out), errHelpInteractive)
+				return out, true
+			}
+		}
+	}
+}
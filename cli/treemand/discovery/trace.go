@@ -0,0 +1,77 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ExecRecord describes one command discovery ran. A discovery trace (see
+// WithTrace) holds one per line, as JSON.
+type ExecRecord struct {
+	Time    time.Time `json:"time"`
+	Command []string  `json:"command"`
+	// DurationMS is how long the command ran, in milliseconds.
+	DurationMS int64 `json:"duration_ms"`
+	// ExitCode is the command's exit status; -1 when it was killed or did
+	// not start.
+	ExitCode    int    `json:"exit_code"`
+	OutputBytes int    `json:"output_bytes"`
+	Error       string `json:"error,omitempty"`
+}
+
+// traceKey is the context key of a discovery trace.
+type traceKey struct{}
+
+// trace writes ExecRecords as JSON Lines.
+type trace struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// WithTrace returns a copy of ctx under which discovery writes an
+// ExecRecord to w for every command it runs, one JSON object per line.
+// Writes are serialized, so w need not be safe for concurrent use.
+func WithTrace(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, traceKey{}, &trace{enc: json.NewEncoder(w)})
+}
+
+// logExec reports cmd, started at start, having printed outLen bytes and
+// ended with err: in the debug log, and to the trace of ctx if it has one.
+func logExec(ctx context.Context, cmd *exec.Cmd, start time.Time, outLen int, err error) {
+	rec := ExecRecord{
+		Time:        start,
+		Command:     cmd.Args,
+		DurationMS:  time.Since(start).Milliseconds(),
+		ExitCode:    -1,
+		OutputBytes: outLen,
+	}
+	if cmd.ProcessState != nil {
+		rec.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	log.Debug().
+		Str("command", strings.Join(cmd.Args, " ")).
+		Int64("ms", rec.DurationMS).
+		Int("exit", rec.ExitCode).
+		Int("bytes", outLen).
+		Err(err).
+		Msg("exec")
+	t, ok := ctx.Value(traceKey{}).(*trace)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.enc.Encode(rec); err != nil {
+		log.Warn().Err(err).Msg("could not write trace")
+	}
+}
@@ -0,0 +1,351 @@
+package discovery
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// Usage lines are parsed with a small grammar rather than by matching
+// <name> and [name] anywhere, so that positionals keep their order, know
+// whether they sit in an optional group, and which of them are
+// alternatives to each other:
+//
+//	alt  = seq { "|" seq }
+//	seq  = { item }
+//	item = atom [ "..." ]
+//	atom = "[" alt "]" | "(" alt ")" | "{" alt "}" | <name> | WORD
+//
+// WORDs are command names, flags, flag values and literal choices; only
+// all-caps WORDs (FILE, IMAGE) and lone words in brackets ([file]) are
+// positionals. Unbalanced brackets are closed at the end of the line.
+//
+// argparse writes a choice of subcommands as "{install,remove} ...": a
+// repeated brace group of comma-separated names, which are commands.
+
+var (
+	// usageTokenRe splits a usage line into grammar tokens.
+	usageTokenRe = regexp.MustCompile(`\.\.\.|[\[\](){}|]|<[^<>]*>|[^\s\[\](){}|<>]+`)
+	// usageNameRe matches a positional name inside <...>.
+	usageNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+	// usageCapsRe matches an all-caps WORD naming a positional.
+	usageCapsRe = regexp.MustCompile(`^[A-Z][A-Z0-9_-]+$`)
+)
+
+type usageKind int
+
+const (
+	usageWord   usageKind = iota // a <name> or WORD
+	usageSeq                     // items in order
+	usageAlt                     // alternatives separated by |
+	usageOpt                     // [...]
+	usageChoice                  // {...}
+)
+
+// usageNode is a node of a parsed usage line.
+type usageNode struct {
+	kind     usageKind
+	text     string // usageWord only
+	repeat   bool   // followed by "..."
+	children []*usageNode
+}
+
+// usageParser is a recursive-descent parser over the tokens of one line.
+type usageParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *usageParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+// alt parses alternatives up to the closing token end ("" = end of line).
+func (p *usageParser) alt(end string) *usageNode {
+	n := &usageNode{kind: usageAlt, children: []*usageNode{p.seq(end)}}
+	for p.peek() == "|" {
+		p.pos++
+		n.children = append(n.children, p.seq(end))
+	}
+	if len(n.children) == 1 {
+		return n.children[0]
+	}
+	return n
+}
+
+func (p *usageParser) seq(end string) *usageNode {
+	n := &usageNode{kind: usageSeq}
+	for p.pos < len(p.toks) {
+		tok := p.peek()
+		if tok == "|" || tok == end || tok == "]" || tok == ")" || tok == "}" {
+			// A stray closer of another kind ends nothing: skip it.
+			if tok != "|" && tok != end {
+				p.pos++
+				continue
+			}
+			break
+		}
+		if item := p.item(); item != nil {
+			n.children = append(n.children, item)
+		}
+	}
+	return n
+}
+
+func (p *usageParser) item() *usageNode {
+	tok := p.toks[p.pos]
+	p.pos++
+	var n *usageNode
+	switch tok {
+	case "[", "(", "{":
+		end := map[string]string{"[": "]", "(": ")", "{": "}"}[tok]
+		n = p.alt(end)
+		if p.peek() == end {
+			p.pos++
+		}
+		switch tok {
+		case "[":
+			n = &usageNode{kind: usageOpt, children: []*usageNode{n}}
+		case "{":
+			n = &usageNode{kind: usageChoice, children: []*usageNode{n}}
+		}
+	case "...":
+		return nil // "..." with nothing before it
+	default:
+		n = &usageNode{kind: usageWord, text: tok}
+	}
+	if p.peek() == "..." {
+		p.pos++
+		n.repeat = true
+	}
+	return n
+}
+
+// parseUsage parses one usage line, without its "usage:" prefix.
+func parseUsage(line string) *usageNode {
+	p := &usageParser{toks: usageTokenRe.FindAllString(line, -1)}
+	return p.alt("")
+}
+
+// usageWalker collects the positionals and argparse subcommands of a
+// parsed usage line.
+type usageWalker struct {
+	out       []models.Positional
+	commands  []string
+	nextGroup int
+	// angled makes every <name> a positional, placeholder names included:
+	// docopt names each positional so.
+	angled bool
+}
+
+// walk visits n; optional and variadic are inherited from enclosing groups.
+func (w *usageWalker) walk(n *usageNode, optional, variadic bool) {
+	variadic = variadic || n.repeat
+	switch n.kind {
+	case usageWord:
+		if name, dots, ok := w.positional(n.text, false); ok {
+			w.add(models.Positional{Name: name, Required: !optional, Variadic: variadic || dots})
+		}
+	case usageOpt:
+		// A lone word in brackets is a positional however it is spelled:
+		// [file], [FILE...].
+		if inner := n.children[0]; inner.kind == usageSeq && len(inner.children) == 1 && inner.children[0].kind == usageWord {
+			word := inner.children[0]
+			if name, dots, ok := w.positional(word.text, true); ok {
+				w.add(models.Positional{Name: name, Variadic: variadic || word.repeat || dots})
+			}
+			return
+		}
+		w.walk(n.children[0], true, variadic)
+	case usageChoice:
+		if inner := n.children[0]; n.repeat && inner.kind == usageSeq && len(inner.children) == 1 && inner.children[0].kind == usageWord {
+			w.addCommands(strings.Split(inner.children[0].text, ","))
+			return
+		}
+		w.walk(n.children[0], optional, variadic)
+	case usageSeq:
+		// The word after a flag is its value: -o FILE, --out=<file>.
+		flagValue := false
+		for _, c := range n.children {
+			if c.kind == usageWord {
+				if flagValue {
+					flagValue = false
+					continue
+				}
+				if isFlagWord(c.text) {
+					flagValue = !strings.Contains(strings.TrimSuffix(c.text, "="), "=")
+					continue
+				}
+			}
+			flagValue = false
+			w.walk(c, optional, variadic)
+		}
+	case usageAlt:
+		w.walkAlt(n, optional, variadic)
+	}
+}
+
+// walkAlt visits alternatives. When every alternative is one positional
+// (<url> | <file>), they share a Group: the command takes one of them.
+// Otherwise their positionals are only needed on some paths, so optional.
+func (w *usageWalker) walkAlt(n *usageNode, optional, variadic bool) {
+	branches := make([][]models.Positional, len(n.children))
+	single := 0
+	for i, c := range n.children {
+		sub := &usageWalker{nextGroup: w.nextGroup, angled: w.angled}
+		sub.walk(c, optional, variadic)
+		w.nextGroup = sub.nextGroup
+		w.addCommands(sub.commands)
+		branches[i] = sub.out
+		switch len(sub.out) {
+		case 0:
+		case 1:
+			single++
+		default:
+			single = -len(n.children)
+		}
+	}
+	group := 0
+	if single >= 2 {
+		w.nextGroup++
+		group = w.nextGroup
+	}
+	for _, b := range branches {
+		for _, p := range b {
+			if group != 0 {
+				p.Group = group
+			} else if len(n.children) > 1 {
+				p.Required = false
+			}
+			w.add(p)
+		}
+	}
+}
+
+// add appends p unless a positional of that name was seen already.
+func (w *usageWalker) add(p models.Positional) {
+	for _, q := range w.out {
+		if q.Name == p.Name {
+			return
+		}
+	}
+	w.out = append(w.out, p)
+}
+
+// addCommands appends the valid command names among names not seen already.
+func (w *usageWalker) addCommands(names []string) {
+	for _, name := range names {
+		if validCmdNameRe.MatchString(name) && !slices.Contains(w.commands, name) {
+			w.commands = append(w.commands, name)
+		}
+	}
+}
+
+// usagePositional returns the positional named by a usage WORD — <name>,
+// an all-caps word, or with anyWord any word — and whether it ends in
+// "..." (variadic). Flags, literals and placeholders such as [OPTIONS] or
+// <command> name none.
+func usagePositional(text string, anyWord bool) (name string, dots bool, ok bool) {
+	name, angled := strings.CutPrefix(text, "<")
+	if angled {
+		name = strings.TrimSuffix(name, ">")
+	}
+	trimmed := strings.TrimRight(name, ".+")
+	dots = trimmed != name
+	name = trimmed
+	switch {
+	case !angled && !anyWord && !usageCapsRe.MatchString(name),
+		!usageNameRe.MatchString(name),
+		positionalPlaceholders[strings.ToUpper(name)]:
+		return "", false, false
+	}
+	return name, dots, true
+}
+
+// positional is usagePositional, except that with w.angled every <name>
+// is a positional.
+func (w *usageWalker) positional(text string, anyWord bool) (string, bool, bool) {
+	if name, dots, ok := usagePositional(text, anyWord); ok || !w.angled || !strings.HasPrefix(text, "<") {
+		return name, dots, ok
+	}
+	name := strings.TrimRight(text, ".+")
+	dots := name != text
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "<"), ">")
+	return name, dots, usageNameRe.MatchString(name)
+}
+
+// isFlagWord reports whether a usage WORD is a flag (-o, --out, --out=) or
+// the "=" of a [=<value>] suffix. "--", which ends the flags, is not.
+func isFlagWord(s string) bool {
+	return s == "=" || (strings.HasPrefix(s, "-") && s != "--" && s != "-")
+}
+
+// usageProgram returns the program named by the first usage line of lines
+// ("Usage: git [--version]", or "Usage:" and the line after it), without
+// its directory, or "cli" when there is none.
+func usageProgram(lines []string) string {
+	for i, line := range lines {
+		before, rest, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(before), "usage") {
+			continue
+		}
+		for _, l := range append([]string{rest}, lines[i+1:]...) {
+			if fields := strings.Fields(l); len(fields) > 0 {
+				return path.Base(fields[0])
+			}
+		}
+	}
+	return "cli"
+}
+
+// parseUsageLines extracts the positionals of usage lines in order, and the
+// subcommands they offer as argparse choices. A positional named on several
+// lines is kept once, as first seen; Group numbers stay distinct across
+// lines. Lines after the first are other forms of the command, so
+// positionals only they name are optional.
+//
+// Usage sections often run on into prose or flag lists, so a line counts
+// only when it starts with the program named by the first one ("or:" and
+// "usage:" prefixes aside), or continues an accepted line with a bracket.
+func parseUsageLines(lines []string) ([]models.Positional, []string) {
+	w := &usageWalker{}
+	prog, prev, forms := "", false, 0
+	for _, line := range lines {
+		if idx := strings.Index(strings.ToLower(line), "usage:"); idx >= 0 {
+			line = line[idx+len("usage:"):]
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.EqualFold(fields[0], "or:") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 || strings.EqualFold(fields[0], "synopsis") {
+			continue
+		}
+		switch first := fields[0]; {
+		case prog == "":
+			prog = first
+			forms++
+		case first == prog:
+			forms++
+		case prev && strings.ContainsAny(first[:1], "[<({"):
+		default:
+			prev = false
+			continue
+		}
+		prev = true
+		n := len(w.out)
+		w.walk(parseUsage(strings.Join(fields, " ")), false, false)
+		if forms > 1 {
+			for i := n; i < len(w.out); i++ {
+				w.out[i].Required = false
+			}
+		}
+	}
+	return w.out, w.commands
+}
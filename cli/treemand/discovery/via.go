@@ -0,0 +1,61 @@
+package discovery
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// viaKey is the context key of the command prefix discovery runs CLIs
+// through.
+type viaKey struct{}
+
+// ParseVia splits a --via value into the command prefix it names, at
+// spaces; quotes are not read. "wsl" runs the CLI with wsl --exec, without
+// the distribution's shell reading its arguments, and "docker run" and
+// "podman run" remove each container they start (--rm).
+func ParseVia(via string) []string {
+	prefix := strings.Fields(via)
+	if len(prefix) == 0 {
+		return nil
+	}
+	switch strings.TrimSuffix(strings.ToLower(filepath.Base(prefix[0])), ".exe") {
+	case "wsl":
+		if !slices.Contains(prefix, "--exec") && !slices.Contains(prefix, "-e") {
+			prefix = append(prefix, "--exec")
+		}
+	case "docker", "podman":
+		if len(prefix) > 1 && prefix[1] == "run" && !slices.Contains(prefix, "--rm") {
+			prefix = slices.Insert(prefix, 2, "--rm")
+		}
+	}
+	return prefix
+}
+
+// WithVia returns a copy of ctx under which discovery runs every command
+// through prefix: prefix's words, then the CLI and its arguments. The CLI
+// is then looked for where prefix runs it, in a container or a WSL
+// distribution, not on this system. An empty prefix returns ctx.
+func WithVia(ctx context.Context, prefix []string) context.Context {
+	if len(prefix) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, viaKey{}, prefix)
+}
+
+// viaPrefix returns the prefix of WithVia in ctx, or nil.
+func viaPrefix(ctx context.Context) []string {
+	prefix, _ := ctx.Value(viaKey{}).([]string)
+	return prefix
+}
+
+// BinaryPath returns what discovery runs for cliName under ctx: its path
+// (see ResolveBinary), or cliName itself when ctx runs commands through a
+// prefix (see WithVia).
+func BinaryPath(ctx context.Context, cliName string) (string, error) {
+	if viaPrefix(ctx) != nil {
+		return cliName, nil
+	}
+	return ResolveBinary(cliName)
+}
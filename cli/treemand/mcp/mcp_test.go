@@ -0,0 +1,179 @@
+package mcp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aallbrig/treemand/mcp"
+	"github.com/aallbrig/treemand/models"
+)
+
+func sampleTree() *models.Node {
+	return &models.Node{
+		Name:     "git",
+		FullPath: []string{"git"},
+		Flags:    []models.Flag{{Name: "--version", ValueType: "bool"}},
+		Children: []*models.Node{
+			{
+				Name:        "commit",
+				FullPath:    []string{"git", "commit"},
+				Description: "Record changes to the repository",
+				Flags: []models.Flag{
+					{Name: "--message", ShortName: "m", ValueType: "string"},
+					{Name: "--amend", ValueType: "bool"},
+					{Name: "--trailer", ValueType: "stringArray", Repeatable: true},
+					{Name: "--verbose", ShortName: "v", ValueType: "count", Repeatable: true},
+				},
+			},
+			{
+				Name:     "remote",
+				FullPath: []string{"git", "remote"},
+				Children: []*models.Node{{
+					Name:        "add",
+					FullPath:    []string{"git", "remote", "add"},
+					Description: "Add a remote",
+					Positionals: []models.Positional{
+						{Name: "name", Required: true},
+						{Name: "url", Required: true},
+					},
+				}},
+			},
+		},
+	}
+}
+
+type rpcResponse struct {
+	ID     int `json:"id"`
+	Result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool             `json:"isError"`
+		Tools   []map[string]any `json:"tools"`
+	} `json:"result"`
+	Error *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+// serve sends each request line to a fresh server and returns the decoded
+// responses.
+func serve(t *testing.T, lines ...string) []rpcResponse {
+	t.Helper()
+	loads := 0
+	load := func(_ context.Context, cli string) (*models.Node, error) {
+		if cli != "git" {
+			return nil, fmt.Errorf("command %q not found", cli)
+		}
+		loads++
+		return sampleTree(), nil
+	}
+	var out bytes.Buffer
+	in := strings.NewReader(strings.Join(lines, "\n") + "\n")
+	if err := mcp.NewServer(load, "test").Serve(context.Background(), in, &out); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	if loads > 1 {
+		t.Errorf("tree loaded %d times, want at most once", loads)
+	}
+	var resps []rpcResponse
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r rpcResponse
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		resps = append(resps, r)
+	}
+	return resps
+}
+
+func call(id int, tool string, args string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q,"arguments":%s}}`, id, tool, args)
+}
+
+func text(r rpcResponse) string {
+	if len(r.Result.Content) == 0 {
+		return ""
+	}
+	return r.Result.Content[0].Text
+}
+
+func TestServe_initializeAndList(t *testing.T) {
+	resps := serve(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"bogus"}`,
+	)
+	if len(resps) != 3 {
+		t.Fatalf("got %d responses, want 3 (notifications are not answered)", len(resps))
+	}
+	var names []string
+	for _, tool := range resps[1].Result.Tools {
+		names = append(names, tool["name"].(string))
+	}
+	want := "build_command discover_cli get_flags search_commands"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("tools/list = %q, want %q", got, want)
+	}
+	if resps[2].Error == nil || resps[2].Error.Code != -32601 {
+		t.Errorf("unknown method should return -32601, got %+v", resps[2].Error)
+	}
+}
+
+func TestServe_tools(t *testing.T) {
+	resps := serve(t,
+		call(1, "discover_cli", `{"cli":"git","commands_only":true}`),
+		call(2, "search_commands", `{"cli":"git","query":"amend"}`),
+		call(3, "get_flags", `{"cli":"git","command":"git commit"}`),
+		call(4, "build_command", `{"cli":"git","command":"commit","flags":{"m":"fix bug","amend":true}}`),
+		call(5, "build_command", `{"cli":"git","command":"remote add","args":["origin"]}`),
+		call(6, "build_command", `{"cli":"git","command":"commit","flags":{"--nope":true}}`),
+		call(7, "get_flags", `{"cli":"git","command":"push"}`),
+		call(8, "discover_cli", `{"cli":"nosuchcli"}`),
+	)
+	if len(resps) != 8 {
+		t.Fatalf("got %d responses, want 8", len(resps))
+	}
+	if got := text(resps[0]); !strings.Contains(got, "remote") || strings.Contains(got, "--message") {
+		t.Errorf("discover_cli commands_only output:\n%s", got)
+	}
+	if got := text(resps[1]); !strings.Contains(got, "git commit") || !strings.Contains(got, "flag --amend") {
+		t.Errorf("search_commands output: %q", got)
+	}
+	if got := text(resps[2]); !strings.Contains(got, `"--message"`) {
+		t.Errorf("get_flags output: %q", got)
+	}
+	if got := text(resps[3]); got != "git commit --amend --message 'fix bug'" {
+		t.Errorf("build_command = %q", got)
+	}
+	for i, want := range map[int]string{4: "missing required argument(s): url", 5: `no flag "--nope"`, 6: `unknown command "git push"`, 7: "not found"} {
+		if !resps[i].Result.IsError || !strings.Contains(text(resps[i]), want) {
+			t.Errorf("response %d: want tool error containing %q, got %+v", i+1, want, resps[i].Result)
+		}
+	}
+}
+
+func TestServe_buildCommandRepeatableFlags(t *testing.T) {
+	resps := serve(t,
+		call(1, "build_command", `{"cli":"git","command":"commit","flags":{"trailer":["a: 1","b: 2"],"v":2}}`),
+		call(2, "build_command", `{"cli":"git","command":"commit","flags":{"m":["x","y"]}}`),
+		call(3, "build_command", `{"cli":"git","command":"commit","flags":{"amend":3}}`),
+	)
+	if len(resps) != 3 {
+		t.Fatalf("got %d responses, want 3", len(resps))
+	}
+	if got, want := text(resps[0]), "git commit --trailer 'a: 1' --trailer 'b: 2' --verbose --verbose"; got != want {
+		t.Errorf("build_command = %q, want %q", got, want)
+	}
+	for i, want := range map[int]string{1: "does not take a list", 2: "does not take a value"} {
+		if !resps[i].Result.IsError || !strings.Contains(text(resps[i]), want) {
+			t.Errorf("response %d: want tool error containing %q, got %+v", i+1, want, resps[i].Result)
+		}
+	}
+}
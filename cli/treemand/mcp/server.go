@@ -0,0 +1,175 @@
+// Package mcp serves discovered CLI trees to AI assistants over the Model
+// Context Protocol (JSON-RPC 2.0 on stdio).
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ProtocolVersion is the MCP revision the server implements. Clients that
+// request a different revision are answered with this one, as the spec
+// allows.
+const ProtocolVersion = "2024-11-05"
+
+// Loader returns the command tree for a CLI, discovering it if necessary.
+type Loader func(ctx context.Context, cli string) (*models.Node, error)
+
+// Server answers MCP requests about CLI trees. Trees are loaded once per
+// CLI and kept for the lifetime of the server.
+type Server struct {
+	load    Loader
+	version string
+
+	mu    sync.Mutex
+	trees map[string]*models.Node
+}
+
+// NewServer creates a server that obtains trees through load. version is
+// reported to clients as the server version.
+func NewServer(load Loader, version string) *Server {
+	return &Server{load: load, version: version, trees: map[string]*models.Node{}}
+}
+
+// JSON-RPC error codes used by the server.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve reads newline-delimited JSON-RPC messages from r and writes
+// responses to w until r is exhausted or ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(w)
+	for sc.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		resp := s.handle(ctx, line)
+		if resp == nil {
+			continue // notification
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// handle processes one message and returns the response to send, or nil
+// for notifications.
+func (s *Server) handle(ctx context.Context, line []byte) *response {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"),
+			Error: &rpcError{Code: codeParseError, Message: err.Error()}}
+	}
+	if req.ID == nil {
+		return nil
+	}
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" {
+		resp.Error = &rpcError{Code: codeInvalidRequest, Message: `jsonrpc must be "2.0"`}
+		return resp
+	}
+	switch req.Method {
+	case "initialize":
+		resp.Result = map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "treemand", "version": s.version},
+		}
+	case "ping":
+		resp.Result = map[string]any{}
+	case "tools/list":
+		resp.Result = map[string]any{"tools": toolList()}
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			resp.Error = &rpcError{Code: codeInvalidParams, Message: err.Error()}
+			return resp
+		}
+		t, ok := tools[p.Name]
+		if !ok {
+			resp.Error = &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", p.Name)}
+			return resp
+		}
+		text, err := t.call(ctx, s, p.Arguments)
+		resp.Result = toolResult(text, err)
+	default:
+		resp.Error = &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	return resp
+}
+
+// toolResult wraps a tool's output in an MCP CallToolResult. Tool failures
+// are reported in the result (isError) rather than as JSON-RPC errors so the
+// assistant can see and react to them.
+func toolResult(text string, err error) map[string]any {
+	if err != nil {
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}
+	}
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": text}},
+	}
+}
+
+// tree returns the cached tree for cli, loading it on first use.
+func (s *Server) tree(ctx context.Context, cli string) (*models.Node, error) {
+	if cli == "" {
+		return nil, fmt.Errorf("cli is required")
+	}
+	s.mu.Lock()
+	node, ok := s.trees[cli]
+	s.mu.Unlock()
+	if ok {
+		return node, nil
+	}
+	node, err := s.load(ctx, cli)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.trees[cli] = node
+	s.mu.Unlock()
+	return node, nil
+}
@@ -0,0 +1,338 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/render"
+)
+
+// tool is one callable MCP tool.
+type tool struct {
+	description string
+	schema      map[string]any
+	call        func(ctx context.Context, s *Server, args json.RawMessage) (string, error)
+}
+
+// tools is the registry of tools exposed by the server, keyed by name.
+var tools = map[string]tool{
+	"discover_cli": {
+		description: "Discover a CLI's command hierarchy and return it as an indented tree of subcommands, flags and positional arguments.",
+		schema: objectSchema([]string{"cli"}, map[string]any{
+			"cli":           prop("string", "Name of the CLI binary, e.g. git or kubectl."),
+			"depth":         prop("integer", "Maximum depth to print (default: everything discovered)."),
+			"commands_only": prop("boolean", "Omit flags and positionals."),
+		}),
+		call: discoverCLI,
+	},
+	"search_commands": {
+		description: "Search a CLI's commands by name, description and flag names. Returns matching full command paths.",
+		schema: objectSchema([]string{"cli", "query"}, map[string]any{
+			"cli":   prop("string", "Name of the CLI binary."),
+			"query": prop("string", "Case-insensitive text to look for."),
+		}),
+		call: searchCommands,
+	},
+	"get_flags": {
+		description: "Return the flags and positional arguments of one command as JSON.",
+		schema: objectSchema([]string{"cli", "command"}, map[string]any{
+			"cli":     prop("string", "Name of the CLI binary."),
+			"command": prop("string", `Subcommand path, e.g. "remote add" (the CLI name prefix is optional).`),
+		}),
+		call: getFlags,
+	},
+	"build_command": {
+		description: "Build a shell command line for a subcommand, validating that the command and flags exist and that required positional arguments are given.",
+		schema: objectSchema([]string{"cli", "command"}, map[string]any{
+			"cli":     prop("string", "Name of the CLI binary."),
+			"command": prop("string", `Subcommand path, e.g. "remote add".`),
+			"flags": map[string]any{
+				"type":        "object",
+				"description": `Flag name (with or without dashes) to value. Use true for boolean flags, a list of values for repeatable flags, and a number for count flags (e.g. 3 for -v -v -v).`,
+			},
+			"args": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Positional arguments, in order.",
+			},
+		}),
+		call: buildCommand,
+	},
+}
+
+func objectSchema(required []string, props map[string]any) map[string]any {
+	return map[string]any{"type": "object", "properties": props, "required": required}
+}
+
+func prop(typ, desc string) map[string]any {
+	return map[string]any{"type": typ, "description": desc}
+}
+
+// toolList returns the tools/list payload in a stable order.
+func toolList() []map[string]any {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		t := tools[name]
+		list = append(list, map[string]any{
+			"name":        name,
+			"description": t.description,
+			"inputSchema": t.schema,
+		})
+	}
+	return list
+}
+
+func discoverCLI(ctx context.Context, s *Server, raw json.RawMessage) (string, error) {
+	var args struct {
+		CLI          string `json:"cli"`
+		Depth        *int   `json:"depth"`
+		CommandsOnly bool   `json:"commands_only"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	root, err := s.tree(ctx, args.CLI)
+	if err != nil {
+		return "", err
+	}
+	opts := render.DefaultOptions()
+	opts.NoColor = true
+	opts.CommandsOnly = args.CommandsOnly
+	if args.Depth != nil {
+		opts.MaxDepth = *args.Depth
+	}
+	return render.ToString(root, opts)
+}
+
+// maxSearchResults caps search_commands output so a broad query on a huge
+// CLI does not flood the assistant's context.
+const maxSearchResults = 100
+
+func searchCommands(ctx context.Context, s *Server, raw json.RawMessage) (string, error) {
+	var args struct {
+		CLI   string `json:"cli"`
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	if args.Query == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	root, err := s.tree(ctx, args.CLI)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, m := range models.Search(root, args.Query, maxSearchResults+1) {
+		lines = append(lines, m.String())
+	}
+	if len(lines) == 0 {
+		return "no commands match " + fmt.Sprintf("%q", args.Query), nil
+	}
+	if len(lines) > maxSearchResults {
+		lines = append(lines[:maxSearchResults], fmt.Sprintf("… more than %d matches; refine the query", maxSearchResults))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func getFlags(ctx context.Context, s *Server, raw json.RawMessage) (string, error) {
+	var args struct {
+		CLI     string `json:"cli"`
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	node, err := s.command(ctx, args.CLI, args.Command)
+	if err != nil {
+		return "", err
+	}
+	out := struct {
+		Command     string              `json:"command"`
+		Stub        bool                `json:"stub,omitempty"`
+		Flags       []models.Flag       `json:"flags"`
+		Positionals []models.Positional `json:"positionals"`
+		Subcommands []string            `json:"subcommands,omitempty"`
+	}{
+		Command:     node.FullCommand(),
+		Stub:        node.Stub,
+		Flags:       node.Flags,
+		Positionals: node.Positionals,
+	}
+	if out.Flags == nil {
+		out.Flags = []models.Flag{}
+	}
+	if out.Positionals == nil {
+		out.Positionals = []models.Positional{}
+	}
+	for _, c := range node.Children {
+		if !c.Virtual {
+			out.Subcommands = append(out.Subcommands, c.Name)
+		}
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	return string(b), err
+}
+
+func buildCommand(ctx context.Context, s *Server, raw json.RawMessage) (string, error) {
+	var args struct {
+		CLI     string         `json:"cli"`
+		Command string         `json:"command"`
+		Flags   map[string]any `json:"flags"`
+		Args    []string       `json:"args"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	node, err := s.command(ctx, args.CLI, args.Command)
+	if err != nil {
+		return "", err
+	}
+
+	parts := append([]string{}, node.FullPath...)
+
+	// Sort flag names so the output is deterministic.
+	names := make([]string, 0, len(args.Flags))
+	for name := range args.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, ok := findFlag(node, name)
+		if !ok {
+			return "", fmt.Errorf("%s has no flag %q", node.FullCommand(), name)
+		}
+		switch v := args.Flags[name].(type) {
+		case bool:
+			if f.TakesValue() {
+				return "", fmt.Errorf("flag %s takes a %s value", f.Name, f.ValueType)
+			}
+			if v {
+				parts = append(parts, f.Name)
+			}
+		case nil:
+			return "", fmt.Errorf("flag %s has no value", f.Name)
+		case []any:
+			if !f.Repeatable || !f.TakesValue() {
+				return "", fmt.Errorf("flag %s does not take a list of values", f.Name)
+			}
+			for _, item := range v {
+				parts = append(parts, f.Name, fmt.Sprint(item))
+			}
+		case float64:
+			if !f.TakesValue() {
+				// Count flags are repeated bare, once per count.
+				if !f.Repeatable || v < 0 || v != float64(int(v)) {
+					return "", fmt.Errorf("flag %s does not take a value", f.Name)
+				}
+				for range int(v) {
+					parts = append(parts, f.Name)
+				}
+				continue
+			}
+			parts = append(parts, f.Name, fmt.Sprint(v))
+		default:
+			if !f.TakesValue() {
+				return "", fmt.Errorf("flag %s does not take a value", f.Name)
+			}
+			parts = append(parts, f.Name, fmt.Sprint(v))
+		}
+	}
+
+	if err := checkPositionals(node, args.Args); err != nil {
+		return "", err
+	}
+	parts = append(parts, args.Args...)
+
+	quoted := make([]string, len(parts))
+	for i, p := range parts {
+		quoted[i] = shellQuote(p)
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// command resolves a space-separated subcommand path within cli's tree. The
+// path may optionally start with the CLI name itself.
+func (s *Server) command(ctx context.Context, cli, path string) (*models.Node, error) {
+	root, err := s.tree(ctx, cli)
+	if err != nil {
+		return nil, err
+	}
+	names := strings.Fields(path)
+	if len(names) > 0 && names[0] == root.Name {
+		names = names[1:]
+	}
+	node := root
+	for i, name := range names {
+		next := node.Find(name)
+		if next == nil {
+			return nil, fmt.Errorf("unknown command %q", strings.Join(append([]string{root.Name}, names[:i+1]...), " "))
+		}
+		node = next
+	}
+	return node, nil
+}
+
+// findFlag looks up a flag by long name or short name, with or without
+// leading dashes.
+func findFlag(node *models.Node, name string) (models.Flag, bool) {
+	bare := strings.TrimLeft(name, "-")
+	for _, f := range node.Flags {
+		if strings.TrimLeft(f.Name, "-") == bare || (f.ShortName != "" && f.ShortName == bare) {
+			return f, true
+		}
+	}
+	return models.Flag{}, false
+}
+
+// checkPositionals verifies that args satisfy the node's positional
+// arguments. Commands without known positionals accept anything.
+func checkPositionals(node *models.Node, args []string) error {
+	if len(node.Positionals) == 0 {
+		return nil
+	}
+	slots := node.PositionalSlots()
+	var required []string
+	variadic := false
+	for _, alts := range slots {
+		p := alts[0]
+		if p.Required && !p.Variadic {
+			names := make([]string, len(alts))
+			for i, a := range alts {
+				names[i] = a.Name
+			}
+			required = append(required, strings.Join(names, " or "))
+		}
+		if p.Variadic {
+			variadic = true
+		}
+	}
+	if len(args) < len(required) {
+		return fmt.Errorf("missing required argument(s): %s", strings.Join(required[len(args):], ", "))
+	}
+	if !variadic && len(args) > len(slots) {
+		return fmt.Errorf("%s takes at most %d argument(s), got %d", node.FullCommand(), len(slots), len(args))
+	}
+	return nil
+}
+
+var shellSafeRe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s for a POSIX shell when it contains special characters.
+func shellQuote(s string) string {
+	if shellSafeRe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Change kinds.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Change is one difference between two trees of a CLI, found by Diff.
+type Change struct {
+	Kind    string `json:"kind"`    // ChangeAdded, ChangeRemoved or ChangeChanged
+	Command string `json:"command"` // full command, e.g. "git remote add"
+	// Flag names the flag that changed; it is empty when the command
+	// itself was added or removed.
+	Flag string `json:"flag,omitempty"`
+	// Detail says how a changed flag changed.
+	Detail string `json:"detail,omitempty"`
+}
+
+// String formats c as a diff line: "+ git maintenance", "- git log --foo"
+// or "~ git commit --cleanup: takes a string, was no value".
+func (c Change) String() string {
+	mark := map[string]string{ChangeAdded: "+", ChangeRemoved: "-", ChangeChanged: "~"}[c.Kind]
+	s := mark + " " + c.Command
+	if c.Flag != "" {
+		s += " " + c.Flag
+	}
+	if c.Detail != "" {
+		s += ": " + c.Detail
+	}
+	return s
+}
+
+// Diff returns the commands and flags added, removed or changed from old to
+// new, in depth-first order. A removed or added command is reported once,
+// not with its subcommands and flags. Only what both trees know is
+// compared: the subcommands and flags of stubs and of commands whose help
+// could not be fetched are not, so trees discovered to different depths
+// do not differ below the shallower one. Inherited flags are left out, and
+// flags of virtual flag groups count as their parent's.
+func Diff(old, new *Node) []Change {
+	var changes []Change
+	var walk func(a, b *Node, cmd string)
+	walk = func(a, b *Node, cmd string) {
+		known := complete(a) && complete(b)
+		if known {
+			changes = append(changes, diffFlags(ownFlags(a), ownFlags(b), cmd)...)
+		}
+		for _, ca := range a.Children {
+			if ca.Virtual {
+				continue
+			}
+			cb := b.Find(ca.Name)
+			switch {
+			case cb != nil && !cb.Virtual:
+				walk(ca, cb, cmd+" "+ca.Name)
+			case known:
+				changes = append(changes, Change{Kind: ChangeRemoved, Command: cmd + " " + ca.Name})
+			}
+		}
+		if !known {
+			return
+		}
+		for _, cb := range b.Children {
+			if ca := a.Find(cb.Name); !cb.Virtual && (ca == nil || ca.Virtual) {
+				changes = append(changes, Change{Kind: ChangeAdded, Command: cmd + " " + cb.Name})
+			}
+		}
+	}
+	walk(old, new, new.Name)
+	return changes
+}
+
+// complete reports whether n's help was parsed, so its flags and
+// subcommands are known.
+func complete(n *Node) bool {
+	return n.Discovered && !n.Stub && n.DiscoveryErr == ""
+}
+
+// ownFlags returns the flags of n that are not inherited, including those
+// of its virtual flag-group children.
+func ownFlags(n *Node) []Flag {
+	var flags []Flag
+	for _, f := range n.Flags {
+		if !f.Inherited {
+			flags = append(flags, f)
+		}
+	}
+	for _, c := range n.Children {
+		if c.Virtual {
+			flags = append(flags, ownFlags(c)...)
+		}
+	}
+	return flags
+}
+
+func diffFlags(old, new []Flag, cmd string) []Change {
+	find := func(flags []Flag, name string) *Flag {
+		for i := range flags {
+			if flags[i].Name == name {
+				return &flags[i]
+			}
+		}
+		return nil
+	}
+	var changes []Change
+	for _, fa := range old {
+		fb := find(new, fa.Name)
+		if fb == nil {
+			changes = append(changes, Change{Kind: ChangeRemoved, Command: cmd, Flag: fa.Name})
+			continue
+		}
+		var details []string
+		if valueType(fa) != valueType(*fb) {
+			details = append(details, fmt.Sprintf("takes %s, was %s", valueKind(valueType(*fb)), valueKind(valueType(fa))))
+		}
+		if fa.Required != fb.Required {
+			details = append(details, map[bool]string{true: "now required", false: "no longer required"}[fb.Required])
+		}
+		if len(details) > 0 {
+			changes = append(changes, Change{Kind: ChangeChanged, Command: cmd, Flag: fa.Name, Detail: strings.Join(details, ", ")})
+		}
+	}
+	for _, fb := range new {
+		if find(old, fb.Name) == nil {
+			changes = append(changes, Change{Kind: ChangeAdded, Command: cmd, Flag: fb.Name})
+		}
+	}
+	return changes
+}
+
+// valueType returns the value type of f in lower case, with no type
+// meaning bool.
+func valueType(f Flag) string {
+	if f.ValueType == "" {
+		return "bool"
+	}
+	return strings.ToLower(f.ValueType)
+}
+
+// valueKind describes a flag value type for Change.Detail.
+func valueKind(t string) string {
+	if t == "bool" {
+		return "no value"
+	}
+	return "a " + t
+}
@@ -0,0 +1,44 @@
+package models_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+func TestDiff(t *testing.T) {
+	node := func(name string, flags []models.Flag, children ...*models.Node) *models.Node {
+		return &models.Node{Name: name, Discovered: true, Flags: flags, Children: children}
+	}
+	old := node("git", []models.Flag{{Name: "--version"}, {Name: "--paginate"}},
+		node("commit", []models.Flag{{Name: "--message", ValueType: "string"}, {Name: "--cleanup", ValueType: "bool"}, {Name: "--verbose", Inherited: true}}),
+		node("whatchanged", nil, node("sub", nil)),
+		&models.Node{Name: "remote", Stub: true},
+	)
+	new := node("git", []models.Flag{{Name: "--version"}},
+		node("commit", []models.Flag{{Name: "--message", ValueType: "string", Required: true}, {Name: "--cleanup", ValueType: "string"}},
+			&models.Node{Name: "commit-options", Virtual: true, Flags: []models.Flag{{Name: "--trailer", ValueType: "string"}}}),
+		node("maintenance", nil),
+		node("remote", nil, node("add", nil)),
+	)
+
+	var got []string
+	for _, c := range models.Diff(old, new) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"- git --paginate",
+		"~ git commit --message: now required",
+		"~ git commit --cleanup: takes a string, was no value",
+		"+ git commit --trailer",
+		"- git whatchanged",
+		"+ git maintenance",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Diff() =\n%q\nwant\n%q", got, want)
+	}
+	if changes := models.Diff(new, new); len(changes) != 0 {
+		t.Errorf("Diff() of a tree with itself = %v, want none", changes)
+	}
+}
@@ -1,6 +1,12 @@
 // Package models defines the core data structures for CLI command hierarchies.
 package models
 
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
 // Flag represents a CLI flag/option with its metadata.
 type Flag struct {
 	Name        string `json:"name"`
@@ -11,6 +17,87 @@ type Flag struct {
 	// Inherited is set when this flag is also present on an ancestor node
 	// (e.g. Cobra global flags propagated to every subcommand).
 	Inherited bool `json:"inherited,omitempty"`
+	// Repeatable is set for flags that may be given more than once, either
+	// with distinct values (--tag a --tag b, a stringArray) or bare to count
+	// occurrences (-v -v -v).
+	Repeatable bool `json:"repeatable,omitempty"`
+	// Deprecated is set when the help output marks the flag as deprecated;
+	// ReplacedBy names what to use instead, when it says.
+	Deprecated bool   `json:"deprecated,omitempty"`
+	ReplacedBy string `json:"replaced_by,omitempty"`
+	// Default is the flag's default value, when the help states one
+	// ("[default: 10]").
+	Default string `json:"default,omitempty"`
+	// ValueStyle is how the help joins the flag to its value:
+	// ValueStyleEquals for "--output=FILE", ValueStyleSpace for
+	// "--output FILE". Empty when the help shows no value.
+	ValueStyle string `json:"value_style,omitempty"`
+	// Negatable is set for boolean flags that also have a "--no-" form
+	// turning them off: "--[no-]color", or --color listed with --no-color.
+	Negatable bool `json:"negatable,omitempty"`
+	// Sources names the discovery strategies that found the flag.
+	Sources []string `json:"sources,omitempty"`
+	// Confidence is how surely the help parser read this flag; see
+	// LowConfidence.
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// LowConfidence is the Confidence below which a parsed flag or command is
+// doubtful: likely a line of prose or an example misread as one. Scores
+// run from 0 to 1; 0 means the element was not scored, as for flags from
+// shell completions, and is trusted.
+const LowConfidence = 0.5
+
+// Doubtful reports whether the flag was scored below LowConfidence.
+func (f Flag) Doubtful() bool {
+	return f.Confidence > 0 && f.Confidence < LowConfidence
+}
+
+// TakesValue reports whether the flag is given a value. Bool flags and
+// count flags (repeated bare, like -vvv) are not.
+func (f Flag) TakesValue() bool {
+	switch strings.ToLower(f.ValueType) {
+	case "", "bool", "count":
+		return false
+	}
+	return true
+}
+
+// TypeTag returns the "[bool]", "[str]", "[int]" or "[count]" tag, or the
+// value type's own name, that names the kind of value f takes in words,
+// for output that must not tell flag types apart by color alone.
+func (f Flag) TypeTag() string {
+	switch f.ValueType {
+	case "", "bool":
+		return "[bool]"
+	case "string", "stringArray", "[]string":
+		return "[str]"
+	case "int", "int64", "uint", "uint64":
+		return "[int]"
+	}
+	return "[" + f.ValueType + "]"
+}
+
+// NegatedName returns the "--no-" form of a negatable flag: --no-color for
+// --color.
+func (f Flag) NegatedName() string {
+	return "--no-" + strings.TrimPrefix(f.Name, "--")
+}
+
+// Values of Flag.ValueStyle.
+const (
+	ValueStyleEquals = "equals"
+	ValueStyleSpace  = "space"
+)
+
+// WithValue spells the flag given value, in the style its help uses:
+// "--output=x", or "--output x" for ValueStyleSpace. Flags of unknown style
+// use "=", which most parsers accept.
+func (f Flag) WithValue(value string) string {
+	if f.ValueStyle == ValueStyleSpace {
+		return f.Name + " " + value
+	}
+	return f.Name + "=" + value
 }
 
 // Positional represents a positional argument in a CLI command.
@@ -19,18 +106,30 @@ type Positional struct {
 	Description string `json:"description,omitempty"`
 	Required    bool   `json:"required"`
 	Variadic    bool   `json:"variadic,omitempty"`
+	// Group is shared by positionals the usage offers as alternatives for
+	// one slot, e.g. <url> and <file> in "(<url> | <file>)"; 0 for none.
+	Group int `json:"group,omitempty"`
 }
 
 // Node represents a command or subcommand in a CLI hierarchy.
 type Node struct {
-	Name        string       `json:"name"`
-	FullPath    []string     `json:"full_path"`
-	Description string       `json:"description,omitempty"`
-	Flags       []Flag       `json:"flags,omitempty"`
-	Positionals []Positional `json:"positionals,omitempty"`
-	Children    []*Node      `json:"children,omitempty"`
-	HelpText    string       `json:"help_text,omitempty"`
-	Discovered  bool         `json:"discovered"`
+	// SchemaVersion is set on the root of serialized trees only; see
+	// SchemaVersion and JSONSchema.
+	SchemaVersion string       `json:"schema_version,omitempty"`
+	Name          string       `json:"name"`
+	FullPath      []string     `json:"full_path"`
+	Description   string       `json:"description,omitempty"`
+	Flags         []Flag       `json:"flags,omitempty"`
+	Positionals   []Positional `json:"positionals,omitempty"`
+	Children      []*Node      `json:"children,omitempty"`
+	HelpText      string       `json:"help_text,omitempty"`
+	Discovered    bool         `json:"discovered"`
+	// FlagSections groups Flags under the headers the help lists them
+	// under ("General options:", "Debug options:"), when it uses several.
+	FlagSections []FlagSection `json:"flag_sections,omitempty"`
+	// Sources names the discovery strategies that found the command —
+	// help, completions, man, hidden — in the order they ran.
+	Sources []string `json:"sources,omitempty"`
 	// DiscoveryErr holds a non-fatal error from the discovery process
 	// (e.g. a subcommand whose --help timed out). It is intentionally
 	// separate from Description so renderers can display it differently
@@ -40,10 +139,121 @@ type Node struct {
 	// This happens when a parent has too many subcommands to eagerly expand
 	// (e.g. aws with 200+ services). Stub nodes can be expanded on demand.
 	Stub bool `json:"stub,omitempty"`
+	// Interactive marks a command that prompted for input (a confirmation,
+	// a login, a REPL) when asked for help. It was stopped at the prompt
+	// and has a DiscoveryErr.
+	Interactive bool `json:"interactive,omitempty"`
+	// Deprecated is set when the command's help, or its parent's list of
+	// commands, marks it as deprecated; ReplacedBy names what to use
+	// instead, when it says.
+	Deprecated bool   `json:"deprecated,omitempty"`
+	ReplacedBy string `json:"replaced_by,omitempty"`
+	// Hidden marks a command the CLI accepts but leaves out of its help,
+	// found by the hidden discovery strategy.
+	Hidden bool `json:"hidden,omitempty"`
+	// Confidence is how surely the help parser read this command from its
+	// parent's help; see LowConfidence.
+	Confidence float64 `json:"confidence,omitempty"`
 	// Virtual marks a display-only group node (e.g. a Godot flag section like
 	// "run-options"). Virtual nodes organise flags visually but do not
 	// produce command tokens in the preview bar.
 	Virtual bool `json:"virtual,omitempty"`
+	// HelpHash is a short content hash of HelpText. Siblings with the same
+	// hash received identical help output, which usually means the CLI
+	// printed its parent help for a subcommand it does not really know.
+	HelpHash string `json:"help_hash,omitempty"`
+	// AliasOf names the sibling whose help output this node duplicates.
+	// Aliased nodes keep their own row but their subtree is not expanded.
+	AliasOf string `json:"alias_of,omitempty"`
+	// Dedup records the deduplication decisions taken on this node and its
+	// children during discovery. It exists purely for debugging output.
+	Dedup []string `json:"dedup,omitempty"`
+	// Conflicts notes the values discovery strategies disagreed on for this
+	// command and its flags, and which was kept; see `treemand doctor`.
+	Conflicts []string `json:"conflicts,omitempty"`
+	// Probe records how the node's help was fetched. It is nil when the
+	// CLI was not run for this node (stubs, help reused from the cache).
+	Probe *Probe `json:"probe,omitempty"`
+	// Incomplete is set on the root of a tree whose discovery ran out of
+	// time or was interrupted: the commands it did not reach are stubs,
+	// and the strategies that had not finished are missing.
+	Incomplete bool `json:"incomplete,omitempty"`
+	// Diagnostics lists the problems met while discovering the tree. It is
+	// set on the root of discovered trees only; see `treemand doctor`.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// FlagSection is a named group of a node's flags.
+type FlagSection struct {
+	Name  string   `json:"name"`
+	Flags []string `json:"flags"` // names of flags in Node.Flags
+}
+
+// FlagGroup is a run of flags listed under one header; see FlagGroups.
+type FlagGroup struct {
+	Name  string // "" for flags in no FlagSection
+	Flags []int  // indices into Node.Flags
+}
+
+// FlagGroups partitions the flags at the indices in order into the node's
+// FlagSections. Flags in no section come first, in a group with no name;
+// empty groups are left out. It returns nil when fewer than two groups
+// remain, so that callers list the flags ungrouped.
+func (n *Node) FlagGroups(order []int) []FlagGroup {
+	if len(n.FlagSections) == 0 {
+		return nil
+	}
+	groupOf := map[string]int{}
+	groups := make([]FlagGroup, len(n.FlagSections)+1)
+	for i, s := range n.FlagSections {
+		groups[i+1].Name = s.Name
+		for _, name := range s.Flags {
+			if _, ok := groupOf[name]; !ok {
+				groupOf[name] = i + 1
+			}
+		}
+	}
+	for _, i := range order {
+		g := groupOf[n.Flags[i].Name]
+		groups[g].Flags = append(groups[g].Flags, i)
+	}
+	var out []FlagGroup
+	for _, g := range groups {
+		if len(g.Flags) > 0 {
+			out = append(out, g)
+		}
+	}
+	if len(out) < 2 {
+		return nil
+	}
+	return out
+}
+
+// Diagnostic kinds.
+const (
+	DiagError    = "error"    // the command's help could not be fetched
+	DiagTimeout  = "timeout"  // fetching the command's help timed out
+	DiagAnomaly  = "anomaly"  // help was fetched but looks wrong
+	DiagConflict = "conflict" // discovery strategies disagree about the command
+)
+
+// Diagnostic is one problem met while discovering a command.
+type Diagnostic struct {
+	Command string `json:"command"` // full command, e.g. "git remote add"
+	Kind    string `json:"kind"`    // DiagError, DiagTimeout or DiagAnomaly
+	Message string `json:"message"`
+}
+
+// Probe describes the help invocations made for one command.
+type Probe struct {
+	// Attempts counts the tries at fetching help, including retries of
+	// attempts that failed or timed out.
+	Attempts int `json:"attempts"`
+	// Execs counts the processes started: each attempt may try --help,
+	// -h and the help subcommand.
+	Execs int `json:"execs"`
+	// Duration is the wall time spent fetching help, backoff included.
+	Duration time.Duration `json:"duration_ns"`
 }
 
 // FullCommand returns the full command string (e.g., "git remote add").
@@ -76,6 +286,24 @@ func (n *Node) HasPositionals() bool {
 	return len(n.Positionals) > 0
 }
 
+// PositionalSlots returns the argument slots of the node in order, each
+// holding the positionals that can fill it: one, or the alternatives that
+// share a Group. The positionals point into n.Positionals.
+func (n *Node) PositionalSlots() [][]*Positional {
+	var slots [][]*Positional
+	slotOf := make(map[int]int) // group → slot index
+	for i := range n.Positionals {
+		p := &n.Positionals[i]
+		if j, ok := slotOf[p.Group]; ok && p.Group != 0 {
+			slots[j] = append(slots[j], p)
+			continue
+		}
+		slotOf[p.Group] = len(slots)
+		slots = append(slots, []*Positional{p})
+	}
+	return slots
+}
+
 // Find searches for a child node by name.
 func (n *Node) Find(name string) *Node {
 	for _, child := range n.Children {
@@ -86,6 +314,30 @@ func (n *Node) Find(name string) *Node {
 	return nil
 }
 
+// FindPath descends from n through the named children and returns the node
+// at the end of the path, or nil if any segment is missing. An empty path
+// returns n itself.
+func (n *Node) FindPath(names []string) *Node {
+	cur := n
+	for _, name := range names {
+		if cur = cur.Find(name); cur == nil {
+			return nil
+		}
+	}
+	return cur
+}
+
+// FindFullPath returns the node below n, or n itself, whose full path is
+// path: "git remote add" is found from the root of git's tree as from the
+// node of git remote. It returns nil when there is none, or n's own full path
+// does not begin path.
+func (n *Node) FindFullPath(path []string) *Node {
+	if len(path) < len(n.FullPath) || !slices.Equal(path[:len(n.FullPath)], n.FullPath) {
+		return nil
+	}
+	return n.FindPath(path[len(n.FullPath):])
+}
+
 // Walk calls fn for each node in the tree (depth-first pre-order).
 func (n *Node) Walk(fn func(*Node)) {
 	fn(n)
@@ -104,10 +356,42 @@ func (n *Node) Clone() *Node {
 		Discovered:   n.Discovered,
 		DiscoveryErr: n.DiscoveryErr,
 		Stub:         n.Stub,
+		Interactive:  n.Interactive,
+		Deprecated:   n.Deprecated,
+		ReplacedBy:   n.ReplacedBy,
+		Hidden:       n.Hidden,
+		Confidence:   n.Confidence,
+		HelpHash:     n.HelpHash,
+		AliasOf:      n.AliasOf,
+		Incomplete:   n.Incomplete,
 	}
 	copy(c.FullPath, n.FullPath)
+	if len(n.Dedup) > 0 {
+		c.Dedup = append([]string(nil), n.Dedup...)
+	}
+	if len(n.Conflicts) > 0 {
+		c.Conflicts = append([]string(nil), n.Conflicts...)
+	}
+	if len(n.Diagnostics) > 0 {
+		c.Diagnostics = append([]Diagnostic(nil), n.Diagnostics...)
+	}
+	if n.Probe != nil {
+		p := *n.Probe
+		c.Probe = &p
+	}
 	c.Flags = make([]Flag, len(n.Flags))
 	copy(c.Flags, n.Flags)
+	for i := range c.Flags {
+		if len(c.Flags[i].Sources) > 0 {
+			c.Flags[i].Sources = append([]string(nil), c.Flags[i].Sources...)
+		}
+	}
+	if len(n.Sources) > 0 {
+		c.Sources = append([]string(nil), n.Sources...)
+	}
+	for _, s := range n.FlagSections {
+		c.FlagSections = append(c.FlagSections, FlagSection{Name: s.Name, Flags: append([]string(nil), s.Flags...)})
+	}
 	c.Positionals = make([]Positional, len(n.Positionals))
 	copy(c.Positionals, n.Positionals)
 	for _, child := range n.Children {
@@ -143,3 +427,62 @@ func markInherited(n *Node, ancestorFlags map[string]bool) {
 		markInherited(child, combined)
 	}
 }
+
+// ErrorNodes returns the nodes below root (in tree order) whose discovery
+// failed, i.e. that carry a DiscoveryErr.
+func ErrorNodes(root *Node) []*Node {
+	var out []*Node
+	root.Walk(func(n *Node) {
+		if n != root && n.DiscoveryErr != "" {
+			out = append(out, n)
+		}
+	})
+	return out
+}
+
+// Doubtful reports whether the command was scored below LowConfidence.
+func (n *Node) Doubtful() bool {
+	return n.Confidence > 0 && n.Confidence < LowConfidence
+}
+
+// PruneLowConfidence removes the commands below root, with their subtrees,
+// and the flags throughout the tree that were scored below minimum. Unscored
+// elements are kept.
+func PruneLowConfidence(root *Node, minimum float64) {
+	below := func(c float64) bool { return c > 0 && c < minimum }
+	flags := root.Flags[:0]
+	for _, f := range root.Flags {
+		if !below(f.Confidence) {
+			flags = append(flags, f)
+		}
+	}
+	root.Flags = flags
+	kept := root.Children[:0]
+	for _, child := range root.Children {
+		if !below(child.Confidence) {
+			PruneLowConfidence(child, minimum)
+			kept = append(kept, child)
+		}
+	}
+	clear(root.Children[len(kept):])
+	root.Children = kept
+}
+
+// PruneErrors removes the nodes below root whose discovery failed, along
+// with their subtrees, and returns them in tree order. The root itself is
+// never removed.
+func PruneErrors(root *Node) []*Node {
+	var pruned []*Node
+	kept := root.Children[:0]
+	for _, child := range root.Children {
+		if child.DiscoveryErr != "" {
+			pruned = append(pruned, child)
+			continue
+		}
+		pruned = append(pruned, PruneErrors(child)...)
+		kept = append(kept, child)
+	}
+	clear(root.Children[len(kept):])
+	root.Children = kept
+	return pruned
+}
@@ -1,6 +1,8 @@
 package models_test
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/aallbrig/treemand/models"
@@ -173,3 +175,223 @@ func TestNode_HasPositionals(t *testing.T) {
 		t.Error("HasPositionals() should be false when empty")
 	}
 }
+
+func TestNode_PositionalSlots(t *testing.T) {
+	n := &models.Node{Name: "fetch", Positionals: []models.Positional{
+		{Name: "url", Required: true, Group: 1},
+		{Name: "file", Required: true, Group: 1},
+		{Name: "dest"},
+	}}
+	slots := n.PositionalSlots()
+	if len(slots) != 2 || len(slots[0]) != 2 || len(slots[1]) != 1 {
+		t.Fatalf("PositionalSlots() = %v, want [[url file] [dest]]", slots)
+	}
+	if slots[0][1] != &n.Positionals[1] || slots[1][0].Name != "dest" {
+		t.Errorf("slots should point into Positionals in order, got %v", slots)
+	}
+}
+
+func TestNodeFindPath(t *testing.T) {
+	root := &models.Node{Name: "git", Children: []*models.Node{
+		{Name: "remote", Children: []*models.Node{{Name: "add"}}},
+	}}
+	if got := root.FindPath([]string{"remote", "add"}); got == nil || got.Name != "add" {
+		t.Errorf("FindPath(remote add) = %v", got)
+	}
+	if got := root.FindPath(nil); got != root {
+		t.Error("FindPath(nil) should return the receiver")
+	}
+	if got := root.FindPath([]string{"remote", "rm"}); got != nil {
+		t.Errorf("FindPath(remote rm) = %v, want nil", got)
+	}
+}
+
+func TestNodeFindFullPath(t *testing.T) {
+	remote := &models.Node{Name: "remote", FullPath: []string{"git", "remote"}, Children: []*models.Node{
+		{Name: "add", FullPath: []string{"git", "remote", "add"}},
+	}}
+	root := &models.Node{Name: "git", FullPath: []string{"git"}, Children: []*models.Node{remote}}
+	add := []string{"git", "remote", "add"}
+	for _, from := range []*models.Node{root, remote} {
+		if got := from.FindFullPath(add); got == nil || got.Name != "add" {
+			t.Errorf("%s: FindFullPath(git remote add) = %v", from.FullCommand(), got)
+		}
+	}
+	if got := remote.FindFullPath([]string{"git", "remote"}); got != remote {
+		t.Errorf("FindFullPath of its own path = %v, want the receiver", got)
+	}
+	if got := remote.FindFullPath([]string{"git", "commit"}); got != nil {
+		t.Errorf("FindFullPath(git commit) from git remote = %v, want nil", got)
+	}
+	if got := remote.FindFullPath([]string{"git"}); got != nil {
+		t.Errorf("FindFullPath(git) from git remote = %v, want nil", got)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	root := &models.Node{
+		Name: "aws", FullPath: []string{"aws"},
+		Children: []*models.Node{
+			{
+				Name: "cloudformation", FullPath: []string{"aws", "cloudformation"},
+				Children: []*models.Node{{
+					Name: "create-stack", FullPath: []string{"aws", "cloudformation", "create-stack"},
+					Description: "Creates a stack",
+					Flags:       []models.Flag{{Name: "--capabilities"}, {Name: "--region", Inherited: true}},
+				}},
+			},
+			{
+				Name: "s3", FullPath: []string{"aws", "s3"}, Description: "Stack-free object storage",
+				Children: []*models.Node{{Name: "Options", Virtual: true,
+					Flags: []models.Flag{{Name: "--recursive", ShortName: "r"}}}},
+			},
+		},
+	}
+
+	got := models.Search(root, "--capabilities", 0)
+	if len(got) != 1 || got[0].Node.Name != "create-stack" {
+		t.Fatalf("Search(--capabilities) = %+v", got)
+	}
+	if s := got[0].String(); s != "aws cloudformation create-stack — Creates a stack  [flag --capabilities]" {
+		t.Errorf("Match.String() = %q", s)
+	}
+
+	got = models.Search(root, "STACK", 0)
+	if len(got) != 2 || got[0].Node.Name != "create-stack" || got[1].Node.Name != "s3" {
+		t.Errorf("Search(STACK) = %+v, want create-stack then s3", got)
+	}
+	if got := models.Search(root, "STACK", 1); len(got) != 1 {
+		t.Errorf("limit 1 returned %d matches", len(got))
+	}
+	if got := models.Search(root, "--region", 0); len(got) != 0 {
+		t.Errorf("inherited flags should not match, got %+v", got)
+	}
+	// Virtual flag groups match as their parent; -r finds the short name.
+	if got := models.Search(root, "-r", 0); len(got) != 1 || got[0].Node.Name != "s3" {
+		t.Errorf("Search(-r) = %+v, want s3", got)
+	}
+}
+
+func TestPruneErrors(t *testing.T) {
+	root := &models.Node{Name: "git", DiscoveryErr: "root errors are kept", Children: []*models.Node{
+		{Name: "bad", DiscoveryErr: "could not get help: exit status 1"},
+		{Name: "remote", Children: []*models.Node{
+			{Name: "add"},
+			{Name: "broken", DiscoveryErr: "could not get help: timeout"},
+		}},
+	}}
+	if got := models.ErrorNodes(root); len(got) != 2 || got[0].Name != "bad" || got[1].Name != "broken" {
+		t.Fatalf("ErrorNodes = %v", got)
+	}
+	pruned := models.PruneErrors(root)
+	if len(pruned) != 2 || pruned[0].Name != "bad" || pruned[1].Name != "broken" {
+		t.Errorf("PruneErrors returned %v", pruned)
+	}
+	if len(root.Children) != 1 || root.Children[0].Name != "remote" {
+		t.Fatalf("root children after pruning = %v", root.Children)
+	}
+	if remote := root.Children[0]; len(remote.Children) != 1 || remote.Children[0].Name != "add" {
+		t.Errorf("remote children after pruning = %v", remote.Children)
+	}
+	if len(models.ErrorNodes(root)) != 0 {
+		t.Error("expected no error nodes after pruning")
+	}
+}
+
+func TestPruneLowConfidence(t *testing.T) {
+	root := &models.Node{
+		Name:  "tool",
+		Flags: []models.Flag{{Name: "--verbose", Confidence: 0.9}, {Name: "--style", Confidence: 0.4}, {Name: "--from-completions"}},
+		Children: []*models.Node{
+			{Name: "run", Confidence: 0.9, Flags: []models.Flag{{Name: "--example", Confidence: 0.2}}},
+			{Name: "garbage", Confidence: 0.4, Children: []*models.Node{{Name: "child", Confidence: 0.9}}},
+			{Name: "unscored"},
+		},
+	}
+	if !root.Flags[1].Doubtful() || root.Flags[2].Doubtful() || !root.Children[1].Doubtful() || root.Children[2].Doubtful() {
+		t.Error("Doubtful: want true below LowConfidence and false when unscored")
+	}
+	models.PruneLowConfidence(root, 0.5)
+	var flags []string
+	for _, f := range root.Flags {
+		flags = append(flags, f.Name)
+	}
+	if got := strings.Join(flags, ","); got != "--verbose,--from-completions" {
+		t.Errorf("root flags = %s", got)
+	}
+	var children []string
+	for _, c := range root.Children {
+		children = append(children, c.Name)
+	}
+	if got := strings.Join(children, ","); got != "run,unscored" {
+		t.Errorf("children = %s", got)
+	}
+	if run := root.Children[0]; len(run.Flags) != 0 {
+		t.Errorf("run flags = %v, want the doubtful one pruned", run.Flags)
+	}
+}
+
+func TestFlagGroups(t *testing.T) {
+	n := &models.Node{
+		Name:  "godot",
+		Flags: []models.Flag{{Name: "--help"}, {Name: "--verbose"}, {Name: "--debug"}, {Name: "--profiling"}},
+		FlagSections: []models.FlagSection{
+			{Name: "General options", Flags: []string{"--help", "--verbose"}},
+			{Name: "Debug options", Flags: []string{"--debug", "--profiling", "--pruned"}},
+		},
+	}
+	got := n.FlagGroups([]int{3, 2, 1, 0})
+	want := []models.FlagGroup{
+		{Name: "General options", Flags: []int{1, 0}},
+		{Name: "Debug options", Flags: []int{3, 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FlagGroups = %+v, want %+v", got, want)
+	}
+
+	n.Flags = append(n.Flags, models.Flag{Name: "--loose"})
+	if got := n.FlagGroups([]int{4, 0, 2}); len(got) != 3 || got[0].Name != "" || got[0].Flags[0] != 4 {
+		t.Errorf("flags in no section should come first, ungrouped: %+v", got)
+	}
+	if got := n.FlagGroups([]int{0, 1}); got != nil {
+		t.Errorf("FlagGroups of flags in one section = %+v, want nil", got)
+	}
+	if c := n.Clone(); !reflect.DeepEqual(c.FlagSections, n.FlagSections) {
+		t.Errorf("Clone FlagSections = %+v", c.FlagSections)
+	}
+}
+
+func TestFlagWithValue(t *testing.T) {
+	tests := []struct {
+		style, want string
+	}{
+		{"", "--output=x"},
+		{models.ValueStyleEquals, "--output=x"},
+		{models.ValueStyleSpace, "--output x"},
+	}
+	for _, tt := range tests {
+		f := models.Flag{Name: "--output", ValueType: "string", ValueStyle: tt.style}
+		if got := f.WithValue("x"); got != tt.want {
+			t.Errorf("style %q: WithValue = %q, want %q", tt.style, got, tt.want)
+		}
+	}
+}
+
+func TestFlagTypeTag(t *testing.T) {
+	tests := []struct {
+		valueType, want string
+	}{
+		{"", "[bool]"},
+		{"bool", "[bool]"},
+		{"string", "[str]"},
+		{"stringArray", "[str]"},
+		{"int64", "[int]"},
+		{"count", "[count]"},
+		{"duration", "[duration]"},
+	}
+	for _, tt := range tests {
+		if got := (models.Flag{Name: "--x", ValueType: tt.valueType}).TypeTag(); got != tt.want {
+			t.Errorf("TypeTag(%q) = %q, want %q", tt.valueType, got, tt.want)
+		}
+	}
+}
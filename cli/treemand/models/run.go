@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Run is a command run from the TUI, recorded with its output when
+// record_runs is on.
+type Run struct {
+	Command   string        `json:"command"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration_ns"`
+	// ExitCode is the command's exit status, or -1 when it could not be
+	// started or was killed by a signal.
+	ExitCode int `json:"exit_code"`
+	// Stdout and Stderr hold the end of what the command wrote, up to
+	// MaxRunOutput bytes each.
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+}
+
+// MaxRunOutput is how many bytes of each of a Run's stdout and stderr are
+// kept; beyond it, the earliest are dropped.
+const MaxRunOutput = 64 << 10
@@ -0,0 +1,56 @@
+package models
+
+import (
+	_ "embed" // for the JSON Schema below
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// SchemaVersion is the version of the serialized tree format. Bump it (and
+// the "const" in tree.schema.json) whenever a change to Node, Flag or
+// Positional could break consumers of --output=json.
+const SchemaVersion = "1"
+
+// JSONSchema is the JSON Schema (draft 2020-12) describing the tree emitted
+// by --output=json.
+//
+//go:embed tree.schema.json
+var JSONSchema []byte
+
+// ReadTree decodes a tree written by --output=json, or written by hand in
+// the same format. A tree of another schema version is refused. Only names
+// are required: commands without a full path get one from their place in
+// the tree.
+func ReadTree(r io.Reader) (*Node, error) {
+	var root Node
+	if err := json.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("decode tree: %w", err)
+	}
+	if root.SchemaVersion != "" && root.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("tree has schema version %s, want %s", root.SchemaVersion, SchemaVersion)
+	}
+	if root.Name == "" {
+		return nil, errors.New("tree has no root name")
+	}
+	root.SchemaVersion = ""
+	fillPaths(&root, nil)
+	return &root, nil
+}
+
+// fillPaths gives n and its descendants without a full path one below
+// parent's.
+func fillPaths(n *Node, parent []string) {
+	if len(n.FullPath) == 0 {
+		n.FullPath = append(slices.Clone(parent), n.Name)
+	}
+	below := n.FullPath
+	if n.Virtual {
+		below = parent // groups are not part of command paths
+	}
+	for _, c := range n.Children {
+		fillPaths(c, below)
+	}
+}
@@ -0,0 +1,78 @@
+package models_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// TestJSONSchemaCoversFields keeps tree.schema.json in sync with the structs:
+// every serialized field must be declared, and nothing else.
+func TestJSONSchemaCoversFields(t *testing.T) {
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(models.JSONSchema, &schema); err != nil {
+		t.Fatalf("JSONSchema is not valid JSON: %v", err)
+	}
+	for def, typ := range map[string]reflect.Type{
+		"node":       reflect.TypeOf(models.Node{}),
+		"flag":       reflect.TypeOf(models.Flag{}),
+		"positional": reflect.TypeOf(models.Positional{}),
+		"diagnostic": reflect.TypeOf(models.Diagnostic{}),
+		"probe":      reflect.TypeOf(models.Probe{}),
+	} {
+		props := schema.Defs[def].Properties
+		fields := map[string]bool{}
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			fields[name] = true
+			if _, ok := props[name]; !ok {
+				t.Errorf("schema $defs.%s is missing property %q", def, name)
+			}
+		}
+		for name := range props {
+			if !fields[name] {
+				t.Errorf("schema $defs.%s declares %q which %s does not serialize", def, name, typ.Name())
+			}
+		}
+	}
+}
+
+func TestJSONSchemaVersionConst(t *testing.T) {
+	if !strings.Contains(string(models.JSONSchema), `"const": "`+models.SchemaVersion+`"`) {
+		t.Errorf("JSONSchema schema_version const does not match SchemaVersion %q", models.SchemaVersion)
+	}
+}
+
+func TestReadTree(t *testing.T) {
+	root, err := models.ReadTree(strings.NewReader(`{"schema_version": "` + models.SchemaVersion + `", "name": "git",
+		"children": [{"name": "remote", "children": [{"name": "add"}]},
+			{"name": "options", "virtual": true, "children": [{"name": "config"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.SchemaVersion != "" {
+		t.Errorf("SchemaVersion = %q, want it cleared as on discovered trees", root.SchemaVersion)
+	}
+	if add := root.FindPath([]string{"remote", "add"}); add == nil || add.FullCommand() != "git remote add" {
+		t.Errorf("remote add = %+v, want the full path git remote add", add)
+	}
+	if cfg := root.Children[1].Children[0]; cfg.FullCommand() != "git config" {
+		t.Errorf("command in a group: full path %q, want git config", cfg.FullCommand())
+	}
+
+	for _, bad := range []string{`{"schema_version": "0", "name": "git"}`, `{"description": "no name"}`, `[1]`} {
+		if _, err := models.ReadTree(strings.NewReader(bad)); err == nil {
+			t.Errorf("ReadTree(%s) succeeded", bad)
+		}
+	}
+}
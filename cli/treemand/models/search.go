@@ -0,0 +1,76 @@
+package models
+
+import "strings"
+
+// Match is a command found by Search, with the reasons it matched.
+type Match struct {
+	Node *Node
+	// Fields lists what matched: "name", "description" and/or
+	// "flag <name>" for each matching flag.
+	Fields []string
+}
+
+// Search returns the commands under root (in depth-first order) whose name,
+// description or own flag names contain query, case-insensitively. Flags of
+// virtual flag-group children are searched as part of their parent; the
+// virtual nodes themselves are never returned. limit > 0 caps the number of
+// matches.
+func Search(root *Node, query string, limit int) []Match {
+	q := strings.ToLower(query)
+	var matches []Match
+	var walk func(n *Node) bool
+	walk = func(n *Node) bool {
+		if limit > 0 && len(matches) >= limit {
+			return false
+		}
+		var fields []string
+		if strings.Contains(strings.ToLower(n.Name), q) {
+			fields = append(fields, "name")
+		}
+		if strings.Contains(strings.ToLower(n.Description), q) {
+			fields = append(fields, "description")
+		}
+		fields = appendFlagMatches(fields, n.Flags, q)
+		for _, c := range n.Children {
+			if c.Virtual {
+				fields = appendFlagMatches(fields, c.Flags, q)
+			}
+		}
+		if len(fields) > 0 {
+			matches = append(matches, Match{Node: n, Fields: fields})
+		}
+		for _, c := range n.Children {
+			if !c.Virtual && !walk(c) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(root)
+	return matches
+}
+
+func appendFlagMatches(fields []string, flags []Flag, q string) []string {
+	for _, f := range flags {
+		if f.Inherited {
+			continue
+		}
+		// A "-x" query also finds flags by their short name.
+		short := f.ShortName != "" && strings.HasPrefix(q, "-") && !strings.HasPrefix(q, "--") &&
+			strings.ToLower(f.ShortName) == q[1:]
+		if short || strings.Contains(strings.ToLower(f.Name), q) {
+			fields = append(fields, "flag "+f.Name)
+		}
+	}
+	return fields
+}
+
+// String formats the match as
+// "git commit — Record changes to the repository  [name, flag --amend]".
+func (m Match) String() string {
+	line := m.Node.FullCommand()
+	if m.Node.Description != "" {
+		line += " — " + m.Node.Description
+	}
+	return line + "  [" + strings.Join(m.Fields, ", ") + "]"
+}
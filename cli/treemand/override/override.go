@@ -0,0 +1,297 @@
+// Package override corrects discovered trees by hand. An override file,
+// one per CLI, is YAML laid out like the tree it applies to; each command
+// and flag in it is matched by name, and what it sets replaces what
+// discovery found:
+//
+//	description: the stupid content tracker
+//	flags:
+//	  - {name: --exec-path, type: path}
+//	commands:
+//	  - name: remote
+//	    commands:
+//	      - name: add
+//	        flags:
+//	          - {name: --fetch, short: f, description: Fetch after adding}
+//	  - name: whatchanged
+//	    remove: true
+//
+// Commands and flags the tree does not have are added; rename: gives one a
+// new name, and remove: true drops it instead.
+package override
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// Source is what overridden commands and flags list in their Sources.
+const Source = "override"
+
+// Command overrides a command: the CLI itself at the top of the file, or
+// one of its subcommands.
+type Command struct {
+	// Name is the command's name. The CLI's own may be left out, as the
+	// file is named after it.
+	Name string `yaml:"name,omitempty"`
+	// Rename names a subcommand differently from its help.
+	Rename      string `yaml:"rename,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	// Remove drops the command, and everything under it, from the tree.
+	Remove   bool      `yaml:"remove,omitempty"`
+	Flags    []Flag    `yaml:"flags,omitempty"`
+	Commands []Command `yaml:"commands,omitempty"`
+}
+
+// Flag overrides a flag of a command.
+type Flag struct {
+	Name   string `yaml:"name"`
+	Rename string `yaml:"rename,omitempty"`
+	Short  string `yaml:"short,omitempty"`
+	// Type replaces the type of the flag's value; "bool" makes it a flag
+	// that takes none.
+	Type        string `yaml:"type,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	Default     string `yaml:"default,omitempty"`
+	// Remove drops the flag from the command.
+	Remove bool `yaml:"remove,omitempty"`
+}
+
+// Path returns the path of the override file of cli in dir.
+func Path(dir, cli string) string {
+	return filepath.Join(dir, cli+".yaml")
+}
+
+// File is the override file of one CLI, which the TUI saves edits to.
+type File struct {
+	Dir string
+	CLI string
+}
+
+// Path returns the path of the file.
+func (f File) Path() string { return Path(f.Dir, f.CLI) }
+
+// Load reads the file, or returns an empty override of the CLI when there
+// is none.
+func (f File) Load() (*Command, error) {
+	c, err := Load(f.Dir, f.CLI)
+	if c == nil && err == nil {
+		c = &Command{Name: f.CLI}
+	}
+	return c, err
+}
+
+// Save writes c to the file, creating its directory if need be.
+func (f File) Save(c *Command) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(f.Path(), buf.Bytes(), 0o644)
+}
+
+// Load reads the override file of cli in dir. It returns nil, and no
+// error, when there is none.
+func Load(dir, cli string) (*Command, error) {
+	data, err := os.ReadFile(Path(dir, cli))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse reads an override file. Unknown keys are errors, so a misspelt one
+// is not silently ignored.
+func Parse(data []byte) (*Command, error) {
+	var c Command
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Apply applies o to the tree at root, which is the CLI's tree or, when
+// discovery started at a subcommand, that command's subtree; the override
+// of the command at its root applies. It returns the full commands of the
+// overrides that removed nothing because the tree has no such command or
+// flag, so stale ones can be reported.
+func Apply(root *models.Node, o *Command) []string {
+	if o == nil || len(root.FullPath) == 0 {
+		return nil
+	}
+	for _, name := range root.FullPath[1:] {
+		i := slices.IndexFunc(o.Commands, func(c Command) bool { return c.Name == name })
+		if i < 0 {
+			return nil
+		}
+		o = &o.Commands[i]
+	}
+	var stale []string
+	apply(root, o, &stale)
+	models.MarkInheritedFlags(root)
+	return stale
+}
+
+func apply(n *models.Node, o *Command, stale *[]string) {
+	if o.Rename != "" && len(n.FullPath) > 1 {
+		rename(n, o.Rename)
+		addSource(&n.Sources)
+	}
+	if o.Description != "" {
+		n.Description = o.Description
+		addSource(&n.Sources)
+	}
+	for _, of := range o.Flags {
+		flags, i := findFlag(n, of.Name)
+		switch {
+		case of.Remove && i < 0:
+			*stale = append(*stale, n.FullCommand()+" "+of.Name)
+		case of.Remove:
+			*flags = slices.Delete(*flags, i, i+1)
+		case i < 0:
+			*flags = append(*flags, models.Flag{Name: of.Name})
+			i = len(*flags) - 1
+			fallthrough
+		default:
+			applyFlag(&(*flags)[i], of)
+		}
+	}
+	for i := range o.Commands {
+		oc := &o.Commands[i]
+		j := slices.IndexFunc(n.Children, func(c *models.Node) bool { return c.Name == oc.Name })
+		switch {
+		case oc.Remove && j < 0:
+			*stale = append(*stale, n.FullCommand()+" "+oc.Name)
+		case oc.Remove:
+			n.Children = slices.Delete(n.Children, j, j+1)
+		case j < 0:
+			child := &models.Node{
+				Name:       oc.Name,
+				FullPath:   append(slices.Clone(n.FullPath), oc.Name),
+				Discovered: true,
+				Sources:    []string{Source},
+			}
+			n.Children = append(n.Children, child)
+			apply(child, oc, stale)
+		default:
+			apply(n.Children[j], oc, stale)
+		}
+	}
+}
+
+// findFlag finds the flag named name among n's own, or those of its virtual
+// groups: the flags it is in, and its index there. It returns n's own flags
+// and -1 when there is none.
+func findFlag(n *models.Node, name string) (*[]models.Flag, int) {
+	if i := slices.IndexFunc(n.Flags, func(f models.Flag) bool { return f.Name == name }); i >= 0 {
+		return &n.Flags, i
+	}
+	for _, c := range n.Children {
+		if !c.Virtual {
+			continue
+		}
+		if flags, i := findFlag(c, name); i >= 0 {
+			return flags, i
+		}
+	}
+	return &n.Flags, -1
+}
+
+// rename renames n, and the command it is in the full path of every node
+// below it.
+func rename(n *models.Node, name string) {
+	i := len(n.FullPath) - 1
+	n.Name = name
+	n.Walk(func(d *models.Node) {
+		if len(d.FullPath) > i {
+			d.FullPath = slices.Clone(d.FullPath)
+			d.FullPath[i] = name
+		}
+	})
+}
+
+func applyFlag(f *models.Flag, of Flag) {
+	if of.Rename != "" {
+		f.Name = of.Rename
+	}
+	if of.Short != "" {
+		f.ShortName = strings.TrimPrefix(of.Short, "-")
+	}
+	switch {
+	case strings.EqualFold(of.Type, "bool"):
+		f.ValueType = ""
+	case of.Type != "":
+		f.ValueType = of.Type
+	}
+	if of.Description != "" {
+		f.Description = of.Description
+	}
+	if of.Default != "" {
+		f.Default = of.Default
+	}
+	// The flag is as the user says, whatever the parser scored it.
+	f.Confidence = 0
+	addSource(&f.Sources)
+}
+
+func addSource(sources *[]string) {
+	if !slices.Contains(*sources, Source) {
+		*sources = append(*sources, Source)
+	}
+}
+
+// shownName is the name of the command the override applies to once it is
+// applied.
+func (c *Command) shownName() string {
+	if c.Rename != "" {
+		return c.Rename
+	}
+	return c.Name
+}
+
+// CommandAt returns the override of the command at path below c, the
+// names in path being those the tree shows with c applied. Overrides
+// missing on the way are added.
+func (c *Command) CommandAt(path []string) *Command {
+	for _, name := range path {
+		i := slices.IndexFunc(c.Commands, func(s Command) bool { return s.shownName() == name })
+		if i < 0 {
+			c.Commands = append(c.Commands, Command{Name: name})
+			i = len(c.Commands) - 1
+		}
+		c = &c.Commands[i]
+	}
+	return c
+}
+
+// FlagAt returns the override of c's flag shown as name, adding it if
+// there is none.
+func (c *Command) FlagAt(name string) *Flag {
+	i := slices.IndexFunc(c.Flags, func(f Flag) bool {
+		return f.Name == name && f.Rename == "" || f.Rename == name
+	})
+	if i < 0 {
+		c.Flags = append(c.Flags, Flag{Name: name})
+		i = len(c.Flags) - 1
+	}
+	return &c.Flags[i]
+}
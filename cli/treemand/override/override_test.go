@@ -0,0 +1,132 @@
+package override_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/override"
+)
+
+func gitTree() *models.Node {
+	return &models.Node{
+		Name: "git", FullPath: []string{"git"},
+		Flags: []models.Flag{{Name: "--exec-path", ValueType: "string"}, {Name: "--paginate", ValueType: "pager", Confidence: 0.3}},
+		Children: []*models.Node{
+			{Name: "remote", FullPath: []string{"git", "remote"}, Children: []*models.Node{
+				{Name: "add", FullPath: []string{"git", "remote", "add"}},
+			}},
+			{Name: "whatchanged", FullPath: []string{"git", "whatchanged"}},
+		},
+	}
+}
+
+const gitOverride = `description: the stupid content tracker
+flags:
+  - {name: --exec-path, type: path}
+  - {name: --paginate, short: p, type: bool}
+  - {name: --no-such, remove: true}
+commands:
+  - name: remote
+    commands:
+      - name: add
+        flags:
+          - {name: --fetch, short: f, description: Fetch after adding}
+  - name: whatchanged
+    remove: true
+  - name: lfs
+    description: Git Large File Storage
+`
+
+func TestApply(t *testing.T) {
+	o, err := override.Parse([]byte(gitOverride))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := gitTree()
+	stale := override.Apply(root, o)
+
+	if root.Description != "the stupid content tracker" || !slices.Contains(root.Sources, override.Source) {
+		t.Errorf("root = %+v, want the override's description", root)
+	}
+	if f := root.Flags[0]; f.ValueType != "path" {
+		t.Errorf("--exec-path type = %q, want path", f.ValueType)
+	}
+	if f := root.Flags[1]; f.TakesValue() || f.ShortName != "p" || f.Doubtful() {
+		t.Errorf("--paginate = %+v, want a bool -p no longer doubtful", f)
+	}
+	add := root.FindPath([]string{"remote", "add"})
+	if len(add.Flags) != 1 || add.Flags[0].Name != "--fetch" || add.Flags[0].ShortName != "f" {
+		t.Errorf("remote add flags = %+v, want --fetch added", add.Flags)
+	}
+	if root.Find("whatchanged") != nil {
+		t.Error("whatchanged was not removed")
+	}
+	if lfs := root.Find("lfs"); lfs == nil || lfs.FullCommand() != "git lfs" || !lfs.Discovered {
+		t.Errorf("lfs = %+v, want it added", lfs)
+	}
+	if !slices.Equal(stale, []string{"git --no-such"}) {
+		t.Errorf("stale = %v, want git --no-such", stale)
+	}
+}
+
+func TestApply_subtree(t *testing.T) {
+	o, err := override.Parse([]byte(gitOverride))
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := gitTree().Find("remote")
+	override.Apply(remote, o)
+	if add := remote.Find("add"); len(add.Flags) != 1 {
+		t.Errorf("remote add flags = %+v, want --fetch added to the subtree", add.Flags)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if o, err := override.Load(dir, "git"); o != nil || err != nil {
+		t.Errorf("Load without a file = %v, %v; want nil, nil", o, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "git.yaml"), []byte("descripton: typo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := override.Load(dir, "git"); err == nil {
+		t.Error("an unknown key was accepted")
+	}
+}
+
+func TestApply_rename(t *testing.T) {
+	o := &override.Command{Name: "git"}
+	o.CommandAt([]string{"remote"}).Rename = "rmt"
+	o.CommandAt([]string{"rmt"}).FlagAt("--verbose").Rename = "--loud"
+	root := gitTree()
+	override.Apply(root, o)
+	add := root.FindPath([]string{"rmt", "add"})
+	if add == nil || add.FullCommand() != "git rmt add" {
+		t.Fatalf("rmt add = %+v, want remote renamed with its subtree", add)
+	}
+	if len(o.Commands) != 1 || o.Commands[0].Name != "remote" {
+		t.Errorf("CommandAt by the new name added an override: %+v", o.Commands)
+	}
+	if f := root.Find("rmt").Flags; len(f) != 1 || f[0].Name != "--loud" {
+		t.Errorf("rmt flags = %+v, want --verbose added as --loud", f)
+	}
+}
+
+func TestFileSave(t *testing.T) {
+	file := override.File{Dir: filepath.Join(t.TempDir(), "overrides"), CLI: "git"}
+	o, err := file.Load()
+	if err != nil || o.Name != "git" {
+		t.Fatalf("Load without a file = %+v, %v; want an empty override of git", o, err)
+	}
+	o.CommandAt([]string{"lfs"}).Description = "Git Large File Storage"
+	if err := file.Save(o); err != nil {
+		t.Fatal(err)
+	}
+	again, err := file.Load()
+	if err != nil || len(again.Commands) != 1 || again.Commands[0].Description != "Git Large File Storage" {
+		t.Errorf("saved and loaded = %+v, %v", again, err)
+	}
+}
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"encoding/csv"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// renderTable writes the tree as CSV, or as TSV when comma is '\t': one row
+// per command (path, description, flag count), or with Options.FlagRows one
+// row per own flag (path, flag, short, type, default, description). Both
+// start with a header row and honor the same filters as flat output.
+func (r *Renderer) renderTable(w io.Writer, root *models.Node, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	if r.opts.FlagRows {
+		_ = cw.Write([]string{"command", "flag", "short", "type", "default", "description"})
+	} else {
+		_ = cw.Write([]string{"command", "description", "flags"})
+	}
+	for _, node := range r.walk(root) {
+		flags := r.ownFlags(node)
+		if !r.opts.FlagRows {
+			_ = cw.Write([]string{node.FullCommand(), node.Description, strconv.Itoa(len(flags))})
+			continue
+		}
+		for _, f := range flags {
+			short := ""
+			if f.ShortName != "" {
+				short = "-" + f.ShortName
+			}
+			_ = cw.Write([]string{node.FullCommand(), f.Name, short, f.ValueType, flagDefault(f.Description), f.Description})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// defaultRe matches the default value help output mentions in a flag's
+// description: "(default 10)", "(default: "json")" or clap's "[default: 10]".
+var defaultRe = regexp.MustCompile(`[(\[]default:?\s+([^)\]]*)[)\]]`)
+
+// flagDefault returns the default value stated in a flag description, or "".
+func flagDefault(desc string) string {
+	m := defaultRe.FindStringSubmatch(desc)
+	if m == nil {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(m[1]), `"'`)
+}
@@ -0,0 +1,154 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// rstUnderlines are the section adornments used per heading level, in the
+// order Sphinx's own docs recommend.
+var rstUnderlines = []byte{'=', '-', '~', '^', '"', '\'', '`', '#', '*', '+'}
+
+// renderOutline writes one section per command, nested by depth, in Emacs
+// Org-mode (format "org") or reStructuredText (format "rst"). Each section
+// holds the description, any note in Options.Notes, a usage line and a
+// list of the command's own flags and positionals.
+func (r *Renderer) renderOutline(w io.Writer, root *models.Node, format string) error {
+	level := -1
+	for _, node := range r.walk(root) {
+		// Filters can skip ancestors; never nest more than one level deeper
+		// than the previous heading, which reStructuredText rejects.
+		level = min(max(len(node.FullPath)-1, 0), level+1)
+		var err error
+		if format == "org" {
+			err = r.writeOrgSection(w, node, level)
+		} else {
+			err = r.writeRSTSection(w, node, level)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Renderer) writeOrgSection(w io.Writer, node *models.Node, level int) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", strings.Repeat("*", level+1), node.FullCommand())
+	if node.Description != "" {
+		fmt.Fprintf(&b, "%s\n", node.Description)
+	}
+	if note := r.opts.Notes[node.FullCommand()]; note != "" {
+		fmt.Fprintf(&b, "\n#+begin_note\n%s\n#+end_note\n", note)
+	}
+	fmt.Fprintf(&b, "\nUsage: =%s=\n", usageLine(node))
+	if len(node.Positionals) > 0 {
+		b.WriteString("\nArguments:\n")
+		for _, p := range node.Positionals {
+			b.WriteString(listItem("="+posPlaceholder(p)+"=", " :: ", p.Description))
+		}
+	}
+	for _, g := range r.flagGroups(node) {
+		fmt.Fprintf(&b, "\n%s:\n", g.heading())
+		for _, f := range g.flags {
+			b.WriteString(listItem("="+flagTerm(f)+"=", " :: ", f.Description))
+		}
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (r *Renderer) writeRSTSection(w io.Writer, node *models.Node, level int) error {
+	var b strings.Builder
+	title := node.FullCommand()
+	underline := rstUnderlines[min(level, len(rstUnderlines)-1)]
+	fmt.Fprintf(&b, "%s\n%s\n\n", title, strings.Repeat(string(underline), len(title)))
+	if node.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", rstEscape(node.Description))
+	}
+	if note := r.opts.Notes[node.FullCommand()]; note != "" {
+		fmt.Fprintf(&b, ".. note:: %s\n\n", rstEscape(note))
+	}
+	fmt.Fprintf(&b, "Usage: ``%s``\n\n", usageLine(node))
+	if len(node.Positionals) > 0 {
+		b.WriteString("Arguments:\n\n")
+		for _, p := range node.Positionals {
+			b.WriteString(listItem("``"+posPlaceholder(p)+"``", ": ", rstEscape(p.Description)))
+		}
+		b.WriteString("\n")
+	}
+	for _, g := range r.flagGroups(node) {
+		fmt.Fprintf(&b, "%s:\n\n", rstEscape(g.heading()))
+		for _, f := range g.flags {
+			b.WriteString(listItem("``"+flagTerm(f)+"``", ": ", rstEscape(f.Description)))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// listItem renders a "- term<sep>desc" list line, leaving out sep when
+// there is no description.
+func listItem(term, sep, desc string) string {
+	if desc == "" {
+		return "- " + term + "\n"
+	}
+	return "- " + term + sep + desc + "\n"
+}
+
+// usageLine returns node's full command followed by its positionals, e.g.
+// "git remote add <name> <url>".
+func usageLine(node *models.Node) string {
+	parts := []string{node.FullCommand()}
+	for _, alts := range node.PositionalSlots() {
+		parts = append(parts, slotPlaceholder(alts))
+	}
+	return strings.Join(parts, " ")
+}
+
+// posPlaceholder renders a positional the way usage lines do: <name> when
+// required, [name] when optional, with "..." for variadic arguments.
+func posPlaceholder(p models.Positional) string {
+	name := p.Name
+	if p.Variadic {
+		name += "..."
+	}
+	if p.Required {
+		return "<" + name + ">"
+	}
+	return "[" + name + "]"
+}
+
+// slotPlaceholder renders a positional slot like posPlaceholder, with its
+// alternatives separated by "|": <url|file>.
+func slotPlaceholder(alts []*models.Positional) string {
+	p := *alts[0]
+	for _, a := range alts[1:] {
+		p.Name += "|" + a.Name
+	}
+	return posPlaceholder(p)
+}
+
+// flagTerm renders a flag with its short form and value, e.g.
+// "-m, --message <string>".
+func flagTerm(f models.Flag) string {
+	s := f.Name
+	if f.ShortName != "" {
+		s = "-" + f.ShortName + ", " + s
+	}
+	if f.TakesValue() {
+		s += " <" + f.ValueType + ">"
+	}
+	return s
+}
+
+// rstEscape backslash-escapes the characters reStructuredText would read as
+// inline markup.
+func rstEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "`", "\\`", "_", `\_`, "|", `\|`).Replace(s)
+}
@@ -0,0 +1,157 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ManHeader is the .TH metadata shared by generated man pages.
+type ManHeader struct {
+	Section string    // manual section, e.g. "1"
+	Date    time.Time // shown in the footer
+	Source  string    // e.g. "git 2.43.0"
+	Manual  string    // e.g. "git manual"
+}
+
+// Commands returns the commands under root in display order, honoring
+// MaxDepth, Filter, Exclude and Sort. Virtual flag groups are folded into
+// their parent commands.
+func (r *Renderer) Commands(root *models.Node) []*models.Node {
+	return r.walk(root)
+}
+
+// ManPageName returns the file name of node's man page, e.g.
+// "git-remote-add.1".
+func ManPageName(node *models.Node, section string) string {
+	return manTitle(node) + "." + section
+}
+
+// manTitle returns node's full path joined with dashes, the way man pages
+// of subcommands are named.
+func manTitle(node *models.Node) string {
+	if len(node.FullPath) == 0 {
+		return node.Name
+	}
+	return strings.Join(node.FullPath, "-")
+}
+
+// WriteManPage writes a roff man page for node built from its description,
+// positionals, own flags and subcommands.
+func (r *Renderer) WriteManPage(w io.Writer, node *models.Node, h ManHeader) error {
+	var b strings.Builder
+	title := manTitle(node)
+	b.WriteString(".TH")
+	for _, field := range []string{strings.ToUpper(title), h.Section, h.Date.Format("Jan 2006"), h.Source, h.Manual} {
+		b.WriteString(` "` + roffEscape(strings.ReplaceAll(field, `"`, "'")) + `"`)
+	}
+	b.WriteString("\n")
+
+	b.WriteString(".SH NAME\n")
+	if node.Description != "" {
+		fmt.Fprintf(&b, "%s \\- %s\n", roffEscape(title), roffEscape(node.Description))
+	} else {
+		b.WriteString(roffEscape(title) + "\n")
+	}
+
+	b.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(&b, "\\fB%s\\fR", roffEscape(node.FullCommand()))
+	flags := r.ownFlags(node)
+	if len(flags) > 0 {
+		b.WriteString(" [\\fIOPTIONS\\fR]")
+	}
+	for _, alts := range node.PositionalSlots() {
+		fmt.Fprintf(&b, " \\fI%s\\fR", roffEscape(slotPlaceholder(alts)))
+	}
+	b.WriteString("\n")
+
+	if node.Description != "" {
+		b.WriteString(".SH DESCRIPTION\n")
+		b.WriteString(roffText(node.Description))
+	}
+
+	if len(node.Positionals) > 0 {
+		b.WriteString(".SH ARGUMENTS\n")
+		for _, p := range node.Positionals {
+			fmt.Fprintf(&b, ".TP\n\\fI%s\\fR\n", roffEscape(posPlaceholder(p)))
+			b.WriteString(roffText(p.Description))
+		}
+	}
+
+	if len(flags) > 0 {
+		b.WriteString(".SH OPTIONS\n")
+	}
+	for _, g := range r.flagGroups(node) {
+		if g.name != "" {
+			fmt.Fprintf(&b, ".SS %s\n", roffEscape(g.name))
+		}
+		for _, f := range g.flags {
+			b.WriteString(".TP\n")
+			if f.ShortName != "" {
+				fmt.Fprintf(&b, "\\fB%s\\fR, ", roffEscape("-"+f.ShortName))
+			}
+			fmt.Fprintf(&b, "\\fB%s\\fR", roffEscape(f.Name))
+			if f.TakesValue() {
+				fmt.Fprintf(&b, " \\fI%s\\fR", roffEscape(f.ValueType))
+			}
+			b.WriteString("\n")
+			b.WriteString(roffText(f.Description))
+		}
+	}
+
+	var subs []*models.Node
+	for _, c := range SortNodes(node.Children, r.opts.Sort) {
+		if !c.Virtual {
+			subs = append(subs, c)
+		}
+	}
+	if len(subs) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, c := range subs {
+			fmt.Fprintf(&b, ".TP\n\\fB%s\\fR\n", roffEscape(c.Name))
+			b.WriteString(roffText(c.Description))
+		}
+	}
+
+	var seeAlso []string
+	if len(node.FullPath) > 1 {
+		parent := &models.Node{Name: node.FullPath[len(node.FullPath)-2], FullPath: node.FullPath[:len(node.FullPath)-1]}
+		seeAlso = append(seeAlso, fmt.Sprintf("\\fB%s\\fR(%s)", roffEscape(manTitle(parent)), h.Section))
+	}
+	for _, c := range subs {
+		seeAlso = append(seeAlso, fmt.Sprintf("\\fB%s\\fR(%s)", roffEscape(manTitle(c)), h.Section))
+	}
+	if len(seeAlso) > 0 {
+		fmt.Fprintf(&b, ".SH SEE ALSO\n%s\n", strings.Join(seeAlso, ", "))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// roffEscape escapes backslashes and dashes so roff prints s literally.
+func roffEscape(s string) string {
+	return strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+}
+
+// roffText escapes s as a paragraph: one output line per input line, with
+// lines that roff would read as requests (leading "." or "'") guarded.
+// Empty text yields no lines.
+func roffText(s string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		line = roffEscape(line)
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			line = `\&` + line
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
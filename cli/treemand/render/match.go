@@ -0,0 +1,112 @@
+package render
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// Matcher tests commands against a --filter or --exclude pattern.
+//
+// A pattern is a comma-separated list of terms; a command matches if any
+// term does. Each term is a case-insensitive regular expression, or a
+// literal substring if it does not compile (so a half-typed "[" in the TUI
+// filter still works). Terms are matched against the command's name and
+// description; terms containing a space are matched against the full
+// command path ("remote add", "^git remote") instead of the name.
+type Matcher struct {
+	terms []matchTerm
+}
+
+type matchTerm struct {
+	re   *regexp.Regexp
+	path bool
+}
+
+// NewMatcher compiles pattern. An empty pattern yields an empty Matcher,
+// which matches nothing.
+func NewMatcher(pattern string) *Matcher {
+	m := &Matcher{}
+	for _, term := range strings.Split(pattern, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + term)
+		if err != nil {
+			re = regexp.MustCompile("(?i)" + regexp.QuoteMeta(term))
+		}
+		m.terms = append(m.terms, matchTerm{re: re, path: strings.Contains(term, " ")})
+	}
+	return m
+}
+
+// Empty reports whether the pattern had no terms.
+func (m *Matcher) Empty() bool { return len(m.terms) == 0 }
+
+// Match reports whether node matches any term.
+func (m *Matcher) Match(node *models.Node) bool {
+	for _, t := range m.terms {
+		if t.path && t.re.MatchString(node.FullCommand()) {
+			return true
+		}
+		if !t.path && t.re.MatchString(node.Name) {
+			return true
+		}
+		if node.Description != "" && t.re.MatchString(node.Description) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchString reports whether s matches any term, ignoring whether the
+// term is a path term. Used for rows that are not commands, e.g. flags.
+func (m *Matcher) MatchString(s string) bool {
+	for _, t := range m.terms {
+		if t.re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Indexes returns the spans of s the terms match, as start and end byte
+// offsets, in order and with overlapping spans merged. Empty matches are
+// left out. Used to highlight the matched text.
+func (m *Matcher) Indexes(s string) [][2]int {
+	var spans [][2]int
+	for _, t := range m.terms {
+		for _, loc := range t.re.FindAllStringIndex(s, -1) {
+			if loc[0] < loc[1] {
+				spans = append(spans, [2]int{loc[0], loc[1]})
+			}
+		}
+	}
+	slices.SortFunc(spans, func(a, b [2]int) int { return a[0] - b[0] })
+	var merged [][2]int
+	for _, sp := range spans {
+		if n := len(merged); n > 0 && sp[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], sp[1])
+			continue
+		}
+		merged = append(merged, sp)
+	}
+	return merged
+}
+
+// MatchesDescendant reports whether any non-virtual descendant of node
+// matches.
+func (m *Matcher) MatchesDescendant(node *models.Node) bool {
+	for _, c := range node.Children {
+		if c.Virtual {
+			continue
+		}
+		if m.Match(c) || m.MatchesDescendant(c) {
+			return true
+		}
+	}
+	return false
+}
@@ -17,15 +17,24 @@ import (
 // Options controls tree rendering behavior.
 type Options struct {
 	MaxDepth       int
-	Filter         string
+	Filter         string // see Matcher for the pattern syntax
 	Exclude        string
 	CommandsOnly   bool
 	FullPath       bool
 	NoColor        bool
-	Output         string // text, json, yaml
+	ASCII          bool   // draw connectors with 7-bit ASCII instead of box-drawing glyphs
+	Accessible     bool   // spell flag types out as [bool]/[str] tags and leave descriptions undimmed
+	Output         string // text, json, yaml, jsonl, flat, template, csv, tsv, org, rst
+	Template       string // text/template source for Output "template"
+	FlagRows       bool   // csv/tsv: one row per flag instead of per command
+	Flat           bool   // text output as one line per command instead of a tree
 	Colors         config.ColorScheme
 	Icons          config.IconSet
 	DescLineLength int // max runes in a description before truncation
+	Sort           config.SortMode
+	// Notes are the user's notes on commands, by full command; org, rst
+	// and template output show them.
+	Notes map[string]string
 }
 
 // DefaultOptions returns rendering options with sensible defaults.
@@ -41,8 +50,10 @@ func DefaultOptions() Options {
 
 // Renderer renders a command tree.
 type Renderer struct {
-	opts   Options
-	styles styles
+	opts    Options
+	styles  styles
+	filter  *Matcher
+	exclude *Matcher
 }
 
 type styles struct {
@@ -61,8 +72,8 @@ type styles struct {
 
 // New creates a Renderer with the given options.
 func New(opts Options) *Renderer {
-	r := &Renderer{opts: opts}
-	if opts.NoColor {
+	r := &Renderer{opts: opts, filter: NewMatcher(opts.Filter), exclude: NewMatcher(opts.Exclude)}
+	if opts.NoColor || opts.Output == "flat" {
 		r.styles = styles{
 			base:       lipgloss.NewStyle(),
 			subcmd:     lipgloss.NewStyle(),
@@ -90,6 +101,9 @@ func New(opts Options) *Renderer {
 			invalid:    lipgloss.NewStyle().Foreground(lipgloss.Color(opts.Colors.Invalid)),
 			dim:        lipgloss.NewStyle().Faint(true),
 		}
+		if opts.Accessible {
+			r.styles.dim = lipgloss.NewStyle()
+		}
 	}
 	return r
 }
@@ -100,12 +114,43 @@ func (r *Renderer) Render(w io.Writer, root *models.Node) error {
 	case "json":
 		enc := json.NewEncoder(w)
 		enc.SetIndent("", "  ")
-		return enc.Encode(root)
+		return enc.Encode(versioned(root))
 	case "yaml":
 		enc := yaml.NewEncoder(w)
 		enc.SetIndent(2)
-		return enc.Encode(root)
+		return enc.Encode(versioned(root))
+	case "jsonl":
+		// A command's virtual flag groups follow it, as they would in a
+		// walk of the whole tree.
+		for _, n := range r.walk(root) {
+			if err := WriteJSONLine(w, n); err != nil {
+				return err
+			}
+			for _, c := range n.Children {
+				if c.Virtual {
+					if err := WriteJSONLine(w, c); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	case "flat":
+		r.renderFlat(w, root)
+		return nil
+	case "template":
+		return r.renderTemplate(w, root)
+	case "csv":
+		return r.renderTable(w, root, ',')
+	case "tsv":
+		return r.renderTable(w, root, '\t')
+	case "org", "rst":
+		return r.renderOutline(w, root, r.opts.Output)
 	case "text", "":
+		if r.opts.Flat {
+			r.renderFlat(w, root)
+			return nil
+		}
 		r.renderNode(w, root, "", true, 0)
 		return nil
 	default:
@@ -113,29 +158,60 @@ func (r *Renderer) Render(w io.Writer, root *models.Node) error {
 	}
 }
 
-const (
-	connLast    = "└── "
-	connMid     = "├── "
-	connLastPad = "    "
-	connMidPad  = "│   "
+// WriteJSONLine writes node as one line of JSON Lines output: the node's
+// fields without its children, which get lines of their own.
+func WriteJSONLine(w io.Writer, node *models.Node) error {
+	n := *node
+	n.Children = nil
+	return json.NewEncoder(w).Encode(&n)
+}
+
+// versioned returns a shallow copy of root stamped with the schema version,
+// leaving the caller's tree untouched.
+func versioned(root *models.Node) *models.Node {
+	if root == nil {
+		return nil
+	}
+	v := *root
+	v.SchemaVersion = models.SchemaVersion
+	return &v
+}
+
+// connectors are the glyphs joining a node to its parent: mid and last
+// for the node's own line, midPad and lastPad for its children's lines.
+type connectors struct {
+	mid, last, midPad, lastPad string
+}
+
+var (
+	boxConnectors   = connectors{mid: "├── ", last: "└── ", midPad: "│   ", lastPad: "    "}
+	asciiConnectors = connectors{mid: "|-- ", last: "`-- ", midPad: "|   ", lastPad: "    "}
 )
 
+// connectors returns the connector set chosen by Options.ASCII.
+func (r *Renderer) connectors() connectors {
+	if r.opts.ASCII {
+		return asciiConnectors
+	}
+	return boxConnectors
+}
+
 func (r *Renderer) renderNode(w io.Writer, node *models.Node, prefix string, isLast bool, depth int) {
 	if r.opts.MaxDepth >= 0 && depth > r.opts.MaxDepth {
 		return
 	}
-	if r.opts.Exclude != "" && strings.Contains(node.Name, r.opts.Exclude) {
+	if r.exclude.Match(node) {
 		return
 	}
-	if r.opts.Filter != "" && !strings.Contains(node.Name, r.opts.Filter) &&
-		!r.hasMatchingDescendant(node, r.opts.Filter) {
+	if !r.filter.Empty() && !r.filter.Match(node) && !r.filter.MatchesDescendant(node) {
 		return
 	}
 
 	// Choose connector
-	conn := connMid
+	conns := r.connectors()
+	conn := conns.mid
 	if isLast {
-		conn = connLast
+		conn = conns.last
 	}
 
 	// Choose icon
@@ -146,22 +222,25 @@ func (r *Renderer) renderNode(w io.Writer, node *models.Node, prefix string, isL
 		icon = r.opts.Icons.Branch
 	}
 
-	// Format the node name
+	// Format the node name; the root's is a command path when the tree
+	// starts at a subcommand.
+	name := node.Name
+	if r.opts.FullPath || depth == 0 {
+		name = node.FullCommand()
+	}
 	var namePart string
 	switch depth {
 	case 0:
-		namePart = r.styles.base.Render(node.Name)
+		namePart = r.styles.base.Render(name)
 	default:
-		if r.opts.CommandsOnly || node.IsLeaf() {
-			namePart = r.styles.subcmd.Render(node.Name)
-		} else {
-			namePart = r.styles.subcmd.Render(node.Name)
-		}
+		namePart = r.styles.subcmd.Render(name)
 	}
 
 	// Build inline metadata
 	var meta []string
+	var groups []flagGroup
 	if !r.opts.CommandsOnly {
+		groups = r.sectionGroups(node)
 		for _, p := range node.Positionals {
 			if p.Required {
 				meta = append(meta, r.styles.pos.Render("<"+p.Name+">"))
@@ -169,25 +248,16 @@ func (r *Renderer) renderNode(w io.Writer, node *models.Node, prefix string, isL
 				meta = append(meta, r.styles.pos.Render("["+p.Name+"]"))
 			}
 		}
-		// Only count / show own (non-inherited) flags.
-		var ownFlags []models.Flag
-		for _, f := range node.Flags {
-			if !f.Inherited {
-				ownFlags = append(ownFlags, f)
-			}
+		// Only count / show own (non-inherited) flags; grouped flags are
+		// shown on their group's line.
+		ownFlags := node.Flags
+		if groups != nil && groups[0].name == "" {
+			ownFlags = groups[0].flags
+		} else if groups != nil {
+			ownFlags = nil
 		}
-		if len(ownFlags) > 0 && len(ownFlags) <= 5 {
-			var flagStrs []string
-			for _, f := range ownFlags {
-				fs := r.flagStyle(f.ValueType).Render(f.Name)
-				if f.ValueType != "" && f.ValueType != "bool" {
-					fs += "=" + r.styles.value.Render("<"+f.ValueType+">")
-				}
-				flagStrs = append(flagStrs, fs)
-			}
-			meta = append(meta, "["+strings.Join(flagStrs, ",")+"]")
-		} else if len(ownFlags) > 5 {
-			meta = append(meta, r.styles.dim.Render(fmt.Sprintf("[%d flags]", len(ownFlags))))
+		if pills := r.flagPills(ownFlags); pills != "" {
+			meta = append(meta, pills)
 		}
 	}
 
@@ -209,9 +279,16 @@ func (r *Renderer) renderNode(w io.Writer, node *models.Node, prefix string, isL
 	suffix := ""
 	if node.Stub {
 		suffix = "  " + r.styles.dim.Render("(…)")
+	} else if node.AliasOf != "" {
+		suffix = "  " + r.styles.dim.Render("(alias of "+node.AliasOf+")")
+	} else if node.Interactive {
+		suffix = "  " + r.styles.dim.Render("(interactive)")
 	} else if node.DiscoveryErr != "" {
 		suffix = "  " + r.styles.dim.Render("(?)")
 	}
+	if node.Hidden {
+		suffix = "  " + r.styles.dim.Render("(hidden)") + suffix
+	}
 
 	line := prefix
 	if depth > 0 {
@@ -229,27 +306,172 @@ func (r *Renderer) renderNode(w io.Writer, node *models.Node, prefix string, isL
 	childPrefix := prefix
 	if depth > 0 {
 		if isLast {
-			childPrefix += connLastPad
+			childPrefix += conns.lastPad
 		} else {
-			childPrefix += connMidPad
+			childPrefix += conns.midPad
 		}
 	}
 
-	for i, child := range node.Children {
-		r.renderNode(w, child, childPrefix, i == len(node.Children)-1, depth+1)
+	children := SortNodes(node.Children, r.opts.Sort)
+	// Flag sections are drawn like virtual group nodes, before the
+	// subcommands.
+	for i, g := range groups {
+		if g.name == "" {
+			continue
+		}
+		isLast := i == len(groups)-1 && len(children) == 0
+		conn := conns.mid
+		if isLast {
+			conn = conns.last
+		}
+		line := childPrefix + conn + r.opts.Icons.Virtual + r.styles.subcmd.Render(g.name)
+		if pills := r.flagPills(g.flags); pills != "" {
+			line += " " + pills
+		}
+		fmt.Fprintln(w, line)
+	}
+	for i, child := range children {
+		r.renderNode(w, child, childPrefix, i == len(children)-1, depth+1)
 	}
 }
 
-func (r *Renderer) hasMatchingDescendant(node *models.Node, filter string) bool {
-	for _, child := range node.Children {
-		if strings.Contains(child.Name, filter) {
-			return true
+// flagPills renders the own (non-inherited) flags among flags inline, as
+// "[--all,--output=<string>]", or as a count when there are more than
+// five; "" when there are none. With Options.Accessible they read
+// "flags: --all [bool], --output [str]", their types not left to color.
+func (r *Renderer) flagPills(flags []models.Flag) string {
+	var own []models.Flag
+	for _, f := range SortedFlags(flags, r.opts.Sort) {
+		if !f.Inherited {
+			own = append(own, f)
+		}
+	}
+	switch {
+	case len(own) == 0:
+		return ""
+	case len(own) > 5 && r.opts.Accessible:
+		return r.styles.dim.Render(fmt.Sprintf("%d flags", len(own)))
+	case len(own) > 5:
+		return r.styles.dim.Render(fmt.Sprintf("[%d flags]", len(own)))
+	}
+	var flagStrs []string
+	if r.opts.Accessible {
+		for _, f := range own {
+			flagStrs = append(flagStrs, r.flagStyle(f.ValueType).Render(f.Name)+" "+r.styles.value.Render(f.TypeTag()))
+		}
+		return "flags: " + strings.Join(flagStrs, ", ")
+	}
+	for _, f := range own {
+		fs := r.flagStyle(f.ValueType).Render(f.Name)
+		if f.TakesValue() {
+			fs += "=" + r.styles.value.Render("<"+f.ValueType+">")
+		}
+		flagStrs = append(flagStrs, fs)
+	}
+	return "[" + strings.Join(flagStrs, ",") + "]"
+}
+
+// flagGroup is a run of flags shown under one heading; name is "" for
+// flags in no group.
+type flagGroup struct {
+	name  string
+	flags []models.Flag
+}
+
+// heading returns the title of the list of g's flags.
+func (g flagGroup) heading() string {
+	if g.name == "" {
+		return "Flags"
+	}
+	return g.name
+}
+
+// sectionGroups returns node's own flags grouped by its FlagSections, in
+// Options.Sort order, or nil when they are not in several groups.
+func (r *Renderer) sectionGroups(node *models.Node) []flagGroup {
+	var own []int
+	for _, i := range FlagOrder(node.Flags, r.opts.Sort) {
+		if !node.Flags[i].Inherited {
+			own = append(own, i)
+		}
+	}
+	var groups []flagGroup
+	for _, g := range node.FlagGroups(own) {
+		fg := flagGroup{name: g.Name}
+		for _, i := range g.Flags {
+			fg.flags = append(fg.flags, node.Flags[i])
+		}
+		groups = append(groups, fg)
+	}
+	return groups
+}
+
+// flagGroups returns node's own flags as the outline and man page formats
+// list them: grouped by its FlagSections, or else all in one group with no
+// name.
+func (r *Renderer) flagGroups(node *models.Node) []flagGroup {
+	if groups := r.sectionGroups(node); groups != nil {
+		return groups
+	}
+	if own := r.ownFlags(node); len(own) > 0 {
+		return []flagGroup{{flags: own}}
+	}
+	return nil
+}
+
+// renderFlat writes one line per command: its full path followed by its
+// positionals and, unless CommandsOnly is set, its own flags, e.g.
+//
+//	git remote add <name> <url> [--fetch] [--tags]
+//
+// Output "flat" is always uncolored so it can be piped into grep or fzf.
+func (r *Renderer) renderFlat(w io.Writer, root *models.Node) {
+	for _, node := range r.walk(root) {
+		fmt.Fprintln(w, r.flatLine(node))
+	}
+}
+
+func (r *Renderer) flatLine(node *models.Node) string {
+	path := node.FullPath
+	if len(path) == 0 {
+		path = []string{node.Name}
+	}
+	parts := []string{r.styles.base.Render(path[0])}
+	for _, p := range path[1:] {
+		parts = append(parts, r.styles.subcmd.Render(p))
+	}
+	if r.opts.CommandsOnly {
+		return strings.Join(parts, " ")
+	}
+	for _, alts := range node.PositionalSlots() {
+		parts = append(parts, r.styles.pos.Render(slotPlaceholder(alts)))
+	}
+	for _, f := range r.ownFlags(node) {
+		fs := r.flagStyle(f.ValueType).Render(f.Name)
+		if f.TakesValue() {
+			fs += " " + r.styles.value.Render("<"+f.ValueType+">")
 		}
-		if r.hasMatchingDescendant(child, filter) {
-			return true
+		parts = append(parts, "["+fs+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// ownFlags returns node's non-inherited flags, including those of its
+// virtual flag groups, in Options.Sort order.
+func (r *Renderer) ownFlags(node *models.Node) []models.Flag {
+	flags := node.Flags
+	for _, c := range node.Children {
+		if c.Virtual {
+			flags = append(append([]models.Flag{}, flags...), c.Flags...)
+		}
+	}
+	var own []models.Flag
+	for _, f := range SortedFlags(flags, r.opts.Sort) {
+		if !f.Inherited {
+			own = append(own, f)
 		}
 	}
-	return false
+	return own
 }
 
 // ToString renders the tree to a string.
@@ -262,11 +484,12 @@ func ToString(root *models.Node, opts Options) (string, error) {
 	return sb.String(), nil
 }
 
-// Stats returns a count of nodes in the tree.
+// Stats summarizes the size of a tree.
 type Stats struct {
-	Commands int
-	Flags    int
-	MaxDepth int
+	Commands    int `json:"commands"` // non-virtual nodes, including the root
+	Flags       int `json:"flags"`    // own (non-inherited) flags
+	Positionals int `json:"positionals"`
+	MaxDepth    int `json:"max_depth"`
 }
 
 // Collect gathers stats from a tree.
@@ -277,8 +500,12 @@ func Collect(root *models.Node) Stats {
 }
 
 func collectStats(node *models.Node, depth int, s *Stats) {
+	s.Flags += ownFlagCount(node)
+	s.Positionals += len(node.Positionals)
+	if node.Virtual {
+		return // flag group: its flags belong to the parent command
+	}
 	s.Commands++
-	s.Flags += len(node.Flags)
 	if depth > s.MaxDepth {
 		s.MaxDepth = depth
 	}
@@ -287,6 +514,13 @@ func collectStats(node *models.Node, depth int, s *Stats) {
 	}
 }
 
+// String formats s as a one-line summary, e.g.
+// "42 commands · 310 flags · 12 positionals · max depth 3".
+func (s Stats) String() string {
+	return fmt.Sprintf("%d commands · %d flags · %d positionals · max depth %d",
+		s.Commands, s.Flags, s.Positionals, s.MaxDepth)
+}
+
 // flagStyle returns the lipgloss style for a flag based on its value type.
 func (r *Renderer) flagStyle(valueType string) lipgloss.Style {
 	switch valueType {
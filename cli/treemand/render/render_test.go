@@ -1,8 +1,11 @@
 package render_test
 
 import (
+	"encoding/json"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aallbrig/treemand/config"
 	"github.com/aallbrig/treemand/models"
@@ -75,6 +78,106 @@ func TestRenderToString_json(t *testing.T) {
 	}
 }
 
+func TestRenderToString_jsonSchemaVersion(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.Output = "json"
+	root := sampleTree()
+	got, err := render.ToString(root, opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	if !strings.Contains(got, `"schema_version": "`+models.SchemaVersion+`"`) {
+		t.Errorf("expected schema_version on the root, got:\n%s", got)
+	}
+	if strings.Count(got, "schema_version") != 1 {
+		t.Error("schema_version should only appear on the root node")
+	}
+	if root.SchemaVersion != "" {
+		t.Error("rendering should not modify the caller's tree")
+	}
+}
+
+func TestRenderToString_flat(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.Output = "flat"
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	want := strings.Join([]string{
+		"git [--version] [--verbose]",
+		"git commit [file...] [--message <string>]",
+		"git remote",
+		"git remote add <name> <url>",
+		"git remote remove",
+	}, "\n") + "\n"
+	if got != want {
+		t.Errorf("flat output:\n%s\nwant:\n%s", got, want)
+	}
+
+	opts.CommandsOnly = true
+	opts.Filter = "remo"
+	got, _ = render.ToString(sampleTree(), opts)
+	if got != "git remote\ngit remote remove\n" {
+		t.Errorf("flat commands-only filtered output = %q", got)
+	}
+}
+
+func TestRenderToString_flatText(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.NoColor = true
+	opts.Flat = true
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	if !strings.Contains(got, "git remote add <name> <url>\n") || strings.Contains(got, "└") {
+		t.Errorf("--flat text output should be one line per command, got:\n%s", got)
+	}
+}
+
+func TestSortNodes(t *testing.T) {
+	nodes := []*models.Node{
+		{Name: "stub", Stub: true},
+		{Name: "broken", DiscoveryErr: "timeout"},
+		{Name: "Beta", Flags: []models.Flag{{Name: "--a"}, {Name: "--b"}, {Name: "--c", Inherited: true}}},
+		{Name: "alpha", Flags: []models.Flag{{Name: "--a"}}},
+	}
+	names := func(ns []*models.Node) string {
+		var out []string
+		for _, n := range ns {
+			out = append(out, n.Name)
+		}
+		return strings.Join(out, " ")
+	}
+	for mode, want := range map[config.SortMode]string{
+		config.SortNone:       "stub broken Beta alpha",
+		config.SortName:       "alpha Beta broken stub",
+		config.SortDiscovered: "Beta alpha stub broken",
+		config.SortFlags:      "Beta alpha stub broken",
+	} {
+		if got := names(render.SortNodes(nodes, mode)); got != want {
+			t.Errorf("SortNodes(%s) = %q, want %q", config.SortModeNames[mode], got, want)
+		}
+	}
+	if nodes[0].Name != "stub" {
+		t.Error("SortNodes must not reorder its input")
+	}
+}
+
+func TestRenderToString_sortName(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.Output = "flat"
+	opts.Sort = config.SortName
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	if !strings.HasPrefix(got, "git [--verbose] [--version]\n") {
+		t.Errorf("flags should be sorted by name, got:\n%s", got)
+	}
+}
+
 func TestRenderToString_maxDepth(t *testing.T) {
 	opts := render.DefaultOptions()
 	opts.NoColor = true
@@ -102,6 +205,80 @@ func TestRenderToString_filter(t *testing.T) {
 	}
 }
 
+func TestMatcher(t *testing.T) {
+	add := &models.Node{Name: "add", FullPath: []string{"git", "remote", "add"}, Description: "Add a remote"}
+	cases := []struct {
+		pattern string
+		want    bool
+	}{
+		{"ADD", true},          // case-insensitive name
+		{"^a.d$", true},        // regex
+		{"a remote", true},     // description
+		{"commit, ^ad", true},  // any of several terms
+		{"remote add", true},   // full path (contains a space)
+		{"^remote add", false}, // path terms are anchored to the full path
+		{"git remote$", false},
+		{"[add", false}, // invalid regex: matched literally
+		{"commit", false},
+	}
+	for _, c := range cases {
+		if got := render.NewMatcher(c.pattern).Match(add); got != c.want {
+			t.Errorf("NewMatcher(%q).Match(add) = %v, want %v", c.pattern, got, c.want)
+		}
+	}
+	if !render.NewMatcher(" , ").Empty() {
+		t.Error("pattern of blank terms should be empty")
+	}
+}
+
+func TestMatcher_Indexes(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		want       [][2]int
+	}{
+		{"re", "Add a remote", [][2]int{{6, 8}}},
+		{"E", "remote", [][2]int{{1, 2}, {5, 6}}},
+		{"mot,emo,x*", "remote", [][2]int{{1, 5}}}, // overlapping spans merge; empty matches are left out
+		{"commit", "remote", nil},
+	}
+	for _, c := range cases {
+		if got := render.NewMatcher(c.pattern).Indexes(c.s); !slices.Equal(got, c.want) {
+			t.Errorf("NewMatcher(%q).Indexes(%q) = %v, want %v", c.pattern, c.s, got, c.want)
+		}
+	}
+}
+
+func TestRenderToString_filterFullPathAndExcludeRegex(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.Output = "flat"
+	opts.CommandsOnly = true
+	opts.Filter = "remote add,^commit$"
+	got, _ := render.ToString(sampleTree(), opts)
+	if got != "git commit\ngit remote add\n" {
+		t.Errorf("filtered flat output = %q", got)
+	}
+
+	opts.Filter = ""
+	opts.Exclude = "^(commit|remove)$"
+	got, _ = render.ToString(sampleTree(), opts)
+	if got != "git\ngit remote\ngit remote add\n" {
+		t.Errorf("excluded flat output = %q", got)
+	}
+}
+
+func TestRenderToString_fullPath(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.NoColor = true
+	opts.FullPath = true
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	if !strings.Contains(got, "git remote add <name> <url>") {
+		t.Errorf("expected full command paths in output:\n%s", got)
+	}
+}
+
 func TestRenderToString_unknownFormat(t *testing.T) {
 	opts := render.DefaultOptions()
 	opts.Output = "toml"
@@ -133,6 +310,21 @@ func TestCollect(t *testing.T) {
 	}
 }
 
+func TestCollect_countsOwnFlagsAndPositionals(t *testing.T) {
+	root := sampleTree()
+	root.Children[0].Flags = append(root.Children[0].Flags, models.Flag{Name: "--verbose", Inherited: true})
+	root.Children = append(root.Children, &models.Node{Name: "Global Flags", Virtual: true,
+		Flags: []models.Flag{{Name: "--no-pager"}}})
+	got := render.Collect(root)
+	want := render.Stats{Commands: 5, Flags: 4, Positionals: 3, MaxDepth: 2}
+	if got != want {
+		t.Errorf("Collect = %+v, want %+v", got, want)
+	}
+	if s := got.String(); s != "5 commands · 4 flags · 3 positionals · max depth 2" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
 func TestRenderToString_icons(t *testing.T) {
 	opts := render.DefaultOptions()
 	opts.NoColor = true
@@ -307,3 +499,294 @@ func TestRenderNode_descLineLength(t *testing.T) {
 		t.Errorf("truncation ellipsis not found:\n%s", got)
 	}
 }
+
+func TestAnalyze(t *testing.T) {
+	root := sampleTree()
+	root.Children[0].Children = []*models.Node{{Name: "Options", Virtual: true,
+		Flags: []models.Flag{{Name: "--amend"}, {Name: "--all"}}}}
+	rep := render.Analyze(root, 1)
+	if rep.CLI != "git" || rep.Commands != 5 || rep.MaxDepth != 2 {
+		t.Errorf("Analyze stats = %+v", rep)
+	}
+	if rep.DeepestPath != "git remote add" {
+		t.Errorf("DeepestPath = %q, want %q", rep.DeepestPath, "git remote add")
+	}
+	if rep.Widest != (render.CommandCount{Command: "git", Count: 2}) {
+		t.Errorf("Widest = %+v", rep.Widest)
+	}
+	// commit's virtual flag group counts towards commit: 1 + 2 flags.
+	want := []render.CommandCount{{Command: "git commit", Count: 3}}
+	if len(rep.TopFlags) != 1 || rep.TopFlags[0] != want[0] {
+		t.Errorf("TopFlags = %+v, want %+v", rep.TopFlags, want)
+	}
+
+	var sb strings.Builder
+	rep.WriteText(&sb)
+	for _, s := range []string{"deepest path  git remote add", "Top 1 commands by flag count:", "3  git commit"} {
+		if !strings.Contains(sb.String(), s) {
+			t.Errorf("WriteText output missing %q:\n%s", s, sb.String())
+		}
+	}
+}
+
+func TestRenderToString_ascii(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.NoColor = true
+	opts.ASCII = true
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	for _, glyph := range []string{"├", "└", "│"} {
+		if strings.Contains(got, glyph) {
+			t.Errorf("ASCII output contains %q:\n%s", glyph, got)
+		}
+	}
+	if !strings.Contains(got, "|-- ") || !strings.Contains(got, "`-- ") {
+		t.Errorf("expected ASCII connectors, got:\n%s", got)
+	}
+}
+
+func TestRenderToString_accessible(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.NoColor = true
+	opts.Accessible = true
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	for _, want := range []string{"flags: --version [bool], --verbose [bool]", "flags: --message [str]"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "=<string>") {
+		t.Errorf("flag types should be tags, not =<type>:\n%s", got)
+	}
+}
+
+func TestRenderToString_template(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.Output = "template"
+	opts.Exclude = "remove"
+	opts.Template = `{{range walk .}}{{path .}} ({{depth .}}){{range flags .}} {{.Name}}{{end}}
+{{end}}`
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	want := "git (0) --version --verbose\ngit commit (1) --message\ngit remote (1)\ngit remote add (2)\n"
+	if got != want {
+		t.Errorf("template output:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestRenderToString_templateErrors(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.Output = "template"
+	if _, err := render.ToString(sampleTree(), opts); err == nil {
+		t.Error("expected error for an empty template")
+	}
+	opts.Template = "{{range walk .}"
+	if _, err := render.ToString(sampleTree(), opts); err == nil || !strings.Contains(err.Error(), "parse template") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+	opts.Template = "{{.NoSuchField}}"
+	if _, err := render.ToString(sampleTree(), opts); err == nil || !strings.Contains(err.Error(), "execute template") {
+		t.Errorf("expected execute error, got %v", err)
+	}
+}
+
+func TestRenderToString_csv(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.Output = "csv"
+	opts.Filter = "commit"
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	want := "command,description,flags\ngit commit,record changes to the repository,1\n"
+	if got != want {
+		t.Errorf("csv output:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestRenderToString_tsvFlagRows(t *testing.T) {
+	tree := sampleTree()
+	tree.Flags = append(tree.Flags, models.Flag{Name: "--jobs", ShortName: "j", ValueType: "int", Description: "parallel jobs (default 4)"})
+	opts := render.DefaultOptions()
+	opts.Output = "tsv"
+	opts.FlagRows = true
+	opts.MaxDepth = 0
+	got, err := render.ToString(tree, opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	want := "command\tflag\tshort\ttype\tdefault\tdescription\n" +
+		"git\t--version\t\tbool\t\t\n" +
+		"git\t--verbose\t-v\tbool\t\t\n" +
+		"git\t--jobs\t-j\tint\t4\tparallel jobs (default 4)\n"
+	if got != want {
+		t.Errorf("tsv output:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestRenderToString_org(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.Output = "org"
+	opts.Filter = "commit,add"
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	want := "* git commit\nrecord changes to the repository\n\nUsage: =git commit [file...]=\n\n" +
+		"Arguments:\n- =[file...]=\n\nFlags:\n- =-m, --message <string>=\n\n" +
+		"** git remote add\n\nUsage: =git remote add <name> <url>=\n\n" +
+		"Arguments:\n- =<name>=\n- =<url>=\n\n"
+	if got != want {
+		t.Errorf("org output:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestRenderToString_rst(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.Output = "rst"
+	opts.MaxDepth = 1
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	for _, want := range []string{
+		"git\n===\n\nthe version control system\n\nUsage: ``git``\n",
+		"git commit\n----------\n",
+		"- ``-m, --message <string>``\n",
+		"git remote\n----------\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rst output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderToString_notes(t *testing.T) {
+	notes := map[string]string{"git commit": "sign with -S on this repo"}
+	for format, want := range map[string]string{
+		"org":      "record changes to the repository\n\n#+begin_note\nsign with -S on this repo\n#+end_note\n",
+		"rst":      "record changes to the repository\n\n.. note:: sign with -S on this repo\n\n",
+		"template": "git commit: sign with -S on this repo\n",
+	} {
+		opts := render.DefaultOptions()
+		opts.Output = format
+		opts.Filter = "commit"
+		opts.Template = `{{range walk .}}{{path .}}: {{note .}}{{"\n"}}{{end}}`
+		opts.Notes = notes
+		got, err := render.ToString(sampleTree(), opts)
+		if err != nil {
+			t.Fatalf("%s: ToString error: %v", format, err)
+		}
+		if !strings.Contains(got, want) {
+			t.Errorf("%s output missing %q:\n%s", format, want, got)
+		}
+	}
+}
+
+func TestWriteManPage(t *testing.T) {
+	tree := sampleTree()
+	remote := tree.Children[1]
+	add := remote.Children[0]
+	add.Description = ".dot-leading text\nwith a back\\slash"
+	r := render.New(render.DefaultOptions())
+	var sb strings.Builder
+	h := render.ManHeader{Section: "1", Date: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Source: "git", Manual: "git manual"}
+	if err := r.WriteManPage(&sb, add, h); err != nil {
+		t.Fatalf("WriteManPage error: %v", err)
+	}
+	got := sb.String()
+	for _, want := range []string{
+		`.TH "GIT\-REMOTE\-ADD" "1" "Jan 2025" "git" "git manual"` + "\n",
+		".SH SYNOPSIS\n\\fBgit remote add\\fR \\fI<name>\\fR \\fI<url>\\fR\n",
+		".SH DESCRIPTION\n\\&.dot\\-leading text\nwith a back\\eslash\n",
+		".SH ARGUMENTS\n.TP\n\\fI<name>\\fR\n",
+		".SH SEE ALSO\n\\fBgit\\-remote\\fR(1)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("man page missing %q:\n%s", want, got)
+		}
+	}
+	if name := render.ManPageName(add, "1"); name != "git-remote-add.1" {
+		t.Errorf("ManPageName = %q", name)
+	}
+
+	sb.Reset()
+	if err := r.WriteManPage(&sb, tree.Children[0], h); err != nil {
+		t.Fatalf("WriteManPage error: %v", err)
+	}
+	if want := ".SH OPTIONS\n.TP\n\\fB\\-m\\fR, \\fB\\-\\-message\\fR \\fIstring\\fR\n"; !strings.Contains(sb.String(), want) {
+		t.Errorf("man page missing %q:\n%s", want, sb.String())
+	}
+}
+
+func TestRenderToString_jsonl(t *testing.T) {
+	opts := render.DefaultOptions()
+	opts.Output = "jsonl"
+	got, err := render.ToString(sampleTree(), opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected one line per node (5), got %d:\n%s", len(lines), got)
+	}
+	for _, line := range lines {
+		var n models.Node
+		if err := json.Unmarshal([]byte(line), &n); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+		if len(n.Children) != 0 {
+			t.Errorf("line for %v carries children", n.FullPath)
+		}
+	}
+	if !strings.HasPrefix(lines[3], `{"name":"add","full_path":["git","remote","add"]`) {
+		t.Errorf("unexpected fourth line %q", lines[3])
+	}
+}
+
+func TestRender_flagSections(t *testing.T) {
+	root := &models.Node{
+		Name:     "godot",
+		FullPath: []string{"godot"},
+		Flags: []models.Flag{
+			{Name: "--help", ValueType: "bool"},
+			{Name: "--verbose", ValueType: "bool"},
+			{Name: "--debug", ValueType: "bool"},
+		},
+		FlagSections: []models.FlagSection{
+			{Name: "General options", Flags: []string{"--help", "--verbose"}},
+			{Name: "Debug options", Flags: []string{"--debug"}},
+		},
+	}
+	opts := render.DefaultOptions()
+	opts.NoColor = true
+	got, err := render.ToString(root, opts)
+	if err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	want := "• godot\n├── ◆ General options [--help,--verbose]\n└── ◆ Debug options [--debug]\n"
+	if got != want {
+		t.Errorf("text output:\ngot  %q\nwant %q", got, want)
+	}
+
+	opts.Output = "org"
+	if got, err = render.ToString(root, opts); err != nil {
+		t.Fatalf("ToString error: %v", err)
+	}
+	if !strings.Contains(got, "\nGeneral options:\n- =--help=\n- =--verbose=\n\nDebug options:\n- =--debug=\n") {
+		t.Errorf("org output should list each flag section under its heading:\n%s", got)
+	}
+
+	opts.Output = "text"
+	opts.CommandsOnly = true
+	if got, _ = render.ToString(root, opts); strings.Contains(got, "options") {
+		t.Errorf("commands-only output should leave out flag sections:\n%s", got)
+	}
+}
@@ -0,0 +1,94 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// Report is a set of tree metrics, as printed by `treemand stats`.
+type Report struct {
+	CLI string `json:"cli"`
+	Stats
+	// DeepestPath is the first command (in tree order) at MaxDepth.
+	DeepestPath string `json:"deepest_path"`
+	// Widest is the command with the most subcommands.
+	Widest CommandCount `json:"widest"`
+	// TopFlags lists the commands with the most own flags, most first.
+	TopFlags []CommandCount `json:"top_flags"`
+}
+
+// CommandCount pairs a full command path with a count.
+type CommandCount struct {
+	Command string `json:"command"`
+	Count   int    `json:"count"`
+}
+
+// Analyze computes a Report for root, listing at most top commands in
+// TopFlags. Virtual nodes (flag groups) count towards their parent.
+func Analyze(root *models.Node, top int) Report {
+	rep := Report{CLI: root.Name, Stats: Collect(root), TopFlags: []CommandCount{}}
+	var byFlags []CommandCount
+	var walk func(n *models.Node, depth int)
+	walk = func(n *models.Node, depth int) {
+		flags := ownFlagCount(n)
+		children := 0
+		for _, c := range n.Children {
+			if c.Virtual {
+				flags += ownFlagCount(c)
+			} else {
+				children++
+			}
+		}
+		cmd := n.FullCommand()
+		if depth == rep.MaxDepth && rep.DeepestPath == "" {
+			rep.DeepestPath = cmd
+		}
+		if children > rep.Widest.Count {
+			rep.Widest = CommandCount{Command: cmd, Count: children}
+		}
+		if flags > 0 {
+			byFlags = append(byFlags, CommandCount{Command: cmd, Count: flags})
+		}
+		for _, c := range n.Children {
+			if !c.Virtual {
+				walk(c, depth+1)
+			}
+		}
+	}
+	walk(root, 0)
+	sort.SliceStable(byFlags, func(i, j int) bool { return byFlags[i].Count > byFlags[j].Count })
+	if len(byFlags) > top {
+		byFlags = byFlags[:top]
+	}
+	rep.TopFlags = append(rep.TopFlags, byFlags...)
+	return rep
+}
+
+// WriteText writes the report as aligned plain text.
+func (rep Report) WriteText(w io.Writer) {
+	fmt.Fprintln(w, rep.CLI)
+	rows := [][2]string{
+		{"commands", fmt.Sprint(rep.Commands)},
+		{"flags", fmt.Sprint(rep.Flags)},
+		{"positionals", fmt.Sprint(rep.Positionals)},
+		{"max depth", fmt.Sprint(rep.MaxDepth)},
+		{"deepest path", rep.DeepestPath},
+	}
+	if rep.Widest.Count > 0 {
+		rows = append(rows, [2]string{"widest", fmt.Sprintf("%s (%d subcommands)", rep.Widest.Command, rep.Widest.Count)})
+	}
+	for _, r := range rows {
+		fmt.Fprintf(w, "  %-13s %s\n", r[0], r[1])
+	}
+	if len(rep.TopFlags) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nTop %d commands by flag count:\n", len(rep.TopFlags))
+	width := len(fmt.Sprint(rep.TopFlags[0].Count))
+	for _, c := range rep.TopFlags {
+		fmt.Fprintf(w, "  %*d  %s\n", width, c.Count, c.Command)
+	}
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aallbrig/treemand/config"
+	"github.com/aallbrig/treemand/models"
+)
+
+// SortNodes returns nodes ordered according to mode. The input slice is not
+// modified; SortNone returns it unchanged.
+func SortNodes(nodes []*models.Node, mode config.SortMode) []*models.Node {
+	if mode == config.SortNone || len(nodes) < 2 {
+		return nodes
+	}
+	out := append([]*models.Node(nil), nodes...)
+	switch mode {
+	case config.SortName:
+		sort.SliceStable(out, func(i, j int) bool {
+			return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
+		})
+	case config.SortDiscovered:
+		sort.SliceStable(out, func(i, j int) bool {
+			return discoveryRank(out[i]) < discoveryRank(out[j])
+		})
+	case config.SortFlags:
+		sort.SliceStable(out, func(i, j int) bool {
+			return ownFlagCount(out[i]) > ownFlagCount(out[j])
+		})
+	}
+	return out
+}
+
+// FlagOrder returns the indices of flags in the order mode displays them.
+// Indices are returned (rather than a sorted copy) so callers can keep
+// pointers into the original slice.
+func FlagOrder(flags []models.Flag, mode config.SortMode) []int {
+	idx := make([]int, len(flags))
+	for i := range idx {
+		idx[i] = i
+	}
+	if mode == config.SortName || mode == config.SortFlags {
+		sort.SliceStable(idx, func(a, b int) bool {
+			return flagSortKey(flags[idx[a]]) < flagSortKey(flags[idx[b]])
+		})
+	}
+	return idx
+}
+
+// SortedFlags returns flags ordered according to mode as a new slice.
+func SortedFlags(flags []models.Flag, mode config.SortMode) []models.Flag {
+	if mode == config.SortNone {
+		return flags
+	}
+	out := make([]models.Flag, 0, len(flags))
+	for _, i := range FlagOrder(flags, mode) {
+		out = append(out, flags[i])
+	}
+	return out
+}
+
+func flagSortKey(f models.Flag) string {
+	return strings.ToLower(strings.TrimLeft(f.Name, "-"))
+}
+
+// discoveryRank orders fully discovered nodes before stubs, and stubs
+// before nodes whose discovery failed.
+func discoveryRank(n *models.Node) int {
+	switch {
+	case n.DiscoveryErr != "":
+		return 2
+	case n.Stub:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func ownFlagCount(n *models.Node) int {
+	count := 0
+	for _, f := range n.Flags {
+		if !f.Inherited {
+			count++
+		}
+	}
+	return count
+}
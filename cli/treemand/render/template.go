@@ -0,0 +1,59 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// renderTemplate executes Options.Template with the root node as dot. Besides
+// text/template's builtins, templates can call:
+//
+//	walk NODE   the commands under NODE (NODE included) in display order,
+//	            honoring --depth, --filter, --exclude and --sort
+//	flags NODE  NODE's own flags, including those of its flag groups
+//	path NODE   NODE's full command, e.g. "git remote add"
+//	depth NODE  NODE's depth below the root CLI (the root is 0)
+//	note NODE   the user's note on NODE in Options.Notes, "" if none
+func (r *Renderer) renderTemplate(w io.Writer, root *models.Node) error {
+	if r.opts.Template == "" {
+		return fmt.Errorf("output template is empty")
+	}
+	tmpl, err := template.New("output").Funcs(template.FuncMap{
+		"walk":  r.walk,
+		"flags": r.ownFlags,
+		"path":  (*models.Node).FullCommand,
+		"depth": func(n *models.Node) int { return max(len(n.FullPath)-1, 0) },
+		"note":  func(n *models.Node) string { return r.opts.Notes[n.FullCommand()] },
+	}).Parse(r.opts.Template)
+	if err != nil {
+		return fmt.Errorf("parse template: %w", err)
+	}
+	if err := tmpl.Execute(w, root); err != nil {
+		return fmt.Errorf("execute template: %w", err)
+	}
+	return nil
+}
+
+// walk returns the commands under node in display order, honoring MaxDepth,
+// Filter, Exclude and Sort. Virtual flag groups are skipped; their flags
+// belong to the parent command.
+func (r *Renderer) walk(node *models.Node) []*models.Node {
+	var nodes []*models.Node
+	r.walkNode(node, 0, &nodes)
+	return nodes
+}
+
+func (r *Renderer) walkNode(node *models.Node, depth int, nodes *[]*models.Node) {
+	if (r.opts.MaxDepth >= 0 && depth > r.opts.MaxDepth) || r.exclude.Match(node) || node.Virtual {
+		return
+	}
+	if r.filter.Empty() || r.filter.Match(node) {
+		*nodes = append(*nodes, node)
+	}
+	for _, child := range SortNodes(node.Children, r.opts.Sort) {
+		r.walkNode(child, depth+1, nodes)
+	}
+}
@@ -0,0 +1,288 @@
+// Package spec reads hand-written descriptions of a CLI, for tools whose
+// help treemand cannot parse or that have none. A spec is YAML or TOML and
+// maps onto models.Node:
+//
+//	name: deployctl
+//	description: Deploy services to the fleet
+//	flags:
+//	  - {name: --verbose, short: v, description: Log every step}
+//	commands:
+//	  - name: deploy
+//	    description: Deploy one service
+//	    args:
+//	      - {name: service, required: true}
+//	    flags:
+//	      - {name: --env, type: string, default: staging}
+//
+// Unknown keys are errors, so a misspelt one is not silently dropped.
+package spec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// Command describes a command: the CLI itself, or one of its subcommands.
+type Command struct {
+	Name        string `yaml:"name" toml:"name"`
+	Description string `yaml:"description" toml:"description"`
+	// Deprecated marks a command that is still accepted but should not be
+	// used; ReplacedBy names what to use instead.
+	Deprecated bool      `yaml:"deprecated" toml:"deprecated"`
+	ReplacedBy string    `yaml:"replaced_by" toml:"replaced_by"`
+	Hidden     bool      `yaml:"hidden" toml:"hidden"`
+	Flags      []Flag    `yaml:"flags" toml:"flags"`
+	Args       []Arg     `yaml:"args" toml:"args"`
+	Commands   []Command `yaml:"commands" toml:"commands"`
+}
+
+// Flag describes a flag of a command.
+type Flag struct {
+	// Name is the flag as typed: "--output", or "-o" for a flag with no
+	// long form.
+	Name string `yaml:"name" toml:"name"`
+	// Short is the one-letter form, with or without its dash: "o".
+	Short string `yaml:"short" toml:"short"`
+	// Type is the type of the flag's value: "string", "int", "duration",
+	// or any other word; empty or "bool" for a flag that takes none.
+	Type        string `yaml:"type" toml:"type"`
+	Description string `yaml:"description" toml:"description"`
+	Default     string `yaml:"default" toml:"default"`
+	Required    bool   `yaml:"required" toml:"required"`
+	Repeatable  bool   `yaml:"repeatable" toml:"repeatable"`
+	// Negatable marks a boolean flag that also has a "--no-" form.
+	Negatable  bool   `yaml:"negatable" toml:"negatable"`
+	Deprecated bool   `yaml:"deprecated" toml:"deprecated"`
+	ReplacedBy string `yaml:"replaced_by" toml:"replaced_by"`
+}
+
+// Arg describes a positional argument of a command.
+type Arg struct {
+	Name        string `yaml:"name" toml:"name"`
+	Description string `yaml:"description" toml:"description"`
+	Required    bool   `yaml:"required" toml:"required"`
+	// Variadic marks the last argument when it may be given any number of
+	// times.
+	Variadic bool `yaml:"variadic" toml:"variadic"`
+}
+
+// Load reads the spec in the file at path: TOML for a .toml file, YAML
+// (or JSON, which YAML reads too) otherwise.
+func Load(path string) (*Command, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return ParseTOML(data)
+	}
+	return ParseYAML(data)
+}
+
+// IsSpecFile reports whether path is named like a spec: a .yaml, .yml or
+// .toml file.
+func IsSpecFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// ParseYAML reads a spec written in YAML.
+func ParseYAML(data []byte) (*Command, error) {
+	var c Command
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ParseTOML reads a spec written in TOML, subcommands as [[commands]]
+// tables.
+func ParseTOML(data []byte) (*Command, error) {
+	var c Command
+	err := toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(&c)
+	var missing *toml.StrictMissingError
+	var decode *toml.DecodeError
+	switch {
+	case errors.As(err, &missing):
+		// Error only says that some keys are unknown; String says which.
+		return nil, errors.New(strings.TrimSpace(missing.String()))
+	case errors.As(err, &decode):
+		row, _ := decode.Position()
+		return nil, fmt.Errorf("line %d: %w", row, err)
+	case err != nil:
+		return nil, err
+	}
+	return &c, nil
+}
+
+var (
+	// commandNameRe matches the names commands can be typed as.
+	commandNameRe = regexp.MustCompile(`^[A-Za-z0-9_.:][A-Za-z0-9_.:+-]*$`)
+	// flagNameRe matches "--output", "-o" and single-dash long flags such
+	// as find's "-name".
+	flagNameRe = regexp.MustCompile(`^--?[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+)
+
+// Validate reports what is wrong with the spec: missing or malformed
+// names, commands, flags or arguments listed twice, and arguments in an
+// order no command line can give them. Each problem names the command it
+// is in.
+func (c *Command) Validate() []error {
+	var errs []error
+	c.validate(nil, &errs)
+	return errs
+}
+
+func (c *Command) validate(parent []string, errs *[]error) {
+	path := append(append([]string{}, parent...), c.Name)
+	where := strings.Join(path, " ")
+	report := func(format string, args ...any) {
+		*errs = append(*errs, fmt.Errorf("%s: %s", where, fmt.Sprintf(format, args...)))
+	}
+	switch {
+	case c.Name == "" && len(parent) == 0:
+		where = "spec"
+		report("the CLI has no name")
+	case c.Name == "":
+		where = strings.Join(parent, " ")
+		report("a command has no name")
+	case !commandNameRe.MatchString(c.Name):
+		report("%q is not a command name", c.Name)
+	}
+	if c.ReplacedBy != "" && !c.Deprecated {
+		report("replaced_by is set but the command is not deprecated")
+	}
+
+	names := map[string]bool{}
+	for _, sub := range c.Commands {
+		if sub.Name != "" && names[sub.Name] {
+			report("command %q is listed twice", sub.Name)
+		}
+		names[sub.Name] = true
+	}
+
+	flags, shorts := map[string]bool{}, map[string]bool{}
+	for _, f := range c.Flags {
+		switch {
+		case f.Name == "":
+			report("a flag has no name")
+		case !flagNameRe.MatchString(f.Name):
+			report("flag %q must be a dash or two and a name: --output, -o", f.Name)
+		case flags[f.Name]:
+			report("flag %s is listed twice", f.Name)
+		}
+		flags[f.Name] = true
+		if s := strings.TrimPrefix(f.Short, "-"); s != "" {
+			switch {
+			case len(s) != 1:
+				report("flag %s: short form %q is not one letter", f.Name, f.Short)
+			case shorts[s]:
+				report("short flag -%s is listed twice", s)
+			}
+			shorts[s] = true
+		}
+		if f.Negatable && flagTakesValue(f) {
+			report("flag %s is negatable but takes a %s value", f.Name, f.Type)
+		}
+		if f.ReplacedBy != "" && !f.Deprecated {
+			report("flag %s: replaced_by is set but the flag is not deprecated", f.Name)
+		}
+	}
+
+	args := map[string]bool{}
+	optional := ""
+	for i, a := range c.Args {
+		switch {
+		case a.Name == "":
+			report("argument %d has no name", i+1)
+		case args[a.Name]:
+			report("argument %q is listed twice", a.Name)
+		}
+		args[a.Name] = true
+		if a.Variadic && i < len(c.Args)-1 {
+			report("argument %q is variadic but not the last", a.Name)
+		}
+		if a.Required && optional != "" {
+			report("required argument %q follows optional %q", a.Name, optional)
+		}
+		if !a.Required && optional == "" {
+			optional = a.Name
+		}
+	}
+
+	for i := range c.Commands {
+		c.Commands[i].validate(path, errs)
+	}
+}
+
+// flagTakesValue reports whether f is given a value, as models.Flag does.
+func flagTakesValue(f Flag) bool {
+	return models.Flag{ValueType: f.Type}.TakesValue()
+}
+
+// Node converts the spec to the tree treemand shows. Every command is
+// discovered, with "spec" as its source.
+func (c *Command) Node() *models.Node {
+	root := c.node(nil)
+	models.MarkInheritedFlags(root)
+	return root
+}
+
+func (c *Command) node(parent []string) *models.Node {
+	n := &models.Node{
+		Name:        c.Name,
+		FullPath:    append(append([]string{}, parent...), c.Name),
+		Description: c.Description,
+		Discovered:  true,
+		Sources:     []string{"spec"},
+		Deprecated:  c.Deprecated,
+		ReplacedBy:  c.ReplacedBy,
+		Hidden:      c.Hidden,
+	}
+	for _, f := range c.Flags {
+		valueType := f.Type
+		if strings.EqualFold(valueType, "bool") {
+			valueType = ""
+		}
+		n.Flags = append(n.Flags, models.Flag{
+			Name:        f.Name,
+			ShortName:   strings.TrimPrefix(f.Short, "-"),
+			ValueType:   valueType,
+			Description: f.Description,
+			Default:     f.Default,
+			Required:    f.Required,
+			Repeatable:  f.Repeatable,
+			Negatable:   f.Negatable,
+			Deprecated:  f.Deprecated,
+			ReplacedBy:  f.ReplacedBy,
+			Sources:     []string{"spec"},
+		})
+	}
+	for _, a := range c.Args {
+		n.Positionals = append(n.Positionals, models.Positional{
+			Name:        a.Name,
+			Description: a.Description,
+			Required:    a.Required,
+			Variadic:    a.Variadic,
+		})
+	}
+	for i := range c.Commands {
+		n.Children = append(n.Children, c.Commands[i].node(n.FullPath))
+	}
+	return n
+}
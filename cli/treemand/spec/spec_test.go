@@ -0,0 +1,153 @@
+package spec_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aallbrig/treemand/spec"
+)
+
+const yamlSpec = `name: deployctl
+description: Deploy services to the fleet
+flags:
+  - {name: --verbose, short: v, description: Log every step}
+commands:
+  - name: deploy
+    description: Deploy one service
+    args:
+      - {name: service, required: true}
+      - {name: hosts, variadic: true}
+    flags:
+      - {name: --env, short: -e, type: string, default: staging}
+      - {name: --color, negatable: true}
+  - name: rollback
+    deprecated: true
+    replaced_by: deployctl deploy
+`
+
+const tomlSpec = `name = "deployctl"
+description = "Deploy services to the fleet"
+
+[[flags]]
+name = "--verbose"
+short = "v"
+
+[[commands]]
+name = "deploy"
+description = "Deploy one service"
+
+  [[commands.args]]
+  name = "service"
+  required = true
+
+  [[commands.flags]]
+  name = "--env"
+  type = "string"
+`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	for file, data := range map[string]string{"d.yaml": yamlSpec, "d.toml": tomlSpec} {
+		path := filepath.Join(dir, file)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := spec.Load(path)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if errs := c.Validate(); len(errs) > 0 {
+			t.Errorf("%s: unexpected errors %v", file, errs)
+		}
+		root := c.Node()
+		deploy := root.FindFullPath([]string{"deployctl", "deploy"})
+		if deploy == nil {
+			t.Fatalf("%s: no deployctl deploy in %+v", file, root)
+		}
+		if !deploy.Discovered || len(deploy.Positionals) == 0 || !deploy.Positionals[0].Required {
+			t.Errorf("%s: deploy = %+v, want it discovered with a required service", file, deploy)
+		}
+		if len(deploy.Flags) == 0 || deploy.Flags[0].Name != "--env" || !deploy.Flags[0].TakesValue() {
+			t.Errorf("%s: deploy flags = %+v, want --env taking a value", file, deploy.Flags)
+		}
+	}
+}
+
+func TestNode(t *testing.T) {
+	c, err := spec.ParseYAML([]byte(yamlSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := c.Node()
+	if root.Description != "Deploy services to the fleet" || root.Sources[0] != "spec" {
+		t.Errorf("root = %+v", root)
+	}
+	deploy := root.Children[0]
+	env := deploy.Flags[0]
+	if env.ShortName != "e" || env.Default != "staging" {
+		t.Errorf("--env = %+v, want short name e and default staging", env)
+	}
+	if color := deploy.Flags[1]; !color.Negatable || color.TakesValue() {
+		t.Errorf("--color = %+v, want a negatable bool", color)
+	}
+	if !deploy.Positionals[1].Variadic {
+		t.Errorf("hosts = %+v, want variadic", deploy.Positionals[1])
+	}
+	if rb := root.Children[1]; !rb.Deprecated || rb.ReplacedBy != "deployctl deploy" || rb.FullCommand() != "deployctl rollback" {
+		t.Errorf("rollback = %+v", rb)
+	}
+}
+
+func TestParseUnknownKey(t *testing.T) {
+	if _, err := spec.ParseYAML([]byte("name: x\ndescripton: typo\n")); err == nil {
+		t.Error("YAML: unknown key accepted")
+	}
+	_, err := spec.ParseTOML([]byte("name = \"x\"\ndescripton = \"typo\"\n"))
+	if err == nil || !strings.Contains(err.Error(), "descripton") {
+		t.Errorf("TOML: err = %v, want the unknown key named", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c, err := spec.ParseYAML([]byte(`name: tool
+flags:
+  - {name: output}
+  - {name: --verbose, short: v}
+  - {name: --version, short: v}
+  - {name: --size, type: int, negatable: true}
+args:
+  - {name: files, variadic: true}
+  - {name: dest, required: true}
+commands:
+  - name: sub
+  - name: sub
+  - description: no name
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, err := range c.Validate() {
+		msgs = append(msgs, err.Error())
+	}
+	got := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		`tool: flag "output" must be`,
+		"tool: short flag -v is listed twice",
+		"tool: flag --size is negatable",
+		`tool: argument "files" is variadic but not the last`,
+		`tool: required argument "dest" follows optional "files"`,
+		`tool: command "sub" is listed twice`,
+		"tool: a command has no name",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("errors missing %q:\n%s", want, got)
+		}
+	}
+
+	if errs := (&spec.Command{}).Validate(); len(errs) != 1 || !strings.Contains(errs[0].Error(), "no name") {
+		t.Errorf("empty spec: %v", errs)
+	}
+}
@@ -0,0 +1,390 @@
+// Package treemand is the Go API for embedding treemand in other programs.
+//
+// It runs discovery (optionally backed by the on-disk cache) and returns a
+// *models.Node tree that can be rendered with package render or explored
+// with the Bubble Tea model in package tui, without going through the cobra
+// command layer:
+//
+//	opts := treemand.DefaultOptions()
+//	opts.Depth = 2
+//	root, err := treemand.Discover(ctx, "git", opts)
+//	if err != nil { ... }
+//	out, _ := render.ToString(root, render.DefaultOptions())
+//
+// The exported identifiers of this package, models.Node/Flag/Positional,
+// render.Options/ToString and tui.Model follow semantic versioning: they are
+// only changed incompatibly in a new major version. Other packages may
+// change between minor releases.
+package treemand
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aallbrig/treemand/cache"
+	"github.com/aallbrig/treemand/discovery"
+	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/override"
+)
+
+// DefaultCacheMaxAge is how long cached trees and help text stay fresh when
+// Options.CacheMaxAge is zero.
+const DefaultCacheMaxAge = 24 * time.Hour
+
+// Options controls a discovery run. Start from DefaultOptions.
+type Options struct {
+	// Strategies lists discovery strategies to run and merge: "help",
+	// "man", "completions", "hidden". Empty means help only.
+	Strategies []string
+	// Path, when set, names the subcommand to start at: "remote" for
+	// git remote. Only its subtree is discovered, and the tree returned is
+	// rooted at it, with Depth counted from there. Subtrees are not cached
+	// as trees, as the cache keeps whole CLIs, but the help text fetched is;
+	// offline, the subtree is taken from the cached tree of the CLI.
+	Path []string
+	// Depth is the maximum subcommand depth to probe; -1 means unlimited.
+	// Commands below it are returned as Stub nodes.
+	Depth int
+	// Shallow, when above 0 and below Depth, discovers a tree that is not
+	// cached only this many levels deep, leaving the commands below as
+	// stubs for the caller to discover, as the TUI does in the background.
+	// The shallow tree is not cached; the help text fetched is, so a Load
+	// without Shallow once the caller has discovered the rest reads it all
+	// from there and caches the whole tree.
+	Shallow int
+	// StubThreshold is the number of subcommands above which children are
+	// created as stubs instead of being probed. 0 means the default (150).
+	StubThreshold int
+	// CommandTimeout bounds fetching the help of one command; 0 means
+	// the default (5s). The context passed to Load bounds the whole run.
+	CommandTimeout time.Duration
+	// ParserProfile names the help parser profile (see
+	// discovery.Profiles); "" or "auto" detects it from each help text.
+	ParserProfile string
+	// MergePolicy settles values the strategies disagree on (see
+	// discovery.MergePolicies); "" means discovery.MergeFirst.
+	MergePolicy string
+	// Via, when set, is a command prefix every command discovery runs goes
+	// through, so a CLI installed in a container or WSL distribution can be
+	// discovered: see discovery.ParseVia and discovery.WithVia. The CLI is
+	// not looked for on this system, and trees are cached apart from those
+	// of a local CLI of the same name.
+	Via []string
+	// PackageMetadata reads the package.json or pipx metadata of a CLI
+	// installed by npm or pipx (see discovery.DetectPackage), and gives
+	// the root the package's description when its help has none.
+	PackageMetadata bool
+	// OverrideDir, when set, is the directory of override files: the
+	// override of the CLI there, if any (see package override), is applied
+	// to the tree returned, from discovery or the cache. Trees are cached
+	// as discovered, so an edited override applies at once.
+	OverrideDir string
+	// PruneDuplicates drops the subcommands whose help is identical to
+	// their parent's or a sibling's from the tree returned, instead of
+	// keeping them as aliases (see discovery.Dedupe). Trees are cached with
+	// the aliases, so the cache serves runs with and without it.
+	PruneDuplicates bool
+	// Retry controls retries of help invocations that fail or time out.
+	// The zero value tries every command once.
+	Retry discovery.RetryPolicy
+	// Cache, when non-nil, is consulted before discovery and updated after.
+	Cache *cache.Cache
+	// CacheMaxAge bounds the age of cached entries. 0 means
+	// DefaultCacheMaxAge.
+	CacheMaxAge time.Duration
+	// Incremental ignores the cached tree and re-probes the CLI, reusing
+	// what the most recent cached tree read from each help output that is
+	// unchanged. Requires Cache.
+	Incremental bool
+	// Offline serves the most recent cached tree of the CLI, whatever its
+	// age, and never runs the CLI: no discovery, not even a version probe.
+	// It requires Cache; Load fails with ErrNotCached when nothing is
+	// cached for the CLI.
+	Offline bool
+	// OnDiscover, when set, is called as live discovery starts (not for
+	// cache hits); the returned function is called when it finishes. Use
+	// it to show progress.
+	OnDiscover func(cli string) (done func())
+	// OnNode, when set, is called with each node the help strategy
+	// discovers, as soon as its help is parsed (see
+	// discovery.HelpDiscoverer.OnNode). It is not called for cache hits.
+	OnNode func(*models.Node)
+	// OnPartial, when set, is called with the tree merged so far each time
+	// a strategy's results are merged in (see discovery.RunStream). It is
+	// not called for cache hits.
+	OnPartial func(*models.Node)
+	// Trace, when set, receives a line of JSON for every command discovery
+	// runs (see discovery.ExecRecord), for bug reports.
+	Trace io.Writer
+}
+
+// DefaultOptions returns the options treemand itself uses: the help
+// strategy, depth 3 and no cache.
+func DefaultOptions() Options {
+	return Options{Strategies: []string{"help"}, Depth: 3, PackageMetadata: true}
+}
+
+// Result is the outcome of Load.
+type Result struct {
+	// Root is the discovered command tree.
+	Root *models.Node
+	// Cached reports whether Root came from the cache unchanged.
+	Cached bool
+	// Binary is the executable Root was discovered from, when known: it
+	// is resolved only with a cache; in offline mode it is the one
+	// recorded with the cached tree.
+	Binary cache.Binary
+	// Version is the CLI's version line Root was discovered from (see
+	// cache.CLIVersion), with the package or Via it came through; in
+	// offline mode, the one recorded with the cached tree. It is empty
+	// without a cache.
+	Version string
+	// Stale reports, in offline mode, that the executable the CLI
+	// resolves to on PATH is not the one Root was discovered from: it was
+	// upgraded or replaced since. Missing reports that it is no longer on
+	// PATH. Neither is checked when no executable was recorded.
+	Stale, Missing bool
+	// Shallow reports that Root was discovered only Options.Shallow levels
+	// deep, and so was not cached.
+	Shallow bool
+	// Package is the npm or pipx package the CLI was installed from, when
+	// it is one; see discovery.DetectPackage. It is not detected offline or
+	// with Via.
+	Package discovery.Package
+	// HelpStore is the help-text cache for the CLI, or nil without a
+	// cache. Pass it to tui.Model.SetHelpStore so stubs expanded in the TUI
+	// reuse help output that was already fetched.
+	HelpStore discovery.HelpStore
+}
+
+// ErrNotCached is returned by Load in offline mode when the cache holds no
+// tree for the CLI.
+var ErrNotCached = errors.New("no cached tree")
+
+// Discover returns the command tree of cli.
+func Discover(ctx context.Context, cli string, opts Options) (*models.Node, error) {
+	res, err := Load(ctx, cli, opts)
+	if err != nil {
+		return nil, err
+	}
+	return res.Root, nil
+}
+
+// Load is like Discover but also reports where the tree came from and
+// returns the help-text cache for later lazy expansion.
+func Load(ctx context.Context, cli string, opts Options) (*Result, error) {
+	var o *override.Command
+	if opts.OverrideDir != "" {
+		var err error
+		if o, err = override.Load(opts.OverrideDir, cli); err != nil {
+			return nil, fmt.Errorf("override %s: %w", override.Path(opts.OverrideDir, cli), err)
+		}
+	}
+	res, err := load(ctx, cli, opts)
+	if err == nil && opts.PruneDuplicates {
+		discovery.Dedupe(res.Root, true)
+	}
+	if err != nil || o == nil {
+		return res, err
+	}
+	for _, stale := range override.Apply(res.Root, o) {
+		log.Warn().Str("command", stale).Msg("override removes a command or flag the tree does not have")
+	}
+	return res, nil
+}
+
+func load(ctx context.Context, cli string, opts Options) (*Result, error) {
+	if opts.Offline {
+		return loadOffline(opts.Cache, cli, opts.Path)
+	}
+	if len(opts.Via) > 0 {
+		ctx = discovery.WithVia(ctx, opts.Via)
+	} else if err := discovery.CheckAvailable(cli); err != nil {
+		return nil, err
+	}
+	strategies := opts.Strategies
+	if len(strategies) == 0 {
+		strategies = []string{"help"}
+	}
+	profile, err := discovery.LookupProfile(opts.ParserProfile)
+	if err != nil {
+		return nil, err
+	}
+	policy := opts.MergePolicy
+	if policy == "" {
+		policy = discovery.MergeFirst
+	}
+	if err := discovery.CheckMergePolicy(policy); err != nil {
+		return nil, err
+	}
+	maxAge := opts.CacheMaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultCacheMaxAge
+	}
+
+	// A package manager's shim stays the same across upgrades of the
+	// package, so the package's version tells them apart.
+	var pkg discovery.Package
+	if len(opts.Via) == 0 {
+		if path, err := discovery.ResolveBinary(cli); err == nil {
+			pkg, _ = discovery.DetectPackage(path)
+		}
+	}
+
+	var (
+		c        = opts.Cache
+		cacheKey string
+		cliVer   string
+		bin      cache.Binary
+		previous *models.Node
+		store    discovery.HelpStore
+	)
+	if c != nil {
+		cliVer = cache.CLIVersion(ctx, cli)
+		var err error
+		if len(opts.Via) > 0 {
+			// The binary is not on this system; its help is kept apart
+			// from that of a local CLI of the same name.
+			cliVer += " via " + strings.Join(opts.Via, " ")
+		} else {
+			if pkg.Name != "" {
+				cliVer += " (" + pkg.String() + ")"
+			}
+			if bin, err = c.ResolveBinary(cli); err != nil {
+				log.Warn().Err(err).Str("cli", cli).Msg("could not identify binary, caching by name only")
+			}
+		}
+		store = c.HelpStore(cli, cliVer, maxAge)
+		// Depth is part of the key so re-running with a deeper limit
+		// fills in former stubs (from the help-text cache where possible)
+		// rather than returning the shallower tree.
+		keyParts := append(append([]string{}, strategies...), fmt.Sprintf("depth=%d", opts.Depth))
+		if profile != nil {
+			keyParts = append(keyParts, "profile="+profile.Name)
+		}
+		if policy != discovery.MergeFirst {
+			keyParts = append(keyParts, "merge="+policy)
+		}
+		cacheKey = cache.Key(cli, bin, cliVer, keyParts)
+		if opts.Incremental {
+			if previous, err = c.Latest(cli); err != nil {
+				log.Warn().Err(err).Msg("could not load previous tree, running full discovery")
+			}
+		} else if len(opts.Path) == 0 {
+			if node, err := c.Get(cacheKey, maxAge); err == nil && node != nil {
+				log.Debug().Str("cli", cli).Msg("cache hit")
+				return &Result{Root: node, Cached: true, Binary: bin, Version: cliVer, Package: pkg, HelpStore: store}, nil
+			}
+		}
+	}
+
+	maxDepth := opts.Depth
+	if maxDepth < 0 {
+		maxDepth = 99 // -1 means unlimited; cap at 99 to prevent infinite loops
+	}
+	shallow := opts.Shallow > 0 && opts.Shallow < maxDepth
+	if shallow {
+		maxDepth = opts.Shallow
+	}
+	discoverers := discovery.BuildDiscoverersWithThreshold(strategies, maxDepth, opts.StubThreshold)
+	for _, d := range discoverers {
+		if hd, ok := d.(*discovery.HelpDiscoverer); ok {
+			hd.Previous = previous
+			hd.Retry = opts.Retry
+			if opts.CommandTimeout > 0 {
+				hd.Timeout = opts.CommandTimeout
+			}
+			hd.OnNode = opts.OnNode
+			hd.Profile = profile
+			if store != nil {
+				// Incremental refreshes must re-probe every path to detect
+				// changes; they still update the stored help text.
+				hd.Store = store
+				hd.Fresh = opts.Incremental
+			}
+		}
+	}
+	if opts.Trace != nil {
+		ctx = discovery.WithTrace(ctx, opts.Trace)
+	}
+	var done func()
+	if opts.OnDiscover != nil {
+		done = opts.OnDiscover(cli)
+	}
+	node, err := discovery.RunStream(ctx, discoverers, cli, opts.Path, policy, opts.OnPartial)
+	if done != nil {
+		done()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	if node == nil {
+		return nil, fmt.Errorf("no results from discovery for %q", cli)
+	}
+	if len(opts.Path) > 0 && node.DiscoveryErr != "" && len(node.Children) == 0 {
+		return nil, fmt.Errorf("%s: %s", node.FullCommand(), node.DiscoveryErr)
+	}
+	if opts.PackageMetadata && len(opts.Path) == 0 && node.Description == "" {
+		node.Description = pkg.Description
+	}
+	node.Diagnostics = discovery.Diagnose(node)
+	if node.Incomplete {
+		// The help fetched is stored already, so a rerun resumes quickly;
+		// caching the tree would serve it cut short until it expires.
+		log.Warn().Str("cli", cli).Msg("discovery stopped early; the tree is incomplete and is not cached")
+		return &Result{Root: node, Binary: bin, Version: cliVer, Shallow: shallow, Package: pkg, HelpStore: store}, nil
+	}
+
+	if c != nil && len(opts.Path) == 0 && !shallow {
+		if putErr := c.Put(cacheKey, cli, bin, cliVer, strings.Join(strategies, ","), node); putErr != nil {
+			log.Warn().Err(putErr).Msg("cache write failed")
+		}
+	}
+	return &Result{Root: node, Binary: bin, Version: cliVer, Shallow: shallow, Package: pkg, HelpStore: store}, nil
+}
+
+// loadOffline returns the most recent cached tree of cli, or its subtree at
+// path, without running it.
+func loadOffline(c *cache.Cache, cli string, path []string) (*Result, error) {
+	if c == nil {
+		return nil, errors.New("offline mode needs the cache")
+	}
+	node, err := c.Latest(cli)
+	if err != nil {
+		return nil, fmt.Errorf("read cache: %w", err)
+	}
+	if node == nil {
+		return nil, fmt.Errorf("%w for %q", ErrNotCached, cli)
+	}
+	if len(path) > 0 {
+		full := append([]string{cli}, path...)
+		if node = node.FindFullPath(full); node == nil {
+			return nil, fmt.Errorf("%w for %q", ErrNotCached, strings.Join(full, " "))
+		}
+	}
+	log.Debug().Str("cli", cli).Msg("offline: serving cached tree")
+	res := &Result{Root: node, Cached: true}
+	entry, ok, err := c.LatestEntry(cli)
+	if err != nil || !ok {
+		return res, nil
+	}
+	res.Binary, res.Version = entry.Binary, entry.Version
+	// Hashing the executable tells an upgrade without running it.
+	if entry.Binary.Hash != "" {
+		bin, err := c.ResolveBinary(cli)
+		switch {
+		case errors.Is(err, exec.ErrNotFound):
+			res.Missing = true
+		case err == nil && bin.Hash != entry.Binary.Hash:
+			res.Stale = true
+		}
+	}
+	return res, nil
+}
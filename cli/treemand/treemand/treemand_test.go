@@ -0,0 +1,340 @@
+package treemand_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aallbrig/treemand/cache"
+	"github.com/aallbrig/treemand/treemand"
+)
+
+const fakeCLIScript = `#!/bin/sh
+case "$1" in
+  sub) printf 'Usage: fakecli sub [flags]\n\nFlags:\n  --thing   do the thing\n' ;;
+  *)   printf 'fakecli does things\n\nCommands:\n  sub   the sub command\n' ;;
+esac
+`
+
+func fakeCLI(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fakecli"), []byte(fakeCLIScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestDiscover(t *testing.T) {
+	fakeCLI(t)
+	root, err := treemand.Discover(context.Background(), "fakecli", treemand.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Name != "fakecli" || root.Find("sub") == nil {
+		t.Errorf("Discover() = %+v, want fakecli with sub", root)
+	}
+}
+
+func TestDiscover_missingCLI(t *testing.T) {
+	if _, err := treemand.Discover(context.Background(), "no-such-cli-xyz", treemand.DefaultOptions()); err == nil {
+		t.Error("expected error for a CLI that is not installed")
+	}
+}
+
+func TestLoad_usesCache(t *testing.T) {
+	fakeCLI(t)
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	opts := treemand.DefaultOptions()
+	opts.Cache = c
+	discovering := 0
+	opts.OnDiscover = func(string) func() { discovering++; return func() {} }
+
+	first, err := treemand.Load(context.Background(), "fakecli", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Cached || first.HelpStore == nil {
+		t.Errorf("first Load: Cached=%v HelpStore=%v", first.Cached, first.HelpStore)
+	}
+	second, err := treemand.Load(context.Background(), "fakecli", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.Cached {
+		t.Error("second Load should be served from the cache")
+	}
+	if discovering != 1 {
+		t.Errorf("OnDiscover called %d times, want 1", discovering)
+	}
+}
+
+func TestLoad_pruneDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$1" in
+  ls|list) printf 'Usage: dupecli ls\n\nlists things\n' ;;
+  *)       printf 'dupecli does things\n\nCommands:\n  ls     list things\n  list   list things\n' ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "dupecli"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	opts := treemand.DefaultOptions()
+	opts.Cache = c
+	opts.PruneDuplicates = true
+	pruned, err := treemand.Load(context.Background(), "dupecli", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned.Root.Find("list") != nil || pruned.Root.Find("ls") == nil {
+		t.Errorf("PruneDuplicates should drop list and keep ls, got %v", pruned.Root.Children)
+	}
+	// The tree is cached with the alias, for runs without PruneDuplicates.
+	opts.PruneDuplicates = false
+	kept, err := treemand.Load(context.Background(), "dupecli", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !kept.Cached {
+		t.Error("second Load should be served from the cache")
+	}
+	if l := kept.Root.Find("list"); l == nil || l.AliasOf != "ls" {
+		t.Errorf("expected list kept as an alias of ls, got %+v", l)
+	}
+}
+
+func TestLoad_shallow(t *testing.T) {
+	fakeCLI(t)
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	opts := treemand.DefaultOptions()
+	opts.Cache = c
+	opts.Shallow = 1
+
+	res, err := treemand.Load(context.Background(), "fakecli", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Cached || !res.Shallow || res.Root.Find("sub") == nil {
+		t.Errorf("shallow Load: Cached=%v Shallow=%v, root %+v", res.Cached, res.Shallow, res.Root)
+	}
+	if latest, _ := c.Latest("fakecli"); latest != nil {
+		t.Error("a shallow tree was cached")
+	}
+
+	// Once the rest is discovered, as the TUI does in the background, a
+	// Load without Shallow caches the whole tree from the help text
+	// fetched, and the next launch is served from the cache.
+	opts.Shallow = 0
+	if res, err = treemand.Load(context.Background(), "fakecli", opts); err != nil || res.Shallow {
+		t.Fatalf("whole Load: Shallow=%v err=%v", res != nil && res.Shallow, err)
+	}
+	opts.Shallow = 1
+	if res, err = treemand.Load(context.Background(), "fakecli", opts); err != nil || !res.Cached || res.Shallow {
+		t.Errorf("second shallow Load: Cached=%v Shallow=%v err=%v", res != nil && res.Cached, res != nil && res.Shallow, err)
+	}
+}
+
+func TestLoad_npmPackage(t *testing.T) {
+	dir := t.TempDir()
+	pkgDir := filepath.Join(dir, "lib", "node_modules", "fakepkg")
+	if err := os.MkdirAll(filepath.Join(pkgDir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Help without a line of prose leaves the root undescribed.
+	script := "#!/bin/sh\nprintf '  -v, --verbose   be loud\\n'\n"
+	if err := os.WriteFile(filepath.Join(pkgDir, "bin", "cli"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	setVersion := func(v string) {
+		meta := `{"name": "fakepkg", "version": "` + v + `", "description": "Does fake things", "bin": {"fakecli": "bin/cli"}}`
+		if err := os.WriteFile(filepath.Join(pkgDir, "package.json"), []byte(meta), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setVersion("1.0.0")
+	binDir := filepath.Join(dir, "bin")
+	if err := os.Mkdir(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(pkgDir, "bin", "cli"), filepath.Join(binDir, "fakecli")); err != nil {
+		t.Skip("symlinks unavailable:", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	opts := treemand.DefaultOptions()
+	opts.Cache = c
+
+	res, err := treemand.Load(context.Background(), "fakecli", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Package.String(); got != "npm fakepkg@1.0.0" {
+		t.Errorf("Package = %q, want npm fakepkg@1.0.0", got)
+	}
+	if res.Root.Description != "Does fake things" {
+		t.Errorf("root description = %q, want the package's", res.Root.Description)
+	}
+
+	// Upgrading the package leaves the link unchanged but must not serve
+	// the old version's tree.
+	setVersion("1.1.0")
+	if res, err = treemand.Load(context.Background(), "fakecli", opts); err != nil {
+		t.Fatal(err)
+	}
+	if res.Cached {
+		t.Error("tree of fakepkg 1.0.0 served for 1.1.0")
+	}
+}
+
+func TestLoad_path(t *testing.T) {
+	fakeCLI(t)
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	opts := treemand.DefaultOptions()
+	opts.Cache = c
+	opts.Path = []string{"sub"}
+
+	for range 2 {
+		res, err := treemand.Load(context.Background(), "fakecli", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Root.FullCommand() != "fakecli sub" || len(res.Root.Flags) == 0 {
+			t.Errorf("root = %s with flags %v, want fakecli sub with --thing", res.Root.FullCommand(), res.Root.Flags)
+		}
+		if res.Cached {
+			t.Error("a subtree was served as a cached tree")
+		}
+	}
+	if latest, _ := c.Latest("fakecli"); latest != nil {
+		t.Errorf("the subtree was cached as fakecli's tree: %s", latest.FullCommand())
+	}
+
+	// Offline, the subtree comes from the cached tree of the CLI.
+	opts.Path = nil
+	if _, err := treemand.Load(context.Background(), "fakecli", opts); err != nil {
+		t.Fatal(err)
+	}
+	opts.Offline, opts.Path = true, []string{"sub"}
+	res, err := treemand.Load(context.Background(), "fakecli", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Root.FullCommand() != "fakecli sub" {
+		t.Errorf("offline root = %s, want fakecli sub", res.Root.FullCommand())
+	}
+	opts.Path = []string{"nosuch"}
+	if _, err := treemand.Load(context.Background(), "fakecli", opts); !errors.Is(err, treemand.ErrNotCached) {
+		t.Errorf("offline Load of a missing subcommand: err = %v, want ErrNotCached", err)
+	}
+}
+
+func TestLoad_offlineStale(t *testing.T) {
+	fakeCLI(t)
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	opts := treemand.DefaultOptions()
+	opts.Cache = c
+	live, err := treemand.Load(context.Background(), "fakecli", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live.Version == "" || live.Binary.Path == "" {
+		t.Fatalf("Load: Version=%q Binary=%v, want both", live.Version, live.Binary)
+	}
+
+	opts.Offline = true
+	res, err := treemand.Load(context.Background(), "fakecli", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Version != live.Version || res.Binary.Path != live.Binary.Path || res.Stale || res.Missing {
+		t.Errorf("offline: Version=%q Binary=%v Stale=%v Missing=%v, want the cached tree's, fresh",
+			res.Version, res.Binary, res.Stale, res.Missing)
+	}
+
+	// Upgrading the CLI makes the cached tree stale.
+	if err := os.WriteFile(live.Binary.Path, []byte(fakeCLIScript+"# upgraded\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = treemand.Load(context.Background(), "fakecli", opts); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Stale {
+		t.Error("offline Load after an upgrade should report the tree stale")
+	}
+
+	// Uninstalling it leaves the tree served, the CLI missing.
+	if err := os.Remove(live.Binary.Path); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = treemand.Load(context.Background(), "fakecli", opts); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Missing {
+		t.Error("offline Load after an uninstall should report the CLI missing")
+	}
+}
+
+func TestLoad_override(t *testing.T) {
+	fakeCLI(t)
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fakecli.yaml"), []byte(`commands:
+  - name: sub
+    flags:
+      - {name: --thing, description: the fixed description}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := treemand.DefaultOptions()
+	opts.Cache = c
+	opts.OverrideDir = dir
+
+	for range 2 { // discovered, then cached
+		res, err := treemand.Load(context.Background(), "fakecli", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub := res.Root.Find("sub")
+		if len(sub.Flags) == 0 || sub.Flags[0].Description != "the fixed description" {
+			t.Errorf("sub flags = %+v (cached %v), want --thing overridden", sub.Flags, res.Cached)
+		}
+	}
+	if latest, _ := c.Latest("fakecli"); latest.Find("sub").Flags[0].Description == "the fixed description" {
+		t.Error("the override was cached with the tree")
+	}
+}
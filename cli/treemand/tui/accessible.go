@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aallbrig/treemand/config"
+)
+
+// ---------- accessibility mode ----------
+
+// boxBorder returns the border drawn around panes and modals: rounded
+// box-drawing lines, or plain ASCII ones in accessibility mode.
+func boxBorder(cfg *config.Config) lipgloss.Border {
+	if cfg.Accessible {
+		return lipgloss.ASCIIBorder()
+	}
+	return lipgloss.RoundedBorder()
+}
+
+// togglePlain switches between the panes and the plain view.
+func (m *Model) togglePlain() {
+	m.plain = !m.plain
+	if m.plain {
+		m.statusMsg = "plain view: on (:plain again for the panes)"
+	} else {
+		m.statusMsg = "plain view: off"
+	}
+}
+
+// renderPlainView lays the selection out as lines of plain text, one fact
+// each, for screen readers to read top to bottom: the selected row, the
+// command being built, the help the help pane shows, and the status line
+// last. Nothing is told apart by color or position alone.
+func (m *Model) renderPlainView() string {
+	selected := m.selectedLabel()
+	if selected == "" && m.tree.fill(m.tree.cursor) {
+		selected = m.tree.rows[m.tree.cursor].sectionLabel + " section"
+	}
+	command := strings.Join(m.preview.Tokens(), " ")
+	if command == "" {
+		command = "(empty)"
+	}
+	lines := []string{
+		fmt.Sprintf("Selected: %s (row %d of %d)", selected, m.tree.cursor+1, m.tree.RowCount()),
+		"Command line: " + command,
+	}
+	if f := m.filter.Value(); f != "" || m.filtering {
+		if n := m.tree.FilterMatches(); n >= 0 {
+			f += " (" + matchesLabel(n) + ")"
+		}
+		lines = append(lines, "Filter: "+f)
+	}
+	lines = append(lines, "")
+	if len(m.helpPane.lines) == 0 {
+		m.helpPane.rebuildLines()
+	}
+	for _, l := range m.helpPane.lines {
+		lines = append(lines, wordWrap(l, max(1, m.width))...)
+	}
+
+	status := m.statusMsg
+	if status == "" {
+		status = m.currentStatus()
+	}
+	switch {
+	case m.commanding:
+		status = m.cmdline.View()
+	case status == "":
+		status = "Status: ↑↓ select · Enter pick · f flags · Ctrl+E run · ? keys · :plain panes · q quit"
+	default:
+		status = "Status: " + status
+	}
+	if body := max(0, m.height-1); len(lines) > body {
+		more := len(lines) - body + 1
+		lines = append(lines[:max(0, body-1)], fmt.Sprintf("(%d more lines; m shows the full help)", more))
+	}
+	for len(lines) < m.height-1 {
+		lines = append(lines, "")
+	}
+	return strings.Join(append(lines, status), "\n")
+}
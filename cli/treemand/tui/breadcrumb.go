@@ -0,0 +1,171 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+const (
+	breadcrumbHeight = 1
+	breadcrumbSep    = " ▸ "
+	breadcrumbElide  = "…" + breadcrumbSep
+)
+
+// crumb is one segment of the breadcrumb bar. node is nil for the trailing
+// section segment ("Flags"), which is not clickable.
+type crumb struct {
+	label      string
+	node       *models.Node
+	start, end int // x range [start, end) within the bar
+}
+
+// Ancestry returns the commands from the root down to the selected command
+// (or the owner of the selected flag or positional), and the label of the
+// section the cursor is in ("Flags", "Positional arguments", …) or "" on a
+// command row.
+func (t *TreeModel) Ancestry() ([]*models.Node, string) {
+	if !t.fill(t.cursor) {
+		return nil, ""
+	}
+	row := t.rows[t.cursor]
+	node := t.SelectedOrOwner()
+	if node == nil {
+		return nil, ""
+	}
+	var path []*models.Node
+	cur := t.root
+	path = append(path, cur)
+	if len(node.FullPath) > len(cur.FullPath) {
+		for _, name := range node.FullPath[len(cur.FullPath):] {
+			if cur = cur.Find(name); cur == nil {
+				break
+			}
+			path = append(path, cur)
+		}
+	}
+	if path[len(path)-1] != node {
+		path = []*models.Node{node} // not reachable by name, e.g. a renamed alias
+	}
+
+	section := ""
+	switch row.kind {
+	case rowKindSection:
+		section = row.sectionLabel
+		if i := strings.LastIndex(section, " ("); i > 0 {
+			section = section[:i] // drop the "(n)" count
+		}
+	case rowKindFlag:
+		section = "Flags"
+		if row.flag.Inherited {
+			section = "Inherited flags"
+		}
+	case rowKindPositional:
+		section = "Positional arguments"
+	}
+	return path, section
+}
+
+// SelectNode moves the cursor to node's command row. It returns false when
+// the node has no visible row.
+func (t *TreeModel) SelectNode(node *models.Node) bool {
+	for i, row := range t.eachRow() {
+		if row.kind == rowKindCommand && row.node == node {
+			t.cursor = i
+			t.scrollIntoView()
+			return true
+		}
+	}
+	return false
+}
+
+// breadcrumbs lays out the breadcrumb bar for the current selection within
+// the terminal width, eliding leading ancestors when it does not fit.
+func (m *Model) breadcrumbs() (crumbs []crumb, elided bool) {
+	path, section := m.tree.Ancestry()
+	for _, n := range path {
+		crumbs = append(crumbs, crumb{label: n.Name, node: n})
+	}
+	if section != "" {
+		crumbs = append(crumbs, crumb{label: section})
+	}
+	width := func() int {
+		w := 0
+		if elided {
+			w = lipgloss.Width(breadcrumbElide)
+		}
+		for i, c := range crumbs {
+			if i > 0 {
+				w += lipgloss.Width(breadcrumbSep)
+			}
+			w += lipgloss.Width(c.label)
+		}
+		return w
+	}
+	room := m.width
+	if header := m.cliHeader(m.width / 2); header != "" {
+		room -= lipgloss.Width(header) + 2
+	}
+	for len(crumbs) > 1 && m.width > 0 && width() > room {
+		crumbs = crumbs[1:]
+		elided = true
+	}
+	x := 0
+	if elided {
+		x = lipgloss.Width(breadcrumbElide)
+	}
+	for i := range crumbs {
+		if i > 0 {
+			x += lipgloss.Width(breadcrumbSep)
+		}
+		crumbs[i].start = x
+		x += lipgloss.Width(crumbs[i].label)
+		crumbs[i].end = x
+	}
+	return crumbs, elided
+}
+
+func (m *Model) renderBreadcrumb() string {
+	crumbs, elided := m.breadcrumbs()
+	dim := lipgloss.NewStyle().Faint(true)
+	nodeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(m.cfg.Colors.Subcmd))
+	var b strings.Builder
+	if elided {
+		b.WriteString(dim.Render(breadcrumbElide))
+	}
+	for i, c := range crumbs {
+		if i > 0 {
+			b.WriteString(dim.Render(breadcrumbSep))
+		}
+		switch {
+		case c.node == nil:
+			b.WriteString(dim.Italic(true).Render(c.label))
+		case i == len(crumbs)-1:
+			b.WriteString(nodeStyle.Bold(true).Render(c.label))
+		default:
+			b.WriteString(nodeStyle.Render(c.label))
+		}
+	}
+	// The CLI's executable and version, right-aligned.
+	if header := m.cliHeader(m.width / 2); header != "" {
+		if gap := m.width - lipgloss.Width(b.String()) - lipgloss.Width(header); gap >= 2 {
+			b.WriteString(strings.Repeat(" ", gap) + header)
+		}
+	}
+	return b.String()
+}
+
+// clickBreadcrumb jumps to the ancestor under column x, if any.
+func (m *Model) clickBreadcrumb(x int) {
+	crumbs, _ := m.breadcrumbs()
+	for _, c := range crumbs {
+		if c.node != nil && x >= c.start && x < c.end {
+			if m.tree.SelectNode(c.node) {
+				m.syncSelected()
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- command-line words ----------
+
+// splitWords splits a command line into words the way a POSIX shell does:
+// a quoted string, a backslash-escaped character and a $(...), ${...} or
+// `...` substitution stay inside one word, spaces and all, and the
+// operators |, ||, &, && and ; are words of their own. Words keep their text
+// as typed, quotes included, so joining them with spaces gives the line
+// back. An unterminated quote or substitution runs to the end of the line.
+func splitWords(line string) []string {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	flush := func() {
+		if inWord {
+			words = append(words, cur.String())
+			cur.Reset()
+			inWord = false
+		}
+	}
+	var quote byte       // the quote open, ' or "
+	var open, close byte // the brackets of the substitution open, ( ) or { }
+	depth := 0           // how deep in the substitution
+	backtick := false    // in a `...` substitution
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			cur.WriteByte(c)
+			if c == '\\' && quote == '"' && i+1 < len(line) {
+				i++
+				cur.WriteByte(line[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		case depth > 0:
+			cur.WriteByte(c)
+			switch c {
+			case open:
+				depth++
+			case close:
+				depth--
+			case '\'', '"':
+				quote = c
+			}
+			continue
+		case backtick:
+			cur.WriteByte(c)
+			backtick = c != '`'
+			continue
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+		case c == '|' || c == ';' || c == '&' && !strings.HasSuffix(cur.String(), ">"): // 2>&1 is a redirection
+			flush()
+			op := string(c)
+			if c != ';' && i+1 < len(line) && line[i+1] == c {
+				op += string(c)
+				i++
+			}
+			words = append(words, op)
+		default:
+			inWord = true
+			cur.WriteByte(c)
+			switch {
+			case c == '\\' && i+1 < len(line):
+				i++
+				cur.WriteByte(line[i])
+			case c == '\'' || c == '"':
+				quote = c
+			case c == '`':
+				backtick = true
+			case c == '$' && i+1 < len(line) && (line[i+1] == '(' || line[i+1] == '{'):
+				i++
+				open, close, depth = line[i], ')', 1
+				if open == '{' {
+					close = '}'
+				}
+				cur.WriteByte(open)
+			}
+		}
+	}
+	flush()
+	return words
+}
+
+// isOperator reports whether word separates two commands of a line.
+func isOperator(word string) bool {
+	switch word {
+	case "|", "||", "&", "&&", ";":
+		return true
+	}
+	return false
+}
+
+// commandWrappers are programs that run the command their arguments name,
+// such as xargs in "xargs -n 1 kubectl delete pod", with the letters of
+// their short flags that take a value.
+var commandWrappers = map[string]string{
+	"xargs": "IdEeLlnPs",
+	"sudo":  "CDghpRrTtUu",
+	"env":   "CSu",
+	"time":  "fo",
+	"nohup": "",
+	"nice":  "n",
+	"watch": "dn",
+}
+
+// wordKind is what a word of a command line is.
+type wordKind int
+
+const (
+	wordCommand    wordKind = iota // the program a command runs: the CLI, xargs, or one after a pipe
+	wordSubcommand                 // a subcommand of the CLI
+	wordFlag                       // a flag, with its value when attached
+	wordValue                      // a flag's value, or an assignment before a command
+	wordArg                        // a positional argument, or any word after "--"
+	wordOperator                   // |, ||, &, && or ;
+	wordOther                      // a word of a command outside the tree
+)
+
+// cmdWord is a word of a command line and what it is.
+type cmdWord struct {
+	text string
+	kind wordKind
+	flag flagToken // how a wordFlag reads as flags
+	// node is the command of the tree the word is given to, nil for the
+	// words of commands outside it.
+	node *models.Node
+}
+
+// classifyWords reads the words of a command line against the tree at
+// root. The commands of the line are read in turn: the first, each after an
+// operator, and the one a wrapper such as xargs or sudo runs. Assignments
+// (NAME=value) before one are values. A command of root's CLI is read
+// against the tree:
+//   - words naming subcommands descend it, until the first argument;
+//   - flags are read against the flags of the command so far (see
+//     parseFlagToken), and the next word is a value only when the flag
+//     takes one and the word does not carry it; an unknown flag's next
+//     word is taken as its value, as it may be one;
+//   - "--" ends the flags: the words after it are arguments.
+//
+// The words of other commands are wordOther. root may be nil, when the
+// first command's words are read by their shape alone.
+func classifyWords(words []string, root *models.Node) []cmdWord {
+	var rootPath []string
+	if root != nil {
+		rootPath = root.FullPath
+		if len(rootPath) == 0 {
+			rootPath = []string{root.Name}
+		}
+	}
+	out := make([]cmdWord, len(words))
+	start, first := true, true // the next word starts a command; the first one
+	var node *models.Node
+	inTree := false    // the command is root's CLI
+	var path []string  // the words left of root's command path
+	flagNext := false  // the next word is a flag's value
+	flagsDone := false // "--" was given
+	args := false      // an argument was given
+	wrapper := ""      // the wrapper whose flags are read, "" for none
+	for i, w := range words {
+		c := cmdWord{text: w}
+		switch {
+		case isOperator(w):
+			c.kind = wordOperator
+			start, wrapper, flagNext = true, "", false
+			node, inTree = nil, false
+		case wrapper != "" && strings.HasPrefix(w, "-") && len(w) > 1:
+			// A wrapper's own flag, and its value when it takes one.
+			c.kind = wordFlag
+			c.flag = flagToken{names: []string{w}, nameLen: len(w)}
+			if len(w) == 2 && strings.Contains(commandWrappers[wrapper], w[1:]) {
+				flagNext = true
+			}
+		case wrapper != "" && flagNext:
+			c.kind, flagNext = wordValue, false
+		case start && isAssignment(w):
+			c.kind = wordValue
+		case start:
+			c.kind, start, wrapper = wordCommand, false, ""
+			if _, ok := commandWrappers[w]; ok {
+				wrapper, start = w, true
+				break
+			}
+			flagNext, flagsDone, args = false, false, false
+			switch {
+			case root == nil && first:
+				inTree = true
+			case root != nil && w == rootPath[0]:
+				node, inTree, path = root, true, rootPath[1:]
+			}
+			first = false
+		case !inTree:
+			c.kind = wordOther
+		case flagNext:
+			c.kind, flagNext = wordValue, false
+		case len(path) > 0:
+			// The rest of the command path of a tree rooted below the
+			// CLI; a command off the path is not in the tree.
+			if w == path[0] {
+				c.kind, path = wordSubcommand, path[1:]
+			} else {
+				c.kind, node, inTree = wordOther, nil, false
+			}
+		case !flagsDone && w == "--":
+			c.kind, c.flag, flagsDone = wordFlag, flagToken{nameLen: len(w)}, true
+		case !flagsDone && strings.HasPrefix(w, "-") && w != "-":
+			c.kind = wordFlag
+			c.flag, _ = parseFlagToken(w, knownFlags(node, root))
+			// Without a known flag, the next word may be the value.
+			flagNext = c.flag.wantsValue() || (c.flag.last == nil && !c.flag.attached)
+		case !args && !flagsDone && node != nil && findCommand(node, w) != nil:
+			c.kind = wordSubcommand
+			node = findCommand(node, w)
+		case !args && !flagsDone && root == nil:
+			// Without a tree, words are read as subcommands.
+			c.kind = wordSubcommand
+		default:
+			c.kind, args = wordArg, true
+		}
+		if inTree {
+			c.node = node
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// isAssignment reports whether word sets an environment variable for the
+// command after it: NAME=value.
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	return ok && envNameRe.MatchString(name)
+}
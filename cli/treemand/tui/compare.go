@@ -0,0 +1,257 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- comparing two commands ----------
+
+// compareRow is one line of the comparison: a flag or positional of the
+// pinned command and the matching one of the selected command, "" where a
+// command has none.
+type compareRow struct {
+	header      string // section title; left and right are unused
+	left, right string
+}
+
+// differs reports whether the two sides of the row tell the commands apart.
+func (r compareRow) differs() bool { return r.header == "" && r.left != r.right }
+
+// compareModal is the C overlay setting the flags and positionals of the
+// pinned command beside those of the selected one.
+type compareModal struct {
+	active      bool
+	left, right *models.Node
+	rows        []compareRow
+	diffOnly    bool // rows the commands share are hidden
+	offset      int
+}
+
+// togglePin pins the selected command for C to compare against, or unpins
+// it when it is pinned already.
+func (m *Model) togglePin() {
+	node := m.tree.SelectedOrOwner()
+	switch {
+	case node == nil || node.Virtual:
+		m.statusMsg = "select a command to pin"
+	case node == m.pinned:
+		m.pinned = nil
+		m.statusMsg = "unpinned " + node.FullCommand()
+	default:
+		m.pinned = node
+		m.statusMsg = "pinned " + node.FullCommand() + "; select another command and press C to compare"
+	}
+}
+
+// openCompare opens the comparison of the pinned command with the
+// selected one.
+func (m *Model) openCompare() {
+	node := m.tree.SelectedOrOwner()
+	switch {
+	case m.pinned == nil:
+		m.statusMsg = "pin a command with P first"
+	case node == nil || node.Virtual || node == m.pinned:
+		m.statusMsg = "select a command other than the pinned " + m.pinned.FullCommand()
+	default:
+		m.cmp = compareModal{active: true, left: m.pinned, right: node, rows: compareRows(m.pinned, node)}
+	}
+}
+
+// compareRows lines up the flags of a and b by name, those of a in its
+// order followed by those only b has, then their positionals by position.
+func compareRows(a, b *models.Node) []compareRow {
+	rows := []compareRow{{header: "Flags"}}
+	flagsA, flagsB := commandFlags(a), commandFlags(b)
+	find := func(flags []models.Flag, name string) *models.Flag {
+		for i := range flags {
+			if flags[i].Name == name {
+				return &flags[i]
+			}
+		}
+		return nil
+	}
+	for _, f := range flagsA {
+		row := compareRow{left: flagSpec(f)}
+		if g := find(flagsB, f.Name); g != nil {
+			row.right = flagSpec(*g)
+		}
+		rows = append(rows, row)
+	}
+	for _, g := range flagsB {
+		if find(flagsA, g.Name) == nil {
+			rows = append(rows, compareRow{right: flagSpec(g)})
+		}
+	}
+	rows = append(rows, compareRow{header: "Arguments"})
+	for i := range max(len(a.Positionals), len(b.Positionals)) {
+		var row compareRow
+		if i < len(a.Positionals) {
+			row.left = positionalSpec(a.Positionals[i])
+		}
+		if i < len(b.Positionals) {
+			row.right = positionalSpec(b.Positionals[i])
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// flagSpec describes f as the comparison shows it: "--message, -m
+// <string> (required)".
+func flagSpec(f models.Flag) string {
+	s := f.Name
+	if f.ShortName != "" && !strings.HasPrefix(f.ShortName, "-") {
+		s += ", -" + f.ShortName
+	} else if f.ShortName != "" {
+		s += ", " + f.ShortName
+	}
+	if f.TakesValue() {
+		s += " <" + f.ValueType + ">"
+	}
+	if f.Required {
+		s += " (required)"
+	}
+	return s
+}
+
+// positionalSpec describes p as a usage line does: "<msg>", "[<path>...]".
+func positionalSpec(p models.Positional) string {
+	s := "<" + p.Name + ">"
+	if p.Variadic {
+		s += "..."
+	}
+	if !p.Required {
+		s = "[" + s + "]"
+	}
+	return s
+}
+
+// visibleRows returns the rows shown, leaving out those the commands share
+// while only differences are.
+func (c *compareModal) visibleRows() []compareRow {
+	if !c.diffOnly {
+		return c.rows
+	}
+	var rows []compareRow
+	for _, r := range c.rows {
+		if r.header != "" || r.differs() {
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
+
+func (m *Model) updateCompareModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	page := max(1, m.messagesViewport())
+	switch msg.String() {
+	case "ctrl+c", "esc", "q", "enter":
+		m.cmp.active = false
+		return m, nil
+	case "up", "k":
+		m.cmp.offset--
+	case "down", "j":
+		m.cmp.offset++
+	case "pgup", "b", "ctrl+u":
+		m.cmp.offset -= page
+	case "pgdown", " ", "ctrl+d":
+		m.cmp.offset += page
+	case "g", "home":
+		m.cmp.offset = 0
+	case "G", "end":
+		m.cmp.offset = len(m.cmp.rows)
+	case "d":
+		m.cmp.diffOnly = !m.cmp.diffOnly
+	case "s":
+		m.cmp.left, m.cmp.right = m.cmp.right, m.cmp.left
+		m.cmp.rows = compareRows(m.cmp.left, m.cmp.right)
+	}
+	m.cmp.offset = max(0, min(m.cmp.offset, len(m.cmp.visibleRows())-page))
+	return m, nil
+}
+
+func (m *Model) renderCompareModal() string {
+	modalW := max(40, min(m.width-6, 120))
+	// In accessibility mode a "-", "+" or "~" before each row tells what the
+	// colors do: only the pinned command has it, only the selected one, or
+	// both differently.
+	gutter, bar := "", " │ "
+	if m.cfg.Accessible {
+		gutter, bar = "  ", " | "
+	}
+	colW := max(1, (modalW-9-len(gutter))/2)
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	hintStyle := lipgloss.NewStyle().Faint(true)
+	onlyLeft := lipgloss.NewStyle().Foreground(lipgloss.Color(m.cfg.Colors.Invalid))
+	onlyRight := lipgloss.NewStyle().Foreground(lipgloss.Color("#50FA7B"))
+	changed := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C"))
+
+	cell := func(text string, style lipgloss.Style) string {
+		if text == "" {
+			text, style = "—", hintStyle
+		}
+		text = clipRow(text, 0, colW)
+		return style.Render(text) + strings.Repeat(" ", max(0, colW-lipgloss.Width(text)))
+	}
+	var lines []string
+	differences := 0
+	for _, r := range m.cmp.visibleRows() {
+		if r.header != "" {
+			lines = append(lines, titleStyle.Render(r.header))
+			continue
+		}
+		left, right := lipgloss.NewStyle(), lipgloss.NewStyle()
+		mark := gutter
+		switch {
+		case !r.differs():
+		case r.right == "":
+			left, mark = onlyLeft, "- "
+		case r.left == "":
+			right, mark = onlyRight, "+ "
+		default:
+			left, right, mark = changed, changed, "~ "
+		}
+		if r.differs() {
+			differences++
+		}
+		if gutter == "" {
+			mark = ""
+		}
+		lines = append(lines, mark+cell(r.left, left)+hintStyle.Render(bar)+cell(r.right, right))
+	}
+	vp := m.messagesViewport()
+	start := max(0, min(m.cmp.offset, len(lines)-vp))
+	end := min(len(lines), start+vp)
+
+	title := "Compare"
+	if len(lines) > vp {
+		title += fmt.Sprintf(" [%d-%d/%d]", start+1, end, len(lines))
+	}
+	summary := fmt.Sprintf("%d differences", differences)
+	if differences == 1 {
+		summary = "1 difference"
+	}
+	header := gutter + cell(m.cmp.left.FullCommand(), lipgloss.NewStyle().Bold(true)) + hintStyle.Render(bar) +
+		cell(m.cmp.right.FullCommand(), lipgloss.NewStyle().Bold(true))
+	hint := "↑↓/jk scroll · d differences only · s swap · Esc close"
+	if m.cmp.diffOnly {
+		hint = "↑↓/jk scroll · d show all · s swap · Esc close"
+	}
+	content := titleStyle.Render(title) + "  " + hintStyle.Render(summary) + "\n" +
+		hintStyle.Render(hint) + "\n\n" +
+		header + "\n" +
+		strings.Join(lines[start:end], "\n")
+
+	box := lipgloss.NewStyle().
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(0, 2).
+		Width(modalW - 2).
+		Render(content)
+	return m.overlay(box)
+}
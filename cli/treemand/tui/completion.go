@@ -0,0 +1,135 @@
+package tui
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/aallbrig/treemand/discovery"
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- live value completion ----------
+
+const (
+	// completionDelay debounces completion requests while the user types.
+	completionDelay = 200 * time.Millisecond
+	// completionTimeout bounds one run of the CLI's completion hook.
+	completionTimeout = 3 * time.Second
+)
+
+// completeTickMsg fires once typing has paused for completionDelay. Only
+// the tick of the latest request (seq) runs the completer.
+type completeTickMsg struct{ seq int }
+
+// completionsMsg carries the values returned for request seq.
+type completionsMsg struct {
+	seq    int
+	values []string
+}
+
+// SetValueCompleter sets the completer asked for value suggestions while a
+// value prompt is open. A nil completer disables live completion.
+func (m *Model) SetValueCompleter(c discovery.ValueCompleter) {
+	m.completer = c
+}
+
+// valuePrompt is the open value prompt as seen by the completer.
+type valuePrompt struct {
+	input   *textinput.Model
+	suggest *suggestList
+	args    []string // command line tokens (after the CLI name) before the value
+	name    string   // flag or positional the value is for
+}
+
+// activePrompt returns the open value prompt, if any.
+func (m *Model) activePrompt() (valuePrompt, bool) {
+	switch {
+	case m.vm.active:
+		p := valuePrompt{input: &m.vm.input, suggest: &m.vm.suggest, args: m.commandTokens(m.vm.owner), name: m.vm.argName}
+		if m.vm.flag != "" {
+			p.args = append(p.args, m.vm.flag)
+			p.name = m.vm.flag
+		}
+		return p, true
+	case m.fm.active && m.fm.awaitingValue:
+		flag := m.fm.entries[m.fm.awaitingIdx].flag.Name
+		return valuePrompt{
+			input:   &m.fm.valueInput,
+			suggest: &m.fm.suggest,
+			args:    append(m.commandTokens(m.fm.owner), flag),
+			name:    flag,
+		}, true
+	}
+	return valuePrompt{}, false
+}
+
+// commandTokens returns the preview's tokens after the CLI name when the
+// preview is a command line of owner, or owner's path otherwise — what the
+// preview will hold once the prompted value is added.
+func (m *Model) commandTokens(owner *models.Node) []string {
+	if owner == nil {
+		return nil
+	}
+	toks := m.preview.Tokens()
+	if len(toks) < len(owner.FullPath) || !slices.Equal(toks[:len(owner.FullPath)], owner.FullPath) {
+		toks = owner.FullPath
+	}
+	if len(toks) == 0 {
+		return nil
+	}
+	return slices.Clone(toks[1:])
+}
+
+// requestCompletion schedules a completion for the open value prompt when
+// its command line or typed text changed since the last request. Update
+// calls it after every message.
+func (m *Model) requestCompletion() tea.Cmd {
+	if m.completer == nil {
+		return nil
+	}
+	p, ok := m.activePrompt()
+	if !ok {
+		m.completeKey = ""
+		return nil
+	}
+	key := strings.Join(p.args, " ") + "\x00" + p.name + "\x00" + p.input.Value()
+	if key == m.completeKey {
+		return nil
+	}
+	m.completeKey = key
+	m.completeSeq++
+	seq := m.completeSeq
+	return tea.Tick(completionDelay, func(time.Time) tea.Msg { return completeTickMsg{seq: seq} })
+}
+
+// runCompletion asks the completer for values once the debounce delay of
+// request seq has passed without a newer request.
+func (m *Model) runCompletion(seq int) tea.Cmd {
+	p, ok := m.activePrompt()
+	if seq != m.completeSeq || !ok {
+		return nil
+	}
+	completer, toComplete := m.completer, p.input.Value()
+	via := discovery.ParseVia(m.cfg.Via)
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(discovery.WithVia(context.Background(), via), completionTimeout)
+		defer cancel()
+		values, err := completer.CompleteValues(ctx, p.args, toComplete, p.name)
+		if err != nil {
+			values = nil
+		}
+		return completionsMsg{seq: seq, values: values}
+	}
+}
+
+// applyCompletions shows the values of request seq under the open prompt.
+func (m *Model) applyCompletions(msg completionsMsg) {
+	if p, ok := m.activePrompt(); ok && msg.seq == m.completeSeq {
+		p.suggest.live = msg.values
+	}
+}
@@ -0,0 +1,62 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+)
+
+// ---------- dangerous commands ----------
+
+// dangerConfirmation is what the execute modal of a dangerous command
+// needs typed before it runs it.
+const dangerConfirmation = "yes"
+
+// dangerPattern returns the first of patterns that command matches, or ""
+// for none. A pattern matches a command holding its words as consecutive
+// tokens; a flag word also matches the flag given a value with "=", so
+// "--force" matches "--force=true" but not "--force-with-lease".
+func dangerPattern(command string, patterns []string) string {
+	tokens := strings.Fields(command)
+	for _, p := range patterns {
+		words := strings.Fields(p)
+		if len(words) == 0 {
+			continue
+		}
+		for i := 0; i+len(words) <= len(tokens); i++ {
+			if matchWords(tokens[i:i+len(words)], words) {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+func matchWords(tokens, words []string) bool {
+	for i, w := range words {
+		t := tokens[i]
+		if t != w && !(strings.HasPrefix(w, "-") && strings.HasPrefix(t, w+"=")) {
+			return false
+		}
+	}
+	return true
+}
+
+// armDanger makes the execute modal ask for a typed confirmation when its
+// command matches one of the configured danger patterns. Offline, commands
+// are not run, so none is asked for.
+func (m *Model) armDanger() {
+	m.modal.danger = ""
+	if m.cfg.Offline {
+		return
+	}
+	m.modal.danger = dangerPattern(m.modal.command, m.cfg.DangerPatterns)
+	if m.modal.danger == "" {
+		return
+	}
+	in := textinput.New()
+	in.Placeholder = "type " + dangerConfirmation + " to run"
+	in.CharLimit = 16
+	in.Focus()
+	m.modal.confirm = in
+}
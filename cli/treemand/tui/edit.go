@@ -0,0 +1,226 @@
+package tui
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/override"
+)
+
+// ---------- edit mode ----------
+
+// OverrideStore keeps the override file edits made in the TUI are saved to
+// (see package override). override.File implements it for one CLI.
+type OverrideStore interface {
+	Load() (*override.Command, error)
+	Save(*override.Command) error
+	Path() string
+}
+
+// editHint lists the : commands of edit mode, for the status bar.
+const editHint = "rename NAME · describe TEXT · delete · add command|flag NAME"
+
+// SetOverrideStore turns edit mode on: the : commands rename, describe,
+// delete and add change the tree, and every change is saved to the
+// override in store, so later runs see it too. A nil store turns it off.
+func (m *Model) SetOverrideStore(store OverrideStore) error {
+	m.overrides, m.overrideStore = nil, store
+	if store == nil {
+		return nil
+	}
+	o, err := store.Load()
+	if err != nil {
+		return err
+	}
+	m.overrides = o
+	return nil
+}
+
+// Editing reports whether edit mode is on.
+func (m *Model) Editing() bool { return m.overrideStore != nil }
+
+// runEditCommand runs the edit command verb with argument arg on the
+// selected command or flag, reporting the outcome in the status bar.
+func (m *Model) runEditCommand(verb, arg string) {
+	if !m.Editing() {
+		m.statusMsg = verb + ": edit mode is off (start it with treemand edit " + m.cliName() + ")"
+		return
+	}
+	sel := m.tree.SelectedItem()
+	if sel == nil || sel.Kind == SelPositional {
+		m.statusMsg = verb + ": select a command or flag"
+		return
+	}
+	node, flag := sel.Node, ""
+	if sel.Kind == SelFlag {
+		node, flag = sel.Owner, sel.Flag.Name
+	}
+	what := node.FullCommand()
+	if flag != "" {
+		what = flag + " of " + what
+	}
+	// Each change is made to the saved override, and to one holding just
+	// the change, which is applied to the tree shown.
+	change := &override.Command{Name: m.cliName()}
+	var status string
+	switch verb {
+	case "rename":
+		if arg == "" || strings.ContainsAny(arg, " \t") {
+			m.statusMsg = "usage: rename NAME"
+			return
+		}
+		if flag == "" && len(node.FullPath) < 2 {
+			m.statusMsg = "rename: the CLI itself cannot be renamed"
+			return
+		}
+		m.edit(node, flag, change, func(c *override.Command, f *override.Flag) {
+			if f != nil {
+				f.Rename = arg
+			} else {
+				c.Rename = arg
+			}
+		})
+		status = "renamed " + what + " to " + arg
+	case "describe":
+		if arg == "" {
+			m.statusMsg = "usage: describe TEXT"
+			return
+		}
+		m.edit(node, flag, change, func(c *override.Command, f *override.Flag) {
+			if f != nil {
+				f.Description = arg
+			} else {
+				c.Description = arg
+			}
+		})
+		status = "described " + what
+	case "delete":
+		if flag == "" && len(node.FullPath) < 2 {
+			m.statusMsg = "delete: the CLI itself cannot be deleted"
+			return
+		}
+		m.delete(node, flag, change)
+		status = "deleted " + what
+	case "add":
+		kind, rest, _ := strings.Cut(arg, " ")
+		fields := strings.Fields(rest)
+		switch {
+		case kind == "command" && len(fields) == 1:
+			name := fields[0]
+			for _, c := range []*override.Command{change, m.overrides} {
+				c.CommandAt(append(relPath(node), name))
+			}
+			status = "added " + node.FullCommand() + " " + name
+		case kind == "flag" && (len(fields) == 1 || len(fields) == 2) && strings.HasPrefix(fields[0], "-"):
+			for _, c := range []*override.Command{change, m.overrides} {
+				f := c.CommandAt(relPath(node)).FlagAt(fields[0])
+				if len(fields) == 2 {
+					f.Type = fields[1]
+				}
+			}
+			status = "added " + fields[0] + " to " + node.FullCommand()
+		default:
+			m.statusMsg = "usage: add command NAME, or add flag --NAME [TYPE]"
+			return
+		}
+	}
+
+	oldPath := slices.Clone(node.FullPath)
+	override.Apply(m.root, change)
+	if verb == "rename" && flag == "" {
+		m.tree.moveKeys(oldPath, node.FullPath)
+	}
+	m.tree.Rebuild()
+	if verb == "delete" && flag == "" {
+		if parent := m.root.FindFullPath(oldPath[:len(oldPath)-1]); parent != nil {
+			node = parent
+		}
+	}
+	m.tree.SelectNode(node)
+	m.syncSelected()
+	if err := m.overrideStore.Save(m.overrides); err != nil {
+		m.statusMsg = status + ", but could not save it: " + err.Error()
+		return
+	}
+	m.statusMsg = status + " (saved to " + m.overrideStore.Path() + ")"
+}
+
+// edit makes a change with set to the override of node, or of its flag
+// when flag is not "", in both the saved override and change.
+func (m *Model) edit(node *models.Node, flag string, change *override.Command, set func(*override.Command, *override.Flag)) {
+	for _, c := range []*override.Command{change, m.overrides} {
+		oc := c.CommandAt(relPath(node))
+		if flag == "" {
+			set(oc, nil)
+		} else {
+			set(oc, oc.FlagAt(flag))
+		}
+	}
+}
+
+// delete removes node, or its flag when flag is not "". A command or flag
+// that only the override added is taken out of it; one discovery found is
+// overridden with remove.
+func (m *Model) delete(node *models.Node, flag string, change *override.Command) {
+	if flag == "" {
+		change.CommandAt(relPath(node)).Remove = true
+		path := relPath(node)
+		parent := m.overrides.CommandAt(path[:len(path)-1])
+		if onlyOverride(node.Sources) {
+			parent.Commands = slices.DeleteFunc(parent.Commands, func(c override.Command) bool {
+				return c.Name == node.Name && c.Rename == "" || c.Rename == node.Name
+			})
+			return
+		}
+		oc := parent.CommandAt(path[len(path)-1:])
+		*oc = override.Command{Name: oc.Name, Remove: true}
+		return
+	}
+	change.CommandAt(relPath(node)).FlagAt(flag).Remove = true
+	oc := m.overrides.CommandAt(relPath(node))
+	flags := commandFlags(node)
+	if i := slices.IndexFunc(flags, func(f models.Flag) bool { return f.Name == flag }); i >= 0 && onlyOverride(flags[i].Sources) {
+		oc.Flags = slices.DeleteFunc(oc.Flags, func(f override.Flag) bool {
+			return f.Name == flag && f.Rename == "" || f.Rename == flag
+		})
+		return
+	}
+	of := oc.FlagAt(flag)
+	*of = override.Flag{Name: of.Name, Remove: true}
+}
+
+// onlyOverride reports whether sources name the override alone, as those
+// of a command or flag it added.
+func onlyOverride(sources []string) bool {
+	return slices.Equal(sources, []string{override.Source})
+}
+
+// relPath returns node's path below the CLI, as overrides name commands.
+func relPath(node *models.Node) []string {
+	return slices.Clone(node.FullPath[1:])
+}
+
+// cliName returns the name of the CLI explored.
+func (m *Model) cliName() string {
+	if len(m.root.FullPath) > 0 {
+		return m.root.FullPath[0]
+	}
+	return m.root.Name
+}
+
+// moveKeys carries the view state of the command at from, and of those
+// below it, over to the path to, as it was renamed.
+func (t *TreeModel) moveKeys(from, to []string) {
+	prefix := strings.Join(from, "/")
+	moved := map[string]bool{}
+	for k, on := range t.nodeExpanded {
+		rest, ok := strings.CutPrefix(k, prefix)
+		if ok && (strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, "@")) {
+			delete(t.nodeExpanded, k)
+			moved[strings.Join(to, "/")+rest] = on
+		}
+	}
+	maps.Copy(t.nodeExpanded, moved)
+}
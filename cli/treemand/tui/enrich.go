@@ -0,0 +1,142 @@
+package tui
+
+import (
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- background discovery ----------
+
+// maxEnrichWorkers bounds the stubs discovered at once in the background,
+// as discovery.HelpDiscoverer bounds the commands it probes.
+const maxEnrichWorkers = 8
+
+// enrichment discovers the stubs of a tree opened before it was discovered
+// whole, a level at a time, while the tree is explored.
+type enrichment struct {
+	depth   int            // the deepest level discovered, counted from the root; 0 for none
+	gen     int            // bumped when the tree is replaced, to drop what was found for the old one
+	queue   []*models.Node // stubs waiting to be discovered, shallowest first
+	running int            // stubs being discovered
+	found   int            // stubs discovered so far
+	done    func()         // called once no stub is left to discover; nil once called
+}
+
+// enrichedMsg carries a stub discovered in the background.
+type enrichedMsg struct {
+	gen int
+	LazyExpandMsg
+}
+
+// DiscoverInBackground makes the TUI discover the stubs of its tree down to
+// depth levels below the root (-1 for all) once it starts, as the tree
+// would have been had it not been opened first. Stubs of a command with
+// more subcommands than the stub threshold are left to be expanded, as
+// discovery leaves them. done, which may be nil, is called outside the
+// update loop once no stub is left, to cache the tree discovered. It does
+// nothing offline.
+func (m *Model) DiscoverInBackground(depth int, done func()) {
+	if depth < 0 {
+		depth = 99 // as treemand.Load caps an unlimited depth
+	}
+	m.enrich.depth, m.enrich.done = depth, done
+}
+
+// enrichBelow queues the stubs below node to be discovered in the
+// background and starts discovering them.
+func (m *Model) enrichBelow(node *models.Node) tea.Cmd {
+	if m.enrich.depth == 0 || m.cfg.Offline {
+		return nil
+	}
+	threshold := m.cfg.StubThreshold
+	if threshold <= 0 {
+		threshold = 50 // as discovery.HelpDiscoverer defaults it
+	}
+	rootDepth := len(m.root.FullPath)
+	var walk func(n *models.Node)
+	walk = func(n *models.Node) {
+		if len(n.Children) > threshold {
+			return
+		}
+		for _, c := range n.Children {
+			switch {
+			case c.Virtual || len(c.FullPath)-rootDepth > m.enrich.depth:
+			case c.Stub:
+				m.enrich.queue = append(m.enrich.queue, c)
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(node)
+	return m.startEnriching()
+}
+
+// startEnriching starts discovering queued stubs, up to maxEnrichWorkers
+// at once. Stubs expanded since they were queued are skipped. Once none is
+// left, it calls the done function DiscoverInBackground was given.
+func (m *Model) startEnriching() tea.Cmd {
+	var cmds []tea.Cmd
+	for m.enrich.running < maxEnrichWorkers && len(m.enrich.queue) > 0 {
+		stub := m.enrich.queue[0]
+		m.enrich.queue = m.enrich.queue[1:]
+		if !stub.Stub {
+			continue
+		}
+		m.enrich.running++
+		m.tree.setDiscovering(stub, true)
+		discover, gen := m.discoverStub(stub), m.enrich.gen
+		cmds = append(cmds, func() tea.Msg {
+			return enrichedMsg{gen: gen, LazyExpandMsg: discover().(LazyExpandMsg)}
+		})
+	}
+	if done := m.enrich.done; done != nil && m.enrich.running == 0 {
+		m.enrich.done = nil
+		cmds = append(cmds, func() tea.Msg {
+			done()
+			return nil
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// enriched puts a stub discovered in the background in the tree, queues
+// the stubs below it, and goes on with the next ones. A stub that could
+// not be discovered is left to be expanded by hand.
+func (m *Model) enriched(msg enrichedMsg) tea.Cmd {
+	if msg.gen != m.enrich.gen {
+		return nil
+	}
+	m.enrich.running--
+	m.tree.setDiscovering(msg.Stub, false)
+	var cmds []tea.Cmd
+	if msg.Err == nil && msg.Discovered != nil && msg.Stub.Stub {
+		m.tree.PatchNode(msg.Stub, msg.Discovered)
+		m.enrich.found++
+		if m.tree.Selected() == msg.Stub {
+			m.syncSelected()
+		}
+		cmds = append(cmds, m.discoverExpandedStubs(msg.Stub, len(msg.Stub.FullPath)-1), m.enrichBelow(msg.Stub))
+	}
+	cmds = append(cmds, m.startEnriching())
+	if m.enrich.running == 0 && len(m.enrich.queue) == 0 && m.enrich.found > 0 {
+		m.statusMsg = "discovered " + strconv.Itoa(m.enrich.found) + " more commands in the background"
+		m.enrich.found = 0
+	}
+	return tea.Batch(cmds...)
+}
+
+// restartEnriching drops the background discovery of the tree replaced and
+// starts it over on the one explored now. That tree was discovered whole,
+// and cached so, so there is nothing left to cache once it is done.
+func (m *Model) restartEnriching() tea.Cmd {
+	m.enrich.gen++
+	m.enrich.queue, m.enrich.running, m.enrich.found = nil, 0, 0
+	m.enrich.done = nil
+	m.tree.discovering = nil
+	m.tree.rowCache = nil
+	return m.enrichBelow(m.root)
+}
@@ -0,0 +1,209 @@
+package tui
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- environment variables ----------
+
+// EnvStore keeps the environment variables a CLI's commands are run and
+// copied with, by name. cache.EnvStore implements it for one CLI.
+type EnvStore interface {
+	Env() map[string]string
+	// SetEnv sets the variable name to value, deleting it when value is "".
+	SetEnv(name, value string) error
+}
+
+// envModal is the :env overlay listing the variables, with a NAME=value
+// prompt while one is added or edited.
+type envModal struct {
+	active  bool
+	names   []string // of m.env, sorted
+	cursor  int
+	editing bool // the prompt is open
+	input   textinput.Model
+	invalid string // why the assignment typed in the prompt was refused
+}
+
+// envNameRe matches the name of an environment variable.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SetEnvStore sets the store the variables are read from and saved to. A
+// nil store leaves :env setting them for the session only.
+func (m *Model) SetEnvStore(store EnvStore) {
+	m.envStore = store
+	m.env = nil
+	if store != nil {
+		m.env = store.Env()
+	}
+}
+
+// envPrefix returns the variables as assignments to put before a command,
+// "AWS_PROFILE=dev AWS_REGION=eu-west-1 ", or "" when none are set.
+func (m *Model) envPrefix() string {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(m.env)) {
+		b.WriteString(name + "=" + m.env[name] + " ")
+	}
+	return b.String()
+}
+
+// splitEnv splits the assignments a command line starts with, as
+// envPrefix writes them, from the command.
+func splitEnv(tokens []string) (env, command []string) {
+	for i, t := range tokens {
+		name, _, ok := strings.Cut(t, "=")
+		if !ok || !envNameRe.MatchString(name) {
+			return tokens[:i], tokens[i:]
+		}
+	}
+	return tokens, nil
+}
+
+// openEnv opens the :env overlay.
+func (m *Model) openEnv() {
+	m.em = envModal{active: true, names: slices.Sorted(maps.Keys(m.env))}
+}
+
+// editEnv opens the NAME=value prompt holding value.
+func (m *Model) editEnv(value string) tea.Cmd {
+	in := textinput.New()
+	in.Placeholder = "NAME=value (an empty value deletes it)"
+	in.CharLimit = 512
+	in.SetValue(value)
+	in.Focus()
+	m.em.input, m.em.editing, m.em.invalid = in, true, ""
+	return textinput.Blink
+}
+
+func (m *Model) updateEnvModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.em.editing {
+		switch msg.String() {
+		case "enter":
+			m.setEnv(strings.TrimSpace(m.em.input.Value()))
+			return m, nil
+		case "esc":
+			m.em.editing = false
+			return m, nil
+		case "ctrl+c":
+			m.em.active = false
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.em.input, cmd = m.em.input.Update(msg)
+		return m, cmd
+	}
+	switch msg.String() {
+	case "ctrl+c", "esc", "q":
+		m.em.active = false
+	case "up", "k":
+		m.em.cursor = max(m.em.cursor-1, 0)
+	case "down", "j":
+		m.em.cursor = max(min(m.em.cursor+1, len(m.em.names)-1), 0)
+	case "a", "n":
+		return m, m.editEnv("")
+	case "enter", "e":
+		if len(m.em.names) == 0 {
+			return m, m.editEnv("")
+		}
+		name := m.em.names[m.em.cursor]
+		return m, m.editEnv(name + "=" + m.env[name])
+	case "x", "delete":
+		if len(m.em.names) > 0 {
+			m.setEnv(m.em.names[m.em.cursor] + "=")
+		}
+	}
+	return m, nil
+}
+
+// setEnv applies the assignment NAME=value typed in the prompt, deleting
+// the variable when value is empty, and saves it to the store.
+func (m *Model) setEnv(assignment string) {
+	name, value, _ := strings.Cut(assignment, "=")
+	switch {
+	case !envNameRe.MatchString(name):
+		m.em.invalid = "usage: NAME=value, NAME being letters, digits and _"
+		return
+	case strings.ContainsAny(value, " \t"):
+		m.em.invalid = "the value of " + name + " cannot hold spaces"
+		return
+	}
+	m.em.editing = false
+	if m.env == nil {
+		m.env = map[string]string{}
+	}
+	if value == "" {
+		delete(m.env, name)
+		m.statusMsg = "unset " + name
+	} else {
+		m.env[name] = value
+		m.statusMsg = "set " + name + "=" + value
+	}
+	m.em.names = slices.Sorted(maps.Keys(m.env))
+	if i := slices.Index(m.em.names, name); i >= 0 {
+		m.em.cursor = i
+	} else {
+		m.em.cursor = max(min(m.em.cursor, len(m.em.names)-1), 0)
+	}
+	if m.envStore == nil {
+		m.statusMsg += " for this session (the cache, which keeps it, is off)"
+		return
+	}
+	if err := m.envStore.SetEnv(name, value); err != nil {
+		m.statusMsg += ", but could not save it: " + err.Error()
+	}
+}
+
+func (m *Model) renderEnvModal() string {
+	modalW := max(40, min(m.width-6, 80))
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	hintStyle := lipgloss.NewStyle().Faint(true)
+	selStyle := lipgloss.NewStyle().Reverse(true)
+
+	var lines []string
+	for i, name := range m.em.names {
+		line := clipRow(name+"="+m.env[name], 0, modalW-8)
+		if i == m.em.cursor && !m.em.editing {
+			line = selStyle.Render("▶ ") + line
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = []string{hintStyle.Render("no variables set; press a to add one")}
+	}
+	vp := max(1, m.messagesViewport()-3)
+	start := max(0, m.em.cursor-vp+1)
+	end := min(len(lines), start+vp)
+	hint := "↑↓/jk select · a add · Enter edit · x delete · Esc close"
+	if m.em.editing {
+		hint = "[Enter] set  [Esc] back"
+	}
+	content := titleStyle.Render("Environment") + "\n" +
+		hintStyle.Render("set for commands run or copied with Ctrl+E") + "\n\n" +
+		strings.Join(lines[start:end], "\n") + "\n\n"
+	if m.em.editing {
+		m.em.input.Width = modalW - 8
+		content += m.em.input.View() + "\n\n"
+		if m.em.invalid != "" {
+			content += lipgloss.NewStyle().Foreground(lipgloss.Color(m.cfg.Colors.Invalid)).Render(m.em.invalid) + "\n\n"
+		}
+	}
+	content += hintStyle.Render(hint)
+
+	box := lipgloss.NewStyle().
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(0, 2).
+		Width(modalW - 2).
+		Render(content)
+	return m.overlay(box)
+}
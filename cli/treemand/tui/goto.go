@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- :goto ----------
+
+// gotoTarget returns the command query names, or nil when none matches. The
+// query is a command path, with or without the root's ("remote add", "git
+// remote add"), its words separated by spaces or slashes ("git/remote/add").
+// Each word of the query must match a word of the path, in order, by its
+// letters appearing in it in order ("rem ad"). The path with the fewest
+// letters and words left unmatched wins, so one naming a command exactly
+// does, and the shallower of two equal ones.
+func gotoTarget(root *models.Node, query string) *models.Node {
+	words := strings.Fields(strings.ToLower(strings.ReplaceAll(query, "/", " ")))
+	rootPath := root.FullPath
+	if len(rootPath) == 0 {
+		rootPath = []string{root.Name}
+	}
+	// Leave out the root's path, or the root's name it starts with.
+	switch {
+	case len(words) >= len(rootPath) && strings.EqualFold(strings.Join(words[:len(rootPath)], " "), strings.Join(rootPath, " ")):
+		words = words[len(rootPath):]
+	case len(words) > 0 && strings.EqualFold(words[0], root.Name):
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return root
+	}
+
+	var best *models.Node
+	bestCost, bestDepth := -1, 0
+	var walk func(n *models.Node, path []string)
+	walk = func(n *models.Node, path []string) {
+		for _, c := range n.Children {
+			if c.Virtual {
+				continue
+			}
+			p := append(path[:len(path):len(path)], strings.ToLower(c.Name))
+			if cost, ok := pathCost(words, p); ok && (bestCost < 0 || cost < bestCost || cost == bestCost && len(p) < bestDepth) {
+				best, bestCost, bestDepth = c, cost, len(p)
+			}
+			walk(c, p)
+		}
+	}
+	walk(root, nil)
+	return best
+}
+
+// pathCost matches the query words to the words of path in order and
+// returns the letters and words of path left unmatched, 0 for the exact
+// path. It reports false when a query word matches no path word left.
+func pathCost(words, path []string) (int, bool) {
+	cost, i := 0, 0
+	for _, w := range words {
+		for {
+			if i == len(path) {
+				return 0, false
+			}
+			if left, ok := wordCost(w, path[i]); ok {
+				cost += left
+				i++
+				break
+			}
+			cost += len(path[i]) + 1 // a word skipped
+			i++
+		}
+	}
+	for _, p := range path[i:] {
+		cost += len(p) + 1
+	}
+	return cost, true
+}
+
+// wordCost reports whether the letters of q appear in w in order, and how
+// many letters of w are left over, one more when w does not start with q's
+// first letter.
+func wordCost(q, w string) (int, bool) {
+	j := 0
+	for k := 0; k < len(w) && j < len(q); k++ {
+		if w[k] == q[j] {
+			j++
+		}
+	}
+	if j < len(q) {
+		return 0, false
+	}
+	left := len(w) - len(q)
+	if !strings.HasPrefix(w, q[:1]) {
+		left++
+	}
+	return left, true
+}
+
+// gotoCommand moves the cursor to the command query names, expanding the
+// commands above it. It reports false when no command matches.
+func (m *Model) gotoCommand(query string) bool {
+	node := gotoTarget(m.root, query)
+	if node == nil {
+		return false
+	}
+	if !m.tree.Reveal(node) {
+		m.statusMsg = node.FullCommand() + " is hidden by the filter"
+		return true
+	}
+	m.syncSelected()
+	m.statusMsg = "goto: " + node.FullCommand()
+	return true
+}
@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- CLI header ----------
+
+// CLIInfo describes the executable the explored tree was discovered from.
+// The breadcrumb bar shows it at its right.
+type CLIInfo struct {
+	Path    string // the executable the CLI resolves to, "" when unknown
+	Version string // its version line (see cache.CLIVersion), "" when unknown
+	// Stale reports that the tree came from the cache though the
+	// executable was upgraded or replaced since, so it may no longer match
+	// the CLI installed. Missing reports that the CLI is not on PATH.
+	Stale, Missing bool
+}
+
+// SetCLIInfo sets what the breadcrumb bar shows of the executable the tree
+// was discovered from. A stale tree or missing CLI is also reported in the
+// status bar when the TUI starts, with the key that rediscovers a stale one
+// when SetReloader was called first.
+func (m *Model) SetCLIInfo(info CLIInfo) {
+	m.cliInfo = info
+	cli := m.root.Name
+	if len(m.root.FullPath) > 0 {
+		cli = m.root.FullPath[0]
+	}
+	switch {
+	case info.Missing:
+		m.statusMsg = cli + " is not on PATH; this is the tree cached for it"
+	case info.Stale:
+		m.statusMsg = cli + " has changed since this tree was cached; it may be out of date"
+		if m.reloader != nil {
+			m.statusMsg += " (Ctrl+R rediscovers it)"
+		}
+	}
+}
+
+// cliHeader renders the CLI's executable and version for the right of the
+// breadcrumb bar, in at most width columns, or "" when neither is known.
+// A warning that the tree is stale, with the key to rediscover it, or that
+// the CLI is missing or being rediscovered goes first and is kept whole;
+// the path and version are cut to fit.
+func (m *Model) cliHeader(width int) string {
+	var text []string
+	if m.cliInfo.Path != "" {
+		text = append(text, m.cliInfo.Path)
+	}
+	if v := m.cliInfo.Version; v != "" && v != "unknown" {
+		text = append(text, v)
+	}
+	warn := ""
+	switch {
+	case m.reloading:
+		warn = "rediscovering…"
+	case m.cliInfo.Missing:
+		warn = "⚠ not on PATH"
+	case m.cliInfo.Stale && m.reloader != nil:
+		warn = "⚠ stale cache · Ctrl+R refresh"
+	case m.cliInfo.Stale:
+		warn = "⚠ stale cache"
+	}
+	if warn == "" && len(text) == 0 {
+		return ""
+	}
+	dim := lipgloss.NewStyle().Faint(!m.cfg.Accessible)
+	warnStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(m.cfg.Colors.Invalid))
+	header := ""
+	if warn != "" {
+		header = warnStyle.Render(warn)
+		width -= lipgloss.Width(warn) + 2
+		if len(text) > 0 && width > 0 {
+			header += "  "
+		}
+	}
+	if len(text) > 0 && width > 0 {
+		header += dim.Render(truncateWidth(strings.Join(text, " · "), width))
+	}
+	return header
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/aallbrig/treemand/config"
 	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/render"
 )
 
 type helpMode int
@@ -32,6 +33,7 @@ type HelpPaneModel struct {
 	selFlag       *models.Flag
 	selPositional *models.Positional
 	selOwner      *models.Node
+	notes         map[string]string // the user's notes, by full command
 }
 
 func NewHelpPaneModel(cfg *config.Config) *HelpPaneModel {
@@ -70,6 +72,12 @@ func (h *HelpPaneModel) SetPositionalContext(p *models.Positional, owner *models
 	h.rebuildLines()
 }
 
+// SetNotes sets the notes shown above the help of the commands they are on.
+func (h *HelpPaneModel) SetNotes(notes map[string]string) {
+	h.notes = notes
+	h.rebuildLines()
+}
+
 func (h *HelpPaneModel) SetSize(w, hi int) {
 	h.width = w
 	h.height = hi
@@ -211,7 +219,7 @@ func (h *HelpPaneModel) View(w, hi int) string {
 	}
 
 	boxStyle := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
+		Border(boxBorder(h.cfg)).
 		BorderForeground(borderColor).
 		Width(w - 2).
 		Height(hi - 2)
@@ -276,6 +284,9 @@ func (h *HelpPaneModel) rebuildFlagLines() {
 		vt = "bool"
 	}
 	sb.WriteString("Type: " + vt + "\n")
+	if f.Repeatable {
+		sb.WriteString("Repeatable: yes\n")
+	}
 	if f.Description != "" {
 		sb.WriteString("Description: " + f.Description + "\n")
 	}
@@ -317,6 +328,14 @@ func (h *HelpPaneModel) rebuildNodeLines() {
 
 	var sb strings.Builder
 
+	if h.node.Stub {
+		sb.WriteString("Not yet discovered — select it in the tree to expand.\n\n")
+	}
+
+	if note := h.notes[h.node.FullCommand()]; note != "" {
+		sb.WriteString("Note: " + note + "\n\n")
+	}
+
 	if h.node.Description != "" {
 		sb.WriteString(h.node.Description + "\n\n")
 	}
@@ -330,7 +349,10 @@ func (h *HelpPaneModel) rebuildNodeLines() {
 			} else if f.ShortName != "" {
 				name += ", " + f.ShortName
 			}
-			if f.ValueType != "" && f.ValueType != "bool" {
+			switch {
+			case h.cfg.Accessible:
+				name += " " + f.TypeTag()
+			case f.TakesValue():
 				name += " <" + f.ValueType + ">"
 			}
 			line := "  " + name
@@ -356,7 +378,7 @@ func (h *HelpPaneModel) rebuildNodeLines() {
 
 	if len(h.node.Children) > 0 {
 		sb.WriteString("Subcommands:\n")
-		for _, child := range h.node.Children {
+		for _, child := range render.SortNodes(h.node.Children, h.cfg.Sort) {
 			line := "  " + child.Name
 			if child.Description != "" {
 				line += "  " + child.Description
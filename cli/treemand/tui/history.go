@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- flag value history and suggestions ----------
+
+// ValueHistory remembers the values entered for a CLI's flags so the value
+// prompts can suggest them again. cache.ValueHistory implements it for one
+// CLI.
+type ValueHistory interface {
+	FlagValues(flag string) []string
+	AddFlagValue(flag, value string)
+}
+
+// maxSuggestions is how many values a value prompt lists.
+const maxSuggestions = 8
+
+// suggestList is the dropdown of remembered values under a value prompt,
+// followed by any values the CLI's completion hook returned (see
+// completion.go). cursor is an index into the values matching the typed
+// text, or -1 when none is highlighted and Enter confirms the typed text.
+type suggestList struct {
+	values []string
+	live   []string
+	cursor int
+}
+
+// SetValueHistory sets the store flag values are remembered in and
+// suggested from. A nil history disables suggestions.
+func (m *Model) SetValueHistory(h ValueHistory) {
+	m.history = h
+}
+
+// suggestionsFor returns the dropdown for flag's value prompt.
+func (m *Model) suggestionsFor(flag string) suggestList {
+	s := suggestList{cursor: -1}
+	if m.history != nil {
+		s.values = m.history.FlagValues(flag)
+	}
+	return s
+}
+
+// rememberValue records value for flag in the history.
+func (m *Model) rememberValue(flag, value string) {
+	if m.history != nil && value != "" {
+		m.history.AddFlagValue(flag, value)
+	}
+}
+
+// matches returns the values containing typed: remembered ones, most used
+// first, then completed ones.
+func (s suggestList) matches(typed string) []string {
+	typed = strings.ToLower(strings.TrimSpace(typed))
+	var out []string
+	seen := make(map[string]bool)
+	for _, v := range append(s.values[:len(s.values):len(s.values)], s.live...) {
+		if lv := strings.ToLower(v); !seen[v] && lv != typed && strings.Contains(lv, typed) {
+			seen[v] = true
+			out = append(out, v)
+		}
+		if len(out) == maxSuggestions {
+			break
+		}
+	}
+	return out
+}
+
+// update handles the dropdown keys: ↑/↓ highlight a suggestion and Tab
+// copies the highlighted (or first) one into input. Typing clears the
+// highlight. It reports whether the key was consumed.
+func (s *suggestList) update(key string, input *textinput.Model) bool {
+	matches := s.matches(input.Value())
+	switch key {
+	case "down", "ctrl+n":
+		if len(matches) > 0 {
+			s.cursor = min(s.cursor+1, len(matches)-1)
+		}
+		return true
+	case "up", "ctrl+p":
+		s.cursor = max(s.cursor-1, -1)
+		return true
+	case "tab":
+		if len(matches) > 0 {
+			input.SetValue(matches[max(s.cursor, 0)])
+			input.CursorEnd()
+			s.cursor = -1
+		}
+		return true
+	}
+	s.cursor = -1
+	return false
+}
+
+// chosen returns the highlighted suggestion, if any.
+func (s suggestList) chosen(typed string) (string, bool) {
+	matches := s.matches(typed)
+	if s.cursor < 0 || s.cursor >= len(matches) {
+		return "", false
+	}
+	return matches[s.cursor], true
+}
+
+// view renders the dropdown lines for the current input, or "" when there
+// is nothing to suggest.
+func (s suggestList) view(typed string, width int) string {
+	matches := s.matches(typed)
+	if len(matches) == 0 {
+		return ""
+	}
+	hintStyle := lipgloss.NewStyle().Faint(true)
+	selStyle := lipgloss.NewStyle().Background(lipgloss.Color("#264F78")).Bold(true)
+	lines := []string{hintStyle.Render("suggestions (↑↓ select · Tab fill)")}
+	for i, v := range matches {
+		row := "  " + truncateWidth(v, max(1, width-2))
+		if i == s.cursor {
+			row = selStyle.Render(row + strings.Repeat(" ", max(0, width-lipgloss.Width(row))))
+		}
+		lines = append(lines, row)
+	}
+	return strings.Join(lines, "\n")
+}
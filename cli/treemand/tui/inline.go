@@ -0,0 +1,294 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- inline editing ----------
+
+// typedWord reads the preview's text left of the cursor against the tree:
+// the command named before the word being typed, and that word, partial,
+// which is "" after a space. node is nil when the text is not a command of
+// the tree, or the word is not a subcommand or flag of it: a flag's value,
+// an argument, or a word after a pipe.
+func (m *Model) typedWord() (node *models.Node, partial string) {
+	text, _ := m.preview.typed()
+	words := splitWords(text)
+	if len(words) == 0 || slices.ContainsFunc(words, isOperator) {
+		return nil, ""
+	}
+	if !strings.HasSuffix(text, " ") {
+		partial = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+	node, args := resolveCommand(m.root, words)
+	if node == nil {
+		return nil, ""
+	}
+	// Read a word of the partial's shape in its place, to tell whether a
+	// flag or subcommand may go there.
+	probe := "x"
+	if strings.HasPrefix(partial, "-") {
+		probe = "-x"
+	}
+	ws := classifyWords(append(slices.Clip(words), probe), m.root)
+	switch ws[len(ws)-1].kind {
+	case wordFlag, wordSubcommand:
+		return node, partial
+	case wordArg:
+		// A word naming no subcommand, which one may still be typed in
+		// place of before any argument.
+		if len(args) == 0 && !slices.Contains(words, "--") {
+			return node, partial
+		}
+	}
+	return nil, ""
+}
+
+// followPreview moves the tree's cursor to what is typed in the focused
+// preview: the flag or subcommand the word being typed names, or the first
+// one it begins, else the command named so far.
+func (m *Model) followPreview() {
+	node, partial := m.typedWord()
+	if node == nil {
+		return
+	}
+	moved := false
+	switch {
+	case strings.HasPrefix(partial, "-"):
+		name, _, _ := strings.Cut(partial, "=")
+		known := knownFlags(node, m.root)
+		f := lookupFlag(known, name)
+		if f == nil {
+			if names := flagCompletions(known, name); len(names) > 0 {
+				f = lookupFlag(known, names[0])
+			}
+		}
+		if f != nil {
+			owner := node
+			if lookupFlag(node.Flags, f.Name) == nil {
+				owner = m.root
+			}
+			moved = m.tree.RevealFlag(owner, f.Name)
+		}
+	case partial != "":
+		if names := subcommandCompletions(node, partial); len(names) > 0 {
+			moved = m.tree.Reveal(findCommand(node, names[0]))
+		}
+	}
+	if moved || m.tree.Reveal(node) {
+		m.syncSelected()
+	}
+}
+
+// completePreview completes the word being typed at the end of the focused
+// preview against the subcommands and flags of the command named before
+// it: whole when one matches, else as far as those matching agree, listing
+// them in the status bar. It reports false when nothing matches, leaving
+// Tab to move the focus.
+func (m *Model) completePreview() bool {
+	if _, atEnd := m.preview.typed(); !atEnd {
+		return false
+	}
+	node, partial := m.typedWord()
+	if node == nil || partial == "" {
+		return false
+	}
+	var names []string
+	if strings.HasPrefix(partial, "-") {
+		if strings.Contains(partial, "=") {
+			return false
+		}
+		names = flagCompletions(knownFlags(node, m.root), partial)
+	} else {
+		names = subcommandCompletions(node, partial)
+	}
+	switch len(names) {
+	case 0:
+		return false
+	case 1:
+		m.preview.completeWord(partial, names[0]+" ")
+		m.statusMsg = ""
+	default:
+		m.preview.completeWord(partial, commonPrefix(names))
+		m.statusMsg = "matches: " + strings.Join(names, " ")
+	}
+	m.previewEdited()
+	return true
+}
+
+// previewEdited brings what follows the focused preview's text up to date:
+// the tree's highlighting and cursor, and the word popup.
+func (m *Model) previewEdited() {
+	m.tree.SetCmdTokens(m.preview.Tokens())
+	m.followPreview()
+	m.refreshWordPopup()
+}
+
+// wordPopup is the popup under the focused preview listing the subcommands
+// and flags the word being typed may complete to, or after a space, the
+// subcommands the command typed has. cursor is an index into items, or -1
+// when none is highlighted.
+type wordPopup struct {
+	items   []wordSuggestion
+	partial string // the word typed
+	col     int    // the column the word starts at in the preview's text
+	cursor  int
+}
+
+// wordSuggestion is a subcommand or flag the word popup lists.
+type wordSuggestion struct {
+	name, desc string
+}
+
+// refreshWordPopup lists in the popup what the word being typed may
+// complete to. There is no popup when the word is complete, or when the
+// cursor is not at the end of the line.
+func (m *Model) refreshWordPopup() {
+	m.wp = wordPopup{cursor: -1}
+	text, atEnd := m.preview.typed()
+	node, partial := m.typedWord()
+	if node == nil || !atEnd {
+		return
+	}
+	var items []wordSuggestion
+	switch {
+	case strings.HasPrefix(partial, "-"):
+		if strings.Contains(partial, "=") {
+			return
+		}
+		known := knownFlags(node, m.root)
+		for _, name := range flagCompletions(known, partial) {
+			items = append(items, wordSuggestion{name, lookupFlag(known, name).Description})
+		}
+	default:
+		for _, name := range subcommandCompletions(node, partial) {
+			items = append(items, wordSuggestion{name, findCommand(node, name).Description})
+		}
+	}
+	if len(items) == 0 || len(items) == 1 && items[0].name == partial {
+		return
+	}
+	m.wp.items, m.wp.partial = items, partial
+	m.wp.col = lipgloss.Width(text) - lipgloss.Width(partial)
+}
+
+// updateWordPopup handles the popup's keys: ↓/↑ highlight an item, Tab or
+// Enter puts the highlighted one in place of the word typed, and Esc closes
+// the popup. Without a highlighted item Tab completes as completePreview
+// does. It reports whether the key was consumed.
+func (m *Model) updateWordPopup(key string) bool {
+	if len(m.wp.items) == 0 {
+		return false
+	}
+	switch key {
+	case "down", "ctrl+n":
+		m.wp.cursor = min(m.wp.cursor+1, len(m.wp.items)-1)
+	case "up", "ctrl+p":
+		m.wp.cursor = max(m.wp.cursor-1, -1)
+	case "esc":
+		m.wp = wordPopup{}
+	case "tab", "enter":
+		if m.wp.cursor < 0 {
+			return false
+		}
+		m.preview.completeWord(m.wp.partial, m.wp.items[m.wp.cursor].name+" ")
+		m.previewEdited()
+	default:
+		return false
+	}
+	return true
+}
+
+// renderWordPopup renders the word popup, scrolled to keep the highlighted
+// item in view, and returns it with the column it goes at: under the word
+// typed, moved left as far as it must to fit. It returns "" when there is no
+// popup.
+func (m *Model) renderWordPopup() (string, int) {
+	if len(m.wp.items) == 0 || m.focusedPane != panePreview || m.plain {
+		return "", 0
+	}
+	hintStyle := lipgloss.NewStyle().Faint(true)
+	selStyle := lipgloss.NewStyle().Background(lipgloss.Color("#264F78")).Bold(true)
+	title := fmt.Sprintf("%d matches (↑↓ select · Tab fill)", len(m.wp.items))
+	nameW, innerW := 0, lipgloss.Width(title)
+	for _, it := range m.wp.items {
+		nameW = max(nameW, lipgloss.Width(it.name))
+	}
+	for _, it := range m.wp.items {
+		innerW = max(innerW, nameW+2+lipgloss.Width(it.desc))
+	}
+	innerW = max(1, min(min(innerW, 60), m.width-4))
+	start := max(0, m.wp.cursor-maxSuggestions+1)
+	end := min(len(m.wp.items), start+maxSuggestions)
+	lines := []string{hintStyle.Render(truncateWidth(title, innerW))}
+	for i := start; i < end; i++ {
+		it := m.wp.items[i]
+		if i == m.wp.cursor {
+			row := truncateWidth(fmt.Sprintf("%-*s  %s", nameW, it.name, it.desc), innerW)
+			lines = append(lines, selStyle.Render(row+strings.Repeat(" ", max(0, innerW-lipgloss.Width(row)))))
+			continue
+		}
+		lines = append(lines, truncateWidth(fmt.Sprintf("%-*s  %s", nameW, it.name, hintStyle.Render(it.desc)), innerW))
+	}
+	box := lipgloss.NewStyle().
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(0, 1).
+		Render(strings.Join(lines, "\n"))
+	x := min(m.preview.textStart()+m.wp.col, m.width-lipgloss.Width(box))
+	return box, max(0, x)
+}
+
+// subcommandCompletions returns the names of node's subcommands that start
+// with prefix, looking through virtual group nodes.
+func subcommandCompletions(node *models.Node, prefix string) []string {
+	var names []string
+	for _, c := range node.Children {
+		switch {
+		case c.Virtual:
+			names = append(names, subcommandCompletions(c, prefix)...)
+		case strings.HasPrefix(c.Name, prefix):
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// flagCompletions returns the names of flags that start with prefix: long
+// names, negated ones (--no-color) and short ones, each once.
+func flagCompletions(flags []models.Flag, prefix string) []string {
+	var names []string
+	add := func(name string) {
+		if name != "" && strings.HasPrefix(name, prefix) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, f := range flags {
+		add(f.Name)
+		if f.Negatable {
+			add(f.NegatedName())
+		}
+		if f.ShortName != "" {
+			add("-" + f.ShortName)
+		}
+	}
+	return names
+}
+
+// commonPrefix returns the longest prefix the names share.
+func commonPrefix(names []string) string {
+	prefix := names[0]
+	for _, n := range names[1:] {
+		for !strings.HasPrefix(n, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,193 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- key map ----------
+
+// keyBinding is one entry of the ? overlay: the keys and what they do.
+type keyBinding struct {
+	keys string // in the arrows scheme, and in the others unless set below
+	vim  string // in the vim scheme, when they differ
+	wasd string // in the WASD scheme, when they differ
+	desc string
+}
+
+// keysIn returns the keys of b in scheme s.
+func (b keyBinding) keysIn(s NavScheme) string {
+	switch {
+	case s == SchemeVim && b.vim != "":
+		return b.vim
+	case s == SchemeWASD && b.wasd != "":
+		return b.wasd
+	}
+	return b.keys
+}
+
+// keyGroup is a titled group of key bindings, those of a pane, a modal or
+// a feature.
+type keyGroup struct {
+	title    string
+	schemes  []NavScheme // the schemes the group applies in; nil for all
+	bindings []keyBinding
+}
+
+// keymap lists the key bindings the ? overlay shows.
+var keymap = []keyGroup{
+	{title: "Navigation", bindings: []keyBinding{
+		{keys: "↑ / ↓", vim: "k / j", wasd: "w / s", desc: "Move up / down"},
+		{keys: "→", vim: "l", wasd: "d", desc: "Expand node; enter children (2nd press)"},
+		{keys: "←", vim: "h", wasd: "a", desc: "Collapse node; go to parent (2nd press)"},
+		{keys: "Shift+→", vim: "L", desc: "Expand entire subtree"},
+		{keys: "Shift+←", vim: "H", desc: "Collapse entire subtree"},
+		{keys: "gg / Home", desc: "Jump to top"},
+		{keys: "G / End", desc: "Jump to bottom"},
+		{keys: "PgUp / PgDn", desc: "Page up / down"},
+		{keys: "5↓, 20G", vim: "5j, 20G", wasd: "5s, 20G", desc: "Count: move 5 rows, go to row 20"},
+	}},
+	{title: "Vim Scheme", schemes: []NavScheme{SchemeVim}, bindings: []keyBinding{
+		{keys: "Ctrl+D / Ctrl+U", desc: "Half a page down / up"},
+		{keys: "Ctrl+F / Ctrl+B", desc: "Page down / up"},
+		{keys: "zz / zt / zb", desc: "Scroll the cursor row to the center / top / bottom"},
+		{keys: "za", desc: "Expand / collapse node"},
+		{keys: "zR / zM", desc: "Expand all / collapse all"},
+	}},
+	{title: "Tree", bindings: []keyBinding{
+		{keys: "/", desc: `Filter (regex; a,b = either; "remote add" = full path)`},
+		{keys: "n / N", desc: "Next / previous search match"},
+		{keys: "e / E", desc: "Expand all / collapse all"},
+		{keys: "S", desc: "Toggle section headers"},
+		{keys: "c", desc: "Toggle commands only (hide flag and positional rows)"},
+		{keys: "[ / ]", desc: "Scroll long rows left / right"},
+		{keys: "p", desc: "Toggle full command paths (git remote add) instead of indentation"},
+		{keys: "T", desc: "Cycle display style (default → columns → compact → graph)"},
+		{keys: "o", desc: "Cycle sort order (none → name → discovered → flags → used)"},
+		{keys: "R", desc: "Re-discover selected node (refresh children)"},
+		{keys: "Ctrl+R", desc: "Rediscover the whole tree in the background and swap it in (for a stale cache)"},
+		{keys: "P", desc: "Pin the selected command (again to unpin)"},
+		{keys: "C", desc: "Compare the pinned command with the selected one side by side"},
+	}},
+	{title: "Building Commands", bindings: []keyBinding{
+		{keys: "Enter", desc: "Set command / add flag / fill positional"},
+		{keys: "f / F", desc: "Open the flag picker"},
+		{keys: "Backspace", desc: "Remove last token from preview"},
+		{keys: "Ctrl+K", desc: "Clear entire preview bar"},
+		{keys: "Ctrl+E", desc: "Copy or execute the assembled command, filling missing positionals and {{name}} placeholders first"},
+	}},
+	{title: "Execute Modal (Ctrl+E)", bindings: []keyBinding{
+		{keys: "Enter / r", desc: "Run the command, leaving treemand"},
+		{keys: "t", desc: "Run it in a new tmux, kitty or WezTerm split, keeping treemand open"},
+		{keys: "c", desc: "Copy it to the clipboard"},
+		{keys: "yes Enter", desc: "Run a command matching danger_patterns (Ctrl+T in a split, Ctrl+Y copies)"},
+		{keys: "Esc", desc: "Cancel"},
+	}},
+	{title: "Flag Picker (f)", bindings: []keyBinding{
+		{keys: "type", desc: "Filter flags by name or description"},
+		{keys: "↑ / ↓", desc: "Move"},
+		{keys: "Space", desc: "Mark several flags"},
+		{keys: "Enter", desc: "Add the flag, or the marked ones, prompting for values"},
+		{keys: "Esc", desc: "Clear the filter; again to close"},
+	}},
+	{title: "View", bindings: []keyBinding{
+		{keys: "H / Ctrl+P", vim: "? / Ctrl+P", desc: "Toggle help pane"},
+		{keys: "Tab / Shift+Tab", desc: "Cycle pane focus"},
+		{keys: "< / >", desc: "Narrow / widen the tree pane (or drag the divider)"},
+		{keys: "z", vim: "Z", desc: "Zoom: focused pane fills the screen (again to restore)"},
+		{keys: "d / D", wasd: "D", desc: "Open docs URL in browser"},
+		{keys: "m", desc: "View raw --help output (full-screen pager)"},
+		{keys: "Ctrl+N", desc: "Write a note on the selected command (shown in the help pane)"},
+		{keys: "Ctrl+S", desc: "Cycle navigation scheme (arrows → vim → WASD)"},
+		{keys: "? / F1", vim: "F1 / :help", desc: "Show this help"},
+	}},
+	{title: "Help Pane (Tab to focus)", bindings: []keyBinding{
+		{keys: "↑ / ↓, k / j", desc: "Scroll"},
+		{keys: "PgUp / PgDn", desc: "Page up / down"},
+		{keys: "g / G", desc: "Jump to top / bottom"},
+	}},
+	{title: "Preview (Tab to focus)", bindings: []keyBinding{
+		{keys: "type", desc: "Edit the command; the tree follows the subcommand or flag being typed"},
+		{keys: "Tab", desc: "Complete the subcommand or flag being typed (else focus the tree)"},
+		{keys: "↓ / ↑", desc: "Highlight a subcommand or flag in the popup of matches; Tab or Enter puts it in, Esc closes the popup"},
+		{keys: "Ctrl+E", desc: "Copy or execute it"},
+		{keys: "Esc", desc: "Back to the tree"},
+	}},
+	{title: "Command Line (:)", bindings: []keyBinding{
+		{keys: ":goto PATH", desc: `Jump to a command, expanding the way there: "remote add", "git/remote/add" or fuzzily "rem ad" (:remote add works too)`},
+		{keys: ":save NAME", desc: "Save the command in the preview as a snippet; {{name}} in it is a placeholder filled in when it is loaded"},
+		{keys: ":snippets", desc: "List snippets: Enter loads one into the preview, x deletes"},
+		{keys: ":runs", desc: "Commands run with record_runs on: Enter shows the output, p loads the command into the preview"},
+		{keys: ":env", desc: "Environment variables (AWS_PROFILE=dev) the commands Ctrl+E runs or copies get: a adds, Enter edits, x deletes"},
+		{keys: ":messages", desc: "Recent status messages"},
+		{keys: ":help", desc: "Show this help"},
+		{keys: ":plain", desc: "Toggle the plain view: the selection, the command and its help as plain lines, for screen readers"},
+		{keys: ":tour", desc: "Show the guided tour (t in this help too)"},
+		{keys: ":q", desc: "Quit"},
+	}},
+	{title: "Tabs (treemand -i --tabs git kubectl)", bindings: []keyBinding{
+		{keys: "{ / }", desc: "Previous / next tab"},
+		{keys: "click", desc: "Switch to the tab clicked in the tab bar"},
+	}},
+	{title: "Edit Mode (treemand edit <cli>; changes are saved to the CLI's override file)", bindings: []keyBinding{
+		{keys: ":rename NAME", desc: "Rename the selected command or flag"},
+		{keys: ":describe TEXT", desc: "Set its description"},
+		{keys: ":delete", desc: "Delete it"},
+		{keys: ":add command NAME", desc: "Add a subcommand to the selected command"},
+		{keys: ":add flag NAME [TYPE]", desc: "Add a flag to it, taking a TYPE value if given"},
+	}},
+	{title: "Quit", bindings: []keyBinding{
+		{keys: "q / Esc", desc: "Quit"},
+	}},
+}
+
+// keymapLines lays out the key bindings of scheme s that match query out
+// in width columns: each group's title, then its bindings, the keys in a
+// column and the descriptions wrapped beside them. A query matches the
+// bindings whose keys or description hold it, ignoring case, and all those
+// of a group whose title does.
+func keymapLines(s NavScheme, query string, width int) []string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	query = strings.ToLower(query)
+	var lines []string
+	for _, g := range keymap {
+		if g.schemes != nil && !slices.Contains(g.schemes, s) {
+			continue
+		}
+		all := strings.Contains(strings.ToLower(g.title), query)
+		var bindings []keyBinding
+		keyW := 0
+		for _, b := range g.bindings {
+			if all || strings.Contains(strings.ToLower(b.keysIn(s)+"\n"+b.desc), query) {
+				bindings = append(bindings, b)
+				keyW = max(keyW, lipgloss.Width(b.keysIn(s)))
+			}
+		}
+		if len(bindings) == 0 {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, titleStyle.Render(g.title))
+		keyW = min(keyW, width/3)
+		for _, b := range bindings {
+			keys := b.keysIn(s)
+			desc := wordWrap(b.desc, max(1, width-keyW-4))
+			if lipgloss.Width(keys) > keyW {
+				// Keys too long for the column get a line of their own.
+				lines = append(lines, "  "+keys)
+			} else {
+				desc[0] = keys + strings.Repeat(" ", keyW-lipgloss.Width(keys)) + "  " + desc[0]
+				lines = append(lines, "  "+desc[0])
+				desc = desc[1:]
+			}
+			for _, d := range desc {
+				lines = append(lines, strings.Repeat(" ", keyW+4)+d)
+			}
+		}
+	}
+	return lines
+}
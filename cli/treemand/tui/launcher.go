@@ -0,0 +1,191 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aallbrig/treemand/config"
+)
+
+// ---------- launcher ----------
+
+// LauncherEntry is a CLI the launcher lists.
+type LauncherEntry struct {
+	CLI      string
+	Version  string
+	Commands int
+	Flags    int
+	UsedAt   time.Time // when its tree was last loaded
+}
+
+// Launcher is the screen treemand shows when run without a CLI: the cached
+// CLIs, to pick one to explore. Typing filters them by name; Enter picks
+// the selected one, or the name typed when none matches it, for a CLI
+// that is not cached yet.
+type Launcher struct {
+	entries []LauncherEntry
+	visible []int // indices into entries matching the filter
+	cursor  int   // index into visible
+	input   textinput.Model
+	cfg     *config.Config
+	width   int
+	height  int
+	chosen  string
+}
+
+// NewLauncher creates a launcher listing entries in the order given.
+func NewLauncher(entries []LauncherEntry, cfg *config.Config) *Launcher {
+	input := textinput.New()
+	input.Placeholder = "type to filter, or a CLI's name…"
+	input.CharLimit = 64
+	input.Focus()
+	l := &Launcher{entries: entries, input: input, cfg: cfg}
+	l.refilter()
+	return l
+}
+
+// RunLauncher shows the launcher and returns the CLI picked, or "" when it
+// was quit.
+func RunLauncher(entries []LauncherEntry, cfg *config.Config) (string, error) {
+	l := NewLauncher(entries, cfg)
+	if _, err := tea.NewProgram(l, tea.WithAltScreen()).Run(); err != nil {
+		return "", err
+	}
+	return l.Chosen(), nil
+}
+
+// Chosen returns the CLI picked, or "" when none was.
+func (l *Launcher) Chosen() string { return l.chosen }
+
+func (l *Launcher) Init() tea.Cmd { return nil }
+
+// Update handles msg. It returns tea.Quit once a CLI is picked or the
+// launcher is quit.
+func (l *Launcher) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		l.width, l.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return l, tea.Quit
+		case "esc":
+			if l.input.Value() == "" {
+				return l, tea.Quit
+			}
+			l.input.SetValue("")
+			l.refilter()
+		case "up", "ctrl+p":
+			l.cursor = max(l.cursor-1, 0)
+		case "down", "ctrl+n":
+			l.cursor = max(min(l.cursor+1, len(l.visible)-1), 0)
+		case "enter":
+			if l.cursor < len(l.visible) {
+				l.chosen = l.entries[l.visible[l.cursor]].CLI
+			} else if name := strings.TrimSpace(l.input.Value()); name != "" && !strings.ContainsAny(name, " \t") {
+				l.chosen = name
+			}
+			if l.chosen != "" {
+				return l, tea.Quit
+			}
+		default:
+			var cmd tea.Cmd
+			l.input, cmd = l.input.Update(msg)
+			l.refilter()
+			return l, cmd
+		}
+	}
+	return l, nil
+}
+
+// refilter lists the entries whose name holds the typed text, ignoring
+// case, and keeps the cursor within them.
+func (l *Launcher) refilter() {
+	q := strings.ToLower(strings.TrimSpace(l.input.Value()))
+	l.visible = l.visible[:0]
+	for i, e := range l.entries {
+		if strings.Contains(strings.ToLower(e.CLI), q) {
+			l.visible = append(l.visible, i)
+		}
+	}
+	l.cursor = max(0, min(l.cursor, len(l.visible)-1))
+}
+
+func (l *Launcher) View() string {
+	if l.width == 0 || l.height == 0 {
+		return ""
+	}
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	hintStyle := lipgloss.NewStyle().Faint(true)
+	selStyle := lipgloss.NewStyle().Reverse(true)
+	innerW := max(1, l.width-4)
+
+	nameW, versionW := 0, 0
+	for _, i := range l.visible {
+		nameW = max(nameW, lipgloss.Width(l.entries[i].CLI))
+		versionW = max(versionW, lipgloss.Width(l.entries[i].Version))
+	}
+	var rows []string
+	for n, i := range l.visible {
+		e := l.entries[i]
+		row := fmt.Sprintf("%-*s  %-*s  %s", nameW, e.CLI, versionW, e.Version,
+			hintStyle.Render(fmt.Sprintf("%d commands · %d flags · used %s", e.Commands, e.Flags, usedAgo(time.Since(e.UsedAt)))))
+		if n == l.cursor {
+			row = selStyle.Render("▶ ") + row
+		} else {
+			row = "  " + row
+		}
+		rows = append(rows, clipRow(row, 0, innerW))
+	}
+	if len(rows) == 0 {
+		msg := "no CLIs cached yet; type a CLI's name and press Enter to explore it"
+		if name := strings.TrimSpace(l.input.Value()); name != "" {
+			msg = "no cached CLI matches; Enter explores " + name
+		} else if len(l.entries) > 0 {
+			msg = "no cached CLI matches"
+		}
+		rows = []string{hintStyle.Render(msg)}
+	}
+
+	header := []string{
+		titleStyle.Render("treemand") + "  " + hintStyle.Render("recently used CLIs"),
+		hintStyle.Render(clipRow("type to filter · ↑↓ select · Enter explore · Esc quit", 0, innerW)),
+		"",
+		"> " + l.input.View(),
+		"",
+	}
+	// Scroll so the cursor stays in view.
+	vp := max(1, l.height-2-len(header))
+	start := max(0, l.cursor-vp+1)
+	end := min(len(rows), start+vp)
+	lines := append(header, rows[start:end]...)
+	for len(lines) < l.height-2 {
+		lines = append(lines, "")
+	}
+	return lipgloss.NewStyle().
+		Border(boxBorder(l.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(0, 1).
+		Width(max(1, l.width-2)).
+		Render(strings.Join(lines, "\n"))
+}
+
+// usedAgo describes how long ago something was used: "just now", "5m ago",
+// "3h ago", "2d ago".
+func usedAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
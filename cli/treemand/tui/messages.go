@@ -0,0 +1,191 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- status message history and :messages overlay ----------
+
+// maxMessages is how many status messages the :messages overlay keeps.
+const maxMessages = 100
+
+// statusEntry is one status bar message and when it was posted.
+type statusEntry struct {
+	at   time.Time
+	text string
+}
+
+// statusExpiredMsg is fired by a tea.Tick once a status message has been
+// shown for cfg.StatusMsgTimeout, so the status bar redraws without it.
+type statusExpiredMsg struct{}
+
+// messagesModal is the :messages overlay listing recent status messages.
+type messagesModal struct {
+	active bool
+	offset int
+}
+
+// postStatus records text in the message history and shows it in the status
+// bar until cfg.StatusMsgTimeout has passed or a newer message replaces it.
+func (m *Model) postStatus(text string) tea.Cmd {
+	m.messages = append(m.messages, statusEntry{at: time.Now(), text: text})
+	if n := len(m.messages); n > maxMessages {
+		m.messages = m.messages[n-maxMessages:]
+	}
+	return tea.Tick(m.cfg.StatusMsgTimeout, func(time.Time) tea.Msg {
+		return statusExpiredMsg{}
+	})
+}
+
+// currentStatus returns the newest status message if it has not expired.
+func (m *Model) currentStatus() string {
+	if len(m.messages) == 0 {
+		return ""
+	}
+	last := m.messages[len(m.messages)-1]
+	if time.Since(last.at) >= m.cfg.StatusMsgTimeout {
+		return ""
+	}
+	return last.text
+}
+
+// Messages returns the recorded status messages, oldest first.
+func (m *Model) Messages() []string {
+	out := make([]string, len(m.messages))
+	for i, e := range m.messages {
+		out[i] = e.text
+	}
+	return out
+}
+
+// ---------- : command line ----------
+
+func newCommandLine() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = ":"
+	ti.CharLimit = 256 // room for a description in edit mode
+	return ti
+}
+
+func (m *Model) updateCommandLine(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.commanding = false
+		m.cmdline.Blur()
+		return m, nil
+	case "enter":
+		m.commanding = false
+		m.cmdline.Blur()
+		return m.runCommand(strings.TrimSpace(m.cmdline.Value()))
+	}
+	var cmd tea.Cmd
+	m.cmdline, cmd = m.cmdline.Update(msg)
+	return m, cmd
+}
+
+// runCommand executes a command entered at the : prompt. A line that is no
+// command is taken as a command path to go to, as with :goto.
+func (m *Model) runCommand(line string) (tea.Model, tea.Cmd) {
+	name, arg, _ := strings.Cut(line, " ")
+	switch name {
+	case "":
+	case "rename", "describe", "delete", "add":
+		m.runEditCommand(name, strings.TrimSpace(arg))
+	case "save":
+		m.saveSnippet(strings.TrimSpace(arg))
+	case "snippets":
+		m.openSnippets()
+	case "runs":
+		m.openRuns()
+	case "env":
+		m.openEnv()
+	case "goto", "g":
+		if arg = strings.TrimSpace(arg); arg == "" {
+			m.statusMsg = "usage: goto PATH"
+		} else if !m.gotoCommand(arg) {
+			m.statusMsg = "no command matches " + arg
+		}
+	case "plain":
+		m.togglePlain()
+	case "tour":
+		m.StartTour()
+	case "help", "h":
+		m.openKeybinds()
+	case "messages", "mes", "msgs":
+		m.msgs = messagesModal{active: true, offset: max(0, len(m.messages)-m.messagesViewport())}
+	case "q", "quit":
+		m.quitting = true
+		return m, tea.Quit
+	default:
+		if !m.gotoCommand(line) {
+			m.statusMsg = "unknown command: " + name
+		}
+	}
+	return m, nil
+}
+
+func (m *Model) updateMessagesModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc", "q", "enter":
+		m.msgs.active = false
+	case "up", "k":
+		m.msgs.offset--
+	case "down", "j":
+		m.msgs.offset++
+	case "pgup", "b", "ctrl+u":
+		m.msgs.offset -= m.messagesViewport()
+	case "pgdown", "ctrl+d":
+		m.msgs.offset += m.messagesViewport()
+	case "g", "home":
+		m.msgs.offset = 0
+	case "G", "end":
+		m.msgs.offset = len(m.messages)
+	}
+	m.msgs.offset = max(0, min(m.msgs.offset, len(m.messages)-m.messagesViewport()))
+	return m, nil
+}
+
+// messagesViewport is the number of history lines visible in the overlay.
+func (m *Model) messagesViewport() int {
+	return max(5, m.height-10)
+}
+
+func (m *Model) renderMessagesModal() string {
+	modalW := max(40, min(m.width-6, 80))
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	hintStyle := lipgloss.NewStyle().Faint(true)
+
+	var lines []string
+	for _, e := range m.messages {
+		line := hintStyle.Render(e.at.Format("15:04:05")) + "  " + e.text
+		lines = append(lines, clipRow(line, 0, modalW-6))
+	}
+	if len(lines) == 0 {
+		lines = []string{hintStyle.Render("no messages yet")}
+	}
+	vp := m.messagesViewport()
+	start := max(0, min(m.msgs.offset, len(lines)-vp))
+	end := min(len(lines), start+vp)
+
+	title := "Messages"
+	if len(lines) > vp {
+		title += fmt.Sprintf(" [%d-%d/%d]", start+1, end, len(lines))
+	}
+	content := titleStyle.Render(title) + "\n" +
+		hintStyle.Render("↑↓/jk scroll · Esc close") + "\n\n" +
+		strings.Join(lines[start:end], "\n")
+
+	box := lipgloss.NewStyle().
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(0, 2).
+		Width(modalW - 2).
+		Render(content)
+	return m.overlay(box)
+}
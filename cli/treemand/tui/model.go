@@ -5,13 +5,14 @@ import (
 	"os"
 	"os/exec"
 	"strings"
-	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/aallbrig/treemand/config"
+	"github.com/aallbrig/treemand/discovery"
 	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/override"
 	"github.com/aallbrig/treemand/render"
 )
 
@@ -43,49 +44,97 @@ type LazyExpandMsg struct {
 
 // executeModal is the Ctrl+E dialog for running or copying the built command.
 type executeModal struct {
-	active  bool
-	command string
+	active   bool
+	command  string
+	warnings []string // deprecated commands and flags the command uses
+	danger   string   // danger pattern the command matches; "" when it has none
+	confirm  textinput.Model
 }
 
 // valueInputModal is the inline value-entry dialog for flag/positional rows.
 type valueInputModal struct {
-	active bool
-	label  string // e.g. "--flag-name <string>"
-	prefix string // token prefix e.g. "--flag-name=" or ""
-	input  textinput.Model
-	owner  *models.Node // node this flag/positional belongs to
+	active  bool
+	label   string // e.g. "--flag-name <string>"
+	prefix  string // token prefix e.g. "--flag-name=" or ""
+	input   textinput.Model
+	owner   *models.Node // node this flag/positional belongs to
+	slot    bool         // true when filling a positional argument
+	chain   bool         // true when prompting for missing positionals before running
+	flag    string       // flag name the value is for; "" for positionals
+	argName string       // positional name the value is for; "" for flags
+	suggest suggestList  // remembered values for flag
+	// variable is the placeholder the value fills in, e.g. "namespace"
+	// for {{namespace}}; "" for flags and positionals.
+	variable string
 }
 
-// Model is the root Bubble Tea model.
+// Model is the root Bubble Tea model. It can be embedded in another Bubble
+// Tea program: forward messages to Update (it always returns the same
+// *Model), call SetSize when the space allotted to it changes, and read
+// CommandToRun after the user picks "Run". Update returns tea.Quit when the
+// user presses q; hosts that should outlive the explorer can intercept it.
 type Model struct {
-	root         *models.Node
-	cfg          *config.Config
-	scheme       NavScheme
-	tree         *TreeModel
-	preview      *PreviewModel
-	helpPane     *HelpPaneModel
-	showHelpPane bool
-	filter       textinput.Model
-	filtering    bool
-	focusedPane  pane
-	width        int
-	height       int
-	statusMsg    string
-	timedMsg     string    // shown for a fixed duration (e.g. style name on T press)
-	timedMsgExp  time.Time // when timedMsg should clear
-	quitting     bool
-	modal        *executeModal
-	commandToRun string // set when user picks "Run" in the modal
-	fm           flagModal
-	vm           valueInputModal
-	kb           keybindModal // ? key overlay
-	pendingG     bool         // true after first 'g' press, waiting for second 'g'
-	lastSearch   string       // last filter/search term for n/N cycling
+	root          *models.Node
+	cfg           *config.Config
+	scheme        NavScheme
+	tree          *TreeModel
+	preview       *PreviewModel
+	helpPane      *HelpPaneModel
+	showHelpPane  bool
+	filter        textinput.Model
+	filtering     bool
+	focusedPane   pane
+	width         int
+	height        int
+	statusMsg     string        // set by handlers; Update moves it into messages
+	messages      []statusEntry // status message history, newest last
+	cmdline       textinput.Model
+	commanding    bool // : command line is open
+	quitting      bool
+	modal         *executeModal
+	commandToRun  string // set when user picks "Run" in the modal
+	fm            flagModal
+	vm            valueInputModal
+	kb            keybindModal             // ? key overlay
+	tour          tourModal                // guided tour
+	rh            rawHelpModal             // m key raw help pager
+	msgs          messagesModal            // :messages overlay
+	nm            noteModal                // Ctrl+N note prompt
+	sm            snippetsModal            // :snippets overlay
+	rl            runsModal                // :runs overlay
+	em            envModal                 // :env editor
+	cmp           compareModal             // C comparison overlay
+	pinned        *models.Node             // command P pinned for C to compare against
+	pendingG      bool                     // true after first 'g' press, waiting for second 'g'
+	pendingZ      bool                     // true after 'z' in the vim scheme, waiting for zz, zt, za...
+	count         int                      // count typed before a motion, as 5 in 5j; 0 for none
+	lastSearch    string                   // last filter/search term for n/N cycling
+	helpStore     discovery.HelpStore      // optional; lets lazy expansion reuse cached help
+	stateStore    StateStore               // optional; saves the view between sessions
+	history       ValueHistory             // optional; remembers entered flag values
+	completer     discovery.ValueCompleter // optional; live value suggestions
+	overrideStore OverrideStore            // optional; turns edit mode on
+	overrides     *override.Command        // the override edits are saved to
+	noteStore     NoteStore                // optional; keeps notes on commands
+	snippetStore  SnippetStore             // optional; keeps saved commands
+	runLog        RunLog                   // optional; keeps commands run and their output
+	envStore      EnvStore                 // optional; keeps the variables in env
+	env           map[string]string        // environment variables commands are run and copied with
+	usageLog      UsageLog                 // optional; keeps the usage counts between sessions
+	uses          map[string]int           // how often each command and flag was used, by usageKey
+	completeSeq   int                      // latest completion request
+	completeKey   string                   // prompt state the latest request was for
+	fills         map[string]string        // placeholder values given for the command being run
+	wp            wordPopup                // subcommands and flags the word typed in the preview may complete to
+	cliInfo       CLIInfo                  // the executable the tree was discovered from, for the header
+	reloader      Reloader                 // optional; rediscovers the tree for Ctrl+R
+	reloading     bool                     // the tree is being rediscovered
+	enrich        enrichment               // the stubs being discovered in the background
+	zoomed        bool                     // focused pane temporarily fills the width
+	plain         bool                     // plain linear view of the selection replaces the panes
+	dragging      bool                     // divider between tree and help pane is being dragged
 }
 
-// clearTimedMsgMsg is fired by a tea.Tick to clear a timed status message.
-type clearTimedMsgMsg struct{ expiry time.Time }
-
 // NewModel creates a new root TUI model.
 func NewModel(root *models.Node, cfg *config.Config) *Model {
 	filter := textinput.New()
@@ -99,73 +148,110 @@ func NewModel(root *models.Node, cfg *config.Config) *Model {
 		preview:      NewPreviewModel(cfg),
 		helpPane:     NewHelpPaneModel(cfg),
 		filter:       filter,
+		cmdline:      newCommandLine(),
 		showHelpPane: true,
 		focusedPane:  paneTree,
 		modal:        &executeModal{},
+		plain:        cfg.PlainView,
 	}
 	m.tree.SetFocused(true)
+	m.preview.SetRoot(root)
 	m.preview.SetNode(root)
 	m.helpPane.SetNode(root)
 	return m
 }
 
 func (m *Model) Init() tea.Cmd {
-	return tea.EnableMouseAllMotion
+	return tea.Batch(tea.EnableMouseAllMotion, m.discoverExpandedStubs(m.root, 0), m.enrichBelow(m.root))
 }
 
+// Update handles msg. A status message set while handling it is recorded
+// in the message history and shown until it expires.
 func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	// Key-bindings help modal intercepts all input when active.
-	if m.kb.active {
-		if km, ok := msg.(tea.KeyMsg); ok {
-			return m.updateKeybindModal(km)
-		}
-		return m, nil
+	model, cmd := m.update(msg)
+	if c := m.requestCompletion(); c != nil {
+		cmd = tea.Batch(cmd, c)
 	}
-
-	// Value input modal intercepts all input when active.
-	if m.vm.active {
-		if km, ok := msg.(tea.KeyMsg); ok {
-			return m.updateValueModal(km)
-		}
-		return m, nil
+	if m.statusMsg != "" {
+		cmd = tea.Batch(cmd, m.postStatus(m.statusMsg))
+		m.statusMsg = ""
 	}
+	return model, cmd
+}
 
-	// Flag modal intercepts all input when active.
-	if m.fm.active {
-		if km, ok := msg.(tea.KeyMsg); ok {
-			return m.updateFlagModal(km)
+func (m *Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Open modals capture all keyboard and mouse input. Every other message
+	// (window size, async discovery results, timers) is always applied, so
+	// programs embedding the model can forward messages unconditionally.
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case m.tour.active:
+			return m.updateTourModal(msg)
+		case m.kb.active:
+			return m.updateKeybindModal(msg)
+		case m.rh.active:
+			return m.updateRawHelpModal(msg)
+		case m.msgs.active:
+			return m.updateMessagesModal(msg)
+		case m.sm.active:
+			return m.updateSnippetsModal(msg)
+		case m.rl.active:
+			return m.updateRunsModal(msg)
+		case m.em.active:
+			return m.updateEnvModal(msg)
+		case m.cmp.active:
+			return m.updateCompareModal(msg)
+		case m.nm.active:
+			return m.updateNoteModal(msg)
+		case m.vm.active:
+			return m.updateValueModal(msg)
+		case m.fm.active:
+			return m.updateFlagModal(msg)
+		case m.modal.active:
+			return m.updateModal(msg)
 		}
-		return m, nil
-	}
-
-	// Execute modal intercepts all input when active.
-	if m.modal.active {
-		if km, ok := msg.(tea.KeyMsg); ok {
-			return m.updateModal(km)
+	case tea.MouseMsg:
+		if m.tour.active || m.kb.active || m.rh.active || m.msgs.active || m.sm.active || m.rl.active || m.em.active || m.cmp.active || m.nm.active || m.vm.active || m.fm.active || m.modal.active {
+			return m, nil
 		}
-		return m, nil
 	}
 
 	switch msg := msg.(type) {
+	case completeTickMsg:
+		return m, m.runCompletion(msg.seq)
+	case completionsMsg:
+		m.applyCompletions(msg)
+		return m, nil
+	case splitDoneMsg:
+		m.applySplitDone(msg)
+		return m, nil
+	case reloadedMsg:
+		return m, m.reloaded(msg)
+	case enrichedMsg:
+		return m, m.enriched(msg)
+
 	case LazyExpandMsg:
 		if msg.Err == nil && msg.Discovered != nil {
 			m.tree.PatchNode(msg.Stub, msg.Discovered)
 			m.statusMsg = "expanded: " + msg.Stub.Name
+			return m, tea.Batch(m.discoverExpandedStubs(msg.Stub, len(msg.Stub.FullPath)-1), m.enrichBelow(msg.Stub))
 		} else if msg.Err != nil {
 			m.statusMsg = "expand failed: " + msg.Err.Error()
 		}
 		return m, nil
 
 	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
-		m.applyLayout()
+		m.SetSize(msg.Width, msg.Height)
 		return m, nil
 
 	case tea.KeyMsg:
 		if m.filtering {
 			return m.updateFilter(msg)
 		}
+		if m.commanding {
+			return m.updateCommandLine(msg)
+		}
 		if m.focusedPane == panePreview {
 			return m.updatePreviewInput(msg)
 		}
@@ -174,12 +260,8 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.MouseMsg:
 		return m.updateMouse(msg)
 
-	case clearTimedMsgMsg:
-		// Only clear if this tick corresponds to the current expiry (prevents
-		// a rapid re-press of T from clearing the new message early).
-		if !msg.expiry.Before(m.timedMsgExp) {
-			m.timedMsg = ""
-		}
+	case statusExpiredMsg:
+		// Nothing to update: the redraw drops the expired message.
 		return m, nil
 	}
 	return m, nil
@@ -196,21 +278,6 @@ func (m *Model) cycleFocus(delta int) {
 	m.statusMsg = "focus: " + paneName(pane(next))
 }
 
-// setTimedMsg sets a status message that is shown for cfg.StatusMsgTimeout,
-// after which the status bar reverts to the normal key-hint text.
-func (m *Model) setTimedMsg(msg string) {
-	m.timedMsg = msg
-	m.timedMsgExp = time.Now().Add(m.cfg.StatusMsgTimeout)
-}
-
-// timedMsgCmd returns a tea.Cmd that fires clearTimedMsgMsg after the timeout.
-func (m *Model) timedMsgCmd() tea.Cmd {
-	exp := m.timedMsgExp
-	return tea.Tick(m.cfg.StatusMsgTimeout, func(_ time.Time) tea.Msg {
-		return clearTimedMsgMsg{expiry: exp}
-	})
-}
-
 // TreeModel returns the underlying TreeModel for testing.
 func (m *Model) TreeModel() *TreeModel { return m.tree }
 
@@ -222,9 +289,13 @@ func (m *Model) SetScheme(s NavScheme) { m.scheme = s }
 
 func (m *Model) setFocus(p pane) {
 	m.focusedPane = p
+	m.wp = wordPopup{}
 	m.tree.SetFocused(p == paneTree)
 	m.preview.SetFocused(p == panePreview)
 	m.helpPane.SetFocused(p == paneHelp)
+	if m.zoomed {
+		m.applyLayout()
+	}
 }
 
 func (m *Model) syncSelected() {
@@ -258,29 +329,54 @@ func max(a, b int) int {
 	return b
 }
 
-// Run starts the interactive TUI. If the user chose "Run" in the Ctrl+E modal,
-// it executes the command after the TUI exits.
-func Run(root *models.Node, cfg *config.Config) error {
-	m := NewModel(root, cfg)
-	p := tea.NewProgram(m,
-		tea.WithAltScreen(),
-		tea.WithMouseAllMotion(),
-	)
-	finalModel, err := p.Run()
-	if err != nil {
-		return err
+// Run starts the interactive TUI on m, made by NewModel, with the stores
+// it keeps its state in set through its Set* methods. If the user chose
+// "Run" in the Ctrl+E modal, it executes the command after the TUI exits.
+// tour opens the guided tour on start, as for the first launch. RunTabs
+// runs several models in one session.
+func Run(m *Model, tour bool) error {
+	return RunTabs([]*Model{m}, tour)
+}
+
+// runCommand runs the command m's user chose to run, with the environment
+// variables it starts with, recording it in m's run log when record_runs
+// is on.
+func runCommand(m *Model) error {
+	vars, parts := splitEnv(strings.Fields(m.CommandToRun()))
+	if len(parts) == 0 {
+		return nil
 	}
-	if fm, ok := finalModel.(*Model); ok && fm.commandToRun != "" {
-		parts := strings.Fields(fm.commandToRun)
-		if len(parts) > 0 {
-			c := exec.Command(parts[0], parts[1:]...) //nolint:gosec
-			c.Stdin = os.Stdin
-			c.Stdout = os.Stdout
-			c.Stderr = os.Stderr
-			return c.Run()
-		}
+	c := exec.Command(parts[0], parts[1:]...) //nolint:gosec
+	if len(vars) > 0 {
+		c.Env = append(os.Environ(), vars...)
 	}
-	return nil
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if m.cfg.RecordRuns && m.runLog != nil {
+		return recordRun(c, m.CommandToRun(), m.runLog)
+	}
+	return c.Run()
+}
+
+// SetSize resizes the model to w×h cells. It is equivalent to sending a
+// tea.WindowSizeMsg and is meant for hosts that render the model inside a
+// larger layout.
+func (m *Model) SetSize(w, h int) {
+	m.width = w
+	m.height = h
+	m.applyLayout()
+}
+
+// CommandToRun returns the command line the user chose to run from the
+// execute modal, or "" if none was chosen. It starts with the NAME=value
+// assignments of the variables set with :env, as a shell takes them.
+func (m *Model) CommandToRun() string { return m.commandToRun }
+
+// SetHelpStore sets the store used by lazy expansion to reuse help text
+// fetched by earlier runs. A nil store disables caching.
+func (m *Model) SetHelpStore(store discovery.HelpStore) {
+	m.helpStore = store
 }
 
 // NodePreview returns a color-coded command preview string.
@@ -9,6 +9,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/aallbrig/treemand/discovery"
+	"github.com/aallbrig/treemand/models"
 )
 
 // lazyExpandIfStub checks whether the currently selected node is a stub and,
@@ -18,46 +19,106 @@ func (m *Model) lazyExpandIfStub() tea.Cmd {
 	if sel == nil || sel.Kind != SelCommand || !sel.Node.Stub {
 		return nil
 	}
-	stub := sel.Node
-	stubThreshold := m.cfg.StubThreshold
-	cliName := m.root.Name
-	args := stub.FullPath[1:] // subcommand path below root
+	if m.cfg.Offline {
+		m.statusMsg = "offline: " + sel.Node.Name + " was not discovered"
+		return nil
+	}
+	if m.tree.discovering[sel.Node] {
+		// Discovered in the background already; it is shown once done.
+		m.statusMsg = "still discovering " + sel.Node.Name + "…"
+		return nil
+	}
+	m.statusMsg = "discovering " + sel.Node.Name + "…"
+	return m.discoverStub(sel.Node)
+}
 
-	m.statusMsg = "discovering " + stub.Name + "…"
+// discoverStub returns a tea.Cmd that discovers the children of stub one
+// level deep and delivers them as a LazyExpandMsg.
+func (m *Model) discoverStub(stub *models.Node) tea.Cmd {
+	stubThreshold := m.cfg.StubThreshold
+	pruneDuplicates := m.cfg.PruneDuplicates
+	commandTimeout := m.cfg.CommandTimeout
+	retry := m.retryPolicy()
+	profile, _ := discovery.LookupProfile(m.cfg.ParserProfile) // checked before the TUI starts
+	store := m.helpStore
+	cliName := stub.FullPath[0]
+	args := stub.FullPath[1:] // subcommand path below the CLI
+	via := discovery.ParseVia(m.cfg.Via)
 
 	return func() tea.Msg {
 		d := discovery.NewHelpDiscoverer(1) // one level deep for the stub
 		d.StubThreshold = stubThreshold
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		d.PruneDuplicates = pruneDuplicates
+		d.Store = store
+		d.Retry = retry
+		d.Profile = profile
+		if commandTimeout > 0 {
+			d.Timeout = commandTimeout
+		}
+		ctx, cancel := context.WithTimeout(discovery.WithVia(context.Background(), via), 30*time.Second)
 		defer cancel()
 
 		result, err := d.Discover(ctx, cliName, args)
+		if result != nil {
+			discovery.MarkSource(result, d.Name())
+		}
 		return LazyExpandMsg{Stub: stub, Discovered: result, Err: err}
 	}
 }
 
+// retryPolicy returns the configured retries of failed help invocations.
+func (m *Model) retryPolicy() discovery.RetryPolicy {
+	return discovery.RetryPolicy{
+		Retries:        m.cfg.Retries,
+		Backoff:        m.cfg.RetryBackoff,
+		AttemptTimeout: m.cfg.AttemptTimeout,
+	}
+}
+
 // forceExpandSelected re-discovers the currently selected command node
 // regardless of whether it is a stub. It uses the same async LazyExpandMsg
-// pattern as lazyExpandIfStub so the result patches the live tree.
+// pattern as lazyExpandIfStub so the result patches the live tree. Help text
+// is always re-fetched, refreshing any cached copy.
 func (m *Model) forceExpandSelected() tea.Cmd {
 	sel := m.tree.SelectedItem()
 	if sel == nil || sel.Kind != SelCommand {
 		return nil
 	}
+	if m.cfg.Offline {
+		m.statusMsg = "offline: cannot re-discover " + sel.Node.Name
+		return nil
+	}
 	node := sel.Node
 	stubThreshold := m.cfg.StubThreshold
-	cliName := m.root.Name
-	args := node.FullPath[1:] // subcommand path below root
+	pruneDuplicates := m.cfg.PruneDuplicates
+	commandTimeout := m.cfg.CommandTimeout
+	retry := m.retryPolicy()
+	profile, _ := discovery.LookupProfile(m.cfg.ParserProfile) // checked before the TUI starts
+	store := m.helpStore
+	cliName := node.FullPath[0]
+	args := node.FullPath[1:] // subcommand path below the CLI
+	via := discovery.ParseVia(m.cfg.Via)
 
 	m.statusMsg = "discovering " + node.Name + "…"
 
 	return func() tea.Msg {
 		d := discovery.NewHelpDiscoverer(1)
 		d.StubThreshold = stubThreshold
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		d.PruneDuplicates = pruneDuplicates
+		d.Store = store
+		d.Fresh = true
+		d.Retry = retry
+		d.Profile = profile
+		if commandTimeout > 0 {
+			d.Timeout = commandTimeout
+		}
+		ctx, cancel := context.WithTimeout(discovery.WithVia(context.Background(), via), 30*time.Second)
 		defer cancel()
 
 		result, err := d.Discover(ctx, cliName, args)
+		if result != nil {
+			discovery.MarkSource(result, d.Name())
+		}
 		return LazyExpandMsg{Stub: node, Discovered: result, Err: err}
 	}
 }
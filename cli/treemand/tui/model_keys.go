@@ -1,7 +1,7 @@
 package tui
 
 import (
-	"strings"
+	"maps"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -13,15 +13,38 @@ import (
 func (m *Model) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	key := msg.String()
 
-	// Handle pending 'g' for gg (jump to top) sequence.
+	// Handle pending 'g' for gg (jump to top, or to row N for Ngg) sequence.
 	if m.pendingG {
 		m.pendingG = false
 		if key == "g" {
-			m.tree.Top()
+			if m.count > 0 {
+				m.tree.GoToRow(m.count)
+			} else {
+				m.tree.Top()
+			}
+			m.count = 0
 			m.syncSelected()
 			return m, nil
 		}
 		// Not 'g' — cancel pending and process this key normally.
+		m.count = 0
+	}
+
+	// Digits typed in the tree are a count for the motion after them: 5j
+	// moves five rows, 20G goes to row 20.
+	if m.focusedPane == paneTree && !m.pendingZ && len(key) == 1 && key >= "0" && key <= "9" && (key != "0" || m.count > 0) {
+		m.count = min(m.count*10+int(key[0]-'0'), 99999)
+		return m, nil
+	}
+	count := m.count
+	m.count = 0
+
+	// The keys of the navigation scheme take precedence over the global
+	// ones they share.
+	if m.focusedPane == paneTree && m.scheme == SchemeVim {
+		if handled, cmd := m.vimKeys(key); handled {
+			return m, cmd
+		}
 	}
 
 	switch key {
@@ -49,8 +72,44 @@ func (m *Model) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		next := config.DisplayStyle((int(m.cfg.TreeStyle) + 1) % len(config.DisplayStyleNames))
 		m.cfg.TreeStyle = next
 		m.tree.SetDisplayStyle(next)
-		m.setTimedMsg("style: " + config.DisplayStyleNames[next])
-		return m, m.timedMsgCmd()
+		m.statusMsg = "style: " + config.DisplayStyleNames[next]
+		return m, nil
+
+	case "o":
+		next := config.SortMode((int(m.cfg.Sort) + 1) % len(config.SortModeNames))
+		m.cfg.Sort = next
+		m.tree.SetUses(maps.Clone(m.uses))
+		m.tree.SetSortMode(next)
+		m.syncSelected()
+		m.statusMsg = "sort: " + config.SortModeNames[next]
+		return m, nil
+
+	case "c":
+		m.tree.SetCommandsOnly(!m.tree.CommandsOnly())
+		m.syncSelected()
+		if m.tree.CommandsOnly() {
+			m.statusMsg = "commands only: on"
+		} else {
+			m.statusMsg = "commands only: off"
+		}
+		return m, nil
+
+	case "p":
+		m.tree.SetFullPath(!m.tree.FullPath())
+		if m.tree.FullPath() {
+			m.statusMsg = "full paths: on"
+		} else {
+			m.statusMsg = "full paths: off"
+		}
+		return m, nil
+
+	case "P":
+		m.togglePin()
+		return m, nil
+
+	case "C":
+		m.openCompare()
+		return m, nil
 
 	case "S":
 		m.tree.ToggleSections()
@@ -68,27 +127,49 @@ func (m *Model) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.applyLayout()
 		return m, nil
 
+	case "<":
+		m.setPaneRatio(m.paneRatio() - paneRatioStep)
+		return m, nil
+	case ">":
+		m.setPaneRatio(m.paneRatio() + paneRatioStep)
+		return m, nil
+
+	case "[":
+		m.tree.ScrollLeft(hScrollStep)
+		return m, nil
+	case "]":
+		m.tree.ScrollRight(hScrollStep)
+		return m, nil
+
+	case "z":
+		m.toggleZoom()
+		return m, nil
+
 	case "/":
 		m.filtering = true
 		m.filter.Focus()
 		return m, textinput.Blink
 
-	case "?":
-		m.kb.active = true
-		m.kb.offset = 0
+	case "?", "f1":
+		m.openKeybinds()
 		return m, nil
 
-	case "ctrl+e":
-		cmd := strings.Join(m.preview.Tokens(), " ")
-		if cmd == "" {
-			if node := m.tree.Selected(); node != nil {
-				cmd = node.FullCommand()
-			}
-		}
-		m.modal.command = cmd
-		m.modal.active = true
+	case "m":
+		m.openRawHelp()
 		return m, nil
 
+	case ":":
+		m.commanding = true
+		m.cmdline.SetValue("")
+		return m, m.cmdline.Focus()
+
+	case "ctrl+e":
+		return m, m.openExecModal()
+
+	case "ctrl+n":
+		m.openNoteModal()
+		return m, textinput.Blink
+
 	case "ctrl+k":
 		m.preview.ClearAll()
 		m.tree.SetCmdTokens(nil)
@@ -104,6 +185,9 @@ func (m *Model) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "r", "R":
 		return m, m.forceExpandSelected()
 
+	case "ctrl+r":
+		return m, m.reload()
+
 	case "f", "F":
 		m.openFlagModal()
 		return m, nil
@@ -128,30 +212,27 @@ func (m *Model) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	// Shift+Right/Left: expand/collapse subtree under the current node.
 	case "shift+right", "shift+l", "shift+d":
-		if node := m.tree.SelectedOrOwner(); node != nil {
-			m.tree.ExpandAllFrom(node, m.tree.SelectedCommandDepth())
-			m.tree.Rebuild()
-			m.statusMsg = "expanded: " + node.Name
-		}
+		m.expandSubtree()
 		return m, nil
 
 	case "shift+left", "shift+h", "shift+a":
-		if node := m.tree.SelectedOrOwner(); node != nil {
-			m.tree.CollapseSubtree(node, m.tree.SelectedCommandDepth())
-			m.tree.Rebuild()
-			m.statusMsg = "collapsed: " + node.Name
-		}
+		m.collapseSubtree()
 		return m, nil
 
-	// G: jump to last row.
+	// G: jump to last row, or to row N for NG.
 	case "G":
-		m.tree.Bottom()
+		if count > 0 {
+			m.tree.GoToRow(count)
+		} else {
+			m.tree.Bottom()
+		}
 		m.syncSelected()
 		return m, nil
 
 	// g: first press sets pendingG; second 'g' triggers jump to top.
 	case "g":
 		m.pendingG = true
+		m.count = count
 		return m, nil
 
 	// n/N: cycle through search matches.
@@ -176,14 +257,105 @@ func (m *Model) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.updateHelpPaneKeys(key)
 	}
 
-	// Tree navigation.
-	switch m.scheme {
-	case SchemeVim:
-		return m.handleVim(msg)
-	case SchemeWASD:
-		return m.handleWASD(msg)
+	// Paging, in every scheme.
+	switch key {
+	case "pgup":
+		m.tree.PageUp()
+	case "pgdown":
+		m.tree.PageDown()
+	case "home":
+		m.tree.Top()
+	case "end":
+		m.tree.Bottom()
 	default:
-		return m.handleArrows(msg)
+		// Tree navigation.
+		switch m.scheme {
+		case SchemeVim:
+			return m.handleVim(msg, max(1, count))
+		case SchemeWASD:
+			return m.handleWASD(msg, max(1, count))
+		default:
+			return m.handleArrows(msg, max(1, count))
+		}
+	}
+	m.syncSelected()
+	return m, m.lazyExpandIfStub()
+}
+
+// vimKeys handles the keys of the vim scheme that are global keys in the
+// others, or need more than one step. It reports false for the keys it
+// leaves to updateKeys.
+func (m *Model) vimKeys(key string) (bool, tea.Cmd) {
+	if m.pendingZ {
+		m.pendingZ = false
+		switch key {
+		case "z":
+			m.tree.ScrollCursorTo(cursorCenter)
+		case "t":
+			m.tree.ScrollCursorTo(cursorTop)
+		case "b":
+			m.tree.ScrollCursorTo(cursorBottom)
+		case "a":
+			m.tree.ToggleExpand()
+		case "R":
+			m.tree.ExpandAll()
+			m.statusMsg = "expanded all"
+		case "M":
+			m.tree.CollapseAll()
+			m.statusMsg = "collapsed all"
+		default:
+			// Not a z command — cancel pending and process this key normally.
+			return false, nil
+		}
+		m.syncSelected()
+		return true, m.lazyExpandIfStub()
+	}
+	switch key {
+	case "z":
+		m.pendingZ = true
+	case "Z":
+		m.toggleZoom()
+	case "H":
+		m.collapseSubtree()
+	case "L":
+		m.expandSubtree()
+	case "?":
+		m.showHelpPane = !m.showHelpPane
+		m.applyLayout()
+	case "ctrl+d":
+		m.tree.HalfPageDown()
+		m.syncSelected()
+	case "ctrl+u":
+		m.tree.HalfPageUp()
+		m.syncSelected()
+	case "ctrl+f":
+		m.tree.PageDown()
+		m.syncSelected()
+	case "ctrl+b":
+		m.tree.PageUp()
+		m.syncSelected()
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// expandSubtree expands the command at the cursor and everything under it.
+func (m *Model) expandSubtree() {
+	if node := m.tree.SelectedOrOwner(); node != nil {
+		m.tree.ExpandAllFrom(node, m.tree.SelectedCommandDepth())
+		m.tree.Rebuild()
+		m.statusMsg = "expanded: " + node.Name
+	}
+}
+
+// collapseSubtree collapses the command at the cursor and everything under
+// it.
+func (m *Model) collapseSubtree() {
+	if node := m.tree.SelectedOrOwner(); node != nil {
+		m.tree.CollapseSubtree(node, m.tree.SelectedCommandDepth())
+		m.tree.Rebuild()
+		m.statusMsg = "collapsed: " + node.Name
 	}
 }
 
@@ -207,6 +379,9 @@ func (m *Model) updateHelpPaneKeys(key string) (tea.Model, tea.Cmd) {
 
 func (m *Model) updatePreviewInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	key := msg.String()
+	if m.updateWordPopup(key) {
+		return m, nil
+	}
 	switch key {
 	case "ctrl+c":
 		m.quitting = true
@@ -216,19 +391,18 @@ func (m *Model) updatePreviewInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.statusMsg = "focus: tree"
 		return m, nil
 	case "tab":
-		m.cycleFocus(1)
+		if !m.completePreview() {
+			m.cycleFocus(1)
+		}
 		return m, nil
 	case "shift+tab":
 		m.cycleFocus(-1)
 		return m, nil
 	case "ctrl+e":
-		cmd := strings.Join(m.preview.Tokens(), " ")
-		m.modal.command = cmd
-		m.modal.active = true
-		return m, nil
+		return m, m.openExecModal()
 	}
 	cmd := m.preview.Update(msg)
-	m.tree.SetCmdTokens(m.preview.Tokens())
+	m.previewEdited()
 	return m, cmd
 }
 
@@ -247,15 +421,19 @@ func (m *Model) handlePick() {
 		if !sel.Node.Virtual {
 			m.preview.SetCommand(sel.Node.FullCommand())
 			m.tree.SetCmdTokens(m.preview.Tokens())
+			m.use(sel.Node, "")
 			m.statusMsg = "set: " + sel.Node.FullCommand()
 		}
 	case SelFlag:
-		vt := strings.ToLower(sel.Flag.ValueType)
-		if vt == "" || vt == "bool" {
-			if !isFlagActive(*sel.Flag, m.preview.Tokens()) {
+		if sel.Flag.Negatable {
+			m.toggleNegatable(*sel.Flag, sel.Owner)
+		} else if !sel.Flag.TakesValue() {
+			// Repeatable flags (-v -v) may be added again.
+			if sel.Flag.Repeatable || !isFlagActive(*sel.Flag, m.preview.Tokens(), knownFlags(sel.Owner, m.root)) {
 				m.ensureCommandBase(sel.Owner)
 				m.preview.AppendToken(sel.Flag.Name)
 				m.tree.SetCmdTokens(m.preview.Tokens())
+				m.use(sel.Owner, sel.Flag.Name)
 				m.statusMsg = "added: " + sel.Flag.Name
 			}
 		} else {
@@ -289,12 +467,12 @@ func (m *Model) handleEsc() (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m *Model) handleArrows(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+func (m *Model) handleArrows(msg tea.KeyMsg, count int) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "up":
-		m.tree.Up()
+		m.tree.MoveBy(-count)
 	case "down":
-		m.tree.Down()
+		m.tree.MoveBy(count)
 	case "left":
 		m.tree.Left()
 	case "right":
@@ -308,12 +486,12 @@ func (m *Model) handleArrows(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, m.lazyExpandIfStub()
 }
 
-func (m *Model) handleVim(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+func (m *Model) handleVim(msg tea.KeyMsg, count int) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "k":
-		m.tree.Up()
+		m.tree.MoveBy(-count)
 	case "j":
-		m.tree.Down()
+		m.tree.MoveBy(count)
 	case "h":
 		m.tree.Left()
 	case "l":
@@ -327,12 +505,12 @@ func (m *Model) handleVim(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, m.lazyExpandIfStub()
 }
 
-func (m *Model) handleWASD(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+func (m *Model) handleWASD(msg tea.KeyMsg, count int) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "w":
-		m.tree.Up()
+		m.tree.MoveBy(-count)
 	case "s":
-		m.tree.Down()
+		m.tree.MoveBy(count)
 	case "a":
 		m.tree.Left()
 	case "d":
@@ -360,5 +538,6 @@ func (m *Model) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	m.filter, cmd = m.filter.Update(msg)
 	m.tree.SetFilter(m.filter.Value())
+	m.syncSelected()
 	return m, cmd
 }
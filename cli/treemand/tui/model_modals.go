@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/atotto/clipboard"
@@ -16,48 +17,56 @@ import (
 
 // keybindModal is the ? key overlay showing all keyboard shortcuts.
 type keybindModal struct {
-	active bool
-	offset int
-}
-
-const keybindText = `Navigation
-  ↑ / ↓    or  k / j   or  w / s    Move up / down
-  → / l / d                          Expand node; enter children (2nd press)
-  ← / h / a                          Collapse node; go to parent (2nd press)
-  Shift+→ / Shift+L / Shift+D        Expand entire subtree
-  Shift+← / Shift+H / Shift+A        Collapse entire subtree
-  gg                                  Jump to top
-  G                                   Jump to bottom
-
-Tree
-  /        Fuzzy filter
-  n / N    Next / previous search match
-  e / E    Expand all / collapse all
-  S        Toggle section headers
-  T        Cycle display style (default → columns → compact → graph)
-  R        Re-discover selected node (refresh children)
-
-Building Commands
-  Enter    Set command / add flag / fill positional
-  f / F    Open flag picker modal
-  Backspace  Remove last token from preview
-  Ctrl+K   Clear entire preview bar
-  Ctrl+E   Copy or execute the assembled command
-
-View
-  H / Ctrl+P   Toggle help pane
-  Tab / Shift+Tab  Cycle pane focus
-  d / D    Open docs URL in browser
-  Ctrl+S   Cycle navigation scheme (arrows → vim → WASD)
-  ?        Show this help
-
-Quit
-  q / Esc    Quit`
+	active    bool
+	offset    int
+	query     textinput.Model // search; the bindings shown hold its value
+	searching bool            // the search is being typed
+}
+
+// openKeybinds opens the ? overlay.
+func (m *Model) openKeybinds() {
+	query := textinput.New()
+	query.Prompt = "/"
+	query.Placeholder = "search keys and descriptions"
+	m.kb = keybindModal{active: true, query: query}
+}
 
 func (m *Model) updateKeybindModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.kb.searching {
+		switch msg.String() {
+		case "ctrl+c":
+			m.kb.active = false
+		case "enter":
+			m.kb.searching = false
+			m.kb.query.Blur()
+		case "esc":
+			m.kb.searching = false
+			m.kb.query.Blur()
+			m.kb.query.SetValue("")
+		default:
+			var cmd tea.Cmd
+			m.kb.query, cmd = m.kb.query.Update(msg)
+			m.kb.offset = 0
+			return m, cmd
+		}
+		return m, nil
+	}
 	switch msg.String() {
-	case "ctrl+c", "esc", "q", "?":
+	case "esc":
+		if m.kb.query.Value() != "" {
+			m.kb.query.SetValue("")
+			m.kb.offset = 0
+			return m, nil
+		}
+		m.kb.active = false
+	case "ctrl+c", "q", "?", "f1":
 		m.kb.active = false
+	case "t":
+		m.kb.active = false
+		m.StartTour()
+	case "/":
+		m.kb.searching = true
+		return m, m.kb.query.Focus()
 	case "up", "k":
 		if m.kb.offset > 0 {
 			m.kb.offset--
@@ -76,7 +85,7 @@ func (m *Model) updateKeybindModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m *Model) renderKeybindModal() string {
-	modalW := min(m.width-6, 64)
+	modalW := min(m.width-6, 84)
 	if modalW < 40 {
 		modalW = 40
 	}
@@ -84,8 +93,11 @@ func (m *Model) renderKeybindModal() string {
 	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
 	hintStyle := lipgloss.NewStyle().Faint(true)
 
-	lines := strings.Split(keybindText, "\n")
-	maxVisible := m.height - 10
+	lines := keymapLines(m.scheme, m.kb.query.Value(), modalW-6)
+	if len(lines) == 0 {
+		lines = []string{hintStyle.Render("no key bindings match")}
+	}
+	maxVisible := m.height - 12
 	if maxVisible < 5 {
 		maxVisible = 5
 	}
@@ -103,41 +115,31 @@ func (m *Model) renderKeybindModal() string {
 		scrollHint = fmt.Sprintf(" [%d/%d]", m.kb.offset+1, len(lines))
 	}
 
+	hint := "↑↓/jk scroll · / search · t tour · Esc close"
+	switch {
+	case m.kb.searching:
+		hint = "type to search · Enter done · Esc clear"
+	case m.kb.query.Value() != "":
+		hint = "↑↓/jk scroll · / search · Esc clear"
+	}
+	search := hintStyle.Render("scheme: " + schemeName(m.scheme) + " (Ctrl+S cycles)")
+	if m.kb.searching || m.kb.query.Value() != "" {
+		m.kb.query.Width = modalW - 10
+		search = m.kb.query.View()
+	}
 	content := titleStyle.Render("Key Bindings"+scrollHint) + "\n" +
-		hintStyle.Render("↑↓/jk scroll · Esc close") + "\n\n" +
+		hintStyle.Render(hint) + "\n" +
+		search + "\n\n" +
 		strings.Join(visible, "\n")
 
 	box := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
+		Border(boxBorder(m.cfg)).
 		BorderForeground(lipgloss.Color("#5EA4F5")).
 		Padding(0, 2).
 		Width(modalW - 2).
 		Render(content)
 
-	boxH := lipgloss.Height(box)
-	padLeft := (m.width - lipgloss.Width(box)) / 2
-	if padLeft < 0 {
-		padLeft = 0
-	}
-	padTop := (m.height - boxH) / 2
-	if padTop < 0 {
-		padTop = 0
-	}
-	padBottom := max(0, m.height-padTop-boxH)
-
-	blankLine := strings.Repeat(" ", m.width)
-	leftPad := strings.Repeat(" ", padLeft)
-	var sb strings.Builder
-	for i := 0; i < padTop; i++ {
-		sb.WriteString(blankLine + "\n")
-	}
-	for _, line := range strings.Split(box, "\n") {
-		sb.WriteString(leftPad + line + "\n")
-	}
-	for i := 0; i < padBottom; i++ {
-		sb.WriteString(blankLine + "\n")
-	}
-	return sb.String()
+	return m.overlay(box)
 }
 
 // ---------- value input modal ----------
@@ -145,15 +147,58 @@ func (m *Model) renderKeybindModal() string {
 func (m *Model) updateValueModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
+		if v, ok := m.vm.suggest.chosen(m.vm.input.Value()); ok {
+			m.vm.input.SetValue(v)
+		}
+		if m.vm.variable != "" {
+			value := strings.TrimSpace(m.vm.input.Value())
+			if value == "" {
+				return m, nil
+			}
+			m.vm.active = false
+			m.rememberValue(variableKey(m.vm.variable), value)
+			if m.vm.chain {
+				if m.fills == nil {
+					m.fills = map[string]string{}
+				}
+				m.fills[m.vm.variable] = value
+				return m, m.openExecModal()
+			}
+			m.fillPlaceholder(m.vm.variable, value)
+			return m, m.promptPlaceholder(false)
+		}
+		if m.vm.slot && strings.TrimSpace(m.vm.input.Value()) == "" {
+			// An empty positional would not fill its slot.
+			return m, nil
+		}
 		m.ensureCommandBase(m.vm.owner)
+		m.rememberValue(m.vm.flag, strings.TrimSpace(m.vm.input.Value()))
+		if m.vm.flag != "" {
+			m.use(m.vm.owner, m.vm.flag)
+		}
 		val := m.vm.prefix + m.vm.input.Value()
 		m.preview.AppendToken(val)
 		m.tree.SetCmdTokens(m.preview.Tokens())
 		m.statusMsg = "added: " + val
 		m.vm.active = false
+		if m.vm.chain {
+			// Prompt for the next missing positional, or run once none
+			// are left.
+			return m, m.openExecModal()
+		}
 		return m, nil
 	case "esc", "ctrl+c":
 		m.vm.active = false
+		switch {
+		case m.vm.chain && m.vm.variable != "":
+			m.fills = nil
+			m.statusMsg = "cancelled"
+		case m.vm.chain:
+			m.statusMsg = "cancelled: " + m.missingSlotsText()
+		}
+		return m, nil
+	}
+	if m.vm.suggest.update(msg.String(), &m.vm.input) {
 		return m, nil
 	}
 	var cmd tea.Cmd
@@ -171,34 +216,20 @@ func (m *Model) renderValueInputModal() string {
 
 	m.vm.input.Width = modalW - 8
 	inner := titleStyle.Render(m.vm.label) + "\n\n" +
-		m.vm.input.View() + "\n\n" +
-		hintStyle.Render("[Enter] confirm  [Esc] cancel")
+		m.vm.input.View() + "\n\n"
+	if dd := m.vm.suggest.view(m.vm.input.Value(), modalW-8); dd != "" {
+		inner += dd + "\n\n"
+	}
+	inner += hintStyle.Render("[Enter] confirm  [Esc] cancel")
 
 	box := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
+		Border(boxBorder(m.cfg)).
 		BorderForeground(lipgloss.Color("#5EA4F5")).
 		Padding(1, 2).
 		Width(modalW - 2).
 		Render(inner)
 
-	padLeft := (m.width - lipgloss.Width(box)) / 2
-	if padLeft < 0 {
-		padLeft = 0
-	}
-	padTop := (m.height - lipgloss.Height(box)) / 2
-	if padTop < 0 {
-		padTop = 0
-	}
-	blankLine := strings.Repeat(" ", m.width)
-	leftPad := strings.Repeat(" ", padLeft)
-	var sb strings.Builder
-	for i := 0; i < padTop; i++ {
-		sb.WriteString(blankLine + "\n")
-	}
-	for _, line := range strings.Split(box, "\n") {
-		sb.WriteString(leftPad + line + "\n")
-	}
-	return sb.String()
+	return m.overlay(box)
 }
 
 func (m *Model) openValueModal(f *models.Flag, owner *models.Node) {
@@ -207,29 +238,68 @@ func (m *Model) openValueModal(f *models.Flag, owner *models.Node) {
 	vi.CharLimit = 256
 	vi.Focus()
 	m.vm = valueInputModal{
-		active: true,
-		label:  f.Name + " <" + f.ValueType + ">",
-		prefix: f.Name + "=",
-		input:  vi,
-		owner:  owner,
+		active:  true,
+		label:   f.Name + " <" + f.ValueType + ">",
+		prefix:  f.WithValue(""),
+		input:   vi,
+		owner:   owner,
+		flag:    f.Name,
+		suggest: m.suggestionsFor(f.Name),
 	}
 }
 
+// openPositionalModal prompts for positional p of owner. Positionals fill
+// their slots in order, so picking a later one while an earlier slot is
+// still empty prompts for the earlier one instead.
 func (m *Model) openPositionalModal(p *models.Positional, owner *models.Node) {
-	name := "<" + p.Name + ">"
-	if !p.Required {
-		name = "[" + p.Name + "]"
+	slots := m.ownerSlots(owner)
+	idx := -1
+	for i, s := range slots {
+		if s.pos == p || slices.Contains(s.alts, p) {
+			idx = i
+		}
 	}
+	if idx < 0 {
+		return
+	}
+	if slots[idx].filled && !p.Variadic {
+		m.statusMsg = slots[idx].placeholder() + " is already " + slots[idx].value + " (Backspace to change)"
+		return
+	}
+	if open := nextOpenSlot(slots); open >= 0 && open < idx {
+		m.statusMsg = "fill " + slots[open].placeholder() + " first"
+		idx = open
+	}
+	m.promptSlot(slots[idx], owner, false)
+}
+
+// ownerSlots returns owner's positional slots as filled by the preview, or
+// all empty when the preview is not (yet) a command of owner.
+func (m *Model) ownerSlots(owner *models.Node) []posSlot {
+	if node, slots := commandSlots(m.root, m.preview.Tokens()); node == owner {
+		return slots
+	}
+	_, slots := commandSlots(m.root, owner.FullPath)
+	return slots
+}
+
+// promptSlot opens the value input for one positional slot. With chain set,
+// confirming it moves on to the next missing positional and then the
+// execute modal.
+func (m *Model) promptSlot(s posSlot, owner *models.Node, chain bool) {
 	vi := textinput.New()
-	vi.Placeholder = p.Name
+	vi.Placeholder = s.pos.Name
 	vi.CharLimit = 256
 	vi.Focus()
 	m.vm = valueInputModal{
-		active: true,
-		label:  name,
-		prefix: "",
-		input:  vi,
-		owner:  owner,
+		active:  true,
+		label:   s.placeholder(),
+		prefix:  "",
+		input:   vi,
+		owner:   owner,
+		slot:    true,
+		chain:   chain,
+		argName: s.pos.Name,
 	}
 }
 
@@ -263,27 +333,133 @@ func (m *Model) ensureCommandBase(owner *models.Node) {
 
 // ---------- execute modal ----------
 
+// openExecModal opens the execute modal for the command in the preview, or
+// the selected command when the preview is empty. Required positionals must
+// be filled first: it prompts for each missing one in order instead.
+func (m *Model) openExecModal() tea.Cmd {
+	cmd := m.execCommand()
+	node, slots := commandSlots(m.root, splitWords(cmd))
+	if i := nextRequiredSlot(slots); i >= 0 {
+		m.ensureCommandBase(node)
+		m.promptSlot(slots[i], node, true)
+		m.statusMsg = "missing " + m.missingSlotsText()
+		return textinput.Blink
+	}
+	// Placeholders are filled in the command run, leaving the preview as a
+	// template to run again.
+	if blink := m.promptPlaceholder(true); blink != nil {
+		return blink
+	}
+	cmd = fillPlaceholders(cmd, m.fills)
+	m.fills = nil
+	m.modal.command = m.envPrefix() + cmd
+	m.modal.warnings = deprecationWarnings(m.root, splitWords(cmd))
+	m.modal.active = true
+	m.armDanger()
+	if m.modal.danger != "" {
+		return textinput.Blink
+	}
+	return nil
+}
+
+// execCommand returns the command Ctrl+E runs: the preview, or the
+// selected command when the preview is empty.
+func (m *Model) execCommand() string {
+	cmd := strings.Join(m.preview.Tokens(), " ")
+	if cmd == "" {
+		if node := m.tree.Selected(); node != nil {
+			cmd = node.FullCommand()
+		}
+	}
+	return cmd
+}
+
+// missingSlotsText lists the required positionals the preview still lacks,
+// e.g. "<name> <url>".
+func (m *Model) missingSlotsText() string {
+	_, slots := commandSlots(m.root, m.preview.Tokens())
+	var missing []string
+	for _, s := range slots {
+		if s.pos.Required && !s.filled {
+			missing = append(missing, s.placeholder())
+		}
+	}
+	return strings.Join(missing, " ")
+}
+
 func (m *Model) updateModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.modal.danger != "" {
+		return m.updateDangerModal(msg)
+	}
 	switch msg.String() {
 	case "ctrl+c", "esc", "q":
 		m.modal.active = false
 		m.statusMsg = "cancelled"
 	case "enter", "r", "R":
-		m.commandToRun = m.modal.command
-		m.modal.active = false
-		m.quitting = true
-		return m, tea.Quit
-	case "c", "C":
-		if err := clipboard.WriteAll(m.modal.command); err != nil {
-			m.statusMsg = "copy failed: " + err.Error()
-		} else {
-			m.statusMsg = "copied: " + m.modal.command
+		if m.cfg.Offline {
+			m.statusMsg = "offline: commands are not run (press c to copy)"
+			return m, nil
 		}
-		m.modal.active = false
+		return m, m.runModalCommand()
+	case "t", "T":
+		if m.cfg.Offline {
+			m.statusMsg = "offline: commands are not run (press c to copy)"
+			return m, nil
+		}
+		return m, m.runInSplit()
+	case "c", "C":
+		m.copyModalCommand()
 	}
 	return m, nil
 }
 
+// updateDangerModal handles the execute modal of a dangerous command: the
+// keys go to the confirmation input, and Enter runs the command only once
+// it holds dangerConfirmation.
+func (m *Model) updateDangerModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		m.modal.active = false
+		m.statusMsg = "cancelled"
+		return m, nil
+	case "ctrl+y":
+		m.copyModalCommand()
+		return m, nil
+	case "enter", "ctrl+t":
+		if !strings.EqualFold(strings.TrimSpace(m.modal.confirm.Value()), dangerConfirmation) {
+			m.statusMsg = "type " + dangerConfirmation + " to run a command matching " + m.modal.danger
+			return m, nil
+		}
+		if msg.String() == "ctrl+t" {
+			return m, m.runInSplit()
+		}
+		return m, m.runModalCommand()
+	}
+	var cmd tea.Cmd
+	m.modal.confirm, cmd = m.modal.confirm.Update(msg)
+	return m, cmd
+}
+
+// runModalCommand quits so that Run runs the modal's command.
+func (m *Model) runModalCommand() tea.Cmd {
+	m.commandToRun = m.modal.command
+	m.useCommandLine(m.modal.command)
+	m.modal.active = false
+	m.quitting = true
+	return tea.Quit
+}
+
+// copyModalCommand copies the modal's command to the clipboard and closes
+// the modal.
+func (m *Model) copyModalCommand() {
+	if err := clipboard.WriteAll(m.modal.command); err != nil {
+		m.statusMsg = "copy failed: " + err.Error()
+	} else {
+		m.statusMsg = "copied: " + m.modal.command
+	}
+	m.modal.active = false
+}
+
 func (m *Model) renderModal() string {
 	cmd := m.modal.command
 	if cmd == "" {
@@ -295,41 +471,53 @@ func (m *Model) renderModal() string {
 		modalW = 30
 	}
 
-	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	// A dangerous command gets a red modal.
+	accent, title := "#5EA4F5", "Execute Command"
+	if m.modal.danger != "" {
+		accent, title = m.cfg.Colors.Invalid, "Execute Dangerous Command"
+	}
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(accent))
 	cmdStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(m.cfg.Colors.Base)).Bold(true)
 	hintStyle := lipgloss.NewStyle().Faint(true)
 
-	inner := titleStyle.Render("Execute Command") + "\n\n" +
-		cmdStyle.Render(cmd) + "\n\n" +
-		hintStyle.Render("[Enter/R] Run  [C] Copy  [Esc] Cancel")
+	split, _ := terminalSplit("")
+	hint := "[Enter/R] Run  [C] Copy  [Esc] Cancel"
+	if split != "" {
+		hint = "[Enter/R] Run  [T] Run in " + split + "  [C] Copy  [Esc] Cancel"
+	}
+	switch {
+	case m.cfg.Offline:
+		hint = "[C] Copy  [Esc] Cancel  (offline: commands are not run)"
+	case m.modal.danger != "":
+		hint = "[Enter] Run once " + dangerConfirmation + " is typed  [Ctrl+Y] Copy  [Esc] Cancel"
+		if split != "" {
+			hint = "[Enter] Run (Ctrl+T in " + split + ") once " + dangerConfirmation + " is typed  [Ctrl+Y] Copy  [Esc] Cancel"
+		}
+	}
+	inner := titleStyle.Render(title) + "\n\n" +
+		cmdStyle.Render(cmd) + "\n\n"
+	if len(m.modal.warnings) > 0 {
+		warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(m.cfg.Colors.Invalid))
+		for _, w := range m.modal.warnings {
+			inner += warnStyle.Render("⚠ "+w) + "\n"
+		}
+		inner += "\n"
+	}
+	if m.modal.danger != "" {
+		m.modal.confirm.Width = modalW - 8
+		inner += titleStyle.Render("It matches the danger pattern \""+m.modal.danger+"\". Type "+dangerConfirmation+" to run it:") + "\n" +
+			m.modal.confirm.View() + "\n\n"
+	}
+	inner += hintStyle.Render(hint)
 
 	box := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color(accent)).
 		Padding(1, 2).
 		Width(modalW - 2).
 		Render(inner)
 
-	// Center horizontally; place in the middle of the screen vertically.
-	padLeft := (m.width - lipgloss.Width(box)) / 2
-	if padLeft < 0 {
-		padLeft = 0
-	}
-	padTop := (m.height - lipgloss.Height(box)) / 2
-	if padTop < 0 {
-		padTop = 0
-	}
-
-	var sb strings.Builder
-	emptyLine := strings.Repeat(" ", m.width)
-	for i := 0; i < padTop; i++ {
-		sb.WriteString(emptyLine + "\n")
-	}
-	leftPad := strings.Repeat(" ", padLeft)
-	for _, line := range strings.Split(box, "\n") {
-		sb.WriteString(leftPad + line + "\n")
-	}
-	return sb.String()
+	return m.overlay(box)
 }
 
 // ---------- flag picker modal ----------
@@ -338,25 +526,44 @@ func (m *Model) renderModal() string {
 type flagEntry struct {
 	flag   models.Flag
 	global bool // true when sourced from the root node (global flag)
-	added  bool // true when already present in the preview
+	added  bool // true when present in the preview and not repeatable
+	count  int  // occurrences in the preview, shown for repeatable flags
+	marked bool // true when toggled with Space for the next Enter
+	// negated is set when a negatable flag is in the preview in its --no-
+	// form; count is 1 when it is there as itself.
+	negated bool
+}
+
+// setNegatable records the state of the entry's negatable flag in tokens.
+// The flag is never added for good: Enter moves it to its next state.
+func (e *flagEntry) setNegatable(tokens []string, known []models.Flag) {
+	i, on := negatableState(e.flag, tokens, known)
+	e.added, e.count, e.negated = false, 0, i >= 0 && !on
+	if i >= 0 && on {
+		e.count = 1
+	}
 }
 
 // flagModal is the f-key flag-picker overlay.
 type flagModal struct {
 	active        bool
 	entries       []flagEntry
-	cursor        int
+	filter        string // typed filter text
+	visible       []int  // indices into entries matching filter
+	cursor        int    // index into visible
 	offset        int
-	awaitingValue bool // true when prompting the user to type a value
-	awaitingIdx   int  // index of the entry awaiting a value
+	awaitingValue bool  // true when prompting the user to type a value
+	awaitingIdx   int   // index of the entry awaiting a value
+	pending       []int // marked entries still to add after the current one
 	valueInput    textinput.Model
+	suggest       suggestList  // remembered values for the awaited flag
 	owner         *models.Node // node whose flags are shown
 }
 
 // flagTypeColor returns a colour for a flag's value-type indicator in the modal.
 func flagTypeColor(valueType string) lipgloss.Color {
 	switch strings.ToLower(valueType) {
-	case "", "bool":
+	case "", "bool", "count":
 		return lipgloss.Color("#50FA7B") // green
 	case "string", "str":
 		return lipgloss.Color("#8BE9FD") // cyan
@@ -375,18 +582,17 @@ func (m *Model) openFlagModal() {
 		return
 	}
 
-	// Build set of flag names already in the preview.
-	addedSet := make(map[string]bool)
-	for _, tok := range m.preview.Tokens() {
-		if strings.HasPrefix(tok, "--") {
-			name := strings.TrimPrefix(tok, "--")
-			if idx := strings.Index(name, "="); idx >= 0 {
-				name = name[:idx]
-			}
-			addedSet["--"+name] = true
-		} else if strings.HasPrefix(tok, "-") && len(tok) == 2 {
-			addedSet[tok] = true
+	// A flag already in the preview is marked added, unless it may be
+	// given again.
+	tokens := m.preview.Tokens()
+	known := knownFlags(node, m.root)
+	newEntry := func(f models.Flag, global bool) flagEntry {
+		n := flagCount(f, tokens, known)
+		e := flagEntry{flag: f, global: global, added: n > 0 && !f.Repeatable, count: n}
+		if f.Negatable {
+			e.setNegatable(tokens, known)
 		}
+		return e
 	}
 
 	// Collect node-specific flags.
@@ -394,11 +600,7 @@ func (m *Model) openFlagModal() {
 	var entries []flagEntry
 	for _, f := range node.Flags {
 		nodeFlags[f.Name] = true
-		entries = append(entries, flagEntry{
-			flag:   f,
-			global: false,
-			added:  addedSet[f.Name] || (f.ShortName != "" && addedSet["-"+f.ShortName]),
-		})
+		entries = append(entries, newEntry(f, false))
 	}
 
 	// Append global (root) flags not already listed above.
@@ -407,11 +609,7 @@ func (m *Model) openFlagModal() {
 			if nodeFlags[f.Name] {
 				continue
 			}
-			entries = append(entries, flagEntry{
-				flag:   f,
-				global: true,
-				added:  addedSet[f.Name] || (f.ShortName != "" && addedSet["-"+f.ShortName]),
-			})
+			entries = append(entries, newEntry(f, true))
 		}
 	}
 
@@ -419,11 +617,48 @@ func (m *Model) openFlagModal() {
 		m.statusMsg = "no flags available"
 		return
 	}
+	m.sortFlagEntries(entries, node)
 
 	vi := textinput.New()
 	vi.CharLimit = 128
 
 	m.fm = flagModal{active: true, entries: entries, valueInput: vi, owner: node}
+	m.applyFlagFilter()
+}
+
+// applyFlagFilter recomputes the entries matching the typed filter and keeps
+// the cursor within them.
+func (m *Model) applyFlagFilter() {
+	q := strings.ToLower(m.fm.filter)
+	m.fm.visible = m.fm.visible[:0]
+	for i, e := range m.fm.entries {
+		if flagEntryMatches(e, q) {
+			m.fm.visible = append(m.fm.visible, i)
+		}
+	}
+	m.fm.cursor = max(0, min(m.fm.cursor, len(m.fm.visible)-1))
+	m.fm.offset = 0
+}
+
+// flagEntryMatches reports whether the lower-cased query q is a substring of
+// the flag's long name, short name or description.
+func flagEntryMatches(e flagEntry, q string) bool {
+	if q == "" {
+		return true
+	}
+	f := e.flag
+	return strings.Contains(strings.ToLower(f.Name), q) ||
+		(f.ShortName != "" && strings.Contains("-"+strings.ToLower(f.ShortName), q)) ||
+		strings.Contains(strings.ToLower(f.Description), q)
+}
+
+// cursorFlag returns the entry under the cursor, or nil when the filter
+// matches nothing.
+func (m *Model) cursorFlag() *flagEntry {
+	if m.fm.cursor >= len(m.fm.visible) {
+		return nil
+	}
+	return &m.fm.entries[m.fm.visible[m.fm.cursor]]
 }
 
 // updateFlagModal handles keys while the flag picker is open.
@@ -432,23 +667,28 @@ func (m *Model) updateFlagModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if m.fm.awaitingValue {
 		switch msg.String() {
 		case "ctrl+c", "esc":
+			// Cancelling a value also drops the rest of a multi-flag batch.
 			m.fm.awaitingValue = false
 			m.fm.valueInput.SetValue("")
+			m.fm.pending = nil
 			return m, nil
 		case "enter":
 			e := m.fm.entries[m.fm.awaitingIdx]
+			if v, ok := m.fm.suggest.chosen(m.fm.valueInput.Value()); ok {
+				m.fm.valueInput.SetValue(v)
+			}
 			val := strings.TrimSpace(m.fm.valueInput.Value())
+			m.rememberValue(e.flag.Name, val)
 			token := e.flag.Name
 			if val != "" {
-				token += "=" + val
+				token = e.flag.WithValue(val)
 			}
-			m.ensureCommandBase(m.fm.owner)
-			m.preview.AppendToken(token)
-			m.tree.SetCmdTokens(m.preview.Tokens())
-			m.fm.entries[m.fm.awaitingIdx].added = true
-			m.statusMsg = "added: " + token
+			m.addFlagToken(m.fm.awaitingIdx, token)
 			m.fm.awaitingValue = false
 			m.fm.valueInput.SetValue("")
+			return m, m.addPendingFlags()
+		}
+		if m.fm.suggest.update(msg.String(), &m.fm.valueInput) {
 			return m, nil
 		}
 		var cmd tea.Cmd
@@ -456,40 +696,128 @@ func (m *Model) updateFlagModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	}
 
+	// Letters type into the filter, so navigation uses arrows and Ctrl keys.
 	switch msg.String() {
-	case "ctrl+c", "esc", "q":
+	case "ctrl+c":
 		m.fm.active = false
-	case "up", "k":
+	case "esc":
+		// Esc clears a filter first, then closes.
+		if m.fm.filter != "" {
+			m.fm.filter = ""
+			m.applyFlagFilter()
+		} else {
+			m.fm.active = false
+		}
+	case "up", "ctrl+p":
 		if m.fm.cursor > 0 {
 			m.fm.cursor--
 		}
-	case "down", "j":
-		if m.fm.cursor < len(m.fm.entries)-1 {
+	case "down", "ctrl+n":
+		if m.fm.cursor < len(m.fm.visible)-1 {
 			m.fm.cursor++
 		}
-	case "enter", " ":
-		e := m.fm.entries[m.fm.cursor]
-		if !e.added {
-			vt := strings.ToLower(e.flag.ValueType)
-			if vt != "" && vt != "bool" {
-				// Non-bool flag: prompt for a value before adding.
-				m.fm.awaitingValue = true
-				m.fm.awaitingIdx = m.fm.cursor
-				m.fm.valueInput.Placeholder = "value for " + e.flag.Name
-				m.fm.valueInput.SetValue("")
-				m.fm.valueInput.Focus()
-				return m, textinput.Blink
+	case "pgup":
+		m.fm.cursor = max(0, m.fm.cursor-10)
+	case "pgdown":
+		m.fm.cursor = max(0, min(len(m.fm.visible)-1, m.fm.cursor+10))
+	case " ":
+		if e := m.cursorFlag(); e != nil && !e.added {
+			e.marked = !e.marked
+		}
+	case "backspace":
+		if r := []rune(m.fm.filter); len(r) > 0 {
+			m.fm.filter = string(r[:len(r)-1])
+			m.applyFlagFilter()
+		}
+	case "ctrl+u":
+		m.fm.filter = ""
+		m.applyFlagFilter()
+	case "/":
+		// Typing filters directly; "/" is accepted out of habit and ignored.
+	case "enter":
+		// Enter adds every marked flag, or the flag under the cursor when
+		// none are marked.
+		m.fm.pending = nil
+		for i, e := range m.fm.entries {
+			if e.marked {
+				m.fm.pending = append(m.fm.pending, i)
 			}
-			m.ensureCommandBase(m.fm.owner)
-			m.preview.AppendToken(e.flag.Name)
-			m.tree.SetCmdTokens(m.preview.Tokens())
-			m.fm.entries[m.fm.cursor].added = true
-			m.statusMsg = "added: " + e.flag.Name
+		}
+		if e := m.cursorFlag(); len(m.fm.pending) == 0 && e != nil && !e.added {
+			m.fm.pending = []int{m.fm.visible[m.fm.cursor]}
+		}
+		return m, m.addPendingFlags()
+	default:
+		if msg.Type == tea.KeyRunes {
+			m.fm.filter += string(msg.Runes)
+			m.applyFlagFilter()
 		}
 	}
 	return m, nil
 }
 
+// addPendingFlags adds the queued flag entries in order. Bool flags are
+// added directly; it stops at the first flag that takes a value and
+// prompts for it, resuming once the value is confirmed.
+func (m *Model) addPendingFlags() tea.Cmd {
+	for len(m.fm.pending) > 0 {
+		idx := m.fm.pending[0]
+		m.fm.pending = m.fm.pending[1:]
+		e := &m.fm.entries[idx]
+		e.marked = false
+		if e.flag.TakesValue() {
+			// Value flag: prompt for a value before adding.
+			m.fm.awaitingValue = true
+			m.fm.awaitingIdx = idx
+			m.fm.valueInput.Placeholder = "value for " + e.flag.Name
+			m.fm.valueInput.SetValue("")
+			m.fm.suggest = m.suggestionsFor(e.flag.Name)
+			m.fm.valueInput.Focus()
+			return textinput.Blink
+		}
+		if e.flag.Negatable {
+			m.toggleNegatable(e.flag, m.fm.owner)
+			e.setNegatable(m.preview.Tokens(), knownFlags(m.fm.owner, m.root))
+			continue
+		}
+		m.addFlagToken(idx, e.flag.Name)
+	}
+	return nil
+}
+
+// addFlagToken appends token for flag entry idx to the preview.
+func (m *Model) addFlagToken(idx int, token string) {
+	m.ensureCommandBase(m.fm.owner)
+	m.preview.AppendToken(token)
+	m.tree.SetCmdTokens(m.preview.Tokens())
+	e := &m.fm.entries[idx]
+	e.count++
+	e.added = !e.flag.Repeatable
+	m.use(m.fm.owner, e.flag.Name)
+	m.statusMsg = "added: " + token
+}
+
+// toggleNegatable moves negatable flag f of owner to its next state in the
+// preview: unset, then on (--color), then off (--no-color), then unset.
+func (m *Model) toggleNegatable(f models.Flag, owner *models.Node) {
+	m.ensureCommandBase(owner)
+	tokens := m.preview.Tokens()
+	switch i, on := negatableState(f, tokens, knownFlags(owner, m.root)); {
+	case i < 0:
+		m.preview.AppendToken(f.Name)
+		m.use(owner, f.Name)
+		m.statusMsg = "added: " + f.Name
+	case on:
+		tokens[i] = f.NegatedName()
+		m.preview.SetCommand(strings.Join(tokens, " "))
+		m.statusMsg = "added: " + f.NegatedName()
+	default:
+		m.statusMsg = "removed: " + tokens[i]
+		m.preview.SetCommand(strings.Join(slices.Delete(tokens, i, i+1), " "))
+	}
+	m.tree.SetCmdTokens(m.preview.Tokens())
+}
+
 // renderFlagModal renders the flag picker as a centered overlay that fills
 // the full terminal height so Bubble Tea clears stale content from the
 // previous frame.
@@ -502,8 +830,8 @@ func (m *Model) renderFlagModal() string {
 	// Calculate how many global-separator rows will be inserted so we can
 	// keep the viewport from overflowing the modal box.
 	hasGlobals := false
-	for _, e := range m.fm.entries {
-		if e.global {
+	for _, idx := range m.fm.visible {
+		if m.fm.entries[idx].global {
 			hasGlobals = true
 			break
 		}
@@ -513,7 +841,7 @@ func (m *Model) renderFlagModal() string {
 		sepRows = 2 // separator line + "global flags" label
 	}
 	const maxVisible = 14
-	vp := min(maxVisible, len(m.fm.entries))
+	vp := min(maxVisible, len(m.fm.visible))
 	// Clamp vp so that entries + separator rows fit inside the box.
 	if sepRows > 0 && vp+sepRows > maxVisible {
 		vp = max(1, maxVisible-sepRows)
@@ -537,8 +865,8 @@ func (m *Model) renderFlagModal() string {
 	inner := modalW - 6
 	var rows []string
 	prevWasLocal := true
-	for i := m.fm.offset; i < m.fm.offset+vp && i < len(m.fm.entries); i++ {
-		e := m.fm.entries[i]
+	for i := m.fm.offset; i < m.fm.offset+vp && i < len(m.fm.visible); i++ {
+		e := m.fm.entries[m.fm.visible[i]]
 		// Insert the "global flags" separator once, before the first global entry.
 		if e.global && prevWasLocal {
 			rows = append(rows, sepStyle.Render(strings.Repeat("─", inner)))
@@ -550,21 +878,33 @@ func (m *Model) renderFlagModal() string {
 		}
 
 		check := "  "
-		if e.added {
+		switch {
+		case e.added, e.flag.Negatable && e.count > 0:
 			check = "✓ "
+		case e.negated:
+			check = "✗ "
+		case e.marked:
+			check = "● "
 		}
 
 		// Flag name coloured by value type.
 		nameColor := flagTypeColor(e.flag.ValueType)
 		nameStr := e.flag.Name
+		if e.flag.Negatable {
+			nameStr = negatableLabel(e.flag)
+		}
 		if e.flag.ShortName != "" {
 			nameStr += ", -" + e.flag.ShortName
 		}
 		// Value-type badge for non-bool flags.
 		typeTag := ""
-		if vt := strings.ToLower(e.flag.ValueType); vt != "" && vt != "bool" {
+		if e.flag.TakesValue() {
 			typeTag = " <" + e.flag.ValueType + ">"
 		}
+		// Repeatable flags show how often they are already in the preview.
+		if e.flag.Repeatable && e.count > 0 {
+			typeTag += fmt.Sprintf(" ×%d", e.count)
+		}
 
 		// Measure available space for description.
 		fullName := nameStr + typeTag
@@ -606,7 +946,7 @@ func (m *Model) renderFlagModal() string {
 			rendered = globalStyle.Render(plain)
 		default:
 			// Normal flag: type-coloured name, faint description.
-			namePart := lipgloss.NewStyle().Foreground(nameColor).Render(check + nameStr)
+			namePart := check + fadeDoubtful(strikeDeprecated(lipgloss.NewStyle().Foreground(nameColor), e.flag.Deprecated), e.flag.Doubtful()).Render(nameStr)
 			typePart := ""
 			if typeTag != "" {
 				typePart = lipgloss.NewStyle().
@@ -622,16 +962,27 @@ func (m *Model) renderFlagModal() string {
 	}
 
 	// Hint line changes when awaiting a value input.
-	hint := "↑↓/jk navigate · Enter add · Esc close"
+	hint := "Type to filter · ↑↓ navigate · Space mark · Enter add · Esc close"
 	if m.fm.awaitingValue {
 		hint = "Type value · Enter confirm · Esc cancel"
+		if n := len(m.fm.pending); n > 0 {
+			hint += fmt.Sprintf(" · %d more", n)
+		}
 	}
 
 	scrollHint := ""
-	if len(m.fm.entries) > vp {
-		scrollHint = fmt.Sprintf(" [%d/%d]", m.fm.cursor+1, len(m.fm.entries))
+	if len(m.fm.visible) > vp {
+		scrollHint = fmt.Sprintf(" [%d/%d]", m.fm.cursor+1, len(m.fm.visible))
 	}
 
+	filterLine := hintStyle.Render("Filter: type to narrow")
+	if m.fm.filter != "" {
+		filterLine = lipgloss.NewStyle().Bold(true).Render("Filter: "+m.fm.filter) +
+			hintStyle.Render(fmt.Sprintf("  %d/%d", len(m.fm.visible), len(m.fm.entries)))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, hintStyle.Render("  no flags match"))
+	}
 	listSection := strings.Join(rows, "\n")
 
 	// Value input prompt (shown when a non-bool flag is selected).
@@ -644,42 +995,32 @@ func (m *Model) renderFlagModal() string {
 			sepStyle.Render(strings.Repeat("─", inner)) + "\n" +
 			promptStyle.Render("Value for "+e.flag.Name+":") + "\n" +
 			m.fm.valueInput.View()
+		if dd := m.fm.suggest.view(m.fm.valueInput.Value(), inner-4); dd != "" {
+			valueSection += "\n" + dd
+		}
 	}
 
-	content := titleStyle.Render("Add Flag"+scrollHint) + "\n" +
-		hintStyle.Render(hint) + "\n\n" +
+	title := "Add Flag"
+	marked := 0
+	for _, e := range m.fm.entries {
+		if e.marked {
+			marked++
+		}
+	}
+	if marked > 0 {
+		title = fmt.Sprintf("Add Flags (%d marked)", marked)
+	}
+	content := titleStyle.Render(title+scrollHint) + "\n" +
+		hintStyle.Render(hint) + "\n" +
+		filterLine + "\n\n" +
 		listSection + valueSection
 
 	box := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
+		Border(boxBorder(m.cfg)).
 		BorderForeground(lipgloss.Color("#5EA4F5")).
 		Padding(0, 2).
 		Width(modalW - 2).
 		Render(content)
 
-	boxH := lipgloss.Height(box)
-	padLeft := (m.width - lipgloss.Width(box)) / 2
-	if padLeft < 0 {
-		padLeft = 0
-	}
-	padTop := (m.height - boxH) / 2
-	if padTop < 0 {
-		padTop = 0
-	}
-	padBottom := max(0, m.height-padTop-boxH)
-
-	// Build a full-height string so Bubble Tea clears old content beneath.
-	blankLine := strings.Repeat(" ", m.width)
-	leftPad := strings.Repeat(" ", padLeft)
-	var sb strings.Builder
-	for i := 0; i < padTop; i++ {
-		sb.WriteString(blankLine + "\n")
-	}
-	for _, line := range strings.Split(box, "\n") {
-		sb.WriteString(leftPad + line + "\n")
-	}
-	for i := 0; i < padBottom; i++ {
-		sb.WriteString(blankLine + "\n")
-	}
-	return sb.String()
+	return m.overlay(box)
 }
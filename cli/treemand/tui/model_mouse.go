@@ -4,19 +4,36 @@ import tea "github.com/charmbracelet/bubbletea"
 
 func (m *Model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 	switch {
+	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft && m.onDivider(msg.X, msg.Y):
+		m.dragging = true
+
+	case msg.Action == tea.MouseActionMotion && m.dragging:
+		if m.width > 0 {
+			m.setPaneRatio(msg.X * 100 / m.width)
+		}
+
+	case msg.Action == tea.MouseActionRelease && m.dragging:
+		m.dragging = false
+
 	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft:
 		m.handleMouseClick(msg.X, msg.Y)
 
 	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonWheelUp:
-		if m.showHelpPane && m.width > 0 && msg.X >= m.treeWidth() {
+		if m.helpWidth() > 0 && msg.X >= m.treeWidth() {
 			m.helpPane.ScrollUp(3)
 		} else {
 			m.tree.Up()
 			m.syncSelected()
 		}
 
+	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonWheelLeft:
+		m.tree.ScrollLeft(hScrollStep)
+
+	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonWheelRight:
+		m.tree.ScrollRight(hScrollStep)
+
 	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonWheelDown:
-		if m.showHelpPane && m.width > 0 && msg.X >= m.treeWidth() {
+		if m.helpWidth() > 0 && msg.X >= m.treeWidth() {
 			m.helpPane.ScrollDown(3)
 		} else {
 			m.tree.Down()
@@ -29,12 +46,15 @@ func (m *Model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 func (m *Model) handleMouseClick(x, y int) {
 	if y < previewBarHeight {
 		m.setFocus(panePreview)
-	} else if m.showHelpPane && m.width > 0 && x >= m.treeWidth() {
+	} else if y < previewBarHeight+breadcrumbHeight {
+		m.setFocus(paneTree)
+		m.clickBreadcrumb(x)
+	} else if m.helpWidth() > 0 && x >= m.treeWidth() {
 		m.setFocus(paneHelp)
 	} else {
 		m.setFocus(paneTree)
-		// Account for the tree pane's border (1 row).
-		treeY := y - previewBarHeight - 1
+		// Account for the breadcrumb bar and the tree pane's border (1 row).
+		treeY := y - previewBarHeight - breadcrumbHeight - 1
 		if m.tree.SelectAtY(treeY) {
 			m.syncSelected()
 		} else {
@@ -43,3 +63,13 @@ func (m *Model) handleMouseClick(x, y int) {
 	}
 	m.statusMsg = "focus: " + paneName(m.focusedPane)
 }
+
+// onDivider reports whether (x, y) is on the border between the tree and
+// help panes, where a drag resizes them.
+func (m *Model) onDivider(x, y int) bool {
+	if m.helpWidth() == 0 || m.treeWidth() == 0 || y < previewBarHeight+breadcrumbHeight {
+		return false
+	}
+	tw := m.treeWidth()
+	return x == tw-1 || x == tw
+}
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
@@ -18,26 +19,78 @@ func (m *Model) applyLayout() {
 }
 
 func (m *Model) contentHeight() int {
-	h := m.height - previewBarHeight - 1
+	h := m.height - previewBarHeight - breadcrumbHeight - 1
 	if h < 1 {
 		return 1
 	}
 	return h
 }
 
-func (m *Model) treeWidth() int {
-	if m.showHelpPane && m.width >= 80 {
-		tw := m.width * 55 / 100
-		if tw < 30 {
-			tw = 30
-		}
-		return tw
+// Pane split limits: the tree pane takes between minPaneRatio and
+// maxPaneRatio percent of the width, adjusted by paneRatioStep with < and >.
+const (
+	minPaneRatio  = 20
+	maxPaneRatio  = 80
+	paneRatioStep = 5
+)
+
+// paneWidths returns the widths of the tree and help panes. The help pane
+// is hidden (width 0) when toggled off, in terminals narrower than 80
+// columns, or while the tree is zoomed; a zoomed help pane hides the tree.
+func (m *Model) paneWidths() (tree, help int) {
+	switch {
+	case m.zoomed && m.focusedPane == paneHelp && m.showHelpPane:
+		return 0, m.width
+	case m.zoomed || !m.showHelpPane || m.width < 80:
+		return m.width, 0
+	}
+	tw := m.width * m.paneRatio() / 100
+	if tw < 30 {
+		tw = 30
 	}
-	return m.width
+	return tw, m.width - tw
+}
+
+func (m *Model) treeWidth() int {
+	tw, _ := m.paneWidths()
+	return tw
 }
 
 func (m *Model) helpWidth() int {
-	return m.width - m.treeWidth()
+	_, hw := m.paneWidths()
+	return hw
+}
+
+// paneRatio returns the configured tree pane width percentage, clamped to
+// the allowed range.
+func (m *Model) paneRatio() int {
+	r := m.cfg.PaneRatio
+	if r == 0 {
+		r = 55
+	}
+	return clampRatio(r)
+}
+
+func clampRatio(r int) int {
+	return max(minPaneRatio, min(maxPaneRatio, r))
+}
+
+// toggleZoom makes the focused pane fill the width, or restores the split.
+func (m *Model) toggleZoom() {
+	m.zoomed = !m.zoomed
+	m.applyLayout()
+	if m.zoomed {
+		m.statusMsg = "zoom: " + paneName(m.focusedPane)
+	} else {
+		m.statusMsg = "zoom: off"
+	}
+}
+
+// setPaneRatio changes the split and re-lays out the panes.
+func (m *Model) setPaneRatio(r int) {
+	m.cfg.PaneRatio = clampRatio(r)
+	m.applyLayout()
+	m.statusMsg = fmt.Sprintf("split: %d/%d", m.cfg.PaneRatio, 100-m.cfg.PaneRatio)
 }
 
 func (m *Model) View() string {
@@ -45,9 +98,30 @@ func (m *Model) View() string {
 		return ""
 	}
 
+	if m.tour.active {
+		return m.renderTourModal()
+	}
 	if m.kb.active {
 		return m.renderKeybindModal()
 	}
+	if m.rh.active {
+		return m.renderRawHelpModal()
+	}
+	if m.msgs.active {
+		return m.renderMessagesModal()
+	}
+	if m.sm.active {
+		return m.renderSnippetsModal()
+	}
+	if m.rl.active {
+		return m.renderRunsModal()
+	}
+	if m.em.active {
+		return m.renderEnvModal()
+	}
+	if m.cmp.active {
+		return m.renderCompareModal()
+	}
 	if m.modal.active {
 		return m.renderModal()
 	}
@@ -57,69 +131,118 @@ func (m *Model) View() string {
 	if m.vm.active {
 		return m.renderValueInputModal()
 	}
+	if m.nm.active {
+		return m.renderNoteModal()
+	}
+
+	if box, x := m.renderWordPopup(); box != "" {
+		return placeOverlayAt(m.renderPanes(), box, x, previewBarHeight, m.height)
+	}
+	return m.renderPanes()
+}
+
+// renderPanes renders the screen beneath the modals: the preview bar, the
+// breadcrumb, the tree and help panes and the status bar, or the plain
+// view in their place.
+func (m *Model) renderPanes() string {
+	if m.plain {
+		return m.renderPlainView()
+	}
 
 	previewBar := m.preview.View(m.width)
 	statusBar := m.renderStatusBar()
 
 	cH := m.contentHeight()
-	treeView := m.tree.ViewSized(m.treeWidth(), cH)
+	tw, hw := m.paneWidths()
 
 	var body string
-	if m.showHelpPane && m.helpWidth() > 20 {
-		helpView := m.helpPane.View(m.helpWidth(), cH)
-		body = lipgloss.JoinHorizontal(lipgloss.Top, treeView, helpView)
-	} else {
-		body = treeView
+	switch {
+	case tw == 0:
+		body = m.helpPane.View(hw, cH)
+	case hw > 20:
+		body = lipgloss.JoinHorizontal(lipgloss.Top, m.tree.ViewSized(tw, cH), m.helpPane.View(hw, cH))
+	default:
+		body = m.tree.ViewSized(tw, cH)
 	}
 
-	return lipgloss.JoinVertical(lipgloss.Left, previewBar, body, statusBar)
+	return lipgloss.JoinVertical(lipgloss.Left, previewBar, m.renderBreadcrumb(), body, statusBar)
 }
 
-func (m *Model) renderStatusBar() string {
-	// Left side: what is currently selected / focused item context.
+// selectedLabel names the selected row: "git remote add", "commit --message
+// <string>" or "commit <msg>".
+func (m *Model) selectedLabel() string {
+	sel := m.tree.SelectedItem()
+	if sel == nil {
+		return ""
+	}
 	selected := ""
-	if sel := m.tree.SelectedItem(); sel != nil {
-		switch sel.Kind {
-		case SelFlag:
-			selected = sel.Flag.Name
-			if sel.Flag.ValueType != "" && sel.Flag.ValueType != "bool" {
-				selected += " <" + sel.Flag.ValueType + ">"
-			}
-			if sel.Owner != nil {
-				selected = sel.Owner.Name + " " + selected
-			}
-		case SelPositional:
-			selected = "<" + sel.Positional.Name + ">"
-			if sel.Owner != nil {
-				selected = sel.Owner.Name + " " + selected
-			}
-		default:
-			if sel.Node != nil {
-				selected = sel.Node.FullCommand()
-			}
+	switch sel.Kind {
+	case SelFlag:
+		selected = sel.Flag.Name
+		if sel.Flag.TakesValue() {
+			selected += " <" + sel.Flag.ValueType + ">"
+		}
+		if sel.Owner != nil {
+			selected = sel.Owner.Name + " " + selected
+		}
+	case SelPositional:
+		selected = "<" + sel.Positional.Name + ">"
+		if sel.Owner != nil {
+			selected = sel.Owner.Name + " " + selected
+		}
+	default:
+		if sel.Node != nil {
+			selected = sel.Node.FullCommand()
 		}
 	}
+	return selected
+}
+
+func (m *Model) renderStatusBar() string {
+	// Left side: what is currently selected / focused item context.
+	selected := m.selectedLabel()
+	status := m.statusMsg
+	if status == "" {
+		status = m.currentStatus()
+	}
 	leftStyle := lipgloss.NewStyle().Bold(true)
-	if m.statusMsg != "" {
+	if status != "" {
 		leftStyle = leftStyle.Foreground(lipgloss.Color("#FFB86C"))
 	}
 	left := leftStyle.Render(selected)
+	if m.commanding {
+		left = m.cmdline.View()
+	}
 
 	// Right side: context-sensitive key hints.
-	// Priority: one-shot statusMsg > timed message (e.g. style name) > contextual hints.
+	// Priority: unexpired status message > contextual hints.
 	var hint string
 	var hintStyle lipgloss.Style
 	schemeIndicator := "[" + schemeName(m.scheme) + "] "
+	if m.Editing() {
+		schemeIndicator = "[" + schemeName(m.scheme) + " · edit] "
+	}
+	if m.pinned != nil {
+		schemeIndicator += "[pinned: " + m.pinned.FullCommand() + "] "
+	}
+	matches := ""
+	if n := m.tree.FilterMatches(); n >= 0 {
+		matches = matchesLabel(n)
+		schemeIndicator += "[/" + m.tree.filter + ": " + matches + "] "
+		matches += "  "
+	}
 	switch {
-	case m.statusMsg != "":
-		hint = m.statusMsg
-		m.statusMsg = ""
+	case status != "":
+		hint = status
 		hintStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C"))
-	case m.timedMsg != "":
-		hint = m.timedMsg
-		hintStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#BD93F9"))
+	case m.commanding && m.Editing():
+		hint = editHint + " · goto PATH · messages · quit  Enter:run  Esc:cancel"
+		hintStyle = lipgloss.NewStyle().Faint(true)
+	case m.commanding:
+		hint = "goto PATH · messages · quit  Enter:run  Esc:cancel"
+		hintStyle = lipgloss.NewStyle().Faint(true)
 	case m.filtering:
-		hint = "type to filter  Enter/Esc:done"
+		hint = matches + "type to filter  Enter/Esc:done"
 		hintStyle = lipgloss.NewStyle().Faint(true)
 	case m.focusedPane == panePreview:
 		hint = "Esc:back  Ctrl+E:exec/copy  Tab:switch"
@@ -140,11 +263,19 @@ func (m *Model) renderStatusBar() string {
 	return left + strings.Repeat(" ", gap) + right
 }
 
+// matchesLabel counts n filter matches: "1 match", "3 matches".
+func matchesLabel(n int) string {
+	if n == 1 {
+		return "1 match"
+	}
+	return fmt.Sprintf("%d matches", n)
+}
+
 // schemeHints returns the key-hint text adapted to the active navigation scheme.
 func (m *Model) schemeHints() string {
 	switch m.scheme {
 	case SchemeVim:
-		return "j/k:nav  h/l:expand/collapse  Enter:pick  e/E:expand/collapse all  Shift+h/l:subtree  S:sections  f:flags  /:filter  Ctrl+P:help  Ctrl+E:exec  gg/G:top/bottom  n/N:search  q:quit"
+		return "j/k:nav  h/l:expand/collapse  Enter:pick  H/L:subtree  Ctrl+D/U:half page  zz:center  S:sections  f:flags  /:filter  ?:help pane  Ctrl+E:exec  gg/G:top/bottom  n/N:search  :help  q:quit"
 	case SchemeWASD:
 		return "w/s:nav  a/d:expand/collapse  Enter:pick  e/E:expand/collapse all  Shift+a/d:subtree  S:sections  f:flags  /:filter  H:help  Ctrl+E:exec  gg/G:top/bottom  n/N:search  q:quit"
 	default:
@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- notes on commands ----------
+
+// NoteStore keeps the user's notes on a CLI's commands, by full command
+// ("kubectl apply"). cache.NoteStore implements it for one CLI.
+type NoteStore interface {
+	Notes() map[string]string
+	// SaveNote stores note on command, deleting it when note is "".
+	SaveNote(command, note string) error
+}
+
+// noteModal is the Ctrl+N prompt editing the note on a command.
+type noteModal struct {
+	active bool
+	node   *models.Node
+	input  textinput.Model
+}
+
+// SetNoteStore sets the store notes are read from and saved to, and shows
+// the saved ones in the help pane. A nil store turns notes off.
+func (m *Model) SetNoteStore(store NoteStore) {
+	m.noteStore = store
+	var notes map[string]string
+	if store != nil {
+		notes = store.Notes()
+	}
+	m.helpPane.SetNotes(notes)
+}
+
+// openNoteModal prompts for the note on the selected command, or on the
+// command of the selected flag or positional.
+func (m *Model) openNoteModal() {
+	if m.noteStore == nil {
+		m.statusMsg = "notes are kept in the cache, which is off"
+		return
+	}
+	sel := m.tree.SelectedItem()
+	if sel == nil {
+		return
+	}
+	node := sel.Node
+	if sel.Kind != SelCommand {
+		node = sel.Owner
+	}
+	in := textinput.New()
+	in.Placeholder = "note… (empty deletes it)"
+	in.CharLimit = 512
+	in.SetValue(m.helpPane.notes[node.FullCommand()])
+	in.Focus()
+	m.nm = noteModal{active: true, node: node, input: in}
+}
+
+func (m *Model) updateNoteModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.nm.active = false
+		command, note := m.nm.node.FullCommand(), strings.TrimSpace(m.nm.input.Value())
+		if err := m.noteStore.SaveNote(command, note); err != nil {
+			m.statusMsg = "could not save the note: " + err.Error()
+			return m, nil
+		}
+		notes := m.helpPane.notes
+		if notes == nil {
+			notes = map[string]string{}
+		}
+		if note == "" {
+			delete(notes, command)
+			m.statusMsg = "deleted the note on " + command
+		} else {
+			notes[command] = note
+			m.statusMsg = "saved the note on " + command
+		}
+		m.helpPane.SetNotes(notes)
+		return m, nil
+	case "esc", "ctrl+c":
+		m.nm.active = false
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.nm.input, cmd = m.nm.input.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) renderNoteModal() string {
+	modalW := min(m.width-8, 72)
+	if modalW < 30 {
+		modalW = 30
+	}
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	hintStyle := lipgloss.NewStyle().Faint(true)
+
+	m.nm.input.Width = modalW - 8
+	inner := titleStyle.Render("Note on "+m.nm.node.FullCommand()) + "\n\n" +
+		m.nm.input.View() + "\n\n" +
+		hintStyle.Render("[Enter] save  [Esc] cancel")
+
+	box := lipgloss.NewStyle().
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(1, 2).
+		Width(modalW - 2).
+		Render(inner)
+
+	return m.overlay(box)
+}
@@ -0,0 +1,55 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ---------- overlays ----------
+
+// overlay composites a modal's box over the panes, centered. The screen
+// keeps showing the panes around the box, and a frame with a modal open
+// differs from the one before only where the box is, so Bubble Tea redraws
+// those lines rather than a screen of blank padding.
+func (m *Model) overlay(box string) string {
+	return placeOverlay(m.renderPanes(), box, m.width, m.height)
+}
+
+// placeOverlay draws fg centered over bg, a screen of w columns and h
+// lines: each line fg covers keeps bg's text to its left and right.
+func placeOverlay(bg, fg string, w, h int) string {
+	x := max(0, (w-lipgloss.Width(fg))/2)
+	y := max(0, (h-lipgloss.Height(fg))/2)
+	return placeOverlayAt(bg, fg, x, y, h)
+}
+
+// placeOverlayAt draws fg over bg, a screen of h lines, its top left
+// corner at column x of line y.
+func placeOverlayAt(bg, fg string, x, y, h int) string {
+	lines := strings.Split(bg, "\n")
+	for len(lines) < h {
+		lines = append(lines, "")
+	}
+	lines = lines[:max(h, 1)]
+
+	fgLines := strings.Split(fg, "\n")
+	fgW := lipgloss.Width(fg)
+	for i, fl := range fgLines {
+		if y+i >= len(lines) {
+			break
+		}
+		bl := lines[y+i]
+		left := ansi.Truncate(bl, x, "")
+		left += strings.Repeat(" ", x-ansi.StringWidth(left))
+		fl += strings.Repeat(" ", max(0, fgW-ansi.StringWidth(fl)))
+		if strings.Contains(left, "\x1b[") {
+			// Reset, so a style cut in two there does not run on into
+			// the box.
+			left += "\x1b[0m"
+		}
+		lines[y+i] = left + fl + ansi.TruncateLeft(bl, x+fgW, "")
+	}
+	return strings.Join(lines, "\n")
+}
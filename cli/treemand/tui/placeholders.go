@@ -0,0 +1,86 @@
+package tui
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------- placeholders ----------
+
+// placeholderRe matches a placeholder in a command, such as {{namespace}}.
+var placeholderRe = regexp.MustCompile(`\{\{([A-Za-z_][A-Za-z0-9_-]*)\}\}`)
+
+// placeholders returns the names of the placeholders in command, each
+// once, in the order they first appear.
+func placeholders(command string) []string {
+	var names []string
+	for _, m := range placeholderRe.FindAllStringSubmatch(command, -1) {
+		if !slices.Contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// fillPlaceholders replaces every placeholder in command that values has
+// a value for.
+func fillPlaceholders(command string, values map[string]string) string {
+	return placeholderRe.ReplaceAllStringFunc(command, func(p string) string {
+		if v, ok := values[p[2:len(p)-2]]; ok {
+			return v
+		}
+		return p
+	})
+}
+
+// variableKey is the key the values of placeholder name are remembered
+// under in the history, apart from those of flags.
+func variableKey(name string) string { return "{{" + name + "}}" }
+
+// promptPlaceholder opens the value input for the first placeholder left
+// to fill, returning nil when there is none. With chain, that is the
+// first in the command about to run without a value in m.fills, and the
+// preview keeps its placeholders, so the command can be run again with
+// other values; without, it is the first in the preview, which the value
+// replaces.
+func (m *Model) promptPlaceholder(chain bool) tea.Cmd {
+	var name string
+	if chain {
+		for _, n := range placeholders(m.execCommand()) {
+			if _, ok := m.fills[n]; !ok {
+				name = n
+				break
+			}
+		}
+	} else if names := placeholders(strings.Join(m.preview.Tokens(), " ")); len(names) > 0 {
+		name = names[0]
+	}
+	if name == "" {
+		return nil
+	}
+	vi := textinput.New()
+	vi.Placeholder = name
+	vi.CharLimit = 256
+	vi.Focus()
+	m.vm = valueInputModal{
+		active:   true,
+		label:    variableKey(name),
+		input:    vi,
+		chain:    chain,
+		suggest:  m.suggestionsFor(variableKey(name)),
+		variable: name,
+	}
+	return textinput.Blink
+}
+
+// fillPlaceholder replaces every {{name}} in the preview with value.
+func (m *Model) fillPlaceholder(name, value string) {
+	command := strings.ReplaceAll(strings.Join(m.preview.Tokens(), " "), variableKey(name), value)
+	m.preview.SetCommand(command)
+	m.tree.SetCmdTokens(m.preview.Tokens())
+	m.statusMsg = variableKey(name) + " = " + value
+}
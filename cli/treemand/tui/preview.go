@@ -16,6 +16,7 @@ import (
 // highlight nodes that match the typed tokens.
 type PreviewModel struct {
 	node    *models.Node
+	root    *models.Node // resolves the previewed command's positional slots
 	cfg     *config.Config
 	focused bool
 	ti      textinput.Model
@@ -37,6 +38,13 @@ func (p *PreviewModel) SetNode(node *models.Node) {
 	}
 }
 
+// SetRoot sets the tree the previewed command is resolved against. With a
+// root set, the unfocused preview shows placeholders for the command's
+// unfilled positional arguments.
+func (p *PreviewModel) SetRoot(root *models.Node) {
+	p.root = root
+}
+
 func (p *PreviewModel) SetFocused(focused bool) {
 	p.focused = focused
 	if focused {
@@ -47,13 +55,10 @@ func (p *PreviewModel) SetFocused(focused bool) {
 	}
 }
 
-// Tokens returns whitespace-split tokens of the current textinput value.
+// Tokens returns the words of the current textinput value, split as a shell
+// splits them (see splitWords): "fix the bug" in quotes is one.
 func (p *PreviewModel) Tokens() []string {
-	v := strings.TrimSpace(p.ti.Value())
-	if v == "" {
-		return nil
-	}
-	return strings.Fields(v)
+	return splitWords(p.ti.Value())
 }
 
 // SetCommand replaces the preview with an explicit command string.
@@ -78,18 +83,36 @@ func (p *PreviewModel) ClearAll() {
 	p.ti.CursorEnd()
 }
 
-// RemoveLastToken removes the last whitespace-delimited token from the preview.
+// RemoveLastToken removes the last word from the preview, a quoted string
+// whole.
 func (p *PreviewModel) RemoveLastToken() {
-	cur := strings.TrimSpace(p.ti.Value())
-	if cur == "" {
+	words := p.Tokens()
+	if len(words) == 0 {
 		return
 	}
-	idx := strings.LastIndex(cur, " ")
-	if idx < 0 {
-		p.ti.SetValue("")
-	} else {
-		p.ti.SetValue(cur[:idx])
-	}
+	p.ti.SetValue(strings.Join(words[:len(words)-1], " "))
+	p.ti.CursorEnd()
+}
+
+// typed returns the text left of the cursor, and whether the cursor is at
+// the end of the line.
+func (p *PreviewModel) typed() (string, bool) {
+	v := []rune(p.ti.Value())
+	pos := min(p.ti.Position(), len(v))
+	return string(v[:pos]), pos == len(v)
+}
+
+// textStart returns the column the focused preview's text starts at:
+// after the padding, the "► " label and the textinput's prompt.
+func (p *PreviewModel) textStart() int {
+	return 3 + lipgloss.Width(p.ti.Prompt)
+}
+
+// completeWord replaces the word left of the cursor, partial, with word.
+// The cursor is at the end of the line.
+func (p *PreviewModel) completeWord(partial, word string) {
+	v := p.ti.Value()
+	p.ti.SetValue(v[:len(v)-len(partial)] + word)
 	p.ti.CursorEnd()
 }
 
@@ -106,8 +129,12 @@ func (p *PreviewModel) View(width int) string {
 	if p.focused {
 		borderColor = lipgloss.Color("#5EA4F5")
 	}
+	rule := lipgloss.NormalBorder()
+	if p.cfg.Accessible {
+		rule = lipgloss.ASCIIBorder()
+	}
 	style := lipgloss.NewStyle().
-		Border(lipgloss.NormalBorder(), false, false, true, false).
+		Border(rule, false, false, true, false).
 		BorderForeground(borderColor).
 		Width(width-2).
 		Padding(0, 1)
@@ -135,17 +162,35 @@ func (p *PreviewModel) View(width int) string {
 func (p *PreviewModel) buildColoredPreview() string {
 	tokens := p.Tokens()
 	if len(tokens) == 0 {
-		if p.node != nil {
-			return buildColoredFromTokens(p.node.FullPath, p.cfg)
+		if p.node == nil {
+			return ""
+		}
+		tokens = p.node.FullPath
+	}
+	out := buildColoredFromTokens(tokens, p.root, p.cfg)
+	if p.root == nil {
+		return out
+	}
+	// Unfilled positional slots trail the command as faint placeholders.
+	slotStyle := lipgloss.NewStyle().Faint(true).Italic(true)
+	_, slots := commandSlots(p.root, tokens)
+	for _, s := range slots {
+		if !s.filled {
+			out += " " + slotStyle.Render(s.placeholder())
 		}
-		return ""
 	}
-	return buildColoredFromTokens(tokens, p.cfg)
+	return out
 }
 
 // buildColoredFromTokens renders a manually-typed command with color coding
-// by classifying each token (base CLI, subcommands, flags, values).
-func buildColoredFromTokens(tokens []string, cfg *config.Config) string {
+// by classifying each word (see classifyWords): commands, subcommands,
+// flags, their values and positional arguments. With a root, flags are read
+// against the command's known flags, so a short-flag cluster's attached
+// value ("-n5", "-ofile") is colored as a value and a boolean flag's next
+// word is not. Quoted strings and $(...) substitutions color as one word,
+// the words after "--" as arguments, and a command piped to, or run by
+// xargs, as a command of its own.
+func buildColoredFromTokens(tokens []string, root *models.Node, cfg *config.Config) string {
 	if len(tokens) == 0 {
 		return ""
 	}
@@ -153,26 +198,30 @@ func buildColoredFromTokens(tokens []string, cfg *config.Config) string {
 	subcmdStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(cfg.Colors.Subcmd))
 	flagStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(cfg.Colors.Flag))
 	valueStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(cfg.Colors.Value))
+	argStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(cfg.Colors.Pos))
+	opStyle := lipgloss.NewStyle().Faint(true)
 
 	var parts []string
-	flagNext := false
-	for i, tok := range tokens {
-		switch {
-		case i == 0:
-			parts = append(parts, baseStyle.Render(tok))
-		case flagNext:
-			parts = append(parts, valueStyle.Render(tok))
-			flagNext = false
-		case strings.HasPrefix(tok, "--") || (strings.HasPrefix(tok, "-") && len(tok) == 2):
-			parts = append(parts, flagStyle.Render(tok))
-			if strings.Contains(tok, "=") {
-				flagNext = false
-			} else {
-				flagNext = true // next token may be the value
+	for _, w := range classifyWords(tokens, root) {
+		switch w.kind {
+		case wordCommand:
+			parts = append(parts, baseStyle.Render(w.text))
+		case wordSubcommand:
+			parts = append(parts, subcmdStyle.Render(w.text))
+		case wordFlag:
+			part := flagStyle.Render(w.text[:w.flag.nameLen])
+			if w.flag.attached {
+				part += valueStyle.Render(w.text[w.flag.nameLen:])
 			}
+			parts = append(parts, part)
+		case wordValue:
+			parts = append(parts, valueStyle.Render(w.text))
+		case wordArg:
+			parts = append(parts, argStyle.Render(w.text))
+		case wordOperator:
+			parts = append(parts, opStyle.Render(w.text))
 		default:
-			parts = append(parts, subcmdStyle.Render(tok))
-			flagNext = false
+			parts = append(parts, w.text)
 		}
 	}
 	return strings.Join(parts, " ")
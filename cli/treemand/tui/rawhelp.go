@@ -0,0 +1,156 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- raw help pager (m) ----------
+
+// rawHelpModal is the m key overlay: a full-screen pager over the selected
+// node's unparsed --help output.
+type rawHelpModal struct {
+	active bool
+	node   *models.Node
+	offset int
+}
+
+var (
+	// rawHelpHeaderRe matches section header lines: "Flags:", "Available
+	// Commands:", or man-page style "OPTIONS". Headers are not indented.
+	rawHelpHeaderRe = regexp.MustCompile(`^(?:[A-Za-z][A-Za-z0-9 /_-]{0,40}:|[A-Z][A-Z0-9 _-]{2,40})\s*$`)
+	// rawHelpUsageRe matches the "usage:" label that starts a usage line.
+	rawHelpUsageRe = regexp.MustCompile(`(?i)^usage:`)
+	// rawHelpFlagRe matches -x / --long-flag tokens. The first group is the
+	// preceding delimiter, kept so hyphenated words are not highlighted.
+	rawHelpFlagRe = regexp.MustCompile(`(^|[\s\[(,|=])(--?[A-Za-z0-9][A-Za-z0-9_-]*)`)
+	rawHelpURLRe  = regexp.MustCompile(`https?://[^\s)>\]"']+`)
+)
+
+// openRawHelp shows the raw help text of the selected command (or the
+// owner of the selected flag or positional).
+func (m *Model) openRawHelp() {
+	node := m.tree.SelectedOrOwner()
+	if node == nil {
+		m.statusMsg = "no node selected"
+		return
+	}
+	if node.HelpText == "" {
+		m.statusMsg = "no raw help for " + node.Name
+		return
+	}
+	m.rh = rawHelpModal{active: true, node: node}
+}
+
+func (m *Model) updateRawHelpModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	page := max(1, m.rawHelpViewport())
+	switch msg.String() {
+	case "ctrl+c", "esc", "q", "m":
+		m.rh.active = false
+	case "up", "k":
+		m.rh.offset--
+	case "down", "j":
+		m.rh.offset++
+	case "pgup", "b", "ctrl+u":
+		m.rh.offset -= page
+	case "pgdown", " ", "ctrl+d":
+		m.rh.offset += page
+	case "g", "home":
+		m.rh.offset = 0
+	case "G", "end":
+		m.rh.offset = len(m.rawHelpLines())
+	}
+	// View clamps the upper bound once the wrapped line count is known.
+	m.rh.offset = max(0, m.rh.offset)
+	return m, nil
+}
+
+// rawHelpViewport is the number of help lines visible in the pager.
+func (m *Model) rawHelpViewport() int {
+	return max(1, m.height-4)
+}
+
+// rawHelpLines returns the raw help text wrapped to the pager width.
+func (m *Model) rawHelpLines() []string {
+	innerW := max(1, m.width-6)
+	text := strings.ReplaceAll(m.rh.node.HelpText, "\t", "    ")
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		lines = append(lines, wordWrap(strings.TrimRight(line, " "), innerW)...)
+	}
+	return lines
+}
+
+func (m *Model) renderRawHelpModal() string {
+	lines := m.rawHelpLines()
+	vp := m.rawHelpViewport()
+	if m.rh.offset > len(lines)-vp {
+		m.rh.offset = max(0, len(lines)-vp)
+	}
+	end := min(len(lines), m.rh.offset+vp)
+
+	visible := make([]string, 0, vp)
+	for _, line := range lines[m.rh.offset:end] {
+		visible = append(visible, m.highlightHelpLine(line))
+	}
+	for len(visible) < vp {
+		visible = append(visible, "")
+	}
+
+	title := "Raw help: " + m.rh.node.FullCommand()
+	if len(lines) > vp {
+		title += fmt.Sprintf(" [%d%%]", min(100, end*100/len(lines)))
+	}
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	hintStyle := lipgloss.NewStyle().Faint(true)
+	header := titleStyle.Render(title) + "  " + hintStyle.Render("↑↓/jk scroll · Space/b page · g/G top/bottom · Esc close")
+
+	return lipgloss.NewStyle().
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(0, 1).
+		Width(max(1, m.width-2)).
+		Render(clipRow(header, 0, max(1, m.width-4)) + "\n" + strings.Join(visible, "\n"))
+}
+
+// highlightHelpLine colors section headers, flags and URLs in one line of
+// raw help output.
+func (m *Model) highlightHelpLine(line string) string {
+	colors := m.cfg.Colors
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(colors.Subcmd))
+	if rawHelpHeaderRe.MatchString(line) {
+		return headerStyle.Render(line)
+	}
+	prefix := ""
+	if loc := rawHelpUsageRe.FindStringIndex(line); loc != nil {
+		prefix = headerStyle.Render(line[:loc[1]])
+		line = line[loc[1]:]
+	}
+
+	flagStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(colors.Flag))
+	urlStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(colors.Value))
+	highlightFlags := func(s string) string {
+		return rawHelpFlagRe.ReplaceAllStringFunc(s, func(match string) string {
+			sub := rawHelpFlagRe.FindStringSubmatch(match)
+			return sub[1] + flagStyle.Render(sub[2])
+		})
+	}
+
+	// URLs are highlighted as a whole; flags are only looked for between them.
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	last := 0
+	for _, loc := range rawHelpURLRe.FindAllStringIndex(line, -1) {
+		sb.WriteString(highlightFlags(line[last:loc[0]]))
+		sb.WriteString(urlStyle.Render(line[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	sb.WriteString(highlightFlags(line[last:]))
+	return sb.String()
+}
@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- rediscovery ----------
+
+// Reloader discovers the explored tree afresh, never serving it from the
+// cache, and describes the executable it was discovered from. Ctrl+R runs
+// it in the background, to replace a stale tree without leaving the TUI.
+type Reloader func(ctx context.Context) (*models.Node, CLIInfo, error)
+
+// reloadedMsg carries the tree a Reloader discovered.
+type reloadedMsg struct {
+	root *models.Node
+	info CLIInfo
+	err  error
+}
+
+// SetReloader sets how Ctrl+R rediscovers the tree. Without one, as for a
+// tree read from a file, Ctrl+R only says it cannot.
+func (m *Model) SetReloader(r Reloader) {
+	m.reloader = r
+}
+
+// reload rediscovers the tree in the background. It runs the CLI even
+// offline, as the user asked for it.
+func (m *Model) reload() tea.Cmd {
+	switch {
+	case m.reloader == nil:
+		m.statusMsg = "this tree was not discovered from a CLI, so it cannot be rediscovered"
+		return nil
+	case m.reloading:
+		m.statusMsg = "already rediscovering " + m.root.FullCommand() + "…"
+		return nil
+	}
+	m.reloading = true
+	m.statusMsg = "rediscovering " + m.root.FullCommand() + " in the background…"
+	reloader := m.reloader
+	return func() tea.Msg {
+		root, info, err := reloader(context.Background())
+		return reloadedMsg{root: root, info: info, err: err}
+	}
+}
+
+// reloaded puts the rediscovered tree in place of the one explored,
+// keeping the view (see TreeModel.SetRoot) and the command in the preview,
+// and discovers the stubs left expanded in it, and in the background those
+// DiscoverInBackground asked for.
+func (m *Model) reloaded(msg reloadedMsg) tea.Cmd {
+	m.reloading = false
+	if msg.err != nil {
+		m.statusMsg = "rediscovery failed: " + msg.err.Error()
+		return nil
+	}
+	m.root = msg.root
+	m.tree.SetRoot(msg.root)
+	m.preview.SetRoot(msg.root)
+	if m.pinned != nil {
+		m.pinned = msg.root.FindFullPath(m.pinned.FullPath)
+	}
+	m.cliInfo = msg.info
+	m.tree.SetCmdTokens(m.preview.Tokens())
+	m.syncSelected()
+	m.statusMsg = "rediscovered " + msg.root.FullCommand()
+	return tea.Batch(m.discoverExpandedStubs(m.root, 0), m.restartEnriching())
+}
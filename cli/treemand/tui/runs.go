@@ -0,0 +1,249 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- recorded runs ----------
+
+// RunLog keeps the commands of a CLI run from the TUI, with their output.
+// cache.RunLog implements it for one CLI.
+type RunLog interface {
+	// Runs returns the recorded runs, newest first.
+	Runs() []models.Run
+	AddRun(models.Run) error
+}
+
+// runsModal is the :runs overlay: the recorded runs, and the output of one
+// of them once it is opened.
+type runsModal struct {
+	active  bool
+	runs    []models.Run
+	cursor  int
+	viewing bool // the output of runs[cursor] is shown
+	offset  int  // first output line shown
+}
+
+// SetRunLog sets the log :runs reads. Run records commands in it when
+// record_runs is on. A nil log turns :runs off.
+func (m *Model) SetRunLog(log RunLog) { m.runLog = log }
+
+// openRuns opens the :runs overlay.
+func (m *Model) openRuns() {
+	if m.runLog == nil {
+		m.statusMsg = "runs are kept in the cache, which is off"
+		return
+	}
+	m.rl = runsModal{active: true, runs: m.runLog.Runs()}
+}
+
+func (m *Model) updateRunsModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.rl.viewing {
+		page := max(1, m.rawHelpViewport())
+		switch msg.String() {
+		case "ctrl+c":
+			m.rl.active = false
+		case "esc", "q", "enter":
+			m.rl.viewing = false
+		case "up", "k":
+			m.rl.offset--
+		case "down", "j":
+			m.rl.offset++
+		case "pgup", "b", "ctrl+u":
+			m.rl.offset -= page
+		case "pgdown", " ", "ctrl+d":
+			m.rl.offset += page
+		case "g", "home":
+			m.rl.offset = 0
+		case "G", "end":
+			m.rl.offset = len(m.runOutputLines())
+		}
+		// View clamps the upper bound once the wrapped line count is known.
+		m.rl.offset = max(0, m.rl.offset)
+		return m, nil
+	}
+	switch msg.String() {
+	case "ctrl+c", "esc", "q":
+		m.rl.active = false
+	case "up", "k":
+		m.rl.cursor = max(m.rl.cursor-1, 0)
+	case "down", "j":
+		m.rl.cursor = max(min(m.rl.cursor+1, len(m.rl.runs)-1), 0)
+	case "enter":
+		if len(m.rl.runs) > 0 {
+			m.rl.viewing, m.rl.offset = true, 0
+		}
+	case "p":
+		if len(m.rl.runs) == 0 {
+			return m, nil
+		}
+		m.rl.active = false
+		command := m.rl.runs[m.rl.cursor].Command
+		m.preview.SetCommand(command)
+		m.tree.SetCmdTokens(m.preview.Tokens())
+		if node, _ := commandSlots(m.root, m.preview.Tokens()); node != nil {
+			m.tree.SelectNode(node)
+			m.syncSelected()
+		}
+		m.statusMsg = "loaded: " + command
+	}
+	return m, nil
+}
+
+// runSummary describes run in one line: its exit code, when it started
+// and how long it took.
+func runSummary(run models.Run) string {
+	status := "✓ exit 0"
+	switch {
+	case run.ExitCode < 0:
+		status = "✗ failed"
+	case run.ExitCode > 0:
+		status = fmt.Sprintf("✗ exit %d", run.ExitCode)
+	}
+	return fmt.Sprintf("%-9s %s  %s", status, run.StartedAt.Local().Format("2006-01-02 15:04"), run.Duration.Round(time.Millisecond))
+}
+
+// runOutputLines returns the output of the opened run wrapped to the pager
+// width: its stdout, then its stderr under a rule.
+func (m *Model) runOutputLines() []string {
+	run := m.rl.runs[m.rl.cursor]
+	innerW := max(1, m.width-6)
+	wrap := func(text string) []string {
+		var lines []string
+		text = strings.ReplaceAll(text, "\t", "    ")
+		for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+			lines = append(lines, wordWrap(strings.TrimRight(line, " \r"), innerW)...)
+		}
+		return lines
+	}
+	var lines []string
+	if run.Stdout != "" {
+		lines = wrap(run.Stdout)
+	}
+	if run.Stderr != "" {
+		errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(m.cfg.Colors.Invalid))
+		lines = append(lines, lipgloss.NewStyle().Faint(true).Render("── stderr ──"))
+		for _, line := range wrap(run.Stderr) {
+			lines = append(lines, errStyle.Render(line))
+		}
+	}
+	if len(lines) == 0 {
+		lines = []string{lipgloss.NewStyle().Faint(true).Render("(no output)")}
+	}
+	return lines
+}
+
+func (m *Model) renderRunsModal() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	hintStyle := lipgloss.NewStyle().Faint(true)
+	if m.rl.viewing {
+		return m.renderRunOutput(titleStyle, hintStyle)
+	}
+
+	modalW := max(40, min(m.width-6, 100))
+	selStyle := lipgloss.NewStyle().Reverse(true)
+	var lines []string
+	for i, run := range m.rl.runs {
+		line := clipRow(hintStyle.Render(runSummary(run))+"  "+run.Command, 0, modalW-8)
+		if i == m.rl.cursor {
+			line = selStyle.Render("▶ ") + line
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = []string{hintStyle.Render("no runs recorded; set record_runs: true in the config and run a command with Ctrl+E")}
+	}
+	// Scroll so the cursor stays in view.
+	vp := m.messagesViewport()
+	start := max(0, m.rl.cursor-vp+1)
+	end := min(len(lines), start+vp)
+	content := titleStyle.Render("Runs") + "\n" +
+		hintStyle.Render("↑↓/jk select · Enter output · p load into preview · Esc close") + "\n\n" +
+		strings.Join(lines[start:end], "\n")
+
+	box := lipgloss.NewStyle().
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(0, 2).
+		Width(modalW - 2).
+		Render(content)
+	return m.overlay(box)
+}
+
+// renderRunOutput renders the opened run's output as a full-screen pager,
+// like the raw help one.
+func (m *Model) renderRunOutput(titleStyle, hintStyle lipgloss.Style) string {
+	lines := m.runOutputLines()
+	vp := m.rawHelpViewport() - 1
+	if m.rl.offset > len(lines)-vp {
+		m.rl.offset = max(0, len(lines)-vp)
+	}
+	end := min(len(lines), m.rl.offset+vp)
+	visible := append([]string(nil), lines[m.rl.offset:end]...)
+	for len(visible) < vp {
+		visible = append(visible, "")
+	}
+
+	run := m.rl.runs[m.rl.cursor]
+	title := "Run: " + run.Command
+	if len(lines) > vp {
+		title += fmt.Sprintf(" [%d%%]", min(100, end*100/len(lines)))
+	}
+	header := clipRow(titleStyle.Render(title), 0, max(1, m.width-4)) + "\n" +
+		clipRow(hintStyle.Render(runSummary(run)+" · ↑↓/jk scroll · Space/b page · Esc back"), 0, max(1, m.width-4))
+	return lipgloss.NewStyle().
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(0, 1).
+		Width(max(1, m.width-2)).
+		Render(header + "\n" + strings.Join(visible, "\n"))
+}
+
+// tailBuffer keeps the last models.MaxRunOutput bytes written to it.
+type tailBuffer struct{ b []byte }
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.b = append(t.b, p...)
+	if over := len(t.b) - models.MaxRunOutput; over > 0 {
+		t.b = append(t.b[:0:0], t.b[over:]...)
+	}
+	return len(p), nil
+}
+
+// String returns what was kept, any rune cut in two at its start dropped.
+func (t *tailBuffer) String() string { return strings.ToValidUTF8(string(t.b), "") }
+
+// recordRun runs c, which runs command, copying its output to log as well
+// as to where it goes already. A failure to record the run is reported on
+// stderr; c's own error is returned.
+func recordRun(c *exec.Cmd, command string, log RunLog) error {
+	var stdout, stderr tailBuffer
+	c.Stdout = io.MultiWriter(c.Stdout, &stdout)
+	c.Stderr = io.MultiWriter(c.Stderr, &stderr)
+	run := models.Run{Command: command, StartedAt: time.Now()}
+	err := c.Run()
+	run.Duration = time.Since(run.StartedAt)
+	if c.ProcessState != nil {
+		run.ExitCode = c.ProcessState.ExitCode()
+	} else {
+		run.ExitCode = -1
+		fmt.Fprintln(&stderr, err)
+	}
+	run.Stdout, run.Stderr = stdout.String(), stderr.String()
+	if logErr := log.AddRun(run); logErr != nil {
+		fmt.Fprintf(os.Stderr, "treemand: could not record the run: %v\n", logErr)
+	}
+	return err
+}
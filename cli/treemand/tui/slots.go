@@ -0,0 +1,189 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- positional argument slots ----------
+
+// posSlot is one positional argument of the command in the preview, and the
+// token filling it if any.
+type posSlot struct {
+	pos    *models.Positional
+	alts   []*models.Positional // alternatives that fill the slot instead of pos
+	value  string
+	filled bool
+}
+
+// placeholder renders the slot the way usage lines do: <name> when
+// required, [name] when optional, with "..." for variadic arguments and
+// alternatives separated by "|".
+func (s posSlot) placeholder() string {
+	name := s.pos.Name
+	for _, a := range s.alts {
+		name += "|" + a.Name
+	}
+	if s.pos.Variadic {
+		name += "..."
+	}
+	if s.pos.Required {
+		return "<" + name + ">"
+	}
+	return "[" + name + "]"
+}
+
+// resolveCommand finds the deepest command named by tokens, which start with
+// the root's command path ("git", or "git remote" for a tree rooted there),
+// and returns it with the remaining non-flag tokens — the positional
+// arguments given so far. Flags and their values are skipped, and the words
+// are read as classifyWords reads them, so a quoted argument is one and
+// those after "--" are all arguments. Only the first command of a line is
+// read, up to a pipe or other operator. It returns nil when tokens do not
+// start with the root.
+func resolveCommand(root *models.Node, tokens []string) (*models.Node, []string) {
+	if root == nil {
+		return nil, nil
+	}
+	rootPath := root.FullPath
+	if len(rootPath) == 0 {
+		rootPath = []string{root.Name}
+	}
+	if len(tokens) < len(rootPath) || !slices.Equal(tokens[:len(rootPath)], rootPath) {
+		return nil, nil
+	}
+	node := root
+	var args []string
+	for _, w := range classifyWords(tokens, root) {
+		switch w.kind {
+		case wordOperator:
+			return node, args
+		case wordSubcommand:
+			node = w.node
+		case wordArg:
+			args = append(args, w.text)
+		}
+	}
+	return node, args
+}
+
+// findCommand looks up a subcommand of node by name, looking through
+// virtual group nodes, which never appear as tokens themselves.
+func findCommand(node *models.Node, name string) *models.Node {
+	for _, c := range node.Children {
+		if c.Virtual {
+			if found := findCommand(c, name); found != nil {
+				return found
+			}
+			continue
+		}
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// commandSlots returns the command named by tokens and the state of each of
+// its positional slots. Arguments fill the slots in order; a variadic slot
+// takes every remaining argument.
+func commandSlots(root *models.Node, tokens []string) (*models.Node, []posSlot) {
+	node, args := resolveCommand(root, tokens)
+	if node == nil || len(node.Positionals) == 0 {
+		return node, nil
+	}
+	ps := node.PositionalSlots()
+	slots := make([]posSlot, len(ps))
+	for i, alts := range ps {
+		p := alts[0]
+		slots[i].pos, slots[i].alts = p, alts[1:]
+		if len(args) == 0 {
+			continue
+		}
+		if p.Variadic {
+			slots[i].value = strings.Join(args, " ")
+			args = nil
+		} else {
+			slots[i].value = args[0]
+			args = args[1:]
+		}
+		slots[i].filled = true
+	}
+	return node, slots
+}
+
+// nextRequiredSlot returns the index of the first unfilled required slot,
+// or -1 when every required positional has a value.
+func nextRequiredSlot(slots []posSlot) int {
+	for i, s := range slots {
+		if s.pos.Required && !s.filled {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextOpenSlot returns the index of the first unfilled slot, or -1.
+func nextOpenSlot(slots []posSlot) int {
+	for i, s := range slots {
+		if !s.filled {
+			return i
+		}
+	}
+	return -1
+}
+
+// ---------- deprecation warnings ----------
+
+// deprecationWarnings describes the deprecated commands and flags used by
+// the command in tokens, e.g. "--old is deprecated; use --new instead".
+func deprecationWarnings(root *models.Node, tokens []string) []string {
+	node, _ := resolveCommand(root, tokens)
+	if node == nil {
+		return nil
+	}
+	// The command and its ancestors, whose flags it accepts too.
+	chain := []*models.Node{root}
+	for cur, i := root, 1; i < len(node.FullPath) && cur != nil; i++ {
+		if cur = findCommand(cur, node.FullPath[i]); cur != nil {
+			chain = append(chain, cur)
+		}
+	}
+	var warnings []string
+	seen := make(map[string]bool)
+	known := knownFlags(node, root)
+	for _, n := range chain {
+		if n.Deprecated {
+			warnings = append(warnings, deprecationText(n.FullCommand(), n.ReplacedBy))
+		}
+		for _, f := range commandFlags(n) {
+			if !f.Deprecated || seen[f.Name] || flagCount(f, tokens, known) == 0 {
+				continue
+			}
+			seen[f.Name] = true
+			warnings = append(warnings, deprecationText(f.Name, f.ReplacedBy))
+		}
+	}
+	return warnings
+}
+
+// commandFlags returns the flags of node, including those listed under its
+// virtual group children.
+func commandFlags(node *models.Node) []models.Flag {
+	flags := node.Flags
+	for _, c := range node.Children {
+		if c.Virtual {
+			flags = append(slices.Clip(flags), commandFlags(c)...)
+		}
+	}
+	return flags
+}
+
+func deprecationText(name, replacedBy string) string {
+	if replacedBy != "" {
+		return name + " is deprecated; use " + replacedBy + " instead"
+	}
+	return name + " is deprecated"
+}
@@ -0,0 +1,147 @@
+package tui
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- snippets ----------
+
+// SnippetStore keeps the commands saved as snippets of a CLI, by name.
+// cache.SnippetStore implements it for one CLI.
+type SnippetStore interface {
+	Snippets() map[string]string
+	SaveSnippet(name, command string) error
+	DeleteSnippet(name string) error
+}
+
+// snippetsModal is the :snippets overlay listing the saved snippets.
+type snippetsModal struct {
+	active   bool
+	snippets map[string]string
+	names    []string // of snippets, sorted
+	cursor   int
+}
+
+// SetSnippetStore sets the store snippets are saved to. A nil store turns
+// snippets off.
+func (m *Model) SetSnippetStore(store SnippetStore) { m.snippetStore = store }
+
+// saveSnippet saves the command in the preview as the snippet name.
+func (m *Model) saveSnippet(name string) {
+	command := strings.Join(m.preview.Tokens(), " ")
+	switch {
+	case m.snippetStore == nil:
+		m.statusMsg = "snippets are kept in the cache, which is off"
+	case name == "" || strings.ContainsAny(name, " \t"):
+		m.statusMsg = "usage: save NAME"
+	case command == "":
+		m.statusMsg = "save: the preview is empty"
+	default:
+		if err := m.snippetStore.SaveSnippet(name, command); err != nil {
+			m.statusMsg = "could not save the snippet: " + err.Error()
+			return
+		}
+		m.statusMsg = "saved snippet " + name + ": " + command
+	}
+}
+
+// openSnippets opens the :snippets overlay.
+func (m *Model) openSnippets() {
+	if m.snippetStore == nil {
+		m.statusMsg = "snippets are kept in the cache, which is off"
+		return
+	}
+	m.sm = snippetsModal{active: true}
+	m.sm.load(m.snippetStore)
+}
+
+// load reads the snippets from store, keeping the cursor on the list.
+func (s *snippetsModal) load(store SnippetStore) {
+	s.snippets = store.Snippets()
+	s.names = slices.Sorted(maps.Keys(s.snippets))
+	s.cursor = max(min(s.cursor, len(s.names)-1), 0)
+}
+
+func (m *Model) updateSnippetsModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc", "q":
+		m.sm.active = false
+	case "up", "k":
+		m.sm.cursor = max(m.sm.cursor-1, 0)
+	case "down", "j":
+		m.sm.cursor = min(m.sm.cursor+1, len(m.sm.names)-1)
+	case "x", "delete":
+		if len(m.sm.names) == 0 {
+			return m, nil
+		}
+		name := m.sm.names[m.sm.cursor]
+		if err := m.snippetStore.DeleteSnippet(name); err != nil {
+			m.statusMsg = "could not delete the snippet: " + err.Error()
+			return m, nil
+		}
+		m.statusMsg = "deleted snippet " + name
+		m.sm.load(m.snippetStore)
+	case "enter":
+		if len(m.sm.names) == 0 {
+			return m, nil
+		}
+		m.sm.active = false
+		name := m.sm.names[m.sm.cursor]
+		return m, m.loadSnippet(name, m.sm.snippets[name])
+	}
+	return m, nil
+}
+
+// loadSnippet puts command into the preview and prompts for each of its
+// placeholders in turn.
+func (m *Model) loadSnippet(name, command string) tea.Cmd {
+	m.preview.SetCommand(command)
+	m.tree.SetCmdTokens(m.preview.Tokens())
+	if node, _ := commandSlots(m.root, m.preview.Tokens()); node != nil {
+		m.tree.SelectNode(node)
+		m.syncSelected()
+	}
+	m.statusMsg = "loaded snippet " + name
+	return m.promptPlaceholder(false)
+}
+
+func (m *Model) renderSnippetsModal() string {
+	modalW := max(40, min(m.width-6, 80))
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	hintStyle := lipgloss.NewStyle().Faint(true)
+	selStyle := lipgloss.NewStyle().Reverse(true)
+
+	var lines []string
+	for i, name := range m.sm.names {
+		line := clipRow(name+"  "+hintStyle.Render(m.sm.snippets[name]), 0, modalW-8)
+		if i == m.sm.cursor {
+			line = selStyle.Render("▶ ") + line
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = []string{hintStyle.Render("no snippets yet; build a command and :save NAME")}
+	}
+	// Scroll so the cursor stays in view.
+	vp := m.messagesViewport()
+	start := max(0, m.sm.cursor-vp+1)
+	end := min(len(lines), start+vp)
+	content := titleStyle.Render("Snippets") + "\n" +
+		hintStyle.Render("↑↓/jk select · Enter load · x delete · Esc close") + "\n\n" +
+		strings.Join(lines[start:end], "\n")
+
+	box := lipgloss.NewStyle().
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(0, 2).
+		Width(modalW - 2).
+		Render(content)
+	return m.overlay(box)
+}
@@ -0,0 +1,68 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------- running in a terminal split ----------
+
+// splitDoneMsg reports the launch of a command in a terminal split.
+type splitDoneMsg struct {
+	where   string // "tmux pane", ...
+	command string
+	err     error
+}
+
+// terminalSplit returns the name of a new split in the terminal treemand
+// runs in and the command that opens one running the shell script script,
+// or "" when that terminal can't be split. tmux, kitty (with remote control
+// on) and WezTerm are told apart by the variables each sets in the
+// environment of the programs it runs.
+func terminalSplit(script string) (where string, args []string) {
+	switch {
+	case os.Getenv("TMUX") != "":
+		return "tmux pane", []string{"tmux", "split-window", "-h", "sh", "-c", script}
+	case os.Getenv("KITTY_WINDOW_ID") != "":
+		return "kitty split", []string{"kitty", "@", "launch", "--location=vsplit", "--cwd=current", "sh", "-c", script}
+	case os.Getenv("WEZTERM_PANE") != "":
+		return "WezTerm pane", []string{"wezterm", "cli", "split-pane", "--right", "--", "sh", "-c", script}
+	}
+	return "", nil
+}
+
+// runInSplit launches the modal's command in a new split of the terminal,
+// keeping the TUI open. The split waits for Enter once the command exits,
+// so its output can be read.
+func (m *Model) runInSplit() tea.Cmd {
+	command := m.modal.command
+	script := command + `; printf '\n[exit %s] press Enter to close' "$?"; read _`
+	where, args := terminalSplit(script)
+	if where == "" {
+		m.statusMsg = "not in tmux, kitty or WezTerm, so there is no split to run in"
+		return nil
+	}
+	m.modal.active = false
+	m.useCommandLine(command)
+	m.statusMsg = "starting in a " + where + ": " + command
+	return func() tea.Msg {
+		out, err := exec.Command(args[0], args[1:]...).CombinedOutput() //nolint:gosec
+		if err != nil && len(out) > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return splitDoneMsg{where: where, command: command, err: err}
+	}
+}
+
+// applySplitDone reports the outcome of runInSplit in the status bar.
+func (m *Model) applySplitDone(msg splitDoneMsg) {
+	if msg.err != nil {
+		m.statusMsg = "could not open a " + msg.where + ": " + msg.err.Error()
+		return
+	}
+	m.statusMsg = "running in a " + msg.where + ": " + msg.command
+}
@@ -0,0 +1,118 @@
+package tui
+
+import (
+	"encoding/json"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// StateStore persists the explorer's view state (expanded nodes and
+// sections, last selection) between sessions. cache.StateStore implements
+// it for one CLI.
+type StateStore interface {
+	LoadState() ([]byte, bool)
+	SaveState(data []byte)
+}
+
+// viewState is the saved form of the tree's view. Nodes and sections are
+// identified by the same path-based keys the tree uses internally, so they
+// survive re-discovery as long as the command paths still exist.
+type viewState struct {
+	Expanded []string        `json:"expanded,omitempty"`
+	Sections map[string]bool `json:"sections,omitempty"`
+	Selected []string        `json:"selected,omitempty"`
+}
+
+// SetStateStore sets the store the view state is saved to and restores the
+// state saved by the previous session, if any. A nil store disables it.
+func (m *Model) SetStateStore(store StateStore) {
+	m.stateStore = store
+	if store == nil {
+		return
+	}
+	data, ok := store.LoadState()
+	if !ok {
+		return
+	}
+	var st viewState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return
+	}
+	m.tree.restoreState(st)
+	m.syncSelected()
+}
+
+// SaveState writes the current view state to the store set with
+// SetStateStore. Run calls it when the explorer exits.
+func (m *Model) SaveState() {
+	if m.stateStore == nil {
+		return
+	}
+	data, err := json.Marshal(m.tree.viewState())
+	if err != nil {
+		return
+	}
+	m.stateStore.SaveState(data)
+}
+
+// discoverExpandedStubs discovers stubs below node that are marked expanded,
+// typically because the previous session expanded them, so their children
+// appear without another keypress. It runs again for each discovered stub,
+// restoring deep subtrees one level at a time.
+func (m *Model) discoverExpandedStubs(node *models.Node, depth int) tea.Cmd {
+	var cmds []tea.Cmd
+	var walk func(n *models.Node, depth int)
+	walk = func(n *models.Node, depth int) {
+		if !m.tree.nodeExpanded[nodeKey(n, depth)] {
+			return
+		}
+		if n.Stub {
+			cmds = append(cmds, m.discoverStub(n))
+			return
+		}
+		for _, c := range n.Children {
+			if !c.Virtual {
+				walk(c, depth+1)
+			}
+		}
+	}
+	walk(node, depth)
+	return tea.Batch(cmds...)
+}
+
+// viewState captures which nodes and sections are expanded and which
+// command is selected.
+func (t *TreeModel) viewState() viewState {
+	st := viewState{Sections: make(map[string]bool, len(t.sectionExpanded))}
+	for k, on := range t.nodeExpanded {
+		if on {
+			st.Expanded = append(st.Expanded, k)
+		}
+	}
+	sort.Strings(st.Expanded)
+	for k, on := range t.sectionExpanded {
+		st.Sections[k] = on
+	}
+	if node := t.SelectedOrOwner(); node != nil {
+		st.Selected = node.FullPath
+	}
+	return st
+}
+
+// restoreState applies a saved view state. Keys of commands that no longer
+// exist are harmless and simply never match a row.
+func (t *TreeModel) restoreState(st viewState) {
+	for _, k := range st.Expanded {
+		t.nodeExpanded[k] = true
+	}
+	for k, on := range st.Sections {
+		t.sectionExpanded[k] = on
+	}
+	t.rebuild()
+	if node := t.root.FindFullPath(st.Selected); node != nil && len(st.Selected) > 0 {
+		t.SelectNode(node)
+	}
+}
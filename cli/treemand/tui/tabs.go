@@ -0,0 +1,198 @@
+package tui
+
+import (
+	"reflect"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- tabs ----------
+
+// tabBarHeight is the height of the tab bar shown above the tabs.
+const tabBarHeight = 1
+
+// teaPkg is the package of the messages Bubble Tea itself handles, such as
+// tea.QuitMsg and tea.BatchMsg, which Tabs leaves untagged.
+var teaPkg = reflect.TypeOf(tea.QuitMsg{}).PkgPath()
+
+// tabMsg is a message a tab's command produced, tagged with the tab so
+// that it goes back to that tab even once another one is shown.
+type tabMsg struct {
+	tab int
+	msg tea.Msg
+}
+
+// Tabs shows several explorers in one session, one CLI each, with a tab
+// bar above them: { and } switch tabs, as does a click on the bar. Each
+// tab keeps its own tree, preview and help pane. With one tab the bar is
+// left out and the tab fills the screen.
+type Tabs struct {
+	tabs   []*Model
+	active int
+	width  int
+	height int
+}
+
+// NewTabs creates a session showing tabs, the first one active.
+func NewTabs(tabs ...*Model) *Tabs { return &Tabs{tabs: tabs} }
+
+// Active returns the tab shown.
+func (t *Tabs) Active() *Model { return t.tabs[t.active] }
+
+// RunTabs runs the TUI on tabs, which NewModel creates. If the user chose
+// "Run" in the Ctrl+E modal of one, it executes the command after the TUI
+// exits. tour opens the guided tour on start, as for the first launch.
+func RunTabs(tabs []*Model, tour bool) error {
+	if tour {
+		tabs[0].StartTour()
+	}
+	p := tea.NewProgram(NewTabs(tabs...),
+		tea.WithAltScreen(),
+		tea.WithMouseAllMotion(),
+	)
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+	for _, m := range tabs {
+		m.SaveState()
+	}
+	for _, m := range tabs {
+		if m.CommandToRun() != "" {
+			return runCommand(m)
+		}
+	}
+	return nil
+}
+
+func (t *Tabs) Init() tea.Cmd {
+	cmds := make([]tea.Cmd, len(t.tabs))
+	for i, m := range t.tabs {
+		cmds[i] = t.tag(i, m.Init())
+	}
+	return tea.Batch(cmds...)
+}
+
+// Update forwards msg to the tab shown, or to the tab it is tagged with.
+// The tab bar takes the keys and clicks that switch tabs.
+func (t *Tabs) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tabMsg:
+		_, cmd := t.tabs[msg.tab].Update(msg.msg)
+		return t, t.tag(msg.tab, cmd)
+	case tea.WindowSizeMsg:
+		t.width, t.height = msg.Width, msg.Height
+		for _, m := range t.tabs {
+			m.SetSize(msg.Width, max(1, msg.Height-t.barHeight()))
+		}
+		return t, nil
+	case tea.KeyMsg:
+		if len(t.tabs) > 1 && !t.Active().capturesKeys() {
+			switch msg.String() {
+			case "}":
+				t.active = (t.active + 1) % len(t.tabs)
+				return t, nil
+			case "{":
+				t.active = (t.active + len(t.tabs) - 1) % len(t.tabs)
+				return t, nil
+			}
+		}
+	case tea.MouseMsg:
+		if msg.Y < t.barHeight() {
+			if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
+				if i := t.tabAt(msg.X); i >= 0 {
+					t.active = i
+				}
+			}
+			return t, nil
+		}
+		// The tab sees its own rows, starting below the bar.
+		msg.Y -= t.barHeight()
+		_, cmd := t.Active().Update(msg)
+		return t, t.tag(t.active, cmd)
+	}
+	_, cmd := t.Active().Update(msg)
+	return t, t.tag(t.active, cmd)
+}
+
+// tag makes the messages cmd produces go back to tab i. Those Bubble Tea
+// handles itself are left as they are, and a batch's commands are tagged
+// in turn. With one tab there is nothing to tell apart.
+func (t *Tabs) tag(i int, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil || len(t.tabs) == 1 {
+		return cmd
+	}
+	return func() tea.Msg {
+		msg := cmd()
+		switch m := msg.(type) {
+		case nil:
+			return nil
+		case tea.BatchMsg:
+			for j, c := range m {
+				m[j] = t.tag(i, c)
+			}
+			return m
+		}
+		if reflect.TypeOf(msg).PkgPath() == teaPkg {
+			return msg
+		}
+		return tabMsg{tab: i, msg: msg}
+	}
+}
+
+// barHeight returns the height of the tab bar, 0 when there is one tab.
+func (t *Tabs) barHeight() int {
+	if len(t.tabs) > 1 {
+		return tabBarHeight
+	}
+	return 0
+}
+
+// tabLabel returns the label of tab i in the tab bar: the command it
+// explores, padded.
+func (t *Tabs) tabLabel(i int) string {
+	return " " + t.tabs[i].root.FullCommand() + " "
+}
+
+// tabAt returns the tab whose label is at column x of the tab bar, or -1.
+func (t *Tabs) tabAt(x int) int {
+	left := 0
+	for i := range t.tabs {
+		w := lipgloss.Width(t.tabLabel(i))
+		if x >= left && x < left+w {
+			return i
+		}
+		left += w + 1 // the space between labels
+	}
+	return -1
+}
+
+func (t *Tabs) View() string {
+	if t.barHeight() == 0 {
+		return t.Active().View()
+	}
+	activeStyle := lipgloss.NewStyle().Bold(true).Reverse(true)
+	tabStyle := lipgloss.NewStyle().Faint(true)
+	bar := ""
+	for i := range t.tabs {
+		if i > 0 {
+			bar += " "
+		}
+		if i == t.active {
+			bar += activeStyle.Render(t.tabLabel(i))
+		} else {
+			bar += tabStyle.Render(t.tabLabel(i))
+		}
+	}
+	bar += tabStyle.Render("  { } switch tabs")
+	return clipRow(bar, 0, max(1, t.width)) + "\n" + t.Active().View()
+}
+
+// capturesKeys reports whether the keys typed go to a modal, a prompt or
+// the preview rather than to the tree, so that { and } are typed there
+// rather than switching tabs.
+func (m *Model) capturesKeys() bool {
+	return m.tour.active || m.kb.active || m.rh.active || m.msgs.active || m.sm.active || m.rl.active ||
+		m.em.active || m.cmp.active || m.nm.active || m.vm.active || m.fm.active || m.modal.active ||
+		m.filtering || m.commanding || m.focusedPane == panePreview
+}
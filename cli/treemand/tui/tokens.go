@@ -0,0 +1,186 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- flag tokens ----------
+
+// flagToken is what a command-line token that starts with "-" sets.
+type flagToken struct {
+	// names are the flags the token sets, as spelled on the command line:
+	// "--message", or "-x", "-v", "-f" for the cluster "-xvf".
+	names []string
+	// last is the flag the token ends with, nil when it is not known.
+	last *models.Flag
+	// nameLen is the length of the token up to its value: "-n" in "-n5",
+	// "--message=" in "--message=hi".
+	nameLen int
+	// attached reports that the value is part of the token: "--output=x",
+	// "-n5", "-ofile".
+	attached bool
+}
+
+// wantsValue reports whether the next token is the value of the token's
+// last flag: it takes one, and the token does not carry it.
+func (ft flagToken) wantsValue() bool {
+	return !ft.attached && ft.last != nil && ft.last.TakesValue()
+}
+
+// parseFlagToken reads tok against known, the flags of the command it is
+// given to. Long flags may carry their value after "=". A single dash
+// followed by several letters is a cluster of short flags, as tar and ps
+// take them: "-xvf" sets -x, -v and -f. The first letter that names a
+// value-taking flag ends the cluster, and the rest of the token is its value
+// ("-n5", "-ofile"). So does a letter naming no known flag, as its value
+// cannot be told from more flags. A single-dash long flag such as find's
+// "-name" is read whole when it is known. It returns false for tokens that
+// are not flags: "-", "--" and words.
+func parseFlagToken(tok string, known []models.Flag) (flagToken, bool) {
+	if !strings.HasPrefix(tok, "-") || tok == "-" || tok == "--" {
+		return flagToken{}, false
+	}
+	name, _, hasValue := strings.Cut(tok, "=")
+	if f := lookupFlag(known, name); f != nil || strings.HasPrefix(tok, "--") || len(name) == 2 {
+		ft := flagToken{names: []string{name}, last: f, nameLen: len(tok), attached: hasValue}
+		if hasValue {
+			ft.nameLen = len(name) + 1
+		}
+		return ft, true
+	}
+	var ft flagToken
+	for i := 1; i < len(tok) && tok[i] != '='; i++ {
+		short := tok[i : i+1]
+		f := lookupFlag(known, "-"+short)
+		ft.names = append(ft.names, "-"+short)
+		ft.last = f
+		ft.nameLen = i + 1
+		if f == nil || f.TakesValue() {
+			if rest := tok[i+1:]; rest != "" {
+				ft.attached = true
+				if strings.HasPrefix(rest, "=") {
+					ft.nameLen++
+				}
+			}
+			break
+		}
+	}
+	if !ft.attached && ft.nameLen < len(tok) { // "-xv=1"
+		ft.attached = true
+		ft.nameLen++
+	}
+	return ft, true
+}
+
+// lookupFlag finds the flag spelled name, by its name or as "-" and its
+// short name.
+func lookupFlag(flags []models.Flag, name string) *models.Flag {
+	for i := range flags {
+		f := &flags[i]
+		if f.Name == name || (f.ShortName != "" && "-"+f.ShortName == name) ||
+			(f.Negatable && f.NegatedName() == name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// sets reports whether the token sets f, by its name or short name.
+func (ft flagToken) sets(f models.Flag) bool {
+	for _, name := range ft.names {
+		if strings.HasPrefix(name, "--") {
+			if strings.EqualFold(name, f.Name) {
+				return true
+			}
+		} else if f.ShortName != "" && name == "-"+f.ShortName {
+			return true
+		} else if name == f.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// knownFlags returns the flags a token given to node may name: its own,
+// those under its virtual groups, and root's global flags.
+func knownFlags(node, root *models.Node) []models.Flag {
+	if node == nil {
+		node = root
+	}
+	if node == nil {
+		return nil
+	}
+	flags := commandFlags(node)
+	if root != nil && node != root {
+		flags = append(slices.Clip(flags), root.Flags...)
+	}
+	return flags
+}
+
+func isFlagActive(f models.Flag, tokens []string, known []models.Flag) bool {
+	return flagCount(f, tokens, known) > 0
+}
+
+// flagCount returns how many times f occurs in tokens, by long or short
+// name and inside short-flag clusters. known are the flags tokens are read
+// against (see parseFlagToken); a flag's value is not read as a flag, nor
+// a word after "--" or after the first command of a piped line.
+func flagCount(f models.Flag, tokens []string, known []models.Flag) int {
+	n := 0
+	skipValue := false
+	for _, tok := range tokens {
+		if skipValue {
+			skipValue = false
+			continue
+		}
+		if tok == "--" || isOperator(tok) {
+			break
+		}
+		ft, ok := parseFlagToken(tok, known)
+		if !ok {
+			continue
+		}
+		if ft.sets(f) {
+			n++
+		}
+		skipValue = ft.wantsValue()
+	}
+	return n
+}
+
+// negatableState finds negatable flag f in tokens: the index of its last
+// occurrence, and whether that sets it on (--color) or off (--no-color).
+// The index is -1 when tokens give neither.
+func negatableState(f models.Flag, tokens []string, known []models.Flag) (idx int, on bool) {
+	idx = -1
+	skipValue := false
+	for i, tok := range tokens {
+		if skipValue {
+			skipValue = false
+			continue
+		}
+		if tok == "--" || isOperator(tok) {
+			break
+		}
+		ft, ok := parseFlagToken(tok, known)
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.EqualFold(ft.names[0], f.NegatedName()):
+			idx, on = i, false
+		case ft.sets(f):
+			idx, on = i, true
+		}
+		skipValue = ft.wantsValue()
+	}
+	return idx, on
+}
+
+// negatableLabel names a negatable flag with both its forms: --[no-]color.
+func negatableLabel(f models.Flag) string {
+	return "--[no-]" + strings.TrimPrefix(f.Name, "--")
+}
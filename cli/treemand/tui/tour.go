@@ -0,0 +1,93 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- guided tour ----------
+
+// tourStep is one page of the guided tour.
+type tourStep struct {
+	title string
+	body  string
+}
+
+// tourSteps walks through the parts of the TUI a first command is built
+// with.
+var tourSteps = []tourStep{
+	{"Welcome to treemand", "This short tour shows how to explore a CLI and build a command with it. " +
+		"→ or Enter goes on, ← back, and Esc closes the tour; :tour, or t in the ? overlay, shows it again."},
+	{"The tree", "The left pane is the CLI's command tree. ↑/↓ move, → expands a command and ← collapses it " +
+		"or goes to its parent. / filters the tree, :goto jumps to a command, and Ctrl+S switches to vim or WASD keys. " +
+		"The help pane on the right describes what is selected."},
+	{"The preview bar", "The bar at the top is the command being built. Enter on a command sets it, on a flag adds it, " +
+		"and on a positional argument asks for its value. Backspace removes the last token and Ctrl+K clears the bar; " +
+		"Tab focuses it to type in it."},
+	{"The flag picker", "f lists every flag of the selected command: type to filter them, Space marks several, " +
+		"and Enter adds them, asking for the values they take."},
+	{"Running the command", "Ctrl+E copies the command, or runs it once treemand exits, after asking for any required " +
+		"argument it is missing. In tmux, kitty or WezTerm, t runs it in a new split instead."},
+	{"That's it", "? lists every key binding, and / in it searches them. Enjoy exploring!"},
+}
+
+// tourModal is the guided tour overlay.
+type tourModal struct {
+	active bool
+	step   int
+}
+
+// StartTour opens the guided tour. Run opens it on the first launch.
+func (m *Model) StartTour() { m.tour = tourModal{active: true} }
+
+func (m *Model) updateTourModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc", "q":
+		m.tour.active = false
+		m.statusMsg = "tour closed; :tour shows it again"
+	case "right", "l", "enter", " ", "tab", "n":
+		if m.tour.step == len(tourSteps)-1 {
+			m.tour.active = false
+			return m, nil
+		}
+		m.tour.step++
+	case "left", "h", "backspace", "shift+tab", "p":
+		m.tour.step = max(0, m.tour.step-1)
+	}
+	return m, nil
+}
+
+func (m *Model) renderTourModal() string {
+	modalW := max(40, min(m.width-6, 64))
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5EA4F5"))
+	hintStyle := lipgloss.NewStyle().Faint(true)
+
+	step := tourSteps[m.tour.step]
+	var dots []string
+	for i := range tourSteps {
+		if i == m.tour.step {
+			dots = append(dots, "●")
+		} else {
+			dots = append(dots, "○")
+		}
+	}
+	hint := "→/Enter next · ← back · Esc close"
+	if m.tour.step == len(tourSteps)-1 {
+		hint = "Enter done · ← back"
+	}
+	content := titleStyle.Render(step.title) + "  " + hintStyle.Render(fmt.Sprintf("%d/%d", m.tour.step+1, len(tourSteps))) + "\n\n" +
+		strings.Join(wordWrap(step.body, modalW-6), "\n") + "\n\n" +
+		strings.Join(dots, " ") + "\n" +
+		hintStyle.Render(hint)
+
+	box := lipgloss.NewStyle().
+		Border(boxBorder(m.cfg)).
+		BorderForeground(lipgloss.Color("#5EA4F5")).
+		Padding(1, 2).
+		Width(modalW - 2).
+		Render(content)
+	return m.overlay(box)
+}
@@ -2,13 +2,20 @@ package tui
 
 import (
 	"fmt"
+	"iter"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 
 	"github.com/aallbrig/treemand/config"
 	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/render"
 )
 
 // rowKind identifies the type of a tree row.
@@ -67,10 +74,15 @@ type Selection struct {
 // TreeModel manages the scrollable, filterable tree pane.
 type TreeModel struct {
 	root            *models.Node
-	rows            []treeRow
+	rows            []treeRow     // rows flattened so far; see fill
+	pending         []flattenStep // what is left to flatten, next last
 	cursor          int
 	offset          int
 	filter          string
+	filterMatch     *render.Matcher
+	filterShown     map[*models.Node]bool // commands the filter shows: matches and their ancestors
+	filterMatches   int                   // commands, flags and positionals the filter matches
+	unfiltered      *expansion            // expansion before the filter, put back when it is cleared
 	nodeExpanded    map[string]bool
 	sectionExpanded map[string]bool
 	hideSections    bool // when true, section headers are hidden and all items shown flat
@@ -79,8 +91,54 @@ type TreeModel struct {
 	cfg             *config.Config
 	width           int
 	height          int
+	hOffset         int                   // columns scrolled off the left edge of every row
+	uses            map[string]int        // usage counts the "used" sort goes by, by usageKey
+	discovering     map[*models.Node]bool // stubs being discovered in the background
+
+	// Rendered rows, so a keypress renders only the rows it changed: the
+	// selected row and those newly scrolled into view.
+	rowCache      map[rowCacheKey]renderedRow
+	rowCacheState rowRenderState
 }
 
+// renderedRow is a rendered row and the width of its text.
+type renderedRow struct {
+	line  string
+	width int
+}
+
+// rowCacheKey identifies a rendered row: the row itself, and whether it is
+// shown expanded, which its icon and flag pills depend on.
+type rowCacheKey struct {
+	row      treeRow
+	expanded bool
+}
+
+// rowRenderState is the configuration every row is rendered with. Rows
+// rendered with another are dropped from the cache.
+type rowRenderState struct {
+	width       int
+	style       config.DisplayStyle
+	fullPath    bool
+	accessible  bool
+	showSources bool
+	colors      config.ColorScheme
+	icons       config.IconSet
+}
+
+// expansion is which commands and sections of the tree are expanded.
+type expansion struct {
+	nodes, sections map[string]bool
+	// back is the command the filter moved the cursor off to reach its
+	// first match, moved, though it still showed it; the cursor goes back
+	// to it when the filter is cleared, unless it was moved since.
+	back  *models.Node
+	moved Selection
+}
+
+// hScrollStep is how many columns [ and ] scroll the tree horizontally.
+const hScrollStep = 8
+
 func NewTreeModel(root *models.Node, cfg *config.Config) *TreeModel {
 	t := &TreeModel{
 		root:            root,
@@ -95,15 +153,257 @@ func NewTreeModel(root *models.Node, cfg *config.Config) *TreeModel {
 
 func (t *TreeModel) SetSize(w, h int) { t.width = w; t.height = h }
 
+// ScrollLeft and ScrollRight move the horizontal scroll position by n
+// columns. Scrolling right stops once the widest visible row fits.
+func (t *TreeModel) ScrollLeft(n int)  { t.hOffset = max(0, t.hOffset-n) }
+func (t *TreeModel) ScrollRight(n int) { t.hOffset += n }
+
+// HOffset returns the current horizontal scroll position in columns.
+func (t *TreeModel) HOffset() int { return t.hOffset }
+
+// SetFilter shows only commands matching f and their ancestors. f uses the
+// same syntax as --filter (see render.Matcher). The selected command stays
+// selected while the filter matches it; otherwise the first match is. When
+// the filter is cleared the tree is expanded as it was before it, and as
+// far as needed to show the selected command; the cursor goes back to the
+// command the filter moved it off while still showing it, unless it was
+// moved since.
 func (t *TreeModel) SetFilter(f string) {
+	if f == t.filter && t.filterMatch != nil {
+		return
+	}
+	item, sel := t.SelectedItem(), t.SelectedOrOwner()
+	prev, within := t.filter, t.filterShown
+	t.rowCache = nil // rows highlight what the filter matches
 	t.filter = f
-	t.cursor = 0
-	t.offset = 0
+	t.filterMatch = render.NewMatcher(f)
+	if t.filterMatch.Empty() {
+		t.filterShown = nil
+		if u := t.unfiltered; u != nil {
+			t.nodeExpanded, t.sectionExpanded = u.nodes, u.sections
+			t.unfiltered = nil
+			if u.back != nil && item != nil && *item == u.moved {
+				item, sel = &Selection{Kind: SelCommand, Node: u.back}, u.back
+			}
+		}
+		t.rebuild()
+		t.cursor, t.offset = 0, 0
+		switch {
+		case sel == nil:
+		case item != nil && item.Kind == SelCommand:
+			// The command selected is revealed where it was.
+			t.Reveal(sel)
+		default:
+			// A flag's or positional's row may be in a collapsed section:
+			// its command, or the nearest shown above it, is selected.
+			t.selectNearest(sel)
+		}
+		return
+	}
+
+	if t.unfiltered == nil {
+		t.unfiltered = &expansion{nodes: maps.Clone(t.nodeExpanded), sections: maps.Clone(t.sectionExpanded)}
+	}
+	if !narrows(prev, f) {
+		within = nil
+	}
+	t.filterShown = t.filterNodes(within)
 	t.rebuild()
+	t.cursor, t.offset = 0, 0
+	u := t.unfiltered
+	unmoved := item != nil && u.back != nil && *item == u.moved
+	if sel != nil && t.filterShown[sel] && t.filterMatch.Match(sel) {
+		t.reselect(sel)
+		if !unmoved {
+			u.back = nil
+		}
+		return
+	}
+	switch {
+	case unmoved:
+		// Still where the filter last moved the cursor.
+	case item != nil && item.Kind == SelCommand && t.filterShown[sel]:
+		u.back = sel
+	default:
+		u.back = nil
+	}
+	for i, row := range t.eachRow() {
+		// Flag and positional rows are shown only when they match.
+		if row.kind != rowKindCommand || t.filterMatch.Match(row.node) {
+			t.cursor = i
+			break
+		}
+	}
+	t.scrollIntoView()
+	if moved := t.SelectedItem(); moved != nil {
+		u.moved = *moved
+	}
+}
+
+// selectNearest moves the cursor to node's command row or, when node is not
+// shown, to its nearest ancestor's.
+func (t *TreeModel) selectNearest(node *models.Node) {
+	if node == nil {
+		return
+	}
+	best, bestLen := -1, -1
+	for i, row := range t.eachRow() {
+		if row.kind != rowKindCommand {
+			continue
+		}
+		if p := row.node.FullPath; len(p) > bestLen && len(p) <= len(node.FullPath) && pathsEqual(p, node.FullPath[:len(p)]) {
+			best, bestLen = i, len(p)
+		}
+	}
+	if best >= 0 {
+		t.cursor = best
+	}
+	t.scrollIntoView()
+}
+
+// filterNodes returns the commands the filter shows: those it matches, or
+// one of whose flags or positionals it matches, and their ancestors, and
+// counts the matches. Only commands in within, when it is not nil, can be
+// among them; a filter narrowing the last one passes the commands that one
+// showed, so typing into it searches fewer and fewer commands.
+func (t *TreeModel) filterNodes(within map[*models.Node]bool) map[*models.Node]bool {
+	shown := make(map[*models.Node]bool)
+	t.filterMatches = 0
+	var walk func(n *models.Node) bool
+	walk = func(n *models.Node) bool {
+		if n.Virtual || within != nil && !within[n] {
+			return false
+		}
+		show := t.filterMatch.Match(n)
+		if show {
+			t.filterMatches++
+		}
+		if !t.cfg.CommandsOnly {
+			for i := range n.Flags {
+				if t.flagMatches(&n.Flags[i]) {
+					show = true
+					t.filterMatches++
+				}
+			}
+			for i := range n.Positionals {
+				if t.positionalMatches(&n.Positionals[i]) {
+					show = true
+					t.filterMatches++
+				}
+			}
+		}
+		for _, c := range n.Children {
+			if walk(c) {
+				show = true
+			}
+		}
+		if show {
+			shown[n] = true
+		}
+		return show
+	}
+	walk(t.root)
+	return shown
+}
+
+// flagMatches reports whether the filter matches f's name or description.
+// Inherited flags are matched where they are defined only.
+func (t *TreeModel) flagMatches(f *models.Flag) bool {
+	return !f.Inherited && (t.filterMatch.MatchString(f.Name) || f.Description != "" && t.filterMatch.MatchString(f.Description))
+}
+
+// positionalMatches reports whether the filter matches p's name or
+// description.
+func (t *TreeModel) positionalMatches(p *models.Positional) bool {
+	return t.filterMatch.MatchString(p.Name) || p.Description != "" && t.filterMatch.MatchString(p.Description)
+}
+
+// FilterMatches returns how many commands, flags and positionals the filter
+// matches, or -1 when there is no filter.
+func (t *TreeModel) FilterMatches() int {
+	if t.filterShown == nil {
+		return -1
+	}
+	return t.filterMatches
+}
+
+// SetRoot replaces the tree shown with root, the same command discovered
+// afresh, keeping the view: nodes and sections stay expanded, and the
+// selected command selected, by their paths, and the filter is matched
+// against the new tree.
+func (t *TreeModel) SetRoot(root *models.Node) {
+	st := t.viewState()
+	t.root = root
+	t.rowCache = nil
+	t.refilter()
+	t.restoreState(st)
+}
+
+// refilter matches the filter against the whole tree again, after its
+// commands changed.
+func (t *TreeModel) refilter() {
+	if t.filterShown != nil {
+		t.filterShown = t.filterNodes(nil)
+	}
 }
 
-func (t *TreeModel) SetCmdTokens(tokens []string) { t.cmdTokens = tokens }
-func (t *TreeModel) SetFocused(f bool)            { t.focused = f }
+// narrows reports whether every command filter f matches is one filter
+// prev matches too: f extends prev, both are plain text, without regular
+// expression syntax or commas, and f matches command paths only if prev
+// does (see render.Matcher).
+func narrows(prev, f string) bool {
+	prev, f = strings.TrimSpace(prev), strings.TrimSpace(f)
+	if prev == "" || !strings.HasPrefix(f, prev) || strings.Contains(f, ",") || regexp.QuoteMeta(f) != f {
+		return false
+	}
+	return strings.Contains(prev, " ") == strings.Contains(f, " ")
+}
+
+// SetCmdTokens sets the tokens of the command being built, whose commands
+// and flags the tree highlights. Only the rows they highlight differently
+// are rendered again.
+func (t *TreeModel) SetCmdTokens(tokens []string) {
+	if !slices.Equal(tokens, t.cmdTokens) {
+		for key := range t.rowCache {
+			if t.tokensRestyle(key.row, t.cmdTokens, tokens) {
+				delete(t.rowCache, key)
+			}
+		}
+	}
+	t.cmdTokens = tokens
+}
+
+// tokensRestyle reports whether row highlights its command or flags
+// differently with the tokens after than with those before: a command row
+// whether it is on the command's path and which of its inline flags are
+// given, a flag row whether its flag is given.
+func (t *TreeModel) tokensRestyle(row treeRow, before, after []string) bool {
+	switch row.kind {
+	case rowKindCommand:
+		if tokenPrefix(row.node.FullPath, before) != tokenPrefix(row.node.FullPath, after) {
+			return true
+		}
+		known := knownFlags(row.node, t.root)
+		for _, f := range row.node.Flags {
+			if !f.Inherited && isFlagActive(f, before, known) != isFlagActive(f, after, known) {
+				return true
+			}
+		}
+	case rowKindFlag:
+		known := knownFlags(row.owner, t.root)
+		if isFlagActive(*row.flag, before, known) != isFlagActive(*row.flag, after, known) {
+			return true
+		}
+		if row.flag.Negatable {
+			i, on := negatableState(*row.flag, before, known)
+			j, onAfter := negatableState(*row.flag, after, known)
+			return (i >= 0 && !on) != (j >= 0 && !onAfter)
+		}
+	}
+	return false
+}
+
+func (t *TreeModel) SetFocused(f bool) { t.focused = f }
 
 // SetDisplayStyle changes the presentation variant and triggers a rebuild.
 func (t *TreeModel) SetDisplayStyle(s config.DisplayStyle) {
@@ -111,9 +411,90 @@ func (t *TreeModel) SetDisplayStyle(s config.DisplayStyle) {
 	t.rebuild()
 }
 
+// SetSortMode changes the order of child commands and flags and triggers a
+// rebuild. The selected node stays selected.
+func (t *TreeModel) SetSortMode(mode config.SortMode) {
+	sel := t.Selected()
+	t.cfg.Sort = mode
+	t.rebuild()
+	t.reselect(sel)
+}
+
+// SetUses sets the usage counts the "used" sort order goes by, keyed by
+// usageKey, and re-sorts the tree when it is in that order.
+func (t *TreeModel) SetUses(uses map[string]int) {
+	t.uses = uses
+	if t.cfg.Sort == config.SortUsed {
+		sel := t.Selected()
+		t.rebuild()
+		t.reselect(sel)
+	}
+}
+
+// sortedChildren returns node's children in the sort order. The "used"
+// order needs the usage counts render.SortNodes does not have, so it is
+// sorted here, the most used first.
+func (t *TreeModel) sortedChildren(node *models.Node) []*models.Node {
+	if t.cfg.Sort != config.SortUsed {
+		return render.SortNodes(node.Children, t.cfg.Sort)
+	}
+	out := slices.Clone(node.Children)
+	slices.SortStableFunc(out, func(a, b *models.Node) int {
+		return t.uses[usageKey(b, "")] - t.uses[usageKey(a, "")]
+	})
+	return out
+}
+
+// flagOrder returns the indices of node's flags in the sort order, the
+// most used first in the "used" order.
+func (t *TreeModel) flagOrder(node *models.Node) []int {
+	order := render.FlagOrder(node.Flags, t.cfg.Sort)
+	if t.cfg.Sort == config.SortUsed {
+		slices.SortStableFunc(order, func(a, b int) int {
+			return t.uses[usageKey(node, node.Flags[b].Name)] - t.uses[usageKey(node, node.Flags[a].Name)]
+		})
+	}
+	return order
+}
+
+// SetCommandsOnly hides (or shows again) flag, positional and section rows,
+// leaving only commands. A selected flag or positional moves to its command.
+func (t *TreeModel) SetCommandsOnly(on bool) {
+	sel := t.SelectedOrOwner()
+	t.cfg.CommandsOnly = on
+	t.refilter()
+	t.rebuild()
+	t.reselect(sel)
+}
+
+// SetFullPath switches command rows between indented names and
+// unindented full command paths ("git remote add").
+func (t *TreeModel) SetFullPath(on bool) { t.cfg.FullPath = on }
+
+// FullPath reports whether command rows show full command paths.
+func (t *TreeModel) FullPath() bool { return t.cfg.FullPath }
+
+// CommandsOnly reports whether only command rows are shown.
+func (t *TreeModel) CommandsOnly() bool { return t.cfg.CommandsOnly }
+
+// reselect moves the cursor to node's command row after a rebuild, if the
+// row is still visible.
+func (t *TreeModel) reselect(node *models.Node) {
+	if node == nil {
+		return
+	}
+	for i, row := range t.eachRow() {
+		if row.kind == rowKindCommand && row.node == node {
+			t.cursor = i
+			break
+		}
+	}
+	t.scrollIntoView()
+}
+
 // SelectedItem returns the full Selection for the current cursor position.
 func (t *TreeModel) SelectedItem() *Selection {
-	if t.cursor >= len(t.rows) || len(t.rows) == 0 {
+	if !t.fill(t.cursor) {
 		return nil
 	}
 	row := t.rows[t.cursor]
@@ -146,7 +527,7 @@ func (t *TreeModel) Selected() *models.Node {
 
 // SelectedDepth returns the depth of the currently selected row.
 func (t *TreeModel) SelectedDepth() int {
-	if t.cursor >= len(t.rows) {
+	if !t.fill(t.cursor) {
 		return 0
 	}
 	return t.rows[t.cursor].depth
@@ -159,7 +540,7 @@ func (t *TreeModel) SelectedOrOwner() *models.Node {
 	if n := t.Selected(); n != nil {
 		return n
 	}
-	if t.cursor >= len(t.rows) {
+	if !t.fill(t.cursor) {
 		return nil
 	}
 	row := t.rows[t.cursor]
@@ -178,7 +559,7 @@ func (t *TreeModel) SelectedOrOwner() *models.Node {
 // current cursor position. For command rows this is the row's own depth;
 // for section/flag/positional rows it's the owning command's depth.
 func (t *TreeModel) SelectedCommandDepth() int {
-	if t.cursor >= len(t.rows) {
+	if !t.fill(t.cursor) {
 		return 0
 	}
 	row := t.rows[t.cursor]
@@ -197,8 +578,13 @@ func (t *TreeModel) SelectedCommandDepth() int {
 	return 0
 }
 
-// Rebuild is a public alias for rebuild, used when callers mutate state externally.
-func (t *TreeModel) Rebuild() { t.rebuild() }
+// Rebuild is a public alias for rebuild, used when callers mutate state
+// externally. Rows are rendered afresh, since the nodes may have changed.
+func (t *TreeModel) Rebuild() {
+	t.rowCache = nil
+	t.refilter()
+	t.rebuild()
+}
 
 func (t *TreeModel) Up() {
 	if t.cursor > 0 {
@@ -208,12 +594,77 @@ func (t *TreeModel) Up() {
 }
 
 func (t *TreeModel) Down() {
-	if t.cursor < len(t.rows)-1 {
+	if t.fill(t.cursor + 1) {
 		t.cursor++
 		t.scrollIntoView()
 	}
 }
 
+// MoveBy moves the cursor n rows down, or up for a negative n, stopping at
+// the first and last rows.
+func (t *TreeModel) MoveBy(n int) {
+	if !t.fill(0) {
+		return
+	}
+	t.fill(t.cursor + n)
+	t.cursor = max(0, min(len(t.rows)-1, t.cursor+n))
+	t.scrollIntoView()
+}
+
+// pageSize is the number of rows the tree pane shows.
+func (t *TreeModel) pageSize() int { return max(1, t.height-2) }
+
+// scroll scrolls the tree n rows down, or up for a negative n, moving the
+// cursor with it.
+func (t *TreeModel) scroll(n int) {
+	t.fill(t.offset + n + t.pageSize() - 1)
+	t.offset = max(0, min(t.offset+n, len(t.rows)-t.pageSize()))
+	t.MoveBy(n)
+}
+
+// PageDown scrolls the tree a screenful down, moving the cursor with it.
+func (t *TreeModel) PageDown() { t.scroll(t.pageSize()) }
+
+// PageUp scrolls the tree a screenful up, moving the cursor with it.
+func (t *TreeModel) PageUp() { t.scroll(-t.pageSize()) }
+
+// HalfPageDown scrolls the tree half a screenful down, moving the cursor
+// with it.
+func (t *TreeModel) HalfPageDown() { t.scroll(max(1, t.pageSize()/2)) }
+
+// HalfPageUp scrolls the tree half a screenful up, moving the cursor with
+// it.
+func (t *TreeModel) HalfPageUp() { t.scroll(-max(1, t.pageSize()/2)) }
+
+// Cursor positions in the pane for ScrollCursorTo.
+const (
+	cursorTop = iota
+	cursorCenter
+	cursorBottom
+)
+
+// ScrollCursorTo scrolls the tree, leaving the cursor on its row, so that
+// the row shows at the top, center or bottom of the pane, as far as the
+// rows allow.
+func (t *TreeModel) ScrollCursorTo(at int) {
+	line := 0
+	switch at {
+	case cursorCenter:
+		line = t.pageSize() / 2
+	case cursorBottom:
+		line = t.pageSize() - 1
+	}
+	t.fill(t.cursor - line + t.pageSize() - 1)
+	t.offset = max(0, min(t.cursor-line, len(t.rows)-t.pageSize()))
+}
+
+// GoToRow moves the cursor to row n, counting from 1, or to the last row
+// when there are fewer.
+func (t *TreeModel) GoToRow(n int) {
+	t.cursor = 0
+	t.MoveBy(n - 1)
+}
+
 // Top jumps the cursor to the first row.
 func (t *TreeModel) Top() {
 	t.cursor = 0
@@ -222,20 +673,20 @@ func (t *TreeModel) Top() {
 
 // Bottom jumps the cursor to the last row.
 func (t *TreeModel) Bottom() {
-	if len(t.rows) > 0 {
+	if len(t.allRows()) > 0 {
 		t.cursor = len(t.rows) - 1
 		t.scrollIntoView()
 	}
 }
 
-// NextMatch moves the cursor to the next row whose name contains the search
-// string (case-insensitive). Wraps around to the beginning if needed.
+// NextMatch moves the cursor to the next row matching search (a filter
+// pattern, see render.Matcher). Wraps around to the beginning if needed.
 // Returns true if a match was found.
 func (t *TreeModel) NextMatch(search string) bool {
-	if search == "" || len(t.rows) == 0 {
+	if search == "" || len(t.allRows()) == 0 {
 		return false
 	}
-	s := strings.ToLower(search)
+	s := render.NewMatcher(search)
 	for i := 1; i <= len(t.rows); i++ {
 		idx := (t.cursor + i) % len(t.rows)
 		if t.rowMatchesSearch(idx, s) {
@@ -247,14 +698,13 @@ func (t *TreeModel) NextMatch(search string) bool {
 	return false
 }
 
-// PrevMatch moves the cursor to the previous row whose name contains the
-// search string (case-insensitive). Wraps around to the end if needed.
-// Returns true if a match was found.
+// PrevMatch moves the cursor to the previous row matching search. Wraps
+// around to the end if needed. Returns true if a match was found.
 func (t *TreeModel) PrevMatch(search string) bool {
-	if search == "" || len(t.rows) == 0 {
+	if search == "" || len(t.allRows()) == 0 {
 		return false
 	}
-	s := strings.ToLower(search)
+	s := render.NewMatcher(search)
 	for i := 1; i <= len(t.rows); i++ {
 		idx := (t.cursor - i + len(t.rows)) % len(t.rows)
 		if t.rowMatchesSearch(idx, s) {
@@ -266,17 +716,17 @@ func (t *TreeModel) PrevMatch(search string) bool {
 	return false
 }
 
-func (t *TreeModel) rowMatchesSearch(idx int, lowerSearch string) bool {
+func (t *TreeModel) rowMatchesSearch(idx int, m *render.Matcher) bool {
 	row := t.rows[idx]
 	switch row.kind {
 	case rowKindCommand:
-		return strings.Contains(strings.ToLower(row.node.Name), lowerSearch)
+		return m.Match(row.node)
 	case rowKindFlag:
-		return strings.Contains(strings.ToLower(row.flag.Name), lowerSearch)
+		return m.MatchString(row.flag.Name)
 	case rowKindPositional:
-		return strings.Contains(strings.ToLower(row.positional.Name), lowerSearch)
+		return m.MatchString(row.positional.Name)
 	case rowKindSection:
-		return strings.Contains(strings.ToLower(row.sectionLabel), lowerSearch)
+		return m.MatchString(row.sectionLabel)
 	}
 	return false
 }
@@ -286,7 +736,7 @@ func (t *TreeModel) rowMatchesSearch(idx int, lowerSearch string) bool {
 //   - expanded command node → jump to the first command child (skip flags/positionals)
 //   - flag / positional row → no-op
 func (t *TreeModel) Right() {
-	if t.cursor >= len(t.rows) {
+	if !t.fill(t.cursor) {
 		return
 	}
 	row := t.rows[t.cursor]
@@ -302,7 +752,7 @@ func (t *TreeModel) Right() {
 		// Step 1: expand and stay on this node.
 		t.nodeExpanded[key] = true
 		t.rebuild()
-		for i, r := range t.rows {
+		for i, r := range t.eachRow() {
 			if r.kind == rowKindCommand && r.node == row.node && r.depth == row.depth {
 				t.cursor = i
 				break
@@ -315,7 +765,7 @@ func (t *TreeModel) Right() {
 	// Priority: command child > flag/positional > expand first collapsed section.
 	firstChild := -1
 	firstSection := -1
-	for pos := t.cursor + 1; pos < len(t.rows); pos++ {
+	for pos := t.cursor + 1; t.fill(pos); pos++ {
 		r := t.rows[pos]
 		if r.depth <= row.depth {
 			break // walked past children
@@ -344,8 +794,7 @@ func (t *TreeModel) Right() {
 		t.sectionExpanded[secRow.sectionKey] = true
 		t.rebuild()
 		// Land on the first non-section row after where the section was.
-		for pos := 0; pos < len(t.rows); pos++ {
-			r := t.rows[pos]
+		for pos, r := range t.eachRow() {
 			if r.kind != rowKindSection && r.kind != rowKindCommand && r.depth > row.depth {
 				t.cursor = pos
 				t.scrollIntoView()
@@ -369,7 +818,7 @@ func (t *TreeModel) Right() {
 //   - collapsed command node (or leaf) → jump to the parent command row
 //   - flag / positional row → jump to the owner command row
 func (t *TreeModel) Left() {
-	if t.cursor >= len(t.rows) {
+	if !t.fill(t.cursor) {
 		return
 	}
 	row := t.rows[t.cursor]
@@ -378,7 +827,7 @@ func (t *TreeModel) Left() {
 		t.collapseSection(row)
 		return
 	case rowKindFlag, rowKindPositional:
-		for i, r := range t.rows {
+		for i, r := range t.eachRow() {
 			if r.kind == rowKindCommand && r.node == row.owner && r.depth == row.ownerDepth {
 				t.cursor = i
 				t.scrollIntoView()
@@ -391,7 +840,7 @@ func (t *TreeModel) Left() {
 			// Collapse and stay on this node.
 			delete(t.nodeExpanded, key)
 			t.rebuild()
-			for i, r := range t.rows {
+			for i, r := range t.eachRow() {
 				if r.kind == rowKindCommand && r.node == row.node && r.depth == row.depth {
 					t.cursor = i
 					break
@@ -407,7 +856,7 @@ func (t *TreeModel) Left() {
 			}
 			if len(row.node.FullPath) > 1 {
 				parentPath := row.node.FullPath[:len(row.node.FullPath)-1]
-				for i, r := range t.rows {
+				for i, r := range t.eachRow() {
 					if r.kind == rowKindCommand && pathsEqual(r.node.FullPath, parentPath) {
 						t.cursor = i
 						t.scrollIntoView()
@@ -436,7 +885,7 @@ func (t *TreeModel) Collapse() { t.Left() }
 
 // ToggleExpand toggles the current node's or section's expansion (Space key).
 func (t *TreeModel) ToggleExpand() {
-	if t.cursor >= len(t.rows) {
+	if !t.fill(t.cursor) {
 		return
 	}
 	row := t.rows[t.cursor]
@@ -457,7 +906,7 @@ func (t *TreeModel) ToggleExpand() {
 // ToggleSelectedSection toggles expansion of the section at the current cursor.
 // Returns true if the cursor was on a section row (and it was toggled), false otherwise.
 func (t *TreeModel) ToggleSelectedSection() bool {
-	if t.cursor >= len(t.rows) {
+	if !t.fill(t.cursor) {
 		return false
 	}
 	row := t.rows[t.cursor]
@@ -503,7 +952,7 @@ func (t *TreeModel) collapseSection(row treeRow) {
 // the section row identified by key.
 func (t *TreeModel) rebuildKeepingSection(key string) {
 	t.rebuild()
-	for i, r := range t.rows {
+	for i, r := range t.eachRow() {
 		if r.kind == rowKindSection && r.sectionKey == key {
 			t.cursor = i
 			break
@@ -516,7 +965,7 @@ func (t *TreeModel) rebuildKeepingSection(key string) {
 // inside the given section, or -1 if none found.
 func (t *TreeModel) firstSectionChild(section treeRow) int {
 	pos := t.cursor + 1
-	if pos >= len(t.rows) {
+	if !t.fill(pos) {
 		return -1
 	}
 	r := t.rows[pos]
@@ -561,6 +1010,9 @@ func (t *TreeModel) expandAllSections(node *models.Node, depth int) {
 	if ownFlags > 0 {
 		t.sectionExpanded[key+"/flags"] = true
 	}
+	for _, s := range node.FlagSections {
+		t.sectionExpanded[key+"/flags/"+s.Name] = true
+	}
 	if inheritedFlags > 0 {
 		t.sectionExpanded[key+"/inherited"] = true
 	}
@@ -574,6 +1026,58 @@ func (t *TreeModel) expandAllSections(node *models.Node, depth int) {
 	}
 }
 
+// Reveal expands the ancestors of node, and their subcommand sections, so
+// that it has a row, and moves the cursor to it. It returns false when the
+// node is not in the tree or the filter hides it.
+func (t *TreeModel) Reveal(node *models.Node) bool {
+	if len(node.FullPath) < len(t.root.FullPath) {
+		return false
+	}
+	cur := t.root
+	for depth, name := range node.FullPath[len(t.root.FullPath):] {
+		key := nodeKey(cur, depth)
+		t.nodeExpanded[key] = true
+		t.sectionExpanded[key+"/subcommands"] = true
+		if cur = findCommand(cur, name); cur == nil {
+			return false
+		}
+	}
+	t.rebuild()
+	return t.SelectNode(node)
+}
+
+// RevealFlag reveals owner (see Reveal), expanding it and the section its
+// flag named name is listed in, and moves the cursor to the flag's row. It
+// returns false when the flag has no row.
+func (t *TreeModel) RevealFlag(owner *models.Node, name string) bool {
+	i := slices.IndexFunc(owner.Flags, func(f models.Flag) bool { return f.Name == name })
+	if i < 0 || !t.Reveal(owner) {
+		return false
+	}
+	key := nodeKey(owner, len(owner.FullPath)-len(t.root.FullPath))
+	t.nodeExpanded[key] = true
+	section := key + "/flags"
+	if owner.Flags[i].Inherited {
+		section = key + "/inherited"
+	} else {
+		for _, g := range owner.FlagGroups(t.flagOrder(owner)) {
+			if g.Name != "" && slices.Contains(g.Flags, i) {
+				section = key + "/flags/" + g.Name
+			}
+		}
+	}
+	t.sectionExpanded[section] = true
+	t.rebuild()
+	for j, row := range t.eachRow() {
+		if row.kind == rowKindFlag && row.owner == owner && row.flag == &owner.Flags[i] {
+			t.cursor = j
+			t.scrollIntoView()
+			return true
+		}
+	}
+	return false
+}
+
 // CollapseAll collapses every node in the tree, leaving only the root row visible.
 func (t *TreeModel) CollapseAll() {
 	t.nodeExpanded = make(map[string]bool)
@@ -595,7 +1099,7 @@ func (t *TreeModel) SectionsHidden() bool { return t.hideSections }
 
 // IsAtRoot reports whether the cursor is currently on the root command row.
 func (t *TreeModel) IsAtRoot() bool {
-	if t.cursor >= len(t.rows) {
+	if !t.fill(t.cursor) {
 		return true
 	}
 	row := t.rows[t.cursor]
@@ -620,7 +1124,7 @@ func (t *TreeModel) CollapseSubtree(node *models.Node, depth int) {
 // section header or out of bounds.
 func (t *TreeModel) SelectAtY(y int) bool {
 	contentIdx := t.offset + y
-	if contentIdx < 0 || contentIdx >= len(t.rows) {
+	if contentIdx < 0 || !t.fill(contentIdx) {
 		return false
 	}
 	if t.rows[contentIdx].kind == rowKindSection {
@@ -632,7 +1136,7 @@ func (t *TreeModel) SelectAtY(y int) bool {
 
 func (t *TreeModel) ToggleSectionAtY(y int) {
 	contentIdx := t.offset + y
-	if contentIdx < 0 || contentIdx >= len(t.rows) {
+	if contentIdx < 0 || !t.fill(contentIdx) {
 		return
 	}
 	row := t.rows[contentIdx]
@@ -651,6 +1155,12 @@ func (t *TreeModel) PatchNode(stub *models.Node, discovered *models.Node) {
 		return
 	}
 	stub.Stub = false
+	stub.Discovered = discovered.Discovered
+	stub.DiscoveryErr = discovered.DiscoveryErr
+	stub.Interactive = discovered.Interactive
+	if discovered.Deprecated {
+		stub.Deprecated, stub.ReplacedBy = true, discovered.ReplacedBy
+	}
 	stub.Children = discovered.Children
 	if stub.Description == "" {
 		stub.Description = discovered.Description
@@ -658,17 +1168,26 @@ func (t *TreeModel) PatchNode(stub *models.Node, discovered *models.Node) {
 	if len(stub.Flags) == 0 {
 		stub.Flags = discovered.Flags
 	}
+	if len(stub.Positionals) == 0 {
+		stub.Positionals = discovered.Positionals
+	}
+	if discovered.HelpText != "" {
+		stub.HelpText = discovered.HelpText
+		stub.HelpHash = discovered.HelpHash
+	}
 	// Auto-expand the freshly-discovered node.
 	key := t.findNodeKey(stub)
 	if key != "" {
 		t.nodeExpanded[key] = true
 	}
+	t.rowCache = nil
+	t.refilter()
 	t.rebuild()
 }
 
 // findNodeKey locates the nodeKey for a given node pointer within the current rows.
 func (t *TreeModel) findNodeKey(target *models.Node) string {
-	for _, row := range t.rows {
+	for _, row := range t.eachRow() {
 		if row.kind == rowKindCommand && row.node == target {
 			return nodeKey(row.node, row.depth)
 		}
@@ -682,7 +1201,7 @@ func (t *TreeModel) View() string                            { return t.ViewSize
 
 // RowCount returns the number of tree rows currently visible (after filtering).
 // Exported for testing.
-func (t *TreeModel) RowCount() int { return len(t.rows) }
+func (t *TreeModel) RowCount() int { return len(t.allRows()) }
 
 func (t *TreeModel) ViewSized(w, h int) string {
 	t.width = w
@@ -703,25 +1222,33 @@ func (t *TreeModel) ViewSized(w, h int) string {
 	}
 
 	// Clamp cursor
-	if t.cursor >= len(t.rows) && len(t.rows) > 0 {
+	if !t.fill(t.cursor) && len(t.rows) > 0 {
 		t.cursor = len(t.rows) - 1
 	}
 
 	var lines []string
 	end := t.offset + innerH
-	if end > len(t.rows) {
+	if !t.fill(end - 1) {
 		end = len(t.rows)
 	}
+	widest := 0
+	t.checkRowCache(innerW)
 	for i := t.offset; i < end; i++ {
-		line := t.renderRow(t.rows[i], i == t.cursor, innerW)
-		lines = append(lines, line)
+		r := t.cachedRow(i, innerW)
+		widest = max(widest, r.width)
+		lines = append(lines, r.line)
+	}
+	// Never scroll further than needed to show the end of the widest row.
+	t.hOffset = max(0, min(t.hOffset, widest-innerW))
+	for i, line := range lines {
+		lines[i] = clipRow(line, t.hOffset, innerW)
 	}
 	for len(lines) < innerH {
 		lines = append(lines, "")
 	}
 
 	boxStyle := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
+		Border(boxBorder(t.cfg)).
 		BorderForeground(borderColor).
 		Width(w - 2).
 		Height(h - 2)
@@ -731,6 +1258,71 @@ func (t *TreeModel) ViewSized(w, h int) string {
 
 // ---------- rendering ----------
 
+// checkRowCache empties the row cache when rows are to be rendered maxW
+// wide or with a configuration other than the cached ones were.
+func (t *TreeModel) checkRowCache(maxW int) {
+	state := rowRenderState{
+		width:       maxW,
+		style:       t.cfg.TreeStyle,
+		fullPath:    t.cfg.FullPath,
+		accessible:  t.cfg.Accessible,
+		showSources: t.cfg.ShowSources,
+		colors:      t.cfg.Colors,
+		icons:       t.cfg.Icons,
+	}
+	if t.rowCache == nil || state != t.rowCacheState {
+		t.rowCache = make(map[rowCacheKey]renderedRow)
+		t.rowCacheState = state
+	}
+}
+
+// cachedRow returns row i rendered maxW wide, from the cache when it has
+// been rendered before. The selected row is always rendered afresh: its
+// highlight spans the horizontal scroll offset too.
+func (t *TreeModel) cachedRow(i, maxW int) renderedRow {
+	row := t.rows[i]
+	if i == t.cursor {
+		return newRenderedRow(t.renderRow(row, true, maxW))
+	}
+	key := rowCacheKey{row: row}
+	switch row.kind {
+	case rowKindCommand:
+		key.expanded = t.nodeExpanded[nodeKey(row.node, row.depth)]
+	case rowKindSection:
+		key.expanded = t.isSectionExpanded(row.sectionKey, row.sectionDefault)
+	}
+	if r, ok := t.rowCache[key]; ok {
+		return r
+	}
+	r := newRenderedRow(t.renderRow(row, false, maxW))
+	t.rowCache[key] = r
+	return r
+}
+
+func newRenderedRow(line string) renderedRow {
+	// Trailing spaces are selection padding, not content.
+	return renderedRow{line: line, width: ansi.StringWidth(strings.TrimRight(ansi.Strip(line), " "))}
+}
+
+// clipRow cuts a rendered row to the w columns starting at column off, so a
+// long row never wraps inside the pane. Content hidden on either side is
+// marked with an ellipsis.
+func clipRow(line string, off, w int) string {
+	if off > 0 {
+		line = ansi.TruncateLeft(line, off+1, "…")
+	}
+	if ansi.StringWidth(line) > w {
+		line = ansi.Truncate(line, w, "…")
+	}
+	return line
+}
+
+// truncateWidth shortens s to at most w display columns, ending it with an
+// ellipsis when anything was cut.
+func truncateWidth(s string, w int) string {
+	return ansi.Truncate(s, w, "…")
+}
+
 func (t *TreeModel) renderRow(row treeRow, selected bool, maxW int) string {
 	switch row.kind {
 	case rowKindCommand:
@@ -760,7 +1352,7 @@ func (t *TreeModel) renderCommandRow(row treeRow, selected bool, maxW int) strin
 
 // renderCommandRowDefault is the baseline: icon + name + inline flag pills.
 func (t *TreeModel) renderCommandRowDefault(row treeRow, selected bool, maxW int) string {
-	indent := strings.Repeat("  ", row.depth)
+	indent := t.rowIndent(row, "  ")
 	key := nodeKey(row.node, row.depth)
 	isExpanded := t.nodeExpanded[key]
 
@@ -792,8 +1384,9 @@ func (t *TreeModel) renderCommandRowDefault(row treeRow, selected bool, maxW int
 	if t.matchesTokenPrefix(row.node) {
 		nameStyle = nameStyle.Foreground(lipgloss.Color("#50FA7B")).Bold(true)
 	}
-	warn := t.discoveryErrIndicator(row.node)
-	name := nameStyle.Render(row.node.Name)
+	nameStyle = fadeDoubtful(strikeDeprecated(nameStyle, row.node.Deprecated), row.node.Doubtful())
+	warn := t.discoveryIndicator(row.node)
+	name := t.highlight(t.nodeLabel(row.node), nameStyle) + hiddenBadge(row.node) + t.discoveringBadge(row.node) + t.deprecatedBadge(row.node.Deprecated) + t.sourcesBadge(row.node.Sources)
 	summary := t.buildFlagSummary(row, isExpanded)
 
 	// Show description after name when collapsed and space permits.
@@ -803,12 +1396,8 @@ func (t *TreeModel) renderCommandRowDefault(row treeRow, selected bool, maxW int
 		usedW := lipgloss.Width(indent+icon+warn+name) + lipgloss.Width(summary) + lipgloss.Width(sep) + 2
 		maxDesc := maxW - usedW
 		if maxDesc > 8 {
-			desc := row.node.Description
-			runes := []rune(desc)
-			if len(runes) > maxDesc {
-				desc = string(runes[:maxDesc-1]) + "…"
-			}
-			descPart = sep + lipgloss.NewStyle().Faint(true).Render(desc)
+			desc := truncateWidth(row.node.Description, maxDesc)
+			descPart = sep + t.highlight(desc, t.dim())
 		}
 	}
 
@@ -818,7 +1407,7 @@ func (t *TreeModel) renderCommandRowDefault(row treeRow, selected bool, maxW int
 
 // renderCommandRowColumns shows name on the left and description after a · separator.
 func (t *TreeModel) renderCommandRowColumns(row treeRow, selected bool, maxW int) string {
-	indent := strings.Repeat("  ", row.depth)
+	indent := t.rowIndent(row, "  ")
 	key := nodeKey(row.node, row.depth)
 	isExpanded := t.nodeExpanded[key]
 
@@ -849,21 +1438,18 @@ func (t *TreeModel) renderCommandRowColumns(row treeRow, selected bool, maxW int
 	if t.matchesTokenPrefix(row.node) {
 		nameStyle = nameStyle.Foreground(lipgloss.Color("#50FA7B")).Bold(true)
 	}
-	warn := t.discoveryErrIndicator(row.node)
-	name := nameStyle.Render(row.node.Name)
+	nameStyle = fadeDoubtful(strikeDeprecated(nameStyle, row.node.Deprecated), row.node.Doubtful())
+	warn := t.discoveryIndicator(row.node)
+	name := t.highlight(t.nodeLabel(row.node), nameStyle) + hiddenBadge(row.node) + t.discoveringBadge(row.node) + t.deprecatedBadge(row.node.Deprecated) + t.sourcesBadge(row.node.Sources)
 
 	// Build description part: truncate to fit available space.
 	descPart := ""
 	if row.node.Description != "" {
 		sep := lipgloss.NewStyle().Faint(true).Render("  ·  ")
 		maxDesc := maxW - lipgloss.Width(indent+icon+warn+name) - lipgloss.Width(sep) - 2
-		desc := row.node.Description
 		if maxDesc > 8 {
-			runes := []rune(desc)
-			if len(runes) > maxDesc {
-				desc = string(runes[:maxDesc-1]) + "…"
-			}
-			descPart = sep + lipgloss.NewStyle().Faint(true).Render(desc)
+			desc := truncateWidth(row.node.Description, maxDesc)
+			descPart = sep + t.highlight(desc, t.dim())
 		}
 	}
 
@@ -873,7 +1459,7 @@ func (t *TreeModel) renderCommandRowColumns(row treeRow, selected bool, maxW int
 
 // renderCommandRowCompact renders name only — no icons, no inline flags.
 func (t *TreeModel) renderCommandRowCompact(row treeRow, selected bool, maxW int) string {
-	indent := strings.Repeat("  ", row.depth)
+	indent := t.rowIndent(row, "  ")
 
 	nameColor := lipgloss.Color(t.cfg.Colors.Base)
 	if row.depth > 0 {
@@ -886,19 +1472,20 @@ func (t *TreeModel) renderCommandRowCompact(row treeRow, selected bool, maxW int
 	if t.matchesTokenPrefix(row.node) {
 		nameStyle = nameStyle.Foreground(lipgloss.Color("#50FA7B")).Bold(true)
 	}
-	line := indent + t.discoveryErrIndicator(row.node) + nameStyle.Render(row.node.Name)
+	nameStyle = fadeDoubtful(strikeDeprecated(nameStyle, row.node.Deprecated), row.node.Doubtful())
+	line := indent + t.discoveryIndicator(row.node) + t.highlight(t.nodeLabel(row.node), nameStyle) + hiddenBadge(row.node) + t.discoveringBadge(row.node) + t.deprecatedBadge(row.node.Deprecated) + t.sourcesBadge(row.node.Sources)
 	return t.applySelection(line, selected, maxW)
 }
 
 // renderCommandRowGraph renders classic tree connectors (├── / └──).
 func (t *TreeModel) renderCommandRowGraph(row treeRow, selected bool, maxW int) string {
 	var prefix string
-	if row.depth == 0 {
+	if row.depth == 0 || t.cfg.FullPath {
 		prefix = ""
 	} else if row.isLast {
-		prefix = row.graphPrefix + "└── "
+		prefix = row.graphPrefix + t.connectors().last
 	} else {
-		prefix = row.graphPrefix + "├── "
+		prefix = row.graphPrefix + t.connectors().mid
 	}
 	prefix = lipgloss.NewStyle().Faint(true).Render(prefix)
 
@@ -913,7 +1500,8 @@ func (t *TreeModel) renderCommandRowGraph(row treeRow, selected bool, maxW int)
 	if t.matchesTokenPrefix(row.node) {
 		nameStyle = nameStyle.Foreground(lipgloss.Color("#50FA7B")).Bold(true)
 	}
-	name := nameStyle.Render(row.node.Name)
+	nameStyle = fadeDoubtful(strikeDeprecated(nameStyle, row.node.Deprecated), row.node.Doubtful())
+	name := t.highlight(t.nodeLabel(row.node), nameStyle) + hiddenBadge(row.node) + t.discoveringBadge(row.node) + t.deprecatedBadge(row.node.Deprecated) + t.sourcesBadge(row.node.Sources)
 
 	// Show flag count hint when node has own flags.
 	hint := ""
@@ -927,17 +1515,150 @@ func (t *TreeModel) renderCommandRowGraph(row treeRow, selected bool, maxW int)
 		hint = lipgloss.NewStyle().Faint(true).Render(fmt.Sprintf("  [%d flags]", len(ownFlags)))
 	}
 
-	line := prefix + t.discoveryErrIndicator(row.node) + name + hint
+	line := prefix + t.discoveryIndicator(row.node) + name + hint
 	return t.applySelection(line, selected, maxW)
 }
 
-// discoveryErrIndicator returns a styled ⚠ prefix when the node has a
-// non-empty DiscoveryErr, or "" when the node is healthy.
-func (t *TreeModel) discoveryErrIndicator(node *models.Node) string {
-	if node.DiscoveryErr == "" {
+// highlight renders s in style, reversing the text the filter matches in
+// it.
+func (t *TreeModel) highlight(s string, style lipgloss.Style) string {
+	if t.filterShown == nil {
+		return style.Render(s)
+	}
+	var b strings.Builder
+	at := 0
+	for _, sp := range t.filterMatch.Indexes(s) {
+		if sp[0] > at {
+			b.WriteString(style.Render(s[at:sp[0]]))
+		}
+		b.WriteString(style.Reverse(true).Render(s[sp[0]:sp[1]]))
+		at = sp[1]
+	}
+	if at < len(s) {
+		b.WriteString(style.Render(s[at:]))
+	}
+	return b.String()
+}
+
+// rowIndent returns the indentation for row, repeating unit once per level.
+// In full-path mode commands are not indented (the path shows their place)
+// and their sections and items are indented relative to the command.
+func (t *TreeModel) rowIndent(row treeRow, unit string) string {
+	depth := row.depth
+	if t.cfg.FullPath {
+		switch row.kind {
+		case rowKindCommand:
+			depth = 0
+		case rowKindSection:
+			depth = 1
+		default:
+			depth = 2
+		}
+	}
+	return strings.Repeat(unit, depth)
+}
+
+// nodeLabel is the text shown for a command row: its name, or its full
+// command path in full-path mode and for the root, which is a subcommand
+// when the tree starts below the CLI.
+func (t *TreeModel) nodeLabel(node *models.Node) string {
+	if t.cfg.FullPath || node == t.root {
+		return node.FullCommand()
+	}
+	return node.Name
+}
+
+// strikeDeprecated strikes the name of a deprecated command or flag through.
+func strikeDeprecated(style lipgloss.Style, deprecated bool) lipgloss.Style {
+	if deprecated {
+		return style.Strikethrough(true)
+	}
+	return style
+}
+
+// fadeDoubtful fades a command or flag the help parser was unsure of (see
+// models.LowConfidence), so a misparse does not read like the real thing.
+func fadeDoubtful(style lipgloss.Style, doubtful bool) lipgloss.Style {
+	if doubtful {
+		return style.Faint(true).Italic(true)
+	}
+	return style
+}
+
+// sourcesBadge returns the faint "[help,man]" badge naming the strategies
+// that found a command or flag, when show_sources is set.
+func (t *TreeModel) sourcesBadge(sources []string) string {
+	if !t.cfg.ShowSources || len(sources) == 0 {
 		return ""
 	}
-	return lipgloss.NewStyle().Foreground(lipgloss.Color(t.cfg.Colors.Invalid)).Render("⚠ ")
+	return lipgloss.NewStyle().Faint(true).Render(" [" + strings.Join(sources, ",") + "]")
+}
+
+// deprecatedBadge returns " (deprecated)" after a deprecated command or
+// flag in accessibility mode, where the strikethrough alone could go
+// unnoticed, or "".
+func (t *TreeModel) deprecatedBadge(deprecated bool) string {
+	if !deprecated || !t.cfg.Accessible {
+		return ""
+	}
+	return " (deprecated)"
+}
+
+// setDiscovering marks node as being discovered in the background, or as
+// no longer, for the badge after its name. Only node's row is rendered
+// again.
+func (t *TreeModel) setDiscovering(node *models.Node, on bool) {
+	if on {
+		if t.discovering == nil {
+			t.discovering = make(map[*models.Node]bool)
+		}
+		t.discovering[node] = true
+	} else {
+		delete(t.discovering, node)
+	}
+	for key := range t.rowCache {
+		if key.row.kind == rowKindCommand && key.row.node == node {
+			delete(t.rowCache, key)
+		}
+	}
+}
+
+// discoveringBadge returns the faint "discovering…" badge shown after a
+// stub being discovered in the background, or "".
+func (t *TreeModel) discoveringBadge(node *models.Node) string {
+	switch {
+	case !t.discovering[node]:
+		return ""
+	case t.cfg.Accessible:
+		return " (discovering)"
+	}
+	return lipgloss.NewStyle().Faint(true).Italic(true).Render(" discovering…")
+}
+
+// hiddenBadge returns the faint "(hidden)" badge shown after a command the
+// CLI leaves out of its help, or "".
+func hiddenBadge(node *models.Node) string {
+	if !node.Hidden {
+		return ""
+	}
+	return lipgloss.NewStyle().Faint(true).Italic(true).Render(" (hidden)")
+}
+
+// discoveryIndicator returns a styled ⚠ prefix when the node has a
+// non-empty DiscoveryErr, a faint … prefix when the node is a stub that has
+// not been discovered yet, or "" when the node is healthy.
+func (t *TreeModel) discoveryIndicator(node *models.Node) string {
+	switch {
+	case node.DiscoveryErr != "" && t.cfg.Accessible:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(t.cfg.Colors.Invalid)).Render("(error) ")
+	case node.DiscoveryErr != "":
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(t.cfg.Colors.Invalid)).Render("⚠ ")
+	case node.Stub && t.cfg.Accessible:
+		return "... "
+	case node.Stub:
+		return lipgloss.NewStyle().Faint(true).Render("… ")
+	}
+	return ""
 }
 
 // buildFlagSummary builds the inline flag pill string for the default style.
@@ -954,6 +1675,7 @@ func (t *TreeModel) buildFlagSummary(row treeRow, isExpanded bool) string {
 	if len(ownFlags) == 0 {
 		return ""
 	}
+	known := knownFlags(row.node, t.root)
 	const maxInlineFlags = 5
 	bracketStyle := lipgloss.NewStyle().Faint(true)
 	dimStyle := lipgloss.NewStyle().Faint(true)
@@ -961,9 +1683,9 @@ func (t *TreeModel) buildFlagSummary(row treeRow, isExpanded bool) string {
 	if len(ownFlags) > maxInlineFlags {
 		var activeParts []string
 		for _, f := range ownFlags {
-			if isFlagActive(f, t.cmdTokens) {
+			if isFlagActive(f, t.cmdTokens, known) {
 				fs := f.Name
-				if f.ValueType != "" && f.ValueType != "bool" {
+				if f.TakesValue() {
 					fs += "=<" + f.ValueType + ">"
 				}
 				activeParts = append(activeParts, activeStyle.Render(fs))
@@ -980,14 +1702,17 @@ func (t *TreeModel) buildFlagSummary(row treeRow, isExpanded bool) string {
 	var flagParts []string
 	for _, f := range ownFlags {
 		fs := f.Name
-		if f.ValueType != "" && f.ValueType != "bool" {
+		switch {
+		case t.cfg.Accessible:
+			fs += " " + f.TypeTag()
+		case f.TakesValue():
 			fs += "=<" + f.ValueType + ">"
 		}
-		if isFlagActive(f, t.cmdTokens) {
-			flagParts = append(flagParts, activeStyle.Render(fs))
-		} else {
-			flagParts = append(flagParts, t.flagColorStyle(f.ValueType).Faint(true).Render(fs))
+		style := t.flagColorStyle(f.ValueType).Faint(true)
+		if isFlagActive(f, t.cmdTokens, known) {
+			style = activeStyle
 		}
+		flagParts = append(flagParts, fadeDoubtful(strikeDeprecated(style, f.Deprecated), f.Doubtful()).Render(fs))
 	}
 	return " " + bracketStyle.Render("[") +
 		strings.Join(flagParts, bracketStyle.Render(",")) +
@@ -1003,15 +1728,16 @@ func (t *TreeModel) applySelection(line string, selected bool, maxW int) string
 		Background(lipgloss.Color(t.cfg.Colors.Selected)).
 		Foreground(lipgloss.Color(t.cfg.Colors.SelectedText)).
 		Bold(true)
+	// Pad past the scroll offset so the highlight still spans the pane.
 	lineW := lipgloss.Width(line)
-	if lineW < maxW {
-		line += strings.Repeat(" ", maxW-lineW)
+	if padW := maxW + t.hOffset; lineW < padW {
+		line += strings.Repeat(" ", padW-lineW)
 	}
 	return selStyle.Render(line)
 }
 
 func (t *TreeModel) renderSectionRow(row treeRow, selected bool, maxW int) string {
-	indent := strings.Repeat("  ", row.depth)
+	indent := t.rowIndent(row, "  ")
 	expanded := t.isSectionExpanded(row.sectionKey, row.sectionDefault)
 	icon := t.cfg.Icons.SectionCollapsed
 	if expanded {
@@ -1031,37 +1757,47 @@ func (t *TreeModel) renderFlagRow(row treeRow, selected bool, maxW int) string {
 
 	var indent string
 	if t.cfg.TreeStyle == config.StyleGraph {
-		indent = strings.Repeat("    ", row.depth)
+		indent = t.rowIndent(row, "    ")
 	} else {
-		indent = strings.Repeat("  ", row.depth)
+		indent = t.rowIndent(row, "  ")
 	}
 
 	typeHint := ""
-	if !compact && f.ValueType != "" && f.ValueType != "bool" {
+	switch {
+	case t.cfg.Accessible:
+		typeHint = " " + f.TypeTag()
+	case !compact && f.TakesValue():
 		typeHint = " <" + f.ValueType + ">"
 	}
 
+	name := f.Name
+	active := isFlagActive(*f, t.cmdTokens, knownFlags(row.owner, t.root))
+	if f.Negatable {
+		// Shown as --[no-]color, or as --no-color while that is given.
+		name = negatableLabel(*f)
+		if i, on := negatableState(*f, t.cmdTokens, knownFlags(row.owner, t.root)); i >= 0 && !on {
+			name, active = f.NegatedName(), true
+		}
+	}
 	nameStyle := t.flagColorStyle(f.ValueType)
-	if isFlagActive(*f, t.cmdTokens) {
+	if active {
 		nameStyle = nameStyle.Underline(true).Bold(true)
 	}
+	nameStyle = fadeDoubtful(strikeDeprecated(nameStyle, f.Deprecated), f.Doubtful())
 
-	namePart := nameStyle.Render(f.Name)
+	namePart := t.highlight(name, nameStyle)
 	typePart := ""
 	if typeHint != "" {
-		typePart = lipgloss.NewStyle().Faint(true).Render(typeHint)
+		typePart = t.dim().Render(typeHint)
 	}
 	descPart := ""
 	if !compact && f.Description != "" {
 		const maxDescLen = 45
-		desc := f.Description
-		if len([]rune(desc)) > maxDescLen {
-			desc = string([]rune(desc)[:maxDescLen-1]) + "…"
-		}
-		descPart = "  " + lipgloss.NewStyle().Faint(true).Render(desc)
+		desc := truncateWidth(f.Description, maxDescLen)
+		descPart = "  " + t.highlight(desc, t.dim())
 	}
 
-	line := indent + namePart + typePart + descPart
+	line := indent + namePart + typePart + t.deprecatedBadge(f.Deprecated) + t.sourcesBadge(f.Sources) + descPart
 	return t.applySelection(line, selected, maxW)
 }
 
@@ -1069,9 +1805,9 @@ func (t *TreeModel) renderPositionalRow(row treeRow, selected bool, maxW int) st
 	compact := t.cfg.TreeStyle == config.StyleCompact
 	var indent string
 	if t.cfg.TreeStyle == config.StyleGraph {
-		indent = strings.Repeat("    ", row.depth)
+		indent = t.rowIndent(row, "    ")
 	} else {
-		indent = strings.Repeat("  ", row.depth)
+		indent = t.rowIndent(row, "  ")
 	}
 	p := row.positional
 
@@ -1082,15 +1818,12 @@ func (t *TreeModel) renderPositionalRow(row treeRow, selected bool, maxW int) st
 		nameStr = "[" + p.Name + "]"
 	}
 
-	namePart := posStyle.Render(nameStr)
+	namePart := t.highlight(nameStr, posStyle)
 	descPart := ""
 	if !compact && p.Description != "" {
 		const maxDescLen = 45
-		desc := p.Description
-		if len([]rune(desc)) > maxDescLen {
-			desc = string([]rune(desc)[:maxDescLen-1]) + "…"
-		}
-		descPart = "  " + lipgloss.NewStyle().Faint(true).Render(desc)
+		desc := truncateWidth(p.Description, maxDescLen)
+		descPart = "  " + t.highlight(desc, t.dim())
 	}
 
 	line := indent + namePart + descPart
@@ -1099,45 +1832,131 @@ func (t *TreeModel) renderPositionalRow(row treeRow, selected bool, maxW int) st
 
 // ---------- rebuild ----------
 
+// flattenStep is what is left to flatten of the tree: a command and what
+// it expands to, or rows laid out already that follow its subcommands.
+type flattenStep struct {
+	node        *models.Node
+	depth       int
+	graphPrefix string
+	isLast      bool
+	rows        []treeRow // when node is nil
+}
+
+// rebuild lays the rows out afresh. They are flattened only as far as they
+// are needed: down to the rows shown, or the one the cursor is on, so that
+// expanding a command in a huge tree does not flatten all of it.
 func (t *TreeModel) rebuild() {
+	if len(t.rowCache) > 2*len(t.rows)+256 {
+		// Mostly rows collapsed or filtered away since.
+		t.rowCache = nil
+	}
 	t.rows = nil
-	t.flattenNode(t.root, 0, "", true)
-	if t.cursor >= len(t.rows) && len(t.rows) > 0 {
+	t.pending = []flattenStep{{node: t.root, isLast: true}}
+	if !t.fill(t.cursor) && len(t.rows) > 0 {
 		t.cursor = len(t.rows) - 1
 	}
 }
 
+// fill flattens the tree until row i is laid out, or no rows are left, and
+// reports whether there is a row i.
+func (t *TreeModel) fill(i int) bool {
+	for len(t.rows) <= i && len(t.pending) > 0 {
+		s := t.pending[len(t.pending)-1]
+		t.pending = t.pending[:len(t.pending)-1]
+		if s.node == nil {
+			t.rows = append(t.rows, s.rows...)
+		} else {
+			t.flattenNode(s.node, s.depth, s.graphPrefix, s.isLast)
+		}
+	}
+	return i >= 0 && i < len(t.rows)
+}
+
+// eachRow yields the rows with their indices, flattening them as it goes,
+// so a loop that stops at the row it looks for flattens no further.
+func (t *TreeModel) eachRow() iter.Seq2[int, treeRow] {
+	return func(yield func(int, treeRow) bool) {
+		for i := 0; t.fill(i); i++ {
+			if !yield(i, t.rows[i]) {
+				return
+			}
+		}
+	}
+}
+
+// allRows flattens what is left of the tree and returns all of its rows.
+func (t *TreeModel) allRows() []treeRow {
+	t.fill(math.MaxInt - 1)
+	return t.rows
+}
+
+// flattenNode lays out node's row and those of its flags, positionals and
+// sections, and queues its subcommands to be flattened next, followed by
+// the rows that come after them.
 func (t *TreeModel) flattenNode(node *models.Node, depth int, graphPrefix string, isLast bool) {
 	if node.Virtual {
 		return
 	}
+	var children []flattenStep
+	var after []treeRow // rows after the subcommands
+	defer func() {
+		if len(after) > 0 {
+			t.pending = append(t.pending, flattenStep{rows: after})
+		}
+		for i := len(children) - 1; i >= 0; i-- {
+			t.pending = append(t.pending, children[i])
+		}
+	}()
 
 	key := nodeKey(node, depth)
 	expanded := t.nodeExpanded[key]
 
 	// Collect visible (non-virtual) children up front — needed by filter logic.
 	var visChildren []*models.Node
-	for _, c := range node.Children {
+	for _, c := range t.sortedChildren(node) {
 		if !c.Virtual {
 			visChildren = append(visChildren, c)
 		}
 	}
 
-	filtering := t.filter != ""
-
-	// When filtering: show this node if it directly matches OR any descendant
-	// matches (so ancestors act as context breadcrumbs). Always recurse into
-	// children regardless of expanded state so the full tree is searched.
-	if filtering {
-		if matchesFilter(node, t.filter) || nodeOrDescendantMatchesFilter(node, t.filter) {
-			t.rows = append(t.rows, treeRow{
-				kind:  rowKindCommand,
-				depth: depth,
-				node:  node,
-			})
+	// When filtering: show the commands that match and their ancestors
+	// (so ancestors act as context breadcrumbs), regardless of expanded
+	// state, and under each command the flags and positionals that match.
+	if t.filterShown != nil {
+		if !t.filterShown[node] {
+			return
+		}
+		t.rows = append(t.rows, treeRow{
+			kind:  rowKindCommand,
+			depth: depth,
+			node:  node,
+		})
+		if !t.cfg.CommandsOnly {
+			for _, i := range t.flagOrder(node) {
+				if t.flagMatches(&node.Flags[i]) {
+					t.rows = append(t.rows, treeRow{
+						kind:       rowKindFlag,
+						depth:      depth + 1,
+						flag:       &node.Flags[i],
+						owner:      node,
+						ownerDepth: depth,
+					})
+				}
+			}
+			for i := range node.Positionals {
+				if t.positionalMatches(&node.Positionals[i]) {
+					t.rows = append(t.rows, treeRow{
+						kind:       rowKindPositional,
+						depth:      depth + 1,
+						positional: &node.Positionals[i],
+						owner:      node,
+						ownerDepth: depth,
+					})
+				}
+			}
 		}
 		for _, c := range visChildren {
-			t.flattenNode(c, depth+1, "", true)
+			children = append(children, flattenStep{node: c, depth: depth + 1, isLast: true})
 		}
 		return
 	}
@@ -1155,9 +1974,26 @@ func (t *TreeModel) flattenNode(node *models.Node, depth int, graphPrefix string
 		return
 	}
 
+	childGraphPrefix := ""
+	if depth == 0 {
+		childGraphPrefix = ""
+	} else if isLast {
+		childGraphPrefix = graphPrefix + t.connectors().lastPad
+	} else {
+		childGraphPrefix = graphPrefix + t.connectors().midPad
+	}
+
+	// Commands-only: subcommands directly under their parent, no sections.
+	if t.cfg.CommandsOnly {
+		for i, c := range visChildren {
+			children = append(children, flattenStep{node: c, depth: depth + 1, graphPrefix: childGraphPrefix, isLast: i == len(visChildren)-1})
+		}
+		return
+	}
+
 	// Partition flags into own (local) and inherited (global).
 	var ownFlags, inheritedFlags []int // indices into node.Flags
-	for i := range node.Flags {
+	for _, i := range t.flagOrder(node) {
 		if node.Flags[i].Inherited {
 			inheritedFlags = append(inheritedFlags, i)
 		} else {
@@ -1192,22 +2028,30 @@ func (t *TreeModel) flattenNode(node *models.Node, depth int, graphPrefix string
 		}
 	}
 
-	// Own (local) flags section.
-	if len(ownFlags) > 0 {
-		sKey := key + "/flags"
-		flagDefault := len(ownFlags) <= 5
+	// Own (local) flags sections: one, or one per group the help lists
+	// them in ("General options", "Debug options").
+	flagGroups := node.FlagGroups(ownFlags)
+	if flagGroups == nil && len(ownFlags) > 0 {
+		flagGroups = []models.FlagGroup{{Flags: ownFlags}}
+	}
+	for _, g := range flagGroups {
+		sKey, label := key+"/flags", "Flags"
+		if g.Name != "" {
+			sKey, label = key+"/flags/"+g.Name, g.Name
+		}
+		flagDefault := len(g.Flags) <= 5
 		flagExpanded := t.hideSections || t.isSectionExpanded(sKey, flagDefault)
 		if !t.hideSections {
 			t.rows = append(t.rows, treeRow{
 				kind:           rowKindSection,
 				depth:          depth + 1,
 				sectionKey:     sKey,
-				sectionLabel:   fmt.Sprintf("Flags (%d)", len(ownFlags)),
+				sectionLabel:   fmt.Sprintf("%s (%d)", label, len(g.Flags)),
 				sectionDefault: flagDefault,
 			})
 		}
 		if flagExpanded {
-			for _, i := range ownFlags {
+			for _, i := range g.Flags {
 				t.rows = append(t.rows, treeRow{
 					kind:       rowKindFlag,
 					depth:      depth + 2,
@@ -1235,27 +2079,19 @@ func (t *TreeModel) flattenNode(node *models.Node, depth int, graphPrefix string
 			})
 		}
 		if subExpanded {
-			childGraphPrefix := ""
-			if depth == 0 {
-				childGraphPrefix = ""
-			} else if isLast {
-				childGraphPrefix = graphPrefix + "    "
-			} else {
-				childGraphPrefix = graphPrefix + "│   "
-			}
 			for i, c := range visChildren {
-				t.flattenNode(c, depth+1, childGraphPrefix, i == len(visChildren)-1)
+				children = append(children, flattenStep{node: c, depth: depth + 1, graphPrefix: childGraphPrefix, isLast: i == len(visChildren)-1})
 			}
 		}
 	}
 
-	// Positional arguments section.
+	// Positional arguments section, after the subcommands.
 	if len(node.Positionals) > 0 {
 		sKey := key + "/positionals"
 		posDefault := len(node.Positionals) <= 5
 		posExpanded := t.hideSections || t.isSectionExpanded(sKey, posDefault)
 		if !t.hideSections {
-			t.rows = append(t.rows, treeRow{
+			after = append(after, treeRow{
 				kind:           rowKindSection,
 				depth:          depth + 1,
 				sectionKey:     sKey,
@@ -1265,7 +2101,7 @@ func (t *TreeModel) flattenNode(node *models.Node, depth int, graphPrefix string
 		}
 		if posExpanded {
 			for i := range node.Positionals {
-				t.rows = append(t.rows, treeRow{
+				after = append(after, treeRow{
 					kind:       rowKindPositional,
 					depth:      depth + 2,
 					positional: &node.Positionals[i],
@@ -1316,60 +2152,46 @@ func pathsEqual(a, b []string) bool {
 	return true
 }
 
-func isFlagActive(f models.Flag, tokens []string) bool {
-	longName := strings.TrimPrefix(f.Name, "--")
-	for _, tok := range tokens {
-		if strings.HasPrefix(tok, "--") {
-			name := strings.TrimPrefix(tok, "--")
-			if idx := strings.Index(name, "="); idx >= 0 {
-				name = name[:idx]
-			}
-			if strings.EqualFold(name, longName) {
-				return true
-			}
-		} else if strings.HasPrefix(tok, "-") && len(tok) == 2 && f.ShortName != "" {
-			if strings.EqualFold(tok[1:], f.ShortName) {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-func matchesFilter(node *models.Node, filter string) bool {
-	return strings.Contains(strings.ToLower(node.Name), strings.ToLower(filter))
-}
-
-// nodeOrDescendantMatchesFilter returns true if any non-virtual descendant of
-// node matches the filter. Used to keep ancestor rows visible as breadcrumbs.
-func nodeOrDescendantMatchesFilter(node *models.Node, filter string) bool {
-	for _, c := range node.Children {
-		if c.Virtual {
-			continue
-		}
-		if matchesFilter(c, filter) || nodeOrDescendantMatchesFilter(c, filter) {
-			return true
-		}
-	}
-	return false
+func (t *TreeModel) matchesTokenPrefix(node *models.Node) bool {
+	return tokenPrefix(node.FullPath, t.cmdTokens)
 }
 
-func (t *TreeModel) matchesTokenPrefix(node *models.Node) bool {
-	if len(t.cmdTokens) == 0 {
-		return false
-	}
-	fp := node.FullPath
-	if len(fp) > len(t.cmdTokens) {
+// tokenPrefix reports whether tokens start with the command path fp.
+func tokenPrefix(fp, tokens []string) bool {
+	if len(tokens) == 0 || len(fp) > len(tokens) {
 		return false
 	}
 	for i, part := range fp {
-		if !strings.EqualFold(part, t.cmdTokens[i]) {
+		if !strings.EqualFold(part, tokens[i]) {
 			return false
 		}
 	}
 	return true
 }
 
+// dim returns the style of descriptions and type hints: faint, or plain in
+// accessibility mode, where faint text would be too low in contrast.
+func (t *TreeModel) dim() lipgloss.Style {
+	if t.cfg.Accessible {
+		return lipgloss.NewStyle()
+	}
+	return lipgloss.NewStyle().Faint(true)
+}
+
+// treeConnectors are the graph style's connectors: box-drawing ones, or
+// ASCII ones in accessibility mode.
+type treeConnectors struct {
+	mid, last, midPad, lastPad string
+}
+
+// connectors returns the graph style's connectors.
+func (t *TreeModel) connectors() treeConnectors {
+	if t.cfg.Accessible {
+		return treeConnectors{mid: "|-- ", last: "`-- ", midPad: "|   ", lastPad: "    "}
+	}
+	return treeConnectors{mid: "├── ", last: "└── ", midPad: "│   ", lastPad: "    "}
+}
+
 func (t *TreeModel) flagColorStyle(valueType string) lipgloss.Style {
 	var hex string
 	switch valueType {
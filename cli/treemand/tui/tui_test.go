@@ -1,14 +1,24 @@
 package tui_test
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
 	"github.com/aallbrig/treemand/config"
 	"github.com/aallbrig/treemand/models"
+	"github.com/aallbrig/treemand/override"
 	"github.com/aallbrig/treemand/tui"
 )
 
@@ -157,6 +167,65 @@ func TestTreeModel_cmdTokens_highlight(t *testing.T) {
 	}
 }
 
+func TestTreeModel_cmdTokens_rerendersFlagRows(t *testing.T) {
+	root := &models.Node{
+		Name: "git", FullPath: []string{"git"},
+		Flags: []models.Flag{{Name: "--color", ValueType: "bool", Negatable: true}, {Name: "--paginate"}},
+	}
+	tree := tui.NewTreeModel(root, config.DefaultConfig())
+	tree.SetSize(80, 24)
+	if v := tree.View(); !strings.Contains(v, "--[no-]color") {
+		t.Fatalf("expected both forms of the flag:\n%s", v)
+	}
+	// Rows rendered before the tokens changed show the flag as given now.
+	tree.SetCmdTokens([]string{"git", "--no-color"})
+	if v := tree.View(); !strings.Contains(v, "--no-color") || strings.Contains(v, "--[no-]color") {
+		t.Errorf("expected the flag's given form:\n%s", v)
+	}
+	tree.SetCmdTokens([]string{"git", "--no-color", "--paginate"})
+	if v := tree.View(); !strings.Contains(v, "--no-color") {
+		t.Errorf("a row whose flag stays given should stay so:\n%s", v)
+	}
+	tree.SetCmdTokens([]string{"git"})
+	if v := tree.View(); !strings.Contains(v, "--[no-]color") {
+		t.Errorf("expected both forms again:\n%s", v)
+	}
+}
+
+func TestTreeModel_hugeTreeFlattensAsNeeded(t *testing.T) {
+	root := &models.Node{Name: "tool", FullPath: []string{"tool"}}
+	for i := range 2000 {
+		name := fmt.Sprintf("cmd%04d", i)
+		root.Children = append(root.Children, &models.Node{
+			Name: name, FullPath: []string{"tool", name},
+			Flags: []models.Flag{{Name: "--verbose"}},
+		})
+	}
+	tree := tui.NewTreeModel(root, config.DefaultConfig())
+	tree.SetSize(80, 24)
+	tree.ExpandAll()
+	if v := tree.View(); !strings.Contains(v, "cmd0000") || strings.Contains(v, "cmd1999") {
+		t.Errorf("expected the first commands only:\n%s", v)
+	}
+	// tool, Subcommands, then cmdN, Flags and --verbose for each command.
+	if n := tree.RowCount(); n != 2+3*2000 {
+		t.Errorf("RowCount = %d, want %d", n, 2+3*2000)
+	}
+	tree.Bottom()
+	if item := tree.SelectedItem(); item == nil || item.Kind != tui.SelFlag || item.Owner.Name != "cmd1999" {
+		t.Errorf("Bottom should select the last command's flag, got %+v", item)
+	}
+	tree.Top()
+	tree.PageDown() // 22 rows down: cmd0006's --verbose
+	tree.Down()
+	if got := tree.Selected(); got != root.Children[7] {
+		t.Errorf("expected cmd0007 after paging down, got %v", got)
+	}
+	if !tree.Reveal(root.Children[1500]) || tree.Selected() != root.Children[1500] {
+		t.Errorf("Reveal should select cmd1500, got %v", tree.Selected())
+	}
+}
+
 func TestTreeModel_inlineFlags(t *testing.T) {
 	cfg := config.DefaultConfig()
 	tree := tui.NewTreeModel(sampleTree(), cfg)
@@ -486,8 +555,10 @@ func TestModel_CtrlE_opensModal(t *testing.T) {
 	// Set a command first.
 	m.Update(tea.KeyMsg{Type: tea.KeyRight})
 	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
-	// Ctrl+E opens execute modal.
+	// Ctrl+E prompts for commit's required <msg>, then opens execute modal.
 	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("wip")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
 	v := m.View()
 	if !strings.Contains(v, "Execute") && !strings.Contains(v, "commit") {
 		t.Error("expected execute modal after Ctrl+E")
@@ -631,6 +702,11 @@ func TestTreeModel_Filter_ClearedRestoresFull(t *testing.T) {
 	if tm.RowCount() != rowsBefore {
 		t.Errorf("after clearing filter expected %d rows, got %d", rowsBefore, tm.RowCount())
 	}
+	// The filter moved the cursor off root, which it still showed, to
+	// reach gamma; clearing it goes back.
+	if sel := tm.Selected(); sel == nil || sel.Name != "root" {
+		t.Errorf("selected = %v, want root back after clearing the filter", sel)
+	}
 }
 
 func TestTreeModel_Filter_PartialMatch(t *testing.T) {
@@ -642,6 +718,119 @@ func TestTreeModel_Filter_PartialMatch(t *testing.T) {
 	}
 }
 
+func TestTreeModel_Filter_RegexAndFullPath(t *testing.T) {
+	tm := newTreeModel(deepFilterTree())
+	tm.SetFilter("^eps,alpha delta")
+	view := tm.ViewSized(80, 40)
+	for _, name := range []string{"epsilon", "delta", "alpha"} {
+		if !strings.Contains(view, name) {
+			t.Errorf("%q should be visible for filter '^eps,alpha delta'", name)
+		}
+	}
+	if strings.Contains(view, "gamma") {
+		t.Error("'gamma' should be hidden: the path term only matches root alpha delta")
+	}
+}
+
+func TestTreeModel_Filter_typedMatchesAFreshFilter(t *testing.T) {
+	root := largeTree(12, 15, 2)
+	typed := newTreeModel(root)
+	// Each query extends the last but "cmd1" and "", yet "cmd1|cmd2" and
+	// "cmd1,cmd2" widen it, and "group1 cmd2" matches command paths, which
+	// "group1" did not.
+	for _, f := range []string{"c", "cm", "cmd", "cmd1", "cmd1|cmd2", "cmd1", "cmd1,cmd2", "", "group1", "group1 cmd2"} {
+		typed.SetFilter(f)
+		fresh := newTreeModel(root)
+		fresh.SetFilter(f)
+		if typed.RowCount() != fresh.RowCount() {
+			t.Errorf("filter %q: RowCount = %d, want %d", f, typed.RowCount(), fresh.RowCount())
+		}
+	}
+}
+
+func TestTreeModel_Filter_keepsSelectionAndRestoresExpansion(t *testing.T) {
+	root := sampleTree()
+	commit, add := root.Children[0], root.Children[1].Children[0]
+	tm := newTreeModel(root)
+	tm.SelectNode(commit)
+
+	tm.SetFilter("comm")
+	if sel := tm.Selected(); sel != commit {
+		t.Fatalf("selected = %v, want commit kept while the filter shows it", sel)
+	}
+	tm.ExpandAll()
+	tm.SetFilter("add")
+	if sel := tm.Selected(); sel != add {
+		t.Fatalf("selected = %v, want the first match once commit is filtered out", sel)
+	}
+
+	tm.SetFilter("")
+	if sel := tm.Selected(); sel != add {
+		t.Errorf("selected = %v, want add kept after clearing the filter", sel)
+	}
+	// Expanded as before the filter, and as far as needed to show add.
+	want := newTreeModel(root)
+	want.Reveal(add)
+	if got, want := tm.View(), want.View(); got != want {
+		t.Errorf("clearing the filter should restore the expansion:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTreeModel_Filter_matchesFlagsAndPositionals(t *testing.T) {
+	tm := newTreeModel(sampleTree())
+	tm.SetFilter("am")
+	if n := tm.RowCount(); n != 3 {
+		t.Errorf("RowCount = %d, want 3 (git, commit, --amend):\n%s", n, tm.View())
+	}
+	if sel := tm.SelectedItem(); sel == nil || sel.Kind != tui.SelFlag || sel.Flag.Name != "--amend" {
+		t.Errorf("the first match, --amend, should be selected; got %+v", sel)
+	}
+	if n := tm.FilterMatches(); n != 1 {
+		t.Errorf("FilterMatches = %d, want 1", n)
+	}
+
+	tm.SetFilter("msg|pag")
+	v := tm.View()
+	for _, want := range []string{"--paginate", "--no-pager", "<msg>"} {
+		if !strings.Contains(v, want) {
+			t.Errorf("filter should show %s:\n%s", want, v)
+		}
+	}
+	if n := tm.FilterMatches(); n != 3 {
+		t.Errorf("FilterMatches = %d, want 3", n)
+	}
+
+	tm.SetCommandsOnly(true)
+	if n := tm.FilterMatches(); n != 0 || tm.RowCount() != 0 {
+		t.Errorf("commands only: FilterMatches = %d, RowCount = %d, want no matches", n, tm.RowCount())
+	}
+	tm.SetFilter("")
+	if n := tm.FilterMatches(); n != -1 {
+		t.Errorf("FilterMatches without a filter = %d, want -1", n)
+	}
+}
+
+func TestModel_filterShowsMatchCount(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.StatusMsgTimeout = 0 // the status bar shows hints, not the last message
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(140, 30)
+	for _, r := range "/am" {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	if v := m.View(); !strings.Contains(v, "1 match  type to filter") {
+		t.Errorf("the status bar should count the matches while filtering:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if v := m.View(); !strings.Contains(v, "[/am: 1 match]") {
+		t.Errorf("the status bar should show the filter and its matches:\n%s", v)
+	}
+	runColon(m, "plain")
+	if v := m.View(); !strings.Contains(v, "Filter: am (1 match)") {
+		t.Errorf("the plain view should count the matches:\n%s", v)
+	}
+}
+
 func TestTreeModel_Filter_NoMatch_EmptyView(t *testing.T) {
 	tm := newTreeModel(deepFilterTree())
 	tm.SetFilter("zzznomatch")
@@ -922,6 +1111,63 @@ func sampleTreeWithStub() *models.Node {
 	}
 }
 
+func TestModel_DiscoverInBackground(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$1 $2" in
+  "sub deep"*) printf 'Usage: fakecli sub deep [flags]\n\nFlags:\n  --thing   do the thing\n' ;;
+  "sub "*)     printf 'the sub command\n\nCommands:\n  deep   the deep one\n' ;;
+  *)           printf 'fakecli does things\n\nCommands:\n  sub   the sub command\n' ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "fakecli"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	shallow := func() *models.Node {
+		return &models.Node{
+			Name: "fakecli", FullPath: []string{"fakecli"},
+			Children: []*models.Node{{Name: "sub", FullPath: []string{"fakecli", "sub"}, Stub: true}},
+		}
+	}
+
+	root := shallow()
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.TreeModel().View() // renders sub's row before it is discovered
+
+	var done atomic.Int32 // called from a command's goroutine
+	m.DiscoverInBackground(3, func() { done.Add(1) })
+	cmd := m.Init()
+	if v := m.TreeModel().View(); !strings.Contains(v, "sub discovering…") {
+		t.Errorf("a stub discovered in the background should say so:\n%s", v)
+	}
+	pump(m, cmd, 3)
+	sub := root.Find("sub")
+	if sub.Stub || sub.Find("deep") == nil {
+		t.Fatalf("sub should be discovered in the background, got %+v", sub)
+	}
+	if v := m.View(); strings.Contains(v, "discovering…") || !strings.Contains(v, "in the background") {
+		t.Errorf("background discovery should end and report it:\n%s", v)
+	}
+	if n := done.Load(); n != 1 {
+		t.Errorf("done called %d times once the tree was discovered, want 1 to cache it", n)
+	}
+
+	// Offline, nothing is run.
+	cfg := config.DefaultConfig()
+	cfg.Offline = true
+	root = shallow()
+	m = tui.NewModel(root, cfg)
+	m.SetSize(120, 40)
+	done.Store(0)
+	m.DiscoverInBackground(3, func() { done.Add(1) })
+	pump(m, m.Init(), 3)
+	if !root.Find("sub").Stub || done.Load() != 0 {
+		t.Error("offline, stubs should be left as they are")
+	}
+}
+
 func sampleTreeWithValueFlag() *models.Node {
 	return &models.Node{
 		Name:     "git",
@@ -1232,6 +1478,102 @@ func TestModel_PreviewPane_input(t *testing.T) {
 	}
 }
 
+func TestModel_PreviewPane_followsAndCompletes(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m.Update(tea.MouseMsg{Action: tea.MouseActionPress, Button: tea.MouseButtonLeft, X: 10, Y: 0})
+	typeText := func(s string) { m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}) }
+	preview := func() string { return strings.Join(m.Preview().Tokens(), " ") }
+
+	// Typing part of a subcommand moves the cursor to it.
+	typeText(" rem")
+	if sel := m.TreeModel().SelectedItem(); sel == nil || sel.Kind != tui.SelCommand || sel.Node.Name != "remote" {
+		t.Fatalf("typing \"rem\" should select remote, got %+v", sel)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	typeText("a")
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if got := preview(); got != "git remote add" {
+		t.Errorf("after Tab = %q, want \"git remote add\"", got)
+	}
+	if sel := m.TreeModel().SelectedItem(); sel == nil || sel.Node.Name != "add" {
+		t.Errorf("completing add should select it, got %+v", sel)
+	}
+
+	// A flag is revealed under its command and completed as far as the
+	// matching flags agree.
+	m.Preview().SetCommand("")
+	typeText("git commit --am")
+	if sel := m.TreeModel().SelectedItem(); sel == nil || sel.Kind != tui.SelFlag || sel.Flag.Name != "--amend" {
+		t.Fatalf("typing \"--am\" should select --amend, got %+v", sel)
+	}
+	m.Preview().SetCommand("")
+	typeText("git commit --a")
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if v := m.View(); preview() != "git commit --a" || !strings.Contains(v, "--all --amend") {
+		t.Errorf("ambiguous Tab should list the matches, preview %q:\n%s", preview(), v)
+	}
+	typeText("l")
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if got := preview(); got != "git commit --all" {
+		t.Errorf("after Tab = %q, want \"git commit --all\"", got)
+	}
+
+	// With nothing to complete, Tab moves the focus on.
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if !strings.Contains(m.View(), "focus:") {
+		t.Error("Tab after a space should move the focus")
+	}
+}
+
+func TestModel_PreviewPane_suggestionPopup(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m.Update(tea.MouseMsg{Action: tea.MouseActionPress, Button: tea.MouseButtonLeft, X: 10, Y: 0})
+	typeText := func(s string) { m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}) }
+	preview := func() string { return strings.Join(m.Preview().Tokens(), " ") }
+
+	// After a space, the popup lists the command's subcommands, under the
+	// preview bar.
+	typeText(" ")
+	lines := strings.Split(m.View(), "\n")
+	if popup := strings.Join(lines[2:6], "\n"); !strings.Contains(popup, "2 matches") ||
+		!strings.Contains(popup, "commit") || !strings.Contains(popup, "remote") {
+		t.Fatalf("popup should list commit and remote under the preview:\n%s", m.View())
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := preview(); got != "git commit" {
+		t.Errorf("Enter on the highlighted item = %q, want \"git commit\"", got)
+	}
+
+	// Flags with their descriptions; Tab fills the highlighted one.
+	typeText("--a")
+	if v := m.View(); !strings.Contains(v, "--all") || !strings.Contains(v, "--amend") {
+		t.Fatalf("popup should list --all and --amend:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if got := preview(); got != "git commit --amend" {
+		t.Errorf("Tab on the highlighted item = %q, want \"git commit --amend\"", got)
+	}
+
+	// Esc closes the popup, and only then leaves the preview.
+	typeText("--a")
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if strings.Contains(m.View(), "matches (") {
+		t.Error("Esc should close the popup")
+	}
+	if strings.Contains(m.View(), "focus: tree") {
+		t.Error("the first Esc should keep the preview focused")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if !strings.Contains(m.View(), "focus: tree") {
+		t.Error("the second Esc should focus the tree")
+	}
+}
+
 func TestModel_RefreshKey(t *testing.T) {
 	m := tui.NewModel(sampleTree(), config.DefaultConfig())
 	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
@@ -2172,8 +2514,10 @@ func TestExecuteModal_openOnCtrlE(t *testing.T) {
 	}
 	m.Update(tea.KeyMsg{Type: tea.KeyEnter}) // set in preview
 
-	// Press Ctrl+E to open execute modal.
+	// Press Ctrl+E and fill the required <msg> to open execute modal.
 	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("wip")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
 
 	v := m.View()
 	// Execute modal should contain the command and options.
@@ -2206,6 +2550,31 @@ func TestExecuteModal_escCancels(t *testing.T) {
 	}
 }
 
+func TestExecuteModal_warnsAboutDeprecated(t *testing.T) {
+	root := &models.Node{Name: "tool", FullPath: []string{"tool"},
+		Flags: []models.Flag{{Name: "--old", Deprecated: true, ReplacedBy: "--new"}, {Name: "--new"}}}
+	legacy := &models.Node{Name: "legacy", FullPath: []string{"tool", "legacy"}, Deprecated: true}
+	root.Children = []*models.Node{legacy}
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	m.Preview().SetCommand("tool legacy --old")
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	v := m.View()
+	for _, want := range []string{"tool legacy is deprecated", "--old is deprecated; use --new instead"} {
+		if !strings.Contains(v, want) {
+			t.Errorf("execute modal should warn %q", want)
+		}
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m.Preview().SetCommand("tool --new")
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	if v := m.View(); strings.Contains(v, "deprecated") {
+		t.Error("execute modal should not warn without deprecated commands or flags")
+	}
+}
+
 // ---------- Integration: End-to-End Command Assembly ----------
 
 func TestWorkflow_navigatePickFlagCopy(t *testing.T) {
@@ -2296,8 +2665,10 @@ func TestWorkflow_navigatePickFlagCopy(t *testing.T) {
 		t.Errorf("preview should contain --message=test, got:\n%s", v)
 	}
 
-	// Step 5: Ctrl+E should open execute modal.
+	// Step 5: Ctrl+E prompts for the required <msg>, then opens execute modal.
 	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("wip")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
 	v = m.View()
 	if !strings.Contains(v, "git commit") {
 		t.Error("execute modal should show the assembled command")
@@ -2874,14 +3245,15 @@ func TestMouse_clickSelectsRow(t *testing.T) {
 	m := tui.NewModel(sampleTree(), cfg)
 	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
 
-	// The tree pane starts after the preview bar (2 lines) + border (1 line).
-	// Row 0 = root "git" (at y = previewBarHeight + 1 = 3).
-	// Row 1 = "commit" subcommand (at y = 4).
+	// The tree pane starts after the preview bar (2 lines), the breadcrumb
+	// bar (1 line) and the border (1 line).
+	// Row 0 = root "git" (at y = 4).
+	// Row 1 = the first section or subcommand (at y = 5).
 	m.Update(tea.MouseMsg{
 		Action: tea.MouseActionPress,
 		Button: tea.MouseButtonLeft,
 		X:      10,
-		Y:      4, // should hit the second row in the tree
+		Y:      5, // should hit the second row in the tree
 	})
 
 	// After a click, cursor should have moved. SelectedItem may be nil
@@ -3467,3 +3839,2378 @@ func max(a, b int) int {
 	}
 	return b
 }
+
+func TestPatchNode_copiesHelpAndPositionals(t *testing.T) {
+	root := sampleTreeWithStub()
+	stub := root.Children[0]
+	tree := tui.NewTreeModel(root, config.DefaultConfig())
+	tree.SetSize(80, 20)
+
+	tree.PatchNode(stub, &models.Node{
+		Name:        "s3",
+		FullPath:    []string{"aws", "s3"},
+		Discovered:  true,
+		HelpText:    "s3 help",
+		Positionals: []models.Positional{{Name: "bucket"}},
+	})
+	if !stub.Discovered || stub.HelpText != "s3 help" || len(stub.Positionals) != 1 {
+		t.Errorf("PatchNode did not copy discovered details: %+v", stub)
+	}
+}
+
+func TestHelpPane_stubShowsNotYetDiscovered(t *testing.T) {
+	h := tui.NewHelpPaneModel(config.DefaultConfig())
+	h.SetNode(sampleTreeWithStub().Children[0])
+	h.SetSize(80, 20)
+	if v := h.View(80, 20); !strings.Contains(v, "Not yet discovered") {
+		t.Errorf("help pane for a stub should say it is not yet discovered, got:\n%s", v)
+	}
+}
+
+func TestModel_SetSizeMatchesWindowSizeMsg(t *testing.T) {
+	a := tui.NewModel(sampleTree(), config.DefaultConfig())
+	a.SetSize(100, 30)
+	b := tui.NewModel(sampleTree(), config.DefaultConfig())
+	b.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	if a.View() != b.View() {
+		t.Error("SetSize should lay out the model exactly like a WindowSizeMsg")
+	}
+}
+
+func TestModel_nonInputMessagesPassThroughModals(t *testing.T) {
+	root := sampleTreeWithStub()
+	stub := root.Children[0]
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("?")}) // open keybinding modal
+
+	m.Update(tui.LazyExpandMsg{Stub: stub, Discovered: &models.Node{
+		Name:     "s3",
+		FullPath: []string{"aws", "s3"},
+		Children: []*models.Node{{Name: "cp", FullPath: []string{"aws", "s3", "cp"}}},
+	}})
+	if stub.Stub || len(stub.Children) != 1 {
+		t.Error("LazyExpandMsg should be applied even while a modal is open")
+	}
+}
+
+func TestModel_oKeyCyclesSortOrder(t *testing.T) {
+	root := &models.Node{
+		Name:     "app",
+		FullPath: []string{"app"},
+		Children: []*models.Node{
+			{Name: "zeta", FullPath: []string{"app", "zeta"}},
+			{Name: "alpha", FullPath: []string{"app", "alpha"}},
+		},
+	}
+	cfg := config.DefaultConfig()
+	m := tui.NewModel(root, cfg)
+	m.SetSize(120, 40)
+	m.Update(tea.KeyMsg{Type: tea.KeyRight}) // expand root
+
+	v := m.TreeModel().View()
+	if strings.Index(v, "zeta") > strings.Index(v, "alpha") {
+		t.Fatal("default order should follow the help output")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")})
+	if cfg.Sort != config.SortName {
+		t.Fatalf("after o, sort = %v, want SortName", cfg.Sort)
+	}
+	v = m.TreeModel().View()
+	if strings.Index(v, "alpha") > strings.Index(v, "zeta") {
+		t.Error("sort by name should list alpha before zeta")
+	}
+	if !strings.Contains(m.View(), "sort: name") {
+		t.Error("status bar should announce the new sort order")
+	}
+}
+
+func TestModel_cKeyTogglesCommandsOnly(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(120, 40)
+
+	v := m.TreeModel().View()
+	if !strings.Contains(v, "Flags (4)") || !strings.Contains(v, "Subcommands (2)") {
+		t.Fatalf("default tree should show sections:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	if !cfg.CommandsOnly {
+		t.Fatal("c should enable commands-only mode")
+	}
+	v = m.TreeModel().View()
+	for _, hidden := range []string{"Flags (", "Subcommands (", "--version"} {
+		if strings.Contains(v, hidden) {
+			t.Errorf("commands-only tree should not contain %q:\n%s", hidden, v)
+		}
+	}
+	for _, shown := range []string{"commit", "remote"} {
+		if !strings.Contains(v, shown) {
+			t.Errorf("commands-only tree should still contain %q", shown)
+		}
+	}
+	if !strings.Contains(m.View(), "commands only: on") {
+		t.Error("status bar should announce commands-only mode")
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	if cfg.CommandsOnly || !strings.Contains(m.TreeModel().View(), "Flags (4)") {
+		t.Error("second c should restore flag and section rows")
+	}
+}
+
+func TestTreeModel_SetCommandsOnly_movesFlagSelectionToOwner(t *testing.T) {
+	tm := newTreeModel(sampleTree())
+	for i := 0; tm.SelectedItem() == nil || tm.SelectedItem().Kind != tui.SelFlag; i++ {
+		if i == tm.RowCount() {
+			t.Fatal("no flag row reachable")
+		}
+		tm.Down()
+	}
+	owner := tm.SelectedItem().Owner
+	tm.SetCommandsOnly(true)
+	if sel := tm.SelectedItem(); sel == nil || sel.Kind != tui.SelCommand || sel.Node != owner {
+		t.Errorf("selection after SetCommandsOnly = %+v, want owner %q", sel, owner.Name)
+	}
+}
+
+func TestModel_pKeyTogglesFullPath(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(120, 40)
+	m.TreeModel().ExpandAll()
+
+	if strings.Contains(m.TreeModel().View(), "git remote add") {
+		t.Fatal("full paths should be off by default")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if !cfg.FullPath {
+		t.Fatal("p should enable full-path mode")
+	}
+	v := m.TreeModel().View()
+	if !strings.Contains(v, "git remote add") || !strings.Contains(v, "git commit") {
+		t.Errorf("full-path tree should show command paths:\n%s", v)
+	}
+	for _, line := range strings.Split(v, "\n") {
+		if strings.Contains(line, "git remote add") && strings.Contains(line, "   git remote add") {
+			t.Errorf("full-path command rows should not be indented: %q", line)
+		}
+	}
+	if !strings.Contains(m.View(), "full paths: on") {
+		t.Error("status bar should announce full-path mode")
+	}
+}
+
+func TestBreadcrumb_showsAncestryAndSection(t *testing.T) {
+	tm := newTreeModel(sampleTree())
+	tm.ExpandAll()
+	for i := 0; ; i++ {
+		if i == tm.RowCount() {
+			t.Fatal("no flag row of commit reachable")
+		}
+		if sel := tm.SelectedItem(); sel != nil && sel.Kind == tui.SelFlag && sel.Owner.Name == "commit" {
+			break
+		}
+		tm.Down()
+	}
+	path, section := tm.Ancestry()
+	var names []string
+	for _, n := range path {
+		names = append(names, n.Name)
+	}
+	if got := strings.Join(names, " "); got != "git commit" || section != "Flags" {
+		t.Errorf("Ancestry() = %q, %q; want \"git commit\", \"Flags\"", got, section)
+	}
+}
+
+func TestBreadcrumb_clickJumpsToAncestor(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(120, 40)
+	m.TreeModel().ExpandAll()
+	for i := 0; m.TreeModel().Selected() == nil || m.TreeModel().Selected().Name != "add"; i++ {
+		if i == m.TreeModel().RowCount() {
+			t.Fatal("row for 'git remote add' not reachable")
+		}
+		m.TreeModel().Down()
+	}
+	if !strings.Contains(m.View(), "git ▸ remote ▸ add") {
+		t.Fatalf("breadcrumb should show the ancestry of add:\n%s", m.View())
+	}
+
+	// The breadcrumb bar is the line right below the 2-line preview bar;
+	// "remote" starts after "git ▸ ".
+	m.Update(tea.MouseMsg{Action: tea.MouseActionPress, Button: tea.MouseButtonLeft, X: 7, Y: 2})
+	if sel := m.TreeModel().Selected(); sel == nil || sel.Name != "remote" {
+		t.Errorf("clicking 'remote' in the breadcrumb should select it, got %v", sel)
+	}
+}
+
+func TestBreadcrumb_showsCLIHeader(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetCLIInfo(tui.CLIInfo{Path: "/usr/bin/git", Version: "git version 2.43.0"})
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	crumb := strings.Split(m.View(), "\n")[2]
+	if !strings.HasPrefix(crumb, "git") || !strings.HasSuffix(strings.TrimRight(crumb, " "), "/usr/bin/git · git version 2.43.0") {
+		t.Errorf("breadcrumb bar should end with the path and version: %q", crumb)
+	}
+	if strings.Contains(crumb, "⚠") {
+		t.Errorf("a fresh tree should not warn: %q", crumb)
+	}
+
+	m = tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetCLIInfo(tui.CLIInfo{Path: "/usr/bin/git", Version: "git version 2.42.0", Stale: true})
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	v := m.View()
+	if crumb := strings.Split(v, "\n")[2]; !strings.Contains(crumb, "⚠ stale cache") {
+		t.Errorf("a stale tree should be flagged in the breadcrumb bar: %q", crumb)
+	}
+	if !strings.Contains(v, "git has changed since this tree was cached") {
+		t.Errorf("a stale tree should be reported in the status bar:\n%s", v)
+	}
+
+	// In a narrow terminal the ancestry is elided before the header.
+	m.SetSize(60, 40)
+	if crumb := strings.TrimRight(strings.Split(m.View(), "\n")[2], " "); lipgloss.Width(crumb) > 60 || !strings.Contains(crumb, "⚠ stale cache") {
+		t.Errorf("narrow breadcrumb bar = %q", crumb)
+	}
+}
+
+func TestModel_ctrlRRediscoversTheTree(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if v := m.View(); !strings.Contains(v, "cannot be rediscovered") {
+		t.Errorf("Ctrl+R without a reloader should say it cannot rediscover:\n%s", v)
+	}
+
+	m = tui.NewModel(sampleTree(), config.DefaultConfig())
+	calls := 0
+	m.SetReloader(func(ctx context.Context) (*models.Node, tui.CLIInfo, error) {
+		calls++
+		root := sampleTree()
+		root.Children = append(root.Children, &models.Node{Name: "status", FullPath: []string{"git", "status"}})
+		return root, tui.CLIInfo{Path: "/usr/bin/git", Version: "git version 2.44.0"}, nil
+	})
+	m.SetCLIInfo(tui.CLIInfo{Path: "/usr/bin/git", Version: "git version 2.43.0", Stale: true})
+	m.SetSize(120, 40)
+	if v := m.View(); !strings.Contains(v, "Ctrl+R refresh") || !strings.Contains(v, "Ctrl+R rediscovers it") {
+		t.Errorf("a stale tree should offer Ctrl+R:\n%s", v)
+	}
+	navigateTo(m, func(s *tui.Selection) bool { return s.Node != nil && s.Node.Name == "remote" })
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if crumb := strings.Split(m.View(), "\n")[2]; !strings.Contains(crumb, "rediscovering…") {
+		t.Errorf("the header should show the rediscovery running: %q", crumb)
+	}
+	_, again := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if v := m.View(); !strings.Contains(v, "already rediscovering") {
+		t.Errorf("Ctrl+R should not start a second rediscovery while one runs:\n%s", v)
+	}
+	pump(m, cmd, 2)
+	pump(m, again, 2)
+	if calls != 1 {
+		t.Errorf("reloader called %d times, want 1", calls)
+	}
+
+	v := m.View()
+	if !strings.Contains(v, "status") {
+		t.Errorf("the rediscovered tree should be shown:\n%s", v)
+	}
+	if strings.Contains(v, "⚠") || !strings.Contains(v, "git version 2.44.0") {
+		t.Errorf("the header should describe the rediscovered CLI:\n%s", v)
+	}
+	if sel := m.TreeModel().Selected(); sel == nil || sel.Name != "remote" {
+		t.Errorf("selection should be kept across the swap, got %v", sel)
+	}
+}
+
+// treePaneWidth returns the rendered width of the tree pane.
+func treePaneWidth(m *tui.Model) int {
+	return lipgloss.Width(strings.Split(m.TreeModel().View(), "\n")[0])
+}
+
+func TestModel_angleKeysResizePanes(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(100, 40)
+
+	if w := treePaneWidth(m); w != 55 {
+		t.Fatalf("default tree width = %d, want 55", w)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(">")})
+	if cfg.PaneRatio != 60 || treePaneWidth(m) != 60 {
+		t.Errorf("> should widen the tree to 60%%, got ratio %d width %d", cfg.PaneRatio, treePaneWidth(m))
+	}
+	if !strings.Contains(m.View(), "split: 60/40") {
+		t.Error("status bar should announce the new split")
+	}
+	for i := 0; i < 20; i++ {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("<")})
+	}
+	if cfg.PaneRatio != 20 {
+		t.Errorf("ratio should clamp at 20, got %d", cfg.PaneRatio)
+	}
+	if w := treePaneWidth(m); w != 30 {
+		t.Errorf("tree pane should keep a minimum width of 30, got %d", w)
+	}
+}
+
+func TestModel_zKeyZoomsFocusedPane(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(100, 40)
+	if !strings.Contains(m.View(), "▼ git") {
+		t.Fatal("tree should be visible before zooming")
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("z")})
+	if w := treePaneWidth(m); w != 100 {
+		t.Errorf("zoomed tree should fill the width, got %d", w)
+	}
+	// Focusing the help pane while zoomed shows only the help pane.
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if !strings.Contains(m.View(), "focus: help") {
+		t.Fatal("expected help pane focus")
+	}
+	if strings.Contains(m.View(), "▼ git") {
+		t.Error("zoomed help pane should hide the tree")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("z")})
+	if w := treePaneWidth(m); w != 55 {
+		t.Errorf("unzoom should restore the split, got width %d", w)
+	}
+}
+
+func TestMouse_dragDividerResizesPanes(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(100, 40)
+
+	m.Update(tea.MouseMsg{Action: tea.MouseActionPress, Button: tea.MouseButtonLeft, X: 55, Y: 10})
+	m.Update(tea.MouseMsg{Action: tea.MouseActionMotion, Button: tea.MouseButtonLeft, X: 40, Y: 10})
+	m.Update(tea.MouseMsg{Action: tea.MouseActionRelease, Button: tea.MouseButtonLeft, X: 40, Y: 10})
+	if cfg.PaneRatio != 40 || treePaneWidth(m) != 40 {
+		t.Errorf("drag should move the divider to 40%%, got ratio %d width %d", cfg.PaneRatio, treePaneWidth(m))
+	}
+	// Motion after release no longer resizes.
+	m.Update(tea.MouseMsg{Action: tea.MouseActionMotion, X: 70, Y: 10})
+	if cfg.PaneRatio != 40 {
+		t.Errorf("motion after release should not resize, got ratio %d", cfg.PaneRatio)
+	}
+}
+
+func TestTreeModel_longRowsAreClippedAndScroll(t *testing.T) {
+	root := &models.Node{
+		Name:     "tool",
+		FullPath: []string{"tool"},
+		Children: []*models.Node{{
+			Name:     "deploy",
+			FullPath: []string{"tool", "deploy"},
+			Flags: []models.Flag{
+				{Name: "--environment-name", ValueType: "string"},
+				{Name: "--replica-count", ValueType: "int"},
+				{Name: "--dry-run", ValueType: "bool"},
+			},
+		}},
+	}
+	tm := newTreeModel(root)
+	tm.SetSize(30, 10)
+
+	assertFits := func(v string) {
+		t.Helper()
+		lines := strings.Split(v, "\n")
+		if len(lines) != 10 {
+			t.Fatalf("long rows should not wrap: got %d lines, want 10:\n%s", len(lines), v)
+		}
+		for _, line := range lines {
+			if w := lipgloss.Width(line); w > 30 {
+				t.Errorf("line wider than pane (%d): %q", w, line)
+			}
+		}
+	}
+
+	v := tm.View()
+	assertFits(v)
+	if !strings.Contains(v, "…") {
+		t.Errorf("clipped row should end with an ellipsis:\n%s", v)
+	}
+
+	tm.ScrollRight(24)
+	v = tm.View()
+	assertFits(v)
+	if tm.HOffset() != 24 {
+		t.Errorf("HOffset = %d, want 24", tm.HOffset())
+	}
+	if !strings.Contains(v, "-name=<string>,--replica") {
+		t.Errorf("scrolling right should reveal later flags:\n%s", v)
+	}
+
+	tm.ScrollRight(1000)
+	tm.View()
+	if off := tm.HOffset(); off == 0 || off >= 1000 {
+		t.Errorf("HOffset should clamp to the widest row, got %d", off)
+	}
+	tm.ScrollLeft(1000)
+	if tm.HOffset() != 0 {
+		t.Errorf("HOffset = %d after scrolling fully left, want 0", tm.HOffset())
+	}
+}
+
+// largeTree returns a CLI of groups×cmds commands, each with flags flags.
+func largeTree(groups, cmds, flags int) *models.Node {
+	root := &models.Node{Name: "cloud", FullPath: []string{"cloud"}}
+	for i := range groups {
+		g := &models.Node{Name: fmt.Sprintf("group%d", i), FullPath: []string{"cloud", fmt.Sprintf("group%d", i)}}
+		for j := range cmds {
+			c := &models.Node{
+				Name:        fmt.Sprintf("cmd%d", j),
+				FullPath:    []string{"cloud", g.Name, fmt.Sprintf("cmd%d", j)},
+				Description: "manage resources",
+			}
+			for k := range flags {
+				c.Flags = append(c.Flags, models.Flag{Name: fmt.Sprintf("--opt-%d", k), ValueType: "string"})
+			}
+			g.Children = append(g.Children, c)
+		}
+		root.Children = append(root.Children, g)
+	}
+	return root
+}
+
+func TestTreeModel_cachedRowsMatchAFreshRender(t *testing.T) {
+	steps := []func(tm *tui.TreeModel){
+		func(tm *tui.TreeModel) { tm.ExpandAll() },
+		func(tm *tui.TreeModel) { tm.MoveBy(2) },
+		func(tm *tui.TreeModel) { tm.ToggleExpand() }, // group0, rendered expanded before
+		func(tm *tui.TreeModel) { tm.MoveBy(1) },
+		func(tm *tui.TreeModel) { tm.MoveBy(40) },
+		func(tm *tui.TreeModel) { tm.PageDown() },
+		func(tm *tui.TreeModel) { tm.SetCmdTokens([]string{"cloud", "group0", "cmd1", "--opt-2"}) },
+		func(tm *tui.TreeModel) { tm.MoveBy(-25) },
+		func(tm *tui.TreeModel) { tm.SetFullPath(true) },
+		func(tm *tui.TreeModel) { tm.SetDisplayStyle(config.StyleGraph) },
+		func(tm *tui.TreeModel) { tm.SetCmdTokens(nil) },
+		func(tm *tui.TreeModel) { tm.SetSize(50, 16) },
+		func(tm *tui.TreeModel) { tm.CollapseAll() },
+	}
+	// cached renders after every step; fresh renders once, after the
+	// steps so far, so none of its rows come from an earlier render.
+	root := largeTree(20, 30, 6)
+	cached := tui.NewTreeModel(root, config.DefaultConfig())
+	cached.SetSize(70, 20)
+	cached.View()
+	for i := range steps {
+		steps[i](cached)
+		fresh := tui.NewTreeModel(root, config.DefaultConfig())
+		fresh.SetSize(70, 20)
+		for _, step := range steps[:i+1] {
+			step(fresh)
+		}
+		if got, want := cached.View(), fresh.View(); got != want {
+			t.Fatalf("after step %d the view differs from a fresh render:\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+}
+
+func TestTreeModel_largeTreeNavigation(t *testing.T) {
+	tm := tui.NewTreeModel(largeTree(100, 100, 4), config.DefaultConfig())
+	tm.SetSize(80, 30)
+	tm.ExpandAll()
+	if n := tm.RowCount(); n < 40000 {
+		t.Fatalf("RowCount = %d, want a tree of over 40000 rows", n)
+	}
+	tm.Bottom()
+	if v := tm.View(); !strings.Contains(v, "--opt-3") {
+		t.Errorf("the bottom of the tree should show the last command's flags:\n%s", v)
+	}
+	tm.Top()
+	tm.MoveBy(2) // past the Subcommands header
+	if sel := tm.Selected(); sel == nil || sel.Name != "group0" {
+		t.Errorf("selected = %v, want group0", sel)
+	}
+	if v := tm.View(); strings.Count(v, "\n") != 29 {
+		t.Errorf("view should be 30 lines:\n%s", v)
+	}
+}
+
+func TestModel_bracketKeysScrollTree(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(60, 20)
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("]")})
+	if m.TreeModel().HOffset() == 0 {
+		t.Fatal("] should scroll the tree right")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("[")})
+	if m.TreeModel().HOffset() != 0 {
+		t.Errorf("[ should scroll back, HOffset = %d", m.TreeModel().HOffset())
+	}
+}
+
+func TestModel_mKeyOpensRawHelpPager(t *testing.T) {
+	root := sampleTree()
+	var help strings.Builder
+	help.WriteString("usage: git [--version] <command>\n\nOptions:\n")
+	for i := 0; i < 60; i++ {
+		fmt.Fprintf(&help, "  --option-%02d\tdescription %d\n", i, i)
+	}
+	help.WriteString("\nSee https://git-scm.com/docs for more.\n")
+	root.HelpText = help.String()
+
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.SetSize(100, 30)
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m")})
+
+	v := m.View()
+	for _, want := range []string{"Raw help: git", "usage: git [--version]", "Options:", "--option-00"} {
+		if !strings.Contains(v, want) {
+			t.Errorf("pager should show %q:\n%s", want, v)
+		}
+	}
+	if strings.Contains(v, "https://git-scm.com/docs") {
+		t.Error("the end of the help text should not be visible before scrolling")
+	}
+
+	// Keys scroll the pager instead of acting on the tree.
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("G")})
+	v = m.View()
+	if !strings.Contains(v, "https://git-scm.com/docs") || strings.Contains(v, "--option-00") {
+		t.Errorf("G should scroll to the end of the help text:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("g")})
+	if !strings.Contains(m.View(), "--option-00") {
+		t.Error("g should scroll back to the top")
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if strings.Contains(m.View(), "Raw help: git") {
+		t.Error("Esc should close the pager")
+	}
+}
+
+func TestModel_mKeyWithoutHelpText(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(100, 30)
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m")})
+	v := m.View()
+	if strings.Contains(v, "Raw help: git") || !strings.Contains(v, "no raw help for git") {
+		t.Errorf("m without help text should only set a status message:\n%s", v)
+	}
+}
+
+func TestModel_statusMessagePersistsAcrossRenders(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	for i := 0; i < 3; i++ {
+		if !strings.Contains(m.View(), "commands only: on") {
+			t.Fatalf("status message should survive render %d", i+1)
+		}
+	}
+}
+
+func TestModel_statusMessageExpires(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.StatusMsgTimeout = time.Millisecond
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(120, 40)
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	if cmd == nil {
+		t.Fatal("posting a status message should schedule its expiry")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if strings.Contains(m.View(), "commands only: on") {
+		t.Error("expired status message should no longer be shown")
+	}
+	if got := m.Messages(); len(got) != 1 || got[0] != "commands only: on" {
+		t.Errorf("Messages() = %q, want the expired message kept in history", got)
+	}
+}
+
+func TestModel_colonMessagesShowsHistory(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(":")})
+	for _, r := range "messages" {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	if !strings.Contains(m.View(), ":messages") {
+		t.Error("command line should echo the typed command")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	v := m.View()
+	if !strings.Contains(v, "Messages") || !strings.Contains(v, "commands only: on") || !strings.Contains(v, "full paths: on") {
+		t.Errorf(":messages should list earlier status messages:\n%s", v)
+	}
+	if !regexp.MustCompile(`\d\d:\d\d:\d\d`).MatchString(v) {
+		t.Error("messages should be timestamped")
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if strings.Contains(m.View(), "↑↓/jk scroll · Esc close") {
+		t.Error("Esc should close the messages overlay")
+	}
+}
+
+func TestModel_colonUnknownCommand(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(":")})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("bogus")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if !strings.Contains(m.View(), "unknown command: bogus") {
+		t.Error("unknown : command should report an error")
+	}
+}
+
+// memStateStore is an in-memory tui.StateStore.
+type memStateStore struct{ data []byte }
+
+func (s *memStateStore) LoadState() ([]byte, bool) { return s.data, s.data != nil }
+func (s *memStateStore) SaveState(data []byte)     { s.data = data }
+
+func TestModel_stateStoreRestoresExpansionAndSelection(t *testing.T) {
+	store := &memStateStore{}
+	root := sampleTree()
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.SetStateStore(store)
+	m.SetSize(120, 40)
+	m.TreeModel().ExpandAll()
+	add := root.FindPath([]string{"remote", "add"})
+	if add == nil || !m.TreeModel().SelectNode(add) {
+		t.Fatal("sample tree should contain a visible git remote add")
+	}
+	rows := m.TreeModel().RowCount()
+	m.SaveState()
+	if store.data == nil {
+		t.Fatal("SaveState should write to the store")
+	}
+
+	// A new session over a freshly discovered tree restores the view.
+	m2 := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m2.SetStateStore(store)
+	m2.SetSize(120, 40)
+	if got := m2.TreeModel().RowCount(); got != rows {
+		t.Errorf("restored RowCount = %d, want %d", got, rows)
+	}
+	if sel := m2.TreeModel().Selected(); sel == nil || sel.FullCommand() != "git remote add" {
+		t.Errorf("restored selection = %v, want git remote add", sel)
+	}
+	if !strings.Contains(m2.View(), "git ▸ remote ▸ add") {
+		t.Error("breadcrumb should reflect the restored selection")
+	}
+}
+
+func TestModel_stateStoreIgnoresOtherCLIAndBadData(t *testing.T) {
+	store := &memStateStore{data: []byte(`{"expanded":["kubectl/get@1"],"selected":["kubectl","get"]}`)}
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetStateStore(store)
+	if sel := m.TreeModel().Selected(); sel == nil || sel.Name != "git" {
+		t.Errorf("state of another CLI should not move the selection, got %v", sel)
+	}
+
+	store.data = []byte("not json")
+	m = tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetStateStore(store)
+	if m.TreeModel().RowCount() == 0 {
+		t.Error("corrupt state should leave the default view")
+	}
+}
+
+func TestFlagModal_multiSelectAddsMarkedFlags(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	if !navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelCommand && s.Node.Name == "commit"
+	}) {
+		t.Fatal("could not navigate to commit")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+
+	// Mark --message and --amend, skipping --all.
+	m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	if v := m.View(); !strings.Contains(v, "2 marked") {
+		t.Errorf("title should count marked flags:\n%s", v)
+	}
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git" {
+		t.Fatalf("Space should only mark, got preview %q", got)
+	}
+
+	// Enter prompts for --message's value first, then adds --amend.
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if v := m.View(); !strings.Contains(v, "Value for --message") || !strings.Contains(v, "1 more") {
+		t.Fatalf("expected value prompt for --message with one flag pending:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("hi")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	got := strings.Join(m.Preview().Tokens(), " ")
+	if got != "git commit --message=hi --amend" {
+		t.Errorf("preview = %q, want %q", got, "git commit --message=hi --amend")
+	}
+}
+
+func TestFlagModal_escDuringBatchDropsRemainingFlags(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelCommand && s.Node.Name == "commit"
+	})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git" {
+		t.Errorf("Esc at the value prompt should cancel the batch, got preview %q", got)
+	}
+}
+
+func TestFlagModal_typingFiltersEntries(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelCommand && s.Node.Name == "commit"
+	})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	for _, r := range "amen" {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	v := modalBox(m.View(), "Add Flag")
+	if !strings.Contains(v, "Filter: amen") || !strings.Contains(v, "--amend") {
+		t.Fatalf("expected filter line and --amend in view:\n%s", v)
+	}
+	if strings.Contains(v, "--message") {
+		t.Errorf("--message should be filtered out:\n%s", v)
+	}
+
+	// Enter adds the only match; letters never close the modal.
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git commit --amend" {
+		t.Errorf("preview = %q, want %q", got, "git commit --amend")
+	}
+}
+
+func TestFlagModal_escClearsFilterBeforeClosing(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelCommand && s.Node.Name == "commit"
+	})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("zzz")})
+	if v := m.View(); !strings.Contains(v, "no flags match") {
+		t.Fatalf("expected empty-match hint:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter}) // nothing to add
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	v := m.View()
+	if !strings.Contains(v, "Add Flag") || !strings.Contains(v, "--message") {
+		t.Fatalf("first Esc should clear the filter and keep the modal open:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if strings.Contains(m.View(), "Add Flag") {
+		t.Error("second Esc should close the modal")
+	}
+}
+
+// slotTree returns a tree whose "remote add" takes two required positionals
+// and an optional variadic one, with a value flag in between.
+func slotTree() *models.Node {
+	add := &models.Node{
+		Name: "add", FullPath: []string{"git", "remote", "add"},
+		Flags: []models.Flag{{Name: "--track", ShortName: "t", ValueType: "string"}},
+		Positionals: []models.Positional{
+			{Name: "name", Required: true},
+			{Name: "url", Required: true},
+			{Name: "extra", Variadic: true},
+		},
+	}
+	remote := &models.Node{Name: "remote", FullPath: []string{"git", "remote"}, Children: []*models.Node{add}}
+	return &models.Node{Name: "git", FullPath: []string{"git"}, Children: []*models.Node{remote}}
+}
+
+func TestPreview_showsUnfilledPositionalSlots(t *testing.T) {
+	m := tui.NewModel(slotTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelCommand && s.Node.Name == "add"
+	})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	bar := strings.Split(m.View(), "\n")[0]
+	for _, want := range []string{"git remote add", "<name>", "<url>", "[extra...]"} {
+		if !strings.Contains(bar, want) {
+			t.Errorf("preview bar missing %q: %q", want, bar)
+		}
+	}
+
+	// A flag's separate value does not fill a slot; the next word does.
+	m.Preview().SetCommand("git remote add -t main origin")
+	bar = strings.Split(m.View(), "\n")[0]
+	if strings.Contains(bar, "<name>") || !strings.Contains(bar, "<url>") {
+		t.Errorf("origin should fill <name> only: %q", bar)
+	}
+}
+
+func TestPreview_treeRootedAtSubcommand(t *testing.T) {
+	remote := slotTree().Find("remote")
+	m := tui.NewModel(remote, config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	if !strings.Contains(m.View(), "git remote") {
+		t.Error("the root row should show the full command git remote")
+	}
+	navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelCommand && s.Node.Name == "add"
+	})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	bar := strings.Split(m.View(), "\n")[0]
+	for _, want := range []string{"git remote add", "<name>", "<url>"} {
+		if !strings.Contains(bar, want) {
+			t.Errorf("preview bar missing %q: %q", want, bar)
+		}
+	}
+}
+
+func TestPreview_alternativePositionalsShareASlot(t *testing.T) {
+	root := &models.Node{Name: "tool", FullPath: []string{"tool"}}
+	root.Children = []*models.Node{{Name: "fetch", FullPath: []string{"tool", "fetch"}, Positionals: []models.Positional{
+		{Name: "url", Required: true, Group: 1},
+		{Name: "file", Required: true, Group: 1},
+		{Name: "dest"},
+	}}}
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	m.Preview().SetCommand("tool fetch")
+	if bar := strings.Split(m.View(), "\n")[0]; !strings.Contains(bar, "<url|file>") || !strings.Contains(bar, "[dest]") {
+		t.Errorf("preview bar should show <url|file> [dest]: %q", bar)
+	}
+	m.Preview().SetCommand("tool fetch ./x")
+	if bar := strings.Split(m.View(), "\n")[0]; strings.Contains(bar, "<url|file>") || !strings.Contains(bar, "[dest]") {
+		t.Errorf("one argument should fill the <url|file> slot: %q", bar)
+	}
+}
+
+func TestModel_ctrlEPromptsForMissingPositionalsInOrder(t *testing.T) {
+	m := tui.NewModel(slotTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelCommand && s.Node.Name == "add"
+	})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	if v := m.View(); !strings.Contains(v, "<name>") || strings.Contains(v, "Execute Command") {
+		t.Fatalf("Ctrl+E should prompt for <name> first:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter}) // empty value is not accepted
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("origin")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if v := m.View(); !strings.Contains(v, "<url>") || strings.Contains(v, "Execute Command") {
+		t.Fatalf("expected prompt for <url> next:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("https://example.com/r.git")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	v := m.View()
+	if !strings.Contains(v, "Execute Command") || !strings.Contains(v, "git remote add origin https://example.com/r.git") {
+		t.Errorf("expected execute modal once required slots are filled:\n%s", v)
+	}
+}
+
+func TestModel_ctrlECancelledPromptReportsMissing(t *testing.T) {
+	m := tui.NewModel(slotTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m.Preview().SetCommand("git remote add origin")
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	msgs := m.Messages()
+	if len(msgs) == 0 || msgs[len(msgs)-1] != "cancelled: <url>" {
+		t.Errorf("messages = %v, want last to be %q", msgs, "cancelled: <url>")
+	}
+	if strings.Contains(m.View(), "Execute Command") {
+		t.Error("execute modal must not open with a required positional missing")
+	}
+}
+
+func TestModel_positionalRowPromptsEarlierSlotFirst(t *testing.T) {
+	m := tui.NewModel(slotTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	if !navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelPositional && s.Positional.Name == "url"
+	}) {
+		t.Fatal("could not navigate to <url>")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	msgs := m.Messages()
+	if len(msgs) == 0 || msgs[len(msgs)-1] != "fill <name> first" {
+		t.Errorf("messages = %v, want last to be %q", msgs, "fill <name> first")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("origin")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git remote add origin" {
+		t.Errorf("preview = %q, want %q", got, "git remote add origin")
+	}
+}
+
+// repeatTree returns a tree whose commit has a repeatable value flag and a
+// count flag.
+func repeatTree() *models.Node {
+	commit := &models.Node{
+		Name: "commit", FullPath: []string{"git", "commit"},
+		Flags: []models.Flag{
+			{Name: "--trailer", ValueType: "stringArray", Repeatable: true},
+			{Name: "--verbose", ShortName: "v", ValueType: "count", Repeatable: true},
+			{Name: "--amend", ValueType: "bool"},
+		},
+	}
+	return &models.Node{Name: "git", FullPath: []string{"git"}, Children: []*models.Node{commit}}
+}
+
+func TestFlagModal_repeatableFlagCanBeAddedAgain(t *testing.T) {
+	m := tui.NewModel(repeatTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelCommand && s.Node.Name == "commit"
+	})
+	for _, val := range []string{"a", "b"} {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("trailer")})
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		if v := m.View(); !strings.Contains(v, "Value for --trailer") {
+			t.Fatalf("expected value prompt for --trailer:\n%s", v)
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(val)})
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc}) // clear filter
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc}) // close
+	}
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git commit --trailer=a --trailer=b" {
+		t.Errorf("preview = %q, want %q", got, "git commit --trailer=a --trailer=b")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	if v := m.View(); !strings.Contains(v, "--trailer <stringArray> ×2") {
+		t.Errorf("flag modal should show the repeat count:\n%s", v)
+	}
+}
+
+func TestModel_countFlagRowAddsRepeatedly(t *testing.T) {
+	m := tui.NewModel(repeatTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	for _, name := range []string{"--verbose", "--verbose", "--amend", "--amend"} {
+		if !navigateTo(m, func(s *tui.Selection) bool {
+			return s.Kind == tui.SelFlag && s.Flag.Name == name
+		}) {
+			t.Fatalf("could not navigate to %s", name)
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+	// Count flags add without a value prompt; bool flags only once.
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git commit --verbose --verbose --amend" {
+		t.Errorf("preview = %q, want %q", got, "git commit --verbose --verbose --amend")
+	}
+}
+
+type memHistory struct{ values map[string][]string }
+
+func (h *memHistory) FlagValues(flag string) []string { return h.values[flag] }
+func (h *memHistory) AddFlagValue(flag, value string) {
+	h.values[flag] = append([]string{value}, h.values[flag]...)
+}
+
+func TestValueModal_suggestsRememberedValues(t *testing.T) {
+	h := &memHistory{values: map[string][]string{"--message": {"release", "wip"}}}
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetValueHistory(h)
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	if !navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelFlag && s.Flag.Name == "--message"
+	}) {
+		t.Fatal("could not navigate to --message")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	v := m.View()
+	if !strings.Contains(v, "suggestions") || !strings.Contains(v, "release") || !strings.Contains(v, "wip") {
+		t.Fatalf("value modal should list remembered values:\n%s", v)
+	}
+
+	// Typing narrows the list; Tab fills the input with the match.
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w")})
+	if v := m.View(); strings.Contains(v, "release") {
+		t.Errorf("typing w should hide release:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git commit --message=wip" {
+		t.Errorf("preview = %q, want %q", got, "git commit --message=wip")
+	}
+	if got := h.values["--message"][0]; got != "wip" {
+		t.Errorf("confirmed value should be remembered, history = %v", h.values["--message"])
+	}
+}
+
+func TestFlagModal_valuePromptPicksSuggestionWithArrow(t *testing.T) {
+	h := &memHistory{values: map[string][]string{"--message": {"release"}}}
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetValueHistory(h)
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelCommand && s.Node.Name == "commit"
+	})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("message")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if v := m.View(); !strings.Contains(v, "release") {
+		t.Fatalf("flag modal value prompt should suggest release:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git commit --message=release" {
+		t.Errorf("preview = %q, want %q", got, "git commit --message=release")
+	}
+}
+
+// pump runs cmd and feeds the messages it produces back into m, following
+// up to depth rounds of returned commands. Commands that take longer than
+// a moment (cursor blink, status expiry) are abandoned.
+func pump(m *tui.Model, cmd tea.Cmd, depth int) {
+	if cmd == nil || depth == 0 {
+		return
+	}
+	ch := make(chan tea.Msg, 1)
+	go func() { ch <- cmd() }()
+	select {
+	case msg := <-ch:
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for _, c := range batch {
+				pump(m, c, depth)
+			}
+			return
+		}
+		if msg != nil {
+			_, next := m.Update(msg)
+			pump(m, next, depth-1)
+		}
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+type fakeCompleter struct {
+	values     []string
+	args       []string
+	toComplete string
+	name       string
+}
+
+func (c *fakeCompleter) CompleteValues(_ context.Context, args []string, toComplete, name string) ([]string, error) {
+	c.args, c.toComplete, c.name = args, toComplete, name
+	var out []string
+	for _, v := range c.values {
+		if strings.HasPrefix(v, toComplete) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+func TestValueModal_liveCompletionSuggestsValues(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.StatusMsgTimeout = time.Minute
+	fc := &fakeCompleter{values: []string{"main", "develop"}}
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetValueCompleter(fc)
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	if !navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelFlag && s.Flag.Name == "--message"
+	}) {
+		t.Fatal("could not navigate to --message")
+	}
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	pump(m, cmd, 4)
+	if got := strings.Join(fc.args, " "); got != "commit --message" || fc.name != "--message" {
+		t.Errorf("completer args = %q name %q, want %q name %q", got, fc.name, "commit --message", "--message")
+	}
+	if v := m.View(); !strings.Contains(v, "main") || !strings.Contains(v, "develop") {
+		t.Fatalf("value modal should list completed values:\n%s", v)
+	}
+
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	pump(m, cmd, 4)
+	if fc.toComplete != "d" {
+		t.Errorf("completer toComplete = %q, want %q", fc.toComplete, "d")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git commit --message=develop" {
+		t.Errorf("preview = %q, want %q", got, "git commit --message=develop")
+	}
+}
+
+func TestModel_OfflineDoesNotDiscover(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Offline = true
+	m := tui.NewModel(sampleTreeWithStub(), cfg)
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m.Update(tea.KeyMsg{Type: tea.KeyRight}) // select the stub, which would discover it
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	want := []string{"offline: s3 was not discovered", "offline: cannot re-discover s3"}
+	if msgs := m.Messages(); !slices.Equal(msgs, want) {
+		t.Errorf("messages = %q, want %q", msgs, want)
+	}
+}
+
+func TestFlagSections_groupedInTree(t *testing.T) {
+	tree := &models.Node{
+		Name:     "godot",
+		FullPath: []string{"godot"},
+		Flags: []models.Flag{
+			{Name: "--help", ValueType: "bool"},
+			{Name: "--editor", ValueType: "bool"},
+			{Name: "--debug", ValueType: "bool"},
+		},
+		FlagSections: []models.FlagSection{
+			{Name: "Run options", Flags: []string{"--help", "--editor"}},
+			{Name: "Debug options", Flags: []string{"--debug"}},
+		},
+	}
+	m := tui.NewModel(tree, config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	v := m.View()
+	for _, want := range []string{"Run options (2)", "Debug options (1)", "--editor", "--debug"} {
+		if !strings.Contains(v, want) {
+			t.Errorf("view should show %q:\n%s", want, v)
+		}
+	}
+	if strings.Contains(v, "Flags (3)") {
+		t.Errorf("grouped flags should not also be listed under Flags:\n%s", v)
+	}
+}
+
+// tarTree returns a tar whose short flags are given clustered, as in
+// "tar -xvf archive.tar".
+func tarTree() *models.Node {
+	return &models.Node{
+		Name: "tar", FullPath: []string{"tar"},
+		Flags: []models.Flag{
+			{Name: "--extract", ShortName: "x", ValueType: "bool"},
+			{Name: "--verbose", ShortName: "v", ValueType: "bool"},
+			{Name: "--file", ShortName: "f", ValueType: "string"},
+			{Name: "--directory", ShortName: "C", ValueType: "string"},
+		},
+		Positionals: []models.Positional{{Name: "member", Variadic: true}},
+	}
+}
+
+func TestModel_shortFlagClusters(t *testing.T) {
+	for _, cmd := range []string{"tar -xvf archive.tar", "tar -xvfarchive.tar", "tar -x -v -Cout -f archive.tar"} {
+		m := tui.NewModel(tarTree(), config.DefaultConfig())
+		m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+		m.Preview().SetCommand(cmd)
+
+		// -v is already set inside the cluster, so it is not added again.
+		if !navigateTo(m, func(s *tui.Selection) bool {
+			return s.Kind == tui.SelFlag && s.Flag.Name == "--verbose"
+		}) {
+			t.Fatal("could not navigate to --verbose")
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		if got := strings.Join(m.Preview().Tokens(), " "); got != cmd {
+			t.Errorf("%s: preview = %q, --verbose should not be added again", cmd, got)
+		}
+
+		// The file name is -f's value, not a member.
+		if bar := strings.Split(m.View(), "\n")[0]; !strings.Contains(bar, "[member...]") {
+			t.Errorf("%s: archive.tar should not fill [member...]: %q", cmd, bar)
+		}
+	}
+}
+
+func TestFlagModal_marksFlagsSetInCluster(t *testing.T) {
+	m := tui.NewModel(tarTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m.Preview().SetCommand("tar -xvfarchive.tar")
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	v := m.View()
+	for _, name := range []string{"--extract", "--verbose", "--file"} {
+		if !regexp.MustCompile(`✓\s*` + name).MatchString(v) {
+			t.Errorf("flag modal should mark %s added:\n%s", name, v)
+		}
+	}
+	if regexp.MustCompile(`✓\s*--directory`).MatchString(v) {
+		t.Errorf("--directory is not in the command:\n%s", v)
+	}
+}
+
+func TestPreview_readsWordsLikeAShell(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	for _, tc := range []struct {
+		cmd    string
+		filled bool // whether <msg> is filled
+	}{
+		{`git commit -m "fix the bug"`, false}, // the quoted value is -m's, all of it
+		{"git commit -a wip", true},            // -a takes no value
+		{"git commit -- --amend", true},        // after --, words are arguments
+		{"git commit | xargs echo", false},     // the words after a pipe are another command's
+		{"git commit $(cat msg.txt) | cat", true},
+	} {
+		m.Preview().SetCommand(tc.cmd)
+		bar := strings.Split(m.View(), "\n")[0]
+		if got := strings.Contains(bar, "<msg>"); got == tc.filled {
+			t.Errorf("%s: <msg> shown = %v, want %v: %q", tc.cmd, got, !tc.filled, bar)
+		}
+	}
+
+	m.Preview().SetCommand(`git commit -m "fix the bug" && echo $(git rev-parse HEAD)`)
+	want := []string{"git", "commit", "-m", `"fix the bug"`, "&&", "echo", "$(git rev-parse HEAD)"}
+	if got := m.Preview().Tokens(); !slices.Equal(got, want) {
+		t.Errorf("Tokens() = %q, want %q", got, want)
+	}
+	m.Preview().SetCommand(`git commit -m "fix the bug"`)
+	m.Preview().RemoveLastToken()
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git commit -m" {
+		t.Errorf("after RemoveLastToken = %q, want the quoted value removed whole", got)
+	}
+
+	// --amend given after -- is an argument, not the flag.
+	navigateTo(m, func(s *tui.Selection) bool { return s.Kind == tui.SelCommand && s.Node.Name == "commit" })
+	for cmd, added := range map[string]bool{"git commit --amend": true, "git commit -- --amend": false} {
+		m.Preview().SetCommand(cmd)
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+		if got := regexp.MustCompile(`✓\s*--amend`).MatchString(m.View()); got != added {
+			t.Errorf("%s: flag modal marks --amend added = %v, want %v", cmd, got, added)
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	}
+}
+
+func TestModel_valueFlagUsesHelpValueStyle(t *testing.T) {
+	tree := tarTree()
+	tree.Flags[2].ValueStyle = models.ValueStyleSpace // --file
+	m := tui.NewModel(tree, config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	for _, name := range []string{"--file", "--directory"} {
+		if !navigateTo(m, func(s *tui.Selection) bool {
+			return s.Kind == tui.SelFlag && s.Flag.Name == name
+		}) {
+			t.Fatalf("could not navigate to %s", name)
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+	// --directory has no style recorded, so it keeps the "=" form.
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "tar --file x --directory=x" {
+		t.Errorf("preview = %q, want %q", got, "tar --file x --directory=x")
+	}
+}
+
+func TestModel_negatableFlagCyclesThreeStates(t *testing.T) {
+	root := &models.Node{
+		Name: "git", FullPath: []string{"git"},
+		Flags: []models.Flag{{Name: "--color", ValueType: "bool", Negatable: true}},
+	}
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	if !strings.Contains(m.View(), "--[no-]color") {
+		t.Errorf("tree should show both forms of the flag:\n%s", m.View())
+	}
+	for _, want := range []string{"git --color", "git --no-color", "git"} {
+		if !navigateTo(m, func(s *tui.Selection) bool {
+			return s.Kind == tui.SelFlag && s.Flag.Name == "--color"
+		}) {
+			t.Fatal("could not navigate to --color")
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		if got := strings.Join(m.Preview().Tokens(), " "); got != want {
+			t.Errorf("preview = %q, want %q", got, want)
+		}
+	}
+
+	m.Preview().SetCommand("git --no-color")
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	if v := m.View(); !strings.Contains(v, "✗ --[no-]color") {
+		t.Errorf("flag modal should mark the flag off:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git" {
+		t.Errorf("Enter on an off flag should unset it; preview = %q", got)
+	}
+}
+
+func TestTree_sourcesBadge(t *testing.T) {
+	root := &models.Node{
+		Name: "tool", FullPath: []string{"tool"}, Sources: []string{"help", "man"},
+		Flags: []models.Flag{{Name: "--color", ValueType: "bool", Sources: []string{"man"}}},
+	}
+	cfg := config.DefaultConfig()
+	m := tui.NewModel(root, cfg)
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	if v := m.View(); strings.Contains(v, "[help,man]") {
+		t.Errorf("sources should only be shown with show_sources:\n%s", v)
+	}
+
+	cfg = config.DefaultConfig()
+	cfg.ShowSources = true
+	m = tui.NewModel(root, cfg)
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	v := m.View()
+	if !strings.Contains(v, "tool [help,man]") || !strings.Contains(v, "--color [man]") {
+		t.Errorf("expected source badges on the command and the flag:\n%s", v)
+	}
+}
+
+// ---------- Edit mode ----------
+
+// runColon types line at the : command line and runs it.
+// modalBox returns the lines of the modal box whose first line holding
+// title is in view v, leaving out the panes around it.
+func modalBox(v, title string) string {
+	lines := strings.Split(v, "\n")
+	row, col := -1, -1
+	for i, l := range lines {
+		if j := strings.Index(l, title); j >= 0 {
+			row, col = i, len([]rune(l[:j]))
+			break
+		}
+	}
+	if row < 0 {
+		return ""
+	}
+	r := []rune(lines[row])
+	for col >= 0 && r[col] != '│' {
+		col--
+	}
+	top := row
+	for top > 0 && []rune(lines[top])[col] != '╭' {
+		top--
+	}
+	right := col + 1
+	for t := []rune(lines[top]); right < len(t) && t[right] != '╮'; right++ {
+	}
+	var box []string
+	for i := top; i < len(lines); i++ {
+		r := []rune(lines[i])
+		box = append(box, string(r[col:right+1]))
+		if r[col] == '╰' {
+			break
+		}
+	}
+	return strings.Join(box, "\n")
+}
+
+func runColon(m *tui.Model, line string) {
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(":")})
+	for _, r := range line { // one key each: "delete" at once reads as the Delete key
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+}
+
+func TestModel_editCommands(t *testing.T) {
+	file := override.File{Dir: t.TempDir(), CLI: "git"}
+	root := sampleTree()
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.SetSize(120, 40)
+	if err := m.SetOverrideStore(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if !navigateTo(m, func(s *tui.Selection) bool { return s.Kind == tui.SelCommand && s.Node.Name == "remote" }) {
+		t.Fatal("remote not found")
+	}
+	runColon(m, "describe Manage tracked repositories")
+	runColon(m, "add command prune")
+	runColon(m, "rename rmt")
+	if !strings.Contains(m.View(), "saved to") {
+		t.Errorf("status should say where the change was saved:\n%s", m.View())
+	}
+	rmt := root.Find("rmt")
+	if rmt == nil || rmt.Description != "Manage tracked repositories" || rmt.Find("prune") == nil {
+		t.Fatalf("remote = %+v, want it renamed rmt, described, with prune added", rmt)
+	}
+	if add := rmt.Find("add"); add.FullCommand() != "git rmt add" {
+		t.Errorf("child of a renamed command: %q, want git rmt add", add.FullCommand())
+	}
+
+	m.TreeModel().Top()
+	if !navigateTo(m, func(s *tui.Selection) bool { return s.Kind == tui.SelFlag && s.Flag.Name == "--amend" }) {
+		t.Fatal("--amend not found")
+	}
+	runColon(m, "delete")
+	if slices.ContainsFunc(root.Find("commit").Flags, func(f models.Flag) bool { return f.Name == "--amend" }) {
+		t.Error("--amend was not deleted")
+	}
+
+	// The saved override makes the same changes to a fresh tree.
+	o, err := file.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh := sampleTree()
+	if stale := override.Apply(fresh, o); len(stale) > 0 {
+		t.Errorf("stale overrides %v", stale)
+	}
+	if fresh.Find("rmt") == nil || fresh.FindPath([]string{"rmt", "prune"}) == nil || len(fresh.Find("commit").Flags) != 2 {
+		t.Errorf("saved override did not reproduce the edits: %+v", fresh.Children)
+	}
+}
+
+func TestModel_editCommandsNeedEditMode(t *testing.T) {
+	root := sampleTree()
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.SetSize(120, 40)
+	runColon(m, "delete")
+	if !strings.Contains(m.View(), "edit mode is off") {
+		t.Errorf("editing outside edit mode should be refused:\n%s", m.View())
+	}
+	if len(root.Children) != 2 {
+		t.Error("the tree was changed outside edit mode")
+	}
+}
+
+func TestModel_editDeleteAddedCommand(t *testing.T) {
+	file := override.File{Dir: t.TempDir(), CLI: "git"}
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	if err := m.SetOverrideStore(file); err != nil {
+		t.Fatal(err)
+	}
+	runColon(m, "add command lfs")
+	if !navigateTo(m, func(s *tui.Selection) bool { return s.Kind == tui.SelCommand && s.Node.Name == "lfs" }) {
+		t.Fatal("lfs was not added")
+	}
+	runColon(m, "delete")
+	o, err := file.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Commands) != 0 {
+		t.Errorf("deleting an added command should drop it from the override, got %+v", o.Commands)
+	}
+}
+
+type memNoteStore struct{ notes map[string]string }
+
+func (s *memNoteStore) Notes() map[string]string { return maps.Clone(s.notes) }
+
+func (s *memNoteStore) SaveNote(command, note string) error {
+	if note == "" {
+		delete(s.notes, command)
+	} else {
+		s.notes[command] = note
+	}
+	return nil
+}
+
+func TestModel_notes(t *testing.T) {
+	store := &memNoteStore{notes: map[string]string{"git commit": "sign with -S"}}
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.SetNoteStore(store)
+
+	if !navigateTo(m, func(s *tui.Selection) bool { return s.Kind == tui.SelCommand && s.Node.Name == "commit" }) {
+		t.Fatal("commit not found")
+	}
+	if !strings.Contains(m.View(), "Note: sign with -S") {
+		t.Errorf("help pane should show the saved note:\n%s", m.View())
+	}
+
+	// Ctrl+N on a flag edits the note of its command.
+	if !navigateTo(m, func(s *tui.Selection) bool { return s.Kind == tui.SelFlag && s.Flag.Name == "--amend" }) {
+		t.Fatal("--amend not found")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlN})
+	if !strings.Contains(m.View(), "Note on git commit") {
+		t.Fatalf("Ctrl+N should open the note prompt:\n%s", m.View())
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlU}) // clear the text the prompt starts with
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("needs --context=prod")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := store.notes["git commit"]; got != "needs --context=prod" {
+		t.Errorf("saved note = %q, want the typed one", got)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlN})
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlU})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if _, ok := store.notes["git commit"]; ok {
+		t.Error("saving an empty note should delete it")
+	}
+}
+
+func TestModel_notesNeedStore(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlN})
+	if strings.Contains(m.View(), "Note on") || !strings.Contains(m.View(), "cache") {
+		t.Errorf("without a store Ctrl+N should only explain why:\n%s", m.View())
+	}
+}
+
+type memSnippetStore struct{ snippets map[string]string }
+
+func (s *memSnippetStore) Snippets() map[string]string { return maps.Clone(s.snippets) }
+
+func (s *memSnippetStore) SaveSnippet(name, command string) error {
+	s.snippets[name] = command
+	return nil
+}
+
+func (s *memSnippetStore) DeleteSnippet(name string) error {
+	delete(s.snippets, name)
+	return nil
+}
+
+func TestModel_snippets(t *testing.T) {
+	store := &memSnippetStore{snippets: map[string]string{}}
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.SetSnippetStore(store)
+
+	if !navigateTo(m, func(s *tui.Selection) bool { return s.Kind == tui.SelFlag && s.Flag.Name == "--message" }) {
+		t.Fatal("--message not found")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("{{msg}}")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	runColon(m, "save wip")
+	saved := store.snippets["wip"]
+	if !strings.HasPrefix(saved, "git commit") || !strings.Contains(saved, "{{msg}}") {
+		t.Fatalf("saved snippet = %q, want the preview with its placeholder", saved)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlK})
+	runColon(m, "snippets")
+	if v := m.View(); !strings.Contains(v, "Snippets") || !strings.Contains(v, "wip") {
+		t.Fatalf(":snippets should list the saved snippet:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if !strings.Contains(m.View(), "{{msg}}") {
+		t.Fatalf("loading the snippet should prompt for {{msg}}:\n%s", m.View())
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("fixup")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	got := strings.Join(m.Preview().Tokens(), " ")
+	if got != strings.ReplaceAll(saved, "{{msg}}", "fixup") {
+		t.Errorf("preview = %q, want the snippet with {{msg}} filled in", got)
+	}
+
+	runColon(m, "snippets")
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if len(store.snippets) != 0 {
+		t.Errorf("x should delete the snippet, left %v", store.snippets)
+	}
+}
+
+func TestModel_snippetsNeedStore(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	runColon(m, "save wip")
+	if !strings.Contains(m.View(), "cache") {
+		t.Errorf("without a store :save should explain why it cannot:\n%s", m.View())
+	}
+}
+
+func TestModel_placeholdersOnExecute(t *testing.T) {
+	h := &memHistory{values: map[string][]string{}}
+	start := func() *tui.Model {
+		m := tui.NewModel(sampleTree(), config.DefaultConfig())
+		m.SetSize(120, 40)
+		m.SetValueHistory(h)
+		m.Preview().SetCommand("git commit --message {{msg}} {{msg}}")
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+		return m
+	}
+
+	m := start()
+	if !strings.Contains(m.View(), "{{msg}}") {
+		t.Fatalf("Ctrl+E should prompt for {{msg}}:\n%s", m.View())
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("fixup")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if got, want := m.CommandToRun(), "git commit --message fixup fixup"; got != want {
+		t.Errorf("CommandToRun() = %q, want %q", got, want)
+	}
+	if got := strings.Join(m.Preview().Tokens(), " "); !strings.Contains(got, "{{msg}}") {
+		t.Errorf("preview = %q, want it to keep its placeholders", got)
+	}
+
+	// The value is remembered for the next prompt for {{msg}}.
+	m = start()
+	if v := m.View(); !strings.Contains(v, "suggestions") || !strings.Contains(v, "fixup") {
+		t.Fatalf("the {{msg}} prompt should suggest the value given before:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	if !strings.Contains(m.View(), "{{msg}}") {
+		t.Errorf("Ctrl+E after Esc should prompt for {{msg}} again:\n%s", m.View())
+	}
+}
+
+func TestModel_dangerousCommandNeedsConfirmation(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DangerPatterns = []string{"commit --amend"}
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(120, 40)
+	m.Preview().SetCommand("git commit --amend wip")
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	if v := m.View(); !strings.Contains(v, "Dangerous") || !strings.Contains(v, "commit --amend") {
+		t.Fatalf("the execute modal should flag the matched pattern:\n%s", v)
+	}
+
+	// r is typed into the confirmation rather than running the command.
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.CommandToRun() != "" {
+		t.Fatalf("the command ran without the typed confirmation")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("yes")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := m.CommandToRun(); got != "git commit --amend wip" {
+		t.Errorf("CommandToRun() = %q after typing yes", got)
+	}
+
+	// A command matching no pattern runs with r as before.
+	m = tui.NewModel(sampleTree(), cfg)
+	m.SetSize(120, 40)
+	m.Preview().SetCommand("git commit --all wip")
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if got := m.CommandToRun(); got != "git commit --all wip" {
+		t.Errorf("CommandToRun() = %q, want the safe command run with r", got)
+	}
+}
+
+type memRunLog struct{ runs []models.Run }
+
+func (l *memRunLog) Runs() []models.Run { return slices.Clone(l.runs) }
+
+func (l *memRunLog) AddRun(run models.Run) error {
+	l.runs = append([]models.Run{run}, l.runs...)
+	return nil
+}
+
+func TestModel_runs(t *testing.T) {
+	log := &memRunLog{runs: []models.Run{
+		{Command: "git commit --amend", StartedAt: time.Now(), ExitCode: 1, Stderr: "nothing to amend"},
+		{Command: "git remote add", StartedAt: time.Now().Add(-time.Hour), Stdout: "added origin"},
+	}}
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.SetRunLog(log)
+
+	runColon(m, "runs")
+	v := m.View()
+	if !strings.Contains(v, "Runs") || !strings.Contains(v, "exit 1") || !strings.Contains(v, "git remote add") {
+		t.Fatalf(":runs should list the recorded runs:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if v := m.View(); !strings.Contains(v, "nothing to amend") || !strings.Contains(v, "stderr") {
+		t.Fatalf("Enter should show the run's output:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if v := m.View(); !strings.Contains(v, "added origin") {
+		t.Fatalf("Esc should go back to the list, and Enter show the second run:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if got := strings.Join(m.Preview().Tokens(), " "); got != "git remote add" {
+		t.Errorf("p should load the run's command into the preview, got %q", got)
+	}
+	if strings.Contains(m.View(), "Runs") {
+		t.Errorf("p should close the overlay:\n%s", m.View())
+	}
+}
+
+func TestModel_runsNeedLog(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	runColon(m, "runs")
+	if !strings.Contains(m.View(), "cache") {
+		t.Errorf("without a log :runs should explain why it cannot:\n%s", m.View())
+	}
+}
+
+type memEnvStore struct{ env map[string]string }
+
+func (s *memEnvStore) Env() map[string]string { return maps.Clone(s.env) }
+
+func (s *memEnvStore) SetEnv(name, value string) error {
+	if value == "" {
+		delete(s.env, name)
+	} else {
+		s.env[name] = value
+	}
+	return nil
+}
+
+func TestModel_env(t *testing.T) {
+	store := &memEnvStore{env: map[string]string{"GIT_PAGER": "cat"}}
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.SetEnvStore(store)
+
+	runColon(m, "env")
+	if v := m.View(); !strings.Contains(v, "Environment") || !strings.Contains(v, "GIT_PAGER=cat") {
+		t.Fatalf(":env should list the saved variables:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("GIT_DIR=my repo")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if _, ok := store.env["GIT_DIR"]; ok || !strings.Contains(m.View(), "cannot hold spaces") {
+		t.Fatalf("a value with a space should be refused:\n%s", m.View())
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlU})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("GIT_DIR=/tmp/repo")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if store.env["GIT_DIR"] != "/tmp/repo" {
+		t.Fatalf("the variable should be saved, store has %v", store.env)
+	}
+	// GIT_DIR sorts before GIT_PAGER, which x deletes.
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if _, ok := store.env["GIT_PAGER"]; ok {
+		t.Errorf("x should delete GIT_PAGER, store has %v", store.env)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+	m.Preview().SetCommand("git commit --all wip")
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if got, want := m.CommandToRun(), "GIT_DIR=/tmp/repo git commit --all wip"; got != want {
+		t.Errorf("CommandToRun() = %q, want %q", got, want)
+	}
+}
+
+type memUsageLog struct{ uses map[string]int }
+
+func (l *memUsageLog) Uses() map[string]int { return maps.Clone(l.uses) }
+
+func (l *memUsageLog) AddUse(item string) { l.uses[item]++ }
+
+func TestModel_usedSortOrder(t *testing.T) {
+	log := &memUsageLog{uses: map[string]int{"git remote": 3, "git commit --amend": 2}}
+	cfg := config.DefaultConfig()
+	cfg.Sort = config.SortUsed
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(120, 40)
+	m.SetUsageLog(log)
+	m.TreeModel().ExpandAll()
+
+	v := m.TreeModel().View()
+	if strings.Index(v, "remote") > strings.Index(v, "commit") {
+		t.Errorf("the more used remote should be listed before commit:\n%s", v)
+	}
+	if strings.Index(v, "--amend") > strings.Index(v, "--message") {
+		t.Errorf("the more used --amend should be listed before --message:\n%s", v)
+	}
+
+	if !navigateTo(m, func(s *tui.Selection) bool {
+		return s.Kind == tui.SelCommand && s.Node.Name == "commit"
+	}) {
+		t.Fatal("commit not found")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if log.uses["git commit"] != 1 {
+		t.Errorf("picking commit should count it, log has %v", log.uses)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	box := modalBox(m.View(), "Add Flag")
+	if strings.Index(box, "--amend") > strings.Index(box, "--message") {
+		t.Errorf("the flag picker should list --amend first:\n%s", box)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+	m.Preview().SetCommand("git commit -a --amend wip")
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	want := map[string]int{"git remote": 3, "git commit": 2, "git commit --all": 1, "git commit --amend": 3}
+	if !maps.Equal(log.uses, want) {
+		t.Errorf("running the command should count it and its flags, log has %v, want %v", log.uses, want)
+	}
+}
+
+func TestModel_runInSplit(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsFile + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "tmux"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1,0")
+	t.Setenv("KITTY_WINDOW_ID", "")
+	t.Setenv("WEZTERM_PANE", "")
+
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.Preview().SetCommand("git commit --all wip")
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	if cmd == nil {
+		t.Fatalf("t should start the command in a tmux pane:\n%s", m.View())
+	}
+	pump(m, cmd, 1)
+	if m.CommandToRun() != "" {
+		t.Error("running in a split should keep the TUI open")
+	}
+	if v := m.View(); strings.Contains(v, "Execute Command") || !strings.Contains(v, "running in a tmux pane") {
+		t.Errorf("the modal should close and the status tell where the command runs:\n%s", v)
+	}
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := string(data); !strings.HasPrefix(args, "split-window\n") || !strings.Contains(args, "git commit --all wip;") {
+		t.Errorf("tmux was run with %q, want split-window running the command", args)
+	}
+
+	// Outside a terminal that splits, the modal offers no split.
+	t.Setenv("TMUX", "")
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	if v := m.View(); strings.Contains(v, "split") {
+		t.Errorf("the modal should not offer a split without tmux, kitty or WezTerm:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	if v := m.View(); !strings.Contains(v, "Execute Command") {
+		t.Errorf("t should leave the modal open without a split to run in:\n%s", v)
+	}
+}
+
+func TestModel_compare(t *testing.T) {
+	root := &models.Node{
+		Name: "kubectl", FullPath: []string{"kubectl"},
+		Children: []*models.Node{
+			{
+				Name: "apply", FullPath: []string{"kubectl", "apply"},
+				Flags: []models.Flag{
+					{Name: "--filename", ShortName: "f", ValueType: "string", Required: true},
+					{Name: "--dry-run", ValueType: "string"},
+					{Name: "--prune"},
+				},
+			},
+			{
+				Name: "create", FullPath: []string{"kubectl", "create"},
+				Flags: []models.Flag{
+					{Name: "--filename", ShortName: "f", ValueType: "string", Required: true},
+					{Name: "--dry-run"},
+					{Name: "--edit"},
+				},
+				Positionals: []models.Positional{{Name: "resource"}},
+			},
+		},
+	}
+	cfg := config.DefaultConfig()
+	cfg.StatusMsgTimeout = 0 // the status bar shows hints, not the last message
+	m := tui.NewModel(root, cfg)
+	m.SetSize(120, 40)
+	isCommand := func(name string) func(*tui.Selection) bool {
+		return func(s *tui.Selection) bool { return s.Kind == tui.SelCommand && s.Node.Name == name }
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("C")})
+	if got := m.Messages(); !strings.Contains(got[len(got)-1], "pin a command") {
+		t.Errorf("C without a pinned command: messages = %v", got)
+	}
+
+	if !navigateTo(m, isCommand("apply")) {
+		t.Fatal("apply not found")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("P")})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("C")})
+	if v := m.View(); strings.Contains(v, "Compare") {
+		t.Errorf("comparing the pinned command with itself should not open:\n%s", v)
+	}
+	if !navigateTo(m, isCommand("create")) {
+		t.Fatal("create not found")
+	}
+	if v := m.View(); !strings.Contains(v, "pinned: kubectl apply") {
+		t.Errorf("the status bar should show the pinned command:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("C")})
+	v := m.View()
+	for _, want := range []string{"Compare", "kubectl apply", "kubectl create", "4 differences",
+		"--filename, -f <string> (required)", "--dry-run <string>", "--prune", "--edit", "[<resource>]"} {
+		if !strings.Contains(v, want) {
+			t.Errorf("comparison should show %q:\n%s", want, v)
+		}
+	}
+
+	// d hides what the commands share.
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	if v := modalBox(m.View(), "Compare"); strings.Contains(v, "--filename") || !strings.Contains(v, "--prune") {
+		t.Errorf("d should show the differences only:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if v := m.View(); strings.Contains(v, "Compare") {
+		t.Errorf("Esc should close the comparison:\n%s", v)
+	}
+
+	// P on another command moves the pin; on the pinned one it unpins.
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("P")})
+	if v := m.View(); !strings.Contains(v, "pinned: kubectl create") {
+		t.Errorf("P should pin create instead:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("P")})
+	if got := m.Messages(); got[len(got)-1] != "unpinned kubectl create" {
+		t.Errorf("P on the pinned command: messages = %v", got)
+	}
+}
+
+func TestModel_goto(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	selected := func() string {
+		if n := m.TreeModel().Selected(); n != nil {
+			return n.FullCommand()
+		}
+		return ""
+	}
+
+	for _, tc := range []struct{ line, want string }{
+		{"goto remote add", "git remote add"},
+		{"goto git/remote/add", "git remote add"},
+		{"g rem ad", "git remote add"},
+		{"goto add", "git remote add"},
+		{"goto cmt", "git commit"},
+		{"goto git", "git"},
+		{"remote add", "git remote add"}, // no command: a path to go to
+	} {
+		m.TreeModel().CollapseAll()
+		runColon(m, tc.line)
+		if got := selected(); got != tc.want {
+			t.Errorf(":%s selected %q, want %q", tc.line, got, tc.want)
+		}
+	}
+
+	m.TreeModel().CollapseAll()
+	runColon(m, "goto zzz")
+	if got := m.Messages(); got[len(got)-1] != "no command matches zzz" || selected() != "git" {
+		t.Errorf(":goto zzz: messages = %v, selected %q", got, selected())
+	}
+	runColon(m, "bogus")
+	if got := m.Messages(); got[len(got)-1] != "unknown command: bogus" {
+		t.Errorf(":bogus: messages = %v", got)
+	}
+}
+
+func TestTree_countsAndPaging(t *testing.T) {
+	root := &models.Node{Name: "big", FullPath: []string{"big"}}
+	for i := range 100 {
+		name := fmt.Sprintf("c%02d", i)
+		root.Children = append(root.Children, &models.Node{Name: name, FullPath: []string{"big", name}})
+	}
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("S")}) // one row per command
+	keys := func(ks ...tea.KeyMsg) string {
+		for _, k := range ks {
+			m.Update(k)
+		}
+		return m.TreeModel().Selected().Name
+	}
+	r := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	if got := keys(r("1"), r("2"), tea.KeyMsg{Type: tea.KeyDown}); got != "c11" {
+		t.Errorf("12↓ selected %s, want c11", got)
+	}
+	if got := keys(r("3"), tea.KeyMsg{Type: tea.KeyUp}); got != "c08" {
+		t.Errorf("3↑ selected %s, want c08", got)
+	}
+	if got := keys(tea.KeyMsg{Type: tea.KeyDown}); got != "c09" {
+		t.Errorf("a count should apply to one motion only: ↓ selected %s, want c09", got)
+	}
+	if got := keys(r("2"), r("0"), r("G")); got != "c18" {
+		t.Errorf("20G selected %s, want c18 on row 20", got)
+	}
+	if got := keys(r("5"), r("g"), r("g")); got != "c03" {
+		t.Errorf("5gg selected %s, want c03 on row 5", got)
+	}
+	if got := keys(tea.KeyMsg{Type: tea.KeyEnd}); got != "c99" {
+		t.Errorf("End selected %s, want c99", got)
+	}
+	if got := keys(tea.KeyMsg{Type: tea.KeyHome}, tea.KeyMsg{Type: tea.KeyDown}); got != "c00" {
+		t.Errorf("Home ↓ selected %s, want c00", got)
+	}
+
+	// A page is the rows the pane shows; the view scrolls with the cursor.
+	down := keys(tea.KeyMsg{Type: tea.KeyPgDown})
+	var page int
+	fmt.Sscanf(down, "c%d", &page)
+	if page < 10 {
+		t.Fatalf("PgDn selected %s, want a screenful down", down)
+	}
+	if v := m.View(); strings.Contains(v, "c00") || !strings.Contains(v, down) {
+		t.Errorf("PgDn should scroll c00 out of view and %s in:\n%s", down, v)
+	}
+	if got := keys(tea.KeyMsg{Type: tea.KeyPgUp}); got != "c00" {
+		t.Errorf("PgUp selected %s, want c00 back", got)
+	}
+}
+
+func TestVim_schemeKeys(t *testing.T) {
+	root := &models.Node{Name: "big", FullPath: []string{"big"}}
+	for i := range 100 {
+		name := fmt.Sprintf("c%02d", i)
+		child := &models.Node{Name: name, FullPath: []string{"big", name}}
+		child.Children = []*models.Node{{Name: "sub", FullPath: []string{"big", name, "sub"}}}
+		root.Children = append(root.Children, child)
+	}
+	m := tui.NewModel(root, config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.SetScheme(tui.SchemeVim)
+	r := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	keys := func(ks ...tea.KeyMsg) string {
+		for _, k := range ks {
+			m.Update(k)
+		}
+		return m.TreeModel().Selected().Name
+	}
+	m.Update(r("S")) // one row per command
+
+	half := keys(tea.KeyMsg{Type: tea.KeyCtrlD})
+	if half == "big" {
+		t.Fatal("Ctrl+D should move half a page down")
+	}
+	full := keys(tea.KeyMsg{Type: tea.KeyCtrlU}, tea.KeyMsg{Type: tea.KeyCtrlF})
+	if full <= half {
+		t.Errorf("Ctrl+F selected %s, want further down than Ctrl+D's %s", full, half)
+	}
+	if got := keys(tea.KeyMsg{Type: tea.KeyCtrlB}); got != "big" {
+		t.Errorf("Ctrl+B selected %s, want big back", got)
+	}
+
+	// zt puts the cursor row at the top of the pane.
+	keys(r("4"), r("0"), r("G"))
+	m.Update(r("z"))
+	m.Update(r("t"))
+	if lines := strings.Split(m.View(), "\n"); !strings.Contains(strings.Join(lines[:6], "\n"), "c38") {
+		t.Errorf("zt should scroll c38 to the top of the tree:\n%s", m.View())
+	}
+	if m.TreeModel().Selected().Name != "c38" {
+		t.Error("zt should leave the cursor where it is")
+	}
+
+	// za expands the node; L and H expand and collapse its subtree.
+	rows := m.TreeModel().RowCount()
+	keys(r("z"), r("a"))
+	if m.TreeModel().RowCount() != rows+1 {
+		t.Errorf("za should expand c38: %d rows, want %d", m.TreeModel().RowCount(), rows+1)
+	}
+	keys(r("H"))
+	if m.TreeModel().RowCount() != rows {
+		t.Errorf("H should collapse the subtree of c38, not toggle the help pane: %d rows, want %d", m.TreeModel().RowCount(), rows)
+	}
+	keys(r("L"))
+	if m.TreeModel().RowCount() != rows+1 {
+		t.Errorf("L should expand the subtree of c38: %d rows, want %d", m.TreeModel().RowCount(), rows+1)
+	}
+
+	// ? toggles the help pane under vim; :help shows the key bindings.
+	if v := m.View(); !strings.Contains(v, "Help: c38") {
+		t.Fatalf("the help pane should show to begin with:\n%s", v)
+	}
+	m.Update(r("?"))
+	if v := m.View(); strings.Contains(v, "Key Bindings") || strings.Contains(v, "Help: c38") {
+		t.Errorf("? should hide the help pane under vim:\n%s", v)
+	}
+	runColon(m, "help")
+	if v := m.View(); !strings.Contains(v, "Key Bindings") {
+		t.Errorf(":help should show the key bindings:\n%s", v)
+	}
+}
+
+func TestModel_keybindSearch(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 60)
+	r := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	m.Update(r("?"))
+	v := m.View()
+	for _, want := range []string{"Key Bindings", "Navigation", "↑ / ↓", "Flag Picker (f)", "scheme: arrows"} {
+		if !strings.Contains(v, want) {
+			t.Errorf("the overlay should show %q:\n%s", want, v)
+		}
+	}
+	if strings.Contains(v, "Vim Scheme") {
+		t.Errorf("the vim bindings should show in the vim scheme only:\n%s", v)
+	}
+
+	m.Update(r("/"))
+	for _, k := range "pinned" {
+		m.Update(r(string(k)))
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	v = m.View()
+	if !strings.Contains(v, "Compare the pinned command") || strings.Contains(v, "Navigation") {
+		t.Errorf("searching pinned should show only the bindings about it:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if v := m.View(); !strings.Contains(v, "Navigation") {
+		t.Errorf("Esc should clear the search:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if v := m.View(); strings.Contains(v, "Key Bindings") {
+		t.Errorf("a second Esc should close the overlay:\n%s", v)
+	}
+
+	// The keys shown are those of the active scheme.
+	m.SetScheme(tui.SchemeVim)
+	m.Update(tea.KeyMsg{Type: tea.KeyF1})
+	v = m.View()
+	if !strings.Contains(v, "Vim Scheme") || !strings.Contains(v, "k / j") || strings.Contains(v, "Shift+→") {
+		t.Errorf("the vim scheme's keys should show:\n%s", v)
+	}
+}
+
+func TestModel_tour(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	r := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	m.StartTour()
+	if v := m.View(); !strings.Contains(v, "Welcome to treemand") || !strings.Contains(v, "1/6") {
+		t.Fatalf("the tour should open on its first step:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if v := m.View(); !strings.Contains(v, "The preview bar") {
+		t.Errorf("→ and Enter should each go a step on:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	if v := m.View(); !strings.Contains(v, "The tree") {
+		t.Errorf("← should go a step back:\n%s", v)
+	}
+	// Keys don't reach the tree while the tour is open.
+	before := m.TreeModel().Selected()
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if m.TreeModel().Selected() != before {
+		t.Error("↓ should not move the tree cursor during the tour")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if v := m.View(); strings.Contains(v, "The tree ") {
+		t.Errorf("Esc should close the tour:\n%s", v)
+	}
+	if msgs := m.Messages(); len(msgs) == 0 || !strings.Contains(msgs[len(msgs)-1], ":tour") {
+		t.Errorf("closing the tour should say how to show it again, got %q", msgs)
+	}
+
+	// :tour shows it again, from the start, and the last step's Enter
+	// closes it.
+	runColon(m, "tour")
+	if v := m.View(); !strings.Contains(v, "Welcome to treemand") {
+		t.Fatalf(":tour should open the tour:\n%s", v)
+	}
+	for range 6 {
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+	if v := m.View(); strings.Contains(v, "That's it") || strings.Contains(v, "Welcome") {
+		t.Errorf("Enter on the last step should close the tour:\n%s", v)
+	}
+
+	// So does t in the ? overlay.
+	m.Update(r("?"))
+	m.Update(r("t"))
+	if v := m.View(); !strings.Contains(v, "Welcome to treemand") || strings.Contains(v, "Key Bindings") {
+		t.Errorf("t in the ? overlay should open the tour in its place:\n%s", v)
+	}
+}
+
+func TestModel_accessible(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SetAccessible()
+	m := tui.NewModel(sampleTree(), cfg)
+	m.SetSize(120, 40)
+
+	navigateModelTo(m, "commit")
+	v := m.View()
+	if !strings.Contains(v, "--message [str]") || !strings.Contains(v, "--all [bool]") {
+		t.Errorf("flag types should be spelled out as tags:\n%s", v)
+	}
+	for _, glyph := range []string{"╭", "│", "▶"} {
+		if strings.Contains(v, glyph) {
+			t.Errorf("accessibility mode should draw no %q:\n%s", glyph, v)
+		}
+	}
+
+	runColon(m, "plain")
+	v = m.View()
+	for _, want := range []string{"Selected: git commit (row ", "Command line: git", "Flags:", "  --message, -m [str]", "  --all, -a [bool]", "Status: plain view: on"} {
+		if !strings.Contains(v, want) {
+			t.Errorf("the plain view should show %q:\n%s", want, v)
+		}
+	}
+	if strings.Contains(v, "+--") {
+		t.Errorf("the plain view should have no boxes:\n%s", v)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if v := m.View(); !strings.Contains(v, "Selected: ") || strings.Contains(v, "Selected: git commit (") {
+		t.Errorf("the plain view should follow the cursor:\n%s", v)
+	}
+	runColon(m, "plain")
+	if v := m.View(); strings.Contains(v, "Command line: ") || !strings.Contains(v, "+--") {
+		t.Errorf(":plain again should bring the panes back:\n%s", v)
+	}
+}
+
+func TestModel_modalOverlaysPanes(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	m.SetSize(120, 40)
+	m.StartTour()
+	v := m.View()
+	if !strings.Contains(v, "Welcome to treemand") {
+		t.Fatalf("the tour should be open:\n%s", v)
+	}
+	// The panes stay in view around the box, and the frame keeps its size.
+	for _, want := range []string{"Help: git", "▼ git", "[arrows]"} {
+		if !strings.Contains(v, want) {
+			t.Errorf("the panes should show around the modal, missing %q:\n%s", want, v)
+		}
+	}
+	if n := strings.Count(v, "\n") + 1; n != 40 {
+		t.Errorf("the frame has %d lines, want 40", n)
+	}
+	box := modalBox(v, "Welcome to treemand")
+	if strings.Contains(box, "Help: git") || !strings.Contains(box, "Esc close") {
+		t.Errorf("the box should be drawn over the panes whole:\n%s", box)
+	}
+}
+
+// ---------- Launcher ----------
+
+func TestLauncher(t *testing.T) {
+	now := time.Now()
+	l := tui.NewLauncher([]tui.LauncherEntry{
+		{CLI: "kubectl", Version: "1.30", Commands: 120, Flags: 900, UsedAt: now.Add(-3 * time.Hour)},
+		{CLI: "git", Version: "2.43", Commands: 150, Flags: 1200, UsedAt: now.Add(-50 * time.Hour)},
+	}, config.DefaultConfig())
+	l.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+
+	v := l.View()
+	if !strings.Contains(v, "kubectl  1.30") || !strings.Contains(v, "120 commands · 900 flags · used 3h ago") ||
+		!strings.Contains(v, "used 2d ago") {
+		t.Fatalf("the launcher should list the CLIs with their stats:\n%s", v)
+	}
+	if strings.Index(v, "kubectl") > strings.Index(v, "git") {
+		t.Errorf("the CLIs should keep the order given:\n%s", v)
+	}
+
+	l.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("gi")})
+	if v := l.View(); strings.Contains(v, "kubectl") || !strings.Contains(v, "git") {
+		t.Errorf("typing should filter the CLIs by name:\n%s", v)
+	}
+	_, cmd := l.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if l.Chosen() != "git" || cmd == nil {
+		t.Errorf("Enter should pick git and quit, Chosen() = %q", l.Chosen())
+	}
+
+	// A name no cached CLI matches is picked as typed, to be discovered.
+	l = tui.NewLauncher(nil, config.DefaultConfig())
+	l.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+	if v := l.View(); !strings.Contains(v, "no CLIs cached yet") {
+		t.Errorf("an empty launcher should say how to explore a CLI:\n%s", v)
+	}
+	l.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("docker")})
+	if v := l.View(); !strings.Contains(v, "Enter explores docker") {
+		t.Errorf("the launcher should offer to explore the name typed:\n%s", v)
+	}
+	l.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if l.Chosen() != "docker" {
+		t.Errorf("Chosen() = %q, want the name typed", l.Chosen())
+	}
+
+	// Esc clears the filter, then quits without picking.
+	l = tui.NewLauncher(nil, config.DefaultConfig())
+	l.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if _, cmd := l.Update(tea.KeyMsg{Type: tea.KeyEsc}); cmd != nil {
+		t.Error("the first Esc should only clear the filter")
+	}
+	if _, cmd := l.Update(tea.KeyMsg{Type: tea.KeyEsc}); cmd == nil || l.Chosen() != "" {
+		t.Errorf("the second Esc should quit without picking, Chosen() = %q", l.Chosen())
+	}
+}
+
+// ---------- Tabs ----------
+
+func TestTabs(t *testing.T) {
+	kubectl := &models.Node{
+		Name:     "kubectl",
+		FullPath: []string{"kubectl"},
+		Children: []*models.Node{{Name: "get", FullPath: []string{"kubectl", "get"}}},
+	}
+	git := tui.NewModel(sampleTree(), config.DefaultConfig())
+	tabs := tui.NewTabs(git, tui.NewModel(kubectl, config.DefaultConfig()))
+	tabs.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+
+	v := tabs.View()
+	bar, _, _ := strings.Cut(v, "\n")
+	if !strings.Contains(bar, " git ") || !strings.Contains(bar, " kubectl ") {
+		t.Fatalf("the tab bar should list both CLIs:\n%s", v)
+	}
+	if n := strings.Count(v, "\n") + 1; n != 30 {
+		t.Errorf("the tab bar and tab should fill the 30 lines, got %d:\n%s", n, v)
+	}
+
+	// Each tab keeps its own preview.
+	tabs.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	tabs.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("}")})
+	if tabs.Active() == git {
+		t.Fatal("} should switch to the next tab")
+	}
+	if got := strings.Join(tabs.Active().Preview().Tokens(), " "); got == "git" {
+		t.Errorf("the kubectl tab should not share git's preview, got %q", got)
+	}
+	tabs.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("{")})
+	if tabs.Active() != git || strings.Join(git.Preview().Tokens(), " ") != "git" {
+		t.Errorf("{ should switch back to git with its preview kept, got %v", git.Preview().Tokens())
+	}
+
+	// Typed into the filter, } is text rather than a tab switch.
+	tabs.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	tabs.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("}")})
+	if tabs.Active() != git {
+		t.Error("} typed in the filter should not switch tabs")
+	}
+	tabs.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+	// A click on the bar switches to the tab clicked.
+	x := strings.Index(bar, "kubectl")
+	tabs.Update(tea.MouseMsg{X: x, Y: 0, Action: tea.MouseActionPress, Button: tea.MouseButtonLeft})
+	if tabs.Active() == git {
+		t.Error("clicking kubectl in the tab bar should switch to it")
+	}
+
+	// Quitting a tab quits the session.
+	_, cmd := tabs.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	if cmd == nil {
+		t.Fatal("q should quit")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("q in a tab should reach Bubble Tea as tea.QuitMsg")
+	}
+}
+
+func TestTabs_oneTabHasNoBar(t *testing.T) {
+	m := tui.NewModel(sampleTree(), config.DefaultConfig())
+	tabs := tui.NewTabs(m)
+	tabs.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	if got, want := tabs.View(), m.View(); got != want {
+		t.Errorf("one tab should be shown alone:\n%s", got)
+	}
+}
@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/aallbrig/treemand/config"
+	"github.com/aallbrig/treemand/models"
+)
+
+// ---------- usage counts ----------
+
+// UsageLog counts how often each command and flag is added to the preview
+// or run, by usageKey. cache.UsageLog implements it for one CLI.
+type UsageLog interface {
+	Uses() map[string]int
+	AddUse(item string)
+}
+
+// SetUsageLog sets the log commands and flags are counted in, and reads
+// the counts the "used" sort order goes by. A nil log counts them for the
+// session only.
+func (m *Model) SetUsageLog(log UsageLog) {
+	m.usageLog = log
+	m.uses = nil
+	if log != nil {
+		m.uses = log.Uses()
+	}
+	m.tree.SetUses(maps.Clone(m.uses))
+}
+
+// usageKey names command, or its flag when flag is set, in the usage
+// counts: "git commit", "git commit --amend".
+func usageKey(command *models.Node, flag string) string {
+	if flag == "" {
+		return command.FullCommand()
+	}
+	return command.FullCommand() + " " + flag
+}
+
+// use counts command, or its flag when flag is set, as used once more.
+// The tree is re-sorted by the counts only when the sort order is cycled,
+// so rows do not move under the cursor.
+func (m *Model) use(command *models.Node, flag string) {
+	if command == nil || command.Virtual {
+		return
+	}
+	key := usageKey(command, flag)
+	if m.uses == nil {
+		m.uses = map[string]int{}
+	}
+	m.uses[key]++
+	if m.usageLog != nil {
+		m.usageLog.AddUse(key)
+	}
+}
+
+// useCommandLine counts the command of a command line run, and the flags
+// it is given, as used.
+func (m *Model) useCommandLine(line string) {
+	_, tokens := splitEnv(splitWords(line))
+	node, _ := resolveCommand(m.root, tokens)
+	if node == nil {
+		return
+	}
+	m.use(node, "")
+	known := knownFlags(node, m.root)
+	for _, tok := range tokens {
+		ft, ok := parseFlagToken(tok, known)
+		if !ok {
+			continue
+		}
+		for _, name := range ft.names {
+			if f := lookupFlag(known, name); f != nil {
+				m.use(node, f.Name)
+			}
+		}
+	}
+}
+
+// sortFlagEntries orders the flag picker's entries of command the most
+// used first when the sort order is "used", the global flags still after
+// the command's own.
+func (m *Model) sortFlagEntries(entries []flagEntry, command *models.Node) {
+	if m.cfg.Sort != config.SortUsed {
+		return
+	}
+	slices.SortStableFunc(entries, func(a, b flagEntry) int {
+		if a.global != b.global {
+			if a.global {
+				return 1
+			}
+			return -1
+		}
+		return m.uses[usageKey(command, b.flag.Name)] - m.uses[usageKey(command, a.flag.Name)]
+	})
+}